@@ -12,13 +12,16 @@ import (
 	"syscall"
 	"time"
 
+	"saas-chatbot-platform/internal/ai"
 	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/crawler"
 	"saas-chatbot-platform/internal/database"
 	"saas-chatbot-platform/internal/logger"
 	"saas-chatbot-platform/internal/telemetry"
 	"saas-chatbot-platform/middleware"
 	"saas-chatbot-platform/models"
 	"saas-chatbot-platform/routes"
+	"saas-chatbot-platform/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -91,6 +94,12 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to create tenant manager:", err)
 	}
+	// ✅ NEW: Route tenants with a data region to that region's cluster
+	if len(cfg.MongoRegionURIs) > 0 {
+		if err := tenantManager.EnableRegions(cfg.MongoRegionURIs, mongoClient.Database(cfg.DBName).Collection("clients")); err != nil {
+			log.Fatal("Failed to connect regional clusters:", err)
+		}
+	}
 
 	// Initialize OpenTelemetry tracing
 	shutdownTracer, err := telemetry.InitTracer("saas-chatbot-platform")
@@ -118,6 +127,9 @@ func main() {
 	auditLogger := models.NewAuditLogger(db)
 	logger.Info("Audit logging initialized")
 
+	// ✅ NEW: Long-lived Gemini client shared by chat requests
+	aiPool := ai.NewClientPool(cfg.GeminiAPIKey)
+
 	// Initialize Gin router
 	if cfg.GinMode == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -154,7 +166,18 @@ func main() {
 	router.Use(middleware.RequestIDMiddleware())
 
 	// Add request size limit middleware (before CORS)
-	router.Use(middleware.RequestSizeLimit(10 << 20)) // 10 MB for JSON requests
+	// ✅ NEW: Per route group: tiny bodies for the public widget routes, the file size limit for uploads
+	uploadBodyLimit := cfg.MaxFileSize + 1<<20 // multipart boundaries and form fields on top of the file
+	router.Use(middleware.RouteRequestSizeLimit(cfg.MaxRequestBodySize, []middleware.BodySizeRule{
+		{Prefix: "/public/", MaxBytes: cfg.MaxPublicRequestBodySize},
+		{Prefix: "/client/upload", MaxBytes: uploadBodyLimit},
+		{Prefix: "/client/images/bulk", MaxBytes: uploadBodyLimit},
+		{Prefix: "/api/async/upload", MaxBytes: uploadBodyLimit},
+		{Prefix: "/admin/client/:id/documents", MaxBytes: uploadBodyLimit},
+		{Prefix: "/admin/client/:id/images", MaxBytes: uploadBodyLimit},
+		{Prefix: "/admin/client/:id/ai-persona", MaxBytes: uploadBodyLimit},
+		{Prefix: "/admin/default-persona", MaxBytes: uploadBodyLimit},
+	}))
 
 	// Add rate limiting middleware (after CORS, before routes)
 	router.Use(middleware.RateLimitMiddleware(rdb, cfg))
@@ -302,20 +325,22 @@ func main() {
 
 	// Setup routes with new security features
 	routes.SetupAuthRoutes(router, cfg, mongoClient, rdb)
-	routes.SetupAdminRoutes(router, cfg, mongoClient, authMiddleware, roleMiddleware)
-	routes.SetupClientRoutes(router, cfg, mongoClient, authMiddleware, roleMiddleware)
-	routes.SetupChatRoutes(router, cfg, mongoClient, authMiddleware)
+	routes.SetupAdminRoutes(router, cfg, mongoClient, authMiddleware, roleMiddleware, auditLogger)
+	routes.SetupClientRoutes(router, cfg, mongoClient, rdb, authMiddleware, roleMiddleware, auditLogger, aiPool)
+	routes.SetupChatRoutes(router, cfg, mongoClient, authMiddleware, aiPool)
 	routes.SetupEmbedRoutes(router, cfg, mongoClient, authMiddleware)
 
 	// Setup async processing routes
+	clientsCollection := db.Collection("clients")
 	pdfsCollection := db.Collection("pdfs")
 
 	// Async PDF upload routes
 	asyncGroup := router.Group("/api/async")
 	asyncGroup.Use(authMiddleware.RequireAuth())
+	asyncGroup.Use(middleware.NewFeatureCheckMiddleware(clientsCollection).EnforceRouteFeatures(middleware.ClientRouteFeatures))
 	{
-		asyncGroup.POST("/upload", routes.HandleAsyncPDFUpload(cfg, pdfsCollection, queueClient))
-		asyncGroup.GET("/pdf/:fileID/status", routes.CheckPDFStatus(pdfsCollection))
+		asyncGroup.POST("/upload", routes.HandleAsyncPDFUpload(cfg, clientsCollection, pdfsCollection, queueClient, auditLogger))
+		asyncGroup.GET("/pdf/:fileID/status", routes.CheckPDFStatus(cfg, pdfsCollection, rdb))
 		asyncGroup.GET("/pdfs", routes.ListPDFsWithStatus(pdfsCollection))
 	}
 
@@ -329,6 +354,61 @@ func main() {
 		auditGroup.GET("/verify/:clientID", routes.VerifyAuditChain(auditLogger))
 		auditGroup.GET("/stats", routes.GetAuditStats(auditLogger))
 		auditGroup.GET("/export", routes.ExportAuditLogs(auditLogger))
+		auditGroup.POST("/archive", routes.ArchiveAuditLogs(cfg, auditLogger))
+		auditGroup.GET("/archives", routes.ListAuditArchives(auditLogger))
+	}
+
+	// ✅ NEW: Platform-wide maintenance jobs (admin only)
+	maintenanceGroup := router.Group("/api/admin/maintenance")
+	maintenanceGroup.Use(authMiddleware.RequireAuth())
+	maintenanceGroup.Use(roleMiddleware.RequireRole("admin"))
+	{
+		maintenanceGroup.POST("/feedback/process-unanalyzed", routes.HandleBackfillFeedbackAnalysis(db, auditLogger))
+	}
+
+	// ✅ NEW: Cap concurrent page fetches across all crawls so one client can't starve the others
+	crawler.SetGlobalConcurrency(cfg.CrawlGlobalConcurrency)
+
+	// ✅ NEW: Scheduled analytics digest emails
+	if cfg.AnalyticsDigestEnabled {
+		digestScheduler := routes.NewAnalyticsDigestScheduler(cfg, db, auditLogger)
+		go digestScheduler.Start()
+		defer digestScheduler.Stop()
+	}
+
+	// ✅ NEW: Scheduled purge of messages past each client's retention period
+	if cfg.MessageRetentionEnabled {
+		retentionScheduler := routes.NewMessageRetentionScheduler(cfg, db, auditLogger)
+		go retentionScheduler.Start()
+		defer retentionScheduler.Stop()
+	}
+
+	// ✅ NEW: Conversation pushes to client CRMs, with retries
+	if cfg.CRMSyncEnabled {
+		crmSyncScheduler := routes.NewCRMSyncScheduler(cfg, db)
+		go crmSyncScheduler.Start()
+		defer crmSyncScheduler.Stop()
+	}
+
+	// ✅ NEW: Daily analytics rows appended to clients' Google Sheets
+	if cfg.GoogleSheetsSyncEnabled && cfg.GoogleSheetsClientID != "" {
+		sheetsScheduler := routes.NewGoogleSheetsSyncScheduler(cfg, db)
+		go sheetsScheduler.Start()
+		defer sheetsScheduler.Stop()
+	}
+
+	// ✅ NEW: Token usage alert emails for clients crossing a threshold between chats
+	if cfg.TokenAlertScanEnabled {
+		tokenAlertCron := services.NewCronService(*cfg, services.NewSMTPEmailSender(*cfg), db.Collection("clients"))
+		go tokenAlertCron.Start()
+		defer tokenAlertCron.Stop()
+	}
+
+	// ✅ NEW: Scheduled audit chain verification with tamper alerts
+	if cfg.AuditChainCheckEnabled {
+		auditChainMonitor := routes.NewAuditChainMonitor(cfg, db, auditLogger)
+		go auditChainMonitor.Start()
+		defer auditChainMonitor.Stop()
 	}
 
 	// Add tenant database middleware to protected routes
@@ -376,7 +456,7 @@ func main() {
 		log.Printf("   - GET /api/admin/audit/summary/:clientID (audit summary)")
 		log.Printf("   - GET /api/admin/audit/verify/:clientID (verify chain)")
 		log.Printf("   - GET /api/admin/audit/stats (audit statistics)")
-		log.Printf("   - GET /api/admin/audit/export (export logs)")
+		log.Printf("   - GET /api/admin/audit/export (export logs: format=json|ndjson|cef)")
 
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
@@ -396,5 +476,10 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// Requests have drained, so the shared AI client can be closed
+	if err := aiPool.Close(); err != nil {
+		log.Printf("Failed to close AI client pool: %v", err)
+	}
+
 	log.Println("Server exited")
 }
@@ -44,6 +44,11 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create tenant manager: %v", err)
 	}
+	if len(cfg.MongoRegionURIs) > 0 {
+		if err := tenantManager.EnableRegions(cfg.MongoRegionURIs, sharedDB.Collection("clients")); err != nil {
+			log.Fatalf("Failed to connect regional clusters: %v", err)
+		}
+	}
 
 	switch command {
 	case "migrate-to-tenants":
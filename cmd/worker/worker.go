@@ -33,6 +33,11 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to create tenant manager:", err)
 	}
+	if len(cfg.MongoRegionURIs) > 0 {
+		if err := dbManager.EnableRegions(cfg.MongoRegionURIs, mongoClient.Database(cfg.DBName).Collection("clients")); err != nil {
+			log.Fatal("Failed to connect regional clusters:", err)
+		}
+	}
 
 	// Initialize Gemini client
 	geminiClient, err := ai.NewGeminiClient(cfg.GeminiAPIKey, "free")
@@ -59,15 +64,30 @@ func main() {
 				"low":      1, // 10% of workers
 			},
 			StrictPriority: true,
+			// Jobs deferred by the per-client limit are retried shortly without using up their retries
+			IsFailure:      queue.IsTaskFailure,
+			RetryDelayFunc: queue.RetryDelay,
 			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
+				if !queue.IsTaskFailure(err) {
+					return
+				}
 				log.Printf("Task failed: %s, error: %v", task.Type(), err)
 				// Send to error tracking service
 			}),
 		},
 	)
 
+	// Per-client job limiter (shares Redis with the API so status requests can report running jobs)
+	var limiter *queue.ClientLimiter
+	if rdb, err := config.NewRedisClient(cfg); err != nil {
+		log.Printf("⚠️ Per-client PDF job limit disabled: %v", err)
+	} else {
+		defer rdb.Close()
+		limiter = queue.NewClientLimiter(rdb, cfg.PDFJobClientConcurrency)
+	}
+
 	// Create task processor
-	processor := queue.NewTaskProcessor(dbManager, geminiClient, mongoClient)
+	processor := queue.NewTaskProcessor(dbManager, geminiClient, mongoClient, limiter)
 
 	// Create mux and register handlers
 	mux := asynq.NewServeMux()
@@ -77,6 +97,9 @@ func main() {
 	log.Println("🚀 Starting Asynq worker...")
 	log.Printf("   Concurrency: 20")
 	log.Printf("   Queues: critical(6), default(3), low(1)")
+	if limiter != nil {
+		log.Printf("   PDF jobs per client: %d", limiter.Limit())
+	}
 	log.Printf("   Redis: %s", redisOpt.Addr)
 
 	// Start the server
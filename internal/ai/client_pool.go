@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	genai "github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// ErrClientPoolClosed is returned once the pool has been closed at shutdown
+var ErrClientPoolClosed = errors.New("AI client pool is closed")
+
+// ClientPool holds the long-lived Gemini client shared by all chat requests. Previously every request
+// created and closed its own two clients, so each one paid for setting up a new HTTP connection (DNS,
+// TLS handshake) before its first Gemini call; with the pool only the first request does, which the
+// client_init_ms phase timing of the performance metrics shows (0 on a warm pool, vs. typically tens
+// to a few hundred ms per request before).
+//
+// A genai.Client is safe for concurrent use. GenerativeModel values are not, as their settings are
+// changed per request, so callers still create their own model from the shared client.
+type ClientPool struct {
+	apiKey string
+
+	mu     sync.Mutex
+	client *genai.Client
+	closed bool
+}
+
+// NewClientPool creates a pool; the client itself is created on first use
+func NewClientPool(apiKey string) *ClientPool {
+	return &ClientPool{apiKey: apiKey}
+}
+
+// Client returns the shared client, creating it if needed. A failed creation is retried by the next caller.
+func (p *ClientPool) Client() (*genai.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrClientPoolClosed
+	}
+	if p.client == nil {
+		// Not tied to any request's context: the client outlives the request that created it
+		client, err := genai.NewClient(context.Background(), option.WithAPIKey(p.apiKey))
+		if err != nil {
+			return nil, err
+		}
+		p.client = client
+	}
+	return p.client, nil
+}
+
+// GeminiClient returns a GeminiClient on the shared connection. Its rate limiting and circuit breaker
+// state belong to the returned value, and closing it leaves the shared connection open.
+func (p *ClientPool) GeminiClient(tier string) (*GeminiClient, error) {
+	client, err := p.Client()
+	if err != nil {
+		return nil, err
+	}
+	return newGeminiClient(p.apiKey, tier, client, true), nil
+}
+
+// Close closes the shared client; call it at shutdown once requests have drained
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.client == nil {
+		return nil
+	}
+	err := p.client.Close()
+	p.client = nil
+	return err
+}
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ExtractionThrottle limits the Gemini calls made to extract text from uploaded documents (File API
+// upload plus the extraction prompt), so that a burst of uploads cannot use up the quota live chat
+// depends on. At most concurrency extractions run at once and no more than perMinute start each minute.
+// It is safe for concurrent use.
+type ExtractionThrottle struct {
+	slots     chan struct{}
+	limiter   *rate.Limiter // nil = no rate limit
+	perMinute int
+
+	inFlight atomic.Int64
+	waiting  atomic.Int64
+}
+
+var (
+	sharedExtractionThrottle     *ExtractionThrottle
+	sharedExtractionThrottleOnce sync.Once
+)
+
+// NewExtractionThrottle creates a throttle; concurrency <= 0 defaults to 2 and perMinute <= 0 disables the rate limit
+func NewExtractionThrottle(concurrency, perMinute int) *ExtractionThrottle {
+	if concurrency <= 0 {
+		concurrency = 2
+	}
+	t := &ExtractionThrottle{
+		slots:     make(chan struct{}, concurrency),
+		perMinute: perMinute,
+	}
+	if perMinute > 0 {
+		t.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), 1)
+	}
+	return t
+}
+
+// SharedExtractionThrottle returns the process-wide throttle used for Gemini extraction calls.
+// Settings are taken from the first call; later calls return the same instance.
+func SharedExtractionThrottle(concurrency, perMinute int) *ExtractionThrottle {
+	sharedExtractionThrottleOnce.Do(func() {
+		sharedExtractionThrottle = NewExtractionThrottle(concurrency, perMinute)
+	})
+	return sharedExtractionThrottle
+}
+
+// Acquire waits until an extraction may start, or until ctx is done. The returned release must be
+// called once the extraction's Gemini calls have finished.
+func (t *ExtractionThrottle) Acquire(ctx context.Context) (func(), error) {
+	t.waiting.Add(1)
+	defer t.waiting.Add(-1)
+
+	select {
+	case t.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a gemini extraction slot: %w", ctx.Err())
+	}
+
+	if t.limiter != nil {
+		if err := t.limiter.Wait(ctx); err != nil {
+			<-t.slots
+			return nil, fmt.Errorf("waiting for the gemini extraction rate limit: %w", err)
+		}
+	}
+
+	t.inFlight.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.inFlight.Add(-1)
+			<-t.slots
+		})
+	}, nil
+}
+
+// Stats reports the throttle's limits and current load
+func (t *ExtractionThrottle) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"concurrency": cap(t.slots),
+		"per_minute":  t.perMinute,
+		"in_flight":   t.inFlight.Load(),
+		"waiting":     t.waiting.Load(),
+	}
+}
@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExtractionThrottleLimitsConcurrency(t *testing.T) {
+	throttle := NewExtractionThrottle(1, 0)
+
+	release, err := throttle.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := throttle.Acquire(ctx); err == nil {
+		t.Fatal("second extraction should wait while the only slot is taken")
+	}
+
+	release()
+	release() // releasing twice must not free a second slot
+	release2, err := throttle.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("slot not freed: %v", err)
+	}
+	if stats := throttle.Stats(); stats["in_flight"] != int64(1) {
+		t.Errorf("in_flight = %v, want 1", stats["in_flight"])
+	}
+	release2()
+}
+
+func TestExtractionThrottleRateLimit(t *testing.T) {
+	throttle := NewExtractionThrottle(5, 1) // one start per minute
+
+	release, err := throttle.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := throttle.Acquire(ctx); err == nil {
+		t.Fatal("second start within the minute should be rate limited")
+	}
+	if stats := throttle.Stats(); stats["in_flight"] != int64(0) {
+		t.Errorf("a rate-limited acquire must give its slot back, in_flight = %v", stats["in_flight"])
+	}
+}
@@ -24,6 +24,7 @@ type GeminiClient struct {
 	tokenCounter *TokenCounter
 	client       *genai.Client
 	tier         string
+	sharedClient bool // client belongs to a ClientPool and is not closed with this GeminiClient
 }
 
 type TokenCounter struct {
@@ -48,7 +49,10 @@ func NewGeminiClient(apiKey string, tier string) (*GeminiClient, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newGeminiClient(apiKey, tier, client, false), nil
+}
 
+func newGeminiClient(apiKey string, tier string, client *genai.Client, sharedClient bool) *GeminiClient {
 	// Configure rate limits based on tier
 	limits := getRateLimits(tier)
 
@@ -80,7 +84,8 @@ func NewGeminiClient(apiKey string, tier string) (*GeminiClient, error) {
 		tokenCounter: &TokenCounter{},
 		client:       client,
 		tier:         tier,
-	}, nil
+		sharedClient: sharedClient,
+	}
 }
 
 func getRateLimits(tier string) RateLimits {
@@ -219,7 +224,7 @@ func (gc *GeminiClient) getFallbackResponse(prompt string) (*genai.GenerateConte
 			{
 				Content: &genai.Content{
 					Parts: []genai.Part{
-						genai.Text("I'm experiencing high demand right now. Please try again in a moment."),
+						genai.Text(FallbackResponseText),
 					},
 				},
 			},
@@ -288,7 +293,7 @@ func alertOps(message string) {
 
 // Close the client
 func (gc *GeminiClient) Close() error {
-	if gc.client != nil {
+	if gc.client != nil && !gc.sharedClient {
 		return gc.client.Close()
 	}
 	return nil
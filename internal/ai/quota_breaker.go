@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Quota breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// FallbackResponseText is returned to users while Gemini is unavailable
+const FallbackResponseText = "I'm experiencing high demand right now. Please try again in a moment."
+
+// QuotaBreaker trips after repeated quota/resource-exhausted errors so that
+// requests fail fast with a fallback instead of waiting on retries.
+// It is safe for concurrent use.
+type QuotaBreaker struct {
+	mu            sync.Mutex
+	threshold     int
+	window        time.Duration
+	cooldown      time.Duration
+	state         string
+	failures      int
+	firstFailure  time.Time
+	openedAt      time.Time
+	probeInFlight bool
+}
+
+var (
+	sharedQuotaBreaker     *QuotaBreaker
+	sharedQuotaBreakerOnce sync.Once
+)
+
+// NewQuotaBreaker creates a breaker that opens after threshold consecutive
+// quota errors within window and stays open for cooldown.
+func NewQuotaBreaker(threshold int, window, cooldown time.Duration) *QuotaBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	return &QuotaBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// SharedQuotaBreaker returns the process-wide breaker used for Gemini chat calls.
+// Settings are taken from the first call; later calls return the same instance.
+func SharedQuotaBreaker(threshold int, window, cooldown time.Duration) *QuotaBreaker {
+	sharedQuotaBreakerOnce.Do(func() {
+		sharedQuotaBreaker = NewQuotaBreaker(threshold, window, cooldown)
+		sharedQuotaBreaker.registerMetric()
+	})
+	return sharedQuotaBreaker
+}
+
+// Allow reports whether a Gemini call may proceed. While open it returns false
+// until the cooldown has elapsed, then lets a single probe through (half-open).
+func (b *QuotaBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		b.probeInFlight = true
+		return true
+	case BreakerHalfOpen:
+		if b.probeInFlight {
+			return false
+		}
+		b.probeInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Record updates the breaker with the outcome of a Gemini call.
+// Only quota errors count towards tripping; any other outcome proves the
+// quota is available again and closes the breaker.
+func (b *QuotaBreaker) Record(err error, quotaErr bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probeInFlight = false
+
+	if err == nil || !quotaErr {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+
+	now := time.Now()
+	if b.state == BreakerHalfOpen {
+		b.openedAt = now
+		b.setState(BreakerOpen)
+		return
+	}
+
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.window {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+
+	if b.failures >= b.threshold {
+		b.openedAt = now
+		b.setState(BreakerOpen)
+	}
+}
+
+// State returns the current breaker state
+func (b *QuotaBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState must be called with b.mu held
+func (b *QuotaBreaker) setState(to string) {
+	from := b.state
+	b.state = to
+	log.Printf("Gemini quota breaker: %s -> %s", from, to)
+	if to == BreakerOpen {
+		b.failures = 0
+		alertOps("Gemini quota breaker opened - serving fallback responses")
+	}
+}
+
+// registerMetric exposes the breaker state as a gauge (0=closed, 1=half_open, 2=open)
+func (b *QuotaBreaker) registerMetric() {
+	meter := otel.Meter("saas-chatbot-platform")
+	_, err := meter.Int64ObservableGauge(
+		"gemini.quota_breaker.state",
+		metric.WithDescription("Gemini quota circuit breaker state (0=closed, 1=half_open, 2=open)"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			switch b.State() {
+			case BreakerOpen:
+				o.Observe(2)
+			case BreakerHalfOpen:
+				o.Observe(1)
+			default:
+				o.Observe(0)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		log.Printf("Failed to register quota breaker metric: %v", err)
+	}
+}
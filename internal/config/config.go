@@ -31,20 +31,31 @@ type Config struct {
 	FileStorageDir      string
 	SyncProcessingLimit int64
 
+	// Upload virus scanning (disabled unless a clamd address or scanning API is set)
+	VirusScanClamdAddress string // unix:///path/clamd.sock, tcp://host:3310 or host:port
+	VirusScanAPIURL       string // external API receiving the raw file, answering {"infected","signature"}
+	VirusScanAPIKey       string
+	VirusScanTimeout      int  // seconds per scan
+	VirusScanFailOpen     bool // accept uploads when the scanner is unreachable
+
 	// Redis Configuration
 	RedisURL      string
 	RedisPassword string
 	RedisDB       int
 
+	// Async PDF processing
+	PDFJobClientConcurrency int // PDF jobs one client may run at once across all workers
+
 	// JWT Token Secrets
 	AccessSecret  string
 	RefreshSecret string
 
 	// Token Alert Configuration
 	TokenWarnPercent      int    `default:"80"`
-	TokenCriticalPercent  int    `default:"95"`
+	TokenCriticalPercent  int    `default:"90"`
 	TokenExhaustedPercent int    `default:"100"`
 	TokenAlertCron        string `default:"*/15 * * * *"`
+	TokenAlertScanEnabled bool   // periodically email clients crossing a threshold between chats
 
 	// SMTP Configuration
 	SMTPHost    string
@@ -76,6 +87,152 @@ type Config struct {
 
 	// CSRF Protection
 	CSRFSecret string
+
+	// AI Request Logging (sampled, structured)
+	AILogSampleRate float64 // fraction of chat requests logged at debug level (0.0 - 1.0)
+	AILogMaxChars   int     // prompt/response are truncated to this many characters
+
+	// Gemini Quota Circuit Breaker
+	GeminiBreakerThreshold int // consecutive quota errors before opening
+	GeminiBreakerWindow    int // seconds in which the errors must occur
+	GeminiBreakerCooldown  int // seconds the breaker stays open before probing
+
+	// Gemini Document Extraction (throttled apart from chat so a burst of uploads can't use up its quota)
+	GeminiExtractionAPIKey      string // separate key for extraction (empty = GEMINI_API_KEY)
+	GeminiExtractionConcurrency int    // extractions running at once per process
+	GeminiExtractionPerMinute   int    // extractions started per minute per process (0 = unlimited)
+
+	// AI Generation Timeouts
+	AIGenerationTimeout int // seconds a single Gemini call may take (clients can override, up to ChatRequestTimeout)
+	ChatRequestTimeout  int // seconds a public chat request may take overall
+
+	// Default persona cache
+	DefaultPersonaCacheTTL int // seconds the default persona is kept in memory (0 disables the cache)
+
+	// Client config cache
+	ClientConfigCacheTTL int // seconds client documents are kept in memory per instance (0 disables the cache)
+
+	// Admin client overview
+	AdminOverviewCacheSeconds int // seconds the cross-client overview is cached (0 disables the cache)
+
+	// Per-client document quota defaults (overridable on the client document)
+	DefaultMaxDocuments  int
+	DefaultMaxTotalBytes int64
+
+	// Per-client image library limits
+	DefaultMaxImages int   // overridable on the client document
+	MaxImageSize     int64 // bytes per uploaded image
+
+	// Uploaded image optimization
+	ImageOptimizationEnabled bool
+	ImageMaxWidth            int // uploads larger than this are scaled down to fit
+	ImageMaxHeight           int
+	ImageJPEGQuality         int // 1-100
+	ImageThumbnailSize       int // longest side of the stored thumbnail (0 = no thumbnail)
+
+	// Channel integrations
+	PublicAPIURL string // externally reachable base URL used to register webhooks (e.g. Telegram)
+
+	// Analytics digest emails
+	AnalyticsDigestEnabled       bool // run the digest scheduler in this process
+	AnalyticsDigestCheckInterval int  // minutes between scans for due digests
+
+	// Message retention
+	MessageRetentionEnabled  bool // run the retention purge job in this process
+	MessageRetentionInterval int  // hours between purges of a client's expired messages
+
+	// Audit chain monitoring
+	AuditChainCheckEnabled  bool // run the scheduled audit chain verification in this process
+	AuditChainCheckInterval int  // hours between verifications of every client's audit chain
+
+	// CRM conversation sync
+	CRMSyncEnabled       bool // run the CRM delivery job in this process
+	CRMSyncCheckInterval int  // seconds between scans for due deliveries
+	CRMSyncTimeout       int  // seconds to wait for a client's CRM endpoint
+	CRMSyncMaxAttempts   int  // deliveries are marked failed after this many attempts
+
+	// Outbound webhook headers
+	WebhookHeadersKey string // encrypts clients' custom webhook header values at rest (falls back to JWT_SECRET)
+
+	// Quality alerts
+	QualityAlertCooldownHours int // an open alert absorbs repeats of its condition seen within this window
+
+	// Request body size limits (upload routes use MaxFileSize)
+	MaxRequestBodySize       int64 // bytes, for routes without a specific limit
+	MaxPublicRequestBodySize int64 // bytes, for the unauthenticated /public routes (widget chat, feedback, quotes)
+
+	// Google Sheets analytics export
+	GoogleSheetsClientID     string // OAuth client used for clients' Sheets consent (export is disabled when empty)
+	GoogleSheetsClientSecret string
+	GoogleSheetsSyncEnabled  bool // run the Sheets export job in this process
+	GoogleSheetsSyncInterval int  // minutes between scans for sheets missing yesterday's row
+	GoogleSheetsBackfillDays int  // days of history appended when a sheet is first connected
+
+	// Audit log retention
+	AuditRetentionDays     int    // entries older than this are exported and purged by the archive endpoint
+	AuditArchiveDir        string // local directory signed audit exports are written to; copy them off the host
+	AuditArchiveSigningKey string // HMAC key for audit exports (archiving is disabled when empty)
+
+	// Public chat
+	SuspendedWidgetMessage      string // shown by the widgets of suspended or inactive clients (overridable per suspension)
+	ChatDedupWindowSeconds      int    // identical messages in a session within this window get the original reply (0 = off)
+	PromptTemplatePath          string // optional file with the deployment-wide system prompt template
+	PromptSnapshotRetentionDays int    // days a reply's redacted prompt is kept for clients with prompt inspection
+	SpamFilterEnabled           bool
+	SpamThreshold               float64  // spam score (0-1) at or above which messages skip generation
+	DefaultSessionTokenCap      int      // tokens one conversation may consume (0 = unlimited, overridable per client)
+	DefaultMaxConversationTurns int      // visitor messages one conversation may have before it is closed (0 = unlimited, overridable per client)
+	WarmReconnectGapMinutes     int      // silence after which a returning visitor is welcomed back (clients with warm reconnect)
+	ContactIntentThreshold      float64  // contact intent score (0-1) at which contact collection starts (overridable per client)
+	ContactCompletionMessage    string   // reply once contact details are collected and chat is closed (overridable per client)
+	MaxChatMessageLength        int      // longest accepted visitor message, in characters (0 = unlimited)
+	GreetingPhrases             []string // extra greetings answered instantly when a client enables instant greeting replies
+	ProfanityWords              []string // extra words masked in replies for clients with the profanity filter on
+	DefaultContextChunks        int      // knowledge chunks (PDF + crawl) placed in each reply's prompt (overridable per client)
+	MaxContextChunks            int      // upper bound for per-client context chunk overrides
+
+	// Embed secret rotation
+	EmbedSecretGraceHours int // hours the previous embed secret keeps working after a rotation (overridable per rotation)
+
+	// Signed embed configuration
+	EmbedSigningSecret string // HMAC key for signed embed tokens (signing is disabled when empty)
+	EmbedTokenTTLDays  int    // default validity of a signed embed token (overridable per token)
+
+	// Cross-device conversation resume
+	ChatResumeCodeTTLMinutes int // how long a resume code stays valid
+	ChatResumeMaxAttempts    int // code redemptions allowed per IP per window
+	ChatResumeAttemptWindow  int // seconds
+
+	// No-answer escalation
+	NoAnswerEscalationThreshold int // consecutive unanswered turns before contact collection is offered (0 = never)
+
+	// Repeated reply variation
+	RepeatedReplySimilarity float64 // word overlap (0-1) with a recent reply that triggers a regeneration (0 = off)
+	RepeatedReplyLookback   int     // recent bot replies in the session to compare against
+
+	// Strict grounding (opt-in per client)
+	StrictGroundingMinOverlap float64 // share (0-1) of a sentence's words the retrieved context must contain
+
+	// Crawl limits a single crawl request may ask for
+	CrawlMaxDepth int // deepest link depth allowed per crawl
+	CrawlMaxPages int // most pages allowed per crawl
+
+	// Crawl concurrency
+	CrawlConcurrency       int // pages one crawl fetches in parallel by default
+	CrawlMaxConcurrency    int // most parallel fetches a crawl request may ask for
+	CrawlGlobalConcurrency int // in-flight page fetches across all crawls
+
+	// Crawled content freshness
+	CrawlStaleAfterDays int // crawled pages older than this are down-weighted and flagged as possibly outdated (0 = never)
+	CrawlMaxAgeDays     int // crawled pages older than this are left out of the context (0 = keep all, overridable per client)
+
+	// Public feedback abuse protection
+	FeedbackRateLimit  int // feedback submissions allowed per IP per window
+	FeedbackRateWindow int // seconds
+
+	// Tenant database regions (not full data residency): tenant databases of clients with a data_region live on that region's cluster.
+	// Shared collections in DBName (clients, messages, pdfs, pdf_chunks, feedback) are not regional.
+	MongoRegionURIs map[string]string // region -> MongoDB URI, from MONGO_REGION_URIS="eu=mongodb://...;us=mongodb://..."
 }
 
 func LoadConfig() (*Config, error) {
@@ -108,20 +265,31 @@ func LoadConfig() (*Config, error) {
 		FileStorageDir:      getEnv("FILE_STORAGE_DIR", "./storage"),
 		SyncProcessingLimit: getEnvInt64("SYNC_PROCESSING_LIMIT", 20971520), // 20MB sync processing limit
 
+		// Upload virus scanning
+		VirusScanClamdAddress: getEnv("VIRUS_SCAN_CLAMD_ADDRESS", ""),
+		VirusScanAPIURL:       getEnv("VIRUS_SCAN_API_URL", ""),
+		VirusScanAPIKey:       getEnv("VIRUS_SCAN_API_KEY", ""),
+		VirusScanTimeout:      getEnvInt("VIRUS_SCAN_TIMEOUT", 60),
+		VirusScanFailOpen:     getEnvBool("VIRUS_SCAN_FAIL_OPEN", false),
+
 		// Redis Configuration
 		RedisURL:      getEnv("REDIS_URL", "localhost:6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       getEnvInt("REDIS_DB", 0),
 
+		// Async PDF processing
+		PDFJobClientConcurrency: getEnvInt("PDF_JOB_CLIENT_CONCURRENCY", 2),
+
 		// JWT Token Secrets
 		AccessSecret:  getEnv("ACCESS_SECRET", ""),
 		RefreshSecret: getEnv("REFRESH_SECRET", ""),
 
 		// Token Alert Configuration
 		TokenWarnPercent:      getEnvInt("TOKEN_WARN_PERCENT", 80),
-		TokenCriticalPercent:  getEnvInt("TOKEN_CRITICAL_PERCENT", 95),
+		TokenCriticalPercent:  getEnvInt("TOKEN_CRITICAL_PERCENT", 90),
 		TokenExhaustedPercent: getEnvInt("TOKEN_EXHAUSTED_PERCENT", 100),
 		TokenAlertCron:        getEnv("TOKEN_ALERT_CRON", "*/15 * * * *"),
+		TokenAlertScanEnabled: getEnvBool("TOKEN_ALERT_SCAN_ENABLED", true),
 
 		// SMTP Configuration
 		SMTPHost:    getEnv("SMTP_HOST", ""),
@@ -152,6 +320,151 @@ func LoadConfig() (*Config, error) {
 
 		// CSRF Protection
 		CSRFSecret: getEnv("CSRF_SECRET", ""),
+
+		// AI Request Logging
+		AILogSampleRate: getEnvFloat64("AI_LOG_SAMPLE_RATE", 0.05),
+		AILogMaxChars:   getEnvInt("AI_LOG_MAX_CHARS", 2000),
+
+		// Gemini Quota Circuit Breaker
+		GeminiBreakerThreshold: getEnvInt("GEMINI_BREAKER_THRESHOLD", 5),
+		GeminiBreakerWindow:    getEnvInt("GEMINI_BREAKER_WINDOW", 60),
+		GeminiBreakerCooldown:  getEnvInt("GEMINI_BREAKER_COOLDOWN", 60),
+
+		// Gemini Document Extraction
+		GeminiExtractionAPIKey:      getEnv("GEMINI_EXTRACTION_API_KEY", ""),
+		GeminiExtractionConcurrency: getEnvInt("GEMINI_EXTRACTION_CONCURRENCY", 2),
+		GeminiExtractionPerMinute:   getEnvInt("GEMINI_EXTRACTION_PER_MINUTE", 10),
+
+		// AI Generation Timeouts
+		AIGenerationTimeout: getEnvInt("AI_GENERATION_TIMEOUT", 25),
+		ChatRequestTimeout:  getEnvInt("CHAT_REQUEST_TIMEOUT", 30),
+
+		// Default persona cache
+		DefaultPersonaCacheTTL: getEnvInt("DEFAULT_PERSONA_CACHE_TTL", 60),
+
+		// Client config cache
+		ClientConfigCacheTTL: getEnvInt("CLIENT_CONFIG_CACHE_TTL", 30),
+
+		// Admin client overview
+		AdminOverviewCacheSeconds: getEnvInt("ADMIN_OVERVIEW_CACHE_SECONDS", 60),
+
+		// Per-client document quota defaults
+		DefaultMaxDocuments:  getEnvInt("DEFAULT_MAX_DOCUMENTS", 100),
+		DefaultMaxTotalBytes: getEnvInt64("DEFAULT_MAX_TOTAL_BYTES", 524288000), // 500MB
+
+		// Per-client image library limits
+		DefaultMaxImages: getEnvInt("DEFAULT_MAX_IMAGES", 200),
+		MaxImageSize:     getEnvInt64("MAX_IMAGE_SIZE", 5242880), // 5MB
+
+		// Uploaded image optimization
+		ImageOptimizationEnabled: getEnvBool("IMAGE_OPTIMIZATION_ENABLED", true),
+		ImageMaxWidth:            getEnvInt("IMAGE_MAX_WIDTH", 1600),
+		ImageMaxHeight:           getEnvInt("IMAGE_MAX_HEIGHT", 1600),
+		ImageJPEGQuality:         getEnvInt("IMAGE_JPEG_QUALITY", 82),
+		ImageThumbnailSize:       getEnvInt("IMAGE_THUMBNAIL_SIZE", 320),
+
+		// Channel integrations
+		PublicAPIURL: strings.TrimRight(getEnv("PUBLIC_API_URL", ""), "/"),
+
+		// Analytics digest emails
+		AnalyticsDigestEnabled:       getEnvBool("ANALYTICS_DIGEST_ENABLED", true),
+		AnalyticsDigestCheckInterval: getEnvInt("ANALYTICS_DIGEST_CHECK_INTERVAL", 60),
+
+		// Message retention
+		MessageRetentionEnabled:  getEnvBool("MESSAGE_RETENTION_ENABLED", true),
+		MessageRetentionInterval: getEnvInt("MESSAGE_RETENTION_INTERVAL", 24),
+
+		// Audit chain monitoring
+		AuditChainCheckEnabled:  getEnvBool("AUDIT_CHAIN_CHECK_ENABLED", false),
+		AuditChainCheckInterval: getEnvInt("AUDIT_CHAIN_CHECK_INTERVAL", 6),
+
+		// CRM conversation sync
+		CRMSyncEnabled:       getEnvBool("CRM_SYNC_ENABLED", true),
+		CRMSyncCheckInterval: getEnvInt("CRM_SYNC_CHECK_INTERVAL", 60),
+		CRMSyncTimeout:       getEnvInt("CRM_SYNC_TIMEOUT", 10),
+		CRMSyncMaxAttempts:   getEnvInt("CRM_SYNC_MAX_ATTEMPTS", 6),
+
+		// Outbound webhook headers
+		WebhookHeadersKey: getEnv("WEBHOOK_HEADERS_KEY", ""),
+
+		// Quality alerts
+		QualityAlertCooldownHours: getEnvInt("QUALITY_ALERT_COOLDOWN_HOURS", 24),
+
+		// Request body size limits (upload routes use MaxFileSize)
+		MaxRequestBodySize:       getEnvInt64("MAX_REQUEST_BODY_SIZE", 10<<20),        // 10MB
+		MaxPublicRequestBodySize: getEnvInt64("MAX_PUBLIC_REQUEST_BODY_SIZE", 64<<10), // 64KB
+
+		// Google Sheets analytics export
+		GoogleSheetsClientID:     getEnv("GOOGLE_SHEETS_CLIENT_ID", ""),
+		GoogleSheetsClientSecret: getEnv("GOOGLE_SHEETS_CLIENT_SECRET", ""),
+		GoogleSheetsSyncEnabled:  getEnvBool("GOOGLE_SHEETS_SYNC_ENABLED", true),
+		GoogleSheetsSyncInterval: getEnvInt("GOOGLE_SHEETS_SYNC_INTERVAL", 60),
+		GoogleSheetsBackfillDays: getEnvInt("GOOGLE_SHEETS_BACKFILL_DAYS", 90),
+
+		// Audit log retention
+		AuditRetentionDays:     getEnvInt("AUDIT_RETENTION_DAYS", 365),
+		AuditArchiveDir:        getEnv("AUDIT_ARCHIVE_DIR", ""),
+		AuditArchiveSigningKey: getEnv("AUDIT_ARCHIVE_SIGNING_KEY", ""),
+
+		// Public chat
+		SuspendedWidgetMessage:      getEnv("SUSPENDED_WIDGET_MESSAGE", "This chat is currently unavailable. Please try again later."),
+		ChatDedupWindowSeconds:      getEnvInt("CHAT_DEDUP_WINDOW_SECONDS", 5),
+		PromptTemplatePath:          getEnv("PROMPT_TEMPLATE_PATH", ""),
+		PromptSnapshotRetentionDays: getEnvInt("PROMPT_SNAPSHOT_RETENTION_DAYS", 30),
+		SpamFilterEnabled:           getEnvBool("SPAM_FILTER_ENABLED", false),
+		SpamThreshold:               getEnvFloat64("SPAM_THRESHOLD", 0.7),
+		DefaultSessionTokenCap:      getEnvInt("DEFAULT_SESSION_TOKEN_CAP", 0),
+		DefaultMaxConversationTurns: getEnvInt("DEFAULT_MAX_CONVERSATION_TURNS", 0),
+		WarmReconnectGapMinutes:     getEnvInt("WARM_RECONNECT_GAP_MINUTES", 60),
+		ContactIntentThreshold:      getEnvFloat64("CONTACT_INTENT_THRESHOLD", 0.6),
+		ContactCompletionMessage:    getEnv("CONTACT_COMPLETION_MESSAGE", "Thank you! Our team will contact you soon. This chat session is now complete."),
+		MaxChatMessageLength:        getEnvInt("MAX_CHAT_MESSAGE_LENGTH", 4000),
+		GreetingPhrases:             strings.Split(getEnv("GREETING_PHRASES", ""), ","),
+		ProfanityWords:              strings.Split(getEnv("PROFANITY_WORDS", ""), ","),
+		DefaultContextChunks:        getEnvInt("DEFAULT_CONTEXT_CHUNKS", 16),
+		MaxContextChunks:            getEnvInt("MAX_CONTEXT_CHUNKS", 40),
+
+		// Embed secret rotation
+		EmbedSecretGraceHours: getEnvInt("EMBED_SECRET_GRACE_HOURS", 24),
+
+		// Signed embed configuration
+		EmbedSigningSecret: getEnv("EMBED_SIGNING_SECRET", ""),
+		EmbedTokenTTLDays:  getEnvInt("EMBED_TOKEN_TTL_DAYS", 90),
+
+		// Cross-device conversation resume
+		ChatResumeCodeTTLMinutes: getEnvInt("CHAT_RESUME_CODE_TTL_MINUTES", 15),
+		ChatResumeMaxAttempts:    getEnvInt("CHAT_RESUME_MAX_ATTEMPTS", 10),
+		ChatResumeAttemptWindow:  getEnvInt("CHAT_RESUME_ATTEMPT_WINDOW", 900),
+
+		// No-answer escalation
+		NoAnswerEscalationThreshold: getEnvInt("NO_ANSWER_ESCALATION_THRESHOLD", 3),
+
+		// Repeated reply variation
+		RepeatedReplySimilarity: getEnvFloat64("REPEATED_REPLY_SIMILARITY", 0.85),
+		RepeatedReplyLookback:   getEnvInt("REPEATED_REPLY_LOOKBACK", 3),
+
+		// Strict grounding (opt-in per client)
+		StrictGroundingMinOverlap: getEnvFloat64("STRICT_GROUNDING_MIN_OVERLAP", 0.5),
+
+		// Crawl limits a single crawl request may ask for
+		CrawlMaxDepth: getEnvInt("CRAWL_MAX_DEPTH", 5),
+		CrawlMaxPages: getEnvInt("CRAWL_MAX_PAGES", 500),
+
+		// Crawl concurrency
+		CrawlConcurrency:       getEnvInt("CRAWL_CONCURRENCY", 2),
+		CrawlMaxConcurrency:    getEnvInt("CRAWL_MAX_CONCURRENCY", 8),
+		CrawlGlobalConcurrency: getEnvInt("CRAWL_GLOBAL_CONCURRENCY", 16),
+
+		// Crawled content freshness
+		CrawlStaleAfterDays: getEnvInt("CRAWL_STALE_AFTER_DAYS", 90),
+		CrawlMaxAgeDays:     getEnvInt("CRAWL_MAX_AGE_DAYS", 0),
+
+		// Public feedback abuse protection
+		FeedbackRateLimit:  getEnvInt("FEEDBACK_RATE_LIMIT", 20),
+		FeedbackRateWindow: getEnvInt("FEEDBACK_RATE_WINDOW", 3600),
+
+		// Data residency
+		MongoRegionURIs: getEnvRegionURIs("MONGO_REGION_URIS"),
 	}
 
 	// Validate required fields
@@ -208,6 +521,21 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvRegionURIs parses "region=uri" pairs separated by semicolons (URIs may contain commas).
+// Region names are lower-cased.
+func getEnvRegionURIs(key string) map[string]string {
+	uris := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ";") {
+		region, uri, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		region = strings.ToLower(strings.TrimSpace(region))
+		if !ok || region == "" || strings.TrimSpace(uri) == "" {
+			continue
+		}
+		uris[region] = strings.TrimSpace(uri)
+	}
+	return uris
+}
+
 func getEnvFloat64(key string, defaultValue float64) float64 {
 	if value := os.Getenv(key); value != "" {
 		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
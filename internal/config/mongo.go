@@ -117,5 +117,36 @@ func createIndexes(client *mongo.Client, dbName string) error {
 		return err
 	}
 
+	// ✅ NEW: Prompt snapshots are looked up by message and expire at expires_at
+	promptSnapshotsCollection := db.Collection("prompt_snapshots")
+	promptSnapshotIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "message_id", Value: 1}}},
+		{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "conversation_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err = promptSnapshotsCollection.Indexes().CreateMany(context.Background(), promptSnapshotIndexes)
+	if err != nil {
+		return err
+	}
+
+	// ✅ NEW: Client personas are loaded per reply by client, in priority order
+	_, err = db.Collection("client_personas").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "enabled", Value: 1}, {Key: "priority", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// ✅ NEW: Quality checks look up the open alert per client and condition
+	_, err = db.Collection("quality_alerts").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "condition", Value: 1}, {Key: "acknowledged", Value: 1}, {Key: "last_occurred_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -42,8 +42,24 @@ type CrawlConfig struct {
 	RenderTimeout    time.Duration
 	WaitSelector     string
 	NetworkIdleAfter time.Duration
+	// Additional pages to visit directly, e.g. the pages listed in a sitemap
+	SeedURLs []string
+	// Link depth from the start page (0 = DefaultMaxDepth)
+	MaxDepth int
+	// Follow links to other sites when no AllowedDomains are given (default: start host only)
+	AllowExternalLinks bool
+	// Pages fetched in parallel (0 = DefaultConcurrency); fetches across all crawls share the global cap
+	Concurrency int
+	// Optional callback after each fetched page with the running counts
+	OnProgress func(pagesCrawled, pagesFound int)
 }
 
+// Limits used when a crawl doesn't set its own
+const (
+	DefaultMaxDepth = 2
+	DefaultMaxPages = 50
+)
+
 // CrawlResult holds the result of a crawl operation
 type CrawlResult struct {
 	URL          string
@@ -54,8 +70,14 @@ type CrawlResult struct {
 	Error        error
 	PagesFound   int
 	PagesCrawled int
+	// URLs robots.txt disallowed; SkippedURLs keeps the first maxSkippedURLs of them
+	RobotsSkipped int
+	SkippedURLs   []models.SkippedURL
 }
 
+// maxSkippedURLs caps how many skipped URLs a result lists
+const maxSkippedURLs = 100
+
 // normalizeURL normalizes a URL to a canonical form for duplicate detection
 func normalizeURL(rawURL string) (string, error) {
 	parsed, err := url.Parse(rawURL)
@@ -123,7 +145,7 @@ func CrawlURL(cfg CrawlConfig) (*CrawlResult, error) {
 
 	// Determine allowed domains
 	allowedDomains := cfg.AllowedDomains
-	if len(allowedDomains) == 0 {
+	if len(allowedDomains) == 0 && !cfg.AllowExternalLinks {
 		hostname := parsedURL.Hostname()
 		if hostname != "" {
 			hostnameClean := strings.TrimPrefix(strings.ToLower(hostname), "www.")
@@ -137,9 +159,13 @@ func CrawlURL(cfg CrawlConfig) (*CrawlResult, error) {
 
 	// Create a FRESH collector for each crawl
 	// This is critical - each crawl gets its own collector with fresh state
+	maxDepth := cfg.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
 	options := []colly.CollectorOption{
 		colly.Async(true),
-		colly.MaxDepth(2),
+		colly.MaxDepth(maxDepth),
 	}
 
 	// Add allowed domains
@@ -149,8 +175,8 @@ func CrawlURL(cfg CrawlConfig) (*CrawlResult, error) {
 
 	c := colly.NewCollector(options...)
 
-	// ✅ Configure HTTP transport with compression enabled
-	c.WithTransport(httpTransport)
+	// ✅ Configure HTTP transport with compression enabled; fetches hold a global slot
+	c.WithTransport(&pooledTransport{base: httpTransport})
 
 	// Set timeout
 	if cfg.Timeout > 0 {
@@ -162,17 +188,21 @@ func CrawlURL(cfg CrawlConfig) (*CrawlResult, error) {
 	// Set realistic browser User-Agent
 	c.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
 
-	// Configure rate limiting
+	// Configure rate limiting; parallel workers still wait out the delay between their own requests
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
 	c.Limit(&colly.LimitRule{
 		DomainGlob:  "*",
-		Parallelism: 1,
+		Parallelism: concurrency,
 		Delay:       2 * time.Second,
 		RandomDelay: 1 * time.Second,
 	})
 
 	maxPages := cfg.MaxPages
 	if maxPages <= 0 {
-		maxPages = 50
+		maxPages = DefaultMaxPages
 	}
 
 	// Thread-safe page storage
@@ -192,8 +222,33 @@ func CrawlURL(cfg CrawlConfig) (*CrawlResult, error) {
 	initialPageProcessed := false
 	var initialPageMu sync.Mutex
 
+	// robots.txt rules are fetched once per host for this crawl
+	var robots *robotsCache
+	var skippedMu sync.Mutex
+	if cfg.RespectRobots {
+		robots = newRobotsCache()
+		if ok, reason := robots.check(normalizedStartURL); !ok {
+			return nil, fmt.Errorf("cannot crawl %s: %s", normalizedStartURL, reason)
+		}
+	}
+
 	// On request - add proper browser-like headers to avoid 403 Forbidden
 	c.OnRequest(func(r *colly.Request) {
+		// Skip URLs robots.txt disallows and pace requests by the host's crawl-delay
+		if robots != nil {
+			if ok, reason := robots.check(r.URL.String()); !ok {
+				skippedMu.Lock()
+				result.RobotsSkipped++
+				if len(result.SkippedURLs) < maxSkippedURLs {
+					result.SkippedURLs = append(result.SkippedURLs, models.SkippedURL{URL: r.URL.String(), Reason: reason})
+				}
+				skippedMu.Unlock()
+				r.Abort()
+				return
+			}
+			robots.wait(r.URL.String())
+		}
+
 		// Standard browser headers
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
 		r.Headers.Set("Accept-Language", "en-US,en;q=0.9")
@@ -264,10 +319,22 @@ func CrawlURL(cfg CrawlConfig) (*CrawlResult, error) {
 		// Mark response URL as processed (colly handled it)
 		normalizedRespURL, _ := normalizeURL(r.Request.URL.String())
 		if normalizedRespURL != "" {
+			pagesMu.Lock()
 			result.PagesFound++
+			pagesMu.Unlock()
 		}
 	})
 
+	// Report progress once a page is fully handled
+	if cfg.OnProgress != nil {
+		c.OnScraped(func(_ *colly.Response) {
+			pagesMu.Lock()
+			crawled, found := len(pages), result.PagesFound
+			pagesMu.Unlock()
+			cfg.OnProgress(crawled, found)
+		})
+	}
+
 	// On HTML - extract content
 	c.OnHTML("html", func(e *colly.HTMLElement) {
 		pagesMu.Lock()
@@ -549,6 +616,21 @@ func CrawlURL(cfg CrawlConfig) (*CrawlResult, error) {
 		}
 	}
 
+	// Visit seeded pages directly instead of discovering them through links
+	for _, seed := range cfg.SeedURLs {
+		normalized, err := normalizeURL(seed)
+		if err != nil {
+			continue
+		}
+		queuedMu.Lock()
+		_, queuedExists := queued.LoadOrStore(normalized, true)
+		queuedMu.Unlock()
+		if queuedExists {
+			continue
+		}
+		c.Visit(normalized)
+	}
+
 	// Wait for async crawl to complete
 	c.Wait()
 
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	// DefaultConcurrency is how many pages one crawl fetches in parallel unless configured otherwise
+	DefaultConcurrency = 2
+	// defaultGlobalConcurrency caps in-flight page fetches across all crawls until SetGlobalConcurrency is called
+	defaultGlobalConcurrency = 16
+)
+
+var (
+	fetchSlotsMu sync.Mutex
+	fetchSlots   = make(chan struct{}, defaultGlobalConcurrency)
+)
+
+// SetGlobalConcurrency caps how many pages all running crawls may fetch at once, so one client's large
+// crawl can't starve the others. Call it at startup before crawls begin.
+func SetGlobalConcurrency(n int) {
+	if n <= 0 {
+		n = defaultGlobalConcurrency
+	}
+	fetchSlotsMu.Lock()
+	fetchSlots = make(chan struct{}, n)
+	fetchSlotsMu.Unlock()
+}
+
+// pooledTransport holds a global fetch slot from the start of a request until its body is closed
+type pooledTransport struct {
+	base http.RoundTripper
+}
+
+func (t *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fetchSlotsMu.Lock()
+	slots := fetchSlots
+	fetchSlotsMu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-slots
+		return nil, err
+	}
+	resp.Body = &slotReleasingBody{ReadCloser: resp.Body, slots: slots}
+	return resp, nil
+}
+
+// slotReleasingBody frees the fetch slot when the response body is closed
+type slotReleasingBody struct {
+	io.ReadCloser
+	slots chan struct{}
+	once  sync.Once
+}
+
+func (b *slotReleasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { <-b.slots })
+	return err
+}
@@ -0,0 +1,277 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+)
+
+const (
+	// robotsUserAgent is the product token matched against robots.txt User-agent lines
+	robotsUserAgent = "saas-chatbot-crawler"
+
+	maxRobotsBodyBytes  = 512 * 1024 // robots.txt past this size is ignored (RFC 9309 requires at least 500 KiB)
+	maxRobotsCrawlDelay = 30 * time.Second
+	robotsFetchTimeout  = 15 * time.Second
+)
+
+// robotsRule is one Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsRules are the rules of the robots.txt group that applies to the crawler
+type robotsRules struct {
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	disallowAll bool // robots.txt was unreachable (5xx or network error)
+}
+
+// parseRobots parses robots.txt and keeps the group for our user agent, falling back to the * group
+func parseRobots(body io.Reader) *robotsRules {
+	type group struct {
+		agents     []string
+		rules      []robotsRule
+		crawlDelay time.Duration
+	}
+
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive User-agent lines share one group
+			if current == nil || !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			// An empty Disallow allows everything and adds no rule
+			if value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	var matched, wildcard *group
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == "*" && wildcard == nil {
+				wildcard = g
+			} else if agent != "*" && strings.Contains(robotsUserAgent, agent) && matched == nil {
+				matched = g
+			}
+		}
+	}
+	if matched == nil {
+		matched = wildcard
+	}
+	if matched == nil {
+		return &robotsRules{}
+	}
+
+	delay := matched.crawlDelay
+	if delay > maxRobotsCrawlDelay {
+		delay = maxRobotsCrawlDelay
+	}
+	return &robotsRules{rules: matched.rules, crawlDelay: delay}
+}
+
+// allowed reports whether path (with query) may be fetched and, when it may not, which rule blocked it.
+// The longest matching rule wins; Allow wins a tie.
+func (r *robotsRules) allowed(path string) (bool, string) {
+	if r.disallowAll {
+		return false, "robots.txt unreachable"
+	}
+	var best *robotsRule
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if !robotsPatternMatches(rule.pattern, path) {
+			continue
+		}
+		if best == nil || len(rule.pattern) > len(best.pattern) ||
+			(len(rule.pattern) == len(best.pattern) && rule.allow && !best.allow) {
+			best = rule
+		}
+	}
+	if best == nil || best.allow {
+		return true, ""
+	}
+	return false, "Disallow: " + best.pattern
+}
+
+// robotsPatternMatches matches a robots.txt path pattern supporting * and a trailing $
+func robotsPatternMatches(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if !anchored {
+		return true
+	}
+	if len(parts) == 1 {
+		return path == parts[0]
+	}
+	// With $ the last literal must end the path (a trailing * before $ matches anything)
+	last := parts[len(parts)-1]
+	return last == "" || strings.HasSuffix(path, last)
+}
+
+// robotsEntry caches one host's rules and when it was last requested
+type robotsEntry struct {
+	once        sync.Once
+	rules       *robotsRules
+	mu          sync.Mutex
+	nextRequest time.Time
+}
+
+// robotsCache fetches robots.txt once per host for a crawl job and paces requests by crawl-delay
+type robotsCache struct {
+	client *http.Client
+	mu     sync.Mutex
+	hosts  map[string]*robotsEntry
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{
+		client: &http.Client{Timeout: robotsFetchTimeout, Transport: httpTransport},
+		hosts:  make(map[string]*robotsEntry),
+	}
+}
+
+// entry returns the cached rules for u's host, fetching robots.txt on first use
+func (rc *robotsCache) entry(u *url.URL) *robotsEntry {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+	rc.mu.Lock()
+	e, ok := rc.hosts[key]
+	if !ok {
+		e = &robotsEntry{}
+		rc.hosts[key] = e
+	}
+	rc.mu.Unlock()
+
+	e.once.Do(func() {
+		e.rules = rc.fetch(key + "/robots.txt")
+	})
+	return e
+}
+
+// fetch downloads and parses robots.txt. Per RFC 9309 a missing file (4xx) allows everything and an
+// unreachable one (5xx or network error) disallows everything.
+func (rc *robotsCache) fetch(robotsURL string) *robotsRules {
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return &robotsRules{}
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; "+robotsUserAgent+")")
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		logger.Warn("robots.txt unreachable", "url", robotsURL, "error", err)
+		return &robotsRules{disallowAll: true}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		logger.Warn("robots.txt unreachable", "url", robotsURL, "status", resp.StatusCode)
+		return &robotsRules{disallowAll: true}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &robotsRules{}
+	}
+	return parseRobots(io.LimitReader(resp.Body, maxRobotsBodyBytes))
+}
+
+// check reports whether rawURL may be crawled and, if not, why
+func (rc *robotsCache) check(rawURL string) (bool, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, "invalid URL"
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	ok, rule := rc.entry(u).rules.allowed(path)
+	if !ok {
+		return false, "disallowed by robots.txt (" + rule + ")"
+	}
+	return true, ""
+}
+
+// wait blocks until rawURL's host may be requested again under its crawl-delay
+func (rc *robotsCache) wait(rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	e := rc.entry(u)
+	if e.rules.crawlDelay <= 0 {
+		return
+	}
+
+	// Reserve the next slot so parallel requests to the host are spaced out too
+	e.mu.Lock()
+	now := time.Now()
+	slot := e.nextRequest
+	if slot.Before(now) {
+		slot = now
+	}
+	e.nextRequest = slot.Add(e.rules.crawlDelay)
+	e.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
@@ -0,0 +1,161 @@
+package crawler
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	maxSitemapFiles     = 50               // sitemap files fetched per crawl, including nested index entries
+	maxSitemapDepth     = 3                // how deep sitemap index files may nest
+	maxSitemapBodyBytes = 50 * 1024 * 1024 // sitemaps are limited to 50MB uncompressed by the protocol
+	sitemapFetchTimeout = 30 * time.Second
+)
+
+// SitemapResult lists the pages to crawl from a sitemap
+type SitemapResult struct {
+	URLs       []string // normalized page URLs, at most the requested cap
+	Discovered int      // <loc> entries found across all sitemap files
+	Skipped    int      // entries dropped as duplicates, off-site, invalid or over the cap
+	Sitemaps   int      // sitemap files fetched
+}
+
+// sitemapDocument covers both <urlset> and <sitemapindex> documents
+type sitemapDocument struct {
+	XMLName  xml.Name     `xml:""`
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// FetchSitemapURLs reads a sitemap.xml (or sitemap index, optionally gzipped) and returns up to maxURLs
+// page URLs on the sitemap's own site
+func FetchSitemapURLs(sitemapURL string, maxURLs int) (*SitemapResult, error) {
+	root, err := url.Parse(strings.TrimSpace(sitemapURL))
+	if err != nil || (root.Scheme != "http" && root.Scheme != "https") || root.Host == "" {
+		return nil, fmt.Errorf("invalid sitemap URL: %s", sitemapURL)
+	}
+	siteHost := strings.TrimPrefix(strings.ToLower(root.Hostname()), "www.")
+
+	client := &http.Client{Timeout: sitemapFetchTimeout, Transport: httpTransport}
+	result := &SitemapResult{}
+	seen := make(map[string]bool)
+	fetched := make(map[string]bool)
+
+	var walk func(loc string, depth int) error
+	walk = func(loc string, depth int) error {
+		if fetched[loc] || result.Sitemaps >= maxSitemapFiles {
+			return nil
+		}
+		fetched[loc] = true
+		result.Sitemaps++
+
+		doc, err := fetchSitemapDocument(client, loc)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range doc.URLs {
+			result.Discovered++
+			pageURL, ok := sameSiteURL(entry.Loc, siteHost)
+			if !ok || seen[pageURL] || len(result.URLs) >= maxURLs {
+				result.Skipped++
+				continue
+			}
+			seen[pageURL] = true
+			result.URLs = append(result.URLs, pageURL)
+		}
+
+		if depth >= maxSitemapDepth {
+			return nil
+		}
+		for _, child := range doc.Sitemaps {
+			childURL, ok := sameSiteURL(child.Loc, siteHost)
+			if !ok {
+				continue
+			}
+			// A broken child sitemap shouldn't discard what the others listed
+			if err := walk(childURL, depth+1); err != nil {
+				logger.Warn("Skipping sitemap", "url", childURL, "error", err)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root.String(), 0); err != nil {
+		return nil, err
+	}
+	if len(result.URLs) == 0 {
+		return nil, fmt.Errorf("sitemap %s lists no pages on %s", sitemapURL, root.Host)
+	}
+	return result, nil
+}
+
+// fetchSitemapDocument downloads and parses one sitemap file
+func fetchSitemapDocument(client *http.Client, loc string) (*sitemapDocument, error) {
+	req, err := http.NewRequest(http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36")
+	req.Header.Set("Accept", "application/xml,text/xml;q=0.9,*/*;q=0.8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sitemap: HTTP %d", resp.StatusCode)
+	}
+
+	var body io.Reader = io.LimitReader(resp.Body, maxSitemapBodyBytes)
+	if strings.HasSuffix(strings.ToLower(req.URL.Path), ".gz") && resp.Header.Get("Content-Encoding") == "" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap: %w", err)
+		}
+		defer gz.Close()
+		body = io.LimitReader(gz, maxSitemapBodyBytes)
+	}
+
+	var doc sitemapDocument
+	decoder := xml.NewDecoder(body)
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap XML: %w", err)
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("not a sitemap: unexpected <%s> root element", doc.XMLName.Local)
+	}
+	return &doc, nil
+}
+
+// sameSiteURL normalizes loc and reports whether it is an http(s) URL on siteHost (www. ignored)
+func sameSiteURL(loc, siteHost string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(loc))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", false
+	}
+	if strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.") != siteHost {
+		return "", false
+	}
+	normalized, err := normalizeURL(parsed.String())
+	if err != nil {
+		return "", false
+	}
+	return normalized, true
+}
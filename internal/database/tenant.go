@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"saas-chatbot-platform/internal/auth"
 
@@ -14,10 +15,18 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// tenantRegionTTL is how long a tenant's resolved cluster is trusted before its data_region is re-read,
+// so a tenant moved to another region by a migration is picked up without a restart
+const tenantRegionTTL = 10 * time.Minute
+
 type TenantDBManager struct {
 	client    *mongo.Client
-	databases map[string]*mongo.Database
+	databases map[string]*tenantDatabase
 	mu        sync.RWMutex
+
+	// Tenants with a data_region keep their tenant database on that region's cluster
+	regionClients     map[string]*mongo.Client
+	clientsCollection *mongo.Collection // shared clients collection holding each tenant's data_region
 }
 
 func NewTenantDBManager(mongoURI string) (*TenantDBManager, error) {
@@ -28,16 +37,86 @@ func NewTenantDBManager(mongoURI string) (*TenantDBManager, error) {
 
 	return &TenantDBManager{
 		client:    client,
-		databases: make(map[string]*mongo.Database),
+		databases: make(map[string]*tenantDatabase),
 	}, nil
 }
 
+// tenantDatabase is a cached tenant database and when its cluster was last resolved
+type tenantDatabase struct {
+	db         *mongo.Database
+	resolvedAt time.Time
+}
+
+// EnableRegions connects to the region-specific clusters in regionURIs. Tenants are routed by the
+// data_region stored on their client document; tenants without one stay on the default cluster.
+// Only the tenant database (tenant_<client_id>) is regional: the shared collections in DB_NAME
+// (clients, messages, pdfs, pdf_chunks, feedback, ...) stay on the default cluster.
+func (m *TenantDBManager) EnableRegions(regionURIs map[string]string, clientsCollection *mongo.Collection) error {
+	regionClients := make(map[string]*mongo.Client, len(regionURIs))
+	for region, uri := range regionURIs {
+		client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s cluster: %w", region, err)
+		}
+		regionClients[region] = client
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.regionClients = regionClients
+	m.clientsCollection = clientsCollection
+	return nil
+}
+
+// HasRegion reports whether a cluster is configured for region
+func (m *TenantDBManager) HasRegion(region string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.regionClients[region]
+	return ok
+}
+
+// clientForTenant returns the cluster holding a tenant's data. A tenant whose region has no configured
+// cluster is an error rather than a fallback, so its data never lands outside its region.
+// Callers must hold m.mu.
+func (m *TenantDBManager) clientForTenant(clientID string) (*mongo.Client, error) {
+	if m.clientsCollection == nil {
+		return m.client, nil
+	}
+
+	clientObjectID, err := primitive.ObjectIDFromHex(clientID)
+	if err != nil {
+		return m.client, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var tenant struct {
+		DataRegion string `bson:"data_region"`
+	}
+	err = m.clientsCollection.FindOne(ctx, bson.M{"_id": clientObjectID},
+		options.FindOne().SetProjection(bson.M{"data_region": 1})).Decode(&tenant)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to look up data region for %s: %w", clientID, err)
+	}
+	if tenant.DataRegion == "" {
+		return m.client, nil
+	}
+
+	client, ok := m.regionClients[tenant.DataRegion]
+	if !ok {
+		return nil, fmt.Errorf("no cluster configured for data region %q of tenant %s", tenant.DataRegion, clientID)
+	}
+	return client, nil
+}
+
 // GetTenantDB returns isolated database for tenant
 func (m *TenantDBManager) GetTenantDB(clientID string) (*mongo.Database, error) {
 	m.mu.RLock()
-	if db, exists := m.databases[clientID]; exists {
+	if cached, exists := m.databases[clientID]; exists && m.isFresh(cached) {
 		m.mu.RUnlock()
-		return db, nil
+		return cached.db, nil
 	}
 	m.mu.RUnlock()
 
@@ -45,23 +124,47 @@ func (m *TenantDBManager) GetTenantDB(clientID string) (*mongo.Database, error)
 	defer m.mu.Unlock()
 
 	// Double-check after acquiring write lock
-	if db, exists := m.databases[clientID]; exists {
-		return db, nil
+	cached, exists := m.databases[clientID]
+	if exists && m.isFresh(cached) {
+		return cached.db, nil
 	}
 
-	// Create tenant-specific database
+	// Create tenant-specific database on the tenant's regional cluster
+	client, err := m.clientForTenant(clientID)
+	if err != nil {
+		return nil, err
+	}
 	dbName := fmt.Sprintf("tenant_%s", clientID)
-	db := m.client.Database(dbName)
+	if exists && cached.db.Client() == client {
+		// Region unchanged; keep the handle whose indexes already exist
+		cached.resolvedAt = time.Now()
+		return cached.db, nil
+	}
+	db := client.Database(dbName)
 
 	// Create indexes for new tenant database
 	if err := m.createTenantIndexes(db); err != nil {
 		return nil, err
 	}
 
-	m.databases[clientID] = db
+	m.databases[clientID] = &tenantDatabase{db: db, resolvedAt: time.Now()}
 	return db, nil
 }
 
+// isFresh reports whether a cached tenant database can be used without re-reading the tenant's region.
+// Without regional clusters every tenant is on the default cluster, so nothing expires.
+// Callers must hold m.mu.
+func (m *TenantDBManager) isFresh(cached *tenantDatabase) bool {
+	return m.clientsCollection == nil || time.Since(cached.resolvedAt) < tenantRegionTTL
+}
+
+// InvalidateTenant drops the cached database of a tenant so the next request re-reads its data_region
+func (m *TenantDBManager) InvalidateTenant(clientID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.databases, clientID)
+}
+
 func (m *TenantDBManager) createTenantIndexes(db *mongo.Database) error {
 	ctx := context.Background()
 
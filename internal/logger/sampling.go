@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"math/rand"
+	"regexp"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s\-()]{7,}\d`)
+)
+
+// ShouldSample returns true for roughly rate*100 percent of calls.
+// A rate <= 0 never samples, a rate >= 1 always samples.
+func ShouldSample(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 1 {
+		return true
+	}
+	return rand.Float64() < rate
+}
+
+// Redact masks emails and phone numbers and truncates text to maxChars
+// so user-supplied content can be written to logs safely.
+func Redact(text string, maxChars int) string {
+	text = emailPattern.ReplaceAllString(text, "[REDACTED_EMAIL]")
+	text = phonePattern.ReplaceAllString(text, "[REDACTED_PHONE]")
+
+	if maxChars > 0 && len(text) > maxChars {
+		runes := []rune(text)
+		if len(runes) > maxChars {
+			text = string(runes[:maxChars]) + "...[truncated]"
+		}
+	}
+	return text
+}
@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultClientConcurrency is how many PDF jobs of one client may run at once unless configured otherwise
+	DefaultClientConcurrency = 2
+
+	// clientJobLease bounds how long a slot is held if a worker dies without releasing it; it outlasts the
+	// PDF task timeout so a slot is never reclaimed from a job that is still running
+	clientJobLease = 15 * time.Minute
+
+	// clientBusyRetryDelay is the base delay before a job that found its client at the limit is retried
+	clientBusyRetryDelay = 5 * time.Second
+)
+
+// ErrClientBusy is returned by a task handler when its client already has the maximum number of jobs
+// running. Workers treat it as a deferral, not a failure, so it never uses up the task's retries.
+var ErrClientBusy = errors.New("client PDF job limit reached")
+
+// acquireSlotScript drops expired leases, then adds ARGV[3] to the client's running set when there is room
+var acquireSlotScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if redis.call('ZSCORE', KEYS[1], ARGV[3]) then
+	redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+	return 1
+end
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[4]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+// ClientLimiter is a Redis semaphore that caps how many PDF jobs each client runs at once across all
+// workers, so one tenant uploading hundreds of files can't occupy every worker
+type ClientLimiter struct {
+	rdb   *redis.Client
+	limit int
+}
+
+// NewClientLimiter creates a limiter allowing limit concurrent jobs per client (0 or less uses the default)
+func NewClientLimiter(rdb *redis.Client, limit int) *ClientLimiter {
+	if limit <= 0 {
+		limit = DefaultClientConcurrency
+	}
+	return &ClientLimiter{rdb: rdb, limit: limit}
+}
+
+// Limit returns the number of jobs a client may run at once
+func (l *ClientLimiter) Limit() int {
+	return l.limit
+}
+
+func clientJobsKey(clientID string) string {
+	return fmt.Sprintf("pdf_jobs:running:%s", clientID)
+}
+
+// Acquire takes a slot for taskID and reports whether one was free
+func (l *ClientLimiter) Acquire(ctx context.Context, clientID, taskID string) (bool, error) {
+	now := time.Now()
+	acquired, err := acquireSlotScript.Run(ctx, l.rdb, []string{clientJobsKey(clientID)},
+		now.UnixMilli(),
+		now.Add(clientJobLease).UnixMilli(),
+		taskID,
+		l.limit,
+		clientJobLease.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+// Release frees taskID's slot
+func (l *ClientLimiter) Release(ctx context.Context, clientID, taskID string) error {
+	return l.rdb.ZRem(ctx, clientJobsKey(clientID), taskID).Err()
+}
+
+// RunningClientJobs returns how many PDF jobs a client currently has running
+func RunningClientJobs(ctx context.Context, rdb *redis.Client, clientID string) (int64, error) {
+	return rdb.ZCount(ctx, clientJobsKey(clientID), fmt.Sprint(time.Now().UnixMilli()), "+inf").Result()
+}
+
+// IsTaskFailure tells asynq which handler errors count against a task's retries (asynq.Config.IsFailure)
+func IsTaskFailure(err error) bool {
+	return err != nil && !errors.Is(err, ErrClientBusy)
+}
+
+// RetryDelay re-checks deferred jobs after a few seconds, with jitter so a client's backlog doesn't wake up
+// at once, and uses asynq's exponential backoff for real failures (asynq.Config.RetryDelayFunc)
+func RetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	if errors.Is(err, ErrClientBusy) {
+		return clientBusyRetryDelay + time.Duration(rand.Int63n(int64(clientBusyRetryDelay)))
+	}
+	return asynq.DefaultRetryDelayFunc(n, err, t)
+}
@@ -80,13 +80,15 @@ type TaskProcessor struct {
 	dbManager    *database.TenantDBManager
 	geminiClient *ai.GeminiClient
 	rdb          *mongo.Client
+	limiter      *ClientLimiter // per-client PDF job cap (nil = unlimited)
 }
 
-func NewTaskProcessor(dbManager *database.TenantDBManager, geminiClient *ai.GeminiClient, rdb *mongo.Client) *TaskProcessor {
+func NewTaskProcessor(dbManager *database.TenantDBManager, geminiClient *ai.GeminiClient, rdb *mongo.Client, limiter *ClientLimiter) *TaskProcessor {
 	return &TaskProcessor{
 		dbManager:    dbManager,
 		geminiClient: geminiClient,
 		rdb:          rdb,
+		limiter:      limiter,
 	}
 }
 
@@ -96,6 +98,19 @@ func (p *TaskProcessor) ProcessPDF(ctx context.Context, t *asynq.Task) error {
 		return fmt.Errorf("unmarshal failed: %w", asynq.SkipRetry)
 	}
 
+	// Hold one of the client's job slots for the whole run; at the limit the task is deferred and retried
+	if p.limiter != nil {
+		taskID, _ := asynq.GetTaskID(ctx)
+		acquired, err := p.limiter.Acquire(ctx, payload.ClientID, taskID)
+		if err != nil {
+			log.Printf("Client job limiter unavailable, processing without it: %v", err)
+		} else if !acquired {
+			return ErrClientBusy
+		} else {
+			defer p.limiter.Release(context.Background(), payload.ClientID, taskID)
+		}
+	}
+
 	log.Printf("Processing PDF: client=%s file=%s", payload.ClientID, payload.FileID)
 
 	// Get tenant database
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"strings"
 	"time"
 
@@ -59,7 +60,7 @@ func EmbedCORSValidator(db *mongo.Database, rdb *redis.Client) gin.HandlerFunc {
 
 		// Verify embed secret and allowed origins
 		client, err := getClientConfig(db, clientID)
-		if err != nil || client.EmbedSecret != embedSecret {
+		if err != nil || !embedSecretMatches(client, embedSecret, time.Now()) {
 			c.AbortWithStatusJSON(403, gin.H{
 				"error_code": "invalid_credentials",
 				"message":    "Invalid credentials",
@@ -105,6 +106,20 @@ func EmbedCORSValidator(db *mongo.Database, rdb *redis.Client) gin.HandlerFunc {
 	}
 }
 
+// embedSecretMatches accepts the client's current embed secret, or the one it replaced while that
+// rotation's grace period lasts
+func embedSecretMatches(client *models.Client, secret string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(client.EmbedSecret), []byte(secret)) == 1 {
+		return true
+	}
+	return client.PreviousEmbedSecret != "" &&
+		client.PreviousEmbedSecretExpiresAt != nil && now.Before(*client.PreviousEmbedSecretExpiresAt) &&
+		subtle.ConstantTimeCompare([]byte(client.PreviousEmbedSecret), []byte(secret)) == 1
+}
+
 func getClientConfig(db *mongo.Database, clientID string) (*models.Client, error) {
 	ctx := context.Background()
 	clientsCollection := db.Collection("clients")
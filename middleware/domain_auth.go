@@ -19,8 +19,9 @@ import (
 
 // DomainAuthMiddleware handles domain authorization for chatframe embedding
 type DomainAuthMiddleware struct {
-	clientsCollection *mongo.Collection
-	alertsCollection  *mongo.Collection
+	clientsCollection  *mongo.Collection
+	alertsCollection   *mongo.Collection
+	embedSigningSecret string // ✅ NEW: verifies signed embed tokens (see embed_token.go)
 }
 
 // NewDomainAuthMiddleware creates a new domain authorization middleware
@@ -31,6 +32,13 @@ func NewDomainAuthMiddleware(clientsCollection, alertsCollection *mongo.Collecti
 	}
 }
 
+// ✅ NEW: WithEmbedSigningSecret sets the secret embed tokens are verified with. Clients that require
+// signed embeds are rejected while it is empty.
+func (m *DomainAuthMiddleware) WithEmbedSigningSecret(secret string) *DomainAuthMiddleware {
+	m.embedSigningSecret = secret
+	return m
+}
+
 // CheckDomainAuthorization checks if the requesting domain is authorized for the client
 func (m *DomainAuthMiddleware) CheckDomainAuthorization() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -77,12 +85,13 @@ func (m *DomainAuthMiddleware) CheckDomainAuthorization() gin.HandlerFunc {
 
 		// Get client information
 		var client struct {
-			ID                primitive.ObjectID `bson:"_id"`
-			Name              string             `bson:"name"`
-			DomainWhitelist   []string           `bson:"domain_whitelist"`
-			DomainBlacklist   []string           `bson:"domain_blacklist"`
-			DomainMode        string             `bson:"domain_mode"`
-			RequireDomainAuth bool               `bson:"require_domain_auth"`
+			ID                 primitive.ObjectID `bson:"_id"`
+			Name               string             `bson:"name"`
+			DomainWhitelist    []string           `bson:"domain_whitelist"`
+			DomainBlacklist    []string           `bson:"domain_blacklist"`
+			DomainMode         string             `bson:"domain_mode"`
+			RequireDomainAuth  bool               `bson:"require_domain_auth"`
+			RequireSignedEmbed bool               `bson:"require_signed_embed"`
 		}
 
 		err = m.clientsCollection.FindOne(context.Background(), bson.M{"_id": clientObjID}).Decode(&client)
@@ -100,6 +109,12 @@ func (m *DomainAuthMiddleware) CheckDomainAuthorization() gin.HandlerFunc {
 			return
 		}
 
+		// ✅ NEW: Signed embed configuration, checked before the plain domain lists
+		if client.RequireSignedEmbed && !m.checkEmbedToken(c, clientObjID) {
+			c.Abort()
+			return
+		}
+
 		// If domain authorization is not required, allow access
 		if !client.RequireDomainAuth {
 			c.Next()
@@ -141,6 +156,37 @@ func (m *DomainAuthMiddleware) CheckDomainAuthorization() gin.HandlerFunc {
 	}
 }
 
+// ✅ NEW: checkEmbedToken verifies the request's embed token for the client and that the requesting domain
+// is one the token was signed for; on failure it logs the violation and writes the 403
+func (m *DomainAuthMiddleware) checkEmbedToken(c *gin.Context, clientID primitive.ObjectID) bool {
+	token := c.GetHeader(EmbedTokenHeader)
+	if token == "" {
+		token = c.Query("embed_token")
+	}
+	requestDomain := m.getRequestDomain(c)
+
+	reason := ""
+	if token == "" {
+		reason = "Signed embed token missing"
+	} else if claims, err := VerifyEmbedToken(m.embedSigningSecret, token, time.Now()); err != nil {
+		reason = fmt.Sprintf("Signed embed token rejected: %v", err)
+	} else if claims.ClientID != clientID.Hex() {
+		reason = fmt.Sprintf("Signed embed token for client '%s' presented for another client", claims.ClientID)
+	} else if requestDomain == "" || len(claims.Domains) == 0 || !m.checkDomainAccess(requestDomain, claims.Domains, nil, "whitelist") {
+		reason = fmt.Sprintf("Domain '%s' is not covered by the signed embed token", requestDomain)
+	}
+	if reason == "" {
+		return true
+	}
+
+	m.logSuspiciousActivity(clientID, requestDomain, c, "invalid_embed_token", reason)
+	c.JSON(http.StatusForbidden, gin.H{
+		"error_code": "embed_token_invalid",
+		"message":    "A valid signed embed token is required for this client",
+	})
+	return false
+}
+
 // getRequestDomain extracts the domain from the request
 func (m *DomainAuthMiddleware) getRequestDomain(c *gin.Context) string {
 	// Try to get domain from referrer header first
@@ -238,8 +284,7 @@ func (m *DomainAuthMiddleware) checkDomainAccess(domain string, whitelist, black
 	case "whitelist":
 		// In whitelist mode, domain must be in whitelist
 		for _, allowedDomain := range normalizedWhitelist {
-			match := normalizedDomain == allowedDomain || strings.HasSuffix(normalizedDomain, "."+allowedDomain)
-			if match {
+			if matchDomainPattern(normalizedDomain, allowedDomain) {
 				return true
 			}
 		}
@@ -248,7 +293,7 @@ func (m *DomainAuthMiddleware) checkDomainAccess(domain string, whitelist, black
 	case "blacklist":
 		// In blacklist mode, domain must not be in blacklist
 		for _, blockedDomain := range normalizedBlacklist {
-			if normalizedDomain == blockedDomain || strings.HasSuffix(normalizedDomain, "."+blockedDomain) {
+			if matchDomainPattern(normalizedDomain, blockedDomain) {
 				return false
 			}
 		}
@@ -260,6 +305,40 @@ func (m *DomainAuthMiddleware) checkDomainAccess(domain string, whitelist, black
 	}
 }
 
+// ✅ NEW: matchDomainPattern matches a normalized domain against a list entry. "example.com" matches the
+// domain and its subdomains; "*.example.com" matches subdomains only, so the apex must be listed separately.
+func matchDomainPattern(domain, pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(domain, "."+suffix)
+	}
+	return domain == pattern || strings.HasSuffix(domain, "."+pattern)
+}
+
+// ✅ NEW: ValidDomainPattern reports whether a domain list entry is a host name, optionally with a leading
+// "*." wildcard label ("*.example.com"), after the same normalization as requests (scheme, path and port
+// are dropped). Wildcards anywhere else are rejected.
+func ValidDomainPattern(pattern string) bool {
+	pattern = new(DomainAuthMiddleware).normalizeDomain(strings.TrimSpace(pattern))
+	pattern = strings.TrimPrefix(pattern, "*.")
+	if pattern == "" || len(pattern) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(pattern, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // logSuspiciousActivity logs suspicious activity to the database
 func (m *DomainAuthMiddleware) logSuspiciousActivity(clientID primitive.ObjectID, domain string, c *gin.Context, alertType, message string) {
 	// Get additional request information
@@ -269,7 +348,7 @@ func (m *DomainAuthMiddleware) logSuspiciousActivity(clientID primitive.ObjectID
 
 	// Determine severity based on alert type
 	severity := "medium"
-	if alertType == "unauthorized_domain" {
+	if alertType == "unauthorized_domain" || alertType == "invalid_embed_token" {
 		severity = "high"
 	}
 
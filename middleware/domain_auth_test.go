@@ -0,0 +1,48 @@
+package middleware
+
+import "testing"
+
+func TestCheckDomainAccessWildcards(t *testing.T) {
+	m := &DomainAuthMiddleware{}
+	whitelist := []string{"example.com", "*.shop.io", "https://www.partner.org/embed"}
+
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"example.com", true},
+		{"blog.example.com", true},
+		{"notexample.com", false},
+		{"store.shop.io", true},
+		{"a.b.shop.io", true},
+		{"shop.io", false}, // wildcard covers subdomains only
+		{"evilshop.io", false},
+		{"partner.org", true},
+		{"other.com", false},
+	}
+	for _, tt := range tests {
+		if got := m.checkDomainAccess(tt.domain, whitelist, nil, "whitelist"); got != tt.want {
+			t.Errorf("whitelist %q: got %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+
+	if m.checkDomainAccess("cdn.bad.net", nil, []string{"*.bad.net"}, "blacklist") {
+		t.Error("blacklisted wildcard subdomain was allowed")
+	}
+	if !m.checkDomainAccess("bad.net", nil, []string{"*.bad.net"}, "blacklist") {
+		t.Error("apex was blocked by a wildcard blacklist entry")
+	}
+}
+
+func TestValidDomainPattern(t *testing.T) {
+	for _, pattern := range []string{"example.com", "*.example.com", "https://app.example.com:8080/x", "localhost", "WWW.Example.COM"} {
+		if !ValidDomainPattern(pattern) {
+			t.Errorf("%q should be valid", pattern)
+		}
+	}
+	for _, pattern := range []string{"", "*", "*example.com", "app.*.example.com", "exa mple.com", "-bad.com", "example..com"} {
+		if ValidDomainPattern(pattern) {
+			t.Errorf("%q should be invalid", pattern)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEmbedTokenRoundTrip(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	claims := EmbedTokenClaims{
+		ClientID: "64b7f0c2a1b2c3d4e5f60718",
+		Domains:  []string{"example.com", "*.shop.io"},
+		IssuedAt: now.Unix(),
+		Expires:  now.Add(time.Hour).Unix(),
+	}
+	token, err := SignEmbedToken("secret", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := VerifyEmbedToken("secret", token, now)
+	if err != nil || got.ClientID != claims.ClientID || len(got.Domains) != 2 {
+		t.Fatalf("got (%+v, %v)", got, err)
+	}
+
+	rejected := map[string]struct {
+		secret, token string
+		now           time.Time
+	}{
+		"wrong secret": {"other", token, now},
+		"expired":      {"secret", token, now.Add(2 * time.Hour)},
+		"tampered":     {"secret", "eyJjbGllbnRfaWQiOiJ4In0" + token[len(token)-65:], now},
+		"malformed":    {"secret", "not-a-token", now},
+		"no secret":    {"", token, now},
+	}
+	for name, tt := range rejected {
+		if _, err := VerifyEmbedToken(tt.secret, tt.token, tt.now); !errors.Is(err, ErrInvalidEmbedToken) {
+			t.Errorf("%s: got %v, want ErrInvalidEmbedToken", name, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"saas-chatbot-platform/models"
 	"saas-chatbot-platform/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,6 +13,127 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ClientRouteFeatures maps gated client API routes ("METHOD /full/path", as registered) to the feature
+// they require. The dashboard hides these screens when a feature is off; this map enforces the same
+// permissions for direct API calls. Routes not listed are available to every client.
+//
+// Ungated on purpose, because no feature in services.NavigationItemFeatures covers them: permissions,
+// branding reads, privacy erasure (DELETE /client/privacy/data, a data subject right that must always
+// work), retention policy, CRM sync, context signing and embed secrets, prompt template, source priority,
+// personas, no-answer escalation, profanity filter, follow-up suggestions, the Telegram bot connection
+// and the contact-collection maintenance/debug endpoints. Give such a route an entry here once a
+// feature for it exists.
+var ClientRouteFeatures = map[string]string{
+	// Documents and crawled pages (the knowledge base)
+	"POST /client/upload":            "pdf_upload",
+	"GET /client/pdfs":               "document_view",
+	"GET /client/pdfs/:id/status":    "document_status",
+	"GET /client/pdfs/:id/preview":   "document_view",
+	"DELETE /client/pdfs/:id":        "document_delete",
+	"DELETE /client/pdfs/bulk":       "document_delete",
+	"PATCH /client/pdfs/:id/status":  "document_manage",
+	"PATCH /client/pdfs/status/bulk": "document_manage",
+	"PATCH /client/pdfs/:id/active":  "document_manage",
+	"POST /client/crawl/start":       "document_manage",
+	"POST /client/crawl/bulk":        "document_manage",
+	"GET /client/crawls":             "document_view",
+	"GET /client/crawls/:id":         "document_view",
+	"GET /client/crawls/:id/status":  "document_status",
+	"DELETE /client/crawls/:id":      "document_delete",
+
+	// Async document pipeline (/api/async), same features as the /client equivalents
+	"POST /api/async/upload":            "pdf_upload",
+	"GET /api/async/pdfs":               "document_view",
+	"GET /api/async/pdf/:fileID/status": "document_status",
+
+	// Analytics
+	"GET /client/analytics":                  "analytics_view",
+	"GET /client/analytics/welcome-variants": "analytics_view",
+	"GET /client/analytics/funnel":           "analytics_view",
+	"GET /client/analytics-digest":           "analytics_view",
+	"PUT /client/analytics-digest":           "analytics_export",
+	"GET /client/analytics-timezone":         "analytics_view",
+	"PUT /client/analytics-timezone":         "analytics_view",
+	"GET /client/google-sheets":              "analytics_view",
+	"POST /client/google-sheets/connect":     "analytics_export",
+	"DELETE /client/google-sheets":           "analytics_export",
+
+	// Token usage
+	"GET /client/tokens": "token_usage_view",
+
+	// Quality dashboard
+	"GET /client/quality-metrics":               "quality_metrics_view",
+	"GET /client/quality-metrics/:period":       "quality_metrics_view",
+	"POST /client/quality-metrics/calculate":    "quality_metrics_view",
+	"GET /client/feedback-insights":             "quality_insights_view",
+	"GET /client/feedback-insights/:id/resolve": "quality_insights_view",
+	"DELETE /client/feedback-insights/:id":      "quality_insights_view",
+	"GET /client/feedback/search":               "quality_feedback_view",
+	"POST /client/feedback/process-unanalyzed":  "quality_feedback_view",
+	"POST /client/quality-alerts/check":         "quality_alerts_view",
+
+	// Chat history and exports
+	"GET /client/embed-chat-history":                          "chat_history_view",
+	"GET /client/embed-conversations/:id/messages":            "chat_history_details",
+	"GET /client/real-users-chat-history":                     "chat_history_view",
+	"GET /client/messages/search":                             "chat_history_view",
+	"GET /client/customer-journey":                            "chat_history_details",
+	"POST /client/conversations/:session/tags":                "chat_history_filter",
+	"POST /client/export/chats":                               "chat_history_export",
+	"GET /client/export/chats/download":                       "chat_history_export",
+	"GET /client/messages/:message_id/prompt":                 "chat_history_details",
+	"GET /client/export/defaults":                             "chat_history_export",
+	"PUT /client/export/defaults":                             "chat_history_export",
+	"POST /client/privacy/export":                             "chat_history_export",
+	"POST /client/conversations/:session/reset-contact-state": "chat_history_details",
+
+	// Branding
+	"POST /client/branding": "branding_theme_update",
+
+	// Images
+	"GET /client/images":        "image_view",
+	"POST /client/images":       "image_upload",
+	"POST /client/images/bulk":  "image_upload",
+	"PUT /client/images/:id":    "image_manage",
+	"DELETE /client/images/:id": "image_delete",
+
+	// Social posts
+	"GET /client/facebook-posts":          "facebook_post_view",
+	"POST /client/facebook-posts":         "facebook_post_add",
+	"DELETE /client/facebook-posts/:id":   "facebook_post_delete",
+	"GET /client/facebook-posts-config":   "facebook_post_view",
+	"POST /client/facebook-posts-config":  "facebook_post_manage",
+	"GET /client/instagram-posts":         "instagram_post_view",
+	"POST /client/instagram-posts":        "instagram_post_add",
+	"DELETE /client/instagram-posts/:id":  "instagram_post_delete",
+	"GET /client/instagram-posts-config":  "instagram_post_view",
+	"POST /client/instagram-posts-config": "instagram_post_manage",
+
+	// Website embed
+	"GET /client/website-embed-config":  "website_embed_view",
+	"POST /client/website-embed-config": "website_embed_configure",
+
+	// Email templates
+	"GET /client/email-templates":             "email_template_preview",
+	"GET /client/email-templates/:type":       "email_template_preview",
+	"POST /client/email-templates":            "email_template_create",
+	"PUT /client/email-templates/:id":         "email_template_edit",
+	"DELETE /client/email-templates/:id":      "email_template_delete",
+	"POST /client/email-templates/:type/test": "email_template_preview",
+
+	// Calendly
+	"GET /client/calendly":  "calendly_view",
+	"POST /client/calendly": "calendly_configure",
+
+	// QR codes
+	"GET /client/qr-code":           "qr_code_download",
+	"POST /client/qr-code":          "qr_code_generate_call",
+	"GET /client/whatsapp-qr-code":  "qr_code_download",
+	"POST /client/whatsapp-qr-code": "qr_code_generate_whatsapp",
+	"GET /client/telegram-qr-code":  "qr_code_download",
+	"POST /client/telegram-qr-code": "qr_code_generate_telegram",
+}
+
 // FeatureCheckMiddleware checks if a feature is enabled for the client
 type FeatureCheckMiddleware struct {
 	clientsCollection *mongo.Collection
@@ -24,108 +146,59 @@ func NewFeatureCheckMiddleware(clientsCollection *mongo.Collection) *FeatureChec
 	}
 }
 
-// RequireFeature checks if a specific feature is enabled for the client
-func (f *FeatureCheckMiddleware) RequireFeature(featureName string) gin.HandlerFunc {
+// EnforceRouteFeatures looks up the matched route in routeFeatures and, when it is gated, rejects the
+// request with 403 "feature_not_enabled" unless the feature is in the client's EnabledFeatures.
+// Register it on a group with Use; the route is matched after routing, so c.FullPath() is known.
+func (f *FeatureCheckMiddleware) EnforceRouteFeatures(routeFeatures map[string]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientID, exists := c.Get("client_id")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error_code": "unauthorized",
-				"message":    "Client ID not found in context",
-			})
-			c.Abort()
-			return
-		}
-
-		clientOID, ok := clientID.(primitive.ObjectID)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_code": "internal_error",
-				"message":    "Invalid client ID type",
-			})
-			c.Abort()
+		featureName, gated := routeFeatures[c.Request.Method+" "+c.FullPath()]
+		if !gated {
+			c.Next()
 			return
 		}
+		f.requireFeature(c, featureName)
+	}
+}
 
-		// Get client permissions
-		var client models.Client
-		if err := f.clientsCollection.FindOne(context.Background(), bson.M{"_id": clientOID}).Decode(&client); err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error_code": "client_not_found",
-					"message":    "Client not found",
-				})
-				c.Abort()
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_code": "internal_error",
-				"message":    "Failed to retrieve client permissions",
-			})
-			c.Abort()
-			return
-		}
+// RequireFeature checks if a specific feature is enabled for the client
+func (f *FeatureCheckMiddleware) RequireFeature(featureName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f.requireFeature(c, featureName)
+	}
+}
 
-		// Check if feature is enabled
-		// If enabledFeatures is empty, all features are enabled (backward compatible)
-		if !services.HasFeature(client.Permissions.EnabledFeatures, featureName) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error_code": "feature_disabled",
-				"message":    "This feature is not enabled for your account. Please contact your administrator.",
-			})
-			c.Abort()
-			return
-		}
+func (f *FeatureCheckMiddleware) requireFeature(c *gin.Context, featureName string) {
+	permissions, ok := f.loadPermissions(c)
+	if !ok {
+		return
+	}
 
-		c.Next()
+	// Check if feature is enabled
+	// If enabledFeatures is empty, all features are enabled (backward compatible)
+	if !services.HasFeature(permissions.EnabledFeatures, featureName) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error_code": "feature_not_enabled",
+			"message":    "This feature is not enabled for your account. Please contact your administrator.",
+			"feature":    featureName,
+		})
+		c.Abort()
+		return
 	}
+
+	c.Next()
 }
 
 // RequireNavigationItem checks if a navigation item is allowed for the client
 func (f *FeatureCheckMiddleware) RequireNavigationItem(itemName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientID, exists := c.Get("client_id")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error_code": "unauthorized",
-				"message":    "Client ID not found in context",
-			})
-			c.Abort()
-			return
-		}
-
-		clientOID, ok := clientID.(primitive.ObjectID)
+		permissions, ok := f.loadPermissions(c)
 		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_code": "internal_error",
-				"message":    "Invalid client ID type",
-			})
-			c.Abort()
-			return
-		}
-
-		// Get client permissions
-		var client models.Client
-		if err := f.clientsCollection.FindOne(context.Background(), bson.M{"_id": clientOID}).Decode(&client); err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error_code": "client_not_found",
-					"message":    "Client not found",
-				})
-				c.Abort()
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_code": "internal_error",
-				"message":    "Failed to retrieve client permissions",
-			})
-			c.Abort()
 			return
 		}
 
 		// Check if navigation item is allowed
 		// If allowedNavigationItems is empty, all items are allowed (backward compatible)
-		if !services.HasNavigationItem(client.Permissions.AllowedNavigationItems, itemName) {
+		if !services.HasNavigationItem(permissions.AllowedNavigationItems, itemName) {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error_code": "navigation_item_disabled",
 				"message":    "This feature is not enabled for your account. Please contact your administrator.",
@@ -138,3 +211,40 @@ func (f *FeatureCheckMiddleware) RequireNavigationItem(itemName string) gin.Hand
 	}
 }
 
+// loadPermissions fetches the authenticated client's permissions, aborting the request when it can't
+func (f *FeatureCheckMiddleware) loadPermissions(c *gin.Context) (models.ClientPermissions, bool) {
+	clientOID, err := primitive.ObjectIDFromHex(GetClientID(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error_code": "unauthorized",
+			"message":    "Client ID not found in context",
+		})
+		c.Abort()
+		return models.ClientPermissions{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	// Get client permissions
+	var client models.Client
+	err = f.clientsCollection.FindOne(ctx, bson.M{"_id": clientOID}).Decode(&client)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			c.Abort()
+			return models.ClientPermissions{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error_code": "internal_error",
+			"message":    "Failed to retrieve client permissions",
+		})
+		c.Abort()
+		return models.ClientPermissions{}, false
+	}
+
+	return client.Permissions, true
+}
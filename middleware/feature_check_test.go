@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"saas-chatbot-platform/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientRouteFeatures_AreKnownFeatures(t *testing.T) {
+	known := make(map[string]bool)
+	for _, features := range services.NavigationItemFeatures {
+		for _, feature := range features {
+			known[feature] = true
+		}
+	}
+	for route, feature := range ClientRouteFeatures {
+		if !known[feature] {
+			t.Errorf("route %q requires unknown feature %q", route, feature)
+		}
+	}
+}
+
+func TestEnforceRouteFeatures_UngatedRouteSkipsLookup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// A nil collection would panic if the ungated route tried to load permissions
+	router.Use(NewFeatureCheckMiddleware(nil).EnforceRouteFeatures(map[string]string{
+		"GET /client/analytics": "analytics_view",
+	}))
+	router.GET("/client/branding", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/client/branding", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
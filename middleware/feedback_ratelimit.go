@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// feedbackDedupTTL is how long one IP is prevented from rating the same message again
+const feedbackDedupTTL = 30 * 24 * time.Hour
+
+// FeedbackRateLimit protects public feedback from abuse: each IP may submit one feedback per
+// message and at most cfg.FeedbackRateLimit feedbacks per window
+func FeedbackRateLimit(rdb *redis.Client, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		dedupKey := "feedback:message:" + c.Param("message_id") + ":" + ip
+		capKey := "feedback:ip:" + ip
+		window := time.Duration(cfg.FeedbackRateWindow) * time.Second
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+
+		// One feedback per message per IP
+		first, err := rdb.SetNX(ctx, dedupKey, 1, feedbackDedupTTL).Result()
+		if err != nil {
+			// Fail open - don't block feedback if Redis is down
+			c.Next()
+			return
+		}
+		if !first {
+			utils.RespondWithError(c, http.StatusConflict,
+				"duplicate_feedback",
+				"Feedback for this message has already been submitted.",
+				nil)
+			c.Abort()
+			return
+		}
+
+		// Cap per IP per window
+		count, err := rdb.Incr(ctx, capKey).Result()
+		if err == nil {
+			if count == 1 {
+				rdb.Expire(ctx, capKey, window)
+			}
+			if count > int64(cfg.FeedbackRateLimit) {
+				rdb.Del(ctx, dedupKey)
+				c.Header("Retry-After", strconv.Itoa(cfg.FeedbackRateWindow))
+				utils.RespondWithError(c, http.StatusTooManyRequests,
+					"feedback_rate_limited",
+					"Too many feedback submissions. Please try again later.",
+					gin.H{
+						"retry_after": cfg.FeedbackRateWindow,
+						"limit":       cfg.FeedbackRateLimit,
+					})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+
+		// Rejected submissions (bad body, unknown message) don't count as this IP's feedback
+		if c.Writer.Status() >= http.StatusBadRequest {
+			rdb.Del(context.Background(), dedupKey)
+		}
+	}
+}
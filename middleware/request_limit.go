@@ -3,26 +3,68 @@ package middleware
 import (
 	"net/http"
 	"saas-chatbot-platform/utils"
+	"strings"
 	"github.com/gin-gonic/gin"
 )
 
 // RequestSizeLimit middleware limits the size of request bodies
 func RequestSizeLimit(maxSize int64) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check Content-Length header
-		if c.Request.ContentLength > maxSize {
-			utils.RespondWithError(c, http.StatusRequestEntityTooLarge,
-				"request_too_large",
-				"Request body exceeds maximum size",
-				gin.H{
-					"max_size":  maxSize,
-					"received":  c.Request.ContentLength,
-					"max_size_mb": maxSize / (1024 * 1024),
-				})
-			c.Abort()
+		if !limitRequestBody(c, maxSize) {
 			return
 		}
 		c.Next()
 	}
 }
 
+// ✅ NEW: BodySizeRule caps the request body for routes whose path starts with Prefix
+type BodySizeRule struct {
+	Prefix   string
+	MaxBytes int64
+}
+
+// RouteRequestSizeLimit limits request bodies per route group, e.g. small JSON for the public chat and the
+// file size limit for uploads. The rule with the longest prefix matching the registered route (such as
+// "/admin/client/:id/documents") applies; other routes get defaultMax.
+func RouteRequestSizeLimit(defaultMax int64, rules []BodySizeRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+
+		maxSize, matched := defaultMax, -1
+		for _, rule := range rules {
+			if strings.HasPrefix(path, rule.Prefix) && len(rule.Prefix) > matched {
+				maxSize, matched = rule.MaxBytes, len(rule.Prefix)
+			}
+		}
+
+		if !limitRequestBody(c, maxSize) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// limitRequestBody rejects a declared Content-Length over maxSize with 413 before anything is read, and
+// caps bodies sent without one (chunked) so reading past maxSize fails. It returns false if it aborted.
+func limitRequestBody(c *gin.Context, maxSize int64) bool {
+	// Check Content-Length header
+	if c.Request.ContentLength > maxSize {
+		utils.RespondWithError(c, http.StatusRequestEntityTooLarge,
+			"request_too_large",
+			"Request body exceeds maximum size",
+			gin.H{
+				"max_size":  maxSize,
+				"received":  c.Request.ContentLength,
+				"max_size_mb": maxSize / (1024 * 1024),
+			})
+		c.Abort()
+		return false
+	}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	}
+	return true
+}
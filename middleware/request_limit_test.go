@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteRequestSizeLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RouteRequestSizeLimit(1000, []BodySizeRule{
+		{Prefix: "/public/", MaxBytes: 10},
+		{Prefix: "/admin/client/:id/documents", MaxBytes: 5000},
+	}))
+	read := func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	router.POST("/public/chat", read)
+	router.POST("/admin/client/:id/documents", read)
+	router.POST("/client/settings", read)
+
+	tests := []struct {
+		name    string
+		path    string
+		size    int
+		chunked bool
+		want    int
+	}{
+		{"small chat", "/public/chat", 10, false, http.StatusOK},
+		{"oversized chat", "/public/chat", 11, false, http.StatusRequestEntityTooLarge},
+		{"oversized chunked chat", "/public/chat", 11, true, http.StatusBadRequest},
+		{"upload above default", "/admin/client/abc/documents", 4000, false, http.StatusOK},
+		{"default limit", "/client/settings", 1001, false, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		var body io.Reader = strings.NewReader(strings.Repeat("a", tt.size))
+		if tt.chunked {
+			body = io.MultiReader(body) // hides the length, so the request is sent without Content-Length
+		}
+		req := httptest.NewRequest(http.MethodPost, tt.path, body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if tt.want == http.StatusRequestEntityTooLarge && !strings.Contains(w.Body.String(), `"request_too_large"`) {
+			t.Errorf("%s: body = %s", tt.name, w.Body.String())
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -53,9 +54,10 @@ func (e *AuditEvent) ComputeHash() string {
 
 // AuditLogger handles immutable audit logging
 type AuditLogger struct {
-	col        *mongo.Collection
-	lastHashMu sync.RWMutex
-	lastHashes map[string]string // clientID -> last hash
+	col         *mongo.Collection
+	lastHashMu  sync.RWMutex
+	lastHashes  map[string]string // clientID -> last hash
+	clientLocks sync.Map          // clientID -> *sync.Mutex serializing hash assignment and insert
 }
 
 // NewAuditLogger creates a new audit logger
@@ -96,6 +98,38 @@ func NewAuditLogger(db *mongo.Database) *AuditLogger {
 				{Key: "request_id", Value: 1},
 			},
 		},
+		// ✅ NEW: Compound indexes for the audit query filters (actor, action, resource, result) sorted by time
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "timestamp", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "client_id", Value: 1},
+				{Key: "action", Value: 1},
+				{Key: "timestamp", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "action", Value: 1},
+				{Key: "timestamp", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "resource", Value: 1},
+				{Key: "timestamp", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "success", Value: 1},
+				{Key: "timestamp", Value: -1},
+			},
+		},
 	}
 
 	// Create indexes
@@ -109,13 +143,24 @@ func NewAuditLogger(db *mongo.Database) *AuditLogger {
 
 // Log logs an audit event
 func (al *AuditLogger) Log(event *AuditEvent) error {
-	al.lastHashMu.Lock()
-	defer al.lastHashMu.Unlock()
+	// ✅ NEW: One client's events are chained and inserted one at a time so concurrent
+	// LogAsync calls can't both link to the same previous hash
+	lock, _ := al.clientLocks.LoadOrStore(event.ClientID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
 
 	// Get last hash for this client to create chain
-	event.PreviousHash = al.lastHashes[event.ClientID]
-	event.Timestamp = time.Now().UTC()
-	event.CreatedAt = time.Now().UTC()
+	al.lastHashMu.RLock()
+	previousHash, ok := al.lastHashes[event.ClientID]
+	al.lastHashMu.RUnlock()
+	if !ok {
+		// ✅ NEW: Continue the stored chain after a restart instead of starting a new one
+		previousHash = al.loadLastHash(event.ClientID)
+	}
+	event.PreviousHash = previousHash
+	// MongoDB stores milliseconds; hash the timestamp exactly as it will be read back
+	event.Timestamp = time.Now().UTC().Truncate(time.Millisecond)
+	event.CreatedAt = event.Timestamp
 
 	// Generate unique ID
 	event.ID = fmt.Sprintf("%d_%s", time.Now().UnixNano(), event.ClientID)
@@ -132,12 +177,36 @@ func (al *AuditLogger) Log(event *AuditEvent) error {
 	}
 
 	// Update last hash
+	al.lastHashMu.Lock()
 	al.lastHashes[event.ClientID] = event.CurrentHash
+	al.lastHashMu.Unlock()
 
 	log.Printf("✅ Audit event logged: %s %s %s", event.Action, event.Resource, event.ResourceID)
 	return nil
 }
 
+// loadLastHash returns the hash of the client's most recent audit event ("" if there is none)
+func (al *AuditLogger) loadLastHash(clientID string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var last AuditEvent
+	err := al.col.FindOne(ctx,
+		bson.M{"client_id": clientID},
+		options.FindOne().SetSort(chainOrder(-1)).SetProjection(bson.M{"current_hash": 1}),
+	).Decode(&last)
+	if err == nil {
+		return last.CurrentHash
+	}
+
+	// Every stored entry may have been archived; continue from the anchor
+	var anchor AuditChainAnchor
+	if err := al.AnchorCollection().FindOne(ctx, bson.M{"_id": clientID}).Decode(&anchor); err != nil {
+		return ""
+	}
+	return anchor.Hash
+}
+
 // LogAsync logs an audit event asynchronously
 func (al *AuditLogger) LogAsync(event *AuditEvent) {
 	go func() {
@@ -147,12 +216,122 @@ func (al *AuditLogger) LogAsync(event *AuditEvent) {
 	}()
 }
 
+// AuditChainAnchor records where a client's chain continues after older entries were archived and purged
+type AuditChainAnchor struct {
+	ClientID      string             `bson:"_id" json:"client_id"`
+	Hash          string             `bson:"hash" json:"hash"`                     // current_hash of the last archived entry
+	PurgedThrough time.Time          `bson:"purged_through" json:"purged_through"` // timestamp of the last archived entry
+	ArchiveID     primitive.ObjectID `bson:"archive_id" json:"archive_id"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// AuditArchive describes one signed export of audit entries that were purged from the database
+type AuditArchive struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FilePath     string             `bson:"file_path" json:"file_path"`
+	Signature    string             `bson:"signature" json:"signature"` // hex HMAC-SHA256 of the file
+	SHA256       string             `bson:"sha256" json:"sha256"`
+	Cutoff       time.Time          `bson:"cutoff" json:"cutoff"` // entries older than this were exported
+	EventCount   int64              `bson:"event_count" json:"event_count"`
+	ClientCount  int                `bson:"client_count" json:"client_count"`
+	PurgedCount  int64              `bson:"purged_count" json:"purged_count"`
+	Status       string             `bson:"status" json:"status"` // exported, purged, purge_failed
+	ErrorMessage string             `bson:"error_message,omitempty" json:"error_message,omitempty"`
+	CreatedBy    string             `bson:"created_by" json:"created_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// AnchorCollection returns the collection of chain anchors left behind by audit purges
+func (al *AuditLogger) AnchorCollection() *mongo.Collection {
+	return al.col.Database().Collection("audit_chain_anchors")
+}
+
+// chainOrder sorts a client's events in chain order; _id breaks ties between events
+// logged in the same millisecond (it starts with the zero-padded UnixNano)
+func chainOrder(direction int) bson.D {
+	return bson.D{{Key: "timestamp", Value: direction}, {Key: "_id", Value: direction}}
+}
+
+// ClientChainOrder sorts the events of many clients by client, then each client's events in chain order
+func ClientChainOrder() bson.D {
+	return append(bson.D{{Key: "client_id", Value: 1}}, chainOrder(1)...)
+}
+
 // VerifyChain verifies the integrity of the audit chain for a client
 func (al *AuditLogger) VerifyChain(clientID string) (bool, error) {
 	ctx := context.Background()
-	cursor, err := al.col.Find(ctx,
+
+	// ✅ NEW: After a purge the remaining chain must continue from the archived anchor
+	filter := bson.M{"client_id": clientID}
+	var anchor AuditChainAnchor
+	startHash, hasStart := "", false
+	if err := al.AnchorCollection().FindOne(ctx, bson.M{"_id": clientID}).Decode(&anchor); err == nil {
+		startHash, hasStart = anchor.Hash, true
+		filter["timestamp"] = bson.M{"$gt": anchor.PurgedThrough}
+	} else if err != mongo.ErrNoDocuments {
+		return false, err
+	}
+
+	return al.verifyChainFrom(ctx, clientID, filter, startHash, hasStart)
+}
+
+// AuditChainBaseline is the event a client's scheduled chain verification starts after
+type AuditChainBaseline struct {
+	EventID   string    `bson:"event_id" json:"event_id"`
+	Hash      string    `bson:"hash" json:"hash"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// ChainHead returns the client's most recent audit event as a verification baseline
+func (al *AuditLogger) ChainHead(clientID string) (*AuditChainBaseline, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var last AuditEvent
+	err := al.col.FindOne(ctx,
 		bson.M{"client_id": clientID},
-		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}),
+		options.FindOne().SetSort(chainOrder(-1)).SetProjection(bson.M{"current_hash": 1, "timestamp": 1}),
+	).Decode(&last)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditChainBaseline{EventID: last.ID, Hash: last.CurrentHash, Timestamp: last.Timestamp}, nil
+}
+
+// VerifyChainSince verifies only the client's events logged after baseline; the first of them
+// must continue from baseline.Hash. Entries at or before the baseline are never re-checked,
+// unless a purge has archived past it, in which case the chain continues from the purge anchor.
+func (al *AuditLogger) VerifyChainSince(clientID string, baseline *AuditChainBaseline) (bool, error) {
+	ctx := context.Background()
+
+	filter := bson.M{
+		"client_id": clientID,
+		"$or": bson.A{
+			bson.M{"timestamp": bson.M{"$gt": baseline.Timestamp}},
+			bson.M{"timestamp": baseline.Timestamp, "_id": bson.M{"$gt": baseline.EventID}},
+		},
+	}
+	startHash := baseline.Hash
+
+	var anchor AuditChainAnchor
+	if err := al.AnchorCollection().FindOne(ctx, bson.M{"_id": clientID}).Decode(&anchor); err == nil {
+		if !anchor.PurgedThrough.Before(baseline.Timestamp) {
+			filter = bson.M{"client_id": clientID, "timestamp": bson.M{"$gt": anchor.PurgedThrough}}
+			startHash = anchor.Hash
+		}
+	} else if err != mongo.ErrNoDocuments {
+		return false, err
+	}
+
+	return al.verifyChainFrom(ctx, clientID, filter, startHash, true)
+}
+
+// verifyChainFrom walks the events matching filter in chain order. When hasStart is set the
+// first event must link to startHash.
+func (al *AuditLogger) verifyChainFrom(ctx context.Context, clientID string, filter bson.M, startHash string, hasStart bool) (bool, error) {
+	cursor, err := al.col.Find(ctx,
+		filter,
+		options.Find().SetSort(chainOrder(1)),
 	)
 	if err != nil {
 		return false, err
@@ -171,6 +350,10 @@ func (al *AuditLogger) VerifyChain(clientID string) (bool, error) {
 		eventCount++
 
 		// Verify previous hash matches (except for first event)
+		if eventCount == 1 && hasStart && event.PreviousHash != startHash {
+			log.Printf("❌ Audit chain broken at event %s - does not continue from its starting point", event.ID)
+			return false, nil
+		}
 		if eventCount > 1 && event.PreviousHash != previousHash {
 			log.Printf("❌ Audit chain broken at event %s - previous hash mismatch", event.ID)
 			return false, nil
@@ -272,6 +455,21 @@ func (al *AuditLogger) GetAuditSummary(clientID string, days int) (map[string]in
 	return summary, nil
 }
 
+// AuditChainStatus records the outcome of the latest scheduled chain verification for a client
+type AuditChainStatus struct {
+	ClientID       string     `bson:"_id" json:"client_id"`
+	Valid          bool       `bson:"valid" json:"valid"`
+	LastVerifiedAt time.Time  `bson:"last_verified_at" json:"last_verified_at"`
+	Error          string     `bson:"error,omitempty" json:"error,omitempty"` // set when verification could not run
+	AlertedAt      *time.Time `bson:"alerted_at,omitempty" json:"alerted_at,omitempty"`
+	// ✅ NEW: Incremental verification starts after this event
+	Baseline *AuditChainBaseline `bson:"baseline,omitempty" json:"baseline,omitempty"`
+	// ✅ NEW: Outcome of the latest full verification: "verified", "legacy_unverifiable" (the chain
+	// already failed on the first full check, so it predates reproducible hashes) or "failed"
+	History           string     `bson:"history,omitempty" json:"history,omitempty"`
+	HistoryVerifiedAt *time.Time `bson:"history_verified_at,omitempty" json:"history_verified_at,omitempty"`
+}
+
 // Collection returns the audit collection for direct access
 func (al *AuditLogger) Collection() *mongo.Collection {
 	return al.col
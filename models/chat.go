@@ -24,6 +24,9 @@ type Message struct {
 	// Contact collection state
 	ContactCollectionPhase string `bson:"contact_collection_phase,omitempty" json:"contact_collection_phase,omitempty"` // 'none', 'awaiting_name', 'awaiting_email', 'completed'
 	ChatDisabled           bool   `bson:"chat_disabled,omitempty" json:"chat_disabled,omitempty"`                       // Whether chat is disabled after contact collection
+	// ✅ NEW: Details given during this conversation's contact collection (user_name may be remembered from the visitor's IP)
+	ContactName  string `bson:"contact_name,omitempty" json:"contact_name,omitempty"`
+	ContactEmail string `bson:"contact_email,omitempty" json:"contact_email,omitempty"`
 
 	// ✅ NEW: IP tracking and user identification for embed users
 	UserIP      string `bson:"user_ip,omitempty" json:"user_ip,omitempty"`
@@ -31,6 +34,7 @@ type Message struct {
 	Referrer    string `bson:"referrer,omitempty" json:"referrer,omitempty"`
 	SessionID   string `bson:"session_id,omitempty" json:"session_id,omitempty"`
 	IsEmbedUser bool   `bson:"is_embed_user" json:"is_embed_user"`
+	Channel     string `bson:"channel,omitempty" json:"channel,omitempty"` // ✅ NEW: "embed", "telegram", ...
 
 	// ✅ ENHANCED: Comprehensive geolocation data (95% accurate)
 	Country      string  `bson:"country,omitempty" json:"country,omitempty"`           // Country name
@@ -44,6 +48,39 @@ type Message struct {
 	ISP          string  `bson:"isp,omitempty" json:"isp,omitempty"`                   // Internet Service Provider
 	Organization string  `bson:"organization,omitempty" json:"organization,omitempty"` // Organization/Company
 	IPType       string  `bson:"ip_type,omitempty" json:"ip_type,omitempty"`           // Residential/Datacenter/VPN/Proxy
+
+	// ✅ NEW: Which knowledge sources the reply drew from
+	SourceAttribution *SourceAttribution `bson:"source_attribution,omitempty" json:"source_attribution,omitempty"`
+
+	// ✅ NEW: How well the reply is supported by the client's knowledge
+	Confidence *ResponseConfidence `bson:"confidence,omitempty" json:"confidence,omitempty"`
+
+	// ✅ NEW: Demo booking created when the user confirmed a demo
+	DemoBooking *DemoBooking `bson:"demo_booking,omitempty" json:"demo_booking,omitempty"`
+
+	// ✅ NEW: Welcome message variant shown when the conversation started
+	WelcomeVariant string `bson:"welcome_variant,omitempty" json:"welcome_variant,omitempty"`
+
+	// ✅ NEW: Set when the message was filtered as spam (no AI reply, no tokens charged)
+	Spam *SpamVerdict `bson:"spam,omitempty" json:"spam,omitempty"`
+
+	// ✅ NEW: Handoff of the conversation to WhatsApp
+	WhatsAppHandoff *ChannelHandoff `bson:"whatsapp_handoff,omitempty" json:"whatsapp_handoff,omitempty"`
+
+	// ✅ NEW: Set when this reply offered the team after repeated unanswered questions
+	NoAnswerEscalation bool `bson:"no_answer_escalation,omitempty" json:"no_answer_escalation,omitempty"`
+
+	// ✅ NEW: Set when the first reply nearly repeated an earlier one and was regenerated
+	RepeatedReplyRegenerated bool `bson:"repeated_reply_regenerated,omitempty" json:"repeated_reply_regenerated,omitempty"`
+
+	// ✅ NEW: Set on every message of a conversation once it reached its maximum length
+	ConversationClosed bool `bson:"conversation_closed,omitempty" json:"conversation_closed,omitempty"`
+
+	// ✅ NEW: Unsupported sentences strict grounding replaced in this reply
+	StrippedClaims int `bson:"stripped_claims,omitempty" json:"stripped_claims,omitempty"`
+
+	// ✅ NEW: Client persona that answered; later messages in the session stay with it unless another persona is triggered
+	PersonaID *primitive.ObjectID `bson:"persona_id,omitempty" json:"persona_id,omitempty"`
 }
 
 // ✅ UPDATED: Your existing ChatRequest with fixes
@@ -108,6 +145,16 @@ type UserNameByIP struct {
 	Count     int                `bson:"count" json:"count"` // Number of conversations from this IP
 }
 
+// ✅ ADDED: Conversation tags for client CRM workflows (collection: conversation_tags)
+type ConversationTags struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID  primitive.ObjectID `bson:"client_id" json:"client_id"`
+	SessionID string             `bson:"session_id" json:"session_id"`
+	Tags      []string           `bson:"tags" json:"tags"`                               // Tags assigned by the client, e.g. "lead", "complaint", "spam"
+	AutoTags  []string           `bson:"auto_tags,omitempty" json:"auto_tags,omitempty"` // Tags assigned from intent score and topic detection
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
 // ✅ ADDED: Message feedback model for thumbs up/down
 type MessageFeedback struct {
 	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -129,6 +176,8 @@ type MessageFeedback struct {
 	AnalysisDate     time.Time          `bson:"analysis_date,omitempty" json:"analysis_date,omitempty"`
 	QualityScore     float64            `bson:"quality_score,omitempty" json:"quality_score,omitempty"` // 0-1 quality score
 	InsightCreated   bool               `bson:"insight_created,omitempty" json:"insight_created,omitempty"` // Whether this feedback has been used to create an insight
+	DominantSource   string             `bson:"dominant_source,omitempty" json:"dominant_source,omitempty"` // Source the rated reply drew from ("pdf", "crawl", "none")
+	HighlightedText  string             `bson:"highlighted_text,omitempty" json:"highlighted_text,omitempty"` // Span of the reply the user flagged
 }
 
 // ✅ ADDED: Performance metrics model for response time tracking
@@ -147,6 +196,106 @@ type PerformanceMetrics struct {
 	ResponseLength       int                `bson:"response_length,omitempty" json:"response_length,omitempty"`
 }
 
+// ✅ NEW: SpamVerdict records why a message was filtered as spam
+type SpamVerdict struct {
+	Score  float64 `bson:"score" json:"score"`   // 0 (clean) to 1 (certain spam)
+	Reason string  `bson:"reason" json:"reason"` // strongest signal: gibberish, repeated_characters, links, spam_keywords or abuse
+}
+
+// ✅ ADDED: Source attribution for multi-document answers
+// SourceAttribution records which retrieved sources most likely informed a reply
+type SourceAttribution struct {
+	DominantSource string             `bson:"dominant_source" json:"dominant_source"` // "pdf", "crawl" or "none"
+	PDFScore       float64            `bson:"pdf_score" json:"pdf_score"`             // Best overlap score among PDF chunks (0-1)
+	CrawlScore     float64            `bson:"crawl_score" json:"crawl_score"`         // Best overlap score among crawled chunks (0-1)
+	TopSources     []AttributedSource `bson:"top_sources,omitempty" json:"top_sources,omitempty"`
+
+	// ✅ NEW: Source-of-truth resolution, for debugging contradictory answers
+	PersonaScore   float64  `bson:"persona_score,omitempty" json:"persona_score,omitempty"`     // Best overlap with a paragraph of the persona (0-1)
+	SourcePriority []string `bson:"source_priority,omitempty" json:"source_priority,omitempty"` // Order the sources were trusted in
+	WinningSource  string   `bson:"winning_source,omitempty" json:"winning_source,omitempty"`   // Highest-priority source the reply drew from: "persona", "pdf", "crawl" or "none"
+}
+
+// ✅ ADDED: Response confidence
+// ResponseConfidence estimates how likely a reply is grounded in the client's knowledge rather than guessed
+type ResponseConfidence struct {
+	Score          float64 `bson:"score" json:"score"`                     // 0-1
+	Level          string  `bson:"level" json:"level"`                     // "high", "medium" or "low"
+	RetrievalScore float64 `bson:"retrieval_score" json:"retrieval_score"` // Share of query words found in the best matching chunk (0-1)
+	GroundingScore float64 `bson:"grounding_score" json:"grounding_score"` // Best overlap between the reply and a retrieved chunk (0-1)
+	HasGoodMatches bool    `bson:"has_good_matches" json:"has_good_matches"`
+	NoInformation  bool    `bson:"no_information" json:"no_information"` // Reply admits the information is unavailable
+}
+
+// ✅ NEW: Structured output for integrations
+// StructuredReply is a reply generated in JSON mode, with the detected intent and extracted entities
+type StructuredReply struct {
+	Reply            string             `bson:"reply" json:"reply"`
+	Intent           string             `bson:"intent" json:"intent"`                       // snake_case label, e.g. "pricing_inquiry"
+	Entities         []StructuredEntity `bson:"entities" json:"entities"`                   // facts extracted from the user's message
+	SuggestedActions []string           `bson:"suggested_actions" json:"suggested_actions"` // short quick-reply options
+}
+
+// StructuredEntity is one entity extracted from the user's message
+type StructuredEntity struct {
+	Type  string `bson:"type" json:"type"` // e.g. "product", "date", "location"
+	Value string `bson:"value" json:"value"`
+}
+
+// AttributedSource is a single chunk that overlapped with the reply
+type AttributedSource struct {
+	SourceType string  `bson:"source_type" json:"source_type"` // "pdf" or "crawl"
+	Reference  string  `bson:"reference" json:"reference"`     // PDF chunk ID or crawled page URL
+	Score      float64 `bson:"score" json:"score"`             // Token overlap with the reply (0-1)
+}
+
+// ✅ ADDED: Prompt inspection
+// PromptSnapshot is the redacted prompt a reply was generated from, kept for clients with prompt inspection enabled
+type PromptSnapshot struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID       primitive.ObjectID `bson:"client_id" json:"client_id"`
+	MessageID      primitive.ObjectID `bson:"message_id" json:"message_id"`
+	ConversationID string             `bson:"conversation_id" json:"conversation_id"`
+	Prompt         string             `bson:"prompt" json:"prompt"`                   // Built-in instructions replaced by [redacted: ...] markers
+	CustomTemplate bool               `bson:"custom_template" json:"custom_template"` // Rendered from the client's own prompt template
+	PersonaSource  string             `bson:"persona_source" json:"persona_source"`   // "client", "persona", "default" (redacted) or "none"
+	Sources        []PromptSource     `bson:"sources" json:"sources"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt      time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+// PromptSource is one retrieved chunk placed in the prompt's knowledge base
+type PromptSource struct {
+	SourceType string `bson:"source_type" json:"source_type"` // "pdf" or "crawl"
+	Reference  string `bson:"reference" json:"reference"`     // PDF chunk ID or crawled page URL
+	Excerpt    string `bson:"excerpt" json:"excerpt"`
+}
+
+// ✅ ADDED: Automatic demo scheduling
+// DemoBooking references the Calendly booking created for a conversation
+
+type DemoBooking struct {
+	Provider   string     `bson:"provider" json:"provider"`                       // "calendly"
+	Method     string     `bson:"method" json:"method"`                           // "api" (single-use link) or "link" (prefilled public link)
+	BookingURL string     `bson:"booking_url" json:"booking_url"`                 // Link the user opens to pick a slot
+	Reference  string     `bson:"reference,omitempty" json:"reference,omitempty"` // Calendly scheduling link URI when created via API
+	Name       string     `bson:"name,omitempty" json:"name,omitempty"`
+	Email      string     `bson:"email,omitempty" json:"email,omitempty"`
+	DemoTime   string     `bson:"demo_time,omitempty" json:"demo_time,omitempty"` // Time mentioned in conversation, if any
+	DemoAt     *time.Time `bson:"demo_at,omitempty" json:"demo_at,omitempty"`     // ✅ NEW: DemoTime resolved to a datetime
+	Timezone   string     `bson:"timezone,omitempty" json:"timezone,omitempty"`   // ✅ NEW: client timezone DemoAt was resolved in
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+}
+
+// ✅ ADDED: Channel handoff record
+// ChannelHandoff records that a conversation was handed off to another messaging channel
+type ChannelHandoff struct {
+	Channel   string    `bson:"channel" json:"channel"` // "whatsapp"
+	Link      string    `bson:"link" json:"link"`       // Deep link given to the user
+	UserName  string    `bson:"user_name,omitempty" json:"user_name,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
 // PhaseTimings represents timing breakdown for different phases
 type PhaseTimings struct {
 	ContextRetrievalMs int `bson:"context_retrieval_ms" json:"context_retrieval_ms"`
@@ -155,6 +304,7 @@ type PhaseTimings struct {
 	PromptBuildingMs   int `bson:"prompt_building_ms" json:"prompt_building_ms"`
 	AIGenerationMs     int `bson:"ai_generation_ms" json:"ai_generation_ms"`
 	ValidationMs       int `bson:"validation_ms" json:"validation_ms"`
+	ClientInitMs       int `bson:"client_init_ms" json:"client_init_ms"` // ✅ NEW: time to obtain the Gemini clients
 }
 
 // ✅ ADDED: Quality metrics model for tracking feedback quality
@@ -173,6 +323,32 @@ type QualityMetrics struct {
 	AverageQualityScore float64            `bson:"average_quality_score" json:"average_quality_score"`
 	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// ✅ NEW: Satisfaction and quality per topic, and per topic per day for charting
+	TopicBreakdown map[string]TopicQuality `bson:"topic_breakdown" json:"topic_breakdown"`
+	TopicTrend     []TopicQualityPoint     `bson:"-" json:"topic_trend"`
+
+	// ✅ NEW: Replies regenerated because they nearly repeated an earlier reply in the session
+	RepeatedReplies   int     `bson:"repeated_replies" json:"repeated_replies"`
+	RepeatedReplyRate float64 `bson:"repeated_reply_rate" json:"repeated_reply_rate"` // share of bot replies, 0-1
+
+	// ✅ NEW: Unsupported claims removed by strict grounding
+	StrippedClaims int `bson:"stripped_claims" json:"stripped_claims"`
+}
+
+// TopicQuality summarises the feedback on one topic's answers
+type TopicQuality struct {
+	TotalFeedback       int     `bson:"total_feedback" json:"total_feedback"`
+	PositiveFeedback    int     `bson:"positive_feedback" json:"positive_feedback"`
+	NegativeFeedback    int     `bson:"negative_feedback" json:"negative_feedback"`
+	SatisfactionRate    float64 `bson:"satisfaction_rate" json:"satisfaction_rate"` // 0-1
+	AverageQualityScore float64 `bson:"average_quality_score" json:"average_quality_score"`
+}
+
+// TopicQualityPoint is one day of the per-topic quality trend
+type TopicQualityPoint struct {
+	Date   string                  `bson:"date" json:"date"` // YYYY-MM-DD
+	Topics map[string]TopicQuality `bson:"topics" json:"topics"`
 }
 
 // ✅ ADDED: Feedback insights model for storing analyzed feedback patterns
@@ -199,5 +375,7 @@ type FeedbackExample struct {
 	UserMessage string    `bson:"user_message" json:"user_message"`
 	AIResponse  string    `bson:"ai_response" json:"ai_response"`
 	Comment     string    `bson:"comment,omitempty" json:"comment,omitempty"`
+	DominantSource string `bson:"dominant_source,omitempty" json:"dominant_source,omitempty"` // "none" means no document covered the question
+	HighlightedText string `bson:"highlighted_text,omitempty" json:"highlighted_text,omitempty"` // Statement in the answer the user flagged
 	Timestamp   time.Time `bson:"timestamp" json:"timestamp"`
 }
\ No newline at end of file
@@ -20,9 +20,23 @@ type Client struct {
 	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 
+	// ✅ NEW: HMAC secret for signed conversation context on /public/chat (never serialized)
+	ContextSigningSecret string `bson:"context_signing_secret,omitempty" json:"-"`
+
+	// ✅ NEW: Embed secret replaced by the last rotation, still accepted until its grace period ends (never serialized)
+	PreviousEmbedSecret          string     `bson:"previous_embed_secret,omitempty" json:"-"`
+	PreviousEmbedSecretExpiresAt *time.Time `bson:"previous_embed_secret_expires_at,omitempty" json:"-"`
+	EmbedSecretRotatedAt         *time.Time `bson:"embed_secret_rotated_at,omitempty" json:"embed_secret_rotated_at,omitempty"`
+
 	// Migration flag
 	MigratedToTenantDB bool `bson:"migrated_to_tenant_db,omitempty" json:"migrated_to_tenant_db,omitempty"`
 
+	// ✅ NEW: Tenant database region (e.g. "eu"); the tenant database lives on that region's cluster (empty = default cluster).
+	// This is not full data residency: messages, PDFs, chunks and feedback are in shared collections.
+	// Only the tenant database is regional; records in the shared collections stay on the default cluster.
+	// Set when the client is created; moving a tenant between regions is a data migration.
+	DataRegion string `bson:"data_region,omitempty" json:"data_region,omitempty"`
+
 	// Token Alert Fields
 	AlertLevelSent   string    `bson:"alert_level_sent,omitempty" json:"alert_level_sent,omitempty"` // "none"|"warn"|"critical"|"exhausted"
 	AlertLastSentAt  time.Time `bson:"alert_last_sent_at,omitempty" json:"alert_last_sent_at,omitempty"`
@@ -34,6 +48,8 @@ type Client struct {
 	DomainBlacklist   []string `bson:"domain_blacklist,omitempty" json:"domain_blacklist,omitempty"`       // Blocked domains
 	DomainMode        string   `bson:"domain_mode,omitempty" json:"domain_mode,omitempty"`                 // "whitelist" or "blacklist"
 	RequireDomainAuth bool     `bson:"require_domain_auth,omitempty" json:"require_domain_auth,omitempty"` // Whether to enforce domain restrictions
+	// ✅ NEW: Require a server-signed embed token listing the requesting domain (see middleware/embed_token.go)
+	RequireSignedEmbed bool `bson:"require_signed_embed,omitempty" json:"require_signed_embed,omitempty"`
 
 	// AI Persona fields
 	AIPersona *AIPersonaData `bson:"ai_persona,omitempty" json:"ai_persona,omitempty"` // PDF/DOC file info for AI persona
@@ -41,6 +57,9 @@ type Client struct {
 	// Calendly integration fields
 	CalendlyURL     string `bson:"calendly_url,omitempty" json:"calendly_url,omitempty"`         // Calendly scheduling page URL
 	CalendlyEnabled bool   `bson:"calendly_enabled,omitempty" json:"calendly_enabled,omitempty"` // Whether Calendly is enabled
+	// ✅ NEW: Optional Calendly API access for single-use booking links
+	CalendlyAPIToken     string `bson:"calendly_api_token,omitempty" json:"-"`                                      // Personal access token, encrypted at rest (never returned)
+	CalendlyEventTypeURI string `bson:"calendly_event_type_uri,omitempty" json:"calendly_event_type_uri,omitempty"` // Event type used for demos
 
 	// QR Code integration fields
 	QRCodeImageURL string `bson:"qr_code_image_url,omitempty" json:"qr_code_image_url,omitempty"` // QR code image URL for "Connect on Call"
@@ -49,11 +68,18 @@ type Client struct {
 	// WhatsApp QR Code integration fields
 	WhatsAppQRCodeImageURL string `bson:"whatsapp_qr_code_image_url,omitempty" json:"whatsapp_qr_code_image_url,omitempty"` // WhatsApp QR code image URL for "Chat on WhatsApp"
 	WhatsAppQRCodeEnabled  bool   `bson:"whatsapp_qr_code_enabled,omitempty" json:"whatsapp_qr_code_enabled,omitempty"`     // Whether WhatsApp QR code feature is enabled
+	WhatsAppNumber         string `bson:"whatsapp_number,omitempty" json:"whatsapp_number,omitempty"`                       // ✅ NEW: Business number for wa.me deep links (international format)
 
 	// Telegram QR Code integration fields
 	TelegramQRCodeImageURL string `bson:"telegram_qr_code_image_url,omitempty" json:"telegram_qr_code_image_url,omitempty"` // Telegram QR code image URL for "Chat on Telegram"
 	TelegramQRCodeEnabled  bool   `bson:"telegram_qr_code_enabled,omitempty" json:"telegram_qr_code_enabled,omitempty"`     // Whether Telegram QR code feature is enabled
 
+	// ✅ NEW: Telegram bot integration (inbound messages via webhook)
+	TelegramBotEnabled     bool   `bson:"telegram_bot_enabled,omitempty" json:"telegram_bot_enabled,omitempty"`   // Whether the bot answers inbound messages
+	TelegramBotUsername    string `bson:"telegram_bot_username,omitempty" json:"telegram_bot_username,omitempty"` // Bot @username, for display
+	TelegramBotToken       string `bson:"telegram_bot_token,omitempty" json:"-"`                                  // Bot API token, encrypted at rest (never returned)
+	TelegramWebhookSecret  string `bson:"telegram_webhook_secret,omitempty" json:"-"`                             // Expected X-Telegram-Bot-Api-Secret-Token
+
 	// Facebook Posts integration fields
 	FacebookPostsEnabled bool `bson:"facebook_posts_enabled,omitempty" json:"facebook_posts_enabled,omitempty"` // Whether Facebook posts feature is enabled
 
@@ -66,6 +92,185 @@ type Client struct {
 
 	// Client Permissions - Controls what client can see and access
 	Permissions ClientPermissions `bson:"permissions,omitempty" json:"permissions,omitempty"`
+
+	// ✅ NEW: Document storage quota (0 = use platform default)
+	MaxDocuments     int   `bson:"max_documents,omitempty" json:"max_documents,omitempty"`           // Maximum number of PDFs
+	MaxTotalBytes    int64 `bson:"max_total_bytes,omitempty" json:"max_total_bytes,omitempty"`       // Maximum combined size of all PDFs
+	StorageUsedBytes int64 `bson:"storage_used_bytes,omitempty" json:"storage_used_bytes,omitempty"` // Current combined size of all PDFs
+
+	// ✅ NEW: Image library limit (0 = use platform default)
+	MaxImages int `bson:"max_images,omitempty" json:"max_images,omitempty"`
+
+	// ✅ NEW: Scheduled analytics digest email (opt-in)
+	AnalyticsDigest *AnalyticsDigestSettings `bson:"analytics_digest,omitempty" json:"analytics_digest,omitempty"`
+
+	// ✅ NEW: Crawled pages older than this many days are left out of the context (0 = platform default, -1 = keep all)
+	CrawlMaxAgeDays int `bson:"crawl_max_age_days,omitempty" json:"crawl_max_age_days,omitempty"`
+
+	// ✅ NEW: Message retention policy (nil = keep messages forever)
+	MessageRetention *MessageRetentionPolicy `bson:"message_retention,omitempty" json:"message_retention,omitempty"`
+
+	// ✅ NEW: Push finished conversations to the client's CRM (nil = off)
+	CRMSync *CRMSyncSettings `bson:"crm_sync,omitempty" json:"crm_sync,omitempty"`
+
+	// ✅ NEW: Custom system prompt template with {{placeholder}} insertion points (empty = deployment default)
+	PromptTemplate string `bson:"prompt_template,omitempty" json:"prompt_template,omitempty"`
+
+	// ✅ NEW: Follow-up question suggested after each conversation topic (e.g. "pricing", "demo")
+	FollowUpSuggestions map[string]string `bson:"follow_up_suggestions,omitempty" json:"follow_up_suggestions,omitempty"`
+
+	// ✅ NEW: Maximum tokens a single conversation may consume (0 = platform default)
+	SessionTokenCap int `bson:"session_token_cap,omitempty" json:"session_token_cap,omitempty"`
+
+	// ✅ NEW: Visitor messages a single conversation may have before it is wrapped up and closed (0 = platform default)
+	MaxConversationTurns int `bson:"max_conversation_turns,omitempty" json:"max_conversation_turns,omitempty"`
+
+	// ✅ NEW: Seconds a single AI generation may take, e.g. for large-context plans (0 = platform default)
+	AITimeoutSeconds int `bson:"ai_timeout_seconds,omitempty" json:"ai_timeout_seconds,omitempty"`
+
+	// ✅ NEW: Knowledge chunks (PDF + crawl) placed in each reply's prompt, e.g. more for large knowledge bases (0 = platform default)
+	ContextChunks int `bson:"context_chunks,omitempty" json:"context_chunks,omitempty"`
+
+	// ✅ NEW: Maximum public chat messages answered per UTC day, regardless of tokens (0 = unlimited)
+	DailyMessageLimit int `bson:"daily_message_limit,omitempty" json:"daily_message_limit,omitempty"`
+
+	// ✅ NEW: Store visitor IPs truncated (last IPv4 octet / last 80 IPv6 bits zeroed) and only coarse
+	// geolocation (country, region, timezone). Remembered visitor names are then keyed by the truncated IP,
+	// so visitors sharing a network may be greeted with each other's name less reliably or mistakenly.
+	AnonymizeIPs bool `bson:"anonymize_ips,omitempty" json:"anonymize_ips,omitempty"`
+
+	// ✅ NEW: Keep a redacted snapshot of each reply's prompt that the client can inspect (admin-controlled)
+	PromptInspectionEnabled bool `bson:"prompt_inspection_enabled,omitempty" json:"prompt_inspection_enabled,omitempty"`
+
+	// ✅ NEW: Replace reply sentences the retrieved context doesn't support with "I don't have that information"
+	StrictGroundingEnabled bool `bson:"strict_grounding_enabled,omitempty" json:"strict_grounding_enabled,omitempty"`
+
+	// ✅ NEW: Reply sent once contact details are collected and the chat is closed (empty = platform default),
+	// e.g. in the language the client's visitors use
+	ContactCompletionMessage string `bson:"contact_completion_message,omitempty" json:"contact_completion_message,omitempty"`
+
+	// ✅ NEW: Contact intent score (0-1) at which contact collection starts (0 = platform default)
+	ContactIntentThreshold float64 `bson:"contact_intent_threshold,omitempty" json:"contact_intent_threshold,omitempty"`
+
+	// ✅ NEW: Spam score (0-1) at or above which public messages are filtered (0 = platform default)
+	SpamThreshold float64 `bson:"spam_threshold,omitempty" json:"spam_threshold,omitempty"`
+
+	// ✅ NEW: Consecutive unanswered turns before the bot offers contact with the team (0 = platform default, -1 = never)
+	NoAnswerEscalationThreshold int `bson:"no_answer_escalation_threshold,omitempty" json:"no_answer_escalation_threshold,omitempty"`
+
+	// ✅ NEW: Gemini safety threshold per harm category, e.g. {"harassment": "block_only_high"} (unset = block_medium_and_above)
+	SafetySettings map[string]string `bson:"safety_settings,omitempty" json:"safety_settings,omitempty"`
+
+	// ✅ NEW: Mask profanity in bot replies before they reach visitors (stored replies stay unfiltered)
+	ProfanityFilter *ProfanityFilterSettings `bson:"profanity_filter,omitempty" json:"profanity_filter,omitempty"`
+
+	// ✅ NEW: Google Sheet that receives a row of analytics per day
+	GoogleSheets *GoogleSheetsSettings `bson:"google_sheets,omitempty" json:"google_sheets,omitempty"`
+
+	// ✅ NEW: Which source wins when persona, PDFs and crawled pages disagree, e.g. ["pdf", "persona", "crawl"] (unset = persona, pdf, crawl)
+	SourcePriority []string `bson:"source_priority,omitempty" json:"source_priority,omitempty"`
+
+	// ✅ NEW: Chat requests refused because the token balance ran out
+	TokenLimitRejections      int64      `bson:"token_limit_rejections,omitempty" json:"token_limit_rejections,omitempty"`
+	LastTokenLimitRejectionAt *time.Time `bson:"last_token_limit_rejection_at,omitempty" json:"last_token_limit_rejection_at,omitempty"`
+
+	// ✅ NEW: IANA timezone analytics days are bucketed in, e.g. "Asia/Kolkata" (unset = UTC)
+	AnalyticsTimezone string `bson:"analytics_timezone,omitempty" json:"analytics_timezone,omitempty"`
+
+	// ✅ NEW: Why and by whom the client was suspended (set while Status is "suspended")
+	Suspension *ClientSuspension `bson:"suspension,omitempty" json:"suspension,omitempty"`
+
+	// ✅ NEW: Chat export settings used when an export request leaves them out
+	ExportDefaults *ExportDefaults `bson:"export_defaults,omitempty" json:"export_defaults,omitempty"`
+}
+
+// ExportDefaults are a client's preferred chat export format, period and optional fields
+type ExportDefaults struct {
+	Format        string   `bson:"format,omitempty" json:"format,omitempty"`                   // json, csv, txt or excel
+	DateRangeDays int      `bson:"date_range_days,omitempty" json:"date_range_days,omitempty"` // last N days when no dates are given (0 = all time)
+	IncludeFields []string `bson:"include_fields,omitempty" json:"include_fields,omitempty"`   // optional field groups: "geo", "meta"
+}
+
+// ClientSuspension records an admin suspension of a client
+type ClientSuspension struct {
+	Reason        string    `bson:"reason" json:"reason"`
+	WidgetMessage string    `bson:"widget_message,omitempty" json:"widget_message,omitempty"` // shown by the embed widget (empty = platform default)
+	SuspendedBy   string    `bson:"suspended_by" json:"suspended_by"`                         // admin user ID
+	SuspendedAt   time.Time `bson:"suspended_at" json:"suspended_at"`
+}
+
+// ProfanityFilterSettings controls masking of profane words in a client's bot replies
+type ProfanityFilterSettings struct {
+	Enabled bool     `bson:"enabled" json:"enabled"`
+	Words   []string `bson:"words,omitempty" json:"words,omitempty"` // extra words or phrases, in any language, on top of the platform list
+}
+
+// GoogleSheetsSettings connects a client to a Google Sheet that gets one row of analytics appended per day.
+// Tokens come from the client's OAuth consent, are stored encrypted and are never returned by the API.
+type GoogleSheetsSettings struct {
+	SpreadsheetID  string    `bson:"spreadsheet_id" json:"spreadsheet_id"`
+	SheetName      string    `bson:"sheet_name" json:"sheet_name"`
+	RefreshToken   string    `bson:"refresh_token" json:"-"`
+	AccessToken    string    `bson:"access_token,omitempty" json:"-"`
+	TokenExpiry    time.Time `bson:"token_expiry,omitempty" json:"-"`
+	ConnectedAt    time.Time `bson:"connected_at" json:"connected_at"`
+	LastSyncedDate string    `bson:"last_synced_date,omitempty" json:"last_synced_date,omitempty"` // last UTC day (YYYY-MM-DD) appended; empty until the backfill runs
+	LastSyncAt     time.Time `bson:"last_sync_at,omitempty" json:"last_sync_at,omitempty"`
+	LastError      string    `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	SyncLockUntil  time.Time `bson:"sync_lock_until,omitempty" json:"-"`
+}
+
+// MessageRetentionPolicy controls how long a client's chat messages are kept
+type MessageRetentionPolicy struct {
+	RetentionDays   int       `bson:"retention_days" json:"retention_days"` // 0 = keep forever
+	Action          string    `bson:"action" json:"action"`                 // "delete" or "archive"
+	ExemptLeads     bool      `bson:"exempt_leads" json:"exempt_leads"`     // Keep conversations where the visitor left contact details or booked a demo
+	LastPurgeAt     time.Time `bson:"last_purge_at,omitempty" json:"last_purge_at,omitempty"`
+	LastPurgedCount int64     `bson:"last_purged_count" json:"last_purged_count"`
+}
+
+// CRMSyncSettings controls pushing conversation transcripts and contact details to a client's CRM endpoint
+type CRMSyncSettings struct {
+	Enabled       bool              `bson:"enabled" json:"enabled"`
+	EndpointURL   string            `bson:"endpoint_url" json:"endpoint_url"`
+	SigningSecret string            `bson:"signing_secret" json:"-"`                                // HMAC key for X-Chatbot-Signature (never returned)
+	Trigger       string            `bson:"trigger" json:"trigger"`                                 // "completion", "schedule" or "both"
+	IdleMinutes   int               `bson:"idle_minutes" json:"idle_minutes"`                       // schedule: push a conversation once it has been quiet this long
+	FieldMapping  map[string]string `bson:"field_mapping,omitempty" json:"field_mapping,omitempty"` // output path -> payload path; empty sends the standard payload
+
+	// ✅ NEW: Static headers (e.g. Authorization) sent with every push; values are encrypted and never returned
+	Headers []WebhookHeader `bson:"headers,omitempty" json:"-"`
+}
+
+// WebhookHeader is a client-defined header attached to an outbound webhook request
+type WebhookHeader struct {
+	Name  string `bson:"name" json:"name"`
+	Value string `bson:"value" json:"-"` // AES-GCM encrypted
+}
+
+// CRMSyncDelivery tracks pushing one conversation to a client's CRM, including retries
+type CRMSyncDelivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID       primitive.ObjectID `bson:"client_id" json:"client_id"`
+	ConversationID string             `bson:"conversation_id" json:"conversation_id"`
+	Event          string             `bson:"event" json:"event"`   // "conversation.completed" or "conversation.idle"
+	Status         string             `bson:"status" json:"status"` // "pending", "delivered" or "failed"
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastMessageAt  time.Time          `bson:"last_message_at" json:"last_message_at"` // latest message included in the push
+	LastStatusCode int                `bson:"last_status_code,omitempty" json:"last_status_code,omitempty"`
+	LastError      string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	DeliveredAt    time.Time          `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// AnalyticsDigestSettings controls the periodic analytics summary email
+type AnalyticsDigestSettings struct {
+	Enabled    bool      `bson:"enabled" json:"enabled"`
+	Frequency  string    `bson:"frequency" json:"frequency"`   // "daily", "weekly" or "monthly"
+	Recipients []string  `bson:"recipients" json:"recipients"` // Defaults to the client's contact email when empty
+	LastSentAt time.Time `bson:"last_sent_at,omitempty" json:"last_sent_at,omitempty"`
 }
 
 // AIPersonaData represents uploaded persona file information
@@ -79,6 +284,43 @@ type AIPersonaData struct {
 	CharacterCount int       `bson:"character_count,omitempty" json:"character_count,omitempty"`
 }
 
+// ClientPersona is one of a client's additional personas. Each conversation is routed to the first persona
+// whose triggers match; conversations that match none use the client's AI persona (or the platform default).
+type ClientPersona struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID  primitive.ObjectID `bson:"client_id" json:"client_id"`
+	Name      string             `bson:"name" json:"name"`
+	Content   string             `bson:"content" json:"content"` // Personality and knowledge, used in place of the client's AI persona
+	Enabled   bool               `bson:"enabled" json:"enabled"`
+	Priority  int                `bson:"priority" json:"priority"` // Higher wins when several personas match the same message
+	Triggers  PersonaTriggers    `bson:"triggers" json:"triggers"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// PersonaTriggers decide when a conversation is handed to a persona
+type PersonaTriggers struct {
+	Topics         []string `bson:"topics,omitempty" json:"topics,omitempty"`                     // Detected topics ("pricing", "demo", ...) or keywords in the visitor's message
+	PreQuestionIDs []string `bson:"pre_question_ids,omitempty" json:"pre_question_ids,omitempty"` // Pre-questions whose click starts this persona
+	Selectable     bool     `bson:"selectable" json:"selectable"`                                 // Offered in the widget's persona selector
+}
+
+// WelcomeMessageVariant is one welcome message under test; Weight is its relative share of sessions
+type WelcomeMessageVariant struct {
+	ID     string `bson:"id" json:"id"`
+	Text   string `bson:"text" json:"text"`
+	Weight int    `bson:"weight" json:"weight"`
+}
+
+// PreQuestionConfig is a clickable pre-question. Action is "ai" (answered by the AI), "answer"
+// (replies with Answer without an AI call) or "contact" (starts contact collection, Answer optional)
+type PreQuestionConfig struct {
+	ID     string `bson:"id" json:"id"`
+	Text   string `bson:"text" json:"text"`
+	Action string `bson:"action" json:"action"`
+	Answer string `bson:"answer,omitempty" json:"answer,omitempty"`
+}
+
 type Branding struct {
 	LogoURL        string   `bson:"logo_url" json:"logo_url"`
 	ThemeColor     string   `bson:"theme_color" json:"theme_color"`
@@ -89,6 +331,12 @@ type Branding struct {
 	WidgetPosition string   `bson:"widget_position,omitempty" json:"widget_position,omitempty"`
 	EmbedMode      string   `bson:"embed_mode,omitempty" json:"embed_mode,omitempty"` // "widget" or "fullscreen"
 
+	// ✅ NEW: Weighted welcome message variants for A/B testing (WelcomeMessage is used when empty)
+	WelcomeVariants []WelcomeMessageVariant `bson:"welcome_variants,omitempty" json:"welcome_variants,omitempty"`
+
+	// ✅ NEW: Pre-questions with a canned answer or routing target (supersedes PreQuestions when set)
+	PreQuestionConfigs []PreQuestionConfig `bson:"pre_question_configs,omitempty" json:"pre_question_configs,omitempty"`
+
 	// Launcher configuration
 	LauncherColor     string `bson:"launcher_color,omitempty" json:"launcher_color,omitempty"`
 	LauncherText      string `bson:"launcher_text,omitempty" json:"launcher_text,omitempty"`
@@ -108,6 +356,23 @@ type Branding struct {
 	ShowWelcomeAvatar bool   `bson:"show_welcome_avatar,omitempty" json:"show_welcome_avatar,omitempty"`
 	ShowChatAvatar    bool   `bson:"show_chat_avatar,omitempty" json:"show_chat_avatar,omitempty"`
 	ShowTypingAvatar  bool   `bson:"show_typing_avatar,omitempty" json:"show_typing_avatar,omitempty"`
+
+	// ✅ NEW: Offer quick-reply buttons with suggested next questions after each reply
+	ShowQuickReplies bool `bson:"show_quick_replies,omitempty" json:"show_quick_replies,omitempty"`
+
+	// ✅ NEW: Only record visitor IP, geolocation and user agent when the widget reports tracking consent
+	RequireTrackingConsent bool `bson:"require_tracking_consent,omitempty" json:"require_tracking_consent,omitempty"`
+
+	// ✅ NEW: Answer pure greetings ("hi", "namaste") with the welcome message instead of calling the AI
+	InstantGreetingReply bool `bson:"instant_greeting_reply,omitempty" json:"instant_greeting_reply,omitempty"`
+	// ✅ NEW: Extra greetings recognised for this client, in addition to the platform list
+	GreetingPhrases []string `bson:"greeting_phrases,omitempty" json:"greeting_phrases,omitempty"`
+
+	// ✅ NEW: Welcome returning visitors back with a recap of what was discussed last time
+	WarmReconnect bool `bson:"warm_reconnect,omitempty" json:"warm_reconnect,omitempty"`
+
+	// ✅ NEW: Company name the bot represents in replies, when it differs from the account name
+	DisplayCompanyName string `bson:"display_company_name,omitempty" json:"display_company_name,omitempty"`
 }
 
 type CreateClientRequest struct {
@@ -117,6 +382,7 @@ type CreateClientRequest struct {
 	Status       string   `json:"status,omitempty"`
 	ContactEmail string   `json:"contact_email,omitempty"`
 	ContactPhone string   `json:"contact_phone,omitempty"`
+	DataRegion   string   `json:"data_region,omitempty"` // ✅ NEW: must be a region configured in MONGO_REGION_URIS; only the tenant database moves there
 
 	// Optional: create the first login user for this client
 	InitialUser *InitialUser `json:"initial_user,omitempty"`
@@ -147,6 +413,8 @@ type DomainManagementRequest struct {
 	DomainBlacklist   []string `json:"domain_blacklist,omitempty"`
 	DomainMode        string   `json:"domain_mode,omitempty" binding:"omitempty,oneof=whitelist blacklist"`
 	RequireDomainAuth *bool    `json:"require_domain_auth,omitempty"`
+	// ✅ NEW
+	RequireSignedEmbed *bool `json:"require_signed_embed,omitempty"`
 }
 
 type DomainManagementResponse struct {
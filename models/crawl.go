@@ -30,6 +30,20 @@ type CrawlJob struct {
 	IncludeImages  bool     `bson:"include_images" json:"include_images"`
 	RespectRobots  bool     `bson:"respect_robots" json:"respect_robots"`
 
+	// Effective crawl limits (request values after defaults and platform maximums)
+	MaxDepth       int  `bson:"max_depth,omitempty" json:"max_depth,omitempty"`
+	SameDomainOnly bool `bson:"same_domain_only" json:"same_domain_only"`
+	Concurrency    int  `bson:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// Sitemap seeding: pages listed in the sitemap are crawled directly
+	SitemapURL     string `bson:"sitemap_url,omitempty" json:"sitemap_url,omitempty"`
+	SitemapURLs    int    `bson:"sitemap_urls,omitempty" json:"sitemap_urls,omitempty"`       // pages queued from the sitemap
+	SitemapSkipped int    `bson:"sitemap_skipped,omitempty" json:"sitemap_skipped,omitempty"` // entries off-site, duplicate or over the cap
+
+	// robots.txt: URLs left out because the site disallows them
+	RobotsSkipped int          `bson:"robots_skipped,omitempty" json:"robots_skipped,omitempty"`
+	SkippedURLs   []SkippedURL `bson:"skipped_urls,omitempty" json:"skipped_urls,omitempty"`
+
 	// Extracted data
 	CrawledPages  []CrawledPage  `bson:"crawled_pages,omitempty" json:"crawled_pages,omitempty"`
 	Products      []Product      `bson:"products,omitempty" json:"products,omitempty"`
@@ -56,6 +70,12 @@ type CrawledPage struct {
 	WordCount  int       `bson:"word_count,omitempty" json:"word_count,omitempty"`
 }
 
+// SkippedURL is a URL the crawler didn't fetch and why
+type SkippedURL struct {
+	URL    string `bson:"url" json:"url"`
+	Reason string `bson:"reason" json:"reason"`
+}
+
 // Product represents extracted product data from eCommerce sites
 type Product struct {
 	Name        string                 `bson:"name" json:"name"`
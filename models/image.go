@@ -12,7 +12,14 @@ type Image struct {
 	ClientID  primitive.ObjectID `bson:"client_id" json:"client_id"`
 	URL       string             `bson:"url" json:"url" binding:"required"`
 	Title     string             `bson:"title" json:"title" binding:"required"`
+	Caption   string             `bson:"caption,omitempty" json:"caption,omitempty"` // ✅ NEW: shown under the image and used for matching
+	Tags      []string           `bson:"tags,omitempty" json:"tags,omitempty"`       // ✅ NEW: lowercase keywords matched against chat messages
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// ✅ NEW: Set for uploaded images after optimization
+	ThumbnailURL string `bson:"thumbnail_url,omitempty" json:"thumbnail_url,omitempty"`
+	Width        int    `bson:"width,omitempty" json:"width,omitempty"`
+	Height       int    `bson:"height,omitempty" json:"height,omitempty"`
 }
 
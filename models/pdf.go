@@ -28,6 +28,8 @@ type PDF struct {
 	Metadata           PDFMetadata        `bson:"metadata" json:"metadata"`
 	Cached             bool               `bson:"cached,omitempty" json:"cached,omitempty"`
 	CachedAt           *time.Time         `bson:"cached_at,omitempty" json:"cached_at,omitempty"`
+	// ✅ NEW: false excludes the document from answers; nil (uploaded before the toggle) counts as active
+	Active *bool `bson:"active,omitempty" json:"active,omitempty"`
 }
 
 // ContentChunk represents a text chunk from the PDF
@@ -75,6 +77,8 @@ type UploadResponse struct {
 	Metadata   PDFMetadata `json:"metadata"`
 	Message    string      `json:"message"`
 	TaskID     string      `json:"task_id,omitempty"` // For async processing
+	// ✅ NEW: Set when an identical file was already uploaded; ID is then the existing document
+	Duplicate bool `json:"duplicate,omitempty"`
 }
 
 // ChunkingConfig defines how text should be chunked
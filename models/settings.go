@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type EmbedSettings struct {
 	AllowEmbedding bool     `json:"allow_embedding"`
 	AllowedDomains []string `json:"allowed_domains"`
@@ -18,6 +20,21 @@ type TokenUsage struct {
 	Limit     int     `json:"limit"`
 	Remaining int     `json:"remaining"`
 	Usage     float64 `json:"usage_percentage"`
+
+	// ✅ NEW: Alerting and depletion forecast for the dashboard
+	AlertLevel           string         `json:"alert_level"` // "none"|"warn"|"critical"|"exhausted"
+	LimitRejections      int64          `json:"limit_rejections"`
+	LastLimitRejectionAt *time.Time     `json:"last_limit_rejection_at,omitempty"`
+	Forecast             *TokenForecast `json:"forecast,omitempty"`
+}
+
+// TokenForecast projects when a client's remaining tokens run out at its recent burn rate
+type TokenForecast struct {
+	WindowDays             int        `json:"window_days"`
+	TokensUsedInWindow     int        `json:"tokens_used_in_window"`
+	AvgDailyTokens         float64    `json:"avg_daily_tokens"`
+	DaysToDepletion        *float64   `json:"days_to_depletion"` // nil when no tokens were used in the window
+	ProjectedDepletionDate *time.Time `json:"projected_depletion_date,omitempty"`
 }
 
 type SystemHealth struct {
@@ -68,6 +68,8 @@ type PDFDocument struct {
 	Progress  int       `bson:"progress" json:"progress"`
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	// ✅ NEW: SHA-256 of the uploaded bytes, used to detect re-uploads of the same file
+	FileHash string `bson:"file_hash,omitempty" json:"file_hash,omitempty"`
 }
 
 type PasswordReset struct {
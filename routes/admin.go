@@ -3,12 +3,15 @@ package routes
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"saas-chatbot-platform/internal/ai"
 	"saas-chatbot-platform/internal/auth"
 	"saas-chatbot-platform/internal/config"
 	"saas-chatbot-platform/internal/crawler"
@@ -16,6 +19,7 @@ import (
 	"saas-chatbot-platform/models"
 	"saas-chatbot-platform/services"
 	"saas-chatbot-platform/utils"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -35,6 +39,7 @@ func SetupAdminRoutes(
 	mongoClient *mongo.Client,
 	authMiddleware *middleware.AuthMiddleware,
 	roleMiddleware *middleware.RoleMiddleware,
+	auditLogger *models.AuditLogger,
 ) {
 	admin := router.Group("/admin")
 	admin.Use(authMiddleware.RequireAuth())
@@ -50,6 +55,7 @@ func SetupAdminRoutes(
 	facebookPostsCollection := db.Collection("facebook_posts")
 	instagramPostsCollection := db.Collection("instagram_posts")
 	alertsCollection := db.Collection("suspicious_activity_alerts")
+	pdfService := services.NewPDFService(cfg, clientsCollection, pdfsCollection) // ✅ NEW: tracks client storage quota on upload/delete
 
 	// Check if email exists endpoint
 	admin.GET("/check-email", func(c *gin.Context) {
@@ -224,7 +230,7 @@ func SetupAdminRoutes(
 
 		// ✅ Fixed field names: clientid → client_id
 		// 1. Delete all PDFs for this client
-		_, err = pdfsCollection.DeleteMany(context.Background(), bson.M{"client_id": clientID})
+		_, err = pdfService.DeletePDFs(context.Background(), clientID, bson.M{})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "internal_error",
@@ -264,8 +270,19 @@ func SetupAdminRoutes(
 			return
 		}
 
-		// 5. Finally, delete the client itself
+		// ✅ NEW: 5. Delete the client's personas
+		_, err = personasCollection(db).DeleteMany(context.Background(), bson.M{"client_id": clientID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to delete client personas",
+			})
+			return
+		}
+
+		// 6. Finally, delete the client itself
 		result, err := clientsCollection.DeleteOne(context.Background(), bson.M{"_id": clientID})
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "internal_error",
@@ -442,6 +459,7 @@ func SetupAdminRoutes(
 		}
 
 		result, err := clientsCollection.UpdateOne(context.Background(), bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "internal_error",
@@ -475,6 +493,112 @@ func SetupAdminRoutes(
 		})
 	})
 
+	// -------------------------
+	// ✅ NEW: Gemini Safety Settings
+	// -------------------------
+	// Get client safety thresholds (unset categories use block_medium_and_above)
+	admin.GET("/client/:id/safety-settings", func(c *gin.Context) {
+		clientID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var client models.Client
+		if err := clientsCollection.FindOne(context.Background(), bson.M{"_id": clientID}).Decode(&client); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "client_not_found",
+					"message":    "Client not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to retrieve client",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"client_id":       clientID.Hex(),
+			"safety_settings": effectiveSafetySettings(client.SafetySettings),
+		})
+	})
+
+	// Update client safety thresholds (only the categories sent are changed)
+	admin.PATCH("/client/:id/safety-settings", func(c *gin.Context) {
+		clientID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var req struct {
+			SafetySettings map[string]string `json:"safety_settings" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_input",
+				"message":    "Invalid request data",
+				"details":    gin.H{"error": err.Error()},
+			})
+			return
+		}
+
+		if err := validateSafetySettings(req.SafetySettings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_safety_settings",
+				"message":    err.Error(),
+			})
+			return
+		}
+
+		set := bson.M{"updated_at": time.Now()}
+		for category, threshold := range req.SafetySettings {
+			set["safety_settings."+category] = threshold
+		}
+
+		result, err := clientsCollection.UpdateOne(context.Background(), bson.M{"_id": clientID}, bson.M{"$set": set})
+		invalidateClientConfig(clientID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to update safety settings",
+			})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		var updatedClient models.Client
+		if err := clientsCollection.FindOne(context.Background(), bson.M{"_id": clientID}).Decode(&updatedClient); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to retrieve updated client",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":         "Safety settings updated successfully",
+			"client_id":       clientID.Hex(),
+			"safety_settings": effectiveSafetySettings(updatedClient.SafetySettings),
+		})
+	})
+
 	// -------------------------
 	// Calendly Configuration
 	// -------------------------
@@ -570,6 +694,7 @@ func SetupAdminRoutes(
 		}
 
 		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "update_failed",
@@ -707,6 +832,7 @@ func SetupAdminRoutes(
 		}
 
 		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "update_failed",
@@ -843,6 +969,7 @@ func SetupAdminRoutes(
 		}
 
 		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "update_failed",
@@ -979,6 +1106,7 @@ func SetupAdminRoutes(
 		}
 
 		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "update_failed",
@@ -1529,10 +1657,13 @@ func SetupAdminRoutes(
 				"token_limit": req.NewTokenLimit,
 				"token_used":  0, // Reset used tokens
 				"updated_at":  time.Now(),
+				// ✅ NEW: Re-arm usage alerts for the new balance
+				"alert_level_sent": "none",
 			},
 		}
 
 		result, err := clientsCollection.UpdateOne(context.Background(), bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error_code": "internal_error", "message": "Failed to update client tokens"})
 			return
@@ -1555,6 +1686,10 @@ func SetupAdminRoutes(
 		})
 	})
 
+	// ✅ NEW: Suspend or reactivate a client, with an audit entry and optional email
+	admin.POST("/client/:id/suspend", handleSetClientSuspension(cfg, clientsCollection, auditLogger, true))
+	admin.POST("/client/:id/reactivate", handleSetClientSuspension(cfg, clientsCollection, auditLogger, false))
+
 	// -------------------------
 	// Create new client tenant
 	// -------------------------
@@ -1580,6 +1715,28 @@ func SetupAdminRoutes(
 			status = "active"
 		}
 
+		// ✅ NEW: Tenant database region must have a configured cluster
+		dataRegion := strings.ToLower(strings.TrimSpace(req.DataRegion))
+		if _, ok := cfg.MongoRegionURIs[dataRegion]; dataRegion != "" && !ok {
+			regions := make([]string, 0, len(cfg.MongoRegionURIs))
+			for region := range cfg.MongoRegionURIs {
+				regions = append(regions, region)
+			}
+			sort.Strings(regions)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code":        "invalid_data_region",
+				"message":           "No cluster is configured for data region " + dataRegion,
+				"available_regions": regions,
+			})
+			return
+		}
+
+		// ✅ NEW: Reject malformed branding colors and asset URLs
+		if fieldErrors := normalizeBranding(&req.Branding); fieldErrors != nil {
+			respondToInvalidBranding(c, fieldErrors)
+			return
+		}
+
 		client := models.Client{
 			Name:         req.Name,
 			Branding:     req.Branding,
@@ -1589,6 +1746,7 @@ func SetupAdminRoutes(
 			Status:       status,
 			ContactEmail: req.ContactEmail,
 			ContactPhone: req.ContactPhone,
+			DataRegion:   dataRegion,
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
 		}
@@ -1666,8 +1824,14 @@ func SetupAdminRoutes(
 		type Resp struct {
 			models.Client `json:"client"`
 			InitialUser   *models.UserInfo `json:"initial_user,omitempty"`
+			// ✅ NEW: Spell out that data_region is not full data residency
+			DataRegionScope string `json:"data_region_scope,omitempty"`
+		}
+		resp := Resp{Client: client, InitialUser: createdUser}
+		if client.DataRegion != "" {
+			resp.DataRegionScope = "Only the tenant database is stored in " + client.DataRegion + "; chats, documents and other shared records stay on the default cluster"
 		}
-		c.JSON(http.StatusCreated, Resp{Client: client, InitialUser: createdUser})
+		c.JSON(http.StatusCreated, resp)
 	})
 
 	// -------------------------
@@ -1730,6 +1894,9 @@ func SetupAdminRoutes(
 		})
 	})
 
+	// ✅ NEW: Cross-client overview for spotting at-risk or abusive clients
+	admin.GET("/clients/overview", handleAdminClientOverview(cfg, db, auditLogger))
+
 	// -------------------------
 	// Usage analytics
 	// -------------------------
@@ -2113,6 +2280,10 @@ func SetupAdminRoutes(
 				"messages":          totalMessages,
 				"messages_last_24h": msgLast24h,
 				"total_tokens_used": totalTokens,
+				// ✅ NEW: clients reads saved by the config cache on this instance
+				"client_config_cache": sharedClientConfigCache.stats(),
+				// ✅ NEW: load on this instance's Gemini document extraction throttle
+				"gemini_extraction": ai.SharedExtractionThrottle(cfg.GeminiExtractionConcurrency, cfg.GeminiExtractionPerMinute).Stats(),
 			},
 		}
 		c.JSON(http.StatusOK, health)
@@ -2307,12 +2478,92 @@ func SetupAdminRoutes(
 		if tokenLimit, ok := updateData["token_limit"]; ok && tokenLimit != nil {
 			update["$set"].(bson.M)["token_limit"] = tokenLimit
 		}
+		// ✅ NEW: Per-client document quota (0 resets to the platform default)
+		if maxDocuments, ok := updateData["max_documents"].(float64); ok && maxDocuments >= 0 {
+			update["$set"].(bson.M)["max_documents"] = int(maxDocuments)
+		}
+		if maxTotalBytes, ok := updateData["max_total_bytes"].(float64); ok && maxTotalBytes >= 0 {
+			update["$set"].(bson.M)["max_total_bytes"] = int64(maxTotalBytes)
+		}
+		// ✅ NEW: Per-session token cap (0 resets to the platform default)
+		if sessionTokenCap, ok := updateData["session_token_cap"].(float64); ok && sessionTokenCap >= 0 {
+			update["$set"].(bson.M)["session_token_cap"] = int(sessionTokenCap)
+		}
+		// ✅ NEW: Maximum conversation length in visitor messages (0 resets to the platform default)
+		if maxTurns, ok := updateData["max_conversation_turns"].(float64); ok && maxTurns >= 0 {
+			update["$set"].(bson.M)["max_conversation_turns"] = int(maxTurns)
+		}
+		// ✅ NEW: Contact collection completion message (empty resets to the platform default)
+		if completionMessage, ok := updateData["contact_completion_message"].(string); ok && len(completionMessage) <= maxContactCompletionMessageLength {
+			update["$set"].(bson.M)["contact_completion_message"] = strings.TrimSpace(completionMessage)
+		}
+		// ✅ NEW: Contact intent threshold (0 resets to the platform default)
+		if threshold, ok := updateData["contact_intent_threshold"].(float64); ok && threshold >= 0 && threshold <= 1 {
+			update["$set"].(bson.M)["contact_intent_threshold"] = threshold
+		}
+		// ✅ NEW: AI generation timeout in seconds (0 resets to the platform default)
+		if aiTimeout, ok := updateData["ai_timeout_seconds"].(float64); ok {
+			if limit := maxClientAITimeoutSeconds(cfg); aiTimeout < 0 || aiTimeout > float64(limit) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_ai_timeout",
+					"message":    fmt.Sprintf("ai_timeout_seconds must be between 0 and %d (CHAT_REQUEST_TIMEOUT)", limit),
+				})
+				return
+			}
+			update["$set"].(bson.M)["ai_timeout_seconds"] = int(aiTimeout)
+		}
+		// ✅ NEW: Context chunks per reply (0 resets to the platform default)
+		if contextChunks, ok := updateData["context_chunks"].(float64); ok && contextChunks >= 0 && int(contextChunks) <= maxContextChunks(cfg) {
+			update["$set"].(bson.M)["context_chunks"] = int(contextChunks)
+		}
+		// ✅ NEW: Daily public chat message limit (0 = unlimited)
+		if dailyMessageLimit, ok := updateData["daily_message_limit"].(float64); ok && dailyMessageLimit >= 0 {
+			update["$set"].(bson.M)["daily_message_limit"] = int(dailyMessageLimit)
+		}
+		// ✅ NEW: Visitor IP anonymization
+		// ✅ NEW: Let the client inspect the redacted prompt behind each reply
+		if promptInspection, ok := updateData["prompt_inspection_enabled"].(bool); ok {
+			update["$set"].(bson.M)["prompt_inspection_enabled"] = promptInspection
+		}
+		// ✅ NEW: Strict grounding for clients that need a hard guarantee against invented facts
+		if strictGrounding, ok := updateData["strict_grounding_enabled"].(bool); ok {
+			update["$set"].(bson.M)["strict_grounding_enabled"] = strictGrounding
+		}
+		if anonymizeIPs, ok := updateData["anonymize_ips"].(bool); ok {
+			update["$set"].(bson.M)["anonymize_ips"] = anonymizeIPs
+		}
+		// ✅ NEW: No-answer escalation threshold (0 = platform default, -1 = never)
+		if threshold, ok := updateData["no_answer_escalation_threshold"].(float64); ok && threshold >= -1 && threshold <= maxNoAnswerEscalationThreshold {
+			update["$set"].(bson.M)["no_answer_escalation_threshold"] = int(threshold)
+		}
+		// ✅ NEW: Crawled content age limit (0 = platform default, -1 = keep all)
+		if maxAge, ok := updateData["crawl_max_age_days"].(float64); ok && maxAge >= -1 && maxAge <= maxCrawlMaxAgeDays {
+			update["$set"].(bson.M)["crawl_max_age_days"] = int(maxAge)
+		}
 		if branding, ok := updateData["branding"]; ok && branding != nil {
-			update["$set"].(bson.M)["branding"] = branding
+			// ✅ NEW: Decode into the typed branding so colors and asset URLs are validated
+			var typedBranding models.Branding
+			raw, err := json.Marshal(branding)
+			if err == nil {
+				err = json.Unmarshal(raw, &typedBranding)
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_input",
+					"message":    "Invalid branding data",
+				})
+				return
+			}
+			if fieldErrors := normalizeBranding(&typedBranding); fieldErrors != nil {
+				respondToInvalidBranding(c, fieldErrors)
+				return
+			}
+			update["$set"].(bson.M)["branding"] = typedBranding
 		}
 
 		// Update client
 		_, err = clientsCollection.UpdateOne(context.Background(), bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "database_error",
@@ -2381,6 +2632,8 @@ func SetupAdminRoutes(
 			DomainBlacklist   []string           `bson:"domain_blacklist" json:"domain_blacklist"`
 			DomainMode        string             `bson:"domain_mode" json:"domain_mode"`
 			RequireDomainAuth bool               `bson:"require_domain_auth" json:"require_domain_auth"`
+			// ✅ NEW
+			RequireSignedEmbed bool `bson:"require_signed_embed" json:"require_signed_embed"`
 		}
 
 		err = clientsCollection.FindOne(context.Background(), bson.M{"_id": clientID}).Decode(&client)
@@ -2409,6 +2662,8 @@ func SetupAdminRoutes(
 			"domain_blacklist":    client.DomainBlacklist,
 			"domain_mode":         client.DomainMode,
 			"require_domain_auth": client.RequireDomainAuth,
+			// ✅ NEW
+			"require_signed_embed": client.RequireSignedEmbed,
 		})
 	})
 
@@ -2452,6 +2707,18 @@ func SetupAdminRoutes(
 			return
 		}
 
+		// ✅ NEW: Entries are host names, optionally with a leading wildcard ("*.example.com")
+		for _, domain := range append(append([]string{}, req.DomainWhitelist...), req.DomainBlacklist...) {
+			if !middleware.ValidDomainPattern(domain) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_domain",
+					"message":    "Domains must be host names such as example.com or *.example.com",
+					"details":    gin.H{"domain": domain},
+				})
+				return
+			}
+		}
+
 		// Build update document
 		update := bson.M{
 			"$set": bson.M{
@@ -2471,9 +2738,13 @@ func SetupAdminRoutes(
 		if req.RequireDomainAuth != nil {
 			update["$set"].(bson.M)["require_domain_auth"] = *req.RequireDomainAuth
 		}
+		if req.RequireSignedEmbed != nil {
+			update["$set"].(bson.M)["require_signed_embed"] = *req.RequireSignedEmbed
+		}
 
 		// Update client
 		result, err := clientsCollection.UpdateOne(context.Background(), bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "internal_error",
@@ -2746,9 +3017,14 @@ func SetupAdminRoutes(
 			fileContent, err := os.ReadFile(tempFile)
 			if err != nil {
 				extractedContent = fmt.Sprintf("Failed to read file: %v", err)
+			} else if release, err := services.AcquireGeminiExtraction(ctx, cfg); err != nil {
+				// ✅ NEW: Extraction calls share a throttle so uploads can't starve chat of Gemini quota
+				extractedContent = fmt.Sprintf("Document extraction is busy, please try again: %v", err)
 			} else {
-				// Initialize Gemini client
-				geminiClient, err := genai.NewClient(ctx, option.WithAPIKey(cfg.GeminiAPIKey))
+				defer release()
+
+				// Initialize Gemini client (GEMINI_EXTRACTION_API_KEY when set)
+				geminiClient, err := genai.NewClient(ctx, option.WithAPIKey(services.GeminiExtractionKey(cfg)))
 				if err != nil {
 					extractedContent = fmt.Sprintf("Failed to initialize Gemini client: %v", err)
 				} else {
@@ -2829,6 +3105,7 @@ func SetupAdminRoutes(
 		}
 
 		_, err = clientsCollection.UpdateOne(context.Background(), bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "database_error",
@@ -2900,6 +3177,7 @@ func SetupAdminRoutes(
 		}
 
 		_, err = clientsCollection.UpdateOne(context.Background(), bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "database_error",
@@ -3039,9 +3317,14 @@ func SetupAdminRoutes(
 			fileContent, err := os.ReadFile(tempFile)
 			if err != nil {
 				extractedContent = fmt.Sprintf("Failed to read file: %v", err)
+			} else if release, err := services.AcquireGeminiExtraction(ctx, cfg); err != nil {
+				// ✅ NEW: Extraction calls share a throttle so uploads can't starve chat of Gemini quota
+				extractedContent = fmt.Sprintf("Document extraction is busy, please try again: %v", err)
 			} else {
-				// Initialize Gemini client
-				geminiClient, err := genai.NewClient(ctx, option.WithAPIKey(cfg.GeminiAPIKey))
+				defer release()
+
+				// Initialize Gemini client (GEMINI_EXTRACTION_API_KEY when set)
+				geminiClient, err := genai.NewClient(ctx, option.WithAPIKey(services.GeminiExtractionKey(cfg)))
 				if err != nil {
 					extractedContent = fmt.Sprintf("Failed to initialize Gemini client: %v", err)
 				} else {
@@ -3135,6 +3418,7 @@ func SetupAdminRoutes(
 			})
 			return
 		}
+		invalidateDefaultPersonaCache() // ✅ NEW: serve the new persona right away
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":    "Default Persona uploaded successfully",
@@ -3191,12 +3475,21 @@ func SetupAdminRoutes(
 			})
 			return
 		}
+		invalidateDefaultPersonaCache() // ✅ NEW
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Default Persona deleted successfully",
 		})
 	})
 
+	// ✅ NEW: Drop this instance's cached default persona, e.g. after changing system_settings directly
+	admin.POST("/default-persona/cache/invalidate", func(c *gin.Context) {
+		invalidateDefaultPersonaCache()
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Default persona cache invalidated",
+		})
+	})
+
 	// ===== SUPERADMIN-ONLY ROUTES =====
 	// SuperAdmin-only routes group
 	superAdmin := admin.Group("/system")
@@ -3541,12 +3834,15 @@ func SetupAdminRoutes(
 			return
 		}
 
+		// ✅ NEW: Reject renamed or mislabeled files before any processing
+		if verr := utils.ValidatePDFFileHeader(file, header); verr != nil {
+			c.JSON(http.StatusBadRequest, verr)
+			return
+		}
+
 		// Check if async processing is requested
 		isAsync := c.PostForm("async") == "true"
 
-		// Create PDF service
-		pdfService := services.NewPDFService(cfg, pdfsCollection)
-
 		// Create secure upload request
 		uploadReq := &services.SecureUploadRequest{
 			File:     file,
@@ -3554,13 +3850,26 @@ func SetupAdminRoutes(
 			ClientID: clientID,
 			UserID:   primitive.NilObjectID, // Admin upload
 			IsAsync:  isAsync,
+			Force:    c.PostForm("force") == "true", // ✅ NEW: re-upload even if an identical file exists
 		}
 
 		// Process upload
 		result, err := pdfService.ValidateAndProcessUpload(c.Request.Context(), uploadReq)
 		if err != nil {
+			// ✅ NEW: Document count or storage quota reached
+			var quotaErr *services.QuotaError
+			if errors.As(err, &quotaErr) {
+				c.JSON(http.StatusForbidden, documentQuotaErrorBody(quotaErr))
+				return
+			}
+
 			fmt.Printf("❌ PDF upload failed: %s - %v\n", header.Filename, err)
 
+			// ✅ NEW: Infected uploads and an unreachable virus scanner
+			if respondUploadScanError(c, auditLogger, clientID.Hex(), header.Filename, err) {
+				return
+			}
+
 			// Check for specific error types
 			if strings.Contains(err.Error(), "file size") {
 				c.JSON(http.StatusBadRequest, gin.H{
@@ -3600,6 +3909,10 @@ func SetupAdminRoutes(
 			return
 		}
 
+		if !result.Duplicate {
+			invalidateClientConfig(clientID)
+		}
+
 		// Prepare response
 		response := models.UploadResponse{
 			ID:       result.PDF.ID.Hex(),
@@ -3616,6 +3929,12 @@ func SetupAdminRoutes(
 			response.Message = "PDF uploaded successfully, processing in background"
 		}
 
+		// ✅ NEW: The same file was uploaded before; nothing was stored or reprocessed
+		if result.Duplicate {
+			response.Duplicate = true
+			response.Message = "This PDF was already uploaded; returning the existing document (send force=true to upload it again)"
+		}
+
 		// Add task ID for async processing
 		if result.TaskID != "" {
 			response.TaskID = result.TaskID
@@ -3686,11 +4005,8 @@ func SetupAdminRoutes(
 			return
 		}
 
-		// Delete the document
-		deleteResult, err := pdfsCollection.DeleteOne(context.Background(), bson.M{
-			"_id":       documentID,
-			"client_id": clientID,
-		})
+		// Delete the document (✅ NEW: its bytes are released from the client's storage quota)
+		deletedCount, err := pdfService.DeletePDFs(context.Background(), clientID, bson.M{"_id": documentID})
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -3700,20 +4016,21 @@ func SetupAdminRoutes(
 			return
 		}
 
-		if deleteResult.DeletedCount == 0 {
+		if deletedCount == 0 {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error_code": "document_not_found",
 				"message":    "Document not found",
 			})
 			return
 		}
+		invalidateClientConfig(clientID)
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":       "Document deleted successfully",
 			"document_id":   c.Param("documentId"),
 			"filename":      pdfDoc.Filename,
 			"deleted_at":    time.Now().UTC(),
-			"deleted_count": deleteResult.DeletedCount,
+			"deleted_count": deletedCount,
 		})
 	})
 
@@ -3780,11 +4097,8 @@ func SetupAdminRoutes(
 			pdfObjIDs = append(pdfObjIDs, objID)
 		}
 
-		// Delete multiple PDFs
-		deleteResult, err := pdfsCollection.DeleteMany(context.Background(), bson.M{
-			"_id":       bson.M{"$in": pdfObjIDs},
-			"client_id": clientID,
-		})
+		// Delete multiple PDFs (✅ NEW: their bytes are released from the client's storage quota)
+		deletedCount, err := pdfService.DeletePDFs(context.Background(), clientID, bson.M{"_id": bson.M{"$in": pdfObjIDs}})
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -3793,10 +4107,13 @@ func SetupAdminRoutes(
 			})
 			return
 		}
+		if deletedCount > 0 {
+			invalidateClientConfig(clientID)
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":       "Documents deleted successfully",
-			"deleted_count": deleteResult.DeletedCount,
+			"deleted_count": deletedCount,
 			"deleted_at":    time.Now().UTC(),
 		})
 	})
@@ -4019,7 +4336,7 @@ func SetupAdminRoutes(
 			AllowedPaths   []string `json:"allowed_paths,omitempty"`
 			FollowLinks    bool     `json:"follow_links,omitempty"`
 			IncludeImages  bool     `json:"include_images,omitempty"`
-			RespectRobots  bool     `json:"respect_robots,omitempty"`
+			RespectRobots  *bool    `json:"respect_robots,omitempty"` // defaults to true
 			RenderJS       bool     `json:"render_js,omitempty"`
 			WaitSelector   string   `json:"wait_selector,omitempty"`
 			RenderTimeout  int      `json:"render_timeout_ms,omitempty"`
@@ -4049,7 +4366,7 @@ func SetupAdminRoutes(
 			AllowedPaths:   req.AllowedPaths,
 			FollowLinks:    req.FollowLinks,
 			IncludeImages:  req.IncludeImages,
-			RespectRobots:  req.RespectRobots,
+			RespectRobots:  respectsRobots(req.RespectRobots),
 		}
 
 		// Save to MongoDB
@@ -4116,7 +4433,7 @@ func SetupAdminRoutes(
 				AllowedPaths:   req.AllowedPaths,
 				FollowLinks:    req.FollowLinks,
 				IncludeImages:  req.IncludeImages,
-				RespectRobots:  req.RespectRobots,
+				RespectRobots:  respectsRobots(req.RespectRobots),
 				Timeout:        60 * time.Second,
 				RenderJS:       req.RenderJS,
 				WaitSelector:   req.WaitSelector,
@@ -4152,6 +4469,8 @@ func SetupAdminRoutes(
 							"content":         result.Content,
 							"pages_found":     result.PagesFound,
 							"pages_crawled":   result.PagesCrawled,
+							"robots_skipped":  result.RobotsSkipped,
+							"skipped_urls":    result.SkippedURLs,
 							"crawled_pages":   crawledPages,
 							"error":           fmt.Sprintf("Partial success: %v", err.Error()),
 							"updated_at":      time.Now(),
@@ -4186,6 +4505,8 @@ func SetupAdminRoutes(
 					"content":         result.Content,
 					"pages_found":     result.PagesFound,
 					"pages_crawled":   result.PagesCrawled,
+					"robots_skipped":  result.RobotsSkipped,
+					"skipped_urls":    result.SkippedURLs,
 					"crawled_pages":   crawledPages,
 					"updated_at":      time.Now(),
 					"completed_at":    completedAt,
@@ -4242,7 +4563,7 @@ func SetupAdminRoutes(
 			AllowedPaths   []string `json:"allowed_paths,omitempty"`
 			FollowLinks    bool     `json:"follow_links,omitempty"`
 			IncludeImages  bool     `json:"include_images,omitempty"`
-			RespectRobots  bool     `json:"respect_robots,omitempty"`
+			RespectRobots  *bool    `json:"respect_robots,omitempty"` // defaults to true
 			RenderJS       bool     `json:"render_js,omitempty"`
 			WaitSelector   string   `json:"wait_selector,omitempty"`
 			RenderTimeout  int      `json:"render_timeout_ms,omitempty"`
@@ -4342,7 +4663,7 @@ func SetupAdminRoutes(
 				AllowedPaths:   req.AllowedPaths,
 				FollowLinks:    req.FollowLinks,
 				IncludeImages:  req.IncludeImages,
-				RespectRobots:  req.RespectRobots,
+				RespectRobots:  respectsRobots(req.RespectRobots),
 			}
 
 			_, err = crawlsCollection.InsertOne(ctx, crawlJob)
@@ -4375,7 +4696,7 @@ func SetupAdminRoutes(
 					AllowedPaths:   req.AllowedPaths,
 					FollowLinks:    req.FollowLinks,
 					IncludeImages:  req.IncludeImages,
-					RespectRobots:  req.RespectRobots,
+					RespectRobots:  respectsRobots(req.RespectRobots),
 					Timeout:        60 * time.Second,
 					RenderJS:       req.RenderJS,
 					WaitSelector:   req.WaitSelector,
@@ -4408,6 +4729,8 @@ func SetupAdminRoutes(
 								"content":         result.Content,
 								"pages_found":     result.PagesFound,
 								"pages_crawled":   result.PagesCrawled,
+								"robots_skipped":  result.RobotsSkipped,
+								"skipped_urls":    result.SkippedURLs,
 								"crawled_pages":   crawledPages,
 								"error":           fmt.Sprintf("Partial success: %v", err.Error()),
 								"updated_at":      time.Now(),
@@ -4442,6 +4765,8 @@ func SetupAdminRoutes(
 						"content":         result.Content,
 						"pages_found":     result.PagesFound,
 						"pages_crawled":   result.PagesCrawled,
+						"robots_skipped":  result.RobotsSkipped,
+						"skipped_urls":    result.SkippedURLs,
 						"crawled_pages":   crawledPages,
 						"updated_at":      time.Now(),
 						"completed_at":    completedAt,
@@ -4767,6 +5092,12 @@ func SetupAdminRoutes(
 			return
 		}
 
+		// ✅ NEW: Reject malformed branding colors and asset URLs
+		if fieldErrors := normalizeBranding(&req); fieldErrors != nil {
+			respondToInvalidBranding(c, fieldErrors)
+			return
+		}
+
 		// Update client branding
 		update := bson.M{
 			"$set": bson.M{
@@ -4776,6 +5107,7 @@ func SetupAdminRoutes(
 		}
 
 		result, err := clientsCollection.UpdateOne(context.Background(), bson.M{"_id": clientID}, update)
+		invalidateClientConfig(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "internal_error",
@@ -4831,14 +5163,30 @@ func SetupAdminRoutes(
 		period := strings.ToLower(strings.TrimSpace(c.DefaultQuery("period", "30d")))
 		dur := parsePeriod(period)
 
+		channel := strings.ToLower(strings.TrimSpace(c.Query("channel")))
+		if channel != "" && !isValidMessageChannel(channel) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_channel",
+				"message":    "Unknown channel",
+			})
+			return
+		}
+
 		end := time.Now()
 		start := end.Add(-dur)
 
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 		defer cancel()
 
+		// ✅ NEW: Same explicit range and comparison parameters as the client endpoint
+		loc := analyticsLocation(&client)
+		ranges, ok := parseAnalyticsRanges(c, loc, start, end, period)
+		if !ok {
+			return
+		}
+
 		// Use the same generateAnalytics function as client endpoint
-		analytics, err := generateAnalytics(ctx, messagesCollection, clientID, start, end, period)
+		analytics, err := generateAnalytics(ctx, messagesCollection, clientID, ranges.Start, ranges.End, ranges.Period, channel, loc)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "analytics_error",
@@ -4847,6 +5195,9 @@ func SetupAdminRoutes(
 			})
 			return
 		}
+		if !addAnalyticsComparison(ctx, c, messagesCollection, clientID, analytics, ranges, channel) {
+			return
+		}
 
 		// Add token limit info from client
 		analytics["token_used"] = client.TokenUsed
@@ -5049,10 +5400,8 @@ func SetupAdminRoutes(
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		result, err := imagesCollection.DeleteOne(ctx, bson.M{
-			"_id":       imageID,
-			"client_id": clientID,
-		})
+		// ✅ NEW: Uploaded images also lose their stored file and thumbnail
+		found, err := deleteImage(ctx, imagesCollection, imageID, clientID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -5062,7 +5411,7 @@ func SetupAdminRoutes(
 			return
 		}
 
-		if result.DeletedCount == 0 {
+		if !found {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error_code": "image_not_found",
 				"message":    "Image not found",
@@ -5339,6 +5688,7 @@ func SetupAdminRoutes(
 			bson.M{"_id": clientID},
 			update,
 		)
+		invalidateClientConfig(clientID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -5637,6 +5987,7 @@ func SetupAdminRoutes(
 			bson.M{"_id": clientID},
 			update,
 		)
+		invalidateClientConfig(clientID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -5767,6 +6118,7 @@ func SetupAdminRoutes(
 			bson.M{"_id": clientID},
 			update,
 		)
+		invalidateClientConfig(clientID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -6509,7 +6861,7 @@ func SetupAdminRoutes(
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
 		defer cancel()
 
-		err = processUnanalyzedFeedback(ctx, db, &clientID)
+		processed, insightsCreated, err := processUnanalyzedFeedback(ctx, db, &clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "processing_error",
@@ -6520,8 +6872,10 @@ func SetupAdminRoutes(
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "Unanalyzed feedback processed successfully",
+			"success":          true,
+			"message":          "Unanalyzed feedback processed successfully",
+			"processed":        processed,
+			"insights_created": insightsCreated,
 		})
 	})
 
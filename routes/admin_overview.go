@@ -0,0 +1,317 @@
+package routes
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// ADMIN CLIENT OVERVIEW
+// ===================
+//
+// GET /admin/clients/overview summarises every client over the last `days` days (default 30): message
+// volume, token usage, satisfaction rate, status and last activity. The summaries come from one
+// aggregation over messages and one over feedback rather than queries per client, and are cached per
+// period for ADMIN_OVERVIEW_CACHE_SECONDS; sorting and paging are applied to the cached list. Each
+// access is written to the audit log.
+
+const (
+	defaultOverviewDays = 30
+	maxOverviewDays     = 365
+	maxOverviewLimit    = 200
+)
+
+// clientOverview is one client's summary on the admin overview
+type clientOverview struct {
+	ClientID         string     `json:"client_id"`
+	Name             string     `json:"name"`
+	Status           string     `json:"status"`
+	Messages         int        `json:"messages"`
+	TokensUsed       int        `json:"tokens_used"`
+	TokenLimit       int        `json:"token_limit"`
+	TokenUsagePct    float64    `json:"token_usage_percent"`
+	Feedback         int        `json:"feedback"`
+	SatisfactionRate *float64   `json:"satisfaction_rate"` // nil without feedback in the period
+	LastActivity     *time.Time `json:"last_activity"`     // latest message in the period
+}
+
+// overviewSorters order summaries by the supported sort keys, ascending
+var overviewSorters = map[string]func(a, b *clientOverview) bool{
+	"name":         func(a, b *clientOverview) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"messages":     func(a, b *clientOverview) bool { return a.Messages < b.Messages },
+	"token_usage":  func(a, b *clientOverview) bool { return a.TokenUsagePct < b.TokenUsagePct },
+	"satisfaction": func(a, b *clientOverview) bool { return overviewRate(a) < overviewRate(b) },
+	"last_activity": func(a, b *clientOverview) bool {
+		return overviewTime(a).Before(overviewTime(b))
+	},
+}
+
+// overviewRate puts clients without feedback first when sorting by satisfaction ascending
+func overviewRate(o *clientOverview) float64 {
+	if o.SatisfactionRate == nil {
+		return -1
+	}
+	return *o.SatisfactionRate
+}
+
+func overviewTime(o *clientOverview) time.Time {
+	if o.LastActivity == nil {
+		return time.Time{}
+	}
+	return *o.LastActivity
+}
+
+// sortClientOverviews sorts summaries in place by key ("messages" if unknown), keeping name order for ties
+func sortClientOverviews(overviews []clientOverview, key string, descending bool) {
+	less, ok := overviewSorters[key]
+	if !ok {
+		less = overviewSorters["messages"]
+	}
+	byName := overviewSorters["name"]
+	sort.SliceStable(overviews, func(i, j int) bool {
+		a, b := &overviews[i], &overviews[j]
+		if less(a, b) == less(b, a) {
+			return byName(a, b)
+		}
+		if descending {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
+
+// clientOverviewCache keeps the computed summaries per period for a short time
+type clientOverviewCache struct {
+	mu      sync.Mutex
+	entries map[int]clientOverviewCacheEntry
+}
+
+type clientOverviewCacheEntry struct {
+	overviews   []clientOverview
+	generatedAt time.Time
+}
+
+var sharedClientOverviewCache = &clientOverviewCache{entries: make(map[int]clientOverviewCacheEntry)}
+
+func (oc *clientOverviewCache) get(days int, ttl time.Duration) (clientOverviewCacheEntry, bool) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	entry, ok := oc.entries[days]
+	return entry, ok && time.Since(entry.generatedAt) < ttl
+}
+
+func (oc *clientOverviewCache) set(days int, entry clientOverviewCacheEntry) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	oc.entries[days] = entry
+}
+
+// buildClientOverviews computes the summaries of all clients for messages and feedback since start
+func buildClientOverviews(ctx context.Context, db *mongo.Database, start time.Time) ([]clientOverview, error) {
+	type messageStats struct {
+		ClientID     primitive.ObjectID `bson:"_id"`
+		Messages     int                `bson:"messages"`
+		LastActivity time.Time          `bson:"last_activity"`
+	}
+	messagesCursor, err := db.Collection("messages").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": start}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$client_id",
+			"messages":      bson.M{"$sum": 1},
+			"last_activity": bson.M{"$max": "$timestamp"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var messageResults []messageStats
+	if err := messagesCursor.All(ctx, &messageResults); err != nil {
+		return nil, err
+	}
+	messagesByClient := make(map[primitive.ObjectID]messageStats, len(messageResults))
+	for _, result := range messageResults {
+		messagesByClient[result.ClientID] = result
+	}
+
+	type feedbackStats struct {
+		ClientID primitive.ObjectID `bson:"_id"`
+		Total    int                `bson:"total"`
+		Positive int                `bson:"positive"`
+	}
+	feedbackCursor, err := db.Collection("message_feedback").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": start}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$client_id",
+			"total":    bson.M{"$sum": 1},
+			"positive": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$feedback_type", "positive"}}, 1, 0}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var feedbackResults []feedbackStats
+	if err := feedbackCursor.All(ctx, &feedbackResults); err != nil {
+		return nil, err
+	}
+	feedbackByClient := make(map[primitive.ObjectID]feedbackStats, len(feedbackResults))
+	for _, result := range feedbackResults {
+		feedbackByClient[result.ClientID] = result
+	}
+
+	clientsCursor, err := db.Collection("clients").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"name": 1, "status": 1, "token_used": 1, "token_limit": 1,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	var clients []models.Client
+	if err := clientsCursor.All(ctx, &clients); err != nil {
+		return nil, err
+	}
+
+	overviews := make([]clientOverview, 0, len(clients))
+	for _, client := range clients {
+		overview := clientOverview{
+			ClientID:   client.ID.Hex(),
+			Name:       client.Name,
+			Status:     client.Status,
+			TokensUsed: client.TokenUsed,
+			TokenLimit: client.TokenLimit,
+		}
+		if overview.Status == "" {
+			overview.Status = "active"
+		}
+		if client.TokenLimit > 0 {
+			overview.TokenUsagePct = math.Round(float64(client.TokenUsed)/float64(client.TokenLimit)*1000) / 10
+		}
+		if stats, ok := messagesByClient[client.ID]; ok {
+			overview.Messages = stats.Messages
+			lastActivity := stats.LastActivity
+			overview.LastActivity = &lastActivity
+		}
+		if stats, ok := feedbackByClient[client.ID]; ok && stats.Total > 0 {
+			rate := math.Round(float64(stats.Positive)/float64(stats.Total)*1000) / 1000
+			overview.Feedback = stats.Total
+			overview.SatisfactionRate = &rate
+		}
+		overviews = append(overviews, overview)
+	}
+	return overviews, nil
+}
+
+// handleAdminClientOverview returns the sorted, paginated per-client summaries
+// (?days=30&sort=messages|token_usage|satisfaction|last_activity|name&order=desc|asc&page=1&limit=50)
+func handleAdminClientOverview(cfg *config.Config, db *mongo.Database, auditLogger *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultOverviewDays)))
+		if err != nil || days < 1 || days > maxOverviewDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_days",
+				"message":    "days must be between 1 and 365",
+			})
+			return
+		}
+		sortKey := c.DefaultQuery("sort", "messages")
+		if _, ok := overviewSorters[sortKey]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_sort",
+				"message":    "sort must be one of messages, token_usage, satisfaction, last_activity or name",
+			})
+			return
+		}
+		order := "desc"
+		if c.Query("order") == "asc" {
+			order = "asc"
+		}
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if limit < 1 || limit > maxOverviewLimit {
+			limit = 50
+		}
+
+		ttl := time.Duration(cfg.AdminOverviewCacheSeconds) * time.Second
+		entry, fresh := sharedClientOverviewCache.get(days, ttl)
+		if !fresh {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+			defer cancel()
+
+			overviews, err := buildClientOverviews(ctx, db, time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				logger.Error("Failed to build admin client overview", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "overview_failed",
+					"message":    "Failed to build client overview",
+				})
+				return
+			}
+			entry = clientOverviewCacheEntry{overviews: overviews, generatedAt: time.Now()}
+			if ttl > 0 {
+				sharedClientOverviewCache.set(days, entry)
+			}
+		}
+
+		// Sort a copy; the cached slice is shared between requests
+		overviews := append([]clientOverview(nil), entry.overviews...)
+		sortClientOverviews(overviews, sortKey, order == "desc")
+		total := len(overviews)
+		from := (page - 1) * limit
+		if from > total {
+			from = total
+		}
+		to := from + limit
+		if to > total {
+			to = total
+		}
+
+		if auditLogger != nil {
+			auditLogger.LogAsync(&models.AuditEvent{
+				UserID:    middleware.GetUserID(c),
+				Action:    "READ",
+				Resource:  "client_overview",
+				IPAddress: c.ClientIP(),
+				UserAgent: c.Request.UserAgent(),
+				RequestID: middleware.GetRequestID(c),
+				Success:   true,
+				Changes: map[string]interface{}{
+					"days":  days,
+					"sort":  sortKey,
+					"page":  page,
+					"limit": limit,
+				},
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"clients":      overviews[from:to],
+			"total":        total,
+			"page":         page,
+			"limit":        limit,
+			"total_pages":  (total + limit - 1) / limit,
+			"days":         days,
+			"sort":         sortKey,
+			"order":        order,
+			"generated_at": entry.generatedAt,
+			"cached":       fresh,
+		})
+	}
+}
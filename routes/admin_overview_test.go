@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSortClientOverviews(t *testing.T) {
+	rate := func(v float64) *float64 { return &v }
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	overviews := []clientOverview{
+		{Name: "beta", Messages: 10, TokenUsagePct: 95, SatisfactionRate: rate(0.4), LastActivity: &at},
+		{Name: "Alpha", Messages: 10, TokenUsagePct: 20},
+		{Name: "gamma", Messages: 300, TokenUsagePct: 50, SatisfactionRate: rate(0.9)},
+	}
+	names := func() string {
+		var out string
+		for _, o := range overviews {
+			out += o.Name + " "
+		}
+		return out
+	}
+
+	tests := []struct {
+		key        string
+		descending bool
+		want       string
+	}{
+		{"messages", true, "gamma Alpha beta "}, // ties stay in name order
+		{"token_usage", true, "beta gamma Alpha "},
+		{"satisfaction", false, "Alpha beta gamma "}, // no feedback sorts lowest
+		{"last_activity", true, "beta Alpha gamma "},
+		{"name", false, "Alpha beta gamma "},
+	}
+	for _, tt := range tests {
+		sortClientOverviews(overviews, tt.key, tt.descending)
+		if got := names(); got != tt.want {
+			t.Errorf("sort %s desc=%v: got %q, want %q", tt.key, tt.descending, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// AI GENERATION TIMEOUT
+// ===================
+
+// maxAITimeoutSeconds bounds the per-client override
+const maxAITimeoutSeconds = 300
+
+// errAITimeout marks generation that didn't finish within the AI generation timeout
+var errAITimeout = errors.New("AI generation timed out")
+
+// chatRequestTimeout is the overall time budget of a public chat request
+func chatRequestTimeout(cfg *config.Config) time.Duration {
+	if cfg.ChatRequestTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(cfg.ChatRequestTimeout) * time.Second
+}
+
+// maxClientAITimeoutSeconds is the largest per-client override that can take effect: anything above
+// the chat request timeout would be cut short by the request deadline anyway
+func maxClientAITimeoutSeconds(cfg *config.Config) int {
+	limit := int(chatRequestTimeout(cfg) / time.Second)
+	if limit > maxAITimeoutSeconds {
+		limit = maxAITimeoutSeconds
+	}
+	return limit
+}
+
+// aiGenerationTimeout returns how long one Gemini call may take for the client. It never exceeds the
+// overall chat request timeout, which always bounds the call as well.
+func aiGenerationTimeout(cfg *config.Config, client *models.Client) time.Duration {
+	timeout := time.Duration(cfg.AIGenerationTimeout) * time.Second
+	if client.AITimeoutSeconds > 0 {
+		timeout = time.Duration(client.AITimeoutSeconds) * time.Second
+	}
+	if limit := chatRequestTimeout(cfg); timeout <= 0 || timeout > limit {
+		timeout = limit
+	}
+	return timeout
+}
+
+// isAITimeout reports whether a generation error came from the generation deadline (or the request's)
+// expiring rather than from Gemini itself
+func isAITimeout(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
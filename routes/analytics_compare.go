@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// ANALYTICS COMPARISON
+// ===================
+//
+// By default analytics compare against the immediately preceding period of equal length
+// ("previous_period"). The range and compare_to query parameters take explicit day ranges, e.g.
+// range=2026-03-01..2026-03-31&compare_to=2025-03-01..2025-03-31 for this March against last March. Days
+// are inclusive and taken in the client's analytics timezone; the result is returned under "comparison"
+// with the totals of both ranges and their deltas.
+
+const (
+	analyticsDateLayout   = "2006-01-02"
+	analyticsRangeSep     = ".."
+	maxAnalyticsRangeDays = 731
+)
+
+// comparedAnalyticsTotals are the totals compared between the two ranges
+var comparedAnalyticsTotals = []string{"total_messages", "total_tokens", "active_users"}
+
+// parseAnalyticsDateRange parses "YYYY-MM-DD..YYYY-MM-DD" into the start of the first day and the end
+// of the last day in loc
+func parseAnalyticsDateRange(value string, loc *time.Location) (time.Time, time.Time, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(value), analyticsRangeSep)
+	if !ok {
+		return time.Time{}, time.Time{}, errors.New(`date range must look like "2026-03-01..2026-03-31"`)
+	}
+	start, err := time.ParseInLocation(analyticsDateLayout, strings.TrimSpace(from), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q", from)
+	}
+	lastDay, err := time.ParseInLocation(analyticsDateLayout, strings.TrimSpace(to), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q", to)
+	}
+	if lastDay.Before(start) {
+		return time.Time{}, time.Time{}, errors.New("date range ends before it starts")
+	}
+	end := lastDay.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	if end.Sub(start) > maxAnalyticsRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range can span at most %d days", maxAnalyticsRangeDays)
+	}
+	return start, end, nil
+}
+
+// analyticsRanges are the current and comparison ranges of an analytics request
+type analyticsRanges struct {
+	Start, End               time.Time
+	Period                   string
+	CompareStart, CompareEnd time.Time // zero unless compare_to was given
+}
+
+// parseAnalyticsRanges applies the range and compare_to query parameters over the default rolling period,
+// writing a 400 response and returning false if either is invalid
+func parseAnalyticsRanges(c *gin.Context, loc *time.Location, start, end time.Time, period string) (analyticsRanges, bool) {
+	ranges := analyticsRanges{Start: start, End: end, Period: period}
+	var err error
+	if value := c.Query("range"); value != "" {
+		if ranges.Start, ranges.End, err = parseAnalyticsDateRange(value, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_range",
+				"message":    err.Error(),
+			})
+			return ranges, false
+		}
+		ranges.Period = "custom"
+	}
+	if value := c.Query("compare_to"); value != "" {
+		if ranges.CompareStart, ranges.CompareEnd, err = parseAnalyticsDateRange(value, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_compare_to",
+				"message":    err.Error(),
+			})
+			return ranges, false
+		}
+	}
+	return ranges, true
+}
+
+// addAnalyticsComparison adds the requested comparison to analytics, writing a 500 response and
+// returning false on failure
+func addAnalyticsComparison(ctx context.Context, c *gin.Context, collection *mongo.Collection, clientID primitive.ObjectID, analytics gin.H, ranges analyticsRanges, channel string) bool {
+	if ranges.CompareStart.IsZero() {
+		return true
+	}
+	comparison, err := analyticsComparison(ctx, collection, clientID, analytics, ranges.CompareStart, ranges.CompareEnd, channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error_code": "analytics_error",
+			"message":    "Failed to generate comparison analytics",
+			"details":    err.Error(),
+		})
+		return false
+	}
+	analytics["comparison"] = comparison
+	return true
+}
+
+// analyticsDelta describes how a total changed from the comparison range to the current one.
+// change_percent is nil when the comparison total is zero.
+func analyticsDelta(current, compare int) gin.H {
+	var percent *float64
+	if compare != 0 {
+		p := math.Round(float64(current-compare)/float64(compare)*1000) / 10
+		percent = &p
+	}
+	return gin.H{
+		"current":        current,
+		"compare":        compare,
+		"change":         current - compare,
+		"change_percent": percent,
+	}
+}
+
+// analyticsComparison compares the totals in analytics with those of [start, end]
+func analyticsComparison(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, analytics gin.H, start, end time.Time, channel string) (gin.H, error) {
+	totals, err := getPeriodTotals(ctx, collection, clientID, start, end, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas := gin.H{}
+	for _, key := range comparedAnalyticsTotals {
+		current, _ := analytics[key].(int)
+		compare, _ := totals[key].(int)
+		deltas[key] = analyticsDelta(current, compare)
+	}
+
+	totals["start_date"] = start.Format(time.RFC3339)
+	totals["end_date"] = end.Format(time.RFC3339)
+	return gin.H{
+		"totals": totals,
+		"deltas": deltas,
+	}, nil
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAnalyticsDateRange(t *testing.T) {
+	kolkata := time.FixedZone("IST", 5*3600+1800)
+	start, end, err := parseAnalyticsDateRange("2025-03-01..2025-03-31", kolkata)
+	if err != nil {
+		t.Fatalf("parseAnalyticsDateRange: %v", err)
+	}
+	if want := time.Date(2025, 3, 1, 0, 0, 0, 0, kolkata); !start.Equal(want) {
+		t.Errorf("start = %v, want %v", start, want)
+	}
+	if want := time.Date(2025, 4, 1, 0, 0, 0, 0, kolkata).Add(-time.Nanosecond); !end.Equal(want) {
+		t.Errorf("end = %v, want %v", end, want)
+	}
+
+	for _, invalid := range []string{"2025-03-01", "2025-03-31..2025-03-01", "2025-3-1..2025-03-31", "2020-01-01..2025-01-01"} {
+		if _, _, err := parseAnalyticsDateRange(invalid, time.UTC); err == nil {
+			t.Errorf("parseAnalyticsDateRange(%q) accepted an invalid range", invalid)
+		}
+	}
+}
+
+func TestAnalyticsDelta(t *testing.T) {
+	delta := analyticsDelta(150, 100)
+	if delta["change"] != 50 || *delta["change_percent"].(*float64) != 50 {
+		t.Errorf("delta = %v, want +50 (50%%)", delta)
+	}
+	if delta := analyticsDelta(20, 0); delta["change_percent"].(*float64) != nil {
+		t.Errorf("percent change from zero = %v, want nil", delta["change_percent"])
+	}
+}
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// ANALYTICS TIMEZONE
+// ===================
+//
+// Daily analytics buckets and the "daily" quality period follow the client's IANA timezone (e.g.
+// "Asia/Kolkata"), so a day in the charts is the client's business day rather than a UTC day. Unset or
+// unknown timezones fall back to UTC.
+
+// analyticsLocation returns the timezone the client's analytics are bucketed in
+func analyticsLocation(client *models.Client) *time.Location {
+	if client == nil || client.AnalyticsTimezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(client.AnalyticsTimezone)
+	if err != nil {
+		logger.Warn("Unknown analytics timezone, using UTC", "client_id", client.ID.Hex(), "timezone", client.AnalyticsTimezone)
+		return time.UTC
+	}
+	return loc
+}
+
+// loadAnalyticsLocation looks up the client's analytics timezone, falling back to UTC
+func loadAnalyticsLocation(ctx context.Context, clientsCollection *mongo.Collection, clientID primitive.ObjectID) *time.Location {
+	var client models.Client
+	opts := options.FindOne().SetProjection(bson.M{"analytics_timezone": 1})
+	if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientID}, opts).Decode(&client); err != nil {
+		return time.UTC
+	}
+	return analyticsLocation(&client)
+}
+
+// validAnalyticsTimezone reports whether name is an IANA timezone other than the ambiguous "Local"
+func validAnalyticsTimezone(name string) bool {
+	if name == "" || strings.EqualFold(name, "Local") {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// handleGetAnalyticsTimezone returns the timezone the client's analytics days follow
+func handleGetAnalyticsTimezone(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"timezone":   analyticsLocation(clientDoc).String(),
+			"is_default": clientDoc.AnalyticsTimezone == "",
+		})
+	}
+}
+
+// handleUpdateAnalyticsTimezone sets the client's analytics timezone; an empty timezone restores UTC
+func handleUpdateAnalyticsTimezone(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var request struct {
+			Timezone string `json:"timezone"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		timezone := strings.TrimSpace(request.Timezone)
+		if timezone != "" && !validAnalyticsTimezone(timezone) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_timezone",
+				"message":    `timezone must be an IANA timezone such as "Asia/Kolkata" or "America/New_York"`,
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		update := bson.M{"$set": bson.M{"analytics_timezone": timezone, "updated_at": time.Now()}}
+		if timezone == "" {
+			update = bson.M{"$unset": bson.M{"analytics_timezone": ""}, "$set": bson.M{"updated_at": time.Now()}}
+		}
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, update)
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update analytics timezone",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Analytics timezone updated",
+			"timezone": analyticsLocation(&models.Client{AnalyticsTimezone: timezone}).String(),
+		})
+	}
+}
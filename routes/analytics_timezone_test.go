@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"saas-chatbot-platform/models"
+)
+
+func TestAnalyticsLocation(t *testing.T) {
+	tests := []struct {
+		timezone string
+		want     string
+	}{
+		{"", "UTC"},
+		{"Asia/Kolkata", "Asia/Kolkata"},
+		{"Mars/Olympus_Mons", "UTC"},
+	}
+	for _, tt := range tests {
+		if got := analyticsLocation(&models.Client{AnalyticsTimezone: tt.timezone}).String(); got != tt.want {
+			t.Errorf("analyticsLocation(%q) = %s, want %s", tt.timezone, got, tt.want)
+		}
+	}
+	if validAnalyticsTimezone("Local") || validAnalyticsTimezone("Not/AZone") || !validAnalyticsTimezone("America/New_York") {
+		t.Error("validAnalyticsTimezone accepted or rejected the wrong names")
+	}
+}
+
+func TestTopicQualityTrackerUsesLocalDays(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	// 20:00 UTC on March 1 is already March 2 in India
+	feedback := models.MessageFeedback{FeedbackType: "positive", Timestamp: time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)}
+
+	for loc, want := range map[*time.Location]string{time.UTC: "2026-03-01", kolkata: "2026-03-02"} {
+		tracker := newTopicQualityTracker(loc)
+		tracker.add("pricing", feedback)
+		if trend := tracker.trend(); len(trend) != 1 || trend[0].Date != want {
+			t.Errorf("%s: trend = %+v, want one point on %s", loc, trend, want)
+		}
+	}
+}
@@ -2,30 +2,39 @@ package routes
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"saas-chatbot-platform/internal/config"
 	"saas-chatbot-platform/internal/queue"
 	"saas-chatbot-platform/middleware"
 	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+	"saas-chatbot-platform/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // HandleAsyncPDFUpload processes PDF file uploads asynchronously
-func HandleAsyncPDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection, queueClient *asynq.Client) gin.HandlerFunc {
+func HandleAsyncPDFUpload(cfg *config.Config, clientsCollection, pdfsCollection *mongo.Collection, queueClient *asynq.Client, auditLogger *models.AuditLogger) gin.HandlerFunc {
+	scanner := services.NewVirusScanner(cfg)
+	pdfService := services.NewPDFService(cfg, clientsCollection, pdfsCollection)
+
 	return func(c *gin.Context) {
 		userClientID := middleware.GetClientID(c)
 		if userClientID == "" && !middleware.IsAdmin(c) {
@@ -54,13 +63,9 @@ func HandleAsyncPDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection,
 		}
 		defer file.Close()
 
-		// Validate file type
-		ct := header.Header.Get("Content-Type")
-		if !strings.Contains(ct, "pdf") && !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error_code": "invalid_file_type",
-				"message":    "Only PDF files are allowed",
-			})
+		// Validate extension, declared type and file signature
+		if verr := utils.ValidatePDFFileHeader(file, header); verr != nil {
+			c.JSON(http.StatusBadRequest, verr)
 			return
 		}
 
@@ -120,14 +125,68 @@ func HandleAsyncPDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection,
 			})
 			return
 		}
-		defer dst.Close()
-		if _, err := io.Copy(dst, io.LimitReader(file, cfg.MaxFileSize)); err != nil {
+		hasher := sha256.New()
+		_, err = io.Copy(io.MultiWriter(dst, hasher), io.LimitReader(file, cfg.MaxFileSize))
+		dst.Close()
+		if err != nil {
+			os.Remove(filePath)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "file_save_error",
 				"message":    "Failed to save file",
 			})
 			return
 		}
+		fileHash := hex.EncodeToString(hasher.Sum(nil))
+
+		// ✅ NEW: Return the existing document instead of reprocessing a re-uploaded file (force=true overrides)
+		if c.PostForm("force") != "true" {
+			existing, err := findDuplicatePDF(c.Request.Context(), pdfsCollection, userClientID, fileHash)
+			if err != nil {
+				os.Remove(filePath)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "database_error",
+					"message":    "Failed to check for duplicate uploads",
+				})
+				return
+			}
+			if existing != nil {
+				os.Remove(filePath)
+				c.JSON(http.StatusOK, gin.H{
+					"message":   "This PDF was already uploaded; returning the existing document (send force=true to upload it again)",
+					"duplicate": true,
+					"file_id":   existing["_id"],
+					"status":    existing["status"],
+					"filename":  header.Filename,
+					"size":      header.Size,
+				})
+				return
+			}
+		}
+
+		// Enforce the client's document count and storage limits (duplicates above cost nothing)
+		clientObjID, clientIDErr := primitive.ObjectIDFromHex(userClientID)
+		if clientIDErr == nil {
+			if err := pdfService.CheckQuota(c.Request.Context(), clientObjID, header.Size); err != nil {
+				os.Remove(filePath)
+				var quotaErr *services.QuotaError
+				if errors.As(err, &quotaErr) {
+					c.JSON(http.StatusForbidden, documentQuotaErrorBody(quotaErr))
+				}
+				return
+			}
+		}
+
+		// ✅ NEW: Virus scan before the file is queued for processing (infected files are quarantined)
+		if err := services.ScanUploadedFile(cfg, scanner, filePath, userClientID); err != nil {
+			os.Remove(filePath)
+			if !respondUploadScanError(c, auditLogger, userClientID, header.Filename, err) {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "file_scan_error",
+					"message":    "Failed to scan file",
+				})
+			}
+			return
+		}
 
 		// Create database record with "pending" status
 		ctx := context.Background()
@@ -140,6 +199,7 @@ func HandleAsyncPDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection,
 			Progress:  0,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
+			FileHash:  fileHash,
 		}
 
 		_, err = pdfsCollection.InsertOne(ctx, pdfDoc)
@@ -178,6 +238,11 @@ func HandleAsyncPDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection,
 			return
 		}
 
+		if clientIDErr == nil {
+			pdfService.ChargeStorage(ctx, clientObjID, header.Size)
+			invalidateClientConfig(clientObjID)
+		}
+
 		// Return immediately with task info
 		c.JSON(http.StatusAccepted, gin.H{
 			"message":    "PDF upload accepted for processing",
@@ -191,8 +256,42 @@ func HandleAsyncPDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection,
 	}
 }
 
+// pdfClientIDs matches documents uploaded here (string client_id) as well as through
+// /client/upload (ObjectID client_id)
+func pdfClientIDs(clientID string) bson.M {
+	clientIDs := bson.A{clientID}
+	if clientObjID, err := primitive.ObjectIDFromHex(clientID); err == nil {
+		clientIDs = append(clientIDs, clientObjID)
+	}
+	return bson.M{"$in": clientIDs}
+}
+
+// findDuplicatePDF returns the client's earliest live document with the same content hash, whether it was
+// uploaded here (string client_id) or through /client/upload (ObjectID client_id), or nil
+func findDuplicatePDF(ctx context.Context, pdfsCollection *mongo.Collection, clientID, fileHash string) (bson.M, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var existing bson.M
+	err := pdfsCollection.FindOne(ctx, bson.M{
+		"client_id": pdfClientIDs(clientID),
+		"file_hash": fileHash,
+		"status":    bson.M{"$in": bson.A{models.StatusPending, models.StatusProcessing, models.StatusCompleted}},
+	}, options.FindOne().SetProjection(bson.M{"_id": 1, "status": 1})).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := existing["_id"].(primitive.ObjectID); ok {
+		existing["_id"] = id.Hex()
+	}
+	return existing, nil
+}
+
 // CheckPDFStatus checks the processing status of a PDF
-func CheckPDFStatus(pdfsCollection *mongo.Collection) gin.HandlerFunc {
+func CheckPDFStatus(cfg *config.Config, pdfsCollection *mongo.Collection, rdb *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		fileID := c.Param("fileID")
 		userClientID := middleware.GetClientID(c)
@@ -230,8 +329,43 @@ func CheckPDFStatus(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 			"size":       pdf.Size,
 			"created_at": pdf.CreatedAt,
 			"updated_at": pdf.UpdatedAt,
+			"queue":      clientQueueDepth(ctx, cfg, pdfsCollection, rdb, userClientID, &pdf),
+		})
+	}
+}
+
+// clientQueueDepth reports how many of a client's PDF jobs are waiting and running against its per-client
+// limit, and where pdf stands in that client's queue while it is pending. Documents from both upload
+// paths count, whichever form their client_id is stored in.
+func clientQueueDepth(ctx context.Context, cfg *config.Config, pdfsCollection *mongo.Collection, rdb *redis.Client, clientID string, pdf *models.PDFDocument) gin.H {
+	limit := cfg.PDFJobClientConcurrency
+	if limit <= 0 {
+		limit = queue.DefaultClientConcurrency
+	}
+
+	clientIDs := pdfClientIDs(clientID)
+	pending, _ := pdfsCollection.CountDocuments(ctx, bson.M{"client_id": clientIDs, "status": "pending"})
+
+	// Workers hold a Redis slot per running job; fall back to document status if Redis can't be read
+	running, err := queue.RunningClientJobs(ctx, rdb, clientID)
+	if err != nil {
+		running, _ = pdfsCollection.CountDocuments(ctx, bson.M{"client_id": clientIDs, "status": "processing"})
+	}
+
+	depth := gin.H{
+		"pending": pending,
+		"running": running,
+		"limit":   limit,
+	}
+	if pdf.Status == "pending" {
+		ahead, _ := pdfsCollection.CountDocuments(ctx, bson.M{
+			"client_id":  clientIDs,
+			"status":     "pending",
+			"created_at": bson.M{"$lt": pdf.CreatedAt},
 		})
+		depth["position"] = ahead + 1
 	}
+	return depth
 }
 
 // ListPDFsWithStatus lists all PDFs for a client with their status
@@ -1,6 +1,11 @@
 package routes
 
-import "testing"
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 func TestHandleAsyncPDFUpload_Validation(t *testing.T) {
 	t.Skip("integration test placeholder: add multipart upload, magic header, size limits")
@@ -9,3 +14,16 @@ func TestHandleAsyncPDFUpload_Validation(t *testing.T) {
 func TestCheckPDFStatus_Unauthorized(t *testing.T) {
 	t.Skip("integration test placeholder: ensure client scoping on status endpoint")
 }
+
+func TestPDFClientIDs(t *testing.T) {
+	oid := primitive.NewObjectID()
+	ids := pdfClientIDs(oid.Hex())["$in"].(bson.A)
+	if len(ids) != 2 || ids[0] != oid.Hex() || ids[1] != oid {
+		t.Errorf("pdfClientIDs(%s) = %v, want the hex string and the ObjectID", oid.Hex(), ids)
+	}
+
+	ids = pdfClientIDs("legacy-client")["$in"].(bson.A)
+	if len(ids) != 1 || ids[0] != "legacy-client" {
+		t.Errorf("pdfClientIDs(legacy-client) = %v, want only the string", ids)
+	}
+}
@@ -2,26 +2,143 @@ package routes
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"saas-chatbot-platform/middleware"
 	"saas-chatbot-platform/models"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// ✅ NEW: Audit query parameters shared by the query and export endpoints
+//
+//	client_id         exact client
+//	actor / user_id   exact user who performed the action
+//	action            comma-separated list, e.g. CREATE,DELETE
+//	resource          comma-separated list, e.g. pdf,client
+//	resource_id       exact resource
+//	from / to         RFC3339 or YYYY-MM-DD (start_time / end_time are accepted as aliases)
+//	result            success or failure
+
+// auditListParam splits a comma-separated query value, optionally upper-casing each entry
+func auditListParam(value string, upper bool) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if upper {
+			item = strings.ToUpper(item)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// parseAuditTime accepts RFC3339 or YYYY-MM-DD; a date-only end bound covers the whole day
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 or YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+// buildAuditFilter translates the audit query parameters into a MongoDB filter.
+// Admins whose token is bound to a client can only query that client's logs.
+func buildAuditFilter(c *gin.Context) (bson.M, int, error) {
+	filter := bson.M{}
+
+	clientID := strings.TrimSpace(c.Query("client_id"))
+	if scopedClientID := middleware.GetClientID(c); scopedClientID != "" {
+		if clientID != "" && clientID != scopedClientID {
+			return nil, http.StatusForbidden, fmt.Errorf("you can only query audit logs for your own client")
+		}
+		clientID = scopedClientID
+	}
+	if clientID != "" {
+		filter["client_id"] = clientID
+	}
+
+	actor := strings.TrimSpace(c.Query("actor"))
+	if actor == "" {
+		actor = strings.TrimSpace(c.Query("user_id"))
+	}
+	if actor != "" {
+		filter["user_id"] = actor
+	}
+
+	if actions := auditListParam(c.Query("action"), true); len(actions) == 1 {
+		filter["action"] = actions[0]
+	} else if len(actions) > 1 {
+		filter["action"] = bson.M{"$in": actions}
+	}
+
+	if resources := auditListParam(c.Query("resource"), false); len(resources) == 1 {
+		filter["resource"] = resources[0]
+	} else if len(resources) > 1 {
+		filter["resource"] = bson.M{"$in": resources}
+	}
+
+	if resourceID := strings.TrimSpace(c.Query("resource_id")); resourceID != "" {
+		filter["resource_id"] = resourceID
+	}
+
+	switch result := strings.ToLower(strings.TrimSpace(c.Query("result"))); result {
+	case "":
+	case "success":
+		filter["success"] = true
+	case "failure":
+		filter["success"] = false
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("result must be success or failure")
+	}
+
+	fromStr := c.DefaultQuery("from", c.Query("start_time"))
+	toStr := c.DefaultQuery("to", c.Query("end_time"))
+	timeFilter := bson.M{}
+	var from, to time.Time
+	if fromStr != "" {
+		t, err := parseAuditTime(fromStr, false)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		from = t
+		timeFilter["$gte"] = t
+	}
+	if toStr != "" {
+		t, err := parseAuditTime(toStr, true)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		to = t
+		timeFilter["$lte"] = t
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return nil, http.StatusBadRequest, fmt.Errorf("to must not be before from")
+	}
+	if len(timeFilter) > 0 {
+		filter["timestamp"] = timeFilter
+	}
+
+	return filter, http.StatusOK, nil
+}
+
 // QueryAuditLogs queries audit logs with filters
 func QueryAuditLogs(auditor *models.AuditLogger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Parse query parameters
-		clientID := c.Query("client_id")
-		userID := c.Query("user_id")
-		action := c.Query("action")
-		resource := c.Query("resource")
-		startTimeStr := c.Query("start_time")
-		endTimeStr := c.Query("end_time")
 		pageStr := c.DefaultQuery("page", "1")
 		pageSizeStr := c.DefaultQuery("page_size", "20")
 
@@ -37,43 +154,16 @@ func QueryAuditLogs(auditor *models.AuditLogger) gin.HandlerFunc {
 		}
 
 		// Build filter
-		filter := bson.M{}
-
-		if clientID != "" {
-			filter["client_id"] = clientID
-		}
-		if userID != "" {
-			filter["user_id"] = userID
-		}
-		if action != "" {
-			filter["action"] = action
-		}
-		if resource != "" {
-			filter["resource"] = resource
-		}
-
-		// Parse time range
-		if startTimeStr != "" || endTimeStr != "" {
-			timeFilter := bson.M{}
-			
-			if startTimeStr != "" {
-				if startTime, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
-					timeFilter["$gte"] = startTime
-				}
-			}
-			
-			if endTimeStr != "" {
-				if endTime, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
-					timeFilter["$lte"] = endTime
-				}
-			}
-			
-			if len(timeFilter) > 0 {
-				filter["timestamp"] = timeFilter
-			}
+		filter, status, err := buildAuditFilter(c)
+		if err != nil {
+			c.JSON(status, gin.H{
+				"error_code": "invalid_query",
+				"message":    err.Error(),
+			})
+			return
 		}
 
-		// Execute query
+		// Execute query (read-only: the hash chain is never touched)
 		events, total, err := auditor.QueryAuditLogs(filter, page, pageSize)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -86,15 +176,32 @@ func QueryAuditLogs(auditor *models.AuditLogger) gin.HandlerFunc {
 		// Calculate pagination info
 		totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
 
-		c.JSON(http.StatusOK, gin.H{
-			"events": events,
+		response := gin.H{
+			"events":  events,
+			"total":   total,
+			"filters": filter,
 			"pagination": gin.H{
 				"page":        page,
 				"page_size":   pageSize,
 				"total":       total,
 				"total_pages": totalPages,
 			},
-		})
+		}
+
+		// ✅ NEW: Optionally confirm the client's chain is intact alongside the results
+		if clientID, ok := filter["client_id"].(string); ok && c.Query("verify_chain") == "true" {
+			isValid, err := auditor.VerifyChain(clientID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "verification_failed",
+					"message":    "Failed to verify audit chain",
+				})
+				return
+			}
+			response["chain_valid"] = isValid
+		}
+
+		c.JSON(http.StatusOK, response)
 	}
 }
 
@@ -218,61 +325,54 @@ func GetAuditStats(auditor *models.AuditLogger) gin.HandlerFunc {
 			return
 		}
 
+		// ✅ NEW: Results of the scheduled audit chain verification
+		chainHealth, err := auditChainHealth(ctx, col.Database())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "stats_failed",
+				"message":    "Failed to get audit chain health",
+			})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"total_events":    totalEvents,
 			"action_stats":    actionStats,
 			"resource_stats":  resourceStats,
+			"chain_health":    chainHealth,
 			"generated_at":    time.Now(),
 		})
 	}
 }
 
-// ExportAuditLogs exports audit logs to JSON
+// ExportAuditLogs exports audit logs to JSON, or streams them as NDJSON or CEF (see audit_export.go)
 func ExportAuditLogs(auditor *models.AuditLogger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Parse query parameters (same as QueryAuditLogs)
-		clientID := c.Query("client_id")
-		userID := c.Query("user_id")
-		action := c.Query("action")
-		resource := c.Query("resource")
-		startTimeStr := c.Query("start_time")
-		endTimeStr := c.Query("end_time")
+		// ✅ NEW: Output format for SIEM ingestion
+		format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", auditExportFormatJSON)))
+		switch format {
+		case auditExportFormatJSON, auditExportFormatNDJSON, auditExportFormatCEF:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_format",
+				"message":    "format must be json, ndjson or cef",
+			})
+			return
+		}
 
 		// Build filter (same as QueryAuditLogs)
-		filter := bson.M{}
-
-		if clientID != "" {
-			filter["client_id"] = clientID
-		}
-		if userID != "" {
-			filter["user_id"] = userID
-		}
-		if action != "" {
-			filter["action"] = action
-		}
-		if resource != "" {
-			filter["resource"] = resource
+		filter, status, err := buildAuditFilter(c)
+		if err != nil {
+			c.JSON(status, gin.H{
+				"error_code": "invalid_query",
+				"message":    err.Error(),
+			})
+			return
 		}
 
-		// Parse time range
-		if startTimeStr != "" || endTimeStr != "" {
-			timeFilter := bson.M{}
-			
-			if startTimeStr != "" {
-				if startTime, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
-					timeFilter["$gte"] = startTime
-				}
-			}
-			
-			if endTimeStr != "" {
-				if endTime, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
-					timeFilter["$lte"] = endTime
-				}
-			}
-			
-			if len(timeFilter) > 0 {
-				filter["timestamp"] = timeFilter
-			}
+		if format != auditExportFormatJSON {
+			streamAuditExport(c, auditor, filter, format)
+			return
 		}
 
 		// Get all matching events (no pagination for export)
@@ -0,0 +1,303 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// AUDIT CHAIN MONITORING
+// ===================
+
+// auditChainStatusCollection holds one models.AuditChainStatus per audited client
+const auditChainStatusCollection = "audit_chain_status"
+
+// auditChainFullVerifyInterval is how often the whole chain is re-verified on top of the incremental check
+const auditChainFullVerifyInterval = 7 * 24 * time.Hour
+
+// Outcomes of a full chain verification (models.AuditChainStatus.History)
+const (
+	auditHistoryVerified     = "verified"
+	auditHistoryUnverifiable = "legacy_unverifiable"
+	auditHistoryFailed       = "failed"
+)
+
+// AuditChainMonitor periodically verifies every client's audit hash chain from a per-client baseline,
+// re-verifies the whole chain weekly and raises an alert when a chain that previously verified fails,
+// which indicates tampering.
+type AuditChainMonitor struct {
+	cfg         *config.Config
+	db          *mongo.Database
+	auditLogger *models.AuditLogger
+	emailSender *services.SMTPEmailSender
+	stopChan    chan struct{}
+}
+
+// NewAuditChainMonitor creates an audit chain monitor
+func NewAuditChainMonitor(cfg *config.Config, db *mongo.Database, auditLogger *models.AuditLogger) *AuditChainMonitor {
+	return &AuditChainMonitor{
+		cfg:         cfg,
+		db:          db,
+		auditLogger: auditLogger,
+		emailSender: services.NewSMTPEmailSender(*cfg),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Start verifies all chains every AuditChainCheckInterval hours until Stop is called
+func (m *AuditChainMonitor) Start() {
+	interval := time.Duration(m.cfg.AuditChainCheckInterval) * time.Hour
+	if interval <= 0 {
+		interval = 6 * time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info("Starting audit chain monitor", "interval", interval.String())
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			m.verifyAllChains(ctx)
+			cancel()
+
+		case <-m.stopChan:
+			logger.Info("Stopping audit chain monitor")
+			return
+		}
+	}
+}
+
+// Stop stops the monitor
+func (m *AuditChainMonitor) Stop() {
+	close(m.stopChan)
+}
+
+// verifyAllChains verifies the chain of every client that has audit events
+func (m *AuditChainMonitor) verifyAllChains(ctx context.Context) {
+	clientIDs, err := m.auditLogger.Collection().Distinct(ctx, "client_id", bson.M{})
+	if err != nil {
+		logger.Error("Failed to list audited clients", "error", err)
+		return
+	}
+
+	failed := 0
+	for _, raw := range clientIDs {
+		clientID, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !m.verifyClientChain(ctx, clientID) {
+			failed++
+		}
+	}
+	logger.Info("Audit chain verification completed", "clients", len(clientIDs), "failed", failed)
+}
+
+// verifyClientChain verifies one chain, records the result and alerts when the chain has just become invalid.
+// The first check of a client verifies the whole chain and records a baseline at its latest event. A
+// chain that fails that first check is recorded as legacy_unverifiable instead of raising an alert,
+// since it predates monitoring (or reproducibly stored hashes).
+func (m *AuditChainMonitor) verifyClientChain(ctx context.Context, clientID string) bool {
+	statusCollection := m.db.Collection(auditChainStatusCollection)
+
+	var previous models.AuditChainStatus
+	if err := statusCollection.FindOne(ctx, bson.M{"_id": clientID}).Decode(&previous); err != nil && err != mongo.ErrNoDocuments {
+		logger.Warn("Failed to load audit chain status", "error", err, "client_id", clientID)
+		return true
+	}
+	if previous.Baseline == nil {
+		m.recordBaseline(ctx, clientID)
+		return true
+	}
+
+	valid, err := m.auditLogger.VerifyChainSince(clientID, previous.Baseline)
+	now := time.Now()
+
+	set := bson.M{"last_verified_at": now}
+	history := previous.History
+	if err == nil && (previous.HistoryVerifiedAt == nil || now.Sub(*previous.HistoryVerifiedAt) >= auditChainFullVerifyInterval) {
+		history, err = m.verifyHistory(clientID, previous.History)
+		set["history"] = history
+		set["history_verified_at"] = now
+	}
+	// A history that broke after verifying keeps the chain invalid until the next full check passes
+	valid = valid && history != auditHistoryFailed
+	set["valid"] = valid
+	update := bson.M{"$set": set}
+	if err != nil {
+		// Verification could not run; keep the previous result so a DB hiccup isn't reported as tampering
+		logger.Warn("Audit chain verification failed to run", "error", err, "client_id", clientID)
+		update = bson.M{"$set": bson.M{"last_verified_at": now, "error": err.Error()}}
+	} else {
+		update["$unset"] = bson.M{"error": ""}
+		if valid {
+			update["$unset"] = bson.M{"error": "", "alerted_at": ""}
+		}
+	}
+
+	if _, updateErr := statusCollection.UpdateOne(ctx, bson.M{"_id": clientID}, update); updateErr != nil {
+		logger.Warn("Failed to record audit chain status", "error", updateErr, "client_id", clientID)
+	}
+	if err != nil || valid {
+		return true
+	}
+
+	// Alert once per break: only when the previous result was valid
+	if previous.Valid {
+		m.raiseTamperAlert(ctx, clientID, now)
+	}
+	return false
+}
+
+// verifyHistory verifies the client's whole chain. A failure after a passing full check is tampering;
+// a chain that has never passed one is legacy history that can't be verified.
+func (m *AuditChainMonitor) verifyHistory(clientID, previous string) (string, error) {
+	valid, err := m.auditLogger.VerifyChain(clientID)
+	switch {
+	case err != nil:
+		return previous, err
+	case valid:
+		return auditHistoryVerified, nil
+	case previous == auditHistoryVerified || previous == auditHistoryFailed:
+		return auditHistoryFailed, nil
+	default:
+		return auditHistoryUnverifiable, nil
+	}
+}
+
+// recordBaseline verifies a client's whole chain and starts incremental verification from its latest event
+func (m *AuditChainMonitor) recordBaseline(ctx context.Context, clientID string) {
+	// The head is read first so events logged during the full check are covered by the incremental one
+	baseline, err := m.auditLogger.ChainHead(clientID)
+	if err != nil {
+		logger.Warn("Failed to load audit chain head", "error", err, "client_id", clientID)
+		return
+	}
+	history, err := m.verifyHistory(clientID, "")
+	if err != nil {
+		logger.Warn("Audit chain verification failed to run", "error", err, "client_id", clientID)
+		return
+	}
+
+	now := time.Now()
+	_, err = m.db.Collection(auditChainStatusCollection).UpdateOne(ctx,
+		bson.M{"_id": clientID},
+		bson.M{
+			"$set": bson.M{
+				"valid":               true,
+				"last_verified_at":    now,
+				"baseline":            baseline,
+				"history":             history,
+				"history_verified_at": now,
+			},
+			"$unset": bson.M{"error": "", "alerted_at": ""},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		logger.Warn("Failed to record audit chain baseline", "error", err, "client_id", clientID)
+		return
+	}
+	if history == auditHistoryUnverifiable {
+		logger.Warn("Audit chain history predates verification and does not verify", "client_id", clientID)
+	}
+	logger.Info("Recorded audit chain baseline", "client_id", clientID, "event_id", baseline.EventID, "history", history)
+}
+
+// raiseTamperAlert stores a critical suspicious activity alert and emails the platform admins
+func (m *AuditChainMonitor) raiseTamperAlert(ctx context.Context, clientID string, detectedAt time.Time) {
+	logger.Error("Audit chain failed verification - possible tampering", "client_id", clientID)
+
+	message := fmt.Sprintf("Audit log hash chain for client %s failed verification at %s. Entries may have been modified or deleted.",
+		clientID, detectedAt.UTC().Format(time.RFC3339))
+
+	alert := bson.M{
+		"alert_type": "audit_chain_tampered",
+		"severity":   "critical",
+		"message":    message,
+		"resolved":   false,
+		"created_at": detectedAt,
+	}
+	if oid, err := primitive.ObjectIDFromHex(clientID); err == nil {
+		alert["client_id"] = oid
+	}
+	if _, err := m.db.Collection("suspicious_activity_alerts").InsertOne(ctx, alert); err != nil {
+		logger.Warn("Failed to store audit tamper alert", "error", err, "client_id", clientID)
+	}
+
+	m.db.Collection(auditChainStatusCollection).UpdateOne(ctx, bson.M{"_id": clientID}, bson.M{
+		"$set": bson.M{"alerted_at": detectedAt},
+	})
+
+	var recipients []string
+	for _, email := range m.cfg.AdminEmails {
+		if email = strings.TrimSpace(email); email != "" {
+			recipients = append(recipients, email)
+		}
+	}
+	if m.cfg.SMTPHost == "" || len(recipients) == 0 {
+		return
+	}
+	subject := "🚨 Audit log tampering detected"
+	htmlBody := fmt.Sprintf("<p>%s</p><p>Run <code>GET /api/admin/audit/verify/%s</code> to re-check the chain.</p>", message, clientID)
+	if err := m.emailSender.SendEmail(recipients, subject, htmlBody, message); err != nil {
+		logger.Warn("Failed to email audit tamper alert", "error", err, "client_id", clientID)
+	}
+}
+
+// auditChainHealth summarises the latest scheduled verification results for the audit stats endpoint
+func auditChainHealth(ctx context.Context, db *mongo.Database) (map[string]interface{}, error) {
+	cursor, err := db.Collection(auditChainStatusCollection).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.M{"last_verified_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var statuses []models.AuditChainStatus
+	if err := cursor.All(ctx, &statuses); err != nil {
+		return nil, err
+	}
+
+	invalid := []models.AuditChainStatus{}
+	unverifiable := []string{}
+	var lastVerifiedAt *time.Time
+	for i, status := range statuses {
+		if i == 0 {
+			lastVerifiedAt = &statuses[0].LastVerifiedAt
+		}
+		if !status.Valid {
+			invalid = append(invalid, status)
+		}
+		if status.History == auditHistoryUnverifiable {
+			unverifiable = append(unverifiable, status.ClientID)
+		}
+	}
+
+	return map[string]interface{}{
+		"healthy":          len(invalid) == 0,
+		"verified_clients": len(statuses),
+		"invalid_clients":  len(invalid),
+		"last_verified_at": lastVerifiedAt,
+		"invalid":          invalid,
+		// Clients whose history before monitoring started doesn't verify; only newer events are checked
+		"legacy_unverifiable_clients": unverifiable,
+	}, nil
+}
@@ -0,0 +1,242 @@
+package routes
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// SIEM AUDIT EXPORT
+// ===================
+//
+// GET /api/admin/audit/export?format=ndjson|cef streams the matching entries for SIEM ingestion
+// (format=json, the default, keeps the single JSON document capped at 10k entries). Streamed exports
+// have no size cap and are ordered by client, then timestamp, so each client's hash chain is contiguous.
+// Every line carries previous_hash and current_hash: a consumer can recompute current_hash as
+// hex(sha256(timestamp|client_id|user_id|action|resource|resource_id|success|previous_hash)), with the
+// timestamp in RFC3339Nano, and check that previous_hash equals the current_hash of the line before.
+//
+// cef lines are RFC 5424 syslog messages (facility log audit) with a CEF:0 payload; the chain fields are
+// in cs4 (previous hash) and cs5 (current hash).
+
+const (
+	auditExportFormatJSON   = "json"
+	auditExportFormatNDJSON = "ndjson"
+	auditExportFormatCEF    = "cef"
+)
+
+// auditExportFlushEvery is how many lines are buffered before they are flushed to the client
+const auditExportFlushEvery = 500
+
+// auditExportRecord is the NDJSON line for one audit entry
+type auditExportRecord struct {
+	ID           string                 `json:"id"`
+	Timestamp    string                 `json:"timestamp"` // RFC3339Nano, exactly as hashed
+	ClientID     string                 `json:"client_id"`
+	UserID       string                 `json:"user_id"`
+	Action       string                 `json:"action"`
+	Resource     string                 `json:"resource"`
+	ResourceID   string                 `json:"resource_id"`
+	IPAddress    string                 `json:"ip_address"`
+	UserAgent    string                 `json:"user_agent"`
+	RequestID    string                 `json:"request_id"`
+	Success      bool                   `json:"success"`
+	ErrorMessage string                 `json:"error_message,omitempty"`
+	Changes      map[string]interface{} `json:"changes,omitempty"`
+	PreviousHash string                 `json:"previous_hash"`
+	CurrentHash  string                 `json:"current_hash"`
+	HashAlg      string                 `json:"hash_alg"`
+}
+
+func newAuditExportRecord(event *models.AuditEvent) auditExportRecord {
+	return auditExportRecord{
+		ID:           event.ID,
+		Timestamp:    event.Timestamp.Format(time.RFC3339Nano),
+		ClientID:     event.ClientID,
+		UserID:       event.UserID,
+		Action:       event.Action,
+		Resource:     event.Resource,
+		ResourceID:   event.ResourceID,
+		IPAddress:    event.IPAddress,
+		UserAgent:    event.UserAgent,
+		RequestID:    event.RequestID,
+		Success:      event.Success,
+		ErrorMessage: event.ErrorMessage,
+		Changes:      event.Changes,
+		PreviousHash: event.PreviousHash,
+		CurrentHash:  event.CurrentHash,
+		HashAlg:      "sha256",
+	}
+}
+
+// cefHeaderEscape escapes a CEF header field (pipes and backslashes)
+func cefHeaderEscape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// cefExtensionEscape escapes a CEF extension value (backslashes, equals signs and line breaks)
+func cefExtensionEscape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "=", `\=`)
+	value = strings.ReplaceAll(value, "\r", `\r`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+// auditCEFSeverity maps an entry to a CEF severity (0-10) and a syslog severity
+func auditCEFSeverity(event *models.AuditEvent) (int, int) {
+	switch {
+	case !event.Success:
+		return 7, 4 // warning
+	case event.Action == "DELETE":
+		return 5, 5 // notice
+	default:
+		return 3, 6 // informational
+	}
+}
+
+// formatAuditCEF renders one entry as an RFC 5424 syslog line with a CEF payload
+func formatAuditCEF(event *models.AuditEvent, hostname string) string {
+	cefSeverity, syslogSeverity := auditCEFSeverity(event)
+	const facilityLogAudit = 13
+
+	outcome := "success"
+	if !event.Success {
+		outcome = "failure"
+	}
+
+	extension := [][2]string{
+		{"rt", strconv.FormatInt(event.Timestamp.UnixMilli(), 10)},
+		{"externalId", event.ID},
+		{"act", event.Action},
+		{"outcome", outcome},
+		{"suser", event.UserID},
+		{"src", event.IPAddress},
+		{"requestClientApplication", event.UserAgent},
+		{"cs1Label", "clientId"},
+		{"cs1", event.ClientID},
+		{"cs2Label", "resource"},
+		{"cs2", event.Resource},
+		{"cs3Label", "resourceId"},
+		{"cs3", event.ResourceID},
+		{"cs4Label", "previousHash"},
+		{"cs4", event.PreviousHash},
+		{"cs5Label", "currentHash"},
+		{"cs5", event.CurrentHash},
+		{"cs6Label", "requestId"},
+		{"cs6", event.RequestID},
+	}
+	if event.ErrorMessage != "" {
+		extension = append(extension, [2]string{"msg", event.ErrorMessage})
+	}
+	if len(event.Changes) > 0 {
+		if changes, err := json.Marshal(event.Changes); err == nil {
+			extension = append(extension, [2]string{"cs7Label", "changes"}, [2]string{"cs7", string(changes)})
+		}
+	}
+
+	var ext strings.Builder
+	for _, kv := range extension {
+		if kv[1] == "" {
+			continue
+		}
+		if ext.Len() > 0 {
+			ext.WriteByte(' ')
+		}
+		ext.WriteString(kv[0])
+		ext.WriteByte('=')
+		ext.WriteString(cefExtensionEscape(kv[1]))
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s chatbot-platform - - - CEF:0|SaaS Chatbot Platform|Chatbot Backend|1.0|%s|%s|%d|%s",
+		facilityLogAudit*8+syslogSeverity,
+		event.Timestamp.UTC().Format(time.RFC3339Nano),
+		hostname,
+		cefHeaderEscape(event.Action+":"+event.Resource),
+		cefHeaderEscape(strings.ToLower(event.Action)+" "+event.Resource),
+		cefSeverity,
+		ext.String(),
+	)
+}
+
+// streamAuditExport writes the matching entries as NDJSON or CEF lines without loading them into memory
+func streamAuditExport(c *gin.Context, auditor *models.AuditLogger, filter bson.M, format string) {
+	ctx := c.Request.Context()
+	// Chain order, so each line's previous_hash can be checked against the line before
+	cursor, err := auditor.Collection().Find(ctx, filter,
+		options.Find().SetSort(models.ClientChainOrder()),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error_code": "export_failed",
+			"message":    "Failed to export audit logs",
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	filename := "audit_logs_" + time.Now().Format("20060102_150405")
+	if format == auditExportFormatCEF {
+		filename += ".cef.log"
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		filename += ".ndjson"
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+
+	writer := bufio.NewWriter(c.Writer)
+	encoder := json.NewEncoder(writer)
+	count := 0
+	for cursor.Next(ctx) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			logger.Error("Failed to decode audit entry for export", "error", err)
+			break
+		}
+
+		if format == auditExportFormatCEF {
+			_, err = writer.WriteString(formatAuditCEF(&event, hostname) + "\n")
+		} else {
+			err = encoder.Encode(newAuditExportRecord(&event))
+		}
+		if err != nil {
+			// The client went away; nothing more can be sent
+			return
+		}
+
+		count++
+		if count%auditExportFlushEvery == 0 {
+			if writer.Flush() != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		logger.Error("Audit export stopped early", "error", err, "exported", count)
+	}
+	if writer.Flush() == nil {
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"saas-chatbot-platform/models"
+)
+
+func TestFormatAuditCEF(t *testing.T) {
+	event := &models.AuditEvent{
+		ID:           "evt1",
+		Timestamp:    time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		ClientID:     "c1",
+		UserID:       "admin",
+		Action:       "DELETE",
+		Resource:     "pdf|doc",
+		Success:      false,
+		ErrorMessage: "a=b\nc\\d",
+		PreviousHash: "prev",
+		CurrentHash:  "curr",
+	}
+
+	line := formatAuditCEF(event, "host1")
+	if strings.Contains(line, "\n") {
+		t.Fatalf("line must not contain a newline: %q", line)
+	}
+	for _, want := range []string{
+		"<108>1 2026-03-01T12:00:00Z host1 chatbot-platform - - - CEF:0|",
+		`|DELETE:pdf\|doc|delete pdf\|doc|7|`,
+		"rt=1772366400000 ",
+		"outcome=failure",
+		"cs4Label=previousHash cs4=prev cs5Label=currentHash cs5=curr",
+		`msg=a\=b\nc\\d`,
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("missing %q in %q", want, line)
+		}
+	}
+	if strings.Contains(line, "cs3=") {
+		t.Errorf("empty values should be omitted: %q", line)
+	}
+}
+
+func TestAuditExportRecordKeepsHashInput(t *testing.T) {
+	event := &models.AuditEvent{
+		Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 123000000, time.UTC),
+		ClientID:  "c1",
+		Action:    "CREATE",
+		Success:   true,
+	}
+	event.CurrentHash = event.ComputeHash()
+
+	record := newAuditExportRecord(event)
+	parsed, err := time.Parse(time.RFC3339Nano, record.Timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := &models.AuditEvent{Timestamp: parsed, ClientID: record.ClientID, Action: record.Action, Success: record.Success}
+	if rebuilt.ComputeHash() != record.CurrentHash {
+		t.Errorf("hash not reproducible from the exported fields")
+	}
+}
@@ -0,0 +1,342 @@
+package routes
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// AUDIT LOG RETENTION
+// ===================
+//
+// Entries older than the retention period are first written to a JSON Lines file signed with
+// AUDIT_ARCHIVE_SIGNING_KEY (the signature is stored next to the file as <file>.sig and on the
+// audit_archives record). Only then are they deleted. For every client an anchor holding the hash
+// of its last archived entry is stored so the remaining chain still verifies.
+//
+// The archive file is written to local disk only (AUDIT_ARCHIVE_DIR). It is not cold storage: the
+// file and its .sig must be copied off the host (object storage, backup) before the purged entries
+// count as retained, otherwise losing the disk loses the history.
+
+// minAuditRetentionDays stops an accidental purge of recent audit history
+const minAuditRetentionDays = 30
+
+// auditArchiveMu serialises archive runs within this process
+var auditArchiveMu sync.Mutex
+
+// auditArchiveClient tracks the last exported entry of one client's chain
+type auditArchiveClient struct {
+	lastHash      string
+	lastTimestamp time.Time
+}
+
+// auditArchiveDir returns the local directory signed audit exports are written to; files there
+// must be shipped to off-host storage by the deployment
+func auditArchiveDir(cfg *config.Config) string {
+	if cfg.AuditArchiveDir != "" {
+		return cfg.AuditArchiveDir
+	}
+	baseDir := cfg.FileStorageDir
+	if baseDir == "" {
+		baseDir = "./storage"
+	}
+	return filepath.Join(baseDir, "audit_archives")
+}
+
+// exportAuditEntries writes every entry older than cutoff to a signed JSON Lines file and returns the
+// archive record (not yet stored) with the last exported entry of each client
+func exportAuditEntries(ctx context.Context, cfg *config.Config, auditor *models.AuditLogger, cutoff time.Time) (*models.AuditArchive, map[string]*auditArchiveClient, error) {
+	dir := auditArchiveDir(cfg)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	archive := &models.AuditArchive{
+		ID:        primitive.NewObjectID(),
+		Cutoff:    cutoff,
+		Status:    "exported",
+		CreatedAt: time.Now(),
+	}
+	archive.FilePath = filepath.Join(dir, fmt.Sprintf("audit_%s_%s.jsonl", archive.CreatedAt.UTC().Format("20060102T150405Z"), archive.ID.Hex()))
+
+	cursor, err := auditor.Collection().Find(ctx,
+		bson.M{"timestamp": bson.M{"$lt": cutoff}},
+		options.Find().SetSort(models.ClientChainOrder()),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read audit entries: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	file, err := os.OpenFile(archive.FilePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create archive file: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(cfg.AuditArchiveSigningKey))
+	digest := sha256.New()
+	writer := bufio.NewWriter(io.MultiWriter(file, mac, digest))
+	encoder := json.NewEncoder(writer)
+
+	clients := make(map[string]*auditArchiveClient)
+	for cursor.Next(ctx) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to decode audit entry: %w", err)
+		}
+		if err := encoder.Encode(event); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to write archive: %w", err)
+		}
+		archive.EventCount++
+		clients[event.ClientID] = &auditArchiveClient{lastHash: event.CurrentHash, lastTimestamp: event.Timestamp}
+	}
+	if err := cursor.Err(); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to read audit entries: %w", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to sync archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to close archive: %w", err)
+	}
+
+	archive.Signature = hex.EncodeToString(mac.Sum(nil))
+	archive.SHA256 = hex.EncodeToString(digest.Sum(nil))
+	archive.ClientCount = len(clients)
+	if err := os.WriteFile(archive.FilePath+".sig", []byte(archive.Signature+"\n"), 0600); err != nil {
+		return nil, nil, fmt.Errorf("failed to write archive signature: %w", err)
+	}
+	return archive, clients, nil
+}
+
+// purgeArchivedAuditEntries anchors each client's chain at its last archived entry and deletes the archived entries
+func purgeArchivedAuditEntries(ctx context.Context, auditor *models.AuditLogger, archiveID primitive.ObjectID, clients map[string]*auditArchiveClient) (int64, error) {
+	var purged int64
+	for clientID, last := range clients {
+		// Anchor first: if the delete fails the verifier simply skips entries up to the anchor
+		_, err := auditor.AnchorCollection().UpdateOne(ctx, bson.M{"_id": clientID}, bson.M{
+			"$set": bson.M{
+				"hash":           last.lastHash,
+				"purged_through": last.lastTimestamp,
+				"archive_id":     archiveID,
+				"updated_at":     time.Now(),
+			},
+		}, options.Update().SetUpsert(true))
+		if err != nil {
+			return purged, fmt.Errorf("failed to anchor chain for client %q: %w", clientID, err)
+		}
+
+		result, err := auditor.Collection().DeleteMany(ctx, bson.M{
+			"client_id": clientID,
+			"timestamp": bson.M{"$lte": last.lastTimestamp},
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge entries for client %q: %w", clientID, err)
+		}
+		purged += result.DeletedCount
+	}
+	return purged, nil
+}
+
+// ArchiveAuditLogs exports audit entries past the retention period to a signed archive and purges them
+func ArchiveAuditLogs(cfg *config.Config, auditor *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if middleware.GetClientID(c) != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Audit archiving is only available to platform admins",
+			})
+			return
+		}
+		if cfg.AuditArchiveSigningKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "archiving_disabled",
+				"message":    "AUDIT_ARCHIVE_SIGNING_KEY must be set before audit logs can be archived",
+			})
+			return
+		}
+
+		retentionDays := cfg.AuditRetentionDays
+		if raw := c.Query("retention_days"); raw != "" {
+			days, err := strconv.Atoi(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_retention_days",
+					"message":    "retention_days must be a number",
+				})
+				return
+			}
+			retentionDays = days
+		}
+		if retentionDays < minAuditRetentionDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_retention_days",
+				"message":    fmt.Sprintf("retention_days must be at least %d", minAuditRetentionDays),
+			})
+			return
+		}
+
+		if !auditArchiveMu.TryLock() {
+			c.JSON(http.StatusConflict, gin.H{
+				"error_code": "archive_in_progress",
+				"message":    "An audit archive is already running",
+			})
+			return
+		}
+		defer auditArchiveMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Minute)
+		defer cancel()
+
+		cutoff := time.Now().UTC().AddDate(0, 0, -retentionDays)
+		archive, clients, err := exportAuditEntries(ctx, cfg, auditor, cutoff)
+		if err != nil {
+			logger.Error("Audit archive export failed", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "export_failed",
+				"message":    "Failed to export audit logs; nothing was purged",
+			})
+			return
+		}
+		if archive.EventCount == 0 {
+			os.Remove(archive.FilePath)
+			os.Remove(archive.FilePath + ".sig")
+			c.JSON(http.StatusOK, gin.H{
+				"message":        "No audit entries older than the retention period",
+				"retention_days": retentionDays,
+				"cutoff":         cutoff,
+			})
+			return
+		}
+
+		archive.CreatedBy = middleware.GetUserID(c)
+		archivesCollection := auditor.Collection().Database().Collection("audit_archives")
+		if _, err := archivesCollection.InsertOne(ctx, archive); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "export_failed",
+				"message":    "Failed to record audit archive; nothing was purged",
+			})
+			return
+		}
+
+		purged, err := purgeArchivedAuditEntries(ctx, auditor, archive.ID, clients)
+		archive.PurgedCount = purged
+		archive.Status = "purged"
+		if err != nil {
+			archive.Status = "purge_failed"
+			archive.ErrorMessage = err.Error()
+			logger.Error("Audit archive purge failed", "error", err, "archive_id", archive.ID.Hex(), "purged", purged)
+		}
+		archivesCollection.UpdateOne(ctx, bson.M{"_id": archive.ID}, bson.M{"$set": bson.M{
+			"purged_count":  archive.PurgedCount,
+			"status":        archive.Status,
+			"error_message": archive.ErrorMessage,
+		}})
+
+		auditor.Log(&models.AuditEvent{
+			UserID:     archive.CreatedBy,
+			Action:     "DELETE",
+			Resource:   "audit_logs",
+			ResourceID: archive.ID.Hex(),
+			IPAddress:  c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			RequestID:  middleware.GetRequestID(c),
+			Success:    err == nil,
+			Changes: map[string]interface{}{
+				"reason":         "retention_policy",
+				"retention_days": retentionDays,
+				"cutoff":         cutoff,
+				"exported_count": archive.EventCount,
+				"purged_count":   purged,
+				"signature":      archive.Signature,
+			},
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "purge_failed",
+				"message":    "Audit logs were exported but the purge did not complete; it is safe to retry",
+				"archive":    archive,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Audit logs archived and purged; copy the archive file and its .sig off this host",
+			"retention_days": retentionDays,
+			"archive":        archive,
+		})
+	}
+}
+
+// ListAuditArchives lists signed audit exports, newest first
+func ListAuditArchives(auditor *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if middleware.GetClientID(c) != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Audit archives are only available to platform admins",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := auditor.Collection().Database().Collection("audit_archives").Find(ctx, bson.M{},
+			options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(100))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to list audit archives",
+			})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		archives := []models.AuditArchive{}
+		if err := cursor.All(ctx, &archives); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to decode audit archives",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"archives": archives,
+			"total":    len(archives),
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================
+// BRANDING VALIDATION
+// ===================
+//
+// Branding values are written straight into the widget's CSS and markup, so colors must be real CSS
+// colors and asset URLs must be http(s) links or paths on this platform (uploaded media, /media/...).
+// Valid values are normalized in place: hex to lowercase #rrggbb (#rrggbbaa with alpha), rgb()/rgba()
+// to "rgb(r, g, b)" / "rgba(r, g, b, a)" and named colors to lowercase. Empty values are left empty so
+// the widget defaults apply.
+
+const maxBrandingURLLength = 2048
+
+var (
+	hexColorPattern  = regexp.MustCompile(`^#([0-9a-f]{3}|[0-9a-f]{4}|[0-9a-f]{6}|[0-9a-f]{8})$`)
+	rgbColorPattern  = regexp.MustCompile(`^rgba?\(\s*([^,\s]+)\s*,\s*([^,\s]+)\s*,\s*([^,\s]+)\s*(?:,\s*([^,\s]+)\s*)?\)$`)
+	cssNamedColorSet = make(map[string]bool, len(cssNamedColors))
+)
+
+// cssNamedColors are the CSS Color Module Level 4 named colors, plus "transparent"
+var cssNamedColors = []string{
+	"aliceblue", "antiquewhite", "aqua", "aquamarine", "azure", "beige", "bisque", "black", "blanchedalmond",
+	"blue", "blueviolet", "brown", "burlywood", "cadetblue", "chartreuse", "chocolate", "coral", "cornflowerblue",
+	"cornsilk", "crimson", "cyan", "darkblue", "darkcyan", "darkgoldenrod", "darkgray", "darkgreen", "darkgrey",
+	"darkkhaki", "darkmagenta", "darkolivegreen", "darkorange", "darkorchid", "darkred", "darksalmon",
+	"darkseagreen", "darkslateblue", "darkslategray", "darkslategrey", "darkturquoise", "darkviolet", "deeppink",
+	"deepskyblue", "dimgray", "dimgrey", "dodgerblue", "firebrick", "floralwhite", "forestgreen", "fuchsia",
+	"gainsboro", "ghostwhite", "gold", "goldenrod", "gray", "green", "greenyellow", "grey", "honeydew", "hotpink",
+	"indianred", "indigo", "ivory", "khaki", "lavender", "lavenderblush", "lawngreen", "lemonchiffon", "lightblue",
+	"lightcoral", "lightcyan", "lightgoldenrodyellow", "lightgray", "lightgreen", "lightgrey", "lightpink",
+	"lightsalmon", "lightseagreen", "lightskyblue", "lightslategray", "lightslategrey", "lightsteelblue",
+	"lightyellow", "lime", "limegreen", "linen", "magenta", "maroon", "mediumaquamarine", "mediumblue",
+	"mediumorchid", "mediumpurple", "mediumseagreen", "mediumslateblue", "mediumspringgreen", "mediumturquoise",
+	"mediumvioletred", "midnightblue", "mintcream", "mistyrose", "moccasin", "navajowhite", "navy", "oldlace",
+	"olive", "olivedrab", "orange", "orangered", "orchid", "palegoldenrod", "palegreen", "paleturquoise",
+	"palevioletred", "papayawhip", "peachpuff", "peru", "pink", "plum", "powderblue", "purple", "rebeccapurple",
+	"red", "rosybrown", "royalblue", "saddlebrown", "salmon", "sandybrown", "seagreen", "seashell", "sienna",
+	"silver", "skyblue", "slateblue", "slategray", "slategrey", "snow", "springgreen", "steelblue", "tan", "teal",
+	"thistle", "tomato", "turquoise", "violet", "wheat", "white", "whitesmoke", "yellow", "yellowgreen",
+	"transparent",
+}
+
+func init() {
+	for _, name := range cssNamedColors {
+		cssNamedColorSet[name] = true
+	}
+}
+
+// normalizeCSSColor returns value in canonical form, or an error when it isn't a hex, rgb(a) or named color
+func normalizeCSSColor(value string) (string, error) {
+	color := strings.ToLower(strings.TrimSpace(value))
+	if color == "" {
+		return "", nil
+	}
+
+	if hexColorPattern.MatchString(color) {
+		digits := color[1:]
+		if len(digits) == 3 || len(digits) == 4 {
+			expanded := make([]byte, 0, len(digits)*2)
+			for i := 0; i < len(digits); i++ {
+				expanded = append(expanded, digits[i], digits[i])
+			}
+			digits = string(expanded)
+		}
+		if strings.HasSuffix(digits, "ff") && len(digits) == 8 {
+			digits = digits[:6]
+		}
+		return "#" + digits, nil
+	}
+
+	if m := rgbColorPattern.FindStringSubmatch(color); m != nil {
+		channels := make([]int, 3)
+		for i := 0; i < 3; i++ {
+			channel, err := strconv.Atoi(m[i+1])
+			if err != nil || channel < 0 || channel > 255 {
+				return "", fmt.Errorf("rgb channels must be whole numbers from 0 to 255")
+			}
+			channels[i] = channel
+		}
+		if m[4] == "" {
+			return fmt.Sprintf("rgb(%d, %d, %d)", channels[0], channels[1], channels[2]), nil
+		}
+		alpha, err := strconv.ParseFloat(m[4], 64)
+		if err != nil || alpha < 0 || alpha > 1 {
+			return "", fmt.Errorf("alpha must be a number from 0 to 1")
+		}
+		return fmt.Sprintf("rgba(%d, %d, %d, %s)", channels[0], channels[1], channels[2], strconv.FormatFloat(alpha, 'f', -1, 64)), nil
+	}
+
+	if cssNamedColorSet[color] {
+		return color, nil
+	}
+	return "", fmt.Errorf("must be a hex (#rrggbb), rgb()/rgba() or named CSS color")
+}
+
+// normalizeBrandingURL returns value as a well-formed http(s) URL or root-relative path, or an error
+func normalizeBrandingURL(value string) (string, error) {
+	raw := strings.TrimSpace(value)
+	if raw == "" {
+		return "", nil
+	}
+	if len(raw) > maxBrandingURLLength {
+		return "", fmt.Errorf("must be at most %d characters", maxBrandingURLLength)
+	}
+	if strings.ContainsAny(raw, " \t\r\n\"'<>\\`") {
+		return "", fmt.Errorf("must not contain spaces, quotes or angle brackets")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("is not a valid URL")
+	}
+	// Uploaded media is served by the platform itself ("/media/<client>/<file>")
+	if u.Scheme == "" && u.Host == "" && strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//") {
+		return u.String(), nil
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("must use http or https")
+	}
+	if u.Host == "" || u.User != nil {
+		return "", fmt.Errorf("must be an absolute URL with a host and no credentials")
+	}
+	return u.String(), nil
+}
+
+// normalizeBranding validates and normalizes branding's color, URL and company name fields in place. It returns the
+// problems keyed by JSON field name, or nil when everything is valid.
+func normalizeBranding(branding *models.Branding) map[string]string {
+	fieldErrors := make(map[string]string)
+
+	colors := []struct {
+		field string
+		value *string
+	}{
+		{"theme_color", &branding.ThemeColor},
+		{"launcher_color", &branding.LauncherColor},
+		{"launcher_icon_color", &branding.LauncherIconColor},
+		{"cancel_icon_color", &branding.CancelIconColor},
+	}
+	for _, color := range colors {
+		normalized, err := normalizeCSSColor(*color.value)
+		if err != nil {
+			fieldErrors[color.field] = err.Error()
+			continue
+		}
+		*color.value = normalized
+	}
+
+	urls := []struct {
+		field string
+		value *string
+	}{
+		{"logo_url", &branding.LogoURL},
+		{"launcher_image_url", &branding.LauncherImageURL},
+		{"launcher_video_url", &branding.LauncherVideoURL},
+		{"launcher_svg_url", &branding.LauncherSVGURL},
+		{"cancel_image_url", &branding.CancelImageURL},
+	}
+	for _, link := range urls {
+		normalized, err := normalizeBrandingURL(*link.value)
+		if err != nil {
+			fieldErrors[link.field] = err.Error()
+			continue
+		}
+		*link.value = normalized
+	}
+
+	if name, err := normalizeCompanyName(branding.DisplayCompanyName); err != nil {
+		fieldErrors["display_company_name"] = err.Error()
+	} else {
+		branding.DisplayCompanyName = name
+	}
+
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+	return fieldErrors
+}
+
+// respondToInvalidBranding writes the 400 response listing each invalid branding field
+func respondToInvalidBranding(c *gin.Context, fieldErrors map[string]string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error_code": "invalid_branding",
+		"message":    "Some branding fields are invalid",
+		"fields":     fieldErrors,
+	})
+}
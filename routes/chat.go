@@ -2,11 +2,13 @@ package routes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"saas-chatbot-platform/internal/ai"
 	"saas-chatbot-platform/internal/config"
 	"saas-chatbot-platform/middleware"
 	"saas-chatbot-platform/models"
@@ -19,7 +21,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func SetupChatRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.Client, authMiddleware *middleware.AuthMiddleware) {
+func SetupChatRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.Client, authMiddleware *middleware.AuthMiddleware, aiPool *ai.ClientPool) {
 	chat := router.Group("/chat")
 	chat.Use(authMiddleware.RequireAuth())
 
@@ -121,7 +123,7 @@ func SetupChatRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.
 		}
 
 		// ✅ CHECK CLIENT STATUS - If inactive or suspended, block chat
-		if clientDoc.Status == "inactive" || clientDoc.Status == "suspended" {
+		if clientChatDisabled(clientDoc) {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error_code": "client_inactive",
 				"message":    fmt.Sprintf("Client account '%s' is not active. Status: %s", clientDoc.Name, clientDoc.Status),
@@ -131,6 +133,7 @@ func SetupChatRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.
 
 		// ✅ CHECK TOKEN BUDGET
 		if clientDoc.TokenUsed >= clientDoc.TokenLimit {
+			recordTokenLimitRejection(cfg, clientsCollection, targetClientID, "dashboard")
 			c.JSON(http.StatusPaymentRequired, gin.H{
 				"error_code": "token_limit_exceeded",
 				"message": fmt.Sprintf("Token limit exceeded for %s. Used: %d, Limit: %d",
@@ -153,10 +156,24 @@ func SetupChatRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.
 		}
 
 		// ✅ USE AI SYSTEM from Client.go - generateAIResponseWithMemory
-		aiResponse, tokenCost, latency, err := generateAIResponseWithMemory(
-			ctx, cfg, db, pdfsCollection, messagesCollection, crawlsCollection, clientDoc, req.Message, conversationID)
+		aiResponse, tokenCost, latency, meta, err := generateAIResponseWithMemory(
+			ctx, cfg, aiPool, db, pdfsCollection, messagesCollection, crawlsCollection, clientDoc, req.Message, conversationID, nil, false, personaHint{})
 
 		if err != nil {
+			if errors.Is(err, errContentBlocked) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error_code": "content_blocked",
+					"message":    "The response was blocked by the client's safety settings",
+				})
+				return
+			}
+			if errors.Is(err, errAITimeout) {
+				c.JSON(http.StatusGatewayTimeout, gin.H{
+					"error_code": "ai_timeout",
+					"message":    "The AI took too long to respond. Please try again.",
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "ai_generation_error",
 				"message":    "Failed to generate AI response",
@@ -167,6 +184,7 @@ func SetupChatRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.
 
 		// ✅ VALIDATE TOKEN BUDGET with actual cost
 		if clientDoc.TokenUsed+tokenCost > clientDoc.TokenLimit {
+			recordTokenLimitRejection(cfg, clientsCollection, targetClientID, "dashboard")
 			c.JSON(http.StatusPaymentRequired, gin.H{
 				"error_code":       "insufficient_tokens",
 				"message":          fmt.Sprintf("Insufficient tokens for %s", clientDoc.Name),
@@ -189,6 +207,12 @@ func SetupChatRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.
 			TokenCost:      tokenCost,
 			UserName:       user.Username, // ✅ Store username
 			UserEmail:      user.Email,    // ✅ Store email
+			Channel:        "dashboard",
+		}
+		if meta != nil {
+			message.SourceAttribution = meta.SourceAttribution
+			message.Confidence = meta.Confidence
+			message.DemoBooking = meta.DemoBooking
 		}
 
 		_, err = messagesCollection.InsertOne(context.Background(), message)
@@ -207,18 +231,8 @@ func SetupChatRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.
 			return
 		}
 
-		// TRIGGER REAL-TIME ALERT EVALUATION
-		// go func() {
-		//     alertCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		//     defer cancel()
-
-		//	    // Get alert evaluator from global service (implement service registry)
-		//	    if alertEvaluator := getAlertEvaluator(); alertEvaluator != nil {
-		//	        if err := alertEvaluator.EvaluateAndNotify(alertCtx, targetClientID); err != nil {
-		//	            log.Printf("Failed to evaluate alerts for client %s: %v", targetClientID.Hex(), err)
-		//	        }
-		//	    }
-		//	}()
+		// ✅ NEW: Email a usage alert as soon as this reply crosses a threshold (async)
+		evaluateTokenAlerts(cfg, clientsCollection, targetClientID, clientDoc.TokenUsed+tokenCost, clientDoc.TokenLimit)
 
 		// Calculate remaining tokens AFTER database update
 		remainingTokens := clientDoc.TokenLimit - (clientDoc.TokenUsed + tokenCost)
@@ -425,10 +439,10 @@ func getDefaultClientID(collection *mongo.Collection) primitive.ObjectID {
 
 // Helper function to get relevant context from PDFs (already exists in your current chat.go)
 func getRelevantContext(pdfsCollection *mongo.Collection, clientID primitive.ObjectID, query string, maxChunks int) ([]string, error) {
-	// Get all PDFs for the client
+	// Get the client's active, processed PDFs
 	cursor, err := pdfsCollection.Find(
 		context.Background(),
-		bson.M{"client_id": clientID},
+		retrievablePDFFilter(clientID),
 	)
 	if err != nil {
 		return nil, err
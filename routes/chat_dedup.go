@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// PUBLIC CHAT DEDUPLICATION
+// ===================
+
+// chatDedupEntry tracks one in-flight or recently answered public chat message
+type chatDedupEntry struct {
+	done    chan struct{}
+	body    gin.H
+	expires time.Time
+}
+
+// chatDeduplicator collapses identical messages sent to the same session within a short
+// window (e.g. double-clicks on the widget's send button) into a single AI call
+type chatDeduplicator struct {
+	mu        sync.Mutex
+	entries   map[string]*chatDedupEntry
+	lastSweep time.Time
+}
+
+var publicChatDedup = &chatDeduplicator{entries: make(map[string]*chatDedupEntry)}
+
+// chatDedupKey identifies a message by client, session and trimmed text
+func chatDedupKey(clientID, sessionID, message string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(message)))
+	return clientID + "|" + sessionID + "|" + hex.EncodeToString(sum[:])
+}
+
+// acquire registers key and returns (entry, true) when the caller should generate the reply,
+// or the existing entry and false when the message is a duplicate of one already in flight
+// or answered within window
+func (d *chatDeduplicator) acquire(key string, window time.Duration) (*chatDedupEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(d.lastSweep) > window {
+		for k, e := range d.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(d.entries, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if existing, ok := d.entries[key]; ok && (existing.expires.IsZero() || now.Before(existing.expires)) {
+		return existing, false
+	}
+
+	entry := &chatDedupEntry{done: make(chan struct{})}
+	d.entries[key] = entry
+	return entry, true
+}
+
+// finish publishes the reply to waiting duplicates. A nil body means generation failed:
+// the entry is dropped so that the next attempt is answered normally.
+func (d *chatDeduplicator) finish(key string, entry *chatDedupEntry, body gin.H, window time.Duration) {
+	if entry == nil {
+		return
+	}
+
+	d.mu.Lock()
+	if body == nil {
+		if d.entries[key] == entry {
+			delete(d.entries, key)
+		}
+	} else {
+		entry.body = body
+		entry.expires = time.Now().Add(window)
+	}
+	d.mu.Unlock()
+
+	close(entry.done)
+}
+
+// wait blocks until the original request finishes and returns its reply, if it succeeded
+func (e *chatDedupEntry) wait(ctx context.Context) (gin.H, bool) {
+	select {
+	case <-e.done:
+		return e.body, e.body != nil
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// duplicateChatResponse marks a prior reply as a duplicate; no tokens are charged for it
+func duplicateChatResponse(body gin.H) gin.H {
+	response := make(gin.H, len(body)+1)
+	for k, v := range body {
+		response[k] = v
+	}
+	response["token_cost"] = 0
+	response["duplicate"] = true
+	return response
+}
+
+// findRecentDuplicateMessage returns the persisted message with the same text in the session
+// within window, covering duplicates answered by another instance
+func findRecentDuplicateMessage(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, sessionID, message string, window time.Duration) *models.Message {
+	var prior models.Message
+	err := messagesCollection.FindOne(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": sessionID,
+		"message":         message,
+		"timestamp":       bson.M{"$gte": time.Now().Add(-window)},
+	}, options.FindOne().SetSort(bson.M{"timestamp": -1})).Decode(&prior)
+	if err != nil {
+		return nil
+	}
+	return &prior
+}
@@ -3,9 +3,12 @@ package routes
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/mail"
 	"net/url"
 	"os"
 	"os/exec"
@@ -14,21 +17,27 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"saas-chatbot-platform/internal/ai"
 	"saas-chatbot-platform/internal/auth"
 	"saas-chatbot-platform/internal/config"
 	"saas-chatbot-platform/internal/crawler"
+	"saas-chatbot-platform/internal/logger"
 	"saas-chatbot-platform/middleware"
 	"saas-chatbot-platform/models"
 	"saas-chatbot-platform/services"
 	"saas-chatbot-platform/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/generative-ai-go/genai"
 	"github.com/google/uuid"
 	"github.com/ledongthuc/pdf"
+	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -62,9 +71,20 @@ type ChatRequest struct {
 	ClientID  string `json:"client_id" binding:"required"`
 	Message   string `json:"message" binding:"required"`
 	SessionID string `json:"session_id" binding:"required"`
-}
-
-func SetupClientRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.Client, authMiddleware *middleware.AuthMiddleware, roleMiddleware *middleware.RoleMiddleware) {
+	// ✅ NEW: Set when the message came from clicking a configured pre-question
+	PreQuestionID string `json:"pre_question_id,omitempty"`
+	// ✅ NEW: Persona picked in the widget's selector ("default" returns to the client's own persona)
+	PersonaID string `json:"persona_id,omitempty"`
+	// ✅ NEW: Signed facts about the user from the host site (see signed_context.go)
+	Context          string `json:"context,omitempty"`
+	ContextSignature string `json:"context_signature,omitempty"`
+	// ✅ NEW: Also return the reply as {reply, intent, entities, suggested_actions}
+	StructuredOutput bool `json:"structured_output,omitempty"`
+	// ✅ NEW: Whether the visitor accepted tracking (only consulted when the client requires consent)
+	TrackingConsent *bool `json:"tracking_consent,omitempty"`
+}
+
+func SetupClientRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo.Client, rdb *redis.Client, authMiddleware *middleware.AuthMiddleware, roleMiddleware *middleware.RoleMiddleware, auditLogger *models.AuditLogger, aiPool *ai.ClientPool) {
 	client := router.Group("/client")
 	client.Use(authMiddleware.RequireAuth())
 	client.Use(roleMiddleware.ClientGuard())
@@ -73,16 +93,22 @@ func SetupClientRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mong
 	clientsCollection := db.Collection("clients")
 	pdfsCollection := db.Collection("pdfs")
 	messagesCollection := db.Collection("messages")
+
+	// ✅ NEW: In-memory client config cache
+	sharedClientConfigCache.setTTL(time.Duration(cfg.ClientConfigCacheTTL) * time.Second)
 	crawlsCollection := db.Collection("crawls")
 	imagesCollection := db.Collection("images")
 	facebookPostsCollection := db.Collection("facebook_posts")
 	instagramPostsCollection := db.Collection("instagram_posts")
 
+	// ✅ NEW: Enforce the client's enabled features on gated routes, not just in the dashboard
+	client.Use(middleware.NewFeatureCheckMiddleware(clientsCollection).EnforceRouteFeatures(middleware.ClientRouteFeatures))
+
 	// Public routes (no authentication required)
-	setupPublicRoutes(router, cfg, db, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, imagesCollection, facebookPostsCollection, instagramPostsCollection)
+	setupPublicRoutes(router, cfg, aiPool, db, rdb, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, imagesCollection, facebookPostsCollection, instagramPostsCollection)
 
 	// Authenticated client routes
-	setupAuthenticatedRoutes(client, cfg, db, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, imagesCollection, facebookPostsCollection, instagramPostsCollection)
+	setupAuthenticatedRoutes(client, cfg, db, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, imagesCollection, facebookPostsCollection, instagramPostsCollection, auditLogger)
 	
 	// Client permissions endpoint - Get current client's permissions
 	client.GET("/permissions", func(c *gin.Context) {
@@ -158,13 +184,16 @@ func SetupClientRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mong
 }
 
 // setupPublicRoutes configures public endpoints for embedded widgets
-func setupPublicRoutes(router *gin.Engine, cfg *config.Config, db *mongo.Database, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, imagesCollection, facebookPostsCollection, instagramPostsCollection *mongo.Collection) {
+func setupPublicRoutes(router *gin.Engine, cfg *config.Config, aiPool *ai.ClientPool, db *mongo.Database, rdb *redis.Client, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, imagesCollection, facebookPostsCollection, instagramPostsCollection *mongo.Collection) {
 	// Initialize domain auth middleware
 	alertsCollection := clientsCollection.Database().Collection("suspicious_activity_alerts")
-	domainAuthMiddleware := middleware.NewDomainAuthMiddleware(clientsCollection, alertsCollection)
+	domainAuthMiddleware := middleware.NewDomainAuthMiddleware(clientsCollection, alertsCollection).WithEmbedSigningSecret(cfg.EmbedSigningSecret)
 
 	// Public: branding for embed widget (no auth)
-	router.GET("/public/branding/:client_id", handlePublicBranding(clientsCollection))
+	router.GET("/public/branding/:client_id", domainAuthMiddleware.CheckDomainAuthorization(), handlePublicBranding(cfg, clientsCollection))
+
+	// ✅ NEW: Public: recent response latency for the widget's typing indicator (no auth)
+	router.GET("/public/latency/:client_id", handlePublicLatencyEstimate(db))
 
 	// Public: images for embed widget (no auth)
 	router.GET("/public/images/:client_id", handlePublicImages(imagesCollection))
@@ -177,10 +206,17 @@ func setupPublicRoutes(router *gin.Engine, cfg *config.Config, db *mongo.Databas
 
 	// Public: WhatsApp QR Code config for embed widget (no auth)
 	router.GET("/public/whatsapp-qr-code/:client_id", handlePublicWhatsAppQRCode(clientsCollection))
+	// ✅ NEW: Public: WhatsApp click-to-chat deep link for an embed conversation
+	router.POST("/public/whatsapp-handoff/:client_id", domainAuthMiddleware.CheckDomainAuthorization(), handlePublicWhatsAppHandoff(clientsCollection, messagesCollection))
 
 	// Public: Telegram QR Code config for embed widget (no auth)
 	router.GET("/public/telegram-qr-code/:client_id", handlePublicTelegramQRCode(clientsCollection))
 
+	// ✅ NEW: Telegram bot webhook (authenticated by per-client secret token)
+	router.POST("/integrations/telegram/:client_id", handleTelegramWebhook(cfg, aiPool, db, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection))
+	// ✅ NEW: Google Sheets consent redirect (public, authenticated by the signed state)
+	router.GET(googleSheetsCallbackPath, handleGoogleSheetsCallback(cfg, db))
+
 	// Public: Facebook posts for embed widget (no auth)
 	router.GET("/public/facebook-posts/:client_id", handlePublicFacebookPosts(facebookPostsCollection))
 
@@ -197,49 +233,74 @@ func setupPublicRoutes(router *gin.Engine, cfg *config.Config, db *mongo.Databas
 	router.GET("/public/website-embed-config/:client_id", handlePublicWebsiteEmbedConfig(clientsCollection))
 
 	// Public: chat endpoint for embed widget (no auth) - with domain authorization
-	router.POST("/public/chat", domainAuthMiddleware.CheckDomainAuthorization(), handlePublicChat(cfg, db, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection))
+	router.POST("/public/chat", domainAuthMiddleware.CheckDomainAuthorization(), handlePublicChat(cfg, aiPool, db, rdb, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection))
 	// Public: quote/proposal endpoint for embed widget (no auth) - with domain authorization
 	router.POST("/public/quote/:client_id", domainAuthMiddleware.CheckDomainAuthorization(), handlePublicQuote(cfg, clientsCollection))
-	// ✅ Public: feedback endpoint for embed widget (no auth)
-	router.POST("/public/feedback/:message_id", handlePublicFeedback(cfg, db, messagesCollection))
+	// ✅ NEW: Public: continue a conversation on another device with a short-lived resume code
+	router.POST("/public/resume-code/:client_id", domainAuthMiddleware.CheckDomainAuthorization(), handleCreateResumeCode(cfg, rdb, messagesCollection))
+	router.POST("/public/resume/:client_id", domainAuthMiddleware.CheckDomainAuthorization(), handleRedeemResumeCode(cfg, rdb, messagesCollection))
+	// ✅ Public: feedback endpoint for embed widget (no auth) - one feedback per message per IP, capped per IP
+	router.POST("/public/feedback/:message_id", middleware.FeedbackRateLimit(rdb, cfg), handlePublicFeedback(cfg, db, messagesCollection))
 }
 
 // setupAuthenticatedRoutes configures routes that require authentication
-func setupAuthenticatedRoutes(client *gin.RouterGroup, cfg *config.Config, db *mongo.Database, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, imagesCollection, facebookPostsCollection, instagramPostsCollection *mongo.Collection) {
+func setupAuthenticatedRoutes(client *gin.RouterGroup, cfg *config.Config, db *mongo.Database, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, imagesCollection, facebookPostsCollection, instagramPostsCollection *mongo.Collection, auditLogger *models.AuditLogger) {
 	// Branding management
 	client.GET("/branding", handleGetBranding(clientsCollection))
 	client.POST("/branding", handleUpdateBranding(clientsCollection))
 
 	// PDF management
-	client.POST("/upload", handlePDFUpload(cfg, pdfsCollection))
+	client.POST("/upload", handlePDFUpload(cfg, clientsCollection, pdfsCollection, auditLogger))
 	client.GET("/pdfs", handleListPDFs(pdfsCollection))
 	client.GET("/pdfs/:id/status", handlePDFStatus(pdfsCollection))
+	client.GET("/pdfs/:id/preview", handlePDFPreview(pdfsCollection)) // ✅ NEW: extracted text sample and quality
 
 	// Embed chat history
 	client.GET("/embed-chat-history", handleEmbedChatHistory(messagesCollection))
 	client.GET("/embed-conversations/:id/messages", handleEmbedConversationMessages(messagesCollection))
+	// ✅ NEW: Conversation tagging
+	client.POST("/conversations/:session/tags", handleUpdateConversationTags(db))
+	// ✅ NEW: Reopen a conversation locked by contact collection
+	client.POST("/conversations/:session/reset-contact-state", handleResetContactState(messagesCollection, auditLogger))
+	// ✅ NEW: One visitor's conversations across sessions as a single timeline
+	client.GET("/customer-journey", handleCustomerJourney(messagesCollection))
 
 	// Token usage
-	client.GET("/tokens", handleGetTokens(clientsCollection))
+	client.GET("/tokens", handleGetTokens(cfg, clientsCollection, messagesCollection))
 
 	// Chat export functionality
 	client.POST("/export/chats", handleExportChats(messagesCollection, clientsCollection))
 	client.GET("/export/chats/download", handleDownloadExport(messagesCollection, clientsCollection))
+	client.GET("/export/defaults", handleGetExportDefaults(clientsCollection))    // ✅ NEW
+	client.PUT("/export/defaults", handleUpdateExportDefaults(clientsCollection)) // ✅ NEW
+	// ✅ NEW: Export one end user's data for a subject access request
+	client.POST("/privacy/export", handleSubjectDataExport(db, auditLogger))
+	// ✅ NEW: Delete one end user's data (right to be forgotten)
+	client.DELETE("/privacy/data", handleSubjectDataDeletion(db, auditLogger))
 
 	// ========== ADD THESE DELETE ROUTES ==========
-	client.DELETE("/pdfs/:id", handleDeletePDF(pdfsCollection)) // Single PDF delete
-	client.DELETE("/pdfs/bulk", handleBulkDeletePDFs(pdfsCollection))
+	client.DELETE("/pdfs/:id", handleDeletePDF(cfg, clientsCollection, pdfsCollection)) // Single PDF delete
+	client.DELETE("/pdfs/bulk", handleBulkDeletePDFs(cfg, clientsCollection, pdfsCollection))
 	// PATCH /client/pdfs/:id/status - Update PDF status
 	client.PATCH("/pdfs/:id/status", handleUpdatePDFStatus(pdfsCollection))
+	// ✅ NEW: PATCH /client/pdfs/status/bulk - Update the status of many PDFs at once
+	client.PATCH("/pdfs/status/bulk", handleBulkUpdatePDFStatus(pdfsCollection))
+	// ✅ NEW: PATCH /client/pdfs/:id/active - Include or exclude a PDF from answers
+	client.PATCH("/pdfs/:id/active", handleSetPDFActive(pdfsCollection))
 	// Bulk PDF delete
 
 	// Analytics
-	client.GET("/analytics", handleAnalytics(messagesCollection))
+	client.GET("/analytics", handleAnalytics(cfg, clientsCollection, messagesCollection, crawlsCollection))
+	client.GET("/analytics/welcome-variants", handleWelcomeVariantAnalytics(db, clientsCollection))
+	client.GET("/analytics/funnel", handleFunnelAnalytics(clientsCollection, messagesCollection)) // ✅ NEW: visited -> engaged -> lead
+	// ✅ NEW: Message search with month, feedback and topic facet counts
+	client.GET("/messages/search", handleMessageSearch(clientsCollection, messagesCollection))
 
 	// ✅ Quality monitoring endpoints
 	client.GET("/quality-metrics", handleGetQualityMetrics(cfg, db))
 	client.GET("/quality-metrics/:period", handleGetQualityMetricsByPeriod(cfg, db))
 	client.GET("/feedback-insights", handleGetFeedbackInsights(cfg, db))
+	client.GET("/feedback/search", handleSearchFeedback(db)) // ✅ NEW: keyword search over raw feedback
 	client.GET("/feedback-insights/:id/resolve", handleResolveFeedbackInsight(cfg, db))
 	client.DELETE("/feedback-insights/:id", handleDeleteFeedbackInsight(cfg, db))
 	client.POST("/quality-metrics/calculate", handleCalculateQualityMetrics(cfg, db))
@@ -263,12 +324,14 @@ func setupAuthenticatedRoutes(client *gin.RouterGroup, cfg *config.Config, db *m
 
 	// Image management
 	client.GET("/images", handleGetImages(imagesCollection))
-	client.POST("/images", handleAddImage(imagesCollection))
+	client.POST("/images", handleAddImage(cfg, clientsCollection, imagesCollection))
+	client.POST("/images/bulk", handleBulkUploadImages(cfg, clientsCollection, imagesCollection))
+	client.PUT("/images/:id", handleUpdateImage(imagesCollection))
 	client.DELETE("/images/:id", handleDeleteImage(imagesCollection))
 
 	// Calendly management
 	client.GET("/calendly", handleGetCalendly(clientsCollection))
-	client.POST("/calendly", handleUpdateCalendly(clientsCollection))
+	client.POST("/calendly", handleUpdateCalendly(cfg, clientsCollection))
 
 	// QR Code management
 	client.GET("/qr-code", handleGetQRCode(clientsCollection))
@@ -281,6 +344,13 @@ func setupAuthenticatedRoutes(client *gin.RouterGroup, cfg *config.Config, db *m
 	// Telegram QR Code management
 	client.GET("/telegram-qr-code", handleGetTelegramQRCode(clientsCollection))
 	client.POST("/telegram-qr-code", handleUpdateTelegramQRCode(clientsCollection))
+	// ✅ NEW: Telegram bot management
+	client.GET("/telegram-bot", handleGetTelegramBot(clientsCollection))
+	client.POST("/telegram-bot", handleUpdateTelegramBot(cfg, clientsCollection))
+	// ✅ NEW: Daily analytics export to Google Sheets
+	client.GET("/google-sheets", handleGetGoogleSheets(cfg, clientsCollection))
+	client.POST("/google-sheets/connect", handleConnectGoogleSheets(cfg))
+	client.DELETE("/google-sheets", handleDisconnectGoogleSheets(cfg, clientsCollection))
 
 	// Facebook Posts management
 	client.GET("/facebook-posts", handleGetFacebookPosts(facebookPostsCollection))
@@ -321,6 +391,63 @@ func setupAuthenticatedRoutes(client *gin.RouterGroup, cfg *config.Config, db *m
 	client.POST("/email-templates", handleCreateEmailTemplate(emailTemplatesCollection))
 	client.PUT("/email-templates/:id", handleUpdateEmailTemplate(emailTemplatesCollection))
 	client.DELETE("/email-templates/:id", handleDeleteEmailTemplate(emailTemplatesCollection))
+	// ✅ NEW: Render a template with sample data and send it to a test address
+	client.POST("/email-templates/:type/test", handleTestEmailTemplate(cfg, emailTemplatesCollection, clientsCollection))
+
+	// ✅ NEW: Analytics digest email settings
+	client.GET("/analytics-digest", handleGetAnalyticsDigest(clientsCollection))
+	client.PUT("/analytics-digest", handleUpdateAnalyticsDigest(clientsCollection))
+
+	// ✅ NEW: Message retention policy
+	client.GET("/retention-policy", handleGetRetentionPolicy(cfg, clientsCollection))
+	client.PUT("/retention-policy", handleUpdateRetentionPolicy(cfg, clientsCollection))
+
+	// ✅ NEW: Conversation sync to the client's CRM
+	client.GET("/crm-sync", handleGetCRMSync(db, clientsCollection))
+	client.PUT("/crm-sync", handleUpdateCRMSync(cfg, clientsCollection))
+	client.POST("/crm-sync/test", handleTestCRMSync(cfg, clientsCollection))
+	client.GET("/crm-sync/deliveries", handleListCRMSyncDeliveries(db))
+
+	// ✅ NEW: Timezone analytics days follow
+	client.GET("/analytics-timezone", handleGetAnalyticsTimezone(clientsCollection))
+	client.PUT("/analytics-timezone", handleUpdateAnalyticsTimezone(clientsCollection))
+
+	// ✅ NEW: Secret for signing conversation context passed to /public/chat
+	client.GET("/context-signing-secret", handleGetContextSigningSecret(clientsCollection))
+	client.POST("/context-signing-secret", handleRotateContextSigningSecret(clientsCollection))
+	client.DELETE("/context-signing-secret", handleDisableContextSigning(clientsCollection))
+
+	// ✅ NEW: Self-service embed secret rotation
+	client.POST("/embed-secret/rotate", handleRotateEmbedSecret(cfg, clientsCollection, auditLogger))
+
+	// ✅ NEW: Signed embed configuration (see signed_embed.go)
+	client.POST("/embed-snippet/signed", handleGenerateSignedEmbed(cfg, clientsCollection, auditLogger))
+
+	// ✅ NEW: System prompt template
+	client.GET("/prompt-template", handleGetPromptTemplate(cfg, clientsCollection))
+	client.PUT("/prompt-template", handleUpdatePromptTemplate(clientsCollection))
+	client.GET("/messages/:message_id/prompt", handleGetMessagePrompt(db, clientsCollection)) // ✅ NEW: redacted prompt behind a reply
+	// ✅ NEW: Which of persona, PDFs and crawl wins on conflicting information
+	client.GET("/source-priority", handleGetSourcePriority(clientsCollection))
+	client.PUT("/source-priority", handleUpdateSourcePriority(clientsCollection))
+	// ✅ NEW: Additional personas with routing
+	client.GET("/personas", handleListPersonas(db))
+	client.POST("/personas", handleCreatePersona(db, clientsCollection))
+	client.PUT("/personas/:id", handleUpdatePersona(db, clientsCollection))
+	client.DELETE("/personas/:id", handleDeletePersona(db))
+
+	// ✅ NEW: Hand-off after repeated unanswered questions
+	client.GET("/no-answer-escalation", handleGetNoAnswerEscalation(cfg, clientsCollection, messagesCollection))
+	client.PUT("/no-answer-escalation", handleUpdateNoAnswerEscalation(cfg, clientsCollection))
+
+	// ✅ NEW: Reply profanity filter
+	client.GET("/profanity-filter", handleGetProfanityFilter(clientsCollection))
+	client.PUT("/profanity-filter", handleUpdateProfanityFilter(clientsCollection))
+
+	// ✅ NEW: Per-topic follow-up suggestions
+	client.GET("/follow-up-suggestions", handleGetFollowUpSuggestions(clientsCollection))
+	client.PUT("/follow-up-suggestions/:topic", handleSetFollowUpSuggestion(clientsCollection))
+	client.DELETE("/follow-up-suggestions/:topic", handleDeleteFollowUpSuggestion(clientsCollection))
 
 }
 
@@ -407,8 +534,125 @@ func handleUpdatePDFStatus(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 	}
 }
 
+// ✅ NEW: bulkPDFStatuses maps the states clients may set on documents in bulk to the document's active
+// flag. The processing status (pending, processing, completed, failed) belongs to the upload pipeline
+// and is left alone.
+var bulkPDFStatuses = map[string]bool{"active": true, "inactive": false}
+
+const maxBulkPDFStatusIDs = 500
+
+// handleBulkUpdatePDFStatus activates or deactivates all listed PDFs owned by the client in one UpdateMany
+func handleBulkUpdatePDFStatus(pdfsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		var request struct {
+			PdfIDs []string `json:"pdf_ids" binding:"required"`
+			Status string   `json:"status" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_input",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		active, ok := bulkPDFStatuses[request.Status]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_status",
+				"message":    "Status must be 'active' or 'inactive'",
+			})
+			return
+		}
+
+		if len(request.PdfIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "empty_pdf_list",
+				"message":    "At least one PDF ID is required",
+			})
+			return
+		}
+		if len(request.PdfIDs) > maxBulkPDFStatusIDs {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "too_many_pdfs",
+				"message":    fmt.Sprintf("Maximum %d PDF IDs allowed per request", maxBulkPDFStatusIDs),
+			})
+			return
+		}
+
+		// Convert string IDs to ObjectIDs
+		pdfObjIDs := make([]primitive.ObjectID, 0, len(request.PdfIDs))
+		for _, pdfID := range request.PdfIDs {
+			objID, err := primitive.ObjectIDFromHex(pdfID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_pdf_id",
+					"message":    fmt.Sprintf("Invalid PDF ID format: %s", pdfID),
+				})
+				return
+			}
+			pdfObjIDs = append(pdfObjIDs, objID)
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		// IDs belonging to other clients simply don't match
+		now := time.Now()
+		result, err := pdfsCollection.UpdateMany(ctx,
+			bson.M{
+				"_id":       bson.M{"$in": pdfObjIDs},
+				"client_id": clientObjID,
+			},
+			bson.M{"$set": bson.M{
+				"active":     active,
+				"updated_at": now,
+			}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "bulk_update_failed",
+				"message":    "Failed to update PDF status",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "PDF status updated successfully",
+			"new_status":     request.Status,
+			"active":         active,
+			"requested_ids":  request.PdfIDs,
+			"matched_count":  result.MatchedCount,
+			"modified_count": result.ModifiedCount,
+			"updated_at":     now.UTC(),
+		})
+	}
+}
+
 // handleDeletePDF - Delete a single PDF document
-func handleDeletePDF(pdfsCollection *mongo.Collection) gin.HandlerFunc {
+func handleDeletePDF(cfg *config.Config, clientsCollection, pdfsCollection *mongo.Collection) gin.HandlerFunc {
+	pdfService := services.NewPDFService(cfg, clientsCollection, pdfsCollection)
+
 	return func(c *gin.Context) {
 		userClientID := middleware.GetClientID(c)
 		if userClientID == "" {
@@ -470,11 +714,8 @@ func handleDeletePDF(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
-		// Delete the PDF document
-		deleteResult, err := pdfsCollection.DeleteOne(context.Background(), bson.M{
-			"_id":       pdfObjID,
-			"client_id": clientObjID,
-		})
+		// Delete the PDF document (✅ NEW: its bytes are released from the client's storage quota)
+		deletedCount, err := pdfService.DeletePDFs(context.Background(), clientObjID, bson.M{"_id": pdfObjID})
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -484,26 +725,29 @@ func handleDeletePDF(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
-		if deleteResult.DeletedCount == 0 {
+		if deletedCount == 0 {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error_code": "pdf_not_found",
 				"message":    "PDF not found",
 			})
 			return
 		}
+		invalidateClientConfig(clientObjID)
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":       "PDF deleted successfully",
 			"pdf_id":        pdfID,
 			"filename":      pdfDoc.Filename,
 			"deleted_at":    time.Now().UTC(),
-			"deleted_count": deleteResult.DeletedCount,
+			"deleted_count": deletedCount,
 		})
 	}
 }
 
 // handleBulkDeletePDFs - Delete multiple PDF documents
-func handleBulkDeletePDFs(pdfsCollection *mongo.Collection) gin.HandlerFunc {
+func handleBulkDeletePDFs(cfg *config.Config, clientsCollection, pdfsCollection *mongo.Collection) gin.HandlerFunc {
+	pdfService := services.NewPDFService(cfg, clientsCollection, pdfsCollection)
+
 	return func(c *gin.Context) {
 		userClientID := middleware.GetClientID(c)
 		if userClientID == "" {
@@ -558,11 +802,8 @@ func handleBulkDeletePDFs(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
-		// Delete multiple PDFs
-		deleteResult, err := pdfsCollection.DeleteMany(context.Background(), bson.M{
-			"_id":       bson.M{"$in": pdfObjIDs},
-			"client_id": clientObjID,
-		})
+		// Delete multiple PDFs (✅ NEW: their bytes are released from the client's storage quota)
+		deletedCount, err := pdfService.DeletePDFs(context.Background(), clientObjID, bson.M{"_id": bson.M{"$in": pdfObjIDs}})
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -572,21 +813,48 @@ func handleBulkDeletePDFs(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
+		if deletedCount > 0 {
+			invalidateClientConfig(clientObjID)
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message":       "PDFs deleted successfully",
 			"requested_ids": request.PdfIDs,
-			"deleted_count": deleteResult.DeletedCount,
+			"deleted_count": deletedCount,
 			"deleted_at":    time.Now().UTC(),
 		})
 	}
 }
 
+// documentQuotaErrorBody renders a quota rejection from the PDF service for the client
+func documentQuotaErrorBody(qerr *services.QuotaError) gin.H {
+	if qerr.Code == services.QuotaDocumentLimitExceeded {
+		return gin.H{
+			"error_code": qerr.Code,
+			"message":    fmt.Sprintf("Document limit reached (%d of %d). Delete unused documents or contact support to raise your limit.", qerr.DocumentCount, qerr.MaxDocuments),
+			"details": gin.H{
+				"max_documents":  qerr.MaxDocuments,
+				"document_count": qerr.DocumentCount,
+			},
+		}
+	}
+	return gin.H{
+		"error_code": qerr.Code,
+		"message":    fmt.Sprintf("Uploading this file would exceed your storage quota (%s of %s used).", formatBytes(qerr.StorageUsedBytes), formatBytes(qerr.MaxTotalBytes)),
+		"details": gin.H{
+			"max_total_bytes":    qerr.MaxTotalBytes,
+			"storage_used_bytes": qerr.StorageUsedBytes,
+			"file_size":          qerr.FileSize,
+		},
+	}
+}
+
 // =====================
 // PUBLIC ROUTE HANDLERS
 // =====================
 
 // handlePublicBranding returns branding info for embed widgets
-func handlePublicBranding(clientsCollection *mongo.Collection) gin.HandlerFunc {
+func handlePublicBranding(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clientIDHex := c.Param("client_id")
 		clientOID, err := primitive.ObjectIDFromHex(clientIDHex)
@@ -615,14 +883,37 @@ func handlePublicBranding(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
+		// ✅ NEW: Serve one welcome message variant, sticky per session_id
+		welcomeMessage := clientDoc.Branding.WelcomeMessage
+		welcomeVariantID := ""
+		messagesCollection := clientsCollection.Database().Collection("messages")
+		if variant := sessionWelcomeVariant(ctx, messagesCollection, clientDoc.Branding, clientOID, c.Query("session_id")); variant != nil {
+			if variant.Text != "" {
+				welcomeMessage = variant.Text
+			}
+			welcomeVariantID = variant.ID
+		}
+
+		// Older widgets only read pre_questions, so list the configured texts there too
+		preQuestions := clientDoc.Branding.PreQuestions
+		if len(clientDoc.Branding.PreQuestionConfigs) > 0 {
+			preQuestions = make([]string, 0, len(clientDoc.Branding.PreQuestionConfigs))
+			for _, pq := range clientDoc.Branding.PreQuestionConfigs {
+				preQuestions = append(preQuestions, pq.Text)
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"name":            clientDoc.Name,
-			"logo_url":        clientDoc.Branding.LogoURL,
-			"theme_color":     clientDoc.Branding.ThemeColor,
-			"welcome_message": clientDoc.Branding.WelcomeMessage,
-			"pre_questions":   clientDoc.Branding.PreQuestions,
-			"allow_embedding": clientDoc.Branding.AllowEmbedding,
-			"show_powered_by": clientDoc.Branding.ShowPoweredBy,
+			"name":                 clientDoc.Name,
+			"logo_url":             clientDoc.Branding.LogoURL,
+			"theme_color":          clientDoc.Branding.ThemeColor,
+			"welcome_message":      welcomeMessage,
+			"welcome_variant":      welcomeVariantID,
+			"pre_questions":        preQuestions,
+			"pre_question_configs": publicPreQuestions(clientDoc.Branding),
+			"personas":             publicPersonas(ctx, clientsCollection.Database(), clientOID), // ✅ NEW: widget persona selector
+			"allow_embedding":      clientDoc.Branding.AllowEmbedding,
+			"show_powered_by":      clientDoc.Branding.ShowPoweredBy,
 			// Launcher configuration
 			"launcher_color":      clientDoc.Branding.LauncherColor,
 			"launcher_text":       clientDoc.Branding.LauncherText,
@@ -640,12 +931,18 @@ func handlePublicBranding(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			"show_welcome_avatar": clientDoc.Branding.ShowWelcomeAvatar,
 			"show_chat_avatar":    clientDoc.Branding.ShowChatAvatar,
 			"show_typing_avatar":  clientDoc.Branding.ShowTypingAvatar,
+			"show_quick_replies":  clientDoc.Branding.ShowQuickReplies, // ✅ NEW
+			// ✅ NEW: Ask visitors for tracking consent before sending tracking_consent with messages
+			"require_tracking_consent": clientDoc.Branding.RequireTrackingConsent,
+			// ✅ NEW: Suspended or inactive clients' widgets show this message instead of the chat
+			"chat_disabled":         clientChatDisabled(clientDoc),
+			"chat_disabled_message": suspendedWidgetMessage(cfg, clientDoc),
 		})
 	}
 }
 
 // handlePublicChat processes chat requests from embedded widgets with conversation memory
-func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection *mongo.Collection) gin.HandlerFunc {
+func handlePublicChat(cfg *config.Config, aiPool *ai.ClientPool, db *mongo.Database, rdb *redis.Client, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req ChatRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -657,6 +954,14 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 			return
 		}
 
+		// ✅ NEW: Reject blank and oversized messages before any lookup or AI call
+		message, err := validateChatMessage(req.Message, cfg.MaxChatMessageLength)
+		if err != nil {
+			respondToInvalidChatMessage(c, err, cfg.MaxChatMessageLength)
+			return
+		}
+		req.Message = message
+
 		// Validate and convert client ID
 		clientOID, err := primitive.ObjectIDFromHex(req.ClientID)
 		if err != nil {
@@ -667,7 +972,7 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), chatRequestTimeout(cfg))
 		defer cancel()
 
 		// Retrieve client configuration
@@ -678,10 +983,11 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 		}
 
 		// ✅ CHECK CLIENT STATUS - If inactive, block chat
-		if clientDoc.Status == "inactive" || clientDoc.Status == "suspended" {
+		if clientChatDisabled(clientDoc) {
 			c.JSON(http.StatusForbidden, gin.H{
-				"error_code": "client_inactive",
-				"message":    "This client account is not active",
+				"error_code":     "client_inactive",
+				"message":        "This client account is not active",
+				"widget_message": suspendedWidgetMessage(cfg, clientDoc), // ✅ NEW
 			})
 			return
 		}
@@ -695,8 +1001,95 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 			return
 		}
 
+		// ✅ NEW: Verify host-provided user context; unsigned or tampered context is rejected
+		var knownFacts map[string]string
+		if req.Context != "" || req.ContextSignature != "" {
+			knownFacts, err = verifySignedContext(clientDoc.ContextSigningSecret, req.Context, req.ContextSignature)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_context",
+					"message":    err.Error(),
+				})
+				return
+			}
+		}
+
+		// ✅ NEW: Welcome variant the session started with, recorded for A/B analytics
+		welcomeVariantID := ""
+		if variant := sessionWelcomeVariant(ctx, messagesCollection, clientDoc.Branding, clientDoc.ID, req.SessionID); variant != nil {
+			welcomeVariantID = variant.ID
+		}
+
+		// ✅ NEW: Scripted pre-questions are answered without an AI call or token charge
+		if req.PreQuestionID != "" {
+			if pq := findPreQuestionConfig(clientDoc.Branding, req.PreQuestionID); pq != nil && pq.Action != preQuestionActionAI {
+				respondToScriptedPreQuestion(ctx, c, messagesCollection, clientDoc, req, pq, welcomeVariantID)
+				return
+			}
+		}
+
+		// ✅ NEW: Skip generation for spam, gibberish and abuse
+		if cfg.SpamFilterEnabled {
+			if score, reason := classifySpam(req.Message); score >= spamThresholdForClient(cfg, clientDoc) {
+				respondToSpamMessage(ctx, c, messagesCollection, clientDoc, req, score, reason, welcomeVariantID)
+				return
+			}
+		}
+
+		// ✅ NEW: Pure greetings get the welcome message without an AI call or token charge
+		if clientDoc.Branding.InstantGreetingReply && isPureGreeting(req.Message, greetingPhrasesFor(cfg, clientDoc.Branding)) {
+			respondToGreeting(ctx, c, messagesCollection, clientDoc, req, welcomeVariantID)
+			return
+		}
+
+		// ✅ NEW: Conversations are closed after their maximum number of turns
+		maxTurns := maxConversationTurnsForClient(cfg, clientDoc)
+		turns := 0
+		if maxTurns > 0 {
+			if count, err := conversationTurns(ctx, messagesCollection, clientDoc.ID, req.SessionID); err != nil {
+				logger.Warn("Failed to count conversation turns", "error", err, "client_id", clientOID.Hex(), "session_id", req.SessionID)
+			} else {
+				turns = count
+			}
+			if turns >= maxTurns {
+				c.JSON(http.StatusOK, gin.H{
+					"reply":               sessionLimitReply,
+					"token_cost":          0,
+					"conversation_id":     req.SessionID,
+					"timestamp":           time.Now().Unix(),
+					"conversation_closed": true,
+					"max_turns":           maxTurns,
+					"turns_remaining":     0,
+				})
+				return
+			}
+		}
+
+		// ✅ NEW: Per-session token cap protects the client's budget from a single runaway session
+		sessionCap := sessionTokenCapForClient(cfg, clientDoc)
+		sessionUsed := 0
+		if sessionCap > 0 {
+			if used, err := sessionTokensUsed(ctx, messagesCollection, clientDoc.ID, req.SessionID); err != nil {
+				logger.Warn("Failed to sum session tokens", "error", err, "client_id", clientDoc.ID.Hex(), "session_id", req.SessionID)
+			} else {
+				sessionUsed = used
+			}
+			if sessionUsed >= sessionCap {
+				c.JSON(http.StatusOK, gin.H{
+					"reply":                    sessionLimitReply,
+					"token_cost":               0,
+					"conversation_id":          req.SessionID,
+					"timestamp":                time.Now().Unix(),
+					"session_limit_reached":    true,
+					"session_tokens_remaining": 0,
+				})
+				return
+			}
+		}
+
 		// Check token budget
 		if clientDoc.TokenUsed >= clientDoc.TokenLimit {
+			recordTokenLimitRejection(cfg, clientsCollection, clientDoc.ID, "widget")
 			c.JSON(http.StatusPaymentRequired, gin.H{
 				"error_code":  "token_limit_exceeded",
 				"message":     "Token limit exceeded. Please upgrade your plan.",
@@ -706,11 +1099,94 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 			return
 		}
 
+		// ✅ NEW: Answer rapid duplicate sends (e.g. double-clicks) with the original reply
+		dedupWindow := time.Duration(cfg.ChatDedupWindowSeconds) * time.Second
+		var dedupKey string
+		var dedupEntry *chatDedupEntry
+		var dedupBody gin.H
+		if dedupWindow > 0 {
+			dedupKey = chatDedupKey(clientOID.Hex(), req.SessionID, req.Message)
+			entry, owner := publicChatDedup.acquire(dedupKey, dedupWindow)
+			if !owner {
+				// If the original request failed, answer this one normally
+				if body, ok := entry.wait(ctx); ok {
+					c.JSON(http.StatusOK, duplicateChatResponse(body))
+					return
+				}
+			} else {
+				dedupEntry = entry
+				defer func() {
+					publicChatDedup.finish(dedupKey, dedupEntry, dedupBody, dedupWindow)
+				}()
+
+				if prior := findRecentDuplicateMessage(ctx, messagesCollection, clientOID, req.SessionID, req.Message, dedupWindow); prior != nil {
+					priorReply, _ := profanityFilterFor(cfg, clientDoc).mask(prior.Reply)
+					dedupBody = gin.H{
+						"reply":            priorReply,
+						"token_cost":       prior.TokenCost,
+						"remaining_tokens": clientDoc.TokenLimit - clientDoc.TokenUsed,
+						"conversation_id":  req.SessionID,
+						"message_id":       prior.ID.Hex(),
+						"latency_ms":       0,
+						"timestamp":        prior.Timestamp.Unix(),
+					}
+					c.JSON(http.StatusOK, duplicateChatResponse(dedupBody))
+					return
+				}
+			}
+		}
+
+		// ✅ NEW: Daily message limit, counted per client and UTC day independent of token cost
+		dailyLimit := clientDoc.DailyMessageLimit
+		var dailyCount int64
+		if dailyLimit > 0 {
+			count, allowed := reserveDailyMessage(ctx, rdb, clientOID.Hex(), dailyLimit)
+			if !allowed {
+				resetsAt := nextDailyReset(time.Now())
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(resetsAt).Seconds())+1))
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"error_code":          "daily_message_limit_exceeded",
+					"message":             "This chat has reached its message limit for today. Please try again tomorrow.",
+					"daily_message_limit": dailyLimit,
+					"resets_at":           resetsAt,
+				})
+				return
+			}
+			dailyCount = count
+		}
+
+		// ✅ NEW: Recap where the conversation left off for a visitor returning after a gap (before this message is stored)
+		welcomeBack := ""
+		if clientDoc.Branding.WarmReconnect {
+			welcomeBack = warmReconnectGreeting(ctx, cfg, messagesCollection, clientDoc.ID, req.SessionID)
+		}
+
 		// Generate AI response with conversation memory
-		response, tokenCost, latency, err := generateAIResponseWithMemory(ctx, cfg, db, pdfsCollection, messagesCollection, crawlsCollection, clientDoc, req.Message, req.SessionID)
+		response, tokenCost, latency, meta, err := generateAIResponseWithMemory(ctx, cfg, aiPool, db, pdfsCollection, messagesCollection, crawlsCollection, clientDoc, req.Message, req.SessionID, knownFacts, req.StructuredOutput,
+			personaHint{Selector: req.PersonaID, PreQuestionID: req.PreQuestionID})
 		if err != nil {
+			if dailyLimit > 0 {
+				releaseDailyMessage(rdb, clientOID.Hex())
+			}
 			// ✅ Use user-friendly error mapping
 			userFriendlyErr := mapToUserFriendlyError(err, "Failed to generate AI response")
+			if errors.Is(err, errContentBlocked) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error_code": "content_blocked",
+					"message":    userFriendlyErr.UserMessage,
+					"action":     userFriendlyErr.Action,
+				})
+				return
+			}
+			// ✅ NEW: Generation ran past its timeout; the widget can show the fallback reply
+			if errors.Is(err, errAITimeout) {
+				c.JSON(http.StatusGatewayTimeout, gin.H{
+					"error_code": "ai_timeout",
+					"message":    ai.FallbackResponseText,
+					"reply":      ai.FallbackResponseText,
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "ai_generation_error",
 				"message":    userFriendlyErr.UserMessage,
@@ -722,6 +1198,10 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 
 		// Validate token budget again with actual cost
 		if clientDoc.TokenUsed+tokenCost > clientDoc.TokenLimit {
+			if dailyLimit > 0 {
+				releaseDailyMessage(rdb, clientOID.Hex())
+			}
+			recordTokenLimitRejection(cfg, clientsCollection, clientDoc.ID, "widget")
 			c.JSON(http.StatusPaymentRequired, gin.H{
 				"error_code":       "insufficient_tokens",
 				"message":          "Insufficient tokens to complete this request",
@@ -731,11 +1211,37 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 			return
 		}
 
+		// ✅ NEW: After repeated unanswered questions, offer the client's team instead of another "I don't know"
+		escalate := shouldOfferNoAnswerEscalation(ctx, cfg, messagesCollection, clientDoc, req.SessionID, meta)
+		if escalate {
+			meta.NoAnswerEscalation = true
+			response += "\n\n" + noAnswerEscalationReply
+		}
+
+		if welcomeBack != "" {
+			response = welcomeBack + "\n\n" + response
+		}
+
+		// ✅ NEW: Wrap up on the conversation's last turn
+		closing := maxTurns > 0 && turns+1 >= maxTurns
+		if closing {
+			response += "\n\n" + conversationWrapUpReply
+		}
+
 		// ✅ Persist conversation with IP tracking and get message ID
-		messageID, err := persistMessage(ctx, messagesCollection, clientDoc.ID, req, response, tokenCost, c.Request)
+		messageID, err := persistMessage(ctx, messagesCollection, clientDoc.ID, req, response, tokenCost, meta, welcomeVariantID, visitorTrackingFor(clientDoc, req), c.Request)
 		if err != nil {
 			// Log error but don't fail the request
 			fmt.Printf("Failed to persist message: %v\n", err)
+		} else if escalate {
+			if err := updateContactCollectionState(ctx, messagesCollection, clientDoc.ID, req.SessionID, "awaiting_name", "", "", false); err != nil {
+				logger.Warn("Failed to start contact collection", "error", err, "client_id", clientDoc.ID.Hex(), "session_id", req.SessionID)
+			}
+		}
+		if closing {
+			if err := closeConversation(ctx, messagesCollection, clientDoc.ID, req.SessionID); err != nil {
+				logger.Warn("Failed to close conversation", "error", err, "client_id", clientOID.Hex(), "session_id", req.SessionID)
+			}
 		}
 
 		// Update token usage atomically + ALERT CHECK
@@ -749,17 +1255,18 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 			return
 		}
 
-		// TRIGGER REAL-TIME ALERT EVALUATION (async)
-		// go func() {
-		//     alertCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		//     defer cancel()
+		// ✅ NEW: Email a usage alert as soon as this reply crosses a threshold (async)
+		evaluateTokenAlerts(cfg, clientsCollection, clientDoc.ID, clientDoc.TokenUsed+tokenCost, clientDoc.TokenLimit)
 
-		//     if alertEvaluator := getAlertEvaluator(); alertEvaluator != nil {
-		//         if err := alertEvaluator.EvaluateAndNotify(alertCtx, clientDoc.ID); err != nil {
-		//             log.Printf("Failed to evaluate alerts for client %s: %v", clientDoc.Name, err)
-		//         }
-		//     }
-		// }()
+		// ✅ NEW: Mask profanity in what the visitor sees, including the structured reply and quick replies;
+		// the stored reply above stays unfiltered
+		profanity := profanityFilterFor(cfg, clientDoc)
+		filtered, count := profanity.mask(response)
+		structuredReply, quickReplies, extrasCount := profanity.maskReplyExtras(meta)
+		if count += extrasCount; count > 0 {
+			logger.Info("Masked profanity in reply", "client_id", clientOID.Hex(), "session_id", req.SessionID, "count", count)
+		}
+		response = filtered
 
 		// Calculate remaining tokens AFTER database update
 		remainingTokens := clientDoc.TokenLimit - (clientDoc.TokenUsed + tokenCost)
@@ -768,7 +1275,7 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 		}
 
 		// Return successful response with message ID for feedback
-		c.JSON(http.StatusOK, gin.H{
+		responseBody := gin.H{
 			"reply":            response,
 			"token_cost":       tokenCost,
 			"remaining_tokens": remainingTokens,
@@ -776,10 +1283,93 @@ func handlePublicChat(cfg *config.Config, db *mongo.Database, clientsCollection,
 			"message_id":       messageID.Hex(), // ✅ Include message ID for feedback
 			"latency_ms":       int(latency.Milliseconds()),
 			"timestamp":        time.Now().Unix(),
-		})
+		}
+		if sessionCap > 0 {
+			sessionRemaining := sessionCap - (sessionUsed + tokenCost)
+			if sessionRemaining < 0 {
+				sessionRemaining = 0
+			}
+			responseBody["session_tokens_remaining"] = sessionRemaining
+		}
+		if maxTurns > 0 {
+			responseBody["max_turns"] = maxTurns
+			responseBody["turns_remaining"] = maxTurns - (turns + 1)
+			responseBody["conversation_closed"] = closing
+		}
+		if dailyLimit > 0 {
+			dailyRemaining := int64(dailyLimit) - dailyCount
+			if dailyRemaining < 0 {
+				dailyRemaining = 0
+			}
+			responseBody["daily_messages_remaining"] = dailyRemaining
+		}
+		// ✅ NEW: Lets the widget show which persona is answering
+		if meta != nil && meta.Persona != nil {
+			responseBody["persona"] = gin.H{"id": meta.Persona.ID.Hex(), "name": meta.Persona.Name}
+		}
+		// ✅ NEW: Let the widget render the booking link as a button
+		if meta != nil && meta.DemoBooking != nil {
+			responseBody["demo_booking"] = meta.DemoBooking
+		}
+		// ✅ NEW: Lets the widget show a disclaimer or offer escalation on low-confidence answers
+		if meta != nil && meta.Confidence != nil {
+			responseBody["confidence"] = meta.Confidence.Score
+			responseBody["confidence_level"] = meta.Confidence.Level
+		}
+		if meta != nil && meta.WhatsAppHandoff != nil {
+			responseBody["whatsapp_link"] = meta.WhatsAppHandoff.Link
+		}
+		// ✅ NEW: Intent, entities and quick-reply suggestions for integrations (absent if JSON parsing failed)
+		if structuredReply != nil {
+			responseBody["structured"] = structuredReply
+		}
+		if len(quickReplies) > 0 {
+			responseBody["quick_replies"] = quickReplies
+		}
+		if welcomeBack != "" {
+			responseBody["welcome_back"] = true
+		}
+		if escalate {
+			responseBody["escalation_offered"] = true
+			responseBody["contact_collection"] = true
+		}
+		// ✅ NEW: Images whose tags, title or caption match the message, for inline rendering
+		if images := matchRelevantImages(ctx, db.Collection("images"), clientDoc.ID, req.Message); len(images) > 0 {
+			responseBody["images"] = images
+		}
+		dedupBody = responseBody
+		c.JSON(http.StatusOK, responseBody)
 	}
 }
 
+// maxHighlightedTextChars caps the span of a reply a feedback can point at
+const maxHighlightedTextChars = 1000
+
+// normalizeWhitespace collapses runs of whitespace so a span copied from the rendered reply still matches the stored text
+func normalizeWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var (
+	markdownLinkPattern       = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	markdownLinePrefixPattern = regexp.MustCompile(`(?m)^[ \t]*(#{1,6}[ \t]+|>[ \t]?|[-*+][ \t]+|\d+[.)][ \t]+)`)
+	markdownEmphasisPattern   = regexp.MustCompile("[*_~`]+")
+)
+
+// stripMarkdown reduces a markdown reply to the text the widget renders, so a span selected
+// from the rendered reply can be found in the stored markdown
+func stripMarkdown(s string) string {
+	s = markdownLinkPattern.ReplaceAllString(s, "$1")
+	s = markdownLinePrefixPattern.ReplaceAllString(s, "")
+	s = markdownEmphasisPattern.ReplaceAllString(s, "")
+	return normalizeWhitespace(s)
+}
+
+// replyContainsSpan reports whether a span highlighted in the rendered reply is part of the stored reply
+func replyContainsSpan(reply, span string) bool {
+	return strings.Contains(stripMarkdown(reply), stripMarkdown(span))
+}
+
 // ✅ ADDED: handlePublicFeedback handles feedback submission from embed widget
 func handlePublicFeedback(cfg *config.Config, db *mongo.Database, messagesCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -789,6 +1379,7 @@ func handlePublicFeedback(cfg *config.Config, db *mongo.Database, messagesCollec
 			FeedbackType  string `json:"feedback_type" binding:"required"` // "positive" or "negative"
 			Comment       string `json:"comment,omitempty"`
 			IssueCategory string `json:"issue_category,omitempty"` // "wrong_answer", "unclear", "incomplete", "irrelevant", "too_generic", "repetitive", "technical_error"
+			HighlightedText string `json:"highlighted_text,omitempty"` // ✅ NEW: span of the reply the feedback is about
 		}
 		
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -858,6 +1449,25 @@ func handlePublicFeedback(cfg *config.Config, db *mongo.Database, messagesCollec
 			return
 		}
 		
+		// ✅ NEW: A highlighted span must be quoted from the rated reply
+		req.HighlightedText = strings.TrimSpace(req.HighlightedText)
+		if req.HighlightedText != "" {
+			if len(req.HighlightedText) > maxHighlightedTextChars {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_highlighted_text",
+					"message":    fmt.Sprintf("highlighted_text must be at most %d characters", maxHighlightedTextChars),
+				})
+				return
+			}
+			if !replyContainsSpan(message.Reply, req.HighlightedText) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_highlighted_text",
+					"message":    "highlighted_text must be part of the reply",
+				})
+				return
+			}
+		}
+		
 		// Get conversation context (last 3 messages)
 		var conversationContext string
 		cursor, err := messagesCollection.Find(ctx, bson.M{
@@ -893,6 +1503,10 @@ func handlePublicFeedback(cfg *config.Config, db *mongo.Database, messagesCollec
 			ConversationID:     message.ConversationID,
 			ConversationContext: conversationContext,
 			Analyzed:           false,
+			HighlightedText:    req.HighlightedText,
+		}
+		if message.SourceAttribution != nil {
+			feedback.DominantSource = message.SourceAttribution.DominantSource
 		}
 		
 		_, err = feedbackCollection.InsertOne(ctx, feedback)
@@ -955,7 +1569,7 @@ func analyzeFeedback(ctx context.Context, db *mongo.Database, feedbackID primiti
 	
 	// Auto-categorize issue if not provided and feedback is negative
 	if feedback.FeedbackType == "negative" && feedback.IssueCategory == "" {
-		feedback.IssueCategory = categorizeIssue(feedback.UserMessage, feedback.AIResponse, feedback.Comment)
+		feedback.IssueCategory = categorizeIssue(feedback.UserMessage, feedback.AIResponse, feedback.Comment, feedback.HighlightedText)
 		// If still empty after categorization, set a default
 		if feedback.IssueCategory == "" {
 			feedback.IssueCategory = "wrong_answer" // Default category
@@ -1011,7 +1625,11 @@ func analyzeFeedback(ctx context.Context, db *mongo.Database, feedbackID primiti
 }
 
 // categorizeIssue automatically categorizes feedback issues based on content
-func categorizeIssue(userMessage, aiResponse, comment string) string {
+func categorizeIssue(userMessage, aiResponse, comment, highlightedText string) string {
+	// ✅ NEW: When the user flagged a specific statement, judge that statement rather than the whole reply
+	if highlightedText != "" {
+		aiResponse = highlightedText
+	}
 	text := strings.ToLower(userMessage + " " + aiResponse + " " + comment)
 	
 	// Issue category keywords
@@ -1104,8 +1722,8 @@ func calculateQualityScore(feedback models.MessageFeedback) float64 {
 			score = severity
 		}
 		
-		// Penalty if no comment (less actionable)
-		if len(feedback.Comment) == 0 {
+		// Penalty if no comment or highlighted statement (less actionable)
+		if len(feedback.Comment) == 0 && feedback.HighlightedText == "" {
 			score -= 0.05
 		}
 		
@@ -1158,10 +1776,12 @@ func generateFeedbackInsight(ctx context.Context, db *mongo.Database, feedback m
 		
 		// Add example feedback (limit to 5 examples per insight)
 		exampleFeedback := models.FeedbackExample{
-			UserMessage: feedback.UserMessage,
-			AIResponse:  feedback.AIResponse,
-			Comment:     feedback.Comment,
-			Timestamp:   feedback.Timestamp,
+			UserMessage:    feedback.UserMessage,
+			AIResponse:     feedback.AIResponse,
+			Comment:        feedback.Comment,
+			DominantSource: feedback.DominantSource,
+			HighlightedText: feedback.HighlightedText,
+			Timestamp:      feedback.Timestamp,
 		}
 		
 		// Add to examples array (limit to 5 most recent)
@@ -1200,10 +1820,18 @@ func generateFeedbackInsight(ctx context.Context, db *mongo.Database, feedback m
 	
 	// Create new insight with example feedback
 	exampleFeedback := models.FeedbackExample{
-		UserMessage: feedback.UserMessage,
-		AIResponse:  feedback.AIResponse,
-		Comment:     feedback.Comment,
-		Timestamp:   feedback.Timestamp,
+		UserMessage:    feedback.UserMessage,
+		AIResponse:     feedback.AIResponse,
+		Comment:        feedback.Comment,
+		DominantSource: feedback.DominantSource,
+		HighlightedText: feedback.HighlightedText,
+		Timestamp:      feedback.Timestamp,
+	}
+	
+	recommendation := generateRecommendation(feedback.IssueCategory, topics[0])
+	// ✅ NEW: Flag knowledge gaps - the reply wasn't grounded in any uploaded document or crawled page
+	if feedback.DominantSource == "none" {
+		recommendation += " No uploaded document or crawled page matched this answer - consider adding content that covers it."
 	}
 	
 	insight := models.FeedbackInsight{
@@ -1216,7 +1844,7 @@ func generateFeedbackInsight(ctx context.Context, db *mongo.Database, feedback m
 		AffectedTopics:   topics,
 		IssueCategory:    feedback.IssueCategory,
 		FeedbackCount:    1,
-		Recommendation:   generateRecommendation(feedback.IssueCategory, topics[0]),
+		Recommendation:   recommendation,
 		ExampleFeedbacks: []models.FeedbackExample{exampleFeedback},
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
@@ -1607,9 +2235,10 @@ func calculateQualityMetrics(ctx context.Context, db *mongo.Database, clientID p
 	feedbackCollection := db.Collection("message_feedback")
 	metricsCollection := db.Collection("quality_metrics")
 
-	// Determine time range based on period
+	// Determine time range based on period; "daily" starts at midnight in the client's analytics timezone
 	var periodStart, periodEnd time.Time
-	now := time.Now()
+	loc := loadAnalyticsLocation(ctx, db.Collection("clients"), clientID)
+	now := time.Now().In(loc)
 
 	switch period {
 	case "daily":
@@ -1657,6 +2286,7 @@ func calculateQualityMetrics(ctx context.Context, db *mongo.Database, clientID p
 	topicDistribution := make(map[string]int)
 	totalQualityScore := 0.0
 	qualityScoreCount := 0
+	topicQuality := newTopicQualityTracker(loc) // ✅ NEW
 
 	for _, feedback := range feedbacks {
 		if feedback.FeedbackType == "positive" {
@@ -1670,11 +2300,12 @@ func calculateQualityMetrics(ctx context.Context, db *mongo.Database, clientID p
 
 		// Extract topic from user message
 		topics := extractTopics(feedback.UserMessage)
+		topic := "general"
 		if len(topics) > 0 {
-			topicDistribution[topics[0]]++
-		} else {
-			topicDistribution["general"]++
+			topic = topics[0]
 		}
+		topicDistribution[topic]++
+		topicQuality.add(topic, feedback)
 
 		// Calculate quality score if not already set
 		if feedback.QualityScore > 0 {
@@ -1695,6 +2326,16 @@ func calculateQualityMetrics(ctx context.Context, db *mongo.Database, clientID p
 		averageQualityScore = totalQualityScore / float64(qualityScoreCount)
 	}
 
+	// ✅ NEW: How often replies had to be regenerated for repeating an earlier one
+	repeatedReplies, repeatedReplyRate, err := countRepeatedReplies(ctx, db.Collection("messages"), clientID, periodStart, periodEnd)
+	if err != nil {
+		logger.Warn("Failed to count repeated replies", "error", err, "client_id", clientID.Hex())
+	}
+	strippedClaims, err := sumStrippedClaims(ctx, db.Collection("messages"), clientID, periodStart, periodEnd)
+	if err != nil {
+		logger.Warn("Failed to sum stripped claims", "error", err, "client_id", clientID.Hex())
+	}
+
 	// Create metrics object
 	metrics := &models.QualityMetrics{
 		ID:                  primitive.NewObjectID(),
@@ -1711,6 +2352,11 @@ func calculateQualityMetrics(ctx context.Context, db *mongo.Database, clientID p
 		AverageQualityScore: averageQualityScore,
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
+		TopicBreakdown:      topicQuality.breakdown(),
+		TopicTrend:          topicQuality.trend(),
+		RepeatedReplies:     repeatedReplies,
+		RepeatedReplyRate:   repeatedReplyRate,
+		StrippedClaims:      strippedClaims,
 	}
 
 	// Store or update metrics
@@ -1730,6 +2376,10 @@ func calculateQualityMetrics(ctx context.Context, db *mongo.Database, clientID p
 			"issue_distribution":    metrics.IssueDistribution,
 			"topic_distribution":    metrics.TopicDistribution,
 			"average_quality_score": metrics.AverageQualityScore,
+			"topic_breakdown":       metrics.TopicBreakdown,
+			"repeated_replies":      metrics.RepeatedReplies,
+			"repeated_reply_rate":   metrics.RepeatedReplyRate,
+			"stripped_claims":       metrics.StrippedClaims,
 			"updated_at":            metrics.UpdatedAt,
 		},
 		"$setOnInsert": bson.M{
@@ -1750,7 +2400,7 @@ func calculateQualityMetrics(ctx context.Context, db *mongo.Database, clientID p
 }
 
 // processUnanalyzedFeedback processes all unanalyzed feedback entries
-func processUnanalyzedFeedback(ctx context.Context, db *mongo.Database, clientID *primitive.ObjectID) error {
+func processUnanalyzedFeedback(ctx context.Context, db *mongo.Database, clientID *primitive.ObjectID) (int, int, error) {
 	feedbackCollection := db.Collection("message_feedback")
 	messagesCollection := db.Collection("messages")
 	
@@ -1781,13 +2431,13 @@ func processUnanalyzedFeedback(ctx context.Context, db *mongo.Database, clientID
 	
 	cursor, err := feedbackCollection.Find(ctx, filter, options.Find().SetLimit(100))
 	if err != nil {
-		return fmt.Errorf("failed to query unanalyzed feedback: %w", err)
+		return 0, 0, fmt.Errorf("failed to query unanalyzed feedback: %w", err)
 	}
 	defer cursor.Close(ctx)
 	
 	var feedbacks []models.MessageFeedback
 	if err := cursor.All(ctx, &feedbacks); err != nil {
-		return fmt.Errorf("failed to decode feedback: %w", err)
+		return 0, 0, fmt.Errorf("failed to decode feedback: %w", err)
 	}
 	
 	fmt.Printf("Found %d unanalyzed feedback entries\n", len(feedbacks))
@@ -1855,7 +2505,7 @@ func processUnanalyzedFeedback(ctx context.Context, db *mongo.Database, clientID
 				}
 				if feedback.IssueCategory == "" {
 					// Try to categorize if missing
-					feedback.IssueCategory = categorizeIssue(feedback.UserMessage, feedback.AIResponse, feedback.Comment)
+					feedback.IssueCategory = categorizeIssue(feedback.UserMessage, feedback.AIResponse, feedback.Comment, feedback.HighlightedText)
 					if feedback.IssueCategory == "" {
 						feedback.IssueCategory = "wrong_answer"
 					}
@@ -1898,6 +2548,7 @@ func processUnanalyzedFeedback(ctx context.Context, db *mongo.Database, clientID
 						UserMessage: feedback.UserMessage,
 						AIResponse:  feedback.AIResponse,
 						Comment:     feedback.Comment,
+						HighlightedText: feedback.HighlightedText,
 						Timestamp:   feedback.Timestamp,
 					}
 					
@@ -1936,62 +2587,36 @@ func processUnanalyzedFeedback(ctx context.Context, db *mongo.Database, clientID
 	}
 	
 	fmt.Printf("Processed %d feedback entries, created/updated %d insights\n", processed, insightsCreated)
-	return nil
+	return processed, insightsCreated, nil
 }
 
-// checkQualityAlerts checks for quality issues and generates alerts
-func checkQualityAlerts(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID) error {
+// checkQualityAlerts checks for quality issues and generates alerts. A condition that already has an open
+// alert within the cooldown is counted on that alert instead of creating a duplicate.
+func checkQualityAlerts(ctx context.Context, cfg *config.Config, db *mongo.Database, clientID primitive.ObjectID) (created, repeated int, err error) {
 	// Get recent quality metrics
 	metrics, err := calculateQualityMetrics(ctx, db, clientID, "30d")
 	if err != nil {
-		return fmt.Errorf("failed to calculate metrics: %w", err)
-	}
-	
-	// Check alert thresholds
-	alerts := []string{}
-	
-	// Low satisfaction rate alert
-	if metrics.SatisfactionRate < 0.7 && metrics.TotalFeedback >= 10 {
-		alerts = append(alerts, fmt.Sprintf("Low satisfaction rate: %.1f%% (threshold: 70%%)", metrics.SatisfactionRate*100))
-	}
-	
-	// High negative feedback rate alert
-	negativeRate := float64(metrics.NegativeFeedback) / float64(metrics.TotalFeedback)
-	if negativeRate > 0.3 && metrics.TotalFeedback >= 10 {
-		alerts = append(alerts, fmt.Sprintf("High negative feedback rate: %.1f%% (threshold: 30%%)", negativeRate*100))
-	}
-	
-	// Critical issue alert
-	if metrics.IssueDistribution["wrong_answer"] >= 5 {
-		alerts = append(alerts, fmt.Sprintf("Multiple wrong answer issues: %d reports", metrics.IssueDistribution["wrong_answer"]))
-	}
-	
-	// Low quality score alert
-	if metrics.AverageQualityScore < 0.5 && metrics.TotalFeedback >= 10 {
-		alerts = append(alerts, fmt.Sprintf("Low average quality score: %.2f (threshold: 0.5)", metrics.AverageQualityScore))
+		return 0, 0, fmt.Errorf("failed to calculate metrics: %w", err)
 	}
-	
-	// Store alerts if any
-	if len(alerts) > 0 {
-		alertsCollection := db.Collection("quality_alerts")
-		alert := bson.M{
-			"_id":         primitive.NewObjectID(),
-			"client_id":   clientID,
-			"alerts":      alerts,
-			"metrics":     metrics,
-			"created_at":  time.Now(),
-			"acknowledged": false,
-		}
-		
-		_, err = alertsCollection.InsertOne(ctx, alert)
+
+	alertsCollection := db.Collection("quality_alerts")
+	cooldown := qualityAlertCooldown(cfg)
+	for _, condition := range qualityAlertConditions(metrics) {
+		isNew, err := recordQualityAlert(ctx, alertsCollection, clientID, condition, metrics, cooldown)
 		if err != nil {
-			fmt.Printf("Failed to store quality alerts: %v\n", err)
+			return created, repeated, fmt.Errorf("failed to store quality alert %s: %w", condition.Key, err)
+		}
+		if isNew {
+			created++
 		} else {
-			fmt.Printf("Generated %d quality alerts for client %s\n", len(alerts), clientID.Hex())
+			repeated++
 		}
 	}
-	
-	return nil
+
+	if created > 0 {
+		logger.Info("Generated quality alerts", "client_id", clientID.Hex(), "count", created)
+	}
+	return created, repeated, nil
 }
 
 // handleProcessUnanalyzedFeedback processes all unanalyzed feedback
@@ -2019,7 +2644,7 @@ func handleProcessUnanalyzedFeedback(cfg *config.Config, db *mongo.Database) gin
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
 		defer cancel()
 		
-		err = processUnanalyzedFeedback(ctx, db, &clientObjID)
+		processed, insightsCreated, err := processUnanalyzedFeedback(ctx, db, &clientObjID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "processing_error",
@@ -2030,8 +2655,10 @@ func handleProcessUnanalyzedFeedback(cfg *config.Config, db *mongo.Database) gin
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "Unanalyzed feedback processed successfully",
+			"success":          true,
+			"message":          "Unanalyzed feedback processed successfully",
+			"processed":        processed,
+			"insights_created": insightsCreated,
 		})
 	}
 }
@@ -2060,7 +2687,7 @@ func handleCheckQualityAlerts(cfg *config.Config, db *mongo.Database) gin.Handle
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 		defer cancel()
 
-		err = checkQualityAlerts(ctx, db, clientObjID)
+		created, repeated, err := checkQualityAlerts(ctx, cfg, db, clientObjID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "alert_check_error",
@@ -2071,8 +2698,10 @@ func handleCheckQualityAlerts(cfg *config.Config, db *mongo.Database) gin.Handle
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "Quality alerts checked successfully",
+			"success":         true,
+			"message":         "Quality alerts checked successfully",
+			"new_alerts":      created,
+			"repeated_alerts": repeated, // conditions already open within the cooldown
 		})
 	}
 }
@@ -2148,6 +2777,39 @@ func handleUpdateBranding(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
+		// ✅ NEW: Validate pre-question answers and routing
+		if err := validatePreQuestionConfigs(branding.PreQuestionConfigs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_pre_questions",
+				"message":    err.Error(),
+			})
+			return
+		}
+
+		// ✅ NEW: Bound the client's extra greeting phrases
+		if len(branding.GreetingPhrases) > maxGreetingPhrases {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "too_many_greeting_phrases",
+				"message":    fmt.Sprintf("Maximum %d greeting phrases allowed", maxGreetingPhrases),
+			})
+			return
+		}
+
+		// ✅ NEW: Reject malformed colors and asset URLs, which would break or inject into the widget
+		if fieldErrors := normalizeBranding(&branding); fieldErrors != nil {
+			respondToInvalidBranding(c, fieldErrors)
+			return
+		}
+
+		// ✅ NEW: Validate welcome message A/B variants
+		if err := validateWelcomeVariants(branding.WelcomeVariants); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_welcome_variants",
+				"message":    err.Error(),
+			})
+			return
+		}
+
 		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -2168,6 +2830,7 @@ func handleUpdateBranding(clientsCollection *mongo.Collection) gin.HandlerFunc {
 		}
 
 		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, update)
+		invalidateClientConfig(clientObjID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "internal_error",
@@ -2203,7 +2866,7 @@ func handleUpdateBranding(clientsCollection *mongo.Collection) gin.HandlerFunc {
 }
 
 // handlePDFUpload processes PDF file uploads using the new PDF service
-func handlePDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection) gin.HandlerFunc {
+func handlePDFUpload(cfg *config.Config, clientsCollection, pdfsCollection *mongo.Collection, auditLogger *models.AuditLogger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userClientID := middleware.GetClientID(c)
 		if userClientID == "" && !middleware.IsAdmin(c) {
@@ -2246,6 +2909,12 @@ func handlePDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection) gin.H
 			return
 		}
 
+		// ✅ NEW: Reject renamed or mislabeled files before any processing
+		if verr := utils.ValidatePDFFileHeader(file, header); verr != nil {
+			c.JSON(http.StatusBadRequest, verr)
+			return
+		}
+
 		// Check if async processing is requested
 		isAsync := c.PostForm("async") == "true"
 
@@ -2259,8 +2928,8 @@ func handlePDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection) gin.H
 			return
 		}
 
-		// Create PDF service
-		pdfService := services.NewPDFService(cfg, pdfsCollection)
+		// Create PDF service (✅ NEW: it enforces the client's document count and storage limits)
+		pdfService := services.NewPDFService(cfg, clientsCollection, pdfsCollection)
 
 		// Create secure upload request
 		uploadReq := &services.SecureUploadRequest{
@@ -2269,13 +2938,26 @@ func handlePDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection) gin.H
 			ClientID: clientObjID,
 			UserID:   primitive.NilObjectID, // Public upload
 			IsAsync:  isAsync,
+			Force:    c.PostForm("force") == "true", // ✅ NEW: re-upload even if an identical file exists
 		}
 
 		// Process upload
 		result, err := pdfService.ValidateAndProcessUpload(c.Request.Context(), uploadReq)
 		if err != nil {
+			// ✅ NEW: Document count or storage quota reached
+			var quotaErr *services.QuotaError
+			if errors.As(err, &quotaErr) {
+				c.JSON(http.StatusForbidden, documentQuotaErrorBody(quotaErr))
+				return
+			}
+
 			fmt.Printf("❌ PDF upload failed: %s - %v\n", header.Filename, err)
 
+			// ✅ NEW: Infected uploads and an unreachable virus scanner
+			if respondUploadScanError(c, auditLogger, clientObjID.Hex(), header.Filename, err) {
+				return
+			}
+
 			// Check for specific error types
 			if strings.Contains(err.Error(), "file size") {
 				c.JSON(http.StatusBadRequest, gin.H{
@@ -2315,6 +2997,11 @@ func handlePDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection) gin.H
 			return
 		}
 
+		// ✅ NEW: The PDF service charged the stored bytes to the client (duplicates cost nothing)
+		if !result.Duplicate {
+			invalidateClientConfig(clientObjID)
+		}
+
 		// Prepare response
 		response := models.UploadResponse{
 			ID:       result.PDF.ID.Hex(),
@@ -2331,6 +3018,12 @@ func handlePDFUpload(cfg *config.Config, pdfsCollection *mongo.Collection) gin.H
 			response.Message = "PDF uploaded successfully, processing in background"
 		}
 
+		// ✅ NEW: The same file was uploaded before; nothing was stored or reprocessed
+		if result.Duplicate {
+			response.Duplicate = true
+			response.Message = "This PDF was already uploaded; returning the existing document (send force=true to upload it again)"
+		}
+
 		// Add task ID for async processing
 		if result.TaskID != "" {
 			response.TaskID = result.TaskID
@@ -2420,8 +3113,8 @@ func handlePDFStatus(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 	}
 }
 
-// handleGetTokens returns token usage information
-func handleGetTokens(clientsCollection *mongo.Collection) gin.HandlerFunc {
+// handleGetTokens returns token usage information, the usage alert level and a depletion forecast
+func handleGetTokens(cfg *config.Config, clientsCollection, messagesCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userClientID := middleware.GetClientID(c)
 		if userClientID == "" {
@@ -2460,11 +3153,177 @@ func handleGetTokens(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			usage = float64(clientDoc.TokenUsed) / float64(clientDoc.TokenLimit) * 100
 		}
 
+		// ✅ NEW: Forecast depletion from the recent burn rate; usage is still returned if it can't be computed
+		now := time.Now()
+		start := forecastWindowStart(clientDoc.CreatedAt, now)
+		var forecast *models.TokenForecast
+		if usedInWindow, err := tokensUsedSince(ctx, messagesCollection, clientObjID, start); err != nil {
+			logger.Warn("Failed to compute token forecast", "error", err, "client_id", userClientID)
+		} else {
+			forecast = forecastTokenDepletion(remaining, usedInWindow, start, now)
+		}
+
 		c.JSON(http.StatusOK, models.TokenUsage{
 			Used:      clientDoc.TokenUsed,
 			Limit:     clientDoc.TokenLimit,
 			Remaining: remaining,
 			Usage:     usage,
+
+			AlertLevel:           services.TokenAlertLevel(*cfg, clientDoc.TokenUsed, clientDoc.TokenLimit),
+			LimitRejections:      clientDoc.TokenLimitRejections,
+			LastLimitRejectionAt: clientDoc.LastTokenLimitRejectionAt,
+			Forecast:             forecast,
+		})
+	}
+}
+
+const (
+	defaultPDFPreviewChars = 1000
+	maxPDFPreviewChars     = 5000
+)
+
+// pdfChunkText returns a chunk's text, decompressing it if it was stored compressed
+func pdfChunkText(chunk models.ContentChunk) (string, error) {
+	if !chunk.Compressed {
+		return chunk.Text, nil
+	}
+	compressed, err := base64.StdEncoding.DecodeString(chunk.Text)
+	if err != nil {
+		return "", err
+	}
+	return utils.DecompressText(compressed, utils.CompressionAlgorithm(chunk.Compression))
+}
+
+// handlePDFPreview returns the start of a PDF's extracted text with a quality verdict so clients can spot
+// garbled extractions right after upload
+func handlePDFPreview(pdfsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		pdfObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_pdf_id",
+				"message":    "Invalid PDF ID format",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		previewChars := defaultPDFPreviewChars
+		if raw := c.Query("chars"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxPDFPreviewChars {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_chars",
+					"message":    fmt.Sprintf("chars must be between 1 and %d", maxPDFPreviewChars),
+				})
+				return
+			}
+			previewChars = n
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		var pdfDoc models.PDF
+		err = pdfsCollection.FindOne(ctx, bson.M{
+			"_id":       pdfObjID,
+			"client_id": clientObjID,
+		}).Decode(&pdfDoc)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "pdf_not_found",
+					"message":    "PDF not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to retrieve PDF",
+			})
+			return
+		}
+
+		if pdfDoc.Status != models.StatusCompleted {
+			c.JSON(http.StatusConflict, gin.H{
+				"error_code": "pdf_not_ready",
+				"message":    "Text preview is available once processing has completed",
+				"status":     pdfDoc.Status,
+			})
+			return
+		}
+
+		chunks := append([]models.ContentChunk(nil), pdfDoc.ContentChunks...)
+		sort.SliceStable(chunks, func(i, j int) bool {
+			return chunks[i].Order < chunks[j].Order
+		})
+
+		// Quality is judged on a larger sample than the preview so a clean title page can't hide garbage
+		var sample strings.Builder
+		totalChars := 0
+		for _, chunk := range chunks {
+			text, err := pdfChunkText(chunk)
+			if err != nil {
+				continue
+			}
+			totalChars += utf8.RuneCountInString(text)
+			if sample.Len() < maxPDFPreviewChars*4 {
+				if sample.Len() > 0 {
+					sample.WriteString("\n\n")
+				}
+				sample.WriteString(text)
+			}
+		}
+
+		sampleRunes := []rune(sample.String())
+		if len(sampleRunes) > maxPDFPreviewChars {
+			sampleRunes = sampleRunes[:maxPDFPreviewChars]
+		}
+		preview := sampleRunes
+		if len(preview) > previewChars {
+			preview = preview[:previewChars]
+		}
+
+		quality := "good"
+		qualityMessage := "Text extracted cleanly"
+		switch {
+		case totalChars == 0:
+			quality = "empty"
+			qualityMessage = "No text could be extracted; the PDF may be scanned images or protected"
+		case !qualityOK(string(sampleRunes)):
+			quality = "poor"
+			qualityMessage = "Extracted text looks garbled; try re-exporting the PDF or uploading a text-based version"
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"id":                pdfDoc.ID.Hex(),
+			"filename":          pdfDoc.OriginalName,
+			"preview":           string(preview),
+			"preview_chars":     len(preview),
+			"total_chars":       totalChars,
+			"truncated":         totalChars > len(preview),
+			"chunk_count":       len(chunks),
+			"extraction_method": pdfDoc.Metadata.ExtractionMethod,
+			"quality":           quality,
+			"quality_ok":        quality == "good",
+			"quality_message":   qualityMessage,
 		})
 	}
 }
@@ -2522,6 +3381,11 @@ func handleListPDFs(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 			})
 			return
 		}
+		// ✅ NEW: Documents uploaded before the active toggle are active
+		for i := range pdfs {
+			active := pdfIsActive(&pdfs[i])
+			pdfs[i].Active = &active
+		}
 
 		total, _ := pdfsCollection.CountDocuments(ctx, bson.M{"client_id": clientObjID})
 
@@ -2536,7 +3400,7 @@ func handleListPDFs(pdfsCollection *mongo.Collection) gin.HandlerFunc {
 }
 
 // handleAnalytics returns client analytics data
-func handleAnalytics(messagesCollection *mongo.Collection) gin.HandlerFunc {
+func handleAnalytics(cfg *config.Config, clientsCollection, messagesCollection, crawlsCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userClientID := middleware.GetClientID(c)
 		if userClientID == "" {
@@ -2560,13 +3424,31 @@ func handleAnalytics(messagesCollection *mongo.Collection) gin.HandlerFunc {
 		period := strings.ToLower(strings.TrimSpace(c.DefaultQuery("period", "30d")))
 		dur := parsePeriod(period)
 
+		// ✅ NEW: Optional channel filter
+		channel := strings.ToLower(strings.TrimSpace(c.Query("channel")))
+		if channel != "" && !isValidMessageChannel(channel) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_channel",
+				"message":    "Unknown channel",
+			})
+			return
+		}
+
 		end := time.Now()
 		start := end.Add(-dur)
 
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 		defer cancel()
 
-		analytics, err := generateAnalytics(ctx, messagesCollection, clientObjID, start, end, period)
+		loc := loadAnalyticsLocation(ctx, clientsCollection, clientObjID)
+
+		// ✅ NEW: Explicit date range and comparison range, e.g. this month vs the same month last year
+		ranges, ok := parseAnalyticsRanges(c, loc, start, end, period)
+		if !ok {
+			return
+		}
+
+		analytics, err := generateAnalytics(ctx, messagesCollection, clientObjID, ranges.Start, ranges.End, ranges.Period, channel, loc)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "analytics_error",
@@ -2576,6 +3458,17 @@ func handleAnalytics(messagesCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
+		if !addAnalyticsComparison(ctx, c, messagesCollection, clientObjID, analytics, ranges, channel) {
+			return
+		}
+
+		// ✅ NEW: Warn when most crawled knowledge is past the stale threshold
+		if freshness, err := knowledgeFreshness(ctx, cfg, crawlsCollection, clientObjID); err != nil {
+			logger.Warn("Failed to compute knowledge freshness", "error", err, "client_id", clientObjID.Hex())
+		} else {
+			analytics["knowledge_freshness"] = freshness
+		}
+
 		c.JSON(http.StatusOK, analytics)
 	}
 }
@@ -2584,8 +3477,8 @@ func handleAnalytics(messagesCollection *mongo.Collection) gin.HandlerFunc {
 // ENHANCED AI RESPONSE WITH MEMORY
 // ===================
 
-// getDefaultPersona retrieves the default persona from system settings
-func getDefaultPersona(ctx context.Context, db *mongo.Database) (*models.AIPersonaData, error) {
+// loadDefaultPersona retrieves the default persona from system settings (see getDefaultPersona for the cached lookup)
+func loadDefaultPersona(ctx context.Context, db *mongo.Database) (*models.AIPersonaData, error) {
 	systemSettingsCollection := db.Collection("system_settings")
 	var settingDoc bson.M
 	err := systemSettingsCollection.FindOne(ctx, bson.M{"key": "default_persona"}).Decode(&settingDoc)
@@ -2610,62 +3503,67 @@ func getDefaultPersona(ctx context.Context, db *mongo.Database) (*models.AIPerso
 }
 
 // generateAIResponseWithMemory generates AI response with conversation history
-func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *mongo.Database, pdfsCollection, messagesCollection, crawlsCollection *mongo.Collection, client *models.Client, message, sessionID string) (string, int, time.Duration, error) {
+func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, aiPool *ai.ClientPool, db *mongo.Database, pdfsCollection, messagesCollection, crawlsCollection *mongo.Collection, client *models.Client, message, sessionID string, knownFacts map[string]string, structured bool, hint personaHint) (string, int, time.Duration, *aiResponseMeta, error) {
 	// ✅ START: Performance tracking - start overall timer
 	overallStart := time.Now()
 	var phaseTimings models.PhaseTimings
+	// ✅ NEW: Decide once per request whether this exchange is logged in full
+	sampled := logger.ShouldSample(cfg.AILogSampleRate)
 
 	// Check contact collection state
-	phase, chatDisabled, err := getContactCollectionState(ctx, messagesCollection, client.ID, sessionID)
+	contactState, err := loadContactCollectionState(ctx, messagesCollection, client.ID, sessionID)
 	if err != nil {
-		fmt.Printf("Warning: Failed to get contact collection state: %v\n", err)
-		phase = "none"
-		chatDisabled = false
+		logger.Warn("Failed to get contact collection state", "error", err, "client_id", client.ID.Hex(), "session_id", sessionID)
+		contactState = contactCollectionState{Phase: "none"}
 	}
+	phase, chatDisabled := contactState.Phase, contactState.ChatDisabled
 
 	// If chat is disabled, return completion message
 	if chatDisabled {
-		return "Thank you! Hamari team aapse jald hi contact karegi. Chat session completed.", 30, 0, nil
+		return contactCompletionMessageForClient(cfg, client), 30, 0, nil, nil
 	}
 
-	// Initialize Gemini client for token counting and summarization
-	geminiClient, err := genai.NewClient(ctx, option.WithAPIKey(cfg.GeminiAPIKey))
+	// ✅ NEW: Gemini clients come from the long-lived pool instead of being created per request
+	clientInitStart := time.Now()
+	geminiClient, err := aiPool.Client()
 	if err != nil {
-		return "", 0, 0, fmt.Errorf("failed to initialize Gemini client: %w", err)
+		return "", 0, 0, nil, fmt.Errorf("failed to initialize Gemini client: %w", err)
 	}
-	defer geminiClient.Close()
 
 	// Configure model
-	model := configureGeminiModel(geminiClient)
+	model := configureGeminiModel(geminiClient, client.SafetySettings)
 
 	// Initialize SummarizationService
-	aiGeminiClient, err := ai.NewGeminiClient(cfg.GeminiAPIKey, "free")
+	aiGeminiClient, err := aiPool.GeminiClient("free")
 	if err != nil {
-		return "", 0, 0, fmt.Errorf("failed to initialize AI Gemini client: %w", err)
+		return "", 0, 0, nil, fmt.Errorf("failed to initialize AI Gemini client: %w", err)
 	}
-	defer aiGeminiClient.Close()
+	phaseTimings.ClientInitMs = int(time.Since(clientInitStart).Milliseconds())
 	summarizationService := services.NewSummarizationService(aiGeminiClient)
 
 	// ✅ START: Context retrieval timing
 	contextStart := time.Now()
+	// ✅ NEW: Per-client chunk budget; each source may fill the whole budget if the other comes up short
+	contextChunks := contextChunksForClient(cfg, client)
 	// Retrieve PDF context - prefer Atlas Search/Vector when enabled
-	pdfChunks, err := retrievePDFContext(ctx, cfg, pdfsCollection, client.ID, message, 8)
+	pdfChunks, err := retrievePDFContext(ctx, cfg, pdfsCollection, client.ID, message, contextChunks)
 	if err != nil {
-		fmt.Printf("Warning: Failed to retrieve PDF context: %v\n", err)
+		logger.Warn("Failed to retrieve PDF context", "error", err, "client_id", client.ID.Hex())
 	} else {
 		// PDF chunks retrieved for context
 	}
 
 	// ✅ Retrieve crawled content context from completed crawl jobs
-	crawledChunks, err := retrieveCrawledContext(ctx, crawlsCollection, client.ID, message, 8)
+	crawledChunks, err := retrieveCrawledContext(ctx, crawlsCollection, client.ID, message, contextChunks, crawlFreshnessFor(cfg, client))
 	if err != nil {
-		fmt.Printf("Warning: Failed to retrieve crawled context: %v\n", err)
+		logger.Warn("Failed to retrieve crawled context", "error", err, "client_id", client.ID.Hex())
 	} else {
 		// Crawled chunks retrieved for context
 	}
 	phaseTimings.ContextRetrievalMs = int(time.Since(contextStart).Milliseconds())
 
 	// Combine PDF and crawled chunks
+	pdfChunks, crawledChunks = combineContextChunks(pdfChunks, crawledChunks, contextChunks)
 	var allContextChunks []models.ContentChunk
 	allContextChunks = append(allContextChunks, pdfChunks...)
 	allContextChunks = append(allContextChunks, crawledChunks...)
@@ -2681,14 +3579,14 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 	historyStart := time.Now()
 	// ✅ Token-aware history retrieval with summarization
 	conversationHistory, historySummary, tokensBefore, tokensAfter, summarized, summaryRefreshCount, err := getTokenAwareHistory(
-		ctx, messagesCollection, client.ID, sessionID, model, summarizationService,
+		ctx, messagesCollection, client.ID, sessionID, summarizationService,
 	)
 	if err != nil {
-		fmt.Printf("Warning: Token-aware history retrieval failed, falling back to simple retrieval: %v\n", err)
+		logger.Warn("Token-aware history retrieval failed, falling back to simple retrieval", "error", err, "session_id", sessionID)
 		// Fallback to simple history retrieval
 		conversationHistory, err = getConversationHistory(ctx, messagesCollection, client.ID, sessionID, 100)
 		if err != nil {
-			fmt.Printf("Warning: Failed to retrieve conversation history: %v\n", err)
+			logger.Warn("Failed to retrieve conversation history", "error", err, "session_id", sessionID)
 		}
 		historySummary = ""
 		tokensBefore = 0
@@ -2703,49 +3601,99 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 		phaseTimings.SummarizationMs = phaseTimings.HistoryLoadingMs / 2 // Approximate
 	}
 
-	// Build enhanced context with conversation history and summary
-	contextStr := buildContextWithHistory(allContextChunks, conversationHistory, historySummary)
-
 	// ✅ ADD AI PERSONA CONTENT TO CONTEXT
-	// Layer 2: Client-specific persona (highest priority)
-	if client.AIPersona != nil && client.AIPersona.Content != "" {
-		// Adding Client Persona (Layer 2) content to context
-		personaContext := fmt.Sprintf("AI PERSONALITY & KNOWLEDGE:\n%s\n\n---\n\n", client.AIPersona.Content)
-		contextStr = personaContext + contextStr
+	var personaContent string
+	personaSource := "none" // ✅ NEW: for prompt inspection
+	// ✅ NEW: Layer 3: A client persona routed by widget selection, pre-question or topic
+	routedPersona := routePersona(ctx, db, messagesCollection, client.ID, sessionID, message, hint)
+	if routedPersona != nil {
+		personaContent = routedPersona.Content
+		personaSource = "persona"
+	} else if client.AIPersona != nil && client.AIPersona.Content != "" {
+		// Layer 2: Client-specific persona
+		personaContent = client.AIPersona.Content
+		personaSource = "client"
 	} else {
 		// Layer 1: Default persona (fallback if client doesn't have one)
 		// ✅ Use default persona when client has no documents - this is the expected behavior
 		// The default persona should contain generic instructions, not client-specific information
-		defaultPersona, err := getDefaultPersona(ctx, db)
+		defaultPersona, err := getDefaultPersona(ctx, cfg, db)
 		if err != nil {
-			fmt.Printf("Warning: Failed to retrieve default persona: %v\n", err)
+			logger.Warn("Failed to retrieve default persona", "error", err)
 		} else if defaultPersona != nil && defaultPersona.Content != "" {
-			// Adding Default Persona (Layer 1) content to context
-			personaContext := fmt.Sprintf("AI PERSONALITY & KNOWLEDGE:\n%s\n\n---\n\n", defaultPersona.Content)
-			contextStr = personaContext + contextStr
+			personaContent = defaultPersona.Content
+			personaSource = "default"
 		}
 	}
 
+	// Build enhanced context with conversation history and summary
+	// ✅ NEW: Persona, PDFs and crawl are placed in the client's source-of-truth order, each labelled with its authority
+	sourcePriority := sourcePriorityFor(client)
+	contextStr := buildContextWithHistory(buildSourceBlocks(sourcePriority, personaContent, pdfChunks, crawledChunks), conversationHistory, historySummary)
+
 	// ✅ START: Prompt building timing
 	promptStart := time.Now()
 	// Generate enhanced prompt with conversation context
 	// ✅ Pass hasDocuments flag to ensure proper handling when no documents exist
-	prompt := buildPromptWithHistory(client.Name, contextStr, conversationHistory, message, hasDocuments)
+	prompt := buildPromptWithHistory(promptTemplateForClient(cfg, client), companyNameFor(client), contextStr, conversationHistory, message, hasDocuments, client.FollowUpSuggestions, knownFacts)
 	phaseTimings.PromptBuildingMs = int(time.Since(promptStart).Milliseconds())
 
+	// ✅ NEW: Fail fast with the fallback while Gemini quota is exhausted
+	quotaBreaker := ai.SharedQuotaBreaker(cfg.GeminiBreakerThreshold,
+		time.Duration(cfg.GeminiBreakerWindow)*time.Second, time.Duration(cfg.GeminiBreakerCooldown)*time.Second)
+	if !quotaBreaker.Allow() {
+		logger.Warn("Gemini quota breaker open, returning fallback response", "client_id", client.ID.Hex())
+		go storePerformanceMetrics(db, client.ID, sessionID, phaseTimings, int(time.Since(overallStart).Milliseconds()),
+			0, "error", "quota_breaker_open", len(message), 0)
+		return ai.FallbackResponseText, 0, time.Since(overallStart), nil, nil
+	}
+
 	// ✅ START: AI generation timing
 	aiStart := time.Now()
 	// Generate response with timing
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	// ✅ NEW: Each Gemini call gets its own deadline; the SDK cancels the HTTP request when it passes
+	aiTimeout := aiGenerationTimeout(cfg, client)
+	// ✅ NEW: JSON mode for structured replies
+	generationPrompt := prompt
+	if structured {
+		setStructuredOutput(model, true)
+		generationPrompt += structuredOutputInstruction
+	}
+	genCtx, cancelGen := context.WithTimeout(ctx, aiTimeout)
+	resp, err := model.GenerateContent(genCtx, genai.Text(generationPrompt))
+	timedOut := err != nil && isAITimeout(genCtx, err)
+	cancelGen()
+	quotaBreaker.Record(err, isGeminiQuotaError(err))
 	aiLatency := time.Since(aiStart)
 	phaseTimings.AIGenerationMs = int(aiLatency.Milliseconds())
 
 	if err != nil {
+		// ✅ NEW: Report safety blocks distinctly so the widget can handle them
+		var blocked *genai.BlockedError
+		if errors.As(err, &blocked) {
+			logger.Warn("Gemini response blocked by safety settings",
+				"client_id", client.ID.Hex(), "session_id", sessionID,
+				"reason", blocked.Error(), "categories", blockedSafetyCategories(blocked))
+			go storePerformanceMetrics(db, client.ID, sessionID, phaseTimings, int(time.Since(overallStart).Milliseconds()),
+				0, "error", "blocked_by_safety_settings", len(message), 0)
+			return "", 0, time.Since(overallStart), nil, fmt.Errorf("%w: %v", errContentBlocked, err)
+		}
+
+		// ✅ NEW: Report timeouts distinctly from other generation failures
+		if timedOut {
+			logger.Warn("Gemini generation timed out",
+				"client_id", client.ID.Hex(), "session_id", sessionID,
+				"timeout_ms", aiTimeout.Milliseconds(), "prompt_chars", len(prompt))
+			go storePerformanceMetrics(db, client.ID, sessionID, phaseTimings, int(time.Since(overallStart).Milliseconds()),
+				0, "error", "ai_timeout", len(message), 0)
+			return "", 0, time.Since(overallStart), nil, fmt.Errorf("%w after %s: %v", errAITimeout, aiTimeout, err)
+		}
+
 		userFriendlyErr := mapToUserFriendlyError(err, "AI generation failed")
 		// Store performance metrics for error case
 		go storePerformanceMetrics(db, client.ID, sessionID, phaseTimings, int(time.Since(overallStart).Milliseconds()), 
 			0, "error", userFriendlyErr.UserMessage, len(message), 0)
-		return "", 0, time.Since(overallStart), fmt.Errorf("generation failed: %w", err)
+		return "", 0, time.Since(overallStart), nil, fmt.Errorf("generation failed: %w", err)
 	}
 
 	// Extract response text
@@ -2754,7 +3702,39 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 		userFriendlyErr := mapToUserFriendlyError(err, "Failed to extract AI response")
 		// Store performance metrics for error case
 		go storePerformanceMetrics(db, client.ID, sessionID, phaseTimings, 0, 0, "error", userFriendlyErr.UserMessage, len(message), 0)
-		return "", 0, time.Since(overallStart), fmt.Errorf("generation failed: %w", err)
+		return "", 0, time.Since(overallStart), nil, fmt.Errorf("generation failed: %w", err)
+	}
+
+	// ✅ NEW: Unpack the structured reply; if it isn't valid JSON, generate a plain text reply instead
+	var structuredReply *models.StructuredReply
+	if structured {
+		setStructuredOutput(model, false)
+		parsed, parseErr := parseStructuredReply(replyText)
+		if parseErr == nil {
+			structuredReply = parsed
+			replyText = parsed.Reply
+		} else {
+			logger.Warn("Structured reply was not valid JSON, falling back to plain text",
+				"client_id", client.ID.Hex(), "session_id", sessionID, "error", parseErr)
+			if !quotaBreaker.Allow() {
+				logger.Warn("Gemini quota breaker open, skipping structured fallback", "client_id", client.ID.Hex())
+				go storePerformanceMetrics(db, client.ID, sessionID, phaseTimings, int(time.Since(overallStart).Milliseconds()),
+					0, "error", "quota_breaker_open", len(message), 0)
+				return ai.FallbackResponseText, 0, time.Since(overallStart), nil, nil
+			}
+			plainCtx, cancelPlain := context.WithTimeout(ctx, aiTimeout)
+			resp, err = model.GenerateContent(plainCtx, genai.Text(prompt))
+			cancelPlain()
+			quotaBreaker.Record(err, isGeminiQuotaError(err))
+			if err == nil {
+				replyText, err = extractResponseText(resp)
+			}
+			if err != nil {
+				go storePerformanceMetrics(db, client.ID, sessionID, phaseTimings, int(time.Since(overallStart).Milliseconds()),
+					0, "error", "structured_fallback_failed", len(message), 0)
+				return "", 0, time.Since(overallStart), nil, fmt.Errorf("generation failed: %w", err)
+			}
+		}
 	}
 
 	// ✅ START: Response length validation
@@ -2762,20 +3742,23 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 	topicDepth := getTopicDepth(conversationHistory, message)
 	valid, validatedText, action := validateResponseLength(replyText, topicDepth)
 	if !valid {
-		fmt.Printf("Warning: Response length validation failed (depth=%d, word_count=%d, action=%s)\n", 
-			topicDepth, countWords(replyText), action)
+		logger.Debug("Response length validation failed",
+			"depth", topicDepth, "word_count", countWords(replyText), "action", action, "session_id", sessionID)
 		// If too short and we can regenerate, try once more
-		if action == "expand" {
+		if action == "expand" && quotaBreaker.Allow() {
 			// Try to expand the response
 			expandedPrompt := prompt + "\n\nIMPORTANT: The previous response was too short. Please provide a more detailed and comprehensive answer."
 			aiStart2 := time.Now()
-			resp2, err2 := model.GenerateContent(ctx, genai.Text(expandedPrompt))
+			expandCtx, cancelExpand := context.WithTimeout(ctx, aiTimeout)
+			resp2, err2 := model.GenerateContent(expandCtx, genai.Text(expandedPrompt))
+			cancelExpand()
+			quotaBreaker.Record(err2, isGeminiQuotaError(err2))
 			if err2 == nil {
 				replyText2, err2 := extractResponseText(resp2)
 				if err2 == nil && countWords(replyText2) > countWords(replyText) {
 					replyText = replyText2
 					phaseTimings.AIGenerationMs += int(time.Since(aiStart2).Milliseconds())
-					fmt.Printf("Successfully expanded response from %d to %d words\n", countWords(validatedText), countWords(replyText))
+					logger.Debug("Expanded short response", "from_words", countWords(validatedText), "to_words", countWords(replyText))
 				}
 			}
 		} else if action == "condense" {
@@ -2784,12 +3767,44 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 			words := strings.Fields(replyText)
 			if len(words) > maxWords {
 				replyText = strings.Join(words[:maxWords], " ") + "..."
-				fmt.Printf("Truncated response from %d to %d words\n", len(words), maxWords)
+				logger.Debug("Truncated long response", "from_words", len(words), "to_words", maxWords)
 			}
 		}
 	}
 	phaseTimings.ValidationMs = int(time.Since(validationStart).Milliseconds())
 
+	// ✅ NEW: Regenerate once if the reply nearly repeats one of the recent replies in the session
+	// The regeneration is skipped while the quota breaker is open; the repeat is still recorded
+	previous, repeatedReply := isRepeatedReply(cfg, replyText, conversationHistory)
+	if repeatedReply && quotaBreaker.Allow() {
+		logger.Debug("Reply repeats an earlier reply, regenerating", "client_id", client.ID.Hex(), "session_id", sessionID)
+		aiStart3 := time.Now()
+		variedCtx, cancelVaried := context.WithTimeout(ctx, aiTimeout)
+		resp3, err3 := model.GenerateContent(variedCtx, genai.Text(prompt+replyVariationInstruction(previous)))
+		cancelVaried()
+		quotaBreaker.Record(err3, isGeminiQuotaError(err3))
+		phaseTimings.AIGenerationMs += int(time.Since(aiStart3).Milliseconds())
+		if err3 == nil {
+			if variedText, err3 := extractResponseText(resp3); err3 == nil && strings.TrimSpace(variedText) != "" {
+				replyText = variedText
+			}
+		}
+	}
+
+	// ✅ NEW: Strict grounding replaces sentences the context, persona and question don't support
+	strippedClaims := 0
+	if client.StrictGroundingEnabled {
+		knowledgeTexts := []string{personaContent, message}
+		for _, chunk := range allContextChunks {
+			knowledgeTexts = append(knowledgeTexts, chunk.Text)
+		}
+		replyText, strippedClaims = enforceGrounding(replyText, newGroundingKnowledge(knowledgeTexts...), strictGroundingMinOverlap(cfg))
+		if strippedClaims > 0 {
+			logger.Info("Strict grounding stripped unsupported claims",
+				"client_id", client.ID.Hex(), "session_id", sessionID, "stripped_claims", strippedClaims)
+		}
+	}
+
 	// Calculate token cost including conversation history
 	allParts := []genai.Part{
 		genai.Text(message),
@@ -2800,26 +3815,74 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 	tokenCost, err := calculateAccurateTokens(ctx, model, allParts...)
 	if err != nil {
 		// Fallback to estimation if accurate calculation fails
-		fmt.Printf("Warning: Accurate token calculation failed, using estimation: %v\n", err)
+		logger.Warn("Accurate token calculation failed, using estimation", "error", err)
 		tokenCost = estimateTokenCostWithHistory(message, replyText, len(allContextChunks), len(conversationHistory))
 	}
 
-	// Log detailed token usage and metrics for observability
-	fmt.Printf("[tokens] input_parts=%d token_cost=%d latency_ms=%d session=%s client=%s tokens_before=%d tokens_after=%d summarized=%t summary_refresh_count=%d\n",
-		len(allParts), tokenCost, int(time.Since(overallStart).Milliseconds()), sessionID, client.ID.Hex(), tokensBefore, tokensAfter, summarized, summaryRefreshCount)
+	// ✅ NEW: Attribute the reply to the PDF/crawl chunks it most likely drew from
+	attribution := attributeReplyToSources(replyText, pdfChunks, crawledChunks)
+	resolveWinningSource(attribution, sourcePriority, replyText, personaContent) // ✅ NEW
+	meta := &aiResponseMeta{
+		SourceAttribution: attribution,
+		Confidence:        scoreResponseConfidence(message, replyText, allContextChunks, personaContent, attribution),
+	}
+	if routedPersona != nil {
+		meta.Persona = routedPersona
+	}
+	meta.RepeatedReply = repeatedReply // ✅ NEW
+	meta.StrippedClaims = strippedClaims
+	if structuredReply != nil {
+		// Keep the structured reply in step with any length adjustment above
+		structuredReply.Reply = replyText
+		meta.Structured = structuredReply
+	}
+	// ✅ NEW: Quick-reply buttons derived from the topic, at no token cost
+	if client.Branding.ShowQuickReplies {
+		var suggestedActions []string
+		if structuredReply != nil {
+			suggestedActions = structuredReply.SuggestedActions
+		}
+		meta.QuickReplies = quickReplySuggestions(conversationHistory, message, suggestedActions)
+	}
+	// ✅ NEW: Keep the redacted prompt for clients inspecting what the model saw
+	if client.PromptInspectionEnabled {
+		meta.PromptSnapshot = newPromptSnapshot(cfg, client, sessionID, contextStr, personaSource, personaContent,
+			conversationHistory, message, knownFacts, pdfChunks, crawledChunks)
+	}
+
+	// ✅ NEW: Sampled structured log of the full exchange (redacted) for observability
+	if sampled {
+		logger.Debug("AI exchange",
+			"client_id", client.ID.Hex(),
+			"session_id", sessionID,
+			"prompt", logger.Redact(prompt, cfg.AILogMaxChars),
+			"response", logger.Redact(replyText, cfg.AILogMaxChars),
+			"input_parts", len(allParts),
+			"token_cost", tokenCost,
+			"history_tokens_before", tokensBefore,
+			"history_tokens_after", tokensAfter,
+			"summarized", summarized,
+			"summary_refresh_count", summaryRefreshCount,
+			"context_chunks", len(allContextChunks),
+			"latency_ms", int(time.Since(overallStart).Milliseconds()),
+			"phases", phaseTimings,
+			"source_attribution", attribution,
+		)
+	}
 
 	// Handle contact collection state management
 	newPhase := phase
+	contactThreshold := contactIntentThresholdForClient(cfg, client) // ✅ NEW
 	var userName, userEmail string
 	var shouldDisableChat bool
 
 	// Check if this is a contact query and we're not already in collection mode
-	if isContactQuery(message) && phase == "none" {
+	if isContactQuery(message, contactThreshold) && phase == "none" {
 		newPhase = "awaiting_name"
 	}
 
 	// Check if user provided name (awaiting_name phase)
-	if phase == "awaiting_name" && !isContactQuery(message) {
+	if phase == "awaiting_name" && !isContactQuery(message, contactThreshold) {
 		// Try to extract name from the message
 		extractedName := extractNameFromMessage(message)
 		if extractedName != "" {
@@ -2860,41 +3923,36 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 		}
 	}
 
-	// Check if AI response indicates completion (fallback)
-	if strings.Contains(replyText, "Hamari team aapse jald hi contact karegi") && phase != "none" {
-		newPhase = "completed"
-		shouldDisableChat = true
-		// If we're completing, we need to get the user name and email from the conversation
-		if userName == "" || userEmail == "" {
-			// Get the latest user name and email from the conversation
-			filter := bson.M{
-				"client_id":       client.ID,
-				"conversation_id": sessionID,
-				"is_embed_user":   true,
-			}
-			opts := options.FindOne().SetSort(bson.M{"timestamp": -1})
-			var latestMessage models.Message
-			err := messagesCollection.FindOne(ctx, filter, opts).Decode(&latestMessage)
-			if err == nil {
-				if userName == "" && latestMessage.UserName != "" {
-					userName = latestMessage.UserName
-				}
-				if userEmail == "" && latestMessage.UserEmail != "" {
-					userEmail = latestMessage.UserEmail
-				}
-			}
+	// ✅ NEW: Complete from the stored state when the name and email were collected across earlier turns
+	// (e.g. the email was given before the name), rather than from wording in the AI reply. Only details
+	// given in this conversation count, not a name or email remembered from the visitor's IP.
+	if phase != "none" && newPhase != "completed" {
+		collectedName, collectedEmail := userName, userEmail
+		if collectedName == "" {
+			collectedName = contactState.Name
+		}
+		if collectedEmail == "" {
+			collectedEmail = contactState.Email
+		}
+		if collectedName != "" && collectedEmail != "" {
+			userName, userEmail = collectedName, collectedEmail
+			newPhase = "completed"
+			shouldDisableChat = true
 		}
 	}
 
 	// Update contact collection state if it changed
 	if newPhase != phase || userName != "" || userEmail != "" {
-		fmt.Printf("Contact collection state update: phase=%s->%s, userName=%s, userEmail=%s, chatDisabled=%v\n",
-			phase, newPhase, userName, userEmail, shouldDisableChat)
+		logger.Debug("Contact collection state update",
+			"from_phase", phase, "to_phase", newPhase, "has_name", userName != "", "has_email", userEmail != "", "chat_disabled", shouldDisableChat)
 		err := updateContactCollectionState(ctx, messagesCollection, client.ID, sessionID, newPhase, userName, userEmail, shouldDisableChat)
 		if err != nil {
-			fmt.Printf("Warning: Failed to update contact collection state: %v\n", err)
-		} else {
-			fmt.Printf("Successfully updated contact collection state\n")
+			logger.Warn("Failed to update contact collection state", "error", err, "session_id", sessionID)
+		}
+
+		// ✅ NEW: Push the finished conversation to the client's CRM
+		if newPhase == "completed" && phase != "completed" {
+			enqueueCRMSyncOnCompletion(db, client, sessionID)
 		}
 
 		// ✅ NEW: Store the name by IP for future conversations
@@ -2916,9 +3974,7 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 				if err == nil && latestMessage.UserIP != "" {
 					err := storeUserNameByIP(storeCtx, messagesCollection, latestMessage.UserIP, userName, userEmail, client.ID)
 					if err != nil {
-						fmt.Printf("Warning: Failed to store name by IP: %v\n", err)
-					} else {
-						fmt.Printf("Stored name '%s' for IP %s from contact collection\n", userName, latestMessage.UserIP)
+						logger.Warn("Failed to store name by IP", "error", err, "client_id", client.ID.Hex())
 					}
 				}
 			}()
@@ -2928,7 +3984,15 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 	// ✅ NEW: Update conversation state when demo is confirmed
 	isDemoConfirmed := checkDemoConfirmed(conversationHistory, message)
 	demoTime := extractDemoTime(conversationHistory, message)
-	if isDemoConfirmed || demoTime != "" {
+	// ✅ NEW: Resolve the mentioned time to a datetime in the client's timezone
+	demoLoc := analyticsLocation(client)
+	var demoAt *time.Time
+	if isDemoConfirmed || demoTime != "" || conversationMentionsDemo(conversationHistory, message) {
+		if at, ok := extractDemoDateTime(conversationHistory, message, time.Now(), demoLoc); ok {
+			demoAt = &at
+		}
+	}
+	if isDemoConfirmed || demoTime != "" || demoAt != nil {
 		stateUpdates := map[string]interface{}{}
 		if isDemoConfirmed {
 			stateUpdates["demo_scheduled"] = true
@@ -2937,6 +4001,10 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 		if demoTime != "" {
 			stateUpdates["demo_time"] = demoTime
 		}
+		if demoAt != nil {
+			stateUpdates["demo_at"] = *demoAt
+			stateUpdates["demo_timezone"] = demoLoc.String()
+		}
 
 		if len(stateUpdates) > 0 {
 			go func() {
@@ -2945,14 +4013,39 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 
 				err := updateConversationState(stateCtx, messagesCollection, client.ID, sessionID, stateUpdates)
 				if err != nil {
-					fmt.Printf("Warning: Failed to update conversation state: %v\n", err)
-				} else {
-					fmt.Printf("Successfully updated conversation state: %+v\n", stateUpdates)
+					logger.Warn("Failed to update conversation state", "error", err, "session_id", sessionID)
 				}
 			}()
 		}
 	}
 
+	// ✅ NEW: Hand the user a Calendly booking link once the demo is confirmed
+	if isDemoConfirmed {
+		bookingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		booking := scheduleDemoBooking(bookingCtx, cfg, messagesCollection, client, sessionID, demoTime, demoAt)
+		cancel()
+		if booking != nil {
+			meta.DemoBooking = booking
+			replyText += fmt.Sprintf("\n\n📅 Pick a time for your demo here: %s", booking.BookingURL)
+		}
+	}
+
+	// ✅ NEW: Offer a WhatsApp deep link when the user asks to continue there
+	if isWhatsAppHandoffRequest(message) {
+		handoffCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		handoff, err := mintWhatsAppHandoff(handoffCtx, messagesCollection, client, sessionID)
+		cancel()
+		if err != nil {
+			logger.Warn("Failed to create WhatsApp handoff", "error", err, "session_id", sessionID)
+		} else if handoff != nil {
+			meta.WhatsAppHandoff = handoff
+			replyText += fmt.Sprintf("\n\n💬 Continue on WhatsApp: %s", handoff.Link)
+		}
+	}
+
+	// ✅ NEW: Auto-tag the conversation from intent score and topics
+	go autoTagConversation(db, client.ID, sessionID, conversationHistory, message)
+
 	// Debug: Log current state for troubleshooting
 	// Contact collection phase check
 	// Removed debug logging for production readiness
@@ -2962,7 +4055,7 @@ func generateAIResponseWithMemory(ctx context.Context, cfg *config.Config, db *m
 	go storePerformanceMetrics(db, client.ID, sessionID, phaseTimings, int(totalLatency.Milliseconds()), 
 		tokenCost, "success", "", len(message), countWords(replyText))
 
-	return replyText, tokenCost, totalLatency, nil
+	return replyText, tokenCost, totalLatency, meta, nil
 }
 
 // getConversationHistory retrieves recent conversation history
@@ -2997,26 +4090,25 @@ func getConversationHistory(ctx context.Context, collection *mongo.Collection, c
 	return messages, nil
 }
 
-// calculateHistoryTokens calculates total token count for conversation history
-func calculateHistoryTokens(ctx context.Context, model *genai.GenerativeModel, messages []models.Message) (int, error) {
-	if len(messages) == 0 {
-		return 0, nil
+// ✅ NEW: Local token estimation
+// estimateTextTokens approximates Gemini's token count without an API call: about four characters per
+// token, but at least one token per word, which keeps short-word and non-Latin text from being undercounted.
+// It is used for history truncation decisions; billed cost still comes from CountTokens.
+func estimateTextTokens(text string) int {
+	tokens := (utf8.RuneCountInString(text) + 3) / 4
+	if words := len(strings.Fields(text)); words > tokens {
+		tokens = words
 	}
+	return tokens
+}
 
-	// Build text representation of history for token counting
-	var historyText strings.Builder
+// estimateHistoryTokens estimates the token count of conversation history as it appears in the prompt
+func estimateHistoryTokens(messages []models.Message) int {
+	total := 0
 	for _, msg := range messages {
-		historyText.WriteString(fmt.Sprintf("User: %s\nAssistant: %s\n\n", msg.Message, msg.Reply))
-	}
-
-	// Use accurate token counting
-	tokenCount, err := calculateAccurateTokens(ctx, model, genai.Text(historyText.String()))
-	if err != nil {
-		// Fallback to estimation if accurate calculation fails
-		return len(historyText.String()) / 4, nil
+		total += estimateTextTokens("User: "+msg.Message+"\nAssistant: "+msg.Reply) + 2
 	}
-
-	return tokenCount, nil
+	return total
 }
 
 // getTokenAwareHistory retrieves conversation history with token-aware truncation and summarization
@@ -3025,7 +4117,6 @@ func getTokenAwareHistory(
 	messagesCollection *mongo.Collection,
 	clientID primitive.ObjectID,
 	sessionID string,
-	model *genai.GenerativeModel,
 	summarizationService *services.SummarizationService,
 ) (recentMessages []models.Message, summary string, tokensBefore int, tokensAfter int, summarized bool, summaryRefreshCount int, err error) {
 	// Get all messages (up to a reasonable limit)
@@ -3038,11 +4129,8 @@ func getTokenAwareHistory(
 		return nil, "", 0, 0, false, 0, nil
 	}
 
-	// Calculate total tokens in history
-	tokensBefore, err = calculateHistoryTokens(ctx, model, allMessages)
-	if err != nil {
-		return nil, "", 0, 0, false, 0, fmt.Errorf("failed to calculate history tokens: %w", err)
-	}
+	// Calculate total tokens in history (estimated locally; only the final cost is counted by the API)
+	tokensBefore = estimateHistoryTokens(allMessages)
 
 	// If within limit, return all messages without summarization
 	if tokensBefore <= MAX_HISTORY_TOKENS {
@@ -3061,10 +4149,7 @@ func getTokenAwareHistory(
 	oldMessages := allMessages[:len(allMessages)-RECENT_MESSAGES_COUNT]
 
 	// Calculate tokens for recent messages
-	recentTokens, err := calculateHistoryTokens(ctx, model, recentMessages)
-	if err != nil {
-		return nil, "", 0, 0, false, 0, fmt.Errorf("failed to calculate recent message tokens: %w", err)
-	}
+	recentTokens := estimateHistoryTokens(recentMessages)
 
 	// Try to get or create summary for old messages
 	summary, summaryRefreshCount, err = getOrCreateConversationSummary(
@@ -3078,7 +4163,7 @@ func getTokenAwareHistory(
 	}
 
 	// Calculate final token count (recent messages + summary)
-	summaryTokens := len(summary) / 4 // Estimation for summary tokens
+	summaryTokens := estimateTextTokens(summary)
 	tokensAfter = recentTokens + summaryTokens
 	summarized = true
 
@@ -3679,20 +4764,11 @@ func extractDemoTime(history []models.Message, currentMessage string) string {
 }
 
 // buildContextWithHistory creates context string including conversation history and optional summary
-func buildContextWithHistory(chunks []models.ContentChunk, history []models.Message, historySummary string) string {
+func buildContextWithHistory(sourceBlocks string, history []models.Message, historySummary string) string {
 	var contextStr strings.Builder
 
-	// Add PDF context first (more important for company info)
-	if len(chunks) > 0 {
-		// Building context with PDF chunks
-		contextStr.WriteString("COMPANY INFORMATION:\n\n")
-		for _, chunk := range chunks {
-			contextStr.WriteString(fmt.Sprintf("%s\n\n", chunk.Text))
-		}
-		contextStr.WriteString("---\n\n")
-	} else {
-		// No PDF chunks available for context
-	}
+	// ✅ UPDATED: Persona and company information, already ordered by source priority (see buildSourceBlocks)
+	contextStr.WriteString(sourceBlocks)
 
 	// Add conversation summary if available (older messages)
 	if historySummary != "" {
@@ -3715,10 +4791,25 @@ func buildContextWithHistory(chunks []models.ContentChunk, history []models.Mess
 	return contextStr.String()
 }
 
-func buildPromptWithHistory(clientName, contextStr string, history []models.Message, currentMessage string, hasDocuments bool) string {
+// buildPromptWithHistory renders the prompt template with the built-in prompt sections and
+// the raw client name, context, conversation and message (see promptTemplatePlaceholders)
+func buildPromptWithHistory(template, companyName, contextStr string, history []models.Message, currentMessage string, hasDocuments bool, followUps map[string]string, knownFacts map[string]string) string {
 	hasHistory := len(history) > 0
 	var prompt strings.Builder
 
+	// ✅ NEW: Each block of the built-in prompt becomes a template section
+	sections := map[string]string{
+		"client_name":  companyName,
+		"context":      contextStr,
+		"conversation": formatPromptConversation(history),
+		"message":      currentMessage,
+		"known_facts":  formatKnownFacts(knownFacts), // ✅ NEW: signed context from the host site
+	}
+	endSection := func(name string) {
+		sections[name] = prompt.String()
+		prompt.Reset()
+	}
+
 	// ========================================
 	// 🚨 CRITICAL: CLIENT DATA ISOLATION
 	// ========================================
@@ -3727,13 +4818,15 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 	prompt.WriteString("1. Use ONLY the persona and documents provided below for THIS client\n")
 	prompt.WriteString("2. NEVER reference data from other clients, previous conversations with different clients, or generic examples\n")
 	prompt.WriteString("3. NEVER use placeholder data (555-xxx-xxxx, info@company.com, etc.)\n")
-	prompt.WriteString("4. If information is NOT in the client's persona or documents, say: 'I don't have that information for our company'\n")
+	prompt.WriteString(fmt.Sprintf("4. If information is NOT in the client's persona or documents, say: 'I don't have that information for %s'\n", companyName))
 	prompt.WriteString("5. CRITICAL: This client's data is SACRED - treat it as the ONLY source of truth\n\n")
 
+	endSection("isolation")
+
 	// ========================================
 	// ✅ CHECK FOR AI PERSONA
 	// ========================================
-	hasPersona := strings.Contains(contextStr, "AI PERSONALITY & KNOWLEDGE:")
+	hasPersona := strings.Contains(contextStr, personaContextLabel)
 
 	// ========================================
 	// 🎯 PERSONA-FIRST ARCHITECTURE
@@ -3768,7 +4861,7 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 			prompt.WriteString("2. NEVER invent company-specific details not mentioned in the persona\n")
 			prompt.WriteString("3. If persona contains pricing/services/contact info, SHARE it confidently\n")
 			prompt.WriteString("4. If persona lacks specific details, acknowledge the limitation honestly\n")
-			prompt.WriteString(fmt.Sprintf("5. When asked about company name, use: '%s' (unless persona specifies otherwise)\n", clientName))
+			prompt.WriteString(fmt.Sprintf("5. When asked about company name, use: '%s' (unless persona specifies otherwise)\n", companyName))
 			prompt.WriteString("6. DO NOT reference 'documents', 'PDFs', or 'knowledge base' in responses\n\n")
 		} else if hasPersona {
 			prompt.WriteString("PERSONA + DOCUMENTS MODE:\n")
@@ -3776,7 +4869,8 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 			prompt.WriteString("• Persona defines HOW you communicate (tone, style, priorities)\n")
 			prompt.WriteString("• Documents contain WHAT information you can share (services, policies, details)\n")
 			prompt.WriteString("• Use persona to guide your responses, documents to provide specific information\n")
-			prompt.WriteString("• If information exists in EITHER source, share it confidently\n\n")
+			prompt.WriteString("• If information exists in EITHER source, share it confidently\n")
+			prompt.WriteString("• If the sources contradict each other, follow the SOURCE PRIORITY ranking in the knowledge base\n\n") // ✅ NEW
 		} else {
 			prompt.WriteString("DOCUMENTS-ONLY MODE:\n")
 			prompt.WriteString("• You have company documents/PDFs with detailed information\n")
@@ -3789,12 +4883,14 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 		// ❌ ZERO KNOWLEDGE STATE
 		// ========================================
 		prompt.WriteString("⚠️ LIMITED INFORMATION MODE:\n")
-		prompt.WriteString(fmt.Sprintf("You are a customer support representative for %s.\n", clientName))
+		prompt.WriteString(fmt.Sprintf("You are a customer support representative for %s.\n", companyName))
 		prompt.WriteString("Currently, you don't have access to detailed company information.\n")
 		prompt.WriteString("Politely inform customers you'll connect them with the team for specific details.\n")
-		prompt.WriteString(fmt.Sprintf("CRITICAL: Use company name '%s' consistently. Do NOT use any other company name.\n\n", clientName))
+		prompt.WriteString(fmt.Sprintf("CRITICAL: Use company name '%s' consistently. Do NOT use any other company name.\n\n", companyName))
 	}
 
+	endSection("knowledge")
+
 	// ========================================
 	// 🌐 MULTI-LANGUAGE SUPPORT
 	// ========================================
@@ -3834,6 +4930,8 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 	prompt.WriteString("• Use markdown **bold** for key terms (2-4 per message)\n")
 	prompt.WriteString("• End with context-specific follow-up questions (not generic)\n\n")
 
+	endSection("guidelines")
+
 	// ========================================
 	// 📊 PROGRESSIVE DISCLOSURE & FOLLOW-UP QUESTIONS
 	// ========================================
@@ -3854,28 +4952,9 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 	lastTopic := detectLastTopic(history, currentMessage)
 	topicDepth := getTopicDepth(history, currentMessage)
 
-	// Context-specific follow-up map
-	contextMap := map[string]string{
-		"pricing":    "For a 1 lac campaign at ₹60,000, that's 60 paisa per message. What's your target cost per acquisition?",
-		"database":   "Which cities/states should we prioritize for your campaigns? I can check our database availability.",
-		"delivery":   "With 80% delivery on 1 lac messages, that's 80,000 potential customers. What conversion rate are you targeting?",
-		"conversion": "Our real estate clients typically see 3-5% lead conversion. What would 3,000-4,000 qualified leads mean for your business?",
-		"demo":       "I can arrange a 5-minute live demo today. Morning (11 AM-1 PM) or evening (5-7 PM) - which suits you?",
-		"messaging":  "What scale are you planning for? This helps me suggest the best package and delivery timeline.",
-		"general":    "What specific aspect would you like to explore next?",
-	}
-
-	if followUp, exists := contextMap[lastTopic]; exists {
-		prompt.WriteString(fmt.Sprintf("✅ USE THIS FOLLOW-UP (based on last topic '%s'):\n", lastTopic))
-		prompt.WriteString(fmt.Sprintf("   '%s'\n\n", followUp))
-	} else {
-		prompt.WriteString("✅ ALWAYS use context-specific questions based on the topic discussed:\n")
-		prompt.WriteString("   - After pricing: 'Would you like a detailed ROI breakdown for a 1 lac message campaign?'\n")
-		prompt.WriteString("   - After database info: 'Which cities/salary ranges should we target for your real estate projects?'\n")
-		prompt.WriteString("   - After delivery ratio: 'With 80% delivery, that's 80,000 potential customers. What's your conversion goal?'\n")
-		prompt.WriteString("   - After conversion info: 'What's your target for lead generation? I can show you how our CTA buttons achieve 15-25% click-through rates.'\n")
-		prompt.WriteString("   - After demo discussion: 'What time works best for you? I can schedule a 5-minute demo to show you the platform.'\n\n")
-	}
+	// ✅ NEW: Follow-ups come from the client's per-topic suggestions, falling back to generic ones
+	prompt.WriteString(fmt.Sprintf("✅ USE THIS FOLLOW-UP (based on last topic '%s'):\n", lastTopic))
+	prompt.WriteString(fmt.Sprintf("   '%s'\n\n", followUpSuggestion(followUps, lastTopic)))
 
 	// Add topic depth information
 	prompt.WriteString(fmt.Sprintf("CURRENT TOPIC DEPTH: %d (provide depth-%d answer)\n", topicDepth, topicDepth))
@@ -3894,6 +4973,8 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 	prompt.WriteString("3. Confirm: 'Thank you! Our team will contact you shortly.' (END)\n")
 	prompt.WriteString("DO NOT trigger for general questions, pricing, services, or non-contact queries\n\n")
 
+	endSection("sales_playbook")
+
 	// ========================================
 	// 🔄 CONVERSATION CONTEXT
 	// ========================================
@@ -3976,11 +5057,15 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 		prompt.WriteString("• Immediately address their question\n\n")
 	}
 
+	endSection("history")
+
 	// ========================================
 	// ❓ CURRENT USER MESSAGE
 	// ========================================
 	prompt.WriteString(fmt.Sprintf("USER'S CURRENT MESSAGE: \"%s\"\n\n", currentMessage))
 
+	endSection("current_message")
+
 	// ========================================
 	// 🎯 RESPONSE TASK
 	// ========================================
@@ -4013,8 +5098,9 @@ func buildPromptWithHistory(clientName, contextStr string, history []models.Mess
 	prompt.WriteString("❌ Refusing to share information that EXISTS in your knowledge\n\n")
 
 	prompt.WriteString("REMEMBER: You serve ONE client with UNIQUE data. Treat their persona and documents as your ONLY source of truth.\n")
+	endSection("response_rules")
 
-	return prompt.String()
+	return services.RenderPlaceholders(template, sections)
 }
 
 // estimateTokenCostWithHistory provides token cost estimation including conversation history
@@ -4037,8 +5123,22 @@ func estimateTokenCostWithHistory(userMessage, aiReply string, contextChunks, hi
 // CONTACT COLLECTION STATE MANAGEMENT
 // ===================
 
+// contactCollectionState is a conversation's contact collection progress, read from its latest message
+type contactCollectionState struct {
+	Phase        string
+	ChatDisabled bool
+	Name         string // collected in this conversation, never remembered from the visitor's IP
+	Email        string
+}
+
 // getContactCollectionState retrieves the current contact collection state for a conversation
 func getContactCollectionState(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, sessionID string) (string, bool, error) {
+	state, err := loadContactCollectionState(ctx, collection, clientID, sessionID)
+	return state.Phase, state.ChatDisabled, err
+}
+
+// loadContactCollectionState returns the conversation's contact collection state including the details collected so far
+func loadContactCollectionState(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, sessionID string) (contactCollectionState, error) {
 	filter := bson.M{
 		"client_id":       clientID,
 		"conversation_id": sessionID,
@@ -4050,9 +5150,9 @@ func getContactCollectionState(ctx context.Context, collection *mongo.Collection
 	err := collection.FindOne(ctx, filter, opts).Decode(&message)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return "none", false, nil // Default state
+			return contactCollectionState{Phase: "none"}, nil // Default state
 		}
-		return "none", false, err
+		return contactCollectionState{Phase: "none"}, err
 	}
 
 	phase := message.ContactCollectionPhase
@@ -4060,7 +5160,12 @@ func getContactCollectionState(ctx context.Context, collection *mongo.Collection
 		phase = "none"
 	}
 
-	return phase, message.ChatDisabled, nil
+	return contactCollectionState{
+		Phase:        phase,
+		ChatDisabled: message.ChatDisabled,
+		Name:         message.ContactName,
+		Email:        message.ContactEmail,
+	}, nil
 }
 
 // updateContactCollectionState updates the contact collection state for a conversation
@@ -4082,9 +5187,11 @@ func updateContactCollectionState(ctx context.Context, collection *mongo.Collect
 	if userName != "" {
 		update["$set"].(bson.M)["user_name"] = userName
 		update["$set"].(bson.M)["from_name"] = userName // Also update from_name
+		update["$set"].(bson.M)["contact_name"] = userName
 	}
 	if userEmail != "" {
 		update["$set"].(bson.M)["user_email"] = userEmail
+		update["$set"].(bson.M)["contact_email"] = userEmail
 	}
 
 	// Update the most recent message
@@ -4127,25 +5234,10 @@ func updateContactCollectionState(ctx context.Context, collection *mongo.Collect
 	return nil
 }
 
-// isContactQuery checks if the message contains contact-related keywords
-func isContactQuery(message string) bool {
-	contactKeywords := []string{
-		"contact number", "phone number", "email", "how to contact", "reach you",
-		"get in touch", "support contact", "customer service", "helpline", "call",
-		"write to", "aapka contact", "aapka phone", "aapka email", "kaise contact kare",
-		"customer care", "support", "help", "office ka number", "business ka number",
-		"how i can connect", "how can i connect", "how to connect", "connect with you",
-		"connect with", "can i connect", "want to connect", "i want to connect",
-		"reach out", "contact you", "speak with", "talk to", "get in touch with",
-	}
-
-	messageLower := strings.ToLower(message)
-	for _, keyword := range contactKeywords {
-		if strings.Contains(messageLower, keyword) {
-			return true
-		}
-	}
-	return false
+// isContactQuery checks whether the message asks to contact the business, scoring contact intent
+// against threshold (see contactIntentScore)
+func isContactQuery(message string, threshold float64) bool {
+	return contactIntentScore(message) >= threshold
 }
 
 // isNameProvided checks if the message looks like a name
@@ -4478,60 +5570,147 @@ func updateConversationState(ctx context.Context, collection *mongo.Collection,
 	return nil
 }
 
-// ===================
-// UTILITY FUNCTIONS
-// ===================
+// ✅ NEW: Automatic demo scheduling via Calendly
 
-// fixContactCollectionForExistingConversations fixes contact collection state for existing conversations
-func fixContactCollectionForExistingConversations(ctx context.Context, collection *mongo.Collection) error {
-	// Find conversations where AI said completion message but state wasn't updated
-	filter := bson.M{
-		"reply": bson.M{
-			"$regex":   "Hamari team aapse jald hi contact karegi",
-			"$options": "i",
-		},
-		"is_embed_user":            true,
-		"contact_collection_phase": bson.M{"$ne": "completed"},
+// scheduleDemoBooking creates (once per conversation) a Calendly booking link for a confirmed demo.
+// With an API token and event type a single-use scheduling link is created; otherwise, or if the
+// API call fails, the client's public Calendly URL is returned prefilled with the captured name/email.
+// Returns nil if Calendly is not configured or the conversation already has a booking.
+// ✅ NEW: A resolved demoAt is stored on the booking and opens the link on that day.
+func scheduleDemoBooking(ctx context.Context, cfg *config.Config, collection *mongo.Collection, client *models.Client, sessionID, demoTime string, demoAt *time.Time) *models.DemoBooking {
+	if !client.CalendlyEnabled || (client.CalendlyURL == "" && (client.CalendlyAPIToken == "" || client.CalendlyEventTypeURI == "")) {
+		return nil
 	}
 
-	cursor, err := collection.Find(ctx, filter)
-	if err != nil {
-		return err
+	filter := bson.M{
+		"client_id":       client.ID,
+		"conversation_id": sessionID,
 	}
-	defer cursor.Close(ctx)
 
-	var messages []models.Message
-	if err := cursor.All(ctx, &messages); err != nil {
-		return err
+	// Only one booking per conversation
+	existing, err := collection.CountDocuments(ctx, bson.M{
+		"client_id":       client.ID,
+		"conversation_id": sessionID,
+		"demo_booking":    bson.M{"$exists": true},
+	}, options.Count().SetLimit(1))
+	if err != nil || existing > 0 {
+		return nil
 	}
 
-	for _, message := range messages {
-		// Update the message to completed state
-		update := bson.M{
-			"$set": bson.M{
-				"contact_collection_phase": "completed",
-				"chat_disabled":            true,
-			},
+	// Use the most recently captured name/email for prefill
+	var name, email string
+	opts := options.FindOne().SetSort(bson.M{"timestamp": -1})
+	var latestMessage models.Message
+	if err := collection.FindOne(ctx, bson.M{
+		"client_id":       client.ID,
+		"conversation_id": sessionID,
+		"user_name":       bson.M{"$nin": []interface{}{nil, ""}},
+	}, opts).Decode(&latestMessage); err == nil {
+		name = latestMessage.UserName
+		email = latestMessage.UserEmail
+	}
+	if email == "" {
+		if err := collection.FindOne(ctx, bson.M{
+			"client_id":       client.ID,
+			"conversation_id": sessionID,
+			"user_email":      bson.M{"$nin": []interface{}{nil, ""}},
+		}, opts).Decode(&latestMessage); err == nil {
+			email = latestMessage.UserEmail
 		}
+	}
 
-		_, err := collection.UpdateOne(ctx, bson.M{"_id": message.ID}, update)
-		if err != nil {
-			fmt.Printf("Failed to update message %s: %v\n", message.ID.Hex(), err)
+	booking := &models.DemoBooking{
+		Provider:  "calendly",
+		Name:      name,
+		Email:     email,
+		DemoTime:  demoTime,
+		DemoAt:    demoAt,
+		CreatedAt: time.Now(),
+	}
+	if demoAt != nil {
+		booking.Timezone = demoAt.Location().String()
+	}
+
+	if client.CalendlyAPIToken != "" && client.CalendlyEventTypeURI != "" {
+		var link *services.CalendlySchedulingLink
+		token, err := openIntegrationSecret(cfg, client.CalendlyAPIToken)
+		if err == nil {
+			link, err = services.NewCalendlyClient(token).CreateSchedulingLink(ctx, client.CalendlyEventTypeURI)
+		}
+		if err == nil {
+			booking.Method = "api"
+			booking.BookingURL = services.PrefillCalendlyURL(link.BookingURL, name, email)
+			booking.Reference = link.BookingURL
 		} else {
-			fmt.Printf("Updated message %s to completed state\n", message.ID.Hex())
+			logger.Warn("Calendly scheduling link creation failed, falling back to public link",
+				"error", err, "client_id", client.ID.Hex())
 		}
 	}
 
-	return nil
+	if booking.BookingURL == "" {
+		if client.CalendlyURL == "" {
+			return nil
+		}
+		booking.Method = "link"
+		booking.BookingURL = services.PrefillCalendlyURL(client.CalendlyURL, name, email)
+	}
+	if demoAt != nil {
+		booking.BookingURL = services.PrefillCalendlyDate(booking.BookingURL, *demoAt)
+	}
+
+	// Store the booking reference on the conversation's existing messages;
+	// the current message gets it through aiResponseMeta when persisted.
+	if _, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"demo_booking": booking}}); err != nil {
+		logger.Warn("Failed to store demo booking", "error", err, "session_id", sessionID)
+	}
+
+	return booking
+}
+
+// ===================
+// UTILITY FUNCTIONS
+// ===================
+
+// fixContactCollectionForExistingConversations completes contact collection for messages that hold both a
+// collected name and email but were left mid-collection, and returns how many were updated. It works from the
+// state fields only, so it doesn't depend on the wording of any reply.
+func fixContactCollectionForExistingConversations(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID) (int64, error) {
+	filter := bson.M{
+		"client_id":                clientID,
+		"is_embed_user":            true,
+		"contact_collection_phase": bson.M{"$in": []string{"awaiting_name", "awaiting_email"}},
+		"contact_name":             bson.M{"$nin": []interface{}{nil, ""}},
+		"contact_email":            bson.M{"$nin": []interface{}{nil, ""}},
+	}
+
+	result, err := collection.UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{
+			"contact_collection_phase": "completed",
+			"chat_disabled":            true,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
 }
 
 // handleFixContactCollection fixes contact collection state for existing conversations
 func handleFixContactCollection(messagesCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		clientObjID, err := primitive.ObjectIDFromHex(middleware.GetClientID(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 		defer cancel()
 
-		err := fixContactCollectionForExistingConversations(ctx, messagesCollection)
+		updated, err := fixContactCollectionForExistingConversations(ctx, messagesCollection, clientObjID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to fix contact collection state",
@@ -4542,6 +5721,7 @@ func handleFixContactCollection(messagesCollection *mongo.Collection) gin.Handle
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Contact collection state fixed successfully",
+			"updated": updated,
 		})
 	}
 }
@@ -4767,10 +5947,14 @@ func handleDebugContactState(messagesCollection *mongo.Collection) gin.HandlerFu
 
 		// Count by phase
 		phaseCounts := make(map[string]int)
+		sourceCounts := make(map[string]int)
 		hasNameEmail := 0
 		completedPhase := 0
 
 		for _, msg := range messages {
+			if msg.SourceAttribution != nil {
+				sourceCounts[msg.SourceAttribution.DominantSource]++
+			}
 			phase := msg.ContactCollectionPhase
 			if phase == "" {
 				phase = "none"
@@ -4802,6 +5986,7 @@ func handleDebugContactState(messagesCollection *mongo.Collection) gin.HandlerFu
 				"user_email":    msg.UserEmail,
 				"contact_phase": msg.ContactCollectionPhase,
 				"chat_disabled": msg.ChatDisabled,
+				"source_attribution": msg.SourceAttribution,
 				"timestamp":     msg.Timestamp,
 			})
 		}
@@ -4811,6 +5996,7 @@ func handleDebugContactState(messagesCollection *mongo.Collection) gin.HandlerFu
 			"phase_counts":    phaseCounts,
 			"has_name_email":  hasNameEmail,
 			"completed_phase": completedPhase,
+			"source_counts":   sourceCounts,
 			"recent_messages": detailedMessages,
 		})
 	}
@@ -5084,6 +6270,10 @@ func handleUpdateMessageNames(messagesCollection *mongo.Collection) gin.HandlerF
 
 // getClientConfig retrieves client configuration from database
 func getClientConfig(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID) (*models.Client, error) {
+	// ✅ NEW: Served from memory while fresh (see client_config_cache.go)
+	if cached, ok := sharedClientConfigCache.get(clientID); ok {
+		return cached, nil
+	}
 	var clientDoc models.Client
 	err := collection.FindOne(ctx, bson.M{"_id": clientID}).Decode(&clientDoc)
 	if err != nil {
@@ -5092,6 +6282,7 @@ func getClientConfig(ctx context.Context, collection *mongo.Collection, clientID
 		}
 		return nil, fmt.Errorf("database_error")
 	}
+	sharedClientConfigCache.set(clientDoc)
 	return &clientDoc, nil
 }
 
@@ -5117,25 +6308,44 @@ func handleClientError(c *gin.Context, err error) {
 }
 
 // persistMessage saves the conversation to database and returns the message ID
-func persistMessage(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, req ChatRequest, response string, tokenCost int, r *http.Request) (primitive.ObjectID, error) {
-	// Extract user information from request
-	userIP := utils.GetClientIP(r)
-	userAgent := utils.GetUserAgent(r)
-	referrer := utils.GetReferrer(r)
+func persistMessage(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, req ChatRequest, response string, tokenCost int, meta *aiResponseMeta, welcomeVariant string, tracking visitorTracking, r *http.Request) (primitive.ObjectID, error) {
+	// ✅ NEW: Without tracking consent, nothing identifying the visitor's device or location is recorded
+	// and the name isn't remembered by IP; geoData stays empty and userIP blank
+	var userIP, userAgent, referrer string
+	geoData := &utils.GeolocationData{}
+	ipType := utils.IPTypeUnknown
+	if tracking.Track {
+		// Extract user information from request
+		userIP = utils.GetClientIP(r)
+		userAgent = utils.GetUserAgent(r)
+		referrer = utils.GetReferrer(r)
 
-	// Get comprehensive geolocation data
-	geoData := utils.GetGeolocationData(userIP)
-	ipType := utils.GetIPType(geoData)
+		// ✅ NEW: With IP anonymization the full address is never stored or sent to the geolocation service.
+		// The truncated IP also becomes the key for remembered names below, trading name-matching accuracy
+		// (visitors on the same /24 or /48 network share a key) for privacy.
+		if tracking.AnonymizeIP {
+			userIP = utils.AnonymizeIP(userIP)
+		}
+
+		// Get comprehensive geolocation data
+		geoData = utils.GetGeolocationData(userIP)
+		ipType = utils.GetIPType(geoData)
+		if tracking.AnonymizeIP {
+			geoData = utils.CoarseGeolocation(geoData)
+		}
+	}
 
 	// ✅ NEW: First check if we have a stored name for this IP address
 	var userName, userEmail string
-	storedName, storedEmail, err := getUserNameByIP(ctx, collection, userIP, clientID)
-	if err != nil {
-		fmt.Printf("Warning: Failed to get stored name by IP: %v\n", err)
-	} else if storedName != "" {
-		userName = storedName
-		userEmail = storedEmail
-		fmt.Printf("DEBUG: Found stored name for IP %s: '%s'\n", userIP, userName)
+	if userIP != "" {
+		storedName, storedEmail, err := getUserNameByIP(ctx, collection, userIP, clientID)
+		if err != nil {
+			logger.Warn("Failed to get stored name by IP", "error", err, "client_id", clientID.Hex())
+		} else if storedName != "" {
+			userName = storedName
+			userEmail = storedEmail
+			logger.Debug("Found stored name for IP", "client_id", clientID.Hex())
+		}
 	}
 
 	// Check if we have user name from contact collection (if no stored name found)
@@ -5172,7 +6382,7 @@ func persistMessage(ctx context.Context, collection *mongo.Collection, clientID
 	}
 
 	// ✅ NEW: Store the name by IP for future conversations
-	if userName != "" {
+	if userName != "" && userIP != "" {
 		go func() {
 			storeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
@@ -5206,7 +6416,9 @@ func persistMessage(ctx context.Context, collection *mongo.Collection, clientID
 		Referrer:       referrer,
 		SessionID:      req.SessionID,
 		IsEmbedUser:    true,
+		Channel:        defaultMessageChannel, // ✅ NEW
 		UserName:       userName, // Include collected/extracted user name
+		WelcomeVariant: welcomeVariant,
 
 		// Enhanced geolocation data
 		Country:      geoData.Country,
@@ -5222,11 +6434,31 @@ func persistMessage(ctx context.Context, collection *mongo.Collection, clientID
 		IPType:       string(ipType),
 	}
 
+	if meta != nil {
+		message.SourceAttribution = meta.SourceAttribution
+		message.Confidence = meta.Confidence
+		message.DemoBooking = meta.DemoBooking
+		message.WhatsAppHandoff = meta.WhatsAppHandoff
+		message.Spam = meta.Spam
+		message.NoAnswerEscalation = meta.NoAnswerEscalation
+		message.RepeatedReplyRegenerated = meta.RepeatedReply
+		message.StrippedClaims = meta.StrippedClaims
+		if meta.Persona != nil {
+			message.PersonaID = &meta.Persona.ID
+		}
+	}
+
 	result, err := collection.InsertOne(ctx, message)
 	if err != nil {
 		return primitive.NilObjectID, err
 	}
-	return result.InsertedID.(primitive.ObjectID), nil
+	messageID := result.InsertedID.(primitive.ObjectID)
+
+	// ✅ NEW: Prompt snapshots are stored apart from the message, keyed by its id
+	if meta != nil && meta.PromptSnapshot != nil {
+		storePromptSnapshot(ctx, collection.Database(), meta.PromptSnapshot, messageID)
+	}
+	return messageID, nil
 }
 
 // updateTokenUsage atomically updates client token usage
@@ -5249,6 +6481,7 @@ func updateTokenUsage(ctx context.Context, collection *mongo.Collection, clientI
 	if updateResult.MatchedCount == 0 {
 		return fmt.Errorf("token update failed or insufficient tokens")
 	}
+	sharedClientConfigCache.addTokenUsage(clientID, tokenCost)
 
 	return nil
 }
@@ -5278,6 +6511,15 @@ func handleEmbedChatHistory(messagesCollection *mongo.Collection) gin.HandlerFun
 		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 		search := c.Query("search")
+		tag := normalizeConversationTag(c.Query("tag"))
+		channel := strings.ToLower(strings.TrimSpace(c.Query("channel")))
+		if channel != "" && !isValidMessageChannel(channel) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_channel",
+				"message":    "Unknown channel",
+			})
+			return
+		}
 
 		if page < 1 {
 			page = 1
@@ -5295,6 +6537,35 @@ func handleEmbedChatHistory(messagesCollection *mongo.Collection) gin.HandlerFun
 			"is_embed_user": true,
 		}
 
+		// ✅ NEW: Channel filter; non-embed channels (e.g. telegram) aren't flagged as embed users
+		if channel == defaultMessageChannel {
+			applyMessageChannelFilter(filter, channel)
+		} else if channel != "" {
+			delete(filter, "is_embed_user")
+			filter["channel"] = channel
+		}
+
+		tagsCollection := messagesCollection.Database().Collection("conversation_tags")
+
+		// ✅ NEW: Restrict to sessions carrying the requested tag
+		if tag != "" {
+			sessionIDs, err := tagsCollection.Distinct(ctx, "session_id", bson.M{
+				"client_id": clientObjID,
+				"$or": []bson.M{
+					{"tags": tag},
+					{"auto_tags": tag},
+				},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "database_error",
+					"message":    "Failed to filter conversations by tag",
+				})
+				return
+			}
+			filter["session_id"] = bson.M{"$in": sessionIDs}
+		}
+
 		// Add search filter if provided
 		if search != "" {
 			filter["$or"] = []bson.M{
@@ -5333,6 +6604,7 @@ func handleEmbedChatHistory(messagesCollection *mongo.Collection) gin.HandlerFun
 				{Key: "city", Value: bson.D{{Key: "$first", Value: "$city"}}},
 				{Key: "referrer", Value: bson.D{{Key: "$first", Value: "$referrer"}}},
 				{Key: "user_name", Value: bson.D{{Key: "$last", Value: "$user_name"}}}, // Get the latest user name
+				{Key: "channel", Value: bson.D{{Key: "$first", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$channel", defaultMessageChannel}}}}}},
 			}}},
 			{{Key: "$sort", Value: bson.D{{Key: "last_message.timestamp", Value: -1}}}},
 			{{Key: "$skip", Value: (page - 1) * limit}},
@@ -5364,6 +6636,7 @@ func handleEmbedChatHistory(messagesCollection *mongo.Collection) gin.HandlerFun
 				City           string         `bson:"city"`
 				Referrer       string         `bson:"referrer"`
 				UserName       string         `bson:"user_name"`
+				Channel        string         `bson:"channel"`
 			}
 
 			if err := cursor.Decode(&result); err != nil {
@@ -5383,15 +6656,83 @@ func handleEmbedChatHistory(messagesCollection *mongo.Collection) gin.HandlerFun
 				"city":            result.City,
 				"referrer":        result.Referrer,
 				"user_name":       result.UserName,
+				"channel":         result.Channel,
 				"started_at":      result.FirstMessage.Timestamp,
 				"last_activity":   result.LastMessage.Timestamp,
+				"tags":            []string{},
+				"auto_tags":       []string{},
 			})
 		}
 
+		// ✅ NEW: Attach tags for the sessions on this page
+		if len(conversations) > 0 {
+			var sessionIDs []string
+			for _, conv := range conversations {
+				sessionIDs = append(sessionIDs, conv["session_id"].(string))
+			}
+			tagCursor, err := tagsCollection.Find(ctx, bson.M{
+				"client_id":  clientObjID,
+				"session_id": bson.M{"$in": sessionIDs},
+			})
+			if err == nil {
+				var tagDocs []models.ConversationTags
+				if err := tagCursor.All(ctx, &tagDocs); err == nil {
+					tagsBySession := make(map[string]models.ConversationTags)
+					for _, doc := range tagDocs {
+						tagsBySession[doc.SessionID] = doc
+					}
+					for _, conv := range conversations {
+						if doc, ok := tagsBySession[conv["session_id"].(string)]; ok {
+							if doc.Tags != nil {
+								conv["tags"] = doc.Tags
+							}
+							if doc.AutoTags != nil {
+								conv["auto_tags"] = doc.AutoTags
+							}
+						}
+					}
+				}
+			}
+		}
+
 		totalPages := (total + int64(limit) - 1) / int64(limit)
 
+		// ✅ NEW: Conversation counts per channel (ignores the channel filter)
+		channelCounts := gin.H{}
+		countPipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{
+				"client_id": clientObjID,
+				"$or": []bson.M{
+					{"is_embed_user": true},
+					{"channel": bson.M{"$exists": true}},
+				},
+			}}},
+			{{Key: "$group", Value: bson.M{
+				"_id": bson.M{
+					"session_id": "$session_id",
+					"channel":    bson.M{"$ifNull": bson.A{"$channel", defaultMessageChannel}},
+				},
+			}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":   "$_id.channel",
+				"count": bson.M{"$sum": 1},
+			}}},
+		}
+		if countCursor, err := messagesCollection.Aggregate(ctx, countPipeline); err == nil {
+			var counts []struct {
+				Channel string `bson:"_id"`
+				Count   int    `bson:"count"`
+			}
+			if err := countCursor.All(ctx, &counts); err == nil {
+				for _, cc := range counts {
+					channelCounts[cc.Channel] = cc.Count
+				}
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"conversations": conversations,
+			"conversations":  conversations,
+			"channel_counts": channelCounts,
 			"pagination": gin.H{
 				"page":        page,
 				"limit":       limit,
@@ -5402,6 +6743,218 @@ func handleEmbedChatHistory(messagesCollection *mongo.Collection) gin.HandlerFun
 	}
 }
 
+// ✅ NEW: Conversation tagging
+
+const maxConversationTags = 20
+
+// normalizeConversationTag lowercases and trims a tag; returns "" if it is invalid
+func normalizeConversationTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || len(tag) > 32 {
+		return ""
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != ':' {
+			return ""
+		}
+	}
+	return tag
+}
+
+// handleUpdateConversationTags adds and/or removes tags on an embed conversation
+func handleUpdateConversationTags(db *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		sessionID := c.Param("session")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_session_id",
+				"message":    "Session ID required",
+			})
+			return
+		}
+
+		var req struct {
+			Add    []string `json:"add"`
+			Remove []string `json:"remove"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+		if len(req.Add) == 0 && len(req.Remove) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "no_tags",
+				"message":    "Provide at least one tag to add or remove",
+			})
+			return
+		}
+
+		normalize := func(tags []string) ([]string, bool) {
+			var out []string
+			for _, t := range tags {
+				normalized := normalizeConversationTag(t)
+				if normalized == "" {
+					return nil, false
+				}
+				out = append(out, normalized)
+			}
+			return out, true
+		}
+		addTags, okAdd := normalize(req.Add)
+		removeTags, okRemove := normalize(req.Remove)
+		if !okAdd || !okRemove {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_tag",
+				"message":    "Tags must be 1-32 characters of letters, digits, '-', '_' or ':'",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		// Make sure the conversation belongs to this client
+		count, err := db.Collection("messages").CountDocuments(ctx, bson.M{
+			"client_id":  clientObjID,
+			"session_id": sessionID,
+		}, options.Count().SetLimit(1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to look up conversation",
+			})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "conversation_not_found",
+				"message":    "Conversation not found",
+			})
+			return
+		}
+
+		tagsCollection := db.Collection("conversation_tags")
+		filter := bson.M{"client_id": clientObjID, "session_id": sessionID}
+
+		var existing models.ConversationTags
+		if err := tagsCollection.FindOne(ctx, filter).Decode(&existing); err != nil && err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to load conversation tags",
+			})
+			return
+		}
+
+		// Apply removals then additions, keeping order and dropping duplicates
+		removeSet := make(map[string]bool)
+		for _, t := range removeTags {
+			removeSet[t] = true
+		}
+		seen := make(map[string]bool)
+		tags := []string{}
+		for _, t := range append(existing.Tags, addTags...) {
+			if removeSet[t] || seen[t] {
+				continue
+			}
+			seen[t] = true
+			tags = append(tags, t)
+		}
+		if len(tags) > maxConversationTags {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "too_many_tags",
+				"message":    fmt.Sprintf("A conversation can have at most %d tags", maxConversationTags),
+			})
+			return
+		}
+
+		// Removing a tag also clears it from the auto-assigned set
+		autoTags := []string{}
+		for _, t := range existing.AutoTags {
+			if !removeSet[t] {
+				autoTags = append(autoTags, t)
+			}
+		}
+
+		now := time.Now()
+		_, err = tagsCollection.UpdateOne(ctx, filter, bson.M{
+			"$set": bson.M{
+				"tags":       tags,
+				"auto_tags":  autoTags,
+				"updated_at": now,
+			},
+		}, options.Update().SetUpsert(true))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to update conversation tags",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": sessionID,
+			"tags":       tags,
+			"auto_tags":  autoTags,
+			"updated_at": now,
+		})
+	}
+}
+
+// autoTagConversation assigns tags from buying intent and detected topics.
+// Auto tags live in their own field so they never overwrite the client's manual tags.
+func autoTagConversation(db *mongo.Database, clientID primitive.ObjectID, sessionID string, history []models.Message, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var autoTags []string
+	if calculateIntentScore(history, message) >= 8 {
+		autoTags = append(autoTags, "lead")
+	}
+	for _, topic := range extractTopics(message) {
+		if topic != "general" {
+			autoTags = append(autoTags, "topic:"+topic)
+		}
+	}
+	if len(autoTags) == 0 {
+		return
+	}
+
+	_, err := db.Collection("conversation_tags").UpdateOne(ctx,
+		bson.M{"client_id": clientID, "session_id": sessionID},
+		bson.M{
+			"$addToSet":    bson.M{"auto_tags": bson.M{"$each": autoTags}},
+			"$set":         bson.M{"updated_at": time.Now()},
+			"$setOnInsert": bson.M{"tags": []string{}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		logger.Warn("Failed to auto-tag conversation", "error", err, "session_id", sessionID)
+	}
+}
+
 // handleEmbedConversationMessages returns messages for a specific embed conversation
 func handleEmbedConversationMessages(messagesCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -5439,7 +6992,11 @@ func handleEmbedConversationMessages(messagesCollection *mongo.Collection) gin.H
 		filter := bson.M{
 			"client_id":       clientObjID,
 			"conversation_id": conversationID,
-			"is_embed_user":   true,
+			// ✅ UPDATED: Include external channel conversations (e.g. telegram)
+			"$or": []bson.M{
+				{"is_embed_user": true},
+				{"channel": bson.M{"$exists": true, "$ne": "dashboard"}},
+			},
 		}
 
 		cursor, err := messagesCollection.Find(
@@ -5488,28 +7045,95 @@ func handleEmbedConversationMessages(messagesCollection *mongo.Collection) gin.H
 	}
 }
 
+// ✅ NEW: Per-client safety thresholds
+
+// geminiSafetyCategories maps Client.SafetySettings keys to Gemini harm categories, in the order they are applied
+var geminiSafetyCategories = []struct {
+	Key      string
+	Category genai.HarmCategory
+}{
+	{"harassment", genai.HarmCategoryHarassment},
+	{"hate_speech", genai.HarmCategoryHateSpeech},
+	{"dangerous_content", genai.HarmCategoryDangerousContent},
+	{"sexually_explicit", genai.HarmCategorySexuallyExplicit},
+}
+
+// geminiBlockThresholds maps Client.SafetySettings values to Gemini block thresholds
+var geminiBlockThresholds = map[string]genai.HarmBlockThreshold{
+	"block_low_and_above":    genai.HarmBlockLowAndAbove,
+	"block_medium_and_above": genai.HarmBlockMediumAndAbove,
+	"block_only_high":        genai.HarmBlockOnlyHigh,
+	"block_none":             genai.HarmBlockNone,
+}
+
+// defaultSafetyThreshold applies to every category the client hasn't configured
+const defaultSafetyThreshold = "block_medium_and_above"
+
+// errContentBlocked marks generation failures caused by Gemini safety filtering
+var errContentBlocked = errors.New("response blocked by safety settings")
+
+// validateSafetySettings checks that every key is a known harm category and every value a known threshold
+func validateSafetySettings(settings map[string]string) error {
+	for key, value := range settings {
+		known := false
+		for _, sc := range geminiSafetyCategories {
+			if sc.Key == key {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown safety category %q (allowed: harassment, hate_speech, dangerous_content, sexually_explicit)", key)
+		}
+		if _, ok := geminiBlockThresholds[value]; !ok {
+			return fmt.Errorf("invalid threshold %q for %s (allowed: block_low_and_above, block_medium_and_above, block_only_high, block_none)", value, key)
+		}
+	}
+	return nil
+}
+
+// effectiveSafetySettings returns the threshold applied to every category for a client
+func effectiveSafetySettings(settings map[string]string) map[string]string {
+	effective := make(map[string]string, len(geminiSafetyCategories))
+	for _, sc := range geminiSafetyCategories {
+		effective[sc.Key] = defaultSafetyThreshold
+		if _, ok := geminiBlockThresholds[settings[sc.Key]]; ok {
+			effective[sc.Key] = settings[sc.Key]
+		}
+	}
+	return effective
+}
+
+// blockedSafetyCategories lists the harm categories that caused a BlockedError
+func blockedSafetyCategories(blocked *genai.BlockedError) []string {
+	var ratings []*genai.SafetyRating
+	if blocked.Candidate != nil {
+		ratings = append(ratings, blocked.Candidate.SafetyRatings...)
+	}
+	if blocked.PromptFeedback != nil {
+		ratings = append(ratings, blocked.PromptFeedback.SafetyRatings...)
+	}
+	var categories []string
+	for _, rating := range ratings {
+		if rating != nil && rating.Blocked {
+			categories = append(categories, rating.Category.String())
+		}
+	}
+	return categories
+}
+
 // configureGeminiModel sets up Gemini model with FREE TIER settings
-func configureGeminiModel(client *genai.Client) *genai.GenerativeModel {
+func configureGeminiModel(client *genai.Client, safetySettings map[string]string) *genai.GenerativeModel {
 	// 🆓 FREE TIER MODEL (with version)
 	model := client.GenerativeModel("gemini-2.0-flash")
 
-	model.SafetySettings = []*genai.SafetySetting{
-		{
-			Category:  genai.HarmCategoryHarassment,
-			Threshold: genai.HarmBlockMediumAndAbove,
-		},
-		{
-			Category:  genai.HarmCategoryHateSpeech,
-			Threshold: genai.HarmBlockMediumAndAbove,
-		},
-		{
-			Category:  genai.HarmCategoryDangerousContent,
-			Threshold: genai.HarmBlockMediumAndAbove,
-		},
-		{
-			Category:  genai.HarmCategorySexuallyExplicit,
-			Threshold: genai.HarmBlockMediumAndAbove,
-		},
+	effective := effectiveSafetySettings(safetySettings)
+	model.SafetySettings = make([]*genai.SafetySetting, 0, len(geminiSafetyCategories))
+	for _, sc := range geminiSafetyCategories {
+		model.SafetySettings = append(model.SafetySettings, &genai.SafetySetting{
+			Category:  sc.Category,
+			Threshold: geminiBlockThresholds[effective[sc.Key]],
+		})
 	}
 
 	model.GenerationConfig = genai.GenerationConfig{
@@ -5574,12 +7198,15 @@ func parsePeriod(period string) time.Duration {
 	}
 }
 
-// generateAnalytics generates comprehensive analytics data
-func generateAnalytics(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time, period string) (gin.H, error) {
+// generateAnalytics computes a client's analytics for [start, end]; daily buckets follow loc
+func generateAnalytics(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time, period, channel string, loc *time.Location) (gin.H, error) {
 	match := bson.M{
 		"client_id": clientID,
 		"timestamp": bson.M{"$gte": start, "$lte": end},
 	}
+	if channel != "" {
+		applyMessageChannelFilter(match, channel)
+	}
 
 	// Get total messages
 	totalMessages, err := collection.CountDocuments(ctx, match)
@@ -5608,6 +7235,20 @@ func generateAnalytics(ctx context.Context, collection *mongo.Collection, client
 		}
 	}
 
+	// ✅ NEW: Messages filtered as spam
+	spamMatch := bson.M{"spam": bson.M{"$exists": true}}
+	for k, v := range match {
+		spamMatch[k] = v
+	}
+	spamMessages, err := collection.CountDocuments(ctx, spamMatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count spam messages: %w", err)
+	}
+	spamRate := 0.0
+	if totalMessages > 0 {
+		spamRate = float64(spamMessages) / float64(totalMessages)
+	}
+
 	// Get active users
 	var activeUsers int
 	if vals, err := collection.Distinct(ctx, "from_user_id", match); err == nil {
@@ -5621,6 +7262,17 @@ func generateAnalytics(ctx context.Context, collection *mongo.Collection, client
 	}
 	totalConversations := len(convIDs)
 
+	// ✅ NEW: Include totals of messages removed by the retention purge
+	rolledUp, err := loadRolledUpStats(ctx, collection.Database().Collection(messageDailyStatsCollection), clientID, start, end, channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load purged message stats: %w", err)
+	}
+	for _, day := range rolledUp {
+		totalMessages += day.Messages
+		totalTokens += day.Tokens
+		totalConversations += int(day.Conversations)
+	}
+
 	// Calculate averages
 	avgMessagesPerConversation := 0.0
 	if totalConversations > 0 {
@@ -5628,17 +7280,24 @@ func generateAnalytics(ctx context.Context, collection *mongo.Collection, client
 	}
 
 	// Get time series data
-	timeSeries, err := getTimeSeriesData(ctx, collection, match)
+	timeSeries, err := getTimeSeriesData(ctx, collection, match, loc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get time series: %w", err)
 	}
+	timeSeries = mergeRolledUpSeries(timeSeries, rolledUp)
 
 	// Get previous period data for comparison
-	prevData, err := getPreviousPeriodData(ctx, collection, clientID, start, end)
+	prevData, err := getPreviousPeriodData(ctx, collection, clientID, start, end, channel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get previous period data: %w", err)
 	}
 
+	// ✅ NEW: Breakdown by channel
+	byChannel, err := getChannelBreakdown(ctx, collection, match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel breakdown: %w", err)
+	}
+
 	return gin.H{
 		"client_id":                     clientID.Hex(),
 		"period":                        period,
@@ -5654,11 +7313,115 @@ func generateAnalytics(ctx context.Context, collection *mongo.Collection, client
 		"time_series":                   timeSeries,
 		"usage_by_period":               timeSeries, // alias
 		"previous_period":               prevData,
+		"channel":                       channel,
+		"by_channel":                    byChannel,
+		"spam_messages":                 int(spamMessages),
+		"spam_rate":                     spamRate,
+		"timezone":                      loc.String(),
 	}, nil
 }
 
-// getTimeSeriesData retrieves time series analytics data
-func getTimeSeriesData(ctx context.Context, collection *mongo.Collection, match bson.M) ([]gin.H, error) {
+// ✅ NEW: Channel dimension
+
+// defaultMessageChannel is assumed for messages stored before the channel field existed
+const defaultMessageChannel = "embed"
+
+// messageChannels lists the channels messages can arrive on
+var messageChannels = map[string]bool{
+	"embed":     true,
+	"telegram":  true,
+	"whatsapp":  true,
+	"dashboard": true,
+}
+
+// isValidMessageChannel reports whether channel is a known message channel
+func isValidMessageChannel(channel string) bool {
+	return messageChannels[channel]
+}
+
+// applyMessageChannelFilter restricts match to a channel. Legacy messages without a channel
+// field count as embed only when they came from an embed user, so older dashboard and
+// test-chat messages aren't reported as embed traffic.
+func applyMessageChannelFilter(match bson.M, channel string) {
+	if channel != defaultMessageChannel {
+		match["channel"] = channel
+		return
+	}
+	embedOr := bson.A{
+		bson.M{"channel": defaultMessageChannel},
+		bson.M{"channel": nil, "is_embed_user": true},
+	}
+	if existing, ok := match["$or"]; ok {
+		delete(match, "$or")
+		match["$and"] = bson.A{bson.M{"$or": existing}, bson.M{"$or": embedOr}}
+		return
+	}
+	match["$or"] = embedOr
+}
+
+// messageChannelExpr is the aggregation expression for a message's channel; legacy
+// messages count as embed for embed users and as dashboard otherwise
+func messageChannelExpr() bson.M {
+	return bson.M{"$ifNull": bson.A{"$channel", bson.M{
+		"$cond": bson.A{bson.M{"$eq": bson.A{"$is_embed_user", true}}, defaultMessageChannel, "dashboard"},
+	}}}
+}
+
+// getChannelBreakdown groups messages, tokens and conversations by channel
+func getChannelBreakdown(ctx context.Context, collection *mongo.Collection, match bson.M) ([]gin.H, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            messageChannelExpr(),
+			"total_messages": bson.M{"$sum": 1},
+			"total_tokens": bson.M{"$sum": bson.M{
+				"$toInt": bson.M{"$ifNull": bson.A{"$token_cost", 0}},
+			}},
+			"convs": bson.M{"$addToSet": "$conversation_id"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"total_messages":      1,
+			"total_tokens":        1,
+			"total_conversations": bson.M{"$size": "$convs"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"total_messages": -1}}},
+	}
+
+	cur, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	byChannel := []gin.H{}
+	for cur.Next(ctx) {
+		var doc struct {
+			Channel            string `bson:"_id"`
+			TotalMessages      int    `bson:"total_messages"`
+			TotalTokens        int    `bson:"total_tokens"`
+			TotalConversations int    `bson:"total_conversations"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			continue
+		}
+		avgLength := 0.0
+		if doc.TotalConversations > 0 {
+			avgLength = float64(doc.TotalMessages) / float64(doc.TotalConversations)
+		}
+		byChannel = append(byChannel, gin.H{
+			"channel":                       doc.Channel,
+			"total_messages":                doc.TotalMessages,
+			"total_tokens":                  doc.TotalTokens,
+			"total_conversations":           doc.TotalConversations,
+			"avg_messages_per_conversation": avgLength,
+		})
+	}
+
+	return byChannel, nil
+}
+
+// getTimeSeriesData retrieves time series analytics data, one bucket per day in loc
+func getTimeSeriesData(ctx context.Context, collection *mongo.Collection, match bson.M, loc *time.Location) ([]gin.H, error) {
 	seriesPipe := mongo.Pipeline{
 		{{Key: "$match", Value: match}},
 		{{Key: "$group", Value: bson.M{
@@ -5666,7 +7429,7 @@ func getTimeSeriesData(ctx context.Context, collection *mongo.Collection, match
 				"day": bson.M{"$dateToString": bson.M{
 					"format":   "%Y-%m-%d",
 					"date":     "$timestamp",
-					"timezone": "UTC",
+					"timezone": loc.String(),
 				}},
 			},
 			"total_messages": bson.M{"$sum": 1},
@@ -5677,7 +7440,7 @@ func getTimeSeriesData(ctx context.Context, collection *mongo.Collection, match
 			"convs": bson.M{"$addToSet": "$conversation_id"},
 		}}},
 		{{Key: "$project", Value: bson.M{
-			"date":                "_id.day",
+			"date":                "$_id.day",
 			"total_messages":      1,
 			"total_tokens":        1,
 			"active_users":        bson.M{"$size": "$users"},
@@ -5713,22 +7476,30 @@ func getTimeSeriesData(ctx context.Context, collection *mongo.Collection, match
 }
 
 // getPreviousPeriodData retrieves data from the previous period for comparison
-func getPreviousPeriodData(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time) (gin.H, error) {
+func getPreviousPeriodData(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time, channel string) (gin.H, error) {
 	dur := end.Sub(start)
 	prevStart := start.Add(-dur)
 	prevEnd := start.Add(-time.Nanosecond)
 
-	prevMatch := bson.M{
+	return getPeriodTotals(ctx, collection, clientID, prevStart, prevEnd, channel)
+}
+
+// getPeriodTotals returns message, token and active user totals for [start, end]
+func getPeriodTotals(ctx context.Context, collection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time, channel string) (gin.H, error) {
+	match := bson.M{
 		"client_id": clientID,
-		"timestamp": bson.M{"$gte": prevStart, "$lte": prevEnd},
+		"timestamp": bson.M{"$gte": start, "$lte": end},
+	}
+	if channel != "" {
+		applyMessageChannelFilter(match, channel)
 	}
 
-	prevMsgs, _ := collection.CountDocuments(ctx, prevMatch)
+	messages, _ := collection.CountDocuments(ctx, match)
 
-	// Get previous tokens
-	var prevTokens int64
+	// Get tokens
+	var tokens int64
 	tokPipe := mongo.Pipeline{
-		{{Key: "$match", Value: prevMatch}},
+		{{Key: "$match", Value: match}},
 		{{Key: "$group", Value: bson.M{
 			"_id": nil,
 			"tokens": bson.M{"$sum": bson.M{
@@ -5742,20 +7513,28 @@ func getPreviousPeriodData(ctx context.Context, collection *mongo.Collection, cl
 			Tokens int64 `bson:"tokens"`
 		}
 		if err := cur.All(ctx, &r); err == nil && len(r) > 0 {
-			prevTokens = r[0].Tokens
+			tokens = r[0].Tokens
 		}
 	}
 
-	// Get previous active users
-	var prevUsers int
-	if vals, err := collection.Distinct(ctx, "from_user_id", prevMatch); err == nil {
-		prevUsers = len(vals)
+	// Get active users
+	var users int
+	if vals, err := collection.Distinct(ctx, "from_user_id", match); err == nil {
+		users = len(vals)
+	}
+
+	// ✅ NEW: Include totals of messages removed by the retention purge
+	if rolledUp, err := loadRolledUpStats(ctx, collection.Database().Collection(messageDailyStatsCollection), clientID, start, end, channel); err == nil {
+		for _, day := range rolledUp {
+			messages += day.Messages
+			tokens += day.Tokens
+		}
 	}
 
 	return gin.H{
-		"total_messages": int(prevMsgs),
-		"total_tokens":   int(prevTokens),
-		"active_users":   prevUsers,
+		"total_messages": int(messages),
+		"total_tokens":   int(tokens),
+		"active_users":   users,
 	}, nil
 }
 
@@ -5763,8 +7542,12 @@ func getPreviousPeriodData(ctx context.Context, collection *mongo.Collection, cl
 func retrievePDFContext(ctx context.Context, cfg *config.Config, pdfsCollection *mongo.Collection, clientID primitive.ObjectID, query string, maxChunks int) ([]models.ContentChunk, error) {
 	// Prefer Atlas Vector/Text Search when enabled; fall back to keyword scoring
 	if cfg != nil && (cfg.VectorSearchEnabled || cfg.AtlasTextSearchEnabled) {
-		if chunks, err := searchRelevantChunks(ctx, pdfsCollection.Database(), clientID, query, maxChunks, cfg); err == nil && len(chunks) > 0 {
-			return chunks, nil
+		// ✅ NEW: Chunks of inactive or unprocessed documents are left out
+		excluded, err := excludedPDFIDs(ctx, pdfsCollection, clientID)
+		if err == nil {
+			if chunks, err := searchRelevantChunks(ctx, pdfsCollection.Database(), clientID, query, maxChunks, cfg, excluded); err == nil && len(chunks) > 0 {
+				return chunks, nil
+			}
 		}
 	}
 	// Check if any PDFs exist for this client
@@ -5774,7 +7557,8 @@ func retrievePDFContext(ctx context.Context, cfg *config.Config, pdfsCollection
 		_ = err
 	}
 
-	cursor, err := pdfsCollection.Find(ctx, bson.M{"client_id": clientID})
+	// ✅ NEW: Only active, processed documents
+	cursor, err := pdfsCollection.Find(ctx, retrievablePDFFilter(clientID))
 	if err != nil {
 		return nil, err
 	}
@@ -5935,12 +7719,16 @@ func retrievePDFContext(ctx context.Context, cfg *config.Config, pdfsCollection
 }
 
 // searchRelevantChunks uses Atlas Vector Search ($vectorSearch) or Atlas Text Search ($search)
-// against the denormalized 'pdf_chunks' collection.
-func searchRelevantChunks(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID, query string, limit int, cfg *config.Config) ([]models.ContentChunk, error) {
+// against the denormalized 'pdf_chunks' collection. Chunks of the excluded PDF IDs are skipped.
+func searchRelevantChunks(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID, query string, limit int, cfg *config.Config, excludedPDFIDs []interface{}) ([]models.ContentChunk, error) {
 	col := db.Collection("pdf_chunks")
 
+	match := bson.M{"client_id": clientID}
+	if len(excludedPDFIDs) > 0 {
+		match["pdf_id"] = bson.M{"$nin": excludedPDFIDs}
+	}
 	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{"client_id": clientID}}},
+		bson.D{{Key: "$match", Value: match}},
 	}
 
 	useVector := cfg.VectorSearchEnabled
@@ -6011,7 +7799,7 @@ func searchRelevantChunks(ctx context.Context, db *mongo.Database, clientID prim
 }
 
 // retrieveCrawledContext retrieves relevant crawled page content for the given query
-func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collection, clientID primitive.ObjectID, query string, maxChunks int) ([]models.ContentChunk, error) {
+func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collection, clientID primitive.ObjectID, query string, maxChunks int, freshness crawlFreshness) ([]models.ContentChunk, error) {
 	// Get only completed crawl jobs for this client
 	count, err := crawlsCollection.CountDocuments(ctx, bson.M{
 		"client_id": clientID,
@@ -6047,9 +7835,27 @@ func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collect
 	queryLower := strings.ToLower(query)
 
 	// Collect all crawled pages content
-	var allCrawledPages []models.CrawledPage
+	// ✅ NEW: Pages past the client's max age are skipped; stale ones are kept but flagged
+	type datedPage struct {
+		page      models.CrawledPage
+		crawledAt time.Time
+		stale     bool
+	}
+	now := time.Now()
+	var allCrawledPages []datedPage
+	expired := 0
 	for _, job := range crawlJobs {
-		allCrawledPages = append(allCrawledPages, job.CrawledPages...)
+		for _, page := range job.CrawledPages {
+			crawledAt := crawledPageTime(page, job)
+			if freshness.isExpired(crawledAt, now) {
+				expired++
+				continue
+			}
+			allCrawledPages = append(allCrawledPages, datedPage{page: page, crawledAt: crawledAt, stale: freshness.isStale(crawledAt, now)})
+		}
+	}
+	if expired > 0 {
+		logger.Debug("Skipped crawled pages older than the max age", "count", expired)
 	}
 
 	if len(allCrawledPages) == 0 {
@@ -6060,8 +7866,10 @@ func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collect
 	fmt.Printf("Debug: Total crawled pages available: %d\n", len(allCrawledPages))
 
 	// Convert crawled pages to content chunks for scoring
-	var allChunks []models.ContentChunk
-	for i, page := range allCrawledPages {
+	var allChunks, staleChunks []models.ContentChunk
+	for i, dated := range allCrawledPages {
+		page := dated.page
+		source := crawledSourceLine(page.URL, dated.crawledAt, dated.stale)
 		// Convert crawled page content to chunks (similar to PDF chunking)
 		// Split long content into smaller chunks if needed
 		content := strings.TrimSpace(page.Content)
@@ -6074,10 +7882,15 @@ func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collect
 		chunkSize := 500
 		if len(words) <= chunkSize {
 			// Single chunk if content is small
-			allChunks = append(allChunks, models.ContentChunk{
-				Text:  fmt.Sprintf("%s\n\nSource: %s", content, page.URL),
+			chunk := models.ContentChunk{
+				Text:  fmt.Sprintf("%s\n\n%s", content, source),
 				Order: i,
-			})
+			}
+			if dated.stale {
+				staleChunks = append(staleChunks, chunk)
+			} else {
+				allChunks = append(allChunks, chunk)
+			}
 		} else {
 			// Split into multiple chunks
 			for start := 0; start < len(words); start += chunkSize {
@@ -6086,15 +7899,24 @@ func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collect
 					end = len(words)
 				}
 				chunkText := strings.Join(words[start:end], " ")
-				allChunks = append(allChunks, models.ContentChunk{
-					Text:  fmt.Sprintf("%s\n\nSource: %s", chunkText, page.URL),
+				chunk := models.ContentChunk{
+					Text:  fmt.Sprintf("%s\n\n%s", chunkText, source),
 					Order: i*1000 + start/chunkSize, // Ensure unique ordering
-				})
+				}
+				if dated.stale {
+					staleChunks = append(staleChunks, chunk)
+				} else {
+					allChunks = append(allChunks, chunk)
+				}
 			}
 		}
 	}
 
-	fmt.Printf("Debug: Created %d chunks from crawled pages\n", len(allChunks))
+	// ✅ NEW: Fresh chunks come first; chunks from firstStale on are past the stale threshold
+	firstStale := len(allChunks)
+	allChunks = append(allChunks, staleChunks...)
+
+	logger.Debug("Created chunks from crawled pages", "chunks", len(allChunks), "stale", len(staleChunks))
 
 	// Apply same relevance scoring as PDF chunks
 	// ✅ BASIC COMPANY QUESTIONS - Return ALL content (but not for simple greetings)
@@ -6128,8 +7950,12 @@ func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collect
 
 	// ✅ If basic question or no specific keywords, return LIMITED chunks
 	if isBasicQuestion || len(allChunks) <= maxChunks {
-		sort.Slice(allChunks, func(i, j int) bool {
-			return allChunks[i].Order < allChunks[j].Order
+		fresh, stale := allChunks[:firstStale], allChunks[firstStale:]
+		sort.Slice(fresh, func(i, j int) bool {
+			return fresh[i].Order < fresh[j].Order
+		})
+		sort.Slice(stale, func(i, j int) bool {
+			return stale[i].Order < stale[j].Order
 		})
 
 		if len(allChunks) <= maxChunks {
@@ -6148,8 +7974,9 @@ func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collect
 
 	var scored []scoredChunk
 
-	for _, chunk := range allChunks {
-		chunkLower := strings.ToLower(chunk.Text)
+	for idx, chunk := range allChunks {
+		// The stale note itself mustn't match queries about prices or dates
+		chunkLower := strings.ToLower(strings.TrimSuffix(chunk.Text, staleCrawlNote))
 		score := 0
 
 		// Enhanced scoring system
@@ -6167,6 +7994,11 @@ func retrieveCrawledContext(ctx context.Context, crawlsCollection *mongo.Collect
 			}
 		}
 
+		// ✅ NEW: Stale pages count half so fresher pages win comparable matches
+		if idx >= firstStale {
+			score = (score + 1) / 2
+		}
+
 		scored = append(scored, scoredChunk{chunk: chunk, score: score})
 	}
 
@@ -7012,7 +8844,7 @@ func handleExportChats(messagesCollection, clientsCollection *mongo.Collection)
 
 		// Parse export request
 		var req services.ExportRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
+		if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error_code": "invalid_request",
 				"message":    "Invalid export request: " + err.Error(),
@@ -7020,6 +8852,17 @@ func handleExportChats(messagesCollection, clientsCollection *mongo.Collection)
 			return
 		}
 
+		// ✅ NEW: Fill what the request left out from the client's export defaults
+		var given struct {
+			IncludeGeo  *bool `json:"include_geo"`
+			IncludeMeta *bool `json:"include_meta"`
+		}
+		c.ShouldBindBodyWith(&given, binding.JSON)
+		applyExportDefaults(&req, exportRequestGiven{
+			includeGeo:  given.IncludeGeo != nil,
+			includeMeta: given.IncludeMeta != nil,
+		}, loadExportDefaults(c, clientsCollection, req.ClientID), time.Now())
+
 		// Validate format
 		if req.Format == "" {
 			req.Format = "json" // Default format
@@ -7047,6 +8890,18 @@ func handleExportChats(messagesCollection, clientsCollection *mongo.Collection)
 	}
 }
 
+// parseExportDate parses a YYYY-MM-DD or RFC3339 export bound. A plain date used as the
+// upper bound covers the whole day.
+func parseExportDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			return t.Add(24*time.Hour - time.Nanosecond), nil
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 // handleDownloadExport handles direct download of exported chat data
 func handleDownloadExport(messagesCollection, clientsCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -7070,9 +8925,13 @@ func handleDownloadExport(messagesCollection, clientsCollection *mongo.Collectio
 		}
 
 		// Parse query parameters
-		format := c.Query("format")
-		if format == "" {
-			format = "json" // Default format
+		format := strings.ToLower(c.Query("format"))
+		if format != "" && format != "excel" && !services.IsStreamingFormat(format) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_format",
+				"message":    "Invalid format. Use json, csv, txt or excel",
+			})
+			return
 		}
 
 		// Parse date range
@@ -7080,22 +8939,22 @@ func handleDownloadExport(messagesCollection, clientsCollection *mongo.Collectio
 		var err error
 
 		if dateFromStr := c.Query("date_from"); dateFromStr != "" {
-			dateFrom, err = time.Parse("2006-01-02", dateFromStr)
+			dateFrom, err = parseExportDate(dateFromStr, false)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{
 					"error_code": "invalid_date",
-					"message":    "Invalid date_from format. Use YYYY-MM-DD",
+					"message":    "Invalid date_from format. Use YYYY-MM-DD or RFC3339",
 				})
 				return
 			}
 		}
 
 		if dateToStr := c.Query("date_to"); dateToStr != "" {
-			dateTo, err = time.Parse("2006-01-02", dateToStr)
+			dateTo, err = parseExportDate(dateToStr, true)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{
 					"error_code": "invalid_date",
-					"message":    "Invalid date_to format. Use YYYY-MM-DD",
+					"message":    "Invalid date_to format. Use YYYY-MM-DD or RFC3339",
 				})
 				return
 			}
@@ -7117,6 +8976,8 @@ func handleDownloadExport(messagesCollection, clientsCollection *mongo.Collectio
 		// Parse boolean flags
 		includeGeo := c.Query("include_geo") == "true"
 		includeMeta := c.Query("include_meta") == "true"
+		_, geoGiven := c.GetQuery("include_geo")
+		_, metaGiven := c.GetQuery("include_meta")
 
 		// Build export request
 		req := &services.ExportRequest{
@@ -7130,9 +8991,68 @@ func handleDownloadExport(messagesCollection, clientsCollection *mongo.Collectio
 			IncludeMeta:    includeMeta,
 		}
 
+		// ✅ NEW: Fill what the request left out from the client's export defaults
+		applyExportDefaults(req, exportRequestGiven{includeGeo: geoGiven, includeMeta: metaGiven},
+			loadExportDefaults(c, clientsCollection, req.ClientID), time.Now())
+		if req.Format == "" {
+			req.Format = "json" // Default format
+		}
+		format = req.Format
+		dateFrom, dateTo = req.DateFrom, req.DateTo
+
+		if !dateFrom.IsZero() && !dateTo.IsZero() && dateTo.Before(dateFrom) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_date_range",
+				"message":    "date_to must not be before date_from",
+			})
+			return
+		}
+
 		// Create export service
 		exportService := services.NewExportService(messagesCollection, clientsCollection)
 
+		// ✅ NEW: json, csv and txt are streamed straight from the cursor instead of being built in memory
+		if services.IsStreamingFormat(format) {
+			// JSON keeps its existing schema, including the empty-result response
+			if format == "json" {
+				total, err := exportService.CountMessages(c.Request.Context(), req, userClaims)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error_code": "export_failed",
+						"message":    "Failed to export chats: " + err.Error(),
+					})
+					return
+				}
+				if total == 0 {
+					c.JSON(http.StatusOK, gin.H{
+						"success":      true,
+						"message":      "No records found for the specified criteria",
+						"record_count": 0,
+					})
+					return
+				}
+			}
+
+			contentType, extension := services.StreamContentType(format)
+			c.Header("Content-Type", contentType)
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=chat_export_%s.%s", time.Now().Format("20060102_150405"), extension))
+			c.Status(http.StatusOK)
+
+			count, err := exportService.StreamMessages(c.Request.Context(), c.Writer, req, userClaims)
+			if err != nil {
+				logger.Error("Chat export stream failed", "error", err, "format", format, "records_written", count)
+				// Once the body has started the error can only be logged
+				if !c.Writer.Written() {
+					c.Header("Content-Disposition", "")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error_code": "export_failed",
+						"message":    "Failed to export chats",
+					})
+				}
+			}
+			return
+		}
+
 		// Perform export
 		response, err := exportService.ExportChats(c.Request.Context(), req, userClaims)
 		if err != nil {
@@ -7208,6 +9128,117 @@ func handleDownloadExport(messagesCollection, clientsCollection *mongo.Collectio
 
 // ========== CRAWLER HANDLERS ==========
 
+// crawlLimits are the effective limits of one crawl request
+type crawlLimits struct {
+	maxDepth       int
+	maxPages       int
+	sameDomainOnly bool
+	concurrency    int
+}
+
+// resolveCrawlLimits applies defaults to the requested crawl limits and checks them against the platform
+// maximums, returning a message when they are out of range
+func resolveCrawlLimits(cfg *config.Config, maxDepth, maxPages, concurrency int, sameDomainOnly *bool) (crawlLimits, string) {
+	limits := crawlLimits{
+		maxDepth:       crawler.DefaultMaxDepth,
+		maxPages:       crawler.DefaultMaxPages,
+		sameDomainOnly: true,
+		concurrency:    cfg.CrawlConcurrency,
+	}
+	if limits.maxDepth > cfg.CrawlMaxDepth {
+		limits.maxDepth = cfg.CrawlMaxDepth
+	}
+	if limits.maxPages > cfg.CrawlMaxPages {
+		limits.maxPages = cfg.CrawlMaxPages
+	}
+	if limits.concurrency <= 0 {
+		limits.concurrency = crawler.DefaultConcurrency
+	}
+	if limits.concurrency > cfg.CrawlMaxConcurrency {
+		limits.concurrency = cfg.CrawlMaxConcurrency
+	}
+
+	if maxDepth < 0 || maxDepth > cfg.CrawlMaxDepth {
+		return limits, fmt.Sprintf("max_depth must be between 1 and %d", cfg.CrawlMaxDepth)
+	}
+	if maxPages < 0 || maxPages > cfg.CrawlMaxPages {
+		return limits, fmt.Sprintf("max_pages must be between 1 and %d", cfg.CrawlMaxPages)
+	}
+	if concurrency < 0 || concurrency > cfg.CrawlMaxConcurrency {
+		return limits, fmt.Sprintf("concurrency must be between 1 and %d", cfg.CrawlMaxConcurrency)
+	}
+	if maxDepth > 0 {
+		limits.maxDepth = maxDepth
+	}
+	if concurrency > 0 {
+		limits.concurrency = concurrency
+	}
+	if maxPages > 0 {
+		limits.maxPages = maxPages
+	}
+	if sameDomainOnly != nil {
+		limits.sameDomainOnly = *sameDomainOnly
+	}
+	return limits, ""
+}
+
+// crawlProgressReporter returns a crawler progress callback that stores the running page counts on the job,
+// at most every few seconds
+func crawlProgressReporter(crawlsCollection *mongo.Collection, jobID primitive.ObjectID, maxPages int) func(pagesCrawled, pagesFound int) {
+	var mu sync.Mutex
+	var lastUpdate time.Time
+	return func(pagesCrawled, pagesFound int) {
+		mu.Lock()
+		if time.Since(lastUpdate) < 3*time.Second {
+			mu.Unlock()
+			return
+		}
+		lastUpdate = time.Now()
+		mu.Unlock()
+
+		// Fetching runs from 10% to 95%; the rest is saving results
+		progress := 10
+		if maxPages > 0 {
+			progress += 85 * pagesCrawled / maxPages
+		}
+		if progress > 95 {
+			progress = 95
+		}
+		crawlsCollection.UpdateOne(context.Background(), bson.M{"_id": jobID, "status": models.CrawlStatusCrawling}, bson.M{"$set": bson.M{
+			"progress":      progress,
+			"pages_crawled": pagesCrawled,
+			"pages_found":   pagesFound,
+			"updated_at":    time.Now(),
+		}})
+	}
+}
+
+// crawlThroughput reports how fast a crawl fetched pages, using the time so far for running crawls
+func crawlThroughput(job *models.CrawlJob) gin.H {
+	if job.StartTime == nil {
+		return nil
+	}
+	end := time.Now()
+	if job.CompletedAt != nil {
+		end = *job.CompletedAt
+	}
+	elapsed := end.Sub(*job.StartTime)
+	pagesPerMinute := 0.0
+	if elapsed > 0 {
+		pagesPerMinute = float64(job.PagesCrawled) / elapsed.Minutes()
+	}
+	return gin.H{
+		"elapsed_seconds":  int(elapsed.Seconds()),
+		"pages_per_minute": math.Round(pagesPerMinute*10) / 10,
+		"concurrency":      job.Concurrency,
+	}
+}
+
+// respectsRobots reports whether a crawl honors robots.txt; it does unless the request opts out
+func respectsRobots(requested *bool) bool {
+	return requested == nil || *requested
+}
+
 // handleStartCrawl starts a new crawl job
 func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -7221,13 +9252,17 @@ func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gi
 		}
 
 		var req struct {
-			URL            string   `json:"url" binding:"required"`
+			URL            string   `json:"url"`
+			SitemapURL     string   `json:"sitemap_url,omitempty"` // ✅ NEW: crawl the pages a sitemap.xml lists instead of following links
 			MaxPages       int      `json:"max_pages,omitempty"`
+			MaxDepth       int      `json:"max_depth,omitempty"`        // ✅ NEW: link depth from the start page
+			SameDomainOnly *bool    `json:"same_domain_only,omitempty"` // ✅ NEW: defaults to true
+			Concurrency    int      `json:"concurrency,omitempty"`      // ✅ NEW: pages fetched in parallel
 			AllowedDomains []string `json:"allowed_domains,omitempty"`
 			AllowedPaths   []string `json:"allowed_paths,omitempty"`
 			FollowLinks    bool     `json:"follow_links,omitempty"`
 			IncludeImages  bool     `json:"include_images,omitempty"`
-			RespectRobots  bool     `json:"respect_robots,omitempty"`
+			RespectRobots  *bool    `json:"respect_robots,omitempty"` // defaults to true
 			RenderJS       bool     `json:"render_js,omitempty"`
 			WaitSelector   string   `json:"wait_selector,omitempty"`
 			RenderTimeout  int      `json:"render_timeout_ms,omitempty"`
@@ -7240,6 +9275,28 @@ func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gi
 			})
 			return
 		}
+		req.URL = strings.TrimSpace(req.URL)
+		req.SitemapURL = strings.TrimSpace(req.SitemapURL)
+		if req.URL == "" && req.SitemapURL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Either url or sitemap_url is required",
+			})
+			return
+		}
+		if req.URL == "" {
+			req.URL = req.SitemapURL
+		}
+
+		// ✅ NEW: Per-request limits, bounded by the platform maximums
+		limits, errMsg := resolveCrawlLimits(cfg, req.MaxDepth, req.MaxPages, req.Concurrency, req.SameDomainOnly)
+		if errMsg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_crawl_limits",
+				"message":    errMsg,
+			})
+			return
+		}
 
 		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
 		if err != nil {
@@ -7261,12 +9318,16 @@ func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gi
 			PagesCrawled:   0,
 			CreatedAt:      time.Now(),
 			UpdatedAt:      time.Now(),
-			MaxPages:       req.MaxPages,
+			MaxPages:       limits.maxPages,
+			MaxDepth:       limits.maxDepth,
+			SameDomainOnly: limits.sameDomainOnly,
+			Concurrency:    limits.concurrency,
 			AllowedDomains: req.AllowedDomains,
 			AllowedPaths:   req.AllowedPaths,
 			FollowLinks:    req.FollowLinks,
 			IncludeImages:  req.IncludeImages,
-			RespectRobots:  req.RespectRobots,
+			RespectRobots:  respectsRobots(req.RespectRobots),
+			SitemapURL:     req.SitemapURL,
 		}
 
 		// Save to MongoDB
@@ -7284,21 +9345,40 @@ func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gi
 		go func() {
 			startTime := time.Now()
 			updateCrawlStatus(crawlsCollection, crawlJob.ID.Hex(), models.CrawlStatusCrawling, 5)
+			crawlsCollection.UpdateOne(context.Background(), bson.M{"_id": crawlJob.ID}, bson.M{"$set": bson.M{"start_time": startTime}})
 
 			// Configure crawler with production settings
-			maxPages := req.MaxPages
-			if maxPages <= 0 {
-				maxPages = 50 // Default limit
+			maxPages := limits.maxPages
+
+			// ✅ NEW: Seed the crawl with the pages listed in the sitemap
+			crawlURL := req.URL
+			var seedURLs []string
+			if req.SitemapURL != "" {
+				sitemap, err := crawler.FetchSitemapURLs(req.SitemapURL, maxPages)
+				if err != nil {
+					updateCrawlError(crawlsCollection, crawlJob.ID.Hex(), err.Error())
+					return
+				}
+				crawlURL, seedURLs = sitemap.URLs[0], sitemap.URLs[1:]
+				crawlsCollection.UpdateOne(context.Background(), bson.M{"_id": crawlJob.ID}, bson.M{"$set": bson.M{
+					"sitemap_urls":    len(sitemap.URLs),
+					"sitemap_skipped": sitemap.Skipped,
+					"updated_at":      time.Now(),
+				}})
 			}
 
 			crawlConfig := crawler.CrawlConfig{
-				URL:            req.URL,
+				URL:            crawlURL,
+				SeedURLs:       seedURLs,
 				MaxPages:       maxPages,
+				MaxDepth:       limits.maxDepth,
+				Concurrency:    limits.concurrency,
+				OnProgress:     crawlProgressReporter(crawlsCollection, crawlJob.ID, maxPages),
 				AllowedDomains: req.AllowedDomains,
 				AllowedPaths:   req.AllowedPaths,
 				FollowLinks:    req.FollowLinks,
 				IncludeImages:  req.IncludeImages,
-				RespectRobots:  req.RespectRobots,
+				RespectRobots:  respectsRobots(req.RespectRobots),
 				Timeout:        60 * time.Second, // Increased timeout for production
 				RenderJS:       req.RenderJS,
 				WaitSelector:   req.WaitSelector,
@@ -7308,7 +9388,8 @@ func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gi
 					}
 					return req.RenderTimeout
 				}()) * time.Millisecond,
-				NetworkIdleAfter: 800 * time.Millisecond,
+				NetworkIdleAfter:   800 * time.Millisecond,
+				AllowExternalLinks: !limits.sameDomainOnly,
 			}
 
 			// Update progress during crawl
@@ -7334,6 +9415,8 @@ func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gi
 							"content":         result.Content,
 							"pages_found":     result.PagesFound,
 							"pages_crawled":   result.PagesCrawled,
+							"robots_skipped":  result.RobotsSkipped,
+							"skipped_urls":    result.SkippedURLs,
 							"crawled_pages":   crawledPages,
 							"error":           fmt.Sprintf("Partial success: %v", err.Error()),
 							"updated_at":      time.Now(),
@@ -7369,6 +9452,8 @@ func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gi
 					"content":         result.Content,
 					"pages_found":     result.PagesFound,
 					"pages_crawled":   result.PagesCrawled,
+					"robots_skipped":  result.RobotsSkipped,
+					"skipped_urls":    result.SkippedURLs,
 					"crawled_pages":   crawledPages,
 					"updated_at":      time.Now(),
 					"completed_at":    completedAt,
@@ -7388,6 +9473,12 @@ func handleStartCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gi
 			"url":     crawlJob.URL,
 			"status":  crawlJob.Status,
 			"message": "Crawl job started successfully",
+			"limits": gin.H{
+				"max_depth":        crawlJob.MaxDepth,
+				"max_pages":        crawlJob.MaxPages,
+				"same_domain_only": crawlJob.SameDomainOnly,
+				"concurrency":      crawlJob.Concurrency,
+			},
 		})
 	}
 }
@@ -7407,11 +9498,14 @@ func handleBulkCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gin
 		var req struct {
 			URLs           []string `json:"urls" binding:"required,min=1"`
 			MaxPages       int      `json:"max_pages,omitempty"`
+			MaxDepth       int      `json:"max_depth,omitempty"`
+			SameDomainOnly *bool    `json:"same_domain_only,omitempty"`
+			Concurrency    int      `json:"concurrency,omitempty"` // ✅ NEW: pages fetched in parallel
 			AllowedDomains []string `json:"allowed_domains,omitempty"`
 			AllowedPaths   []string `json:"allowed_paths,omitempty"`
 			FollowLinks    bool     `json:"follow_links,omitempty"`
 			IncludeImages  bool     `json:"include_images,omitempty"`
-			RespectRobots  bool     `json:"respect_robots,omitempty"`
+			RespectRobots  *bool    `json:"respect_robots,omitempty"` // defaults to true
 			RenderJS       bool     `json:"render_js,omitempty"`
 			WaitSelector   string   `json:"wait_selector,omitempty"`
 			RenderTimeout  int      `json:"render_timeout_ms,omitempty"`
@@ -7455,6 +9549,15 @@ func handleBulkCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gin
 			return
 		}
 
+		limits, errMsg := resolveCrawlLimits(cfg, req.MaxDepth, req.MaxPages, req.Concurrency, req.SameDomainOnly)
+		if errMsg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_crawl_limits",
+				"message":    errMsg,
+			})
+			return
+		}
+
 		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -7479,12 +9582,15 @@ func handleBulkCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gin
 				PagesCrawled:   0,
 				CreatedAt:      time.Now(),
 				UpdatedAt:      time.Now(),
-				MaxPages:       req.MaxPages,
+				MaxPages:       limits.maxPages,
+				MaxDepth:       limits.maxDepth,
+				SameDomainOnly: limits.sameDomainOnly,
+				Concurrency:    limits.concurrency,
 				AllowedDomains: req.AllowedDomains,
 				AllowedPaths:   req.AllowedPaths,
 				FollowLinks:    req.FollowLinks,
 				IncludeImages:  req.IncludeImages,
-				RespectRobots:  req.RespectRobots,
+				RespectRobots:  respectsRobots(req.RespectRobots),
 			}
 
 			_, err = crawlsCollection.InsertOne(ctx, crawlJob)
@@ -7503,20 +9609,20 @@ func handleBulkCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gin
 			go func(jobID string, jobURL string) {
 				startTime := time.Now()
 				updateCrawlStatus(crawlsCollection, jobID, models.CrawlStatusCrawling, 5)
-
-				maxPages := req.MaxPages
-				if maxPages <= 0 {
-					maxPages = 50
-				}
+				jobObjID, _ := primitive.ObjectIDFromHex(jobID)
+				crawlsCollection.UpdateOne(context.Background(), bson.M{"_id": jobObjID}, bson.M{"$set": bson.M{"start_time": startTime}})
 
 				crawlConfig := crawler.CrawlConfig{
 					URL:            jobURL,
-					MaxPages:       maxPages,
+					MaxPages:       limits.maxPages,
+					MaxDepth:       limits.maxDepth,
+					Concurrency:    limits.concurrency,
+					OnProgress:     crawlProgressReporter(crawlsCollection, jobObjID, limits.maxPages),
 					AllowedDomains: req.AllowedDomains,
 					AllowedPaths:   req.AllowedPaths,
 					FollowLinks:    req.FollowLinks,
 					IncludeImages:  req.IncludeImages,
-					RespectRobots:  req.RespectRobots,
+					RespectRobots:  respectsRobots(req.RespectRobots),
 					Timeout:        60 * time.Second,
 					RenderJS:       req.RenderJS,
 					WaitSelector:   req.WaitSelector,
@@ -7526,7 +9632,8 @@ func handleBulkCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gin
 						}
 						return req.RenderTimeout
 					}()) * time.Millisecond,
-					NetworkIdleAfter: 800 * time.Millisecond,
+					NetworkIdleAfter:   800 * time.Millisecond,
+					AllowExternalLinks: !limits.sameDomainOnly,
 				}
 
 				updateCrawlStatus(crawlsCollection, jobID, models.CrawlStatusCrawling, 10)
@@ -7549,6 +9656,8 @@ func handleBulkCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gin
 								"content":         result.Content,
 								"pages_found":     result.PagesFound,
 								"pages_crawled":   result.PagesCrawled,
+								"robots_skipped":  result.RobotsSkipped,
+								"skipped_urls":    result.SkippedURLs,
 								"crawled_pages":   crawledPages,
 								"error":           fmt.Sprintf("Partial success: %v", err.Error()),
 								"updated_at":      time.Now(),
@@ -7582,6 +9691,8 @@ func handleBulkCrawl(cfg *config.Config, crawlsCollection *mongo.Collection) gin
 						"content":         result.Content,
 						"pages_found":     result.PagesFound,
 						"pages_crawled":   result.PagesCrawled,
+						"robots_skipped":  result.RobotsSkipped,
+						"skipped_urls":    result.SkippedURLs,
 						"crawled_pages":   crawledPages,
 						"updated_at":      time.Now(),
 						"completed_at":    completedAt,
@@ -7794,6 +9905,23 @@ func handleCrawlStatus(crawlsCollection *mongo.Collection) gin.HandlerFunc {
 			"updated_at":    crawlJob.UpdatedAt,
 			"completed_at":  crawlJob.CompletedAt,
 			"error":         crawlJob.Error,
+			// ✅ NEW: Pages taken from the sitemap vs. dropped (off-site, duplicate or over the page limit)
+			"sitemap_url":     crawlJob.SitemapURL,
+			"sitemap_urls":    crawlJob.SitemapURLs,
+			"sitemap_skipped": crawlJob.SitemapSkipped,
+			// ✅ NEW: URLs robots.txt disallowed, with the first few and the rule that blocked them
+			"respect_robots": crawlJob.RespectRobots,
+			"robots_skipped": crawlJob.RobotsSkipped,
+			"skipped_urls":   crawlJob.SkippedURLs,
+			// ✅ NEW: Limits the crawl ran with
+			"limits": gin.H{
+				"max_depth":        crawlJob.MaxDepth,
+				"max_pages":        crawlJob.MaxPages,
+				"same_domain_only": crawlJob.SameDomainOnly,
+				"concurrency":      crawlJob.Concurrency,
+			},
+			// ✅ NEW: Pages per minute so far (or overall once finished)
+			"throughput": crawlThroughput(&crawlJob),
 		})
 	}
 }
@@ -7945,7 +10073,7 @@ func handleGetImages(imagesCollection *mongo.Collection) gin.HandlerFunc {
 }
 
 // handleAddImage adds a new image for the authenticated client
-func handleAddImage(imagesCollection *mongo.Collection) gin.HandlerFunc {
+func handleAddImage(cfg *config.Config, clientsCollection, imagesCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userClientID := middleware.GetClientID(c)
 		if userClientID == "" {
@@ -7967,7 +10095,9 @@ func handleAddImage(imagesCollection *mongo.Collection) gin.HandlerFunc {
 
 		var req struct {
 			URL   string `json:"url" binding:"required"`
-			Title string `json:"title" binding:"required"`
+			Title   string   `json:"title" binding:"required"`
+			Caption string   `json:"caption"`
+			Tags    []string `json:"tags"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -7979,14 +10109,52 @@ func handleAddImage(imagesCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
+		// ✅ NEW: Tags and caption let chat replies surface the image
+		tags, err := normalizeImageTags(req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_tags",
+				"message":    err.Error(),
+			})
+			return
+		}
+		caption := strings.TrimSpace(req.Caption)
+		if len(caption) > maxImageCaptionLen {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_caption",
+				"message":    fmt.Sprintf("Caption must be at most %d characters", maxImageCaptionLen),
+			})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		defer cancel()
 
+		// ✅ NEW: Enforce the per-client image limit
+		remaining, maxImages, err := remainingImageSlots(ctx, cfg, clientsCollection, imagesCollection, clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to check image limit",
+			})
+			return
+		}
+		if remaining <= 0 {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "image_limit_exceeded",
+				"message":    fmt.Sprintf("Image limit reached (%d images)", maxImages),
+				"max_images": maxImages,
+			})
+			return
+		}
+
 		image := models.Image{
 			ID:        primitive.NewObjectID(),
 			ClientID:  clientObjID,
 			URL:       req.URL,
 			Title:     req.Title,
+			Caption:   caption,
+			Tags:      tags,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
@@ -8041,10 +10209,8 @@ func handleDeleteImage(imagesCollection *mongo.Collection) gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		defer cancel()
 
-		result, err := imagesCollection.DeleteOne(ctx, bson.M{
-			"_id":       imageObjID,
-			"client_id": clientObjID,
-		})
+		// ✅ NEW: Uploaded images also lose their stored file and thumbnail
+		found, err := deleteImage(ctx, imagesCollection, imageObjID, clientObjID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -8054,7 +10220,7 @@ func handleDeleteImage(imagesCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
-		if result.DeletedCount == 0 {
+		if !found {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error_code": "image_not_found",
 				"message":    "Image not found",
@@ -8192,14 +10358,16 @@ func handleGetCalendly(clientsCollection *mongo.Collection) gin.HandlerFunc {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"calendly_url":     client.CalendlyURL,
-			"calendly_enabled": client.CalendlyEnabled,
+			"calendly_url":            client.CalendlyURL,
+			"calendly_enabled":        client.CalendlyEnabled,
+			"calendly_event_type_uri": client.CalendlyEventTypeURI,
+			"calendly_api_configured": client.CalendlyAPIToken != "",
 		})
 	}
 }
 
 // handleUpdateCalendly updates Calendly configuration for the authenticated client
-func handleUpdateCalendly(clientsCollection *mongo.Collection) gin.HandlerFunc {
+func handleUpdateCalendly(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userClientID := middleware.GetClientID(c)
 		if userClientID == "" {
@@ -8220,8 +10388,10 @@ func handleUpdateCalendly(clientsCollection *mongo.Collection) gin.HandlerFunc {
 		}
 
 		var request struct {
-			CalendlyURL     string `json:"calendly_url"`
-			CalendlyEnabled *bool  `json:"calendly_enabled,omitempty"`
+			CalendlyURL          string  `json:"calendly_url"`
+			CalendlyEnabled      *bool   `json:"calendly_enabled,omitempty"`
+			CalendlyAPIToken     *string `json:"calendly_api_token,omitempty"`      // ✅ NEW: empty string clears the token
+			CalendlyEventTypeURI *string `json:"calendly_event_type_uri,omitempty"` // ✅ NEW
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
@@ -8246,6 +10416,16 @@ func handleUpdateCalendly(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			}
 		}
 
+		// Validate Calendly event type URI if provided
+		if request.CalendlyEventTypeURI != nil && *request.CalendlyEventTypeURI != "" &&
+			!strings.HasPrefix(*request.CalendlyEventTypeURI, "https://api.calendly.com/event_types/") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_event_type",
+				"message":    "Calendly event type URI must start with https://api.calendly.com/event_types/",
+			})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 		defer cancel()
 
@@ -8263,11 +10443,30 @@ func handleUpdateCalendly(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			update["$set"].(bson.M)["calendly_enabled"] = *request.CalendlyEnabled
 		}
 
+		if request.CalendlyAPIToken != nil {
+			// ✅ NEW: The token is stored encrypted, like webhook header values
+			sealed, err := sealIntegrationSecret(cfg, strings.TrimSpace(*request.CalendlyAPIToken))
+			if err != nil {
+				logger.Error("Failed to encrypt Calendly token", "error", err, "client_id", userClientID)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "encryption_failed",
+					"message":    "Failed to store the Calendly token",
+				})
+				return
+			}
+			update["$set"].(bson.M)["calendly_api_token"] = sealed
+		}
+
+		if request.CalendlyEventTypeURI != nil {
+			update["$set"].(bson.M)["calendly_event_type_uri"] = *request.CalendlyEventTypeURI
+		}
+
 		result, err := clientsCollection.UpdateOne(
 			ctx,
 			bson.M{"_id": clientObjID},
 			update,
 		)
+		invalidateClientConfig(clientObjID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -8301,6 +10500,8 @@ func handleUpdateCalendly(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			"message":         "Calendly configuration updated successfully",
 			"calendly_url":     updatedClient.CalendlyURL,
 			"calendly_enabled": updatedClient.CalendlyEnabled,
+			"calendly_event_type_uri": updatedClient.CalendlyEventTypeURI,
+			"calendly_api_configured": updatedClient.CalendlyAPIToken != "",
 		})
 	}
 }
@@ -8472,6 +10673,7 @@ func handleUpdateQRCode(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			bson.M{"_id": clientObjID},
 			update,
 		)
+		invalidateClientConfig(clientObjID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -8489,31 +10691,150 @@ func handleUpdateQRCode(clientsCollection *mongo.Collection) gin.HandlerFunc {
 			return
 		}
 
-		// Fetch updated client to return
-		var updatedClient models.Client
-		err = clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&updatedClient)
-		if err != nil {
-			c.JSON(http.StatusOK, gin.H{
-				"message":           "QR code configuration updated successfully",
-				"qr_code_image_url": request.QRCodeImageURL,
-				"qr_code_enabled":   request.QRCodeEnabled,
-			})
-			return
+		// Fetch updated client to return
+		var updatedClient models.Client
+		err = clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&updatedClient)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message":           "QR code configuration updated successfully",
+				"qr_code_image_url": request.QRCodeImageURL,
+				"qr_code_enabled":   request.QRCodeEnabled,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":           "QR code configuration updated successfully",
+			"qr_code_image_url": updatedClient.QRCodeImageURL,
+			"qr_code_enabled":   updatedClient.QRCodeEnabled,
+		})
+	}
+}
+
+// handlePublicWhatsAppQRCode returns WhatsApp QR code configuration for a specific client (public endpoint for embed widget)
+func handlePublicWhatsAppQRCode(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDHex := c.Param("client_id")
+		clientOID, err := primitive.ObjectIDFromHex(clientIDHex)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		var client models.Client
+		err = clientsCollection.FindOne(ctx, bson.M{"_id": clientOID}).Decode(&client)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "client_not_found",
+					"message":    "Client not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch WhatsApp QR code configuration",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"whatsapp_qr_code_image_url": client.WhatsAppQRCodeImageURL,
+			"whatsapp_qr_code_enabled":   client.WhatsAppQRCodeEnabled,
+			"whatsapp_handoff_enabled":   client.WhatsAppQRCodeEnabled && normalizeWhatsAppNumber(client.WhatsAppNumber) != "",
+		})
+	}
+}
+
+// ✅ NEW: WhatsApp click-to-chat handoff
+
+// whatsAppHandoffPhrases are phrases that signal the user wants to move the chat to WhatsApp
+var whatsAppHandoffPhrases = []string{
+	"on whatsapp", "via whatsapp", "to whatsapp", "whatsapp me", "whatsapp pe", "whatsapp par",
+	"continue on whatsapp", "chat on whatsapp", "message on whatsapp", "whatsapp number",
+}
+
+// isWhatsAppHandoffRequest checks if the user asked to continue the conversation on WhatsApp
+func isWhatsAppHandoffRequest(message string) bool {
+	messageLower := strings.ToLower(message)
+	for _, phrase := range whatsAppHandoffPhrases {
+		if strings.Contains(messageLower, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWhatsAppNumber strips everything but digits, as required by wa.me links
+func normalizeWhatsAppNumber(number string) string {
+	var digits strings.Builder
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
 		}
+	}
+	return digits.String()
+}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":           "QR code configuration updated successfully",
-			"qr_code_image_url": updatedClient.QRCodeImageURL,
-			"qr_code_enabled":   updatedClient.QRCodeEnabled,
-		})
+// buildWhatsAppDeepLink builds a wa.me link prefilled with a message referencing the session
+func buildWhatsAppDeepLink(number, sessionID, userName string) string {
+	var text string
+	if userName != "" {
+		text = fmt.Sprintf("Hi, I'm %s. I'd like to continue my chat from your website (ref: %s).", userName, sessionID)
+	} else {
+		text = fmt.Sprintf("Hi, I'd like to continue my chat from your website (ref: %s).", sessionID)
 	}
+	return fmt.Sprintf("https://wa.me/%s?text=%s", number, url.QueryEscape(text))
 }
 
-// handlePublicWhatsAppQRCode returns WhatsApp QR code configuration for a specific client (public endpoint for embed widget)
-func handlePublicWhatsAppQRCode(clientsCollection *mongo.Collection) gin.HandlerFunc {
+// mintWhatsAppHandoff creates a WhatsApp deep link for an embed conversation and records the handoff on it.
+// Returns nil if the client has no WhatsApp number configured.
+func mintWhatsAppHandoff(ctx context.Context, collection *mongo.Collection, client *models.Client, sessionID string) (*models.ChannelHandoff, error) {
+	number := normalizeWhatsAppNumber(client.WhatsAppNumber)
+	if !client.WhatsAppQRCodeEnabled || number == "" {
+		return nil, nil
+	}
+
+	// Use the captured name, if any, so the business knows who is writing
+	var userName string
+	opts := options.FindOne().SetSort(bson.M{"timestamp": -1})
+	var latestMessage models.Message
+	if err := collection.FindOne(ctx, bson.M{
+		"client_id":       client.ID,
+		"conversation_id": sessionID,
+		"user_name":       bson.M{"$nin": []interface{}{nil, ""}},
+	}, opts).Decode(&latestMessage); err == nil {
+		userName = latestMessage.UserName
+	}
+
+	handoff := &models.ChannelHandoff{
+		Channel:   "whatsapp",
+		Link:      buildWhatsAppDeepLink(number, sessionID, userName),
+		UserName:  userName,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := collection.UpdateMany(ctx, bson.M{
+		"client_id":       client.ID,
+		"conversation_id": sessionID,
+	}, bson.M{"$set": bson.M{"whatsapp_handoff": handoff}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record whatsapp handoff: %w", err)
+	}
+
+	return handoff, nil
+}
+
+// handlePublicWhatsAppHandoff mints a wa.me deep link for an embed conversation (public endpoint for embed widget)
+func handlePublicWhatsAppHandoff(clientsCollection, messagesCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientIDHex := c.Param("client_id")
-		clientOID, err := primitive.ObjectIDFromHex(clientIDHex)
+		clientOID, err := primitive.ObjectIDFromHex(c.Param("client_id"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error_code": "invalid_client_id",
@@ -8522,6 +10843,18 @@ func handlePublicWhatsAppQRCode(clientsCollection *mongo.Collection) gin.Handler
 			return
 		}
 
+		var req struct {
+			SessionID string `json:"session_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 		defer cancel()
 
@@ -8537,14 +10870,52 @@ func handlePublicWhatsAppQRCode(clientsCollection *mongo.Collection) gin.Handler
 			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error_code": "database_error",
-				"message":    "Failed to fetch WhatsApp QR code configuration",
+				"message":    "Failed to fetch client",
+			})
+			return
+		}
+
+		// The session must be an existing embed conversation for this client
+		count, err := messagesCollection.CountDocuments(ctx, bson.M{
+			"client_id":       clientOID,
+			"conversation_id": req.SessionID,
+			"is_embed_user":   true,
+		}, options.Count().SetLimit(1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to look up conversation",
+			})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "conversation_not_found",
+				"message":    "Conversation not found",
+			})
+			return
+		}
+
+		handoff, err := mintWhatsAppHandoff(ctx, messagesCollection, &client, req.SessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "handoff_failed",
+				"message":    "Failed to create WhatsApp link",
+			})
+			return
+		}
+		if handoff == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "whatsapp_not_configured",
+				"message":    "WhatsApp is not configured for this client",
 			})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"whatsapp_qr_code_image_url": client.WhatsAppQRCodeImageURL,
-			"whatsapp_qr_code_enabled":   client.WhatsAppQRCodeEnabled,
+			"whatsapp_link": handoff.Link,
+			"session_id":    req.SessionID,
+			"created_at":    handoff.CreatedAt,
 		})
 	}
 }
@@ -8593,6 +10964,7 @@ func handleGetWhatsAppQRCode(clientsCollection *mongo.Collection) gin.HandlerFun
 		c.JSON(http.StatusOK, gin.H{
 			"whatsapp_qr_code_image_url": client.WhatsAppQRCodeImageURL,
 			"whatsapp_qr_code_enabled":   client.WhatsAppQRCodeEnabled,
+			"whatsapp_number":            client.WhatsAppNumber,
 		})
 	}
 }
@@ -8620,7 +10992,8 @@ func handleUpdateWhatsAppQRCode(clientsCollection *mongo.Collection) gin.Handler
 
 		var request struct {
 			WhatsAppQRCodeImageURL string `json:"whatsapp_qr_code_image_url"`
-			WhatsAppQRCodeEnabled  *bool  `json:"whatsapp_qr_code_enabled,omitempty"`
+			WhatsAppQRCodeEnabled  *bool   `json:"whatsapp_qr_code_enabled,omitempty"`
+			WhatsAppNumber         *string `json:"whatsapp_number,omitempty"` // ✅ NEW
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
@@ -8671,11 +11044,16 @@ func handleUpdateWhatsAppQRCode(clientsCollection *mongo.Collection) gin.Handler
 			update["$set"].(bson.M)["whatsapp_qr_code_enabled"] = *request.WhatsAppQRCodeEnabled
 		}
 
+		if request.WhatsAppNumber != nil {
+			update["$set"].(bson.M)["whatsapp_number"] = strings.TrimSpace(*request.WhatsAppNumber)
+		}
+
 		result, err := clientsCollection.UpdateOne(
 			ctx,
 			bson.M{"_id": clientObjID},
 			update,
 		)
+		invalidateClientConfig(clientObjID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -8709,6 +11087,7 @@ func handleUpdateWhatsAppQRCode(clientsCollection *mongo.Collection) gin.Handler
 			"message":                     "WhatsApp QR code configuration updated successfully",
 			"whatsapp_qr_code_image_url":  updatedClient.WhatsAppQRCodeImageURL,
 			"whatsapp_qr_code_enabled":    updatedClient.WhatsAppQRCodeEnabled,
+			"whatsapp_number":             updatedClient.WhatsAppNumber,
 		})
 	}
 }
@@ -8880,6 +11259,7 @@ func handleUpdateTelegramQRCode(clientsCollection *mongo.Collection) gin.Handler
 			bson.M{"_id": clientObjID},
 			update,
 		)
+		invalidateClientConfig(clientObjID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -9202,6 +11582,7 @@ func handleUpdateFacebookPostsConfig(clientsCollection *mongo.Collection) gin.Ha
 			bson.M{"_id": clientObjID},
 			update,
 		)
+		invalidateClientConfig(clientObjID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -9603,6 +11984,7 @@ func handleUpdateInstagramPostsConfig(clientsCollection *mongo.Collection) gin.H
 			bson.M{"_id": clientObjID},
 			update,
 		)
+		invalidateClientConfig(clientObjID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -9836,6 +12218,7 @@ func handleUpdateWebsiteEmbedConfig(clientsCollection *mongo.Collection) gin.Han
 			bson.M{"_id": clientObjID},
 			update,
 		)
+		invalidateClientConfig(clientObjID)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -10327,6 +12710,185 @@ func handleDeleteEmailTemplate(emailTemplatesCollection *mongo.Collection) gin.H
 	}
 }
 
+// ✅ NEW: Email template test-send
+
+// maxTemplateTestSendsPerHour limits test emails per client
+const maxTemplateTestSendsPerHour = 10
+
+// sampleEmailVariables are used for placeholders the caller didn't supply when test-sending a template
+var sampleEmailVariables = map[string]string{
+	"name":                "Jane Doe",
+	"email":               "jane.doe@example.com",
+	"phone":               "+1 555 0100",
+	"company":             "Acme Corp",
+	"companyName":         "Acme Corp",
+	"message":             "I'd like to know more about your pricing.",
+	"frequency":           "weekly",
+	"period_start":        "Jan 1, 2025",
+	"period_end":          "Jan 8, 2025",
+	"total_messages":      "1,240",
+	"total_conversations": "312",
+	"total_tokens":        "48,900",
+	"satisfaction_rate":   "92.5%",
+	"top_topics":          "pricing, features, support",
+	"new_leads":           "18",
+}
+
+// handleTestEmailTemplate renders a template with sample data and sends it to a given address
+func handleTestEmailTemplate(cfg *config.Config, emailTemplatesCollection, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		templateType := c.Param("type")
+		if templateType == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_type",
+				"message":    "Template type is required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var req struct {
+			To        string            `json:"to" binding:"required"`
+			Variables map[string]string `json:"variables"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		recipient, err := mail.ParseAddress(strings.TrimSpace(req.To))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_recipient",
+				"message":    "Invalid recipient email address",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+
+		// Rate limit test sends per client
+		testSendsCollection := emailTemplatesCollection.Database().Collection("email_template_test_sends")
+		recentSends, err := testSendsCollection.CountDocuments(ctx, bson.M{
+			"client_id": clientObjID,
+			"sent_at":   bson.M{"$gte": time.Now().Add(-time.Hour)},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to check test send limit",
+			})
+			return
+		}
+		if recentSends >= maxTemplateTestSendsPerHour {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error_code": "rate_limit_exceeded",
+				"message":    fmt.Sprintf("At most %d test emails can be sent per hour", maxTemplateTestSendsPerHour),
+			})
+			return
+		}
+
+		var template models.EmailTemplate
+		err = emailTemplatesCollection.FindOne(ctx, bson.M{
+			"client_id": clientObjID,
+			"type":      templateType,
+		}).Decode(&template)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "template_not_found",
+				"message":    "Email template not found",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch email template",
+			})
+			return
+		}
+
+		// Sample data, then the client's name, then caller-supplied values
+		vars := make(map[string]string)
+		for k, v := range sampleEmailVariables {
+			vars[k] = v
+		}
+		var client models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err == nil {
+			vars["client_name"] = client.Name
+		}
+		for k, v := range req.Variables {
+			vars[k] = v
+		}
+		// Any other placeholder gets a visible stand-in so the preview always renders
+		for _, name := range services.TemplateVariables(template) {
+			if _, ok := vars[name]; !ok {
+				vars[name] = "[" + name + "]"
+			}
+		}
+
+		rendered, err := services.RenderEmailTemplateContent(template, vars)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error_code": "render_failed",
+				"message":    "Failed to render email template",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		emailSender := services.NewSMTPEmailSender(*cfg)
+		sendErr := emailSender.SendEmail([]string{recipient.Address}, "[TEST] "+rendered.Subject, rendered.HTMLBody, rendered.TextBody)
+
+		testSendsCollection.InsertOne(ctx, bson.M{
+			"client_id":     clientObjID,
+			"template_type": templateType,
+			"to":            recipient.Address,
+			"success":       sendErr == nil,
+			"sent_at":       time.Now(),
+		})
+
+		if sendErr != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error_code": "send_failed",
+				"message":    "Failed to send test email",
+				"details":    sendErr.Error(),
+				"rendered":   rendered,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Test email sent successfully",
+			"to":        recipient.Address,
+			"rendered":  rendered,
+			"variables": services.TemplateVariables(template),
+		})
+	}
+}
+
 // handlePublicQuote handles quote/proposal requests from embedded widgets
 func handlePublicQuote(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -10859,6 +13421,196 @@ func getMaxWordsForDepth(depth int) int {
 	return maxWords
 }
 
+// aiResponseMeta carries per-reply details that are persisted alongside the message
+type aiResponseMeta struct {
+	SourceAttribution  *models.SourceAttribution
+	Confidence         *models.ResponseConfidence
+	DemoBooking        *models.DemoBooking
+	WhatsAppHandoff    *models.ChannelHandoff
+	Spam               *models.SpamVerdict     // ✅ NEW: set when the message was filtered as spam
+	NoAnswerEscalation bool                    // ✅ NEW: the reply offers the team after repeated unanswered turns
+	Structured         *models.StructuredReply // ✅ NEW: set when a structured reply was requested and parsed
+	QuickReplies       []string                // ✅ NEW: suggested next questions, when enabled in branding
+	PromptSnapshot     *models.PromptSnapshot  // ✅ NEW: redacted prompt, kept when prompt inspection is enabled
+	Persona            *models.ClientPersona   // ✅ NEW: client persona the reply was routed to (nil = default persona)
+	RepeatedReply      bool                    // ✅ NEW: the first reply nearly repeated an earlier one and was regenerated
+	StrippedClaims     int                     // ✅ NEW: unsupported sentences replaced by strict grounding
+}
+
+// ✅ ADDED: Multi-document answer attribution
+// attributionMinScore is the minimum token overlap for a chunk to count as a source
+const attributionMinScore = 0.15
+
+// splitCrawlChunkSource separates a crawled chunk's text from the page URL it carries as a trailing
+// "Source: <url>" line; the chunk ID is returned when there is none
+func splitCrawlChunkSource(chunk models.ContentChunk) (string, string) {
+	if idx := strings.LastIndex(chunk.Text, "\n\nSource: "); idx != -1 {
+		return chunk.Text[:idx], strings.TrimSpace(chunk.Text[idx+len("\n\nSource: "):])
+	}
+	return chunk.Text, chunk.ChunkID
+}
+
+// attributeReplyToSources scores each retrieved chunk by token overlap with the reply
+// and reports which source type (PDF or crawl) the answer most likely came from
+func attributeReplyToSources(reply string, pdfChunks, crawledChunks []models.ContentChunk) *models.SourceAttribution {
+	attribution := &models.SourceAttribution{DominantSource: "none"}
+
+	replyTokens := attributionTokens(reply)
+	if len(replyTokens) == 0 {
+		return attribution
+	}
+
+	var candidates []models.AttributedSource
+	score := func(chunks []models.ContentChunk, sourceType string) float64 {
+		best := 0.0
+		for _, chunk := range chunks {
+			text, reference := chunk.Text, chunk.ChunkID
+			if sourceType == "crawl" {
+				text, reference = splitCrawlChunkSource(chunk)
+			}
+
+			chunkTokens := attributionTokens(text)
+			overlap := 0
+			for token := range replyTokens {
+				if chunkTokens[token] {
+					overlap++
+				}
+			}
+			chunkScore := float64(overlap) / float64(len(replyTokens))
+			if chunkScore > best {
+				best = chunkScore
+			}
+			if chunkScore >= attributionMinScore {
+				candidates = append(candidates, models.AttributedSource{
+					SourceType: sourceType,
+					Reference:  reference,
+					Score:      math.Round(chunkScore*1000) / 1000,
+				})
+			}
+		}
+		return math.Round(best*1000) / 1000
+	}
+
+	attribution.PDFScore = score(pdfChunks, "pdf")
+	attribution.CrawlScore = score(crawledChunks, "crawl")
+
+	switch {
+	case attribution.PDFScore < attributionMinScore && attribution.CrawlScore < attributionMinScore:
+		attribution.DominantSource = "none"
+	case attribution.PDFScore >= attribution.CrawlScore:
+		attribution.DominantSource = "pdf"
+	default:
+		attribution.DominantSource = "crawl"
+	}
+
+	// Keep the 3 strongest matches
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	if len(candidates) > 3 {
+		candidates = candidates[:3]
+	}
+	attribution.TopSources = candidates
+
+	return attribution
+}
+
+// ✅ ADDED: Response confidence
+// noInformationPhrases are the prompt's prescribed ways of admitting missing information
+var noInformationPhrases = []string{
+	"i don't have that information",
+	"i don't have that specific information",
+	"i don't have information",
+	"i do not have that information",
+	"i do not have information",
+	"don't have that information for our company",
+}
+
+// scoreResponseConfidence combines retrieval quality (how much of the question the best chunk covers),
+// grounding (how much of the reply overlaps a retrieved chunk) and explicit "I don't have that
+// information" replies into a 0-1 score. The persona counts as a knowledge chunk.
+func scoreResponseConfidence(query, reply string, chunks []models.ContentChunk, persona string, attribution *models.SourceAttribution) *models.ResponseConfidence {
+	confidence := &models.ResponseConfidence{}
+
+	knowledge := make([]string, 0, len(chunks)+1)
+	for _, chunk := range chunks {
+		knowledge = append(knowledge, chunk.Text)
+	}
+	if persona != "" {
+		knowledge = append(knowledge, persona)
+	}
+
+	queryTokens := attributionTokens(query)
+	if len(queryTokens) == 0 {
+		// Greetings and very short messages: nothing to retrieve, so don't penalise
+		confidence.RetrievalScore = 0.5
+		confidence.HasGoodMatches = len(knowledge) > 0
+	} else {
+		for _, text := range knowledge {
+			chunkTokens := attributionTokens(text)
+			matched := 0
+			for token := range queryTokens {
+				if chunkTokens[token] {
+					matched++
+				}
+			}
+			if matched > 0 {
+				confidence.HasGoodMatches = true
+			}
+			if score := float64(matched) / float64(len(queryTokens)); score > confidence.RetrievalScore {
+				confidence.RetrievalScore = score
+			}
+		}
+	}
+
+	if attribution != nil {
+		confidence.GroundingScore = math.Max(attribution.PDFScore, attribution.CrawlScore)
+	}
+
+	replyLower := strings.ToLower(strings.ReplaceAll(reply, "’", "'"))
+	for _, phrase := range noInformationPhrases {
+		if strings.Contains(replyLower, phrase) {
+			confidence.NoInformation = true
+			break
+		}
+	}
+
+	score := 0.2 + 0.45*confidence.RetrievalScore + 0.35*confidence.GroundingScore
+	if !confidence.HasGoodMatches {
+		score = math.Min(score, 0.4)
+	}
+	if confidence.NoInformation {
+		score = math.Min(score, 0.2)
+	}
+	score = math.Min(score, 1)
+
+	confidence.Score = math.Round(score*100) / 100
+	confidence.RetrievalScore = math.Round(confidence.RetrievalScore*1000) / 1000
+	switch {
+	case confidence.Score >= 0.7:
+		confidence.Level = "high"
+	case confidence.Score >= 0.4:
+		confidence.Level = "medium"
+	default:
+		confidence.Level = "low"
+	}
+	return confidence
+}
+
+// attributionTokens returns the set of meaningful lowercase words in text
+func attributionTokens(text string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(word)) < 4 {
+			continue // skip short/stop words
+		}
+		tokens[word] = true
+	}
+	return tokens
+}
+
 // ✅ ADDED: Performance metrics storage
 // storePerformanceMetrics stores performance metrics in database
 func storePerformanceMetrics(db *mongo.Database, clientID primitive.ObjectID, sessionID string, 
@@ -10901,6 +13653,15 @@ type UserFriendlyError struct {
 func mapToUserFriendlyError(err error, context string) UserFriendlyError {
 	errorStr := err.Error()
 	errorLower := strings.ToLower(errorStr)
+
+	// ✅ NEW: Content blocked by the client's safety settings
+	if errors.Is(err, errContentBlocked) {
+		return UserFriendlyError{
+			UserMessage: "I'm not able to respond to that. Please rephrase your question.",
+			Technical:   errorStr,
+			Action:      "rephrase",
+		}
+	}
 	
 	// Network/timeout errors
 	if strings.Contains(errorLower, "context deadline exceeded") || 
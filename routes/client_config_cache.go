@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ===================
+// CLIENT CONFIG CACHE
+// ===================
+
+// clientConfigCache keeps client documents in memory for CLIENT_CONFIG_CACHE_TTL seconds so the branding,
+// chat and token endpoints don't read the clients collection on every request. Each instance holds its
+// own copy: handlers that write a client invalidate it here, and other instances pick the change up once
+// their TTL runs out.
+//
+// token_used changes on every reply, so rather than invalidating on each one, updateTokenUsage applies its
+// delta to the cached copy. Usage recorded by other instances shows up after the TTL; the token limit
+// itself is enforced by the atomic update in updateTokenUsage, not by the cached count.
+type clientConfigCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration // 0 disables the cache
+	entries map[primitive.ObjectID]clientConfigCacheEntry
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type clientConfigCacheEntry struct {
+	client    models.Client
+	expiresAt time.Time
+}
+
+// maxClientConfigCacheEntries bounds memory; expired entries are dropped first when it is reached
+const maxClientConfigCacheEntries = 10000
+
+var sharedClientConfigCache = &clientConfigCache{entries: make(map[primitive.ObjectID]clientConfigCacheEntry)}
+
+func (cc *clientConfigCache) setTTL(ttl time.Duration) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.ttl = ttl
+	if ttl <= 0 {
+		cc.entries = make(map[primitive.ObjectID]clientConfigCacheEntry)
+	}
+}
+
+// get returns a copy of the cached client while it is fresh
+func (cc *clientConfigCache) get(clientID primitive.ObjectID) (*models.Client, bool) {
+	cc.mu.RLock()
+	entry, ok := cc.entries[clientID]
+	enabled := cc.ttl > 0
+	cc.mu.RUnlock()
+	if !enabled {
+		return nil, false
+	}
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		cc.misses.Add(1)
+		return nil, false
+	}
+	cc.hits.Add(1)
+	client := entry.client
+	return &client, true
+}
+
+func (cc *clientConfigCache) set(client models.Client) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	if len(cc.entries) >= maxClientConfigCacheEntries {
+		for id, entry := range cc.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(cc.entries, id)
+			}
+		}
+		if len(cc.entries) >= maxClientConfigCacheEntries {
+			cc.entries = make(map[primitive.ObjectID]clientConfigCacheEntry)
+		}
+	}
+	cc.entries[client.ID] = clientConfigCacheEntry{client: client, expiresAt: now.Add(cc.ttl)}
+}
+
+func (cc *clientConfigCache) invalidate(clientID primitive.ObjectID) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	delete(cc.entries, clientID)
+}
+
+// addTokenUsage applies a successful token usage update to the cached copy
+func (cc *clientConfigCache) addTokenUsage(clientID primitive.ObjectID, tokens int) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if entry, ok := cc.entries[clientID]; ok {
+		entry.client.TokenUsed += tokens
+		cc.entries[clientID] = entry
+	}
+}
+
+// stats reports how many lookups were served from memory, i.e. the clients reads saved
+func (cc *clientConfigCache) stats() map[string]interface{} {
+	cc.mu.RLock()
+	size, ttl := len(cc.entries), cc.ttl
+	cc.mu.RUnlock()
+	hits, misses := cc.hits.Load(), cc.misses.Load()
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = float64(hits) / float64(hits+misses)
+	}
+	return map[string]interface{}{
+		"enabled":     ttl > 0,
+		"ttl_seconds": int(ttl.Seconds()),
+		"entries":     size,
+		"hits":        hits,
+		"misses":      misses,
+		"hit_rate":    hitRate,
+	}
+}
+
+// invalidateClientConfig drops the cached client so the next lookup reads it from the database
+func invalidateClientConfig(clientID primitive.ObjectID) {
+	sharedClientConfigCache.invalidate(clientID)
+}
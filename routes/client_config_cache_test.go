@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestClientConfigCache(t *testing.T) {
+	cc := &clientConfigCache{entries: make(map[primitive.ObjectID]clientConfigCacheEntry)}
+	client := models.Client{ID: primitive.NewObjectID(), Name: "Acme", TokenUsed: 100}
+
+	cc.set(client)
+	if _, ok := cc.get(client.ID); ok {
+		t.Fatal("disabled cache returned a client")
+	}
+
+	cc.setTTL(time.Minute)
+	if _, ok := cc.get(client.ID); ok {
+		t.Fatal("hit before the client was cached")
+	}
+	cc.set(client)
+
+	got, ok := cc.get(client.ID)
+	if !ok || got.Name != "Acme" {
+		t.Fatalf("got (%+v, %v), want the cached client", got, ok)
+	}
+	got.Name = "changed by caller"
+	if again, _ := cc.get(client.ID); again.Name != "Acme" {
+		t.Error("a caller's change leaked into the cache")
+	}
+
+	cc.addTokenUsage(client.ID, 25)
+	if got, _ := cc.get(client.ID); got.TokenUsed != 125 {
+		t.Errorf("TokenUsed = %d, want 125", got.TokenUsed)
+	}
+
+	cc.invalidate(client.ID)
+	if _, ok := cc.get(client.ID); ok {
+		t.Error("hit after invalidation")
+	}
+
+	cc.set(client)
+	cc.entries[client.ID] = clientConfigCacheEntry{client: client, expiresAt: time.Now().Add(-time.Second)}
+	if _, ok := cc.get(client.ID); ok {
+		t.Error("hit on an expired entry")
+	}
+
+	stats := cc.stats()
+	if stats["hits"].(int64) != 3 || stats["misses"].(int64) != 3 {
+		t.Errorf("stats = %v, want 3 hits and 3 misses", stats)
+	}
+}
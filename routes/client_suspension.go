@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// CLIENT SUSPENSION
+// ===================
+//
+// POST /admin/client/:id/suspend sets the client's status to "suspended" with a reason, and
+// POST /admin/client/:id/reactivate sets it back to "active". Both are audited and can email the client's
+// contact address. While suspended, the chat endpoints refuse messages as they already do for inactive
+// clients, and the embed widget shows the suspension's widget message or SUSPENDED_WIDGET_MESSAGE.
+
+// maxSuspensionTextLength caps the suspension reason and widget message
+const maxSuspensionTextLength = 500
+
+// clientChatDisabled reports whether the client's status blocks chat
+func clientChatDisabled(client *models.Client) bool {
+	return client.Status == "inactive" || client.Status == "suspended"
+}
+
+// suspendedWidgetMessage is what the embed widget shows while the client can't chat
+func suspendedWidgetMessage(cfg *config.Config, client *models.Client) string {
+	if client.Suspension != nil && client.Suspension.WidgetMessage != "" {
+		return client.Suspension.WidgetMessage
+	}
+	return cfg.SuspendedWidgetMessage
+}
+
+// notifyClientStatusChange emails the client's contact address about a suspension or reactivation (async)
+func notifyClientStatusChange(cfg *config.Config, client *models.Client, suspended bool, reason string) bool {
+	if client.ContactEmail == "" {
+		return false
+	}
+
+	subject := fmt.Sprintf("Your chatbot account %s has been reactivated", client.Name)
+	text := fmt.Sprintf("Hello,\n\nYour chatbot account %s has been reactivated and your chat widget is available again.", client.Name)
+	if suspended {
+		subject = fmt.Sprintf("Your chatbot account %s has been suspended", client.Name)
+		text = fmt.Sprintf("Hello,\n\nYour chatbot account %s has been suspended and your chat widget no longer answers visitors.", client.Name)
+	}
+	if reason != "" {
+		text += "\n\nReason: " + reason
+	}
+	text += "\n\nPlease contact support if you have any questions."
+	htmlBody := "<html><body><p>" + strings.ReplaceAll(html.EscapeString(text), "\n\n", "</p><p>") + "</p></body></html>"
+
+	recipient := client.ContactEmail
+	clientID := client.ID.Hex()
+	go func() {
+		if err := services.NewSMTPEmailSender(*cfg).SendEmail([]string{recipient}, subject, htmlBody, text); err != nil {
+			logger.Warn("Failed to send client status email", "error", err, "client_id", clientID, "suspended", suspended)
+		}
+	}()
+	return true
+}
+
+// handleSetClientSuspension suspends (suspend=true) or reactivates a client
+func handleSetClientSuspension(cfg *config.Config, clientsCollection *mongo.Collection, auditLogger *models.AuditLogger, suspend bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error_code": "invalid_client_id", "message": "Invalid client ID format"})
+			return
+		}
+
+		var req struct {
+			Reason        string `json:"reason"`
+			WidgetMessage string `json:"widget_message,omitempty"`
+			Notify        bool   `json:"notify"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_input",
+				"message":    "Invalid request data",
+				"details":    gin.H{"error": err.Error()},
+			})
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		req.WidgetMessage = strings.TrimSpace(req.WidgetMessage)
+		if suspend && req.Reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error_code": "reason_required", "message": "A reason is required to suspend a client"})
+			return
+		}
+		if len(req.Reason) > maxSuspensionTextLength || len(req.WidgetMessage) > maxSuspensionTextLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_input",
+				"message":    fmt.Sprintf("reason and widget_message can be at most %d characters", maxSuspensionTextLength),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		adminID := middleware.GetUserID(c)
+		update := bson.M{
+			"$set":   bson.M{"status": "active", "updated_at": now},
+			"$unset": bson.M{"suspension": ""},
+		}
+		action := "reactivate"
+		if suspend {
+			update = bson.M{"$set": bson.M{
+				"status": "suspended",
+				"suspension": models.ClientSuspension{
+					Reason:        req.Reason,
+					WidgetMessage: req.WidgetMessage,
+					SuspendedBy:   adminID,
+					SuspendedAt:   now,
+				},
+				"updated_at": now,
+			}}
+			action = "suspend"
+		}
+
+		// The previous state is returned for the audit entry
+		var previous models.Client
+		err = clientsCollection.FindOneAndUpdate(ctx, bson.M{"_id": clientID}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&previous)
+		invalidateClientConfig(clientID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error_code": "client_not_found", "message": "Client not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error_code": "internal_error", "message": "Failed to update client status"})
+			return
+		}
+
+		previousStatus := previous.Status
+		if previousStatus == "" {
+			previousStatus = "active"
+		}
+		if auditLogger != nil {
+			auditLogger.Log(&models.AuditEvent{
+				ClientID:   clientID.Hex(),
+				UserID:     adminID,
+				Action:     "UPDATE",
+				Resource:   "client",
+				ResourceID: clientID.Hex(),
+				IPAddress:  c.ClientIP(),
+				UserAgent:  c.Request.UserAgent(),
+				RequestID:  middleware.GetRequestID(c),
+				Success:    true,
+				Changes: map[string]interface{}{
+					"operation":       action,
+					"previous_status": previousStatus,
+					"reason":          req.Reason,
+				},
+			})
+		}
+
+		notified := false
+		if req.Notify {
+			notified = notifyClientStatusChange(cfg, &previous, suspend, req.Reason)
+		}
+
+		status, message := "active", "Client reactivated"
+		if suspend {
+			status, message = "suspended", "Client suspended"
+		}
+		logger.Info("Client status changed", "client_id", clientID.Hex(), "operation", action, "previous_status", previousStatus, "admin_id", adminID)
+		c.JSON(http.StatusOK, gin.H{
+			"message":         message,
+			"client_id":       clientID.Hex(),
+			"status":          status,
+			"previous_status": previousStatus,
+			"reason":          req.Reason,
+			"notified":        notified,
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// DISPLAY COMPANY NAME
+// ===================
+//
+// The bot speaks for the brand in branding.display_company_name, falling back to the account name, so an
+// agency account can run a bot for each brand it manages. The name is written into prompts and replies:
+// branding updates reject markup and template characters, and the value is sanitized again when used.
+
+const maxCompanyNameLength = 100
+
+// companyNameForbiddenChars could break out of the prompt's quoting or its {{section}} templates
+const companyNameForbiddenChars = "<>{}`\"\\"
+
+// sanitizeCompanyName collapses whitespace, drops control and forbidden characters and caps the length
+func sanitizeCompanyName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(companyNameForbiddenChars, r) || (unicode.IsControl(r) && !unicode.IsSpace(r)) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	name = strings.Join(strings.Fields(b.String()), " ")
+	if utf8.RuneCountInString(name) > maxCompanyNameLength {
+		name = strings.TrimSpace(string([]rune(name)[:maxCompanyNameLength]))
+	}
+	return name
+}
+
+// normalizeCompanyName validates a display company name from a branding update
+func normalizeCompanyName(value string) (string, error) {
+	if strings.ContainsAny(value, companyNameForbiddenChars) {
+		return "", fmt.Errorf("must not contain quotes, backslashes, braces or angle brackets")
+	}
+	name := sanitizeCompanyName(value)
+	if utf8.RuneCountInString(strings.Join(strings.Fields(value), " ")) > maxCompanyNameLength {
+		return "", fmt.Errorf("must be at most %d characters", maxCompanyNameLength)
+	}
+	return name, nil
+}
+
+// companyNameFor is the name the bot uses for the client's company
+func companyNameFor(client *models.Client) string {
+	if name := sanitizeCompanyName(client.Branding.DisplayCompanyName); name != "" {
+		return name
+	}
+	return sanitizeCompanyName(client.Name)
+}
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"regexp"
+	"strings"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// CONTACT INTENT SCORING
+// ===================
+
+// contactKeywordWeights scores how strongly a phrase on its own signals that the user wants to be
+// contacted. Unambiguous requests score 1; generic verbs such as "connect" or "call" score low and
+// need intent signals (a person to reach, a request) to pass the threshold.
+var contactKeywordWeights = map[string]float64{
+	"contact number":     1,
+	"phone number":       1,
+	"how to contact":     1,
+	"how can i contact":  1,
+	"contact you":        1,
+	"contact your":       1,
+	"reach you":          1,
+	"get in touch":       1,
+	"support contact":    1,
+	"customer service":   0.8,
+	"customer care":      0.8,
+	"helpline":           0.8,
+	"connect with you":   1,
+	"aapka contact":      1,
+	"aapka phone":        1,
+	"aapka email":        1,
+	"kaise contact kare": 1,
+	"office ka number":   1,
+	"business ka number": 1,
+	"call me":            1,
+	"call back":          0.8,
+	"your email":         1,
+	"your phone":         1,
+	"your number":        1,
+	"your contact":       1,
+	"email me":           0.8,
+	"contact":            0.4,
+	"reach out":          0.3,
+	"speak with":         0.3,
+	"talk to":            0.3,
+	"write to":           0.3,
+	"connect":            0.3,
+	"email":              0.3,
+	"call":               0.3,
+	"support":            0.2,
+	"help":               0.1,
+}
+
+// contactKeywordPatterns match the keywords as whole words, so "recall" doesn't count as "call"
+var contactKeywordPatterns = func() map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp, len(contactKeywordWeights))
+	for keyword := range contactKeywordWeights {
+		patterns[keyword] = regexp.MustCompile(`\b` + regexp.QuoteMeta(keyword) + `\b`)
+	}
+	return patterns
+}()
+
+// contactTargetPattern is a contact verb aimed at someone the user could be put in touch with,
+// as in "talk to someone" or "call you"
+var contactTargetPattern = regexp.MustCompile(`\b(connect|talk|speak|reach|reach out|get in touch|call|email|contact|meet)( me)?( with| to)?\s+(you|aap|aapse|someone|somebody|sales|support|your (team|sales|support|office)|the team|(an? |your |the )?(agent|human|person|representative|executive|manager))\b`)
+
+// contactRequestPattern frames the message as a request
+var contactRequestPattern = regexp.MustCompile(`\b(how (can|do|to)|can i|could i|i want|i'd like|i would like|i need|please|want to|kaise)\b`)
+
+// contactObjectPattern follows a verb with a thing rather than a person, as in "connect these two ideas"
+// or "call this function", or uses "contact" as a noun, as in "import my contacts"
+var contactObjectPattern = regexp.MustCompile(`\b((connect|call|email|talk to|speak with|reach out to)\s+(these|those|this|that|it|the|a|an|two|my|our|them)|(a|the|my|new|each|every|all|import|add|export|delete|save)\s+contacts?)\b`)
+
+// contactIntentScore estimates (0-1) whether the user is asking to contact or be contacted by the business.
+// The strongest keyword sets the base score; aiming it at someone to reach and phrasing it as a request
+// raise it, while a verb aimed at a thing ("connect these ideas") lowers it.
+func contactIntentScore(message string) float64 {
+	messageLower := strings.ToLower(message)
+
+	base := 0.0
+	for keyword, weight := range contactKeywordWeights {
+		if weight > base && contactKeywordPatterns[keyword].MatchString(messageLower) {
+			base = weight
+		}
+	}
+	if base == 0 {
+		return 0
+	}
+
+	score := base
+	hasTarget := contactTargetPattern.MatchString(messageLower)
+	if hasTarget {
+		score += 0.4
+	}
+	if contactRequestPattern.MatchString(messageLower) {
+		score += 0.2
+	}
+	if base < 1 && !hasTarget && contactObjectPattern.MatchString(messageLower) {
+		score -= 0.4
+	}
+
+	if score < 0 {
+		return 0
+	}
+	if score > 1 {
+		return 1
+	}
+	return score
+}
+
+// maxContactCompletionMessageLength bounds a client's completion message
+const maxContactCompletionMessageLength = 500
+
+// contactCompletionMessageForClient returns the reply sent once contact collection has completed
+func contactCompletionMessageForClient(cfg *config.Config, client *models.Client) string {
+	if client.ContactCompletionMessage != "" {
+		return client.ContactCompletionMessage
+	}
+	return cfg.ContactCompletionMessage
+}
+
+// contactIntentThresholdForClient returns the score at which contact collection starts
+func contactIntentThresholdForClient(cfg *config.Config, client *models.Client) float64 {
+	if client.ContactIntentThreshold > 0 {
+		return client.ContactIntentThreshold
+	}
+	return cfg.ContactIntentThreshold
+}
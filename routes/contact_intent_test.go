@@ -0,0 +1,59 @@
+package routes
+
+import "testing"
+
+func TestIsContactQuery(t *testing.T) {
+	const threshold = 0.6
+
+	tests := []struct {
+		name    string
+		message string
+		want    bool
+	}{
+		// Phrases that matched the old keyword list without asking to be contacted
+		{"connect ideas", "Can you connect these two ideas for me?", false},
+		{"connect with a tool", "Does it connect with Shopify?", false},
+		{"api call", "How do I call the API from my backend?", false},
+		{"recall", "I recall you mentioned a discount", false},
+		{"email feature", "Can I send bulk email campaigns?", false},
+		{"email template", "Show me an email template for a product launch", false},
+		{"help with topic", "I need help understanding the pricing", false},
+		{"supports feature", "Does your platform support PDF uploads?", false},
+		{"talk about", "Talk to me about delivery rates", false},
+		{"speak with confidence", "How can my ads speak with more confidence?", false},
+		{"write to file", "Can the report write to a CSV file?", false},
+		{"import contacts", "How do I import my contacts?", false},
+		{"add a contact", "Can I add a contact to a list?", false},
+
+		// Genuine contact requests
+		{"phone number", "What is your phone number?", true},
+		{"how to contact", "How can I contact you?", true},
+		{"get in touch", "I'd like to get in touch", true},
+		{"connect with you", "How can I connect with you?", true},
+		{"talk to someone", "I want to talk to someone from sales", true},
+		{"speak with a human", "Can I speak with a human?", true},
+		{"call me", "Please call me tomorrow", true},
+		{"your email", "What's your email?", true},
+		{"call you", "Can I call you?", true},
+		{"contact support", "How do I contact support?", true},
+		{"customer care", "customer care number please", true},
+		{"hinglish", "aapka contact number kya hai", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isContactQuery(tt.message, threshold); got != tt.want {
+				t.Fatalf("isContactQuery(%q) = %v (score %.2f), want %v",
+					tt.message, got, contactIntentScore(tt.message), tt.want)
+			}
+		})
+	}
+}
+
+func TestContactIntentScoreRange(t *testing.T) {
+	for _, message := range []string{"", "hello", "call call call me now please you", "connect these"} {
+		if score := contactIntentScore(message); score < 0 || score > 1 {
+			t.Fatalf("contactIntentScore(%q) = %.2f, want a score between 0 and 1", message, score)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// CONTACT COLLECTION RESET
+// ===================
+
+// handleResetContactState reopens a conversation that contact collection locked by mistake: the phase goes
+// back to "none" and chat is enabled again. Names and emails already collected are kept.
+func handleResetContactState(messagesCollection *mongo.Collection, auditLogger *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientObjID, err := primitive.ObjectIDFromHex(middleware.GetClientID(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		sessionID := c.Param("session")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_session_id",
+				"message":    "Session ID required",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		previousPhase, previouslyDisabled, err := getContactCollectionState(ctx, messagesCollection, clientObjID, sessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to read contact collection state",
+			})
+			return
+		}
+
+		// Every message of the conversation, so no stale phase is picked up again
+		result, err := messagesCollection.UpdateMany(ctx, bson.M{
+			"client_id":       clientObjID,
+			"conversation_id": sessionID,
+			"is_embed_user":   true,
+		}, bson.M{
+			"$set": bson.M{
+				"contact_collection_phase": "none",
+				"chat_disabled":            false,
+			},
+			"$unset": bson.M{"contact_name": "", "contact_email": ""},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to reset contact collection state",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "conversation_not_found",
+				"message":    "Conversation not found",
+			})
+			return
+		}
+
+		if auditLogger != nil {
+			auditLogger.Log(&models.AuditEvent{
+				ClientID:   clientObjID.Hex(),
+				UserID:     middleware.GetUserID(c),
+				Action:     "UPDATE",
+				Resource:   "conversation_contact_state",
+				ResourceID: sessionID,
+				IPAddress:  c.ClientIP(),
+				UserAgent:  c.Request.UserAgent(),
+				RequestID:  middleware.GetRequestID(c),
+				Success:    true,
+				Changes: map[string]interface{}{
+					"previous_phase":         previousPhase,
+					"previous_chat_disabled": previouslyDisabled,
+					"phase":                  "none",
+					"chat_disabled":          false,
+				},
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":        true,
+			"session_id":     sessionID,
+			"previous_phase": previousPhase,
+			"phase":          "none",
+			"chat_disabled":  false,
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// CONTEXT CHUNK BUDGET
+// ===================
+//
+// Every retrieved chunk is sent to Gemini with each reply, so the budget trades answer coverage against
+// cost and latency: each extra chunk adds a few hundred input tokens (billed to the client) and makes the
+// prompt slower to process, while too few chunks leave large knowledge bases unanswered. The platform
+// default (DEFAULT_CONTEXT_CHUNKS, 16) matches the previous 8 PDF + 8 crawl chunks; admins can raise it
+// for clients with large knowledge bases or lower it for faster, cheaper replies, up to MAX_CONTEXT_CHUNKS.
+// See docs/CONTEXT_CHUNKS.md.
+
+const (
+	defaultContextChunks         = 16
+	defaultMaxContextChunksLimit = 40
+)
+
+// maxContextChunks is the platform cap on chunks per reply
+func maxContextChunks(cfg *config.Config) int {
+	if cfg.MaxContextChunks > 0 {
+		return cfg.MaxContextChunks
+	}
+	return defaultMaxContextChunksLimit
+}
+
+// contextChunksForClient returns how many knowledge chunks go into one of the client's prompts
+func contextChunksForClient(cfg *config.Config, client *models.Client) int {
+	chunks := cfg.DefaultContextChunks
+	if client.ContextChunks > 0 {
+		chunks = client.ContextChunks
+	}
+	if chunks <= 0 {
+		chunks = defaultContextChunks
+	}
+	if limit := maxContextChunks(cfg); chunks > limit {
+		chunks = limit
+	}
+	return chunks
+}
+
+// combineContextChunks fits the PDF and crawled chunks into budget. Each source gets half (PDFs the odd
+// chunk); a source that found fewer gives its unused share to the other. Chunks keep their retrieval order.
+func combineContextChunks(pdfChunks, crawledChunks []models.ContentChunk, budget int) ([]models.ContentChunk, []models.ContentChunk) {
+	pdfShare := (budget + 1) / 2
+	crawlShare := budget - pdfShare
+	if len(pdfChunks) < pdfShare {
+		crawlShare += pdfShare - len(pdfChunks)
+		pdfShare = len(pdfChunks)
+	}
+	if len(crawledChunks) < crawlShare {
+		pdfShare += crawlShare - len(crawledChunks)
+		crawlShare = len(crawledChunks)
+	}
+	if pdfShare > len(pdfChunks) {
+		pdfShare = len(pdfChunks)
+	}
+	return pdfChunks[:pdfShare], crawledChunks[:crawlShare]
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"testing"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+func TestContextChunksForClient(t *testing.T) {
+	cfg := &config.Config{DefaultContextChunks: 16, MaxContextChunks: 30}
+	tests := []struct {
+		override, want int
+	}{
+		{0, 16},
+		{6, 6},
+		{100, 30},
+	}
+	for _, tt := range tests {
+		if got := contextChunksForClient(cfg, &models.Client{ContextChunks: tt.override}); got != tt.want {
+			t.Errorf("contextChunksForClient(override %d) = %d, want %d", tt.override, got, tt.want)
+		}
+	}
+	if got := contextChunksForClient(&config.Config{}, &models.Client{}); got != defaultContextChunks {
+		t.Errorf("unconfigured platform = %d, want %d", got, defaultContextChunks)
+	}
+}
+
+func TestCombineContextChunks(t *testing.T) {
+	chunks := func(n int) []models.ContentChunk { return make([]models.ContentChunk, n) }
+	tests := []struct {
+		name                 string
+		pdf, crawl, budget   int
+		wantPDF, wantCrawled int
+	}{
+		{"both full", 16, 16, 16, 8, 8},
+		{"odd budget favours PDFs", 10, 10, 5, 3, 2},
+		{"short PDFs give way to crawl", 3, 16, 16, 3, 13},
+		{"short crawl gives way to PDFs", 16, 2, 16, 14, 2},
+		{"both short", 4, 5, 16, 4, 5},
+		{"nothing found", 0, 0, 16, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf, crawled := combineContextChunks(chunks(tt.pdf), chunks(tt.crawl), tt.budget)
+			if len(pdf) != tt.wantPDF || len(crawled) != tt.wantCrawled {
+				t.Errorf("combineContextChunks = %d PDF + %d crawl, want %d + %d", len(pdf), len(crawled), tt.wantPDF, tt.wantCrawled)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"context"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// MAXIMUM CONVERSATION LENGTH
+// ===================
+//
+// Long conversations grow the history and summarization cost of every reply. Once a conversation has
+// had its maximum number of visitor messages (turns), the last reply is followed by a wrap-up that offers
+// the client's team, the conversation's messages are marked closed, and later messages get
+// sessionLimitReply without an AI call or token charge. Spam doesn't count as a turn.
+
+// conversationWrapUpReply is appended to the reply that uses the conversation's last turn
+const conversationWrapUpReply = "We've covered a lot in this conversation, so I'll wrap up here. If you need more help, please leave your contact details or start a new conversation and our team will be happy to help."
+
+// maxConversationTurnsForClient returns how many visitor messages a conversation may have (0 = unlimited)
+func maxConversationTurnsForClient(cfg *config.Config, client *models.Client) int {
+	if client.MaxConversationTurns > 0 {
+		return client.MaxConversationTurns
+	}
+	return cfg.DefaultMaxConversationTurns
+}
+
+// conversationTurns counts the visitor messages of one conversation, leaving out spam
+func conversationTurns(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, sessionID string) (int, error) {
+	count, err := messagesCollection.CountDocuments(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": sessionID,
+		"spam":            bson.M{"$exists": false},
+	})
+	return int(count), err
+}
+
+// closeConversation marks every message of the conversation closed
+func closeConversation(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, sessionID string) error {
+	_, err := messagesCollection.UpdateMany(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": sessionID,
+	}, bson.M{"$set": bson.M{"conversation_closed": true}})
+	return err
+}
@@ -0,0 +1,275 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// CROSS-DEVICE CONVERSATION RESUME
+// ===================
+
+// resumeCodeAlphabet leaves out 0/O and 1/I/L so codes can be read off one screen and typed on another
+const resumeCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// resumeCodeLength gives 31^8 (about 850 billion) possible codes
+const resumeCodeLength = 8
+
+// resumeHistoryLimit is how many recent messages are returned so the new device can show the conversation
+const resumeHistoryLimit = 50
+
+func resumeCodeKey(clientID, code string) string {
+	return "chat:resume:" + clientID + ":" + code
+}
+
+func resumeAttemptsKey(clientID, ip string) string {
+	return "chat:resume_attempts:" + clientID + ":" + ip
+}
+
+// newResumeCode returns a random code formatted as XXXX-XXXX
+func newResumeCode() (string, error) {
+	var b strings.Builder
+	alphabetSize := big.NewInt(int64(len(resumeCodeAlphabet)))
+	for i := 0; i < resumeCodeLength; i++ {
+		if i == resumeCodeLength/2 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(resumeCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalizeResumeCode accepts codes typed in lower case, with spaces or without the dash
+func normalizeResumeCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != resumeCodeLength {
+		return ""
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(resumeCodeAlphabet, r) {
+			return ""
+		}
+	}
+	return code[:resumeCodeLength/2] + "-" + code[resumeCodeLength/2:]
+}
+
+// handleCreateResumeCode issues a short-lived code the visitor can enter on another device to continue
+// the same conversation
+func handleCreateResumeCode(cfg *config.Config, rdb *redis.Client, messagesCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientOID, err := primitive.ObjectIDFromHex(c.Param("client_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var req struct {
+			SessionID string `json:"session_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		// Only existing embed conversations of this client can be resumed
+		count, err := messagesCollection.CountDocuments(ctx, bson.M{
+			"client_id":       clientOID,
+			"conversation_id": req.SessionID,
+			"is_embed_user":   true,
+		}, options.Count().SetLimit(1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to look up conversation",
+			})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "conversation_not_found",
+				"message":    "Conversation not found",
+			})
+			return
+		}
+
+		ttl := time.Duration(cfg.ChatResumeCodeTTLMinutes) * time.Minute
+		for attempt := 0; attempt < 3; attempt++ {
+			code, err := newResumeCode()
+			if err != nil {
+				break
+			}
+			// SetNX so a colliding code never rebinds someone else's conversation
+			stored, err := rdb.SetNX(ctx, resumeCodeKey(clientOID.Hex(), code), req.SessionID, ttl).Result()
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error_code": "resume_unavailable",
+					"message":    "Conversation resume is temporarily unavailable",
+				})
+				return
+			}
+			if stored {
+				c.JSON(http.StatusOK, gin.H{
+					"code":       code,
+					"expires_at": time.Now().Add(ttl),
+					"expires_in": int(ttl.Seconds()),
+				})
+				return
+			}
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error_code": "resume_code_failed",
+			"message":    "Failed to create a resume code",
+		})
+	}
+}
+
+// handleRedeemResumeCode exchanges a resume code for the conversation's session_id and recent messages.
+// Codes are single-use and attempts are limited per IP to stop guessing.
+func handleRedeemResumeCode(cfg *config.Config, rdb *redis.Client, messagesCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientOID, err := primitive.ObjectIDFromHex(c.Param("client_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var req struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		// Every attempt counts, so codes can't be brute-forced
+		attemptsKey := resumeAttemptsKey(clientOID.Hex(), c.ClientIP())
+		window := time.Duration(cfg.ChatResumeAttemptWindow) * time.Second
+		attempts, err := rdb.Incr(ctx, attemptsKey).Result()
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error_code": "resume_unavailable",
+				"message":    "Conversation resume is temporarily unavailable",
+			})
+			return
+		}
+		if attempts == 1 {
+			rdb.Expire(ctx, attemptsKey, window)
+		}
+		if attempts > int64(cfg.ChatResumeMaxAttempts) {
+			c.Header("Retry-After", strconv.Itoa(cfg.ChatResumeAttemptWindow))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error_code":  "resume_rate_limited",
+				"message":     "Too many attempts. Please try again later.",
+				"retry_after": cfg.ChatResumeAttemptWindow,
+			})
+			return
+		}
+
+		code := normalizeResumeCode(req.Code)
+		if code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_resume_code",
+				"message":    "Resume codes look like ABCD-2345",
+			})
+			return
+		}
+
+		sessionID, err := rdb.GetDel(ctx, resumeCodeKey(clientOID.Hex(), code)).Result()
+		if err == redis.Nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "resume_code_expired",
+				"message":    "This code is invalid or has expired. Request a new one on your other device.",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error_code": "resume_unavailable",
+				"message":    "Conversation resume is temporarily unavailable",
+			})
+			return
+		}
+
+		// Most recent messages, returned oldest first for display
+		cursor, err := messagesCollection.Find(ctx, bson.M{
+			"client_id":       clientOID,
+			"conversation_id": sessionID,
+		}, options.Find().
+			SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+			SetLimit(resumeHistoryLimit).
+			SetProjection(bson.M{"message": 1, "reply": 1, "timestamp": 1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to load conversation",
+			})
+			return
+		}
+		var messages []models.Message
+		if err := cursor.All(ctx, &messages); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to load conversation",
+			})
+			return
+		}
+
+		history := make([]gin.H, 0, len(messages))
+		for i := len(messages) - 1; i >= 0; i-- {
+			history = append(history, gin.H{
+				"id":        messages[i].ID.Hex(),
+				"message":   messages[i].Message,
+				"reply":     messages[i].Reply,
+				"timestamp": messages[i].Timestamp,
+			})
+		}
+
+		// A successful resume clears the attempt counter
+		rdb.Del(ctx, attemptsKey)
+
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": sessionID,
+			"messages":   history,
+		})
+	}
+}
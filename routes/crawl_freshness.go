@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// CRAWLED CONTENT FRESHNESS
+// ===================
+
+// mostlyStaleRatio is the share of stale pages at which analytics warn that the knowledge base needs a re-crawl
+const mostlyStaleRatio = 0.5
+
+// maxCrawlMaxAgeDays caps the per-client exclusion threshold
+const maxCrawlMaxAgeDays = 3650
+
+// staleCrawlNote is appended to the source line of stale chunks
+const staleCrawlNote = " - this page may be outdated; mention that prices, dates and availability should be confirmed"
+
+// crawlFreshness holds the thresholds applied to crawled pages for one client
+type crawlFreshness struct {
+	staleAfter time.Duration // older pages are down-weighted and flagged as possibly outdated (0 = never stale)
+	maxAge     time.Duration // older pages are left out of the context entirely (0 = keep all)
+}
+
+// crawlFreshnessFor returns the freshness thresholds for a client. A client's crawl_max_age_days overrides
+// the platform CRAWL_MAX_AGE_DAYS (-1 = keep all).
+func crawlFreshnessFor(cfg *config.Config, client *models.Client) crawlFreshness {
+	maxAgeDays := cfg.CrawlMaxAgeDays
+	if client != nil && client.CrawlMaxAgeDays != 0 {
+		maxAgeDays = client.CrawlMaxAgeDays
+	}
+	freshness := crawlFreshness{}
+	if cfg.CrawlStaleAfterDays > 0 {
+		freshness.staleAfter = time.Duration(cfg.CrawlStaleAfterDays) * 24 * time.Hour
+	}
+	if maxAgeDays > 0 {
+		freshness.maxAge = time.Duration(maxAgeDays) * 24 * time.Hour
+	}
+	return freshness
+}
+
+// crawledPageTime returns when a page was fetched, falling back to its crawl job for pages stored before
+// crawled_at was recorded
+func crawledPageTime(page models.CrawledPage, job models.CrawlJob) time.Time {
+	if !page.CrawledAt.IsZero() {
+		return page.CrawledAt
+	}
+	if job.CompletedAt != nil {
+		return *job.CompletedAt
+	}
+	return job.CreatedAt
+}
+
+// isStale reports whether content fetched at crawledAt is past the stale threshold
+func (f crawlFreshness) isStale(crawledAt, now time.Time) bool {
+	return f.staleAfter > 0 && now.Sub(crawledAt) > f.staleAfter
+}
+
+// isExpired reports whether content fetched at crawledAt should be left out of the context
+func (f crawlFreshness) isExpired(crawledAt, now time.Time) bool {
+	return f.maxAge > 0 && now.Sub(crawledAt) > f.maxAge
+}
+
+// crawledSourceLine labels a crawled chunk with its URL and crawl date so the model can caveat old details
+func crawledSourceLine(url string, crawledAt time.Time, stale bool) string {
+	line := fmt.Sprintf("Source: %s (crawled %s)", url, crawledAt.UTC().Format("2006-01-02"))
+	if stale {
+		line += staleCrawlNote
+	}
+	return line
+}
+
+// knowledgeFreshness summarises how current a client's crawled pages are for the analytics response
+func knowledgeFreshness(ctx context.Context, cfg *config.Config, crawlsCollection *mongo.Collection, clientID primitive.ObjectID) (gin.H, error) {
+	cursor, err := crawlsCollection.Find(ctx, bson.M{
+		"client_id": clientID,
+		"status":    models.CrawlStatusCompleted,
+	}, options.Find().SetProjection(bson.M{
+		"created_at":               1,
+		"completed_at":             1,
+		"crawled_pages.url":        1,
+		"crawled_pages.crawled_at": 1,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var jobs []models.CrawlJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+
+	freshness := crawlFreshnessFor(cfg, nil)
+	now := time.Now()
+	totalPages, stalePages := 0, 0
+	var oldest, newest *time.Time
+	for _, job := range jobs {
+		for _, page := range job.CrawledPages {
+			crawledAt := crawledPageTime(page, job)
+			totalPages++
+			if freshness.isStale(crawledAt, now) {
+				stalePages++
+			}
+			if oldest == nil || crawledAt.Before(*oldest) {
+				oldest = &crawledAt
+			}
+			if newest == nil || crawledAt.After(*newest) {
+				newest = &crawledAt
+			}
+		}
+	}
+
+	staleRatio := 0.0
+	if totalPages > 0 {
+		staleRatio = float64(stalePages) / float64(totalPages)
+	}
+	summary := gin.H{
+		"total_pages":       totalPages,
+		"stale_pages":       stalePages,
+		"stale_ratio":       staleRatio,
+		"stale_after_days":  cfg.CrawlStaleAfterDays,
+		"oldest_crawled_at": oldest,
+		"newest_crawled_at": newest,
+		"mostly_stale":      totalPages > 0 && staleRatio >= mostlyStaleRatio,
+	}
+	if totalPages > 0 && staleRatio >= mostlyStaleRatio {
+		summary["warning"] = fmt.Sprintf("%d of %d crawled pages are older than %d days; re-crawl your website so the assistant doesn't cite outdated information",
+			stalePages, totalPages, cfg.CrawlStaleAfterDays)
+	}
+	return summary, nil
+}
@@ -0,0 +1,1026 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// CRM CONVERSATION SYNC
+// ===================
+//
+// Clients can have finished conversations pushed to their CRM. Each push is an HTTPS POST of
+//
+//	{
+//	  "event": "conversation.completed",   // or "conversation.idle", "test"
+//	  "schema_version": 1,
+//	  "delivery_id": "…",
+//	  "client_id": "…",
+//	  "conversation_id": "…",
+//	  "channel": "embed",
+//	  "started_at": "2025-01-02T10:00:00Z",
+//	  "ended_at": "2025-01-02T10:07:00Z",
+//	  "message_count": 6,
+//	  "contact": {"name": "Asha", "email": "asha@example.com", "country": "India", "city": "Pune"},
+//	  "demo_booked": false,
+//	  "transcript": [{"role": "user", "text": "…", "timestamp": "…"}, {"role": "assistant", …}],
+//	  "transcript_text": "User: …\nAssistant: …",
+//	  "sent_at": "2025-01-02T10:08:00Z"
+//	}
+//
+// signed with the headers X-Chatbot-Timestamp (unix seconds) and X-Chatbot-Signature
+// ("sha256=" + hex HMAC-SHA256 of "<timestamp>.<body>" using the client's signing secret).
+// Custom headers the client configured (e.g. Authorization) are sent too; see webhook_headers.go.
+// With a field mapping such as {"properties.email": "contact.email"} only the mapped values
+// are sent, nested under the given dotted output paths, to fit the CRM's own payload shape.
+//
+// Conversations are pushed when contact collection completes ("completion"), once they have
+// been idle for IdleMinutes ("schedule"), or both. Failed pushes are retried with backoff.
+
+const (
+	crmSyncSchemaVersion     = 1
+	crmDefaultIdleMinutes    = 30
+	crmMaxIdleMinutes        = 7 * 24 * 60
+	crmMaxFieldMappings      = 50
+	crmMaxTranscriptMessages = 500
+	crmDeliveryBatchSize     = 100
+	crmDeliveryLease         = 5 * time.Minute  // a claimed delivery is retried after this if the worker dies
+	crmCompletionDelay       = 30 * time.Second // lets the completing turn be saved before the push
+	crmIdleScanWindow        = 7 * 24 * time.Hour
+)
+
+// crmRetryBackoff is the wait before each retry; the last value repeats
+var crmRetryBackoff = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// crmSyncTriggers are the supported moments to push a conversation
+var crmSyncTriggers = map[string]bool{
+	"completion": true,
+	"schedule":   true,
+	"both":       true,
+}
+
+// crmTranscriptEntry is one line of the pushed transcript
+type crmTranscriptEntry struct {
+	Role      string    `json:"role"` // "user" or "assistant"
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// crmContact is the visitor's collected contact details
+type crmContact struct {
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+// crmConversationPayload is the standard JSON body pushed to a CRM endpoint
+type crmConversationPayload struct {
+	Event          string               `json:"event"`
+	SchemaVersion  int                  `json:"schema_version"`
+	DeliveryID     string               `json:"delivery_id"`
+	ClientID       string               `json:"client_id"`
+	ConversationID string               `json:"conversation_id"`
+	Channel        string               `json:"channel"`
+	StartedAt      time.Time            `json:"started_at"`
+	EndedAt        time.Time            `json:"ended_at"`
+	MessageCount   int                  `json:"message_count"`
+	Contact        crmContact           `json:"contact"`
+	DemoBooked     bool                 `json:"demo_booked"`
+	Transcript     []crmTranscriptEntry `json:"transcript"`
+	TranscriptText string               `json:"transcript_text"`
+	SentAt         time.Time            `json:"sent_at"`
+}
+
+// crmSyncActive reports whether the client pushes conversations on the given trigger ("completion" or "schedule")
+func crmSyncActive(settings *models.CRMSyncSettings, trigger string) bool {
+	if settings == nil || !settings.Enabled || settings.EndpointURL == "" || settings.SigningSecret == "" {
+		return false
+	}
+	return settings.Trigger == trigger || settings.Trigger == "both"
+}
+
+// crmIdleDuration returns how long a conversation must be quiet before a scheduled push
+func crmIdleDuration(settings *models.CRMSyncSettings) time.Duration {
+	if settings.IdleMinutes > 0 {
+		return time.Duration(settings.IdleMinutes) * time.Minute
+	}
+	return crmDefaultIdleMinutes * time.Minute
+}
+
+// crmRetryDelay returns the wait before the next attempt after the given number of failed attempts
+func crmRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > len(crmRetryBackoff) {
+		attempts = len(crmRetryBackoff)
+	}
+	return crmRetryBackoff[attempts-1]
+}
+
+// validateCRMEndpoint requires an HTTPS URL that doesn't point at this host or a private network
+func validateCRMEndpoint(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return errors.New("endpoint_url must be an absolute URL")
+	}
+	if parsed.Scheme != "https" {
+		return errors.New("endpoint_url must use https")
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return errors.New("endpoint_url must be publicly reachable")
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicCRMAddress(ip) {
+		return errors.New("endpoint_url must be publicly reachable")
+	}
+	return nil
+}
+
+// isPublicCRMAddress reports whether CRM pushes may connect to ip
+func isPublicCRMAddress(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// crmDialControl runs after DNS resolution, so a public hostname that resolves to a private,
+// loopback or link-local address is refused at connect time (validateCRMEndpoint only sees the name)
+func crmDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicCRMAddress(ip) {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// crmHTTPClient delivers CRM pushes. It never follows redirects or uses a proxy, so every
+// connection goes straight to an address checked by crmDialControl.
+var crmHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: crmDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        20,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	// Redirects would skip endpoint validation
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// validateCRMFieldMapping checks that every mapping has an output path and reads a field of the standard payload
+func validateCRMFieldMapping(mapping map[string]string) error {
+	if len(mapping) > crmMaxFieldMappings {
+		return fmt.Errorf("at most %d field mappings are allowed", crmMaxFieldMappings)
+	}
+	sample, err := crmPayloadMap(crmSamplePayload("", "test"))
+	if err != nil {
+		return err
+	}
+	for target, source := range mapping {
+		if strings.TrimSpace(target) == "" || strings.Contains(target, "..") || strings.HasPrefix(target, ".") || strings.HasSuffix(target, ".") {
+			return fmt.Errorf("invalid output path %q", target)
+		}
+		if _, ok := lookupCRMPath(sample, source); !ok {
+			return fmt.Errorf("unknown payload field %q for %q", source, target)
+		}
+	}
+	return nil
+}
+
+// crmSamplePayload is a representative payload for test pushes and mapping validation
+func crmSamplePayload(clientID, event string) crmConversationPayload {
+	now := time.Now().UTC().Truncate(time.Second)
+	transcript := []crmTranscriptEntry{
+		{Role: "user", Text: "Hi, can someone from your team call me about pricing?", Timestamp: now.Add(-2 * time.Minute)},
+		{Role: "assistant", Text: "Of course! May I have your name, please?", Timestamp: now.Add(-2 * time.Minute)},
+	}
+	return crmConversationPayload{
+		Event:          event,
+		SchemaVersion:  crmSyncSchemaVersion,
+		DeliveryID:     "test",
+		ClientID:       clientID,
+		ConversationID: "test-conversation",
+		Channel:        "embed",
+		StartedAt:      now.Add(-2 * time.Minute),
+		EndedAt:        now.Add(-2 * time.Minute),
+		MessageCount:   1,
+		Contact:        crmContact{Name: "Test Visitor", Email: "visitor@example.com", Country: "India", City: "Pune"},
+		Transcript:     transcript,
+		TranscriptText: crmTranscriptText(transcript),
+		SentAt:         now,
+	}
+}
+
+// buildCRMPayload loads a conversation and assembles its standard payload
+func buildCRMPayload(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, conversationID string) (crmConversationPayload, error) {
+	cursor, err := messagesCollection.Find(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": conversationID,
+	}, options.Find().SetSort(bson.M{"timestamp": 1}).SetLimit(crmMaxTranscriptMessages))
+	if err != nil {
+		return crmConversationPayload{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []models.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return crmConversationPayload{}, err
+	}
+	if len(messages) == 0 {
+		return crmConversationPayload{}, mongo.ErrNoDocuments
+	}
+
+	payload := crmConversationPayload{
+		SchemaVersion:  crmSyncSchemaVersion,
+		ClientID:       clientID.Hex(),
+		ConversationID: conversationID,
+		Channel:        messages[0].Channel,
+		StartedAt:      messages[0].Timestamp,
+		EndedAt:        messages[len(messages)-1].Timestamp,
+		MessageCount:   len(messages),
+		Transcript:     make([]crmTranscriptEntry, 0, 2*len(messages)),
+	}
+	if payload.Channel == "" {
+		payload.Channel = "embed"
+	}
+
+	for _, msg := range messages {
+		if msg.Message != "" {
+			payload.Transcript = append(payload.Transcript, crmTranscriptEntry{Role: "user", Text: msg.Message, Timestamp: msg.Timestamp})
+		}
+		if msg.Reply != "" {
+			payload.Transcript = append(payload.Transcript, crmTranscriptEntry{Role: "assistant", Text: msg.Reply, Timestamp: msg.Timestamp})
+		}
+		// The latest details win, e.g. when the visitor corrects their email
+		if msg.UserName != "" {
+			payload.Contact.Name = msg.UserName
+		}
+		if msg.UserEmail != "" {
+			payload.Contact.Email = msg.UserEmail
+		}
+		if payload.Contact.Country == "" && msg.Country != "" {
+			payload.Contact.Country = msg.Country
+			payload.Contact.City = msg.City
+		}
+		if msg.DemoBooking != nil {
+			payload.DemoBooked = true
+		}
+	}
+	payload.TranscriptText = crmTranscriptText(payload.Transcript)
+	return payload, nil
+}
+
+// crmTranscriptText renders the transcript as plain text for CRMs that only take a notes field
+func crmTranscriptText(transcript []crmTranscriptEntry) string {
+	var sb strings.Builder
+	for i, entry := range transcript {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if entry.Role == "user" {
+			sb.WriteString("User: ")
+		} else {
+			sb.WriteString("Assistant: ")
+		}
+		sb.WriteString(entry.Text)
+	}
+	return sb.String()
+}
+
+// crmPayloadMap converts the payload to its generic JSON form so field mappings can address it by path
+func crmPayloadMap(payload crmConversationPayload) (map[string]interface{}, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// lookupCRMPath reads a dotted path such as "contact.email" from the generic payload
+func lookupCRMPath(payload map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = payload
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// applyCRMFieldMapping builds the CRM-shaped body: each output path receives the value of its payload path
+func applyCRMFieldMapping(payload map[string]interface{}, mapping map[string]string) map[string]interface{} {
+	out := make(map[string]interface{})
+	for target, source := range mapping {
+		value, ok := lookupCRMPath(payload, source)
+		if !ok {
+			continue
+		}
+		keys := strings.Split(target, ".")
+		node := out
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := node[key].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[key] = child
+			}
+			node = child
+		}
+		node[keys[len(keys)-1]] = value
+	}
+	return out
+}
+
+// crmRequestBody serialises the payload, applying the client's field mapping when one is configured
+func crmRequestBody(payload crmConversationPayload, mapping map[string]string) ([]byte, error) {
+	if len(mapping) == 0 {
+		return json.Marshal(payload)
+	}
+	generic, err := crmPayloadMap(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(applyCRMFieldMapping(generic, mapping))
+}
+
+// signCRMPayload returns the X-Chatbot-Signature value for a body sent at timestamp
+func signCRMPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// pushCRMPayload signs and POSTs one payload, returning the response status code
+func pushCRMPayload(ctx context.Context, cfg *config.Config, settings *models.CRMSyncSettings, payload crmConversationPayload) (int, error) {
+	body, err := crmRequestBody(payload, settings.FieldMapping)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	timeout := time.Duration(cfg.CRMSyncTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, settings.EndpointURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	// ✅ NEW: The client's own auth headers first, so the signature headers below can't be replaced
+	if err := applyWebhookHeaders(cfg, req, settings.Headers); err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatbot-crm-sync/1")
+	req.Header.Set("X-Chatbot-Event", payload.Event)
+	req.Header.Set("X-Chatbot-Delivery", payload.DeliveryID)
+	req.Header.Set("X-Chatbot-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Chatbot-Signature", signCRMPayload(settings.SigningSecret, timestamp, body))
+
+	resp, err := crmHTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// enqueueCRMSync schedules a push of the conversation after delay. An already pending delivery is
+// replaced so the conversation is pushed once, with its latest messages.
+func enqueueCRMSync(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID, conversationID, event string, lastMessageAt time.Time, delay time.Duration) error {
+	now := time.Now()
+	_, err := db.Collection("crm_sync_deliveries").UpdateOne(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": conversationID,
+	}, bson.M{
+		"$set": bson.M{
+			"event":           event,
+			"status":          "pending",
+			"attempts":        0,
+			"next_attempt_at": now.Add(delay),
+			"last_message_at": lastMessageAt,
+			"last_error":      "",
+			"updated_at":      now,
+		},
+		"$setOnInsert": bson.M{"created_at": now},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+// enqueueCRMSyncOnCompletion queues the conversation for its client's CRM when contact collection completes
+func enqueueCRMSyncOnCompletion(db *mongo.Database, client *models.Client, conversationID string) {
+	if !crmSyncActive(client.CRMSync, "completion") {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := enqueueCRMSync(ctx, db, client.ID, conversationID, "conversation.completed", time.Now(), crmCompletionDelay); err != nil {
+		logger.Warn("Failed to queue CRM sync", "error", err, "client_id", client.ID.Hex(), "conversation_id", conversationID)
+	}
+}
+
+// CRMSyncScheduler pushes queued conversations to client CRMs, retrying failures with backoff,
+// and queues conversations that have gone idle for clients on the "schedule" trigger
+type CRMSyncScheduler struct {
+	cfg      *config.Config
+	db       *mongo.Database
+	stopChan chan struct{}
+}
+
+// NewCRMSyncScheduler creates a CRM sync scheduler
+func NewCRMSyncScheduler(cfg *config.Config, db *mongo.Database) *CRMSyncScheduler {
+	return &CRMSyncScheduler{
+		cfg:      cfg,
+		db:       db,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start queues idle conversations and sends due deliveries every CRMSyncCheckInterval seconds until Stop is called
+func (s *CRMSyncScheduler) Start() {
+	interval := time.Duration(s.cfg.CRMSyncCheckInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info("Starting CRM sync scheduler", "check_interval", interval.String())
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			s.queueIdleConversations(ctx)
+			s.deliverDue(ctx)
+			cancel()
+
+		case <-s.stopChan:
+			logger.Info("Stopping CRM sync scheduler")
+			return
+		}
+	}
+}
+
+// Stop stops the scheduler
+func (s *CRMSyncScheduler) Stop() {
+	close(s.stopChan)
+}
+
+// queueIdleConversations queues every conversation of a scheduled client that has been quiet for its idle
+// period and has messages newer than its last push
+func (s *CRMSyncScheduler) queueIdleConversations(ctx context.Context) {
+	cursor, err := s.db.Collection("clients").Find(ctx, bson.M{
+		"crm_sync.enabled": true,
+		"crm_sync.trigger": bson.M{"$in": bson.A{"schedule", "both"}},
+	})
+	if err != nil {
+		logger.Error("Failed to load clients for CRM sync", "error", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	now := time.Now()
+	for cursor.Next(ctx) {
+		var client models.Client
+		if err := cursor.Decode(&client); err != nil || !crmSyncActive(client.CRMSync, "schedule") {
+			continue
+		}
+		idleBefore := now.Add(-crmIdleDuration(client.CRMSync))
+		if err := s.queueIdleConversationsForClient(ctx, client.ID, now.Add(-crmIdleScanWindow), idleBefore); err != nil {
+			logger.Warn("Failed to queue idle conversations for CRM sync", "error", err, "client_id", client.ID.Hex())
+		}
+	}
+}
+
+func (s *CRMSyncScheduler) queueIdleConversationsForClient(ctx context.Context, clientID primitive.ObjectID, since, idleBefore time.Time) error {
+	cursor, err := s.db.Collection("messages").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"client_id": clientID,
+			"timestamp": bson.M{"$gte": since},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":             "$conversation_id",
+			"last_message_at": bson.M{"$max": "$timestamp"},
+		}}},
+		{{Key: "$match", Value: bson.M{"last_message_at": bson.M{"$lt": idleBefore}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var idle []struct {
+		ConversationID string    `bson:"_id"`
+		LastMessageAt  time.Time `bson:"last_message_at"`
+	}
+	if err := cursor.All(ctx, &idle); err != nil {
+		return err
+	}
+	if len(idle) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(idle))
+	for _, conv := range idle {
+		ids = append(ids, conv.ConversationID)
+	}
+	deliveryCursor, err := s.db.Collection("crm_sync_deliveries").Find(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": bson.M{"$in": ids},
+	}, options.Find().SetProjection(bson.M{"conversation_id": 1, "last_message_at": 1}))
+	if err != nil {
+		return err
+	}
+	var existing []models.CRMSyncDelivery
+	if err := deliveryCursor.All(ctx, &existing); err != nil {
+		return err
+	}
+	synced := make(map[string]time.Time, len(existing))
+	for _, d := range existing {
+		synced[d.ConversationID] = d.LastMessageAt
+	}
+
+	for _, conv := range idle {
+		if last, ok := synced[conv.ConversationID]; ok && !conv.LastMessageAt.After(last) {
+			continue
+		}
+		if err := enqueueCRMSync(ctx, s.db, clientID, conv.ConversationID, "conversation.idle", conv.LastMessageAt, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverDue sends pending deliveries whose next attempt is due
+func (s *CRMSyncScheduler) deliverDue(ctx context.Context) {
+	deliveries := s.db.Collection("crm_sync_deliveries")
+	now := time.Now()
+	cursor, err := deliveries.Find(ctx, bson.M{
+		"status":          "pending",
+		"next_attempt_at": bson.M{"$lte": now},
+	}, options.Find().SetSort(bson.M{"next_attempt_at": 1}).SetLimit(crmDeliveryBatchSize))
+	if err != nil {
+		logger.Error("Failed to load due CRM deliveries", "error", err)
+		return
+	}
+	var due []models.CRMSyncDelivery
+	if err := cursor.All(ctx, &due); err != nil {
+		logger.Error("Failed to decode due CRM deliveries", "error", err)
+		return
+	}
+
+	clients := make(map[primitive.ObjectID]*models.Client)
+	for _, delivery := range due {
+		// Claim the delivery so that only one instance sends it
+		claim, err := deliveries.UpdateOne(ctx, bson.M{
+			"_id":             delivery.ID,
+			"status":          "pending",
+			"next_attempt_at": delivery.NextAttemptAt,
+		}, bson.M{"$set": bson.M{"next_attempt_at": now.Add(crmDeliveryLease)}})
+		if err != nil || claim.ModifiedCount == 0 {
+			continue
+		}
+
+		client, ok := clients[delivery.ClientID]
+		if !ok {
+			var doc models.Client
+			if err := s.db.Collection("clients").FindOne(ctx, bson.M{"_id": delivery.ClientID}).Decode(&doc); err == nil {
+				client = &doc
+			}
+			clients[delivery.ClientID] = client
+		}
+		s.deliver(ctx, client, delivery)
+	}
+}
+
+// deliver pushes one conversation and records the outcome, scheduling a retry on failure
+func (s *CRMSyncScheduler) deliver(ctx context.Context, client *models.Client, delivery models.CRMSyncDelivery) {
+	deliveries := s.db.Collection("crm_sync_deliveries")
+	if client == nil || client.CRMSync == nil || !client.CRMSync.Enabled || client.CRMSync.EndpointURL == "" {
+		deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.M{"$set": bson.M{
+			"status":     "failed",
+			"last_error": "CRM sync is no longer enabled for this client",
+			"updated_at": time.Now(),
+		}})
+		return
+	}
+
+	statusCode := 0
+	payload, err := buildCRMPayload(ctx, s.db.Collection("messages"), delivery.ClientID, delivery.ConversationID)
+	if err == nil {
+		payload.Event = delivery.Event
+		payload.DeliveryID = delivery.ID.Hex()
+		payload.SentAt = time.Now().UTC()
+		statusCode, err = pushCRMPayload(ctx, s.cfg, client.CRMSync, payload)
+	}
+
+	now := time.Now()
+	attempts := delivery.Attempts + 1
+	set := bson.M{
+		"attempts":         attempts,
+		"last_status_code": statusCode,
+		"updated_at":       now,
+	}
+	if err == nil {
+		set["status"] = "delivered"
+		set["delivered_at"] = now
+		set["last_error"] = ""
+		set["last_message_at"] = payload.EndedAt
+	} else {
+		set["last_error"] = err.Error()
+		maxAttempts := s.cfg.CRMSyncMaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = 6
+		}
+		if attempts >= maxAttempts || errors.Is(err, mongo.ErrNoDocuments) {
+			set["status"] = "failed"
+		} else {
+			set["status"] = "pending"
+			set["next_attempt_at"] = now.Add(crmRetryDelay(attempts))
+		}
+		logger.Warn("CRM sync delivery failed", "error", err, "client_id", delivery.ClientID.Hex(),
+			"conversation_id", delivery.ConversationID, "attempts", attempts, "status", set["status"])
+	}
+
+	// A conversation re-queued while we were sending keeps its new pending state
+	deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID, "updated_at": delivery.UpdatedAt}, bson.M{"$set": set})
+}
+
+// crmSyncResponse is the GET/PUT view of a client's CRM sync settings
+func crmSyncResponse(settings *models.CRMSyncSettings) gin.H {
+	if settings == nil {
+		settings = &models.CRMSyncSettings{}
+	}
+	mapping := settings.FieldMapping
+	if mapping == nil {
+		mapping = map[string]string{}
+	}
+	return gin.H{
+		"enabled":        settings.Enabled,
+		"endpoint_url":   settings.EndpointURL,
+		"trigger":        settings.Trigger,
+		"idle_minutes":   settings.IdleMinutes,
+		"field_mapping":  mapping,
+		"has_secret":     settings.SigningSecret != "",
+		"headers":        webhookHeaderNames(settings.Headers),
+		"schema_version": crmSyncSchemaVersion,
+	}
+}
+
+// loadCRMSyncClient resolves the authenticated client, writing an error response on failure
+func loadCRMSyncClient(ctx context.Context, c *gin.Context, clientsCollection *mongo.Collection) (*models.Client, bool) {
+	userClientID := middleware.GetClientID(c)
+	if userClientID == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error_code": "forbidden",
+			"message":    "Client ID required",
+		})
+		return nil, false
+	}
+
+	clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error_code": "invalid_client_id",
+			"message":    "Invalid client ID format",
+		})
+		return nil, false
+	}
+
+	var client models.Client
+	if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error_code": "database_error",
+			"message":    "Failed to fetch CRM sync settings",
+		})
+		return nil, false
+	}
+	return &client, true
+}
+
+// handleGetCRMSync returns the authenticated client's CRM sync settings and delivery counts
+func handleGetCRMSync(db *mongo.Database, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		client, ok := loadCRMSyncClient(ctx, c, clientsCollection)
+		if !ok {
+			return
+		}
+
+		counts := gin.H{"pending": 0, "delivered": 0, "failed": 0}
+		cursor, err := db.Collection("crm_sync_deliveries").Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"client_id": client.ID}}},
+			{{Key: "$group", Value: bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}}},
+		})
+		if err == nil {
+			var groups []struct {
+				Status string `bson:"_id"`
+				Count  int    `bson:"count"`
+			}
+			if cursor.All(ctx, &groups) == nil {
+				for _, g := range groups {
+					counts[g.Status] = g.Count
+				}
+			}
+		}
+
+		response := crmSyncResponse(client.CRMSync)
+		response["deliveries"] = counts
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// handleUpdateCRMSync sets the authenticated client's CRM sync settings. A signing secret is generated
+// the first time sync is configured, or when rotate_secret is set, and returned only in that response.
+// headers replaces the custom request headers as a whole; an empty object removes them.
+func handleUpdateCRMSync(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Enabled      *bool              `json:"enabled"`
+			EndpointURL  *string            `json:"endpoint_url"`
+			Trigger      string             `json:"trigger"`
+			IdleMinutes  *int               `json:"idle_minutes"`
+			FieldMapping *map[string]string `json:"field_mapping"`
+			Headers      *map[string]string `json:"headers"`
+			RotateSecret bool               `json:"rotate_secret"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		client, ok := loadCRMSyncClient(ctx, c, clientsCollection)
+		if !ok {
+			return
+		}
+
+		settings := models.CRMSyncSettings{Trigger: "completion", IdleMinutes: crmDefaultIdleMinutes}
+		if client.CRMSync != nil {
+			settings = *client.CRMSync
+		}
+
+		if request.EndpointURL != nil {
+			endpoint := strings.TrimSpace(*request.EndpointURL)
+			if endpoint != "" {
+				if err := validateCRMEndpoint(endpoint); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"error_code": "invalid_endpoint_url",
+						"message":    err.Error(),
+					})
+					return
+				}
+			}
+			settings.EndpointURL = endpoint
+		}
+
+		if request.Trigger != "" {
+			trigger := strings.ToLower(strings.TrimSpace(request.Trigger))
+			if !crmSyncTriggers[trigger] {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_trigger",
+					"message":    "trigger must be completion, schedule or both",
+				})
+				return
+			}
+			settings.Trigger = trigger
+		}
+
+		if request.IdleMinutes != nil {
+			if *request.IdleMinutes < 1 || *request.IdleMinutes > crmMaxIdleMinutes {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_idle_minutes",
+					"message":    fmt.Sprintf("idle_minutes must be between 1 and %d", crmMaxIdleMinutes),
+				})
+				return
+			}
+			settings.IdleMinutes = *request.IdleMinutes
+		}
+
+		if request.FieldMapping != nil {
+			if err := validateCRMFieldMapping(*request.FieldMapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_field_mapping",
+					"message":    err.Error(),
+				})
+				return
+			}
+			settings.FieldMapping = *request.FieldMapping
+		}
+
+		if request.Headers != nil {
+			if err := validateWebhookHeaders(*request.Headers); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_headers",
+					"message":    err.Error(),
+				})
+				return
+			}
+			headers, err := sealWebhookHeaders(cfg, *request.Headers)
+			if err != nil {
+				logger.Error("Failed to encrypt CRM sync headers", "error", err, "client_id", client.ID.Hex())
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "header_encryption_failed",
+					"message":    "Failed to store custom headers",
+				})
+				return
+			}
+			settings.Headers = headers
+		}
+
+		if request.Enabled != nil {
+			settings.Enabled = *request.Enabled
+		}
+		if settings.Enabled && settings.EndpointURL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "endpoint_required",
+				"message":    "endpoint_url is required to enable CRM sync",
+			})
+			return
+		}
+
+		newSecret := ""
+		if settings.SigningSecret == "" || request.RotateSecret {
+			secret, err := utils.GenerateSecureRandomString(32)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "secret_generation_failed",
+					"message":    "Failed to generate signing secret",
+				})
+				return
+			}
+			settings.SigningSecret = secret
+			newSecret = secret
+		}
+
+		_, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": client.ID}, bson.M{
+			"$set": bson.M{
+				"crm_sync":   settings,
+				"updated_at": time.Now(),
+			},
+		})
+		invalidateClientConfig(client.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update CRM sync settings",
+			})
+			return
+		}
+
+		response := crmSyncResponse(&settings)
+		if newSecret != "" {
+			// Shown once; the client verifies X-Chatbot-Signature with it
+			response["signing_secret"] = newSecret
+		}
+		response["message"] = "CRM sync settings updated successfully"
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// handleTestCRMSync pushes a sample conversation to the configured endpoint and reports the result
+func handleTestCRMSync(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+
+		client, ok := loadCRMSyncClient(ctx, c, clientsCollection)
+		if !ok {
+			return
+		}
+		if client.CRMSync == nil || client.CRMSync.EndpointURL == "" || client.CRMSync.SigningSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "crm_sync_not_configured",
+				"message":    "Configure an endpoint_url before sending a test",
+			})
+			return
+		}
+
+		statusCode, err := pushCRMPayload(ctx, cfg, client.CRMSync, crmSamplePayload(client.ID.Hex(), "test"))
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error_code":  "crm_test_failed",
+				"message":     "The CRM endpoint did not accept the test payload",
+				"details":     err.Error(),
+				"status_code": statusCode,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Test payload delivered",
+			"status_code": statusCode,
+		})
+	}
+}
+
+// handleListCRMSyncDeliveries lists the authenticated client's recent CRM deliveries, optionally by status
+func handleListCRMSyncDeliveries(db *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientObjID, err := primitive.ObjectIDFromHex(middleware.GetClientID(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		filter := bson.M{"client_id": clientObjID}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := db.Collection("crm_sync_deliveries").Find(ctx, filter,
+			options.Find().SetSort(bson.M{"updated_at": -1}).SetLimit(100))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch CRM deliveries",
+			})
+			return
+		}
+		deliveries := []models.CRMSyncDelivery{}
+		if err := cursor.All(ctx, &deliveries); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to decode CRM deliveries",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"deliveries": deliveries,
+			"count":      len(deliveries),
+		})
+	}
+}
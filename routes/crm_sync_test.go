@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateCRMEndpoint(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://crm.example.com/hooks/chat", false},
+		{"http://crm.example.com/hooks/chat", true},
+		{"https://localhost/hook", true},
+		{"https://10.0.0.5/hook", true},
+		{"https://169.254.169.254/latest/meta-data", true},
+		{"https://[::1]/hook", true},
+	}
+	for _, tt := range tests {
+		if err := validateCRMEndpoint(tt.url); (err != nil) != tt.wantErr {
+			t.Errorf("validateCRMEndpoint(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCRMDialControl(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:443", "10.1.2.3:443", "169.254.169.254:80", "[fe80::1]:443", "0.0.0.0:443"} {
+		if err := crmDialControl("tcp", address, nil); err == nil {
+			t.Errorf("crmDialControl(%s) = nil, want refused", address)
+		}
+	}
+	if err := crmDialControl("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("crmDialControl(public) = %v, want nil", err)
+	}
+}
+
+func TestCRMHTTPClientRefusesResolvedLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// A hostname resolving to loopback must be refused after resolution, not just literal IPs
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	_, err := crmHTTPClient.Get(url)
+	if err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("Get(%s) error = %v, want a non-public address refusal", url, err)
+	}
+}
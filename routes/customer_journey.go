@@ -0,0 +1,221 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// CUSTOMER JOURNEY (CONVERSATIONS MERGED ACROSS SESSIONS)
+// ===================
+
+// maxJourneyMessages bounds one timeline; longer journeys return the most recent messages
+const maxJourneyMessages = 2000
+
+// journeySession summarizes one of the sessions making up a journey
+type journeySession struct {
+	SessionID    string    `json:"session_id"`
+	StartedAt    time.Time `json:"started_at"`
+	EndedAt      time.Time `json:"ended_at"`
+	MessageCount int       `json:"message_count"`
+}
+
+// journeySeed works out how to find a visitor's other sessions from one of theirs: by the email they gave
+// in it, or failing that the IP it was held from
+func journeySeed(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, sessionID string) (email, ip string, err error) {
+	var msg models.Message
+	err = messagesCollection.FindOne(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": sessionID,
+		"user_email":      bson.M{"$nin": []interface{}{nil, ""}},
+	}, options.FindOne().SetSort(bson.M{"timestamp": -1})).Decode(&msg)
+	if err == nil {
+		return msg.UserEmail, "", nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return "", "", err
+	}
+
+	err = messagesCollection.FindOne(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": sessionID,
+		"user_ip":         bson.M{"$nin": []interface{}{nil, ""}},
+	}, options.FindOne().SetSort(bson.M{"timestamp": -1})).Decode(&msg)
+	if err == nil {
+		return "", msg.UserIP, nil
+	}
+	if err == mongo.ErrNoDocuments {
+		return "", "", nil
+	}
+	return "", "", err
+}
+
+// handleCustomerJourney returns every conversation of one visitor as a single timeline, so a returning
+// customer reads as one journey instead of separate sessions. Visitors are matched by the email they gave
+// in chat (verified) or by IP address (probable, as visitors can share an IP); pass session_id to start
+// from one of their conversations. Sessions are only grouped for display - stored conversations are not
+// changed, and each timeline entry keeps its session_id.
+func handleCustomerJourney(messagesCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientObjID, err := primitive.ObjectIDFromHex(middleware.GetClientID(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		email := strings.TrimSpace(c.Query("email"))
+		ip := strings.TrimSpace(c.Query("ip"))
+		sessionID := strings.TrimSpace(c.Query("session_id"))
+
+		identifiers := 0
+		for _, v := range []string{email, ip, sessionID} {
+			if v != "" {
+				identifiers++
+			}
+		}
+		if identifiers != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_identifier",
+				"message":    "Provide exactly one of email, ip or session_id",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+
+		if sessionID != "" {
+			email, ip, err = journeySeed(ctx, messagesCollection, clientObjID, sessionID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "database_error",
+					"message":    "Failed to look up conversation",
+				})
+				return
+			}
+		}
+
+		matchedBy := "session"
+		conversationFilter := bson.M{
+			"client_id":       clientObjID,
+			"conversation_id": bson.M{"$exists": true, "$ne": ""},
+			"is_embed_user":   true,
+		}
+		switch {
+		case email != "":
+			matchedBy = "email"
+			conversationFilter["user_email"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(email) + "$", Options: "i"}
+		case ip != "":
+			matchedBy = "ip"
+			conversationFilter["user_ip"] = ip
+		default:
+			conversationFilter["conversation_id"] = sessionID
+		}
+
+		values, err := messagesCollection.Distinct(ctx, "conversation_id", conversationFilter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to look up conversations",
+			})
+			return
+		}
+		sessionIDs := distinctStrings(values)
+		if sessionID != "" && len(sessionIDs) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "conversation_not_found",
+				"message":    "Conversation not found",
+			})
+			return
+		}
+
+		// Newest first so a truncated journey keeps the latest messages, then put back in order
+		var messages []models.Message
+		if len(sessionIDs) > 0 {
+			cursor, err := messagesCollection.Find(ctx, bson.M{
+				"client_id":       clientObjID,
+				"conversation_id": bson.M{"$in": sessionIDs},
+			}, options.Find().
+				SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+				SetLimit(maxJourneyMessages+1))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "database_error",
+					"message":    "Failed to load conversations",
+				})
+				return
+			}
+			if err := cursor.All(ctx, &messages); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "database_error",
+					"message":    "Failed to load conversations",
+				})
+				return
+			}
+		}
+		truncated := len(messages) > maxJourneyMessages
+		if truncated {
+			messages = messages[:maxJourneyMessages]
+		}
+
+		sessions := []*journeySession{}
+		bySession := map[string]*journeySession{}
+		timeline := make([]gin.H, 0, len(messages))
+		var userName, userEmail string
+		for i := len(messages) - 1; i >= 0; i-- {
+			m := messages[i]
+			s, ok := bySession[m.ConversationID]
+			if !ok {
+				s = &journeySession{SessionID: m.ConversationID, StartedAt: m.Timestamp}
+				bySession[m.ConversationID] = s
+				sessions = append(sessions, s)
+			}
+			s.EndedAt = m.Timestamp
+			s.MessageCount++
+
+			if m.UserName != "" {
+				userName = m.UserName
+			}
+			if m.UserEmail != "" {
+				userEmail = m.UserEmail
+			}
+
+			timeline = append(timeline, gin.H{
+				"id":         m.ID.Hex(),
+				"session_id": m.ConversationID,
+				"message":    m.Message,
+				"reply":      m.Reply,
+				"timestamp":  m.Timestamp,
+				"channel":    m.Channel,
+			})
+		}
+		sort.SliceStable(sessions, func(i, j int) bool {
+			return sessions[i].StartedAt.Before(sessions[j].StartedAt)
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"matched_by": matchedBy,
+			"user_name":  userName,
+			"user_email": userEmail,
+			"sessions":   sessions,
+			"timeline":   timeline,
+			"truncated":  truncated,
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// END-USER DATA DELETION (RIGHT TO BE FORGOTTEN)
+// ===================
+
+// subjectDeletionCounts records how many documents were removed per kind of data
+type subjectDeletionCounts struct {
+	Messages        int64 `json:"messages"`
+	Feedback        int64 `json:"feedback"`
+	UserRecords     int64 `json:"user_records"`
+	Summaries       int64 `json:"conversation_summaries"`
+	ConversationTag int64 `json:"conversation_tags"`
+	PromptSnapshots int64 `json:"prompt_snapshots"`
+}
+
+// deleteSubjectData removes everything in scope. Deleting the conversations also removes the contact
+// details collected in them (contact_collection_phase "completed"), and deleting the name-by-IP records
+// stops a later conversation from the same IP re-attaching the name, so the lead can't resurface in
+// exports or lead lists.
+func deleteSubjectData(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID, scope *subjectScope) (subjectDeletionCounts, error) {
+	var counts subjectDeletionCounts
+
+	if len(scope.sessionIDs) > 0 {
+		res, err := db.Collection("messages").DeleteMany(ctx, bson.M{
+			"client_id":       clientID,
+			"conversation_id": bson.M{"$in": scope.sessionIDs},
+		})
+		if err != nil {
+			return counts, fmt.Errorf("messages: %w", err)
+		}
+		counts.Messages = res.DeletedCount
+
+		res, err = db.Collection("message_feedback").DeleteMany(ctx, scope.feedbackFilter(clientID))
+		if err != nil {
+			return counts, fmt.Errorf("feedback: %w", err)
+		}
+		counts.Feedback = res.DeletedCount
+
+		res, err = db.Collection("conversation_summaries").DeleteMany(ctx, bson.M{
+			"client_id":       clientID,
+			"conversation_id": bson.M{"$in": scope.sessionIDs},
+		})
+		if err != nil {
+			return counts, fmt.Errorf("conversation summaries: %w", err)
+		}
+		counts.Summaries = res.DeletedCount
+
+		res, err = db.Collection("conversation_tags").DeleteMany(ctx, bson.M{
+			"client_id":  clientID,
+			"session_id": bson.M{"$in": scope.sessionIDs},
+		})
+		if err != nil {
+			return counts, fmt.Errorf("conversation tags: %w", err)
+		}
+		counts.ConversationTag = res.DeletedCount
+
+		res, err = db.Collection("prompt_snapshots").DeleteMany(ctx, bson.M{
+			"client_id":       clientID,
+			"conversation_id": bson.M{"$in": scope.sessionIDs},
+		})
+		if err != nil {
+			return counts, fmt.Errorf("prompt snapshots: %w", err)
+		}
+		counts.PromptSnapshots = res.DeletedCount
+	}
+
+	if filter := scope.userRecordFilter(clientID); filter != nil {
+		res, err := db.Collection("messages").DeleteMany(ctx, filter)
+		if err != nil {
+			return counts, fmt.Errorf("user records: %w", err)
+		}
+		counts.UserRecords = res.DeletedCount
+	}
+
+	return counts, nil
+}
+
+// isTransactionUnsupported reports whether the server can't run transactions (a standalone mongod)
+func isTransactionUnsupported(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 20 { // IllegalOperation
+		return true
+	}
+	return strings.Contains(err.Error(), "Transaction numbers are only allowed")
+}
+
+// deleteSubjectDataAtomically deletes in one transaction when the deployment supports it (replica set or
+// sharded cluster) and falls back to sequential deletes on a standalone server
+func deleteSubjectDataAtomically(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID, scope *subjectScope) (subjectDeletionCounts, bool, error) {
+	session, err := db.Client().StartSession()
+	if err == nil {
+		defer session.EndSession(ctx)
+		var counts subjectDeletionCounts
+		_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+			var txErr error
+			counts, txErr = deleteSubjectData(sc, db, clientID, scope)
+			return nil, txErr
+		})
+		if err == nil {
+			return counts, true, nil
+		}
+		if !isTransactionUnsupported(err) {
+			// The transaction was rolled back, so nothing was deleted
+			return subjectDeletionCounts{}, true, err
+		}
+	}
+
+	counts, err := deleteSubjectData(ctx, db, clientID, scope)
+	return counts, false, err
+}
+
+// hashSubjectIdentifier fingerprints an identifier for the deletion tombstone so the audit log can prove a
+// deletion happened for a later request with the same identifier without storing the identifier itself
+func hashSubjectIdentifier(clientID primitive.ObjectID, req *subjectRequest) (string, string) {
+	kind, value := "ip", req.IP
+	switch {
+	case req.Email != "":
+		kind, value = "email", strings.ToLower(req.Email)
+	case req.SessionID != "":
+		kind, value = "session_id", req.SessionID
+	}
+	sum := sha256.Sum256([]byte(clientID.Hex() + "|" + kind + "|" + value))
+	return kind, hex.EncodeToString(sum[:])
+}
+
+// handleSubjectDataDeletion permanently deletes one end user's conversations, feedback, conversation
+// summaries and tags, and remembered name/email records, and leaves a tombstone without PII in the
+// audit log
+func handleSubjectDataDeletion(db *mongo.Database, auditLogger *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientObjID, err := primitive.ObjectIDFromHex(middleware.GetClientID(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		req, ok := bindSubjectRequest(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+		defer cancel()
+
+		scope, err := resolveSubjectScope(ctx, db.Collection("messages"), clientObjID, req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to look up conversations",
+			})
+			return
+		}
+
+		counts, transactional, err := deleteSubjectDataAtomically(ctx, db, clientObjID, scope)
+
+		identifierType, identifierHash := hashSubjectIdentifier(clientObjID, req)
+		if auditLogger != nil {
+			event := &models.AuditEvent{
+				ClientID:  clientObjID.Hex(),
+				UserID:    middleware.GetUserID(c),
+				Action:    "DELETE",
+				Resource:  "end_user_data",
+				IPAddress: c.ClientIP(),
+				UserAgent: c.Request.UserAgent(),
+				RequestID: middleware.GetRequestID(c),
+				Success:   err == nil,
+				Changes: map[string]interface{}{
+					"reason":          "right_to_be_forgotten",
+					"identifier_type": identifierType,
+					"identifier_hash": identifierHash,
+					"conversations":   len(scope.sessionIDs),
+					"deleted":         counts,
+					"transactional":   transactional,
+				},
+			}
+			if err != nil {
+				event.ErrorMessage = err.Error()
+			}
+			auditLogger.Log(event)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code":    "deletion_failed",
+				"message":       "Failed to delete all data for this user",
+				"deleted":       counts,
+				"transactional": transactional,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":         true,
+			"conversations":   len(scope.sessionIDs),
+			"deleted":         counts,
+			"transactional":   transactional,
+			"identifier_hash": identifierHash,
+		})
+	}
+}
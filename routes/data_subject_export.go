@@ -0,0 +1,298 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// END-USER DATA EXPORT (SUBJECT ACCESS REQUESTS)
+// ===================
+
+// maxSubjectExportMessages bounds one export; larger histories are flagged as truncated
+const maxSubjectExportMessages = 10000
+
+// subjectRequest identifies the end user whose data is exported or deleted. The client confirms it has
+// verified the requester owns the identifier.
+type subjectRequest struct {
+	Email            string `json:"email"`
+	SessionID        string `json:"session_id"`
+	IP               string `json:"ip"`
+	IdentityVerified bool   `json:"identity_verified"`
+}
+
+// bindSubjectRequest reads and validates a subject request, answering the request itself when it is invalid
+func bindSubjectRequest(c *gin.Context) (*subjectRequest, bool) {
+	var req subjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error_code": "invalid_request",
+			"message":    "Invalid request body",
+			"details":    err.Error(),
+		})
+		return nil, false
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	req.SessionID = strings.TrimSpace(req.SessionID)
+	req.IP = strings.TrimSpace(req.IP)
+
+	identifiers := 0
+	for _, v := range []string{req.Email, req.SessionID, req.IP} {
+		if v != "" {
+			identifiers++
+		}
+	}
+	if identifiers != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error_code": "invalid_identifier",
+			"message":    "Provide exactly one of email, session_id or ip",
+		})
+		return nil, false
+	}
+	if !req.IdentityVerified {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error_code": "identity_not_verified",
+			"message":    "Confirm you have verified the requester owns this identifier by setting identity_verified to true",
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+// identifier returns the identifier the request was made with, for responses
+func (r *subjectRequest) identifier() gin.H {
+	switch {
+	case r.Email != "":
+		return gin.H{"email": r.Email}
+	case r.SessionID != "":
+		return gin.H{"session_id": r.SessionID}
+	}
+	return gin.H{"ip": r.IP}
+}
+
+// subjectScope is what belongs to one end user within a client: their conversations and the
+// name-by-IP records remembered for them
+type subjectScope struct {
+	sessionIDs  []string
+	userIPs     []string        // IPs whose name-by-IP records are the user's
+	emailFilter primitive.Regex // set for email requests; matches name-by-IP records by email
+}
+
+// resolveSubjectScope finds the user's conversations. By email, these are the conversations where the email
+// was given; by IP, the conversations held from that IP; by session, that one conversation and the
+// IPs it was held from.
+func resolveSubjectScope(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, req *subjectRequest) (*subjectScope, error) {
+	scope := &subjectScope{}
+	conversationFilter := bson.M{
+		"client_id":       clientID,
+		"conversation_id": bson.M{"$exists": true, "$ne": ""},
+	}
+	switch {
+	case req.SessionID != "":
+		scope.sessionIDs = []string{req.SessionID}
+		ips, err := messagesCollection.Distinct(ctx, "user_ip", bson.M{"client_id": clientID, "conversation_id": req.SessionID})
+		if err != nil {
+			return nil, err
+		}
+		scope.userIPs = distinctStrings(ips)
+		return scope, nil
+	case req.Email != "":
+		scope.emailFilter = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(req.Email) + "$", Options: "i"}
+		conversationFilter["user_email"] = scope.emailFilter
+	default:
+		scope.userIPs = []string{req.IP}
+		conversationFilter["user_ip"] = req.IP
+	}
+
+	sessions, err := messagesCollection.Distinct(ctx, "conversation_id", conversationFilter)
+	if err != nil {
+		return nil, err
+	}
+	scope.sessionIDs = distinctStrings(sessions)
+	return scope, nil
+}
+
+// userRecordFilter matches the user's name-by-IP records (nil when there are none to match)
+func (s *subjectScope) userRecordFilter(clientID primitive.ObjectID) bson.M {
+	var match bson.A
+	if len(s.userIPs) > 0 {
+		match = append(match, bson.M{"user_ip": bson.M{"$in": s.userIPs}})
+	}
+	if s.emailFilter.Pattern != "" {
+		match = append(match, bson.M{"user_email": s.emailFilter})
+	}
+	if len(match) == 0 {
+		return nil
+	}
+	// Name-by-IP records live in the messages collection; first_seen tells them apart from messages
+	return bson.M{"client_id": clientID, "first_seen": bson.M{"$exists": true}, "$or": match}
+}
+
+// feedbackFilter matches feedback given in the user's conversations
+func (s *subjectScope) feedbackFilter(clientID primitive.ObjectID) bson.M {
+	return bson.M{
+		"client_id": clientID,
+		"$or": bson.A{
+			bson.M{"conversation_id": bson.M{"$in": s.sessionIDs}},
+			bson.M{"session_id": bson.M{"$in": s.sessionIDs}},
+		},
+	}
+}
+
+// distinctStrings keeps the non-empty strings of a Distinct result
+func distinctStrings(values []interface{}) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// handleSubjectDataExport exports everything stored about one end user of the client's widget - their
+// conversations, feedback and remembered name/email records - as a downloadable JSON file.
+// Users are found by the email they gave in chat, a widget session ID or their IP address.
+func handleSubjectDataExport(db *mongo.Database, auditLogger *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientObjID, err := primitive.ObjectIDFromHex(middleware.GetClientID(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		req, ok := bindSubjectRequest(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+		defer cancel()
+
+		messagesCollection := db.Collection("messages")
+		feedbackCollection := db.Collection("message_feedback")
+
+		scope, err := resolveSubjectScope(ctx, messagesCollection, clientObjID, req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to look up conversations",
+			})
+			return
+		}
+		sessionIDs := scope.sessionIDs
+
+		messages := []models.Message{}
+		truncated := false
+		if len(sessionIDs) > 0 {
+			cursor, err := messagesCollection.Find(ctx, bson.M{
+				"client_id":       clientObjID,
+				"conversation_id": bson.M{"$in": sessionIDs},
+			}, options.Find().
+				SetSort(bson.D{{Key: "conversation_id", Value: 1}, {Key: "timestamp", Value: 1}}).
+				SetLimit(maxSubjectExportMessages+1))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "database_error",
+					"message":    "Failed to export messages",
+				})
+				return
+			}
+			if err := cursor.All(ctx, &messages); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "database_error",
+					"message":    "Failed to export messages",
+				})
+				return
+			}
+			if len(messages) > maxSubjectExportMessages {
+				messages = messages[:maxSubjectExportMessages]
+				truncated = true
+			}
+		}
+
+		userRecords := []models.UserNameByIP{}
+		if userRecordFilter := scope.userRecordFilter(clientObjID); userRecordFilter != nil {
+			cursor, err := messagesCollection.Find(ctx, userRecordFilter)
+			if err == nil {
+				if err := cursor.All(ctx, &userRecords); err != nil {
+					logger.Warn("Failed to decode user records for export", "error", err, "client_id", clientObjID.Hex())
+				}
+			}
+		}
+
+		feedback := []models.MessageFeedback{}
+		if len(sessionIDs) > 0 {
+			cursor, err := feedbackCollection.Find(ctx, scope.feedbackFilter(clientObjID),
+				options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+			if err == nil {
+				if err := cursor.All(ctx, &feedback); err != nil {
+					logger.Warn("Failed to decode feedback for export", "error", err, "client_id", clientObjID.Hex())
+				}
+			}
+		}
+
+		// Group messages per conversation
+		conversations := []gin.H{}
+		index := map[string]int{}
+		for _, m := range messages {
+			i, ok := index[m.ConversationID]
+			if !ok {
+				i = len(conversations)
+				index[m.ConversationID] = i
+				conversations = append(conversations, gin.H{"session_id": m.ConversationID, "messages": []models.Message{}})
+			}
+			conversations[i]["messages"] = append(conversations[i]["messages"].([]models.Message), m)
+		}
+
+		identifier := req.identifier()
+
+		if auditLogger != nil {
+			auditLogger.Log(&models.AuditEvent{
+				ClientID:  clientObjID.Hex(),
+				UserID:    middleware.GetUserID(c),
+				Action:    "EXPORT",
+				Resource:  "end_user_data",
+				IPAddress: c.ClientIP(),
+				UserAgent: c.Request.UserAgent(),
+				RequestID: middleware.GetRequestID(c),
+				Success:   true,
+				Changes: map[string]interface{}{
+					"identifier":    identifier,
+					"conversations": len(conversations),
+					"messages":      len(messages),
+					"feedback":      len(feedback),
+					"user_records":  len(userRecords),
+				},
+			})
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=data_export_%s.json", time.Now().Format("20060102_150405")))
+		c.JSON(http.StatusOK, gin.H{
+			"exported_at":   time.Now(),
+			"client_id":     clientObjID.Hex(),
+			"identifier":    identifier,
+			"conversations": conversations,
+			"feedback":      feedback,
+			"user_records":  userRecords,
+			"truncated":     truncated,
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package routes
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// DEMO TIME PARSING
+// ===================
+//
+// extractDemoTime keeps the words around a time mention for the prompt. parseDemoDateTime turns the
+// mention into a datetime in the client's timezone (the analytics timezone, UTC if unset), resolving
+// relative days ("today", "tonight", "tomorrow", "friday") against when the message was sent. A clock
+// time without am/pm before 8 is taken as afternoon, since demos happen in business hours; a day without
+// a time falls back to the part of day mentioned ("morning", "evening"), or 10 am. The result is stored
+// on the conversation state as demo_at and passed to the Calendly booking.
+
+// demoClockPattern matches clock times such as "7 pm", "7:30pm", "10 a.m." and "19:00"
+var demoClockPattern = regexp.MustCompile(`\b(\d{1,2})(?::(\d{2}))?\s*(a\.?m\.?|p\.?m\.?)?(?:\s|$|[.,!?])`)
+
+// demoAtHourPattern matches bare hours introduced by "at", e.g. "at 7" or "at seven o'clock"
+var demoAtHourPattern = regexp.MustCompile(`\bat (\d{1,2}|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve)\b`)
+
+// demoHourWords spells out the hours people write as words
+var demoHourWords = map[string]int{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+// demoWordClockPattern matches spelled-out times such as "seven pm" or "seven o'clock"
+var demoWordClockPattern = regexp.MustCompile(`\b(one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve)\s*(pm|am|o ?'?clock)\b`)
+
+// demoDayParts are the default hours for vague times of day
+var demoDayParts = []struct {
+	word string
+	hour int
+}{
+	{"tonight", 20}, {"afternoon", 15}, {"morning", 10}, {"evening", 18}, {"noon", 12}, // "afternoon" before "noon"
+}
+
+const defaultDemoHour = 10
+
+// parseDemoClock finds the time of day in text; ok is false when there is none
+func parseDemoClock(text string) (hour, minute int, ok bool) {
+	for _, match := range demoClockPattern.FindAllStringSubmatch(text, -1) {
+		h, _ := strconv.Atoi(match[1])
+		m := 0
+		if match[2] != "" {
+			m, _ = strconv.Atoi(match[2])
+		}
+		suffix := strings.ReplaceAll(match[3], ".", "")
+		switch {
+		case suffix == "" && match[2] == "":
+			continue // a bare number ("5 people") is not a time
+		case m > 59 || h > 23 || (suffix != "" && (h == 0 || h > 12)):
+			continue
+		case suffix == "pm" && h < 12:
+			h += 12
+		case suffix == "am" && h == 12:
+			h = 0
+		case suffix == "" && h < 8:
+			h += 12
+		}
+		return h, m, true
+	}
+
+	if match := demoWordClockPattern.FindStringSubmatch(text); match != nil {
+		h := demoHourWords[match[1]]
+		switch suffix := match[2]; {
+		case suffix == "pm" && h < 12:
+			h += 12
+		case suffix == "am" && h == 12:
+			h = 0
+		case suffix != "am" && suffix != "pm" && h < 8:
+			h += 12
+		}
+		return h, 0, true
+	}
+
+	if match := demoAtHourPattern.FindStringSubmatch(text); match != nil {
+		h, err := strconv.Atoi(match[1])
+		if err != nil {
+			h = demoHourWords[match[1]]
+		}
+		if h >= 1 && h <= 23 {
+			if h < 8 {
+				h += 12
+			}
+			return h, 0, true
+		}
+	}
+
+	for _, part := range demoDayParts {
+		if strings.Contains(text, part.word) {
+			return part.hour, 0, true
+		}
+	}
+	return 0, 0, false
+}
+
+// parseDemoDay finds the day in text relative to now; ok is false when no day is mentioned
+func parseDemoDay(text string, now time.Time) (time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch {
+	case strings.Contains(text, "day after tomorrow"):
+		return today.AddDate(0, 0, 2), true
+	case strings.Contains(text, "tomorrow"):
+		return today.AddDate(0, 0, 1), true
+	case strings.Contains(text, "today"), strings.Contains(text, "tonight"):
+		return today, true
+	}
+
+	for offset := 0; offset < 7; offset++ {
+		weekday := time.Weekday(offset)
+		name := strings.ToLower(weekday.String())
+		if !strings.Contains(text, name) {
+			continue
+		}
+		days := (int(weekday) - int(now.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7 // today's weekday means the one next week; "today" is handled above
+		}
+		return today.AddDate(0, 0, days), true
+	}
+	return time.Time{}, false
+}
+
+// parseDemoDateTime resolves a time mention in text into a datetime in loc, relative to when the message
+// was sent. A time without a day is today, or tomorrow if it has already passed.
+func parseDemoDateTime(text string, sentAt time.Time, loc *time.Location) (time.Time, bool) {
+	text = strings.ToLower(text)
+	now := sentAt.In(loc)
+
+	day, hasDay := parseDemoDay(text, now)
+	hour, minute, hasClock := parseDemoClock(text)
+	if !hasDay && !hasClock {
+		return time.Time{}, false
+	}
+	if !hasClock {
+		hour = defaultDemoHour
+	}
+	if !hasDay {
+		day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	}
+
+	at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+	if !hasDay && !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at, true
+}
+
+// extractDemoDateTime parses the most recent demo time mention in the conversation: the current message
+// (sent now), then the history from the newest message, each relative to when it was sent
+func extractDemoDateTime(history []models.Message, currentMessage string, now time.Time, loc *time.Location) (time.Time, bool) {
+	if at, ok := parseDemoDateTime(currentMessage, now, loc); ok {
+		return at, true
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		sentAt := history[i].Timestamp
+		if sentAt.IsZero() {
+			sentAt = now
+		}
+		if at, ok := parseDemoDateTime(history[i].Message, sentAt, loc); ok {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// conversationMentionsDemo reports whether the visitor or the bot has talked about a demo
+func conversationMentionsDemo(history []models.Message, currentMessage string) bool {
+	if strings.Contains(strings.ToLower(currentMessage), "demo") {
+		return true
+	}
+	for _, msg := range history {
+		if strings.Contains(strings.ToLower(msg.Message), "demo") || strings.Contains(strings.ToLower(msg.Reply), "demo") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"saas-chatbot-platform/models"
+)
+
+func TestParseDemoDateTime(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("timezone data unavailable")
+	}
+	// Wednesday 2026-03-11, 14:00 in Kolkata
+	sentAt := time.Date(2026, 3, 11, 14, 0, 0, 0, loc)
+
+	tests := []struct {
+		text string
+		want time.Time
+		ok   bool
+	}{
+		{"Can we do 7 pm?", time.Date(2026, 3, 11, 19, 0, 0, 0, loc), true},
+		{"tomorrow at 10:30am works", time.Date(2026, 3, 12, 10, 30, 0, 0, loc), true},
+		{"tonight please", time.Date(2026, 3, 11, 20, 0, 0, 0, loc), true},
+		{"friday afternoon", time.Date(2026, 3, 13, 15, 0, 0, 0, loc), true},
+		{"wednesday at 5", time.Date(2026, 3, 18, 17, 0, 0, 0, loc), true},
+		{"seven o'clock", time.Date(2026, 3, 11, 19, 0, 0, 0, loc), true},
+		{"9 am", time.Date(2026, 3, 12, 9, 0, 0, 0, loc), true}, // already passed today
+		{"day after tomorrow", time.Date(2026, 3, 13, 10, 0, 0, 0, loc), true},
+		{"we are 5 people", time.Time{}, false},
+		{"what does it cost?", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDemoDateTime(tt.text, sentAt, loc)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("%q: got (%v, %v), want (%v, %v)", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExtractDemoDateTimeUsesMessageTime(t *testing.T) {
+	sentAt := time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC)
+	history := []models.Message{{Message: "let's do the demo tomorrow at 3pm", Timestamp: sentAt}}
+
+	got, ok := extractDemoDateTime(history, "sounds good", sentAt.Add(48*time.Hour), time.UTC)
+	if want := time.Date(2026, 3, 12, 15, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("got (%v, %v), want %v", got, ok, want)
+	}
+}
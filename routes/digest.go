@@ -0,0 +1,476 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// ANALYTICS DIGEST EMAILS
+// ===================
+
+// analyticsDigestTemplateType is the email_templates type clients can customise the digest with
+const analyticsDigestTemplateType = "analytics_digest"
+
+// digestFrequencies maps a digest frequency to the period it covers
+var digestFrequencies = map[string]time.Duration{
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+}
+
+// AnalyticsDigestScheduler periodically emails opted-in clients a summary of their analytics
+type AnalyticsDigestScheduler struct {
+	cfg         *config.Config
+	db          *mongo.Database
+	auditLogger *models.AuditLogger
+	emailSender *services.SMTPEmailSender
+	stopChan    chan struct{}
+}
+
+// NewAnalyticsDigestScheduler creates a digest scheduler
+func NewAnalyticsDigestScheduler(cfg *config.Config, db *mongo.Database, auditLogger *models.AuditLogger) *AnalyticsDigestScheduler {
+	return &AnalyticsDigestScheduler{
+		cfg:         cfg,
+		db:          db,
+		auditLogger: auditLogger,
+		emailSender: services.NewSMTPEmailSender(*cfg),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Start scans for due digests every AnalyticsDigestCheckInterval minutes until Stop is called
+func (s *AnalyticsDigestScheduler) Start() {
+	interval := time.Duration(s.cfg.AnalyticsDigestCheckInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info("Starting analytics digest scheduler", "interval", interval.String())
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			s.sendDueDigests(ctx)
+			cancel()
+
+		case <-s.stopChan:
+			logger.Info("Stopping analytics digest scheduler")
+			return
+		}
+	}
+}
+
+// Stop stops the scheduler
+func (s *AnalyticsDigestScheduler) Stop() {
+	close(s.stopChan)
+}
+
+// sendDueDigests sends a digest to every opted-in client whose last digest is older than its frequency
+func (s *AnalyticsDigestScheduler) sendDueDigests(ctx context.Context) {
+	if s.cfg.SMTPHost == "" {
+		return
+	}
+
+	clientsCollection := s.db.Collection("clients")
+	cursor, err := clientsCollection.Find(ctx, bson.M{
+		"analytics_digest.enabled": true,
+		"status":                   bson.M{"$nin": bson.A{"inactive", "suspended"}},
+	})
+	if err != nil {
+		logger.Error("Failed to load clients for analytics digest", "error", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	now := time.Now()
+	for cursor.Next(ctx) {
+		var client models.Client
+		if err := cursor.Decode(&client); err != nil || client.AnalyticsDigest == nil {
+			continue
+		}
+
+		settings := client.AnalyticsDigest
+		periodDur, ok := digestFrequencies[settings.Frequency]
+		if !ok {
+			periodDur = digestFrequencies["weekly"]
+		}
+		if !settings.LastSentAt.IsZero() && now.Sub(settings.LastSentAt) < periodDur {
+			continue
+		}
+
+		// Claim the send so that only one instance emails this client
+		claimFilter := bson.M{"_id": client.ID}
+		if settings.LastSentAt.IsZero() {
+			claimFilter["$or"] = bson.A{
+				bson.M{"analytics_digest.last_sent_at": bson.M{"$exists": false}},
+				bson.M{"analytics_digest.last_sent_at": settings.LastSentAt},
+			}
+		} else {
+			claimFilter["analytics_digest.last_sent_at"] = settings.LastSentAt
+		}
+		claim, err := clientsCollection.UpdateOne(ctx, claimFilter, bson.M{"$set": bson.M{"analytics_digest.last_sent_at": now}})
+		if err != nil {
+			logger.Warn("Failed to claim analytics digest", "error", err, "client_id", client.ID.Hex())
+			continue
+		}
+		if claim.ModifiedCount == 0 {
+			continue
+		}
+
+		s.sendDigest(ctx, &client, now.Add(-periodDur), now)
+	}
+}
+
+// sendDigest composes and emails one client's digest and records the send in the audit log
+func (s *AnalyticsDigestScheduler) sendDigest(ctx context.Context, client *models.Client, start, end time.Time) {
+	recipients := digestRecipients(client)
+	event := &models.AuditEvent{
+		ClientID:   client.ID.Hex(),
+		UserID:     "system",
+		Action:     "SEND",
+		Resource:   "analytics_digest",
+		ResourceID: client.ID.Hex(),
+		Changes: map[string]interface{}{
+			"frequency":    client.AnalyticsDigest.Frequency,
+			"recipients":   recipients,
+			"period_start": start,
+			"period_end":   end,
+		},
+	}
+
+	err := func() error {
+		if len(recipients) == 0 {
+			return fmt.Errorf("no digest recipients configured")
+		}
+		vars, err := buildDigestVariables(ctx, s.db, client, start, end)
+		if err != nil {
+			return err
+		}
+		email, err := composeDigestEmail(ctx, s.db.Collection("email_templates"), client.ID, vars)
+		if err != nil {
+			return err
+		}
+		return s.emailSender.SendEmail(recipients, email.Subject, email.HTMLBody, email.TextBody)
+	}()
+
+	event.Success = err == nil
+	if err != nil {
+		event.ErrorMessage = err.Error()
+		logger.Warn("Failed to send analytics digest", "error", err, "client_id", client.ID.Hex())
+	}
+	if s.auditLogger != nil {
+		s.auditLogger.Log(event)
+	}
+}
+
+// digestRecipients returns the configured recipients, falling back to the client's contact email
+func digestRecipients(client *models.Client) []string {
+	var recipients []string
+	if client.AnalyticsDigest != nil {
+		for _, r := range client.AnalyticsDigest.Recipients {
+			if r = strings.TrimSpace(r); r != "" {
+				recipients = append(recipients, r)
+			}
+		}
+	}
+	if len(recipients) == 0 && client.ContactEmail != "" {
+		recipients = append(recipients, client.ContactEmail)
+	}
+	return recipients
+}
+
+// buildDigestVariables gathers the digest figures using the existing analytics and quality functions
+func buildDigestVariables(ctx context.Context, db *mongo.Database, client *models.Client, start, end time.Time) (map[string]string, error) {
+	messagesCollection := db.Collection("messages")
+	frequency := client.AnalyticsDigest.Frequency
+	if _, ok := digestFrequencies[frequency]; !ok {
+		frequency = "weekly"
+	}
+
+	analytics, err := generateAnalytics(ctx, messagesCollection, client.ID, start, end, frequency, "", analyticsLocation(client))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate analytics: %w", err)
+	}
+
+	satisfaction := "n/a"
+	topTopics := "none"
+	if metrics, err := calculateQualityMetrics(ctx, db, client.ID, frequency); err == nil {
+		if metrics.TotalFeedback > 0 {
+			satisfaction = fmt.Sprintf("%.1f%%", metrics.SatisfactionRate*100)
+		}
+		if topics := topDigestTopics(metrics.TopicDistribution, 5); len(topics) > 0 {
+			topTopics = strings.Join(topics, ", ")
+		}
+	}
+
+	// New leads: conversations in the period where the visitor left an email
+	var newLeads int
+	if leads, err := messagesCollection.Distinct(ctx, "conversation_id", bson.M{
+		"client_id":  client.ID,
+		"timestamp":  bson.M{"$gte": start, "$lte": end},
+		"user_email": bson.M{"$nin": bson.A{nil, ""}},
+	}); err == nil {
+		newLeads = len(leads)
+	}
+
+	return map[string]string{
+		"client_name":         client.Name,
+		"frequency":           frequency,
+		"period_start":        start.Format("Jan 2, 2006"),
+		"period_end":          end.Format("Jan 2, 2006"),
+		"total_messages":      fmt.Sprintf("%v", analytics["total_messages"]),
+		"total_conversations": fmt.Sprintf("%v", analytics["total_conversations"]),
+		"total_tokens":        fmt.Sprintf("%v", analytics["total_tokens"]),
+		"satisfaction_rate":   satisfaction,
+		"top_topics":          topTopics,
+		"new_leads":           fmt.Sprintf("%d", newLeads),
+	}, nil
+}
+
+// topDigestTopics returns up to n topics ordered by count
+func topDigestTopics(distribution map[string]int, n int) []string {
+	topics := make([]string, 0, len(distribution))
+	for topic := range distribution {
+		topics = append(topics, topic)
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		if distribution[topics[i]] != distribution[topics[j]] {
+			return distribution[topics[i]] > distribution[topics[j]]
+		}
+		return topics[i] < topics[j]
+	})
+	if len(topics) > n {
+		topics = topics[:n]
+	}
+	return topics
+}
+
+// composeDigestEmail renders the client's analytics_digest template, or the built-in layout if none is active
+func composeDigestEmail(ctx context.Context, emailTemplatesCollection *mongo.Collection, clientID primitive.ObjectID, vars map[string]string) (*services.RenderedEmail, error) {
+	email, err := services.NewEmailTemplateRenderer(emailTemplatesCollection, clientID).RenderEmailTemplate(ctx, analyticsDigestTemplateType, vars)
+	if err == services.ErrEmailTemplateNotFound {
+		return services.RenderEmailTemplateContent(defaultDigestTemplate(), vars)
+	}
+	return email, err
+}
+
+// defaultDigestTemplate is used when the client hasn't configured an analytics_digest template
+func defaultDigestTemplate() models.EmailTemplate {
+	return models.EmailTemplate{
+		Type:    analyticsDigestTemplateType,
+		Subject: "Your {{frequency}} chatbot summary for {{client_name}}",
+		HTMLBody: `<html><body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
+<div style="max-width: 600px; margin: 0 auto; padding: 20px;">
+<h2 style="color: #3B82F6;">{{client_name}} - chatbot summary</h2>
+<p>{{period_start}} to {{period_end}}</p>
+<ul>
+<li><strong>Messages:</strong> {{total_messages}}</li>
+<li><strong>Conversations:</strong> {{total_conversations}}</li>
+<li><strong>Tokens used:</strong> {{total_tokens}}</li>
+<li><strong>Satisfaction rate:</strong> {{satisfaction_rate}}</li>
+<li><strong>New leads:</strong> {{new_leads}}</li>
+<li><strong>Top topics:</strong> {{top_topics}}</li>
+</ul>
+</div>
+</body></html>`,
+		TextBody: `{{client_name}} - chatbot summary
+{{period_start}} to {{period_end}}
+
+Messages: {{total_messages}}
+Conversations: {{total_conversations}}
+Tokens used: {{total_tokens}}
+Satisfaction rate: {{satisfaction_rate}}
+New leads: {{new_leads}}
+Top topics: {{top_topics}}
+`,
+	}
+}
+
+// handleGetAnalyticsDigest returns the analytics digest settings for the authenticated client
+func handleGetAnalyticsDigest(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		var client models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "client_not_found",
+					"message":    "Client not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch analytics digest settings",
+			})
+			return
+		}
+
+		settings := client.AnalyticsDigest
+		if settings == nil {
+			settings = &models.AnalyticsDigestSettings{Frequency: "weekly", Recipients: []string{}}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"analytics_digest":   settings,
+			"default_recipients": digestRecipients(&models.Client{ContactEmail: client.ContactEmail}),
+		})
+	}
+}
+
+// handleUpdateAnalyticsDigest opts the authenticated client in or out of digest emails
+func handleUpdateAnalyticsDigest(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var request struct {
+			Enabled    *bool    `json:"enabled"`
+			Frequency  string   `json:"frequency"`
+			Recipients []string `json:"recipients"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		set := bson.M{"updated_at": time.Now()}
+
+		if request.Enabled != nil {
+			set["analytics_digest.enabled"] = *request.Enabled
+		}
+
+		if request.Frequency != "" {
+			frequency := strings.ToLower(strings.TrimSpace(request.Frequency))
+			if _, ok := digestFrequencies[frequency]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_frequency",
+					"message":    "Frequency must be daily, weekly or monthly",
+				})
+				return
+			}
+			set["analytics_digest.frequency"] = frequency
+		}
+
+		if request.Recipients != nil {
+			if len(request.Recipients) > 10 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "too_many_recipients",
+					"message":    "At most 10 recipients are allowed",
+				})
+				return
+			}
+			recipients := []string{}
+			for _, r := range request.Recipients {
+				addr, err := mail.ParseAddress(strings.TrimSpace(r))
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"error_code": "invalid_recipient",
+						"message":    fmt.Sprintf("Invalid email address: %s", r),
+					})
+					return
+				}
+				recipients = append(recipients, addr.Address)
+			}
+			set["analytics_digest.recipients"] = recipients
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		// Default the frequency for clients enabling the digest for the first time
+		clientsCollection.UpdateOne(ctx, bson.M{
+			"_id":                        clientObjID,
+			"analytics_digest.frequency": bson.M{"$exists": false},
+		}, bson.M{"$set": bson.M{"analytics_digest.frequency": "weekly"}})
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{"$set": set})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update analytics digest settings",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		var updatedClient models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&updatedClient); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Analytics digest settings updated successfully",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Analytics digest settings updated successfully",
+			"analytics_digest": updatedClient.AnalyticsDigest,
+		})
+	}
+}
@@ -22,7 +22,7 @@ func SetupEmbedRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo
 	alertsCollection := db.Collection("suspicious_activity_alerts")
 
 	// Initialize domain auth middleware
-	domainAuthMiddleware := middleware.NewDomainAuthMiddleware(clientsCollection, alertsCollection)
+	domainAuthMiddleware := middleware.NewDomainAuthMiddleware(clientsCollection, alertsCollection).WithEmbedSigningSecret(cfg.EmbedSigningSecret)
 
 	// PUBLIC: Direct embed chat route - with domain authorization
 	router.GET("/embed/chat/:clientId", domainAuthMiddleware.CheckDomainAuthorization(), func(c *gin.Context) {
@@ -75,6 +75,7 @@ func SetupEmbedRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo
 			"WelcomeMessage": client.Branding.WelcomeMessage,
 			"PreQuestions":   client.Branding.PreQuestions,
 			"AuthToken":      "", // No auth token for public access
+			"EmbedToken":     c.Query("embed_token"),
 			"Theme":          theme,
 		}
 
@@ -122,6 +123,7 @@ func SetupEmbedRoutes(router *gin.Engine, cfg *config.Config, mongoClient *mongo
 			"WelcomeMessage": client.Branding.WelcomeMessage,
 			"PreQuestions":   client.Branding.PreQuestions,
 			"AuthToken":      "", // No auth token for public access
+			"EmbedToken":     c.Query("embed_token"),
 			"Theme":          theme,
 		}
 
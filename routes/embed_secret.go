@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// EMBED SECRET ROTATION
+// ===================
+//
+// Clients can replace a leaked embed secret themselves. The old secret keeps working for a grace
+// period so deployed widgets can be updated without downtime; a grace of 0 revokes it immediately.
+
+const maxEmbedSecretGraceHours = 168
+
+// handleRotateEmbedSecret generates a new embed secret and returns it once.
+// Optional body: {"grace_hours": n} (0-168, defaults to EMBED_SECRET_GRACE_HOURS).
+func handleRotateEmbedSecret(cfg *config.Config, clientsCollection *mongo.Collection, auditLogger *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var req struct {
+			GraceHours *int `json:"grace_hours"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_input",
+					"message":    "Invalid request body",
+				})
+				return
+			}
+		}
+		graceHours := cfg.EmbedSecretGraceHours
+		if req.GraceHours != nil {
+			graceHours = *req.GraceHours
+		}
+		if graceHours < 0 || graceHours > maxEmbedSecretGraceHours {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_grace_hours",
+				"message":    "grace_hours must be between 0 and 168",
+			})
+			return
+		}
+
+		secret, err := utils.GenerateEmbedSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to generate secret",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		var client models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		now := time.Now()
+		set := bson.M{
+			"embed_secret":            secret,
+			"embed_secret_rotated_at": now,
+			"updated_at":              now,
+		}
+		update := bson.M{"$set": set}
+		var previousExpiresAt *time.Time
+		if graceHours > 0 && client.EmbedSecret != "" {
+			expiresAt := now.Add(time.Duration(graceHours) * time.Hour)
+			previousExpiresAt = &expiresAt
+			set["previous_embed_secret"] = client.EmbedSecret
+			set["previous_embed_secret_expires_at"] = expiresAt
+		} else {
+			update["$unset"] = bson.M{"previous_embed_secret": "", "previous_embed_secret_expires_at": ""}
+		}
+
+		// Only replace the secret we read, so two concurrent rotations can't both keep a grace period
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID, "embed_secret": client.EmbedSecret}, update)
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to rotate embed secret",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error_code": "rotation_conflict",
+				"message":    "The embed secret was rotated by another request. Please try again.",
+			})
+			return
+		}
+
+		if auditLogger != nil {
+			changes := map[string]interface{}{"grace_hours": graceHours}
+			if previousExpiresAt != nil {
+				changes["previous_secret_expires_at"] = *previousExpiresAt
+			}
+			auditLogger.Log(&models.AuditEvent{
+				ClientID:   clientObjID.Hex(),
+				UserID:     middleware.GetUserID(c),
+				Action:     "UPDATE",
+				Resource:   "embed_secret",
+				ResourceID: clientObjID.Hex(),
+				IPAddress:  c.ClientIP(),
+				UserAgent:  c.Request.UserAgent(),
+				RequestID:  middleware.GetRequestID(c),
+				Success:    true,
+				Changes:    changes,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":                    "Embed secret rotated. Store it securely; it will not be shown again.",
+			"embed_secret":               secret,
+			"previous_secret_expires_at": previousExpiresAt,
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// EXPORT DEFAULTS
+// ===================
+//
+// A client can store the export shape it always wants (PUT /client/export/defaults). The chat export
+// endpoints (POST /client/export/chats and GET /client/export/chats/download) fill in whatever a request
+// leaves out: the format, the period (the last date_range_days days when neither date is given) and the
+// optional field groups. Anything the request specifies, including include_geo=false, wins.
+
+// exportDefaultFormats are the formats both chat export endpoints accept
+var exportDefaultFormats = map[string]bool{"json": true, "csv": true, "txt": true, "excel": true}
+
+// exportFieldGroups is the allowlist for include_fields
+var exportFieldGroups = map[string]bool{"geo": true, "meta": true}
+
+const maxExportDateRangeDays = 365
+
+// normalizeExportDefaults validates export defaults from an update request
+func normalizeExportDefaults(defaults models.ExportDefaults) (*models.ExportDefaults, error) {
+	normalized := &models.ExportDefaults{DateRangeDays: defaults.DateRangeDays}
+
+	if format := strings.ToLower(strings.TrimSpace(defaults.Format)); format != "" {
+		if !exportDefaultFormats[format] {
+			return nil, fmt.Errorf("format must be json, csv, txt or excel")
+		}
+		normalized.Format = format
+	}
+
+	if defaults.DateRangeDays < 0 || defaults.DateRangeDays > maxExportDateRangeDays {
+		return nil, fmt.Errorf("date_range_days must be between 0 and %d", maxExportDateRangeDays)
+	}
+
+	seen := make(map[string]bool)
+	for _, field := range defaults.IncludeFields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !exportFieldGroups[field] {
+			return nil, fmt.Errorf("unknown field %q in include_fields: use geo or meta", field)
+		}
+		if !seen[field] {
+			seen[field] = true
+			normalized.IncludeFields = append(normalized.IncludeFields, field)
+		}
+	}
+
+	return normalized, nil
+}
+
+// exportRequestGiven records which export settings the request specified itself
+type exportRequestGiven struct {
+	includeGeo  bool
+	includeMeta bool
+}
+
+// applyExportDefaults fills the settings req left out from the client's defaults
+func applyExportDefaults(req *services.ExportRequest, given exportRequestGiven, defaults *models.ExportDefaults, now time.Time) {
+	if defaults == nil {
+		return
+	}
+	if req.Format == "" {
+		req.Format = defaults.Format
+	}
+	if req.DateFrom.IsZero() && req.DateTo.IsZero() && defaults.DateRangeDays > 0 {
+		req.DateFrom = now.AddDate(0, 0, -defaults.DateRangeDays)
+	}
+	for _, field := range defaults.IncludeFields {
+		switch field {
+		case "geo":
+			if !given.includeGeo {
+				req.IncludeGeo = true
+			}
+		case "meta":
+			if !given.includeMeta {
+				req.IncludeMeta = true
+			}
+		}
+	}
+}
+
+// loadExportDefaults returns the export defaults of the authenticated client, or of the client an admin
+// exports (nil when there are none or they can't be read: the export then runs without them)
+func loadExportDefaults(c *gin.Context, clientsCollection *mongo.Collection, requestedClientID string) *models.ExportDefaults {
+	clientID := middleware.GetClientID(c)
+	if clientID == "" {
+		clientID = requestedClientID
+	}
+	clientObjID, err := primitive.ObjectIDFromHex(clientID)
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	client, err := getClientConfig(ctx, clientsCollection, clientObjID)
+	if err != nil {
+		if err.Error() != "client_not_found" {
+			logger.Warn("Failed to load export defaults", "error", err, "client_id", clientID)
+		}
+		return nil
+	}
+	return client.ExportDefaults
+}
+
+// handleGetExportDefaults returns the authenticated client's export defaults
+func handleGetExportDefaults(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		client, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		defaults := client.ExportDefaults
+		if defaults == nil {
+			defaults = &models.ExportDefaults{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"export_defaults":      defaults,
+			"allowed_formats":      []string{"json", "csv", "txt", "excel"},
+			"allowed_field_groups": []string{"geo", "meta"},
+		})
+	}
+}
+
+// handleUpdateExportDefaults replaces the authenticated client's export defaults; an empty body clears them
+func handleUpdateExportDefaults(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var request models.ExportDefaults
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		defaults, err := normalizeExportDefaults(request)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_export_defaults",
+				"message":    err.Error(),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		update := bson.M{"$set": bson.M{"export_defaults": defaults, "updated_at": time.Now()}}
+		if defaults.Format == "" && defaults.DateRangeDays == 0 && len(defaults.IncludeFields) == 0 {
+			update = bson.M{"$unset": bson.M{"export_defaults": ""}, "$set": bson.M{"updated_at": time.Now()}}
+		}
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, update)
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update export defaults",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":         "Export defaults updated successfully",
+			"export_defaults": defaults,
+		})
+	}
+}
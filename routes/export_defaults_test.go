@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+)
+
+func TestNormalizeExportDefaults(t *testing.T) {
+	got, err := normalizeExportDefaults(models.ExportDefaults{Format: " CSV ", DateRangeDays: 30, IncludeFields: []string{"Geo", "meta", "geo"}})
+	want := &models.ExportDefaults{Format: "csv", DateRangeDays: 30, IncludeFields: []string{"geo", "meta"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got (%+v, %v), want %+v", got, err, want)
+	}
+
+	for _, bad := range []models.ExportDefaults{
+		{Format: "both"},
+		{DateRangeDays: -1},
+		{DateRangeDays: 366},
+		{IncludeFields: []string{"user_email"}},
+	} {
+		if _, err := normalizeExportDefaults(bad); err == nil {
+			t.Errorf("%+v: expected an error", bad)
+		}
+	}
+}
+
+func TestApplyExportDefaults(t *testing.T) {
+	now := time.Date(2026, 5, 31, 12, 0, 0, 0, time.UTC)
+	defaults := &models.ExportDefaults{Format: "csv", DateRangeDays: 30, IncludeFields: []string{"geo", "meta"}}
+
+	req := &services.ExportRequest{}
+	applyExportDefaults(req, exportRequestGiven{}, defaults, now)
+	if req.Format != "csv" || !req.DateFrom.Equal(now.AddDate(0, 0, -30)) || !req.IncludeGeo || !req.IncludeMeta {
+		t.Errorf("defaults not applied: %+v", req)
+	}
+
+	// Whatever the request specifies wins
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	req = &services.ExportRequest{Format: "json", DateFrom: from}
+	applyExportDefaults(req, exportRequestGiven{includeGeo: true}, defaults, now)
+	if req.Format != "json" || !req.DateFrom.Equal(from) || req.IncludeGeo || !req.IncludeMeta {
+		t.Errorf("request settings overridden: %+v", req)
+	}
+}
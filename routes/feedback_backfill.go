@@ -0,0 +1,172 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// PLATFORM-WIDE FEEDBACK BACKFILL
+// ===================
+
+const (
+	defaultBackfillTimeBudget = 2 * time.Minute
+	maxBackfillTimeBudget     = 10 * time.Minute
+	defaultBackfillMaxBatches = 10 // processUnanalyzedFeedback handles up to 100 entries per batch
+	maxBackfillMaxBatches     = 100
+)
+
+// feedbackBackfillResult reports the work done for one client
+type feedbackBackfillResult struct {
+	ClientID        string `json:"client_id"`
+	ClientName      string `json:"client_name,omitempty"`
+	Batches         int    `json:"batches"`
+	Processed       int    `json:"processed"`
+	InsightsCreated int    `json:"insights_created"`
+	Completed       bool   `json:"completed"` // false when the batch limit or time budget stopped processing
+	Error           string `json:"error,omitempty"`
+}
+
+// HandleBackfillFeedbackAnalysis processes unanalyzed feedback for every client in bounded
+// batches until the work is done or the time budget runs out.
+// Query parameters: time_budget_seconds (default 120, max 600) and max_batches_per_client (default 10, max 100).
+func HandleBackfillFeedbackAnalysis(db *mongo.Database, auditLogger *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeBudget := defaultBackfillTimeBudget
+		if v := c.Query("time_budget_seconds"); v != "" {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_time_budget",
+					"message":    "time_budget_seconds must be a positive integer",
+				})
+				return
+			}
+			timeBudget = time.Duration(seconds) * time.Second
+			if timeBudget > maxBackfillTimeBudget {
+				timeBudget = maxBackfillTimeBudget
+			}
+		}
+
+		maxBatches := defaultBackfillMaxBatches
+		if v := c.Query("max_batches_per_client"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_max_batches",
+					"message":    "max_batches_per_client must be a positive integer",
+				})
+				return
+			}
+			maxBatches = n
+			if maxBatches > maxBackfillMaxBatches {
+				maxBatches = maxBackfillMaxBatches
+			}
+		}
+
+		start := time.Now()
+		deadline := start.Add(timeBudget)
+		ctx, cancel := context.WithDeadline(c.Request.Context(), deadline.Add(30*time.Second))
+		defer cancel()
+
+		// Only visit clients that have feedback waiting for analysis or an insight
+		clientIDs, err := db.Collection("message_feedback").Distinct(ctx, "client_id", bson.M{
+			"$or": bson.A{
+				bson.M{"analyzed": bson.M{"$ne": true}},
+				bson.M{"feedback_type": "negative", "insight_created": bson.M{"$ne": true}},
+			},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to find clients with unanalyzed feedback",
+			})
+			return
+		}
+
+		results := make([]feedbackBackfillResult, 0, len(clientIDs))
+		totalProcessed, totalInsights, remainingClients := 0, 0, 0
+
+		for i, raw := range clientIDs {
+			clientID, ok := raw.(primitive.ObjectID)
+			if !ok {
+				continue
+			}
+			if time.Now().After(deadline) {
+				remainingClients = len(clientIDs) - i
+				break
+			}
+
+			result := feedbackBackfillResult{ClientID: clientID.Hex()}
+			var client models.Client
+			if err := db.Collection("clients").FindOne(ctx, bson.M{"_id": clientID}).Decode(&client); err == nil {
+				result.ClientName = client.Name
+			}
+
+			for result.Batches < maxBatches && time.Now().Before(deadline) {
+				processed, insights, err := processUnanalyzedFeedback(ctx, db, &clientID)
+				result.Batches++
+				if err != nil {
+					result.Error = err.Error()
+					break
+				}
+				result.Processed += processed
+				result.InsightsCreated += insights
+				if processed == 0 && insights == 0 {
+					result.Completed = true
+					break
+				}
+			}
+
+			totalProcessed += result.Processed
+			totalInsights += result.InsightsCreated
+			results = append(results, result)
+
+			logger.Info("Feedback backfill progress",
+				"client", i+1, "of", len(clientIDs), "client_id", result.ClientID,
+				"processed", result.Processed, "insights_created", result.InsightsCreated, "completed", result.Completed)
+		}
+
+		timedOut := remainingClients > 0
+		if auditLogger != nil {
+			auditLogger.Log(&models.AuditEvent{
+				ClientID: middleware.GetClientID(c),
+				UserID:   middleware.GetUserID(c),
+				Action:   "UPDATE",
+				Resource: "feedback_analysis",
+				Success:  true,
+				Changes: map[string]interface{}{
+					"operation":         "backfill",
+					"clients":           len(results),
+					"remaining_clients": remainingClients,
+					"processed":         totalProcessed,
+					"insights_created":  totalInsights,
+					"time_budget":       timeBudget.String(),
+					"duration":          time.Since(start).String(),
+				},
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":           true,
+			"clients":           results,
+			"clients_processed": len(results),
+			"remaining_clients": remainingClients,
+			"processed":         totalProcessed,
+			"insights_created":  totalInsights,
+			"timed_out":         timedOut,
+			"duration_ms":       time.Since(start).Milliseconds(),
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package routes
+
+import "testing"
+
+func TestReplyContainsSpan(t *testing.T) {
+	reply := "## Pricing\n\nOur **Pro plan** costs *$20/month*.\n\n- Includes `API access`\n- See [the pricing page](https://example.com/pricing) for details\n> Billed annually"
+
+	tests := []struct {
+		span string
+		want bool
+	}{
+		{"Our Pro plan costs $20/month.", true},
+		{"Pro plan", true},
+		{"Includes API access", true},
+		{"See the pricing page for details", true},
+		{"Pricing Our Pro plan", true},
+		{"Billed  annually", true},
+		{"**Pro plan**", true},
+		{"Our Enterprise plan", false},
+		{"https://example.com/pricing", false},
+	}
+	for _, tt := range tests {
+		if got := replyContainsSpan(reply, tt.span); got != tt.want {
+			t.Errorf("replyContainsSpan(%q) = %v, want %v", tt.span, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// FEEDBACK SEARCH
+// ===================
+
+const (
+	minFeedbackSearchChars = 2
+	maxFeedbackSearchChars = 200
+)
+
+// feedbackSearchFields are the feedback fields matched against the query
+var feedbackSearchFields = []string{"comment", "user_message", "ai_response"}
+
+// feedbackMatchedFields lists which searchable fields of a feedback entry contain the query
+func feedbackMatchedFields(feedback models.MessageFeedback, query string) []string {
+	query = strings.ToLower(query)
+	texts := []string{feedback.Comment, feedback.UserMessage, feedback.AIResponse}
+	matched := []string{}
+	for i, field := range feedbackSearchFields {
+		if strings.Contains(strings.ToLower(texts[i]), query) {
+			matched = append(matched, field)
+		}
+	}
+	return matched
+}
+
+// handleSearchFeedback searches the authenticated client's raw feedback by keyword across the comment, the
+// user's message and the rated reply, newest first. Optional filters: type (positive/negative) and issue_category.
+func handleSearchFeedback(db *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		query := strings.TrimSpace(c.Query("q"))
+		if len([]rune(query)) < minFeedbackSearchChars || len([]rune(query)) > maxFeedbackSearchChars {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_query",
+				"message":    "q must be between 2 and 200 characters",
+			})
+			return
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		or := bson.A{}
+		for _, field := range feedbackSearchFields {
+			or = append(or, bson.M{field: pattern})
+		}
+		filter := bson.M{
+			"client_id": clientObjID,
+			"$or":       or,
+		}
+
+		if feedbackType := c.Query("type"); feedbackType != "" {
+			if feedbackType != "positive" && feedbackType != "negative" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_type",
+					"message":    "type must be positive or negative",
+				})
+				return
+			}
+			filter["feedback_type"] = feedbackType
+		}
+		if category := c.Query("issue_category"); category != "" {
+			filter["issue_category"] = category
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+		defer cancel()
+
+		feedbackCollection := db.Collection("message_feedback")
+		total, err := feedbackCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to search feedback",
+			})
+			return
+		}
+
+		cursor, err := feedbackCollection.Find(ctx, filter, options.Find().
+			SetSort(bson.M{"timestamp": -1}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)).
+			SetProjection(bson.M{"user_ip": 0, "conversation_context": 0}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to search feedback",
+			})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var matches []models.MessageFeedback
+		if err := cursor.All(ctx, &matches); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to decode feedback",
+			})
+			return
+		}
+
+		results := make([]gin.H, 0, len(matches))
+		for _, feedback := range matches {
+			results = append(results, gin.H{
+				"id":              feedback.ID.Hex(),
+				"message_id":      feedback.MessageID.Hex(),
+				"conversation_id": feedback.ConversationID,
+				"feedback_type":   feedback.FeedbackType,
+				"issue_category":  feedback.IssueCategory,
+				"comment":         feedback.Comment,
+				"user_message":    feedback.UserMessage,
+				"ai_response":     feedback.AIResponse,
+				"quality_score":   feedback.QualityScore,
+				"timestamp":       feedback.Timestamp,
+				"matched_fields":  feedbackMatchedFields(feedback, query),
+			})
+		}
+
+		totalPages := (total + int64(limit) - 1) / int64(limit)
+
+		c.JSON(http.StatusOK, gin.H{
+			"query":   query,
+			"results": results,
+			"pagination": gin.H{
+				"page":        page,
+				"limit":       limit,
+				"total":       total,
+				"total_pages": totalPages,
+			},
+		})
+	}
+}
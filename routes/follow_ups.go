@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// FOLLOW-UP SUGGESTIONS
+// ===================
+
+// maxFollowUpSuggestionLength bounds a single suggestion
+const maxFollowUpSuggestionLength = 500
+
+// defaultFollowUpSuggestions are used for topics the client hasn't customised.
+// The keys are the topics returned by detectLastTopic.
+var defaultFollowUpSuggestions = map[string]string{
+	"pricing":    "Would you like help choosing the option that best fits your needs?",
+	"database":   "Is there specific information you'd like me to look up for you?",
+	"delivery":   "What timeline or results are you aiming for?",
+	"conversion": "What outcome would make this worthwhile for you?",
+	"demo":       "Would you like me to help arrange a time that works for you?",
+	"general":    "What specific aspect would you like to explore next?",
+}
+
+// followUpSuggestion returns the client's suggestion for topic, or the generic default
+func followUpSuggestion(custom map[string]string, topic string) string {
+	if suggestion := strings.TrimSpace(custom[topic]); suggestion != "" {
+		return suggestion
+	}
+	if suggestion, ok := defaultFollowUpSuggestions[topic]; ok {
+		return suggestion
+	}
+	return defaultFollowUpSuggestions["general"]
+}
+
+// followUpSuggestionsResponse lists every topic with its effective suggestion
+func followUpSuggestionsResponse(custom map[string]string) []gin.H {
+	topics := []string{"pricing", "database", "delivery", "conversion", "demo", "general"}
+	suggestions := make([]gin.H, 0, len(topics))
+	for _, topic := range topics {
+		_, isCustom := custom[topic]
+		suggestions = append(suggestions, gin.H{
+			"topic":      topic,
+			"suggestion": followUpSuggestion(custom, topic),
+			"is_custom":  isCustom,
+			"default":    defaultFollowUpSuggestions[topic],
+		})
+	}
+	return suggestions
+}
+
+// followUpTopicParam validates the :topic parameter, writing a 400 response if it is unknown
+func followUpTopicParam(c *gin.Context) (string, bool) {
+	topic := strings.ToLower(strings.TrimSpace(c.Param("topic")))
+	if _, ok := defaultFollowUpSuggestions[topic]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error_code": "invalid_topic",
+			"message":    "Topic must be one of pricing, database, delivery, conversion, demo or general",
+		})
+		return "", false
+	}
+	return topic, true
+}
+
+// handleGetFollowUpSuggestions returns the follow-up suggestion for every topic
+func handleGetFollowUpSuggestions(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		var client models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "client_not_found",
+					"message":    "Client not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch follow-up suggestions",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"follow_up_suggestions": followUpSuggestionsResponse(client.FollowUpSuggestions),
+		})
+	}
+}
+
+// handleSetFollowUpSuggestion creates or replaces the suggestion for one topic
+func handleSetFollowUpSuggestion(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		topic, ok := followUpTopicParam(c)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			Suggestion string `json:"suggestion" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		suggestion := strings.TrimSpace(request.Suggestion)
+		if suggestion == "" || len(suggestion) > maxFollowUpSuggestionLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_suggestion",
+				"message":    "Suggestion must be between 1 and 500 characters",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$set": bson.M{
+				"follow_up_suggestions." + topic: suggestion,
+				"updated_at":                     time.Now(),
+			},
+		})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update follow-up suggestion",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Follow-up suggestion updated successfully",
+			"topic":      topic,
+			"suggestion": suggestion,
+		})
+	}
+}
+
+// handleDeleteFollowUpSuggestion removes the client's suggestion for a topic, restoring the default
+func handleDeleteFollowUpSuggestion(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		topic, ok := followUpTopicParam(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$unset": bson.M{"follow_up_suggestions." + topic: ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "delete_failed",
+				"message":    "Failed to delete follow-up suggestion",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Follow-up suggestion reset to default",
+			"topic":      topic,
+			"suggestion": defaultFollowUpSuggestions[topic],
+		})
+	}
+}
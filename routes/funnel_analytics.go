@@ -0,0 +1,178 @@
+package routes
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// CONVERSION FUNNEL
+// ===================
+//
+// The funnel follows widget sessions (embed messages grouped by conversation) with messages in the period:
+//
+//	visited          a session with at least one visitor message
+//	engaged          more than one visitor message
+//	contact_started  the bot started collecting contact details (contact_collection_phase set)
+//	lead             contact collection completed
+//
+// Each stage reports its conversion from the previous stage and from visited. Spam is left out.
+
+// funnelCounts are the sessions reaching each funnel stage
+type funnelCounts struct {
+	Visited        int `bson:"visited"`
+	Engaged        int `bson:"engaged"`
+	ContactStarted int `bson:"contact_started"`
+	Leads          int `bson:"leads"`
+}
+
+// funnelRate is part/whole rounded to 0.1%, or 0 for an empty stage
+func funnelRate(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1000) / 1000
+}
+
+// funnelStages lists the stages in order with their conversion rates
+func funnelStages(counts funnelCounts) []gin.H {
+	stages := []struct {
+		name     string
+		sessions int
+	}{
+		{"visited", counts.Visited},
+		{"engaged", counts.Engaged},
+		{"contact_started", counts.ContactStarted},
+		{"lead", counts.Leads},
+	}
+
+	out := make([]gin.H, 0, len(stages))
+	for i, stage := range stages {
+		previous := stage.sessions
+		if i > 0 {
+			previous = stages[i-1].sessions
+		}
+		out = append(out, gin.H{
+			"stage":              stage.name,
+			"sessions":           stage.sessions,
+			"conversion_rate":    funnelRate(stage.sessions, previous), // from the previous stage
+			"overall_conversion": funnelRate(stage.sessions, counts.Visited),
+			"drop_off":           previous - stage.sessions,
+		})
+	}
+	return out
+}
+
+// getFunnelCounts aggregates the funnel over embed sessions with messages in [start, end]
+func getFunnelCounts(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time) (funnelCounts, error) {
+	contactPhases := bson.A{"awaiting_name", "awaiting_email", "completed"}
+	cursor, err := messagesCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"client_id":     clientID,
+			"is_embed_user": true,
+			"timestamp":     bson.M{"$gte": start, "$lte": end},
+			"spam":          bson.M{"$exists": false},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$conversation_id",
+			"messages": bson.M{"$sum": 1},
+			"contact": bson.M{"$max": bson.M{
+				"$cond": bson.A{bson.M{"$in": bson.A{"$contact_collection_phase", contactPhases}}, 1, 0},
+			}},
+			"lead": bson.M{"$max": bson.M{
+				"$cond": bson.A{bson.M{"$eq": bson.A{"$contact_collection_phase", "completed"}}, 1, 0},
+			}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":             nil,
+			"visited":         bson.M{"$sum": 1},
+			"engaged":         bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$messages", 1}}, 1, 0}}},
+			"contact_started": bson.M{"$sum": "$contact"},
+			"leads":           bson.M{"$sum": "$lead"},
+		}}},
+	})
+	if err != nil {
+		return funnelCounts{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []funnelCounts
+	if err := cursor.All(ctx, &results); err != nil {
+		return funnelCounts{}, err
+	}
+	if len(results) == 0 {
+		return funnelCounts{}, nil
+	}
+	return results[0], nil
+}
+
+// handleFunnelAnalytics returns the visited -> engaged -> contact -> lead funnel for a period
+// (period=30d, or range=YYYY-MM-DD..YYYY-MM-DD in the client's analytics timezone)
+func handleFunnelAnalytics(clientsCollection, messagesCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+
+		period := strings.ToLower(strings.TrimSpace(c.DefaultQuery("period", "30d")))
+		end := time.Now()
+		start := end.Add(-parsePeriod(period))
+		loc := loadAnalyticsLocation(ctx, clientsCollection, clientObjID)
+		if value := c.Query("range"); value != "" {
+			if start, end, err = parseAnalyticsDateRange(value, loc); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_range",
+					"message":    err.Error(),
+				})
+				return
+			}
+			period = "custom"
+		}
+
+		counts, err := getFunnelCounts(ctx, messagesCollection, clientObjID, start, end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "analytics_error",
+				"message":    "Failed to compute funnel analytics",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"period":     period,
+			"start_date": start.Format(time.RFC3339),
+			"end_date":   end.Format(time.RFC3339),
+			"timezone":   loc.String(),
+			"stages":     funnelStages(counts),
+			"lead_rate":  funnelRate(counts.Leads, counts.Visited),
+		})
+	}
+}
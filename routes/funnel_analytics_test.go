@@ -0,0 +1,30 @@
+package routes
+
+import "testing"
+
+func TestFunnelStages(t *testing.T) {
+	stages := funnelStages(funnelCounts{Visited: 200, Engaged: 80, ContactStarted: 20, Leads: 10})
+	want := []struct {
+		stage      string
+		sessions   int
+		conversion float64
+		overall    float64
+	}{
+		{"visited", 200, 1, 1},
+		{"engaged", 80, 0.4, 0.4},
+		{"contact_started", 20, 0.25, 0.1},
+		{"lead", 10, 0.5, 0.05},
+	}
+	for i, w := range want {
+		s := stages[i]
+		if s["stage"] != w.stage || s["sessions"] != w.sessions || s["conversion_rate"] != w.conversion || s["overall_conversion"] != w.overall {
+			t.Errorf("stage %d = %v, want %+v", i, s, w)
+		}
+	}
+
+	for _, s := range funnelStages(funnelCounts{}) {
+		if s["conversion_rate"] != 0.0 {
+			t.Errorf("empty funnel stage %v has a non-zero rate", s)
+		}
+	}
+}
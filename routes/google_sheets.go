@@ -0,0 +1,722 @@
+package routes
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// ===================
+// GOOGLE SHEETS ANALYTICS EXPORT
+// ===================
+//
+// A client connects a spreadsheet through Google's OAuth consent screen; the refresh token is stored on the
+// client and used by the export job to append one row per complete UTC day, the same numbers the analytics
+// endpoint reports for that day. The first sync after connecting backfills up to GoogleSheetsBackfillDays.
+
+const (
+	googleSheetsCallbackPath   = "/integrations/google-sheets/callback"
+	googleSheetsDefaultSheet   = "Chatbot Analytics"
+	googleSheetsStateTTL       = 15 * time.Minute
+	googleSheetsSyncLease      = 10 * time.Minute
+	googleSheetsDateLayout     = "2006-01-02"
+	googleSheetsRevokeEndpoint = "https://oauth2.googleapis.com/revoke"
+	maxGoogleSheetNameLength   = 100
+)
+
+var (
+	spreadsheetURLPattern = regexp.MustCompile(`/spreadsheets/d/([a-zA-Z0-9_-]+)`)
+	spreadsheetIDPattern  = regexp.MustCompile(`^[a-zA-Z0-9_-]{20,100}$`)
+
+	// googleSheetsHeader is written above the first row when the sheet is empty
+	googleSheetsHeader = []interface{}{
+		"date", "total_messages", "total_conversations", "active_users", "total_tokens",
+		"avg_messages_per_conversation", "spam_messages", "spam_rate",
+	}
+)
+
+// googleSheetsOAuthConfig returns the OAuth client for Sheets consent, or nil when the export isn't configured
+func googleSheetsOAuthConfig(cfg *config.Config) *oauth2.Config {
+	if cfg.GoogleSheetsClientID == "" || cfg.GoogleSheetsClientSecret == "" || cfg.PublicAPIURL == "" {
+		return nil
+	}
+	return &oauth2.Config{
+		ClientID:     cfg.GoogleSheetsClientID,
+		ClientSecret: cfg.GoogleSheetsClientSecret,
+		RedirectURL:  cfg.PublicAPIURL + googleSheetsCallbackPath,
+		Scopes:       []string{sheets.SpreadsheetsScope},
+		Endpoint:     google.Endpoint,
+	}
+}
+
+// parseSpreadsheetID accepts a spreadsheet ID or a docs.google.com spreadsheet URL
+func parseSpreadsheetID(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if m := spreadsheetURLPattern.FindStringSubmatch(value); m != nil {
+		value = m[1]
+	}
+	return value, spreadsheetIDPattern.MatchString(value)
+}
+
+// googleSheetsState is carried through the consent screen so the callback knows which client and sheet it is for
+type googleSheetsState struct {
+	ClientID      string `json:"c"`
+	SpreadsheetID string `json:"s"`
+	SheetName     string `json:"n"`
+	ExpiresAt     int64  `json:"e"`
+}
+
+// signGoogleSheetsState encodes state with an HMAC so the public callback can trust it
+func signGoogleSheetsState(secret string, state googleSheetsState) (string, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verifyGoogleSheetsState checks the signature and expiry of a state produced by signGoogleSheetsState
+func verifyGoogleSheetsState(secret, value string, now time.Time) (*googleSheetsState, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, errors.New("malformed state")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, errors.New("malformed state")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, errors.New("malformed state")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("invalid state signature")
+	}
+
+	var state googleSheetsState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, errors.New("malformed state")
+	}
+	if now.Unix() > state.ExpiresAt {
+		return nil, errors.New("state expired")
+	}
+	return &state, nil
+}
+
+// googleSheetsDaysToSync returns the UTC days that still need a row, oldest first: the days after the last
+// synced one up to yesterday, or the backfill window (never before the client existed) on the first sync
+func googleSheetsDaysToSync(settings *models.GoogleSheetsSettings, clientCreatedAt, now time.Time, backfillDays int) []time.Time {
+	today := now.UTC().Truncate(24 * time.Hour)
+	yesterday := today.AddDate(0, 0, -1)
+
+	var from time.Time
+	if last, err := time.Parse(googleSheetsDateLayout, settings.LastSyncedDate); err == nil {
+		from = last.AddDate(0, 0, 1)
+	} else {
+		if backfillDays < 1 {
+			backfillDays = 1
+		}
+		from = today.AddDate(0, 0, -backfillDays)
+		if created := clientCreatedAt.UTC().Truncate(24 * time.Hour); !clientCreatedAt.IsZero() && created.After(from) {
+			from = created
+		}
+	}
+
+	var days []time.Time
+	for day := from; !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// googleSheetsRow turns a day's analytics into a sheet row in googleSheetsHeader order
+func googleSheetsRow(day time.Time, analytics gin.H) []interface{} {
+	return []interface{}{
+		day.Format(googleSheetsDateLayout),
+		analytics["total_messages"],
+		analytics["total_conversations"],
+		analytics["active_users"],
+		analytics["total_tokens"],
+		analytics["avg_messages_per_conversation"],
+		analytics["spam_messages"],
+		analytics["spam_rate"],
+	}
+}
+
+// quotedSheetRange returns an A1 range on the named tab, quoting the name as Sheets requires
+func quotedSheetRange(sheetName, cells string) string {
+	return "'" + strings.ReplaceAll(sheetName, "'", "''") + "'!" + cells
+}
+
+// sealGoogleSheetsToken encrypts an OAuth token for storage with the webhook header key
+func sealGoogleSheetsToken(cfg *config.Config, token string) (string, error) {
+	return sealIntegrationSecret(cfg, token)
+}
+
+// isSealedGoogleSheetsToken reports whether a stored token is encrypted; tokens saved before
+// encryption was added are plaintext until the next sync re-stores them
+func isSealedGoogleSheetsToken(stored string) bool {
+	return isSealedIntegrationSecret(stored)
+}
+
+// openGoogleSheetsToken decrypts a stored OAuth token, passing plaintext tokens through
+func openGoogleSheetsToken(cfg *config.Config, stored string) (string, error) {
+	return openIntegrationSecret(cfg, stored)
+}
+
+// newSheetsService builds a Sheets client from the stored tokens, refreshing the access token when needed.
+// The returned token is the one in use, so callers can persist it if it changed.
+func newSheetsService(ctx context.Context, oauthCfg *oauth2.Config, settings *models.GoogleSheetsSettings) (*sheets.Service, *oauth2.Token, error) {
+	source := oauthCfg.TokenSource(ctx, &oauth2.Token{
+		AccessToken:  settings.AccessToken,
+		RefreshToken: settings.RefreshToken,
+		Expiry:       settings.TokenExpiry,
+	})
+	token, err := source.Token()
+	if err != nil {
+		return nil, nil, fmt.Errorf("google authorization failed, reconnect the sheet: %w", err)
+	}
+	service, err := sheets.NewService(ctx, option.WithTokenSource(oauth2.StaticTokenSource(token)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return service, token, nil
+}
+
+// ensureSheetTab creates the export tab in the spreadsheet if it doesn't exist yet
+func ensureSheetTab(ctx context.Context, service *sheets.Service, spreadsheetID, sheetName string) error {
+	spreadsheet, err := service.Spreadsheets.Get(spreadsheetID).Fields(googleapi.Field("sheets.properties.title")).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to open spreadsheet: %w", err)
+	}
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties != nil && sheet.Properties.Title == sheetName {
+			return nil
+		}
+	}
+	_, err = service.Spreadsheets.BatchUpdate(spreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: sheetName}}}},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to add sheet %q: %w", sheetName, err)
+	}
+	return nil
+}
+
+// syncGoogleSheet appends rows for every day the client's sheet is missing. A lease on the client document
+// keeps the scheduler and a post-connect backfill from appending the same days twice.
+func syncGoogleSheet(ctx context.Context, cfg *config.Config, db *mongo.Database, clientID primitive.ObjectID) error {
+	oauthCfg := googleSheetsOAuthConfig(cfg)
+	if oauthCfg == nil {
+		return nil
+	}
+	clientsCollection := db.Collection("clients")
+
+	now := time.Now()
+	var client models.Client
+	err := clientsCollection.FindOneAndUpdate(ctx, bson.M{
+		"_id":                         clientID,
+		"google_sheets.refresh_token": bson.M{"$nin": bson.A{nil, ""}},
+		"$or": bson.A{
+			bson.M{"google_sheets.sync_lock_until": bson.M{"$exists": false}},
+			bson.M{"google_sheets.sync_lock_until": bson.M{"$lt": now}},
+		},
+	}, bson.M{
+		"$set": bson.M{"google_sheets.sync_lock_until": now.Add(googleSheetsSyncLease)},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&client)
+	if err == mongo.ErrNoDocuments {
+		return nil // disconnected, or another sync holds the lease
+	}
+	if err != nil {
+		return err
+	}
+	settings := client.GoogleSheets
+	connectedAt := settings.ConnectedAt
+
+	result := bson.M{"google_sheets.last_sync_at": time.Now()}
+	syncErr := appendMissingDays(ctx, cfg, oauthCfg, db.Collection("messages"), &client, result)
+	if syncErr != nil {
+		result["google_sheets.last_error"] = syncErr.Error()
+	} else {
+		result["google_sheets.last_error"] = ""
+	}
+
+	// Only record the outcome if the sheet wasn't disconnected or reconnected meanwhile
+	_, err = clientsCollection.UpdateOne(context.Background(), bson.M{
+		"_id":                        clientID,
+		"google_sheets.connected_at": connectedAt,
+	}, bson.M{
+		"$set":   result,
+		"$unset": bson.M{"google_sheets.sync_lock_until": ""},
+	})
+	if err != nil {
+		return err
+	}
+	return syncErr
+}
+
+// appendMissingDays writes the client's missing daily rows, recording the new token and last synced day in result
+func appendMissingDays(ctx context.Context, cfg *config.Config, oauthCfg *oauth2.Config, messagesCollection *mongo.Collection, client *models.Client, result bson.M) error {
+	settings := client.GoogleSheets
+	days := googleSheetsDaysToSync(settings, client.CreatedAt, time.Now(), cfg.GoogleSheetsBackfillDays)
+	if len(days) == 0 {
+		return nil
+	}
+
+	// ✅ NEW: Tokens are stored encrypted
+	plain := *settings
+	var err error
+	if plain.RefreshToken, err = openGoogleSheetsToken(cfg, settings.RefreshToken); err != nil {
+		return fmt.Errorf("failed to decrypt the stored Google token, reconnect the sheet: %w", err)
+	}
+	if plain.AccessToken, err = openGoogleSheetsToken(cfg, settings.AccessToken); err != nil {
+		plain.AccessToken = "" // forces a refresh
+	}
+
+	service, token, err := newSheetsService(ctx, oauthCfg, &plain)
+	if err != nil {
+		return err
+	}
+	// Store rotated tokens, and encrypt tokens saved before encryption was added
+	if token.AccessToken != plain.AccessToken || !isSealedGoogleSheetsToken(settings.AccessToken) {
+		if sealed, err := sealGoogleSheetsToken(cfg, token.AccessToken); err == nil {
+			result["google_sheets.access_token"] = sealed
+			result["google_sheets.token_expiry"] = token.Expiry
+		}
+	}
+	refreshToken := token.RefreshToken
+	if refreshToken == "" {
+		refreshToken = plain.RefreshToken
+	}
+	if refreshToken != plain.RefreshToken || !isSealedGoogleSheetsToken(settings.RefreshToken) {
+		if sealed, err := sealGoogleSheetsToken(cfg, refreshToken); err == nil {
+			result["google_sheets.refresh_token"] = sealed
+		}
+	}
+
+	var rows [][]interface{}
+	existing, err := service.Spreadsheets.Values.Get(settings.SpreadsheetID, quotedSheetRange(settings.SheetName, "A1:A1")).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to read sheet: %w", err)
+	}
+	if len(existing.Values) == 0 {
+		rows = append(rows, googleSheetsHeader)
+	}
+
+	for _, day := range days {
+		analytics, err := generateAnalytics(ctx, messagesCollection, client.ID, day, day.Add(24*time.Hour-time.Nanosecond), "day", "", time.UTC)
+		if err != nil {
+			return fmt.Errorf("failed to compute analytics for %s: %w", day.Format(googleSheetsDateLayout), err)
+		}
+		rows = append(rows, googleSheetsRow(day, analytics))
+	}
+
+	_, err = service.Spreadsheets.Values.Append(settings.SpreadsheetID, quotedSheetRange(settings.SheetName, "A1"), &sheets.ValueRange{Values: rows}).
+		ValueInputOption("USER_ENTERED").
+		InsertDataOption("INSERT_ROWS").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return fmt.Errorf("failed to append rows: %w", err)
+	}
+
+	result["google_sheets.last_synced_date"] = days[len(days)-1].Format(googleSheetsDateLayout)
+	return nil
+}
+
+// GoogleSheetsSyncScheduler appends yesterday's analytics (and any missed days) to every connected sheet
+type GoogleSheetsSyncScheduler struct {
+	cfg      *config.Config
+	db       *mongo.Database
+	stopChan chan struct{}
+}
+
+// NewGoogleSheetsSyncScheduler creates a Google Sheets export scheduler
+func NewGoogleSheetsSyncScheduler(cfg *config.Config, db *mongo.Database) *GoogleSheetsSyncScheduler {
+	return &GoogleSheetsSyncScheduler{
+		cfg:      cfg,
+		db:       db,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start syncs connected sheets every GoogleSheetsSyncInterval minutes until Stop is called
+func (s *GoogleSheetsSyncScheduler) Start() {
+	interval := time.Duration(s.cfg.GoogleSheetsSyncInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info("Starting Google Sheets sync scheduler", "check_interval", interval.String())
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			s.syncAll(ctx)
+			cancel()
+
+		case <-s.stopChan:
+			logger.Info("Stopping Google Sheets sync scheduler")
+			return
+		}
+	}
+}
+
+// Stop stops the scheduler
+func (s *GoogleSheetsSyncScheduler) Stop() {
+	close(s.stopChan)
+}
+
+// syncAll syncs every client whose sheet doesn't have yesterday's row yet
+func (s *GoogleSheetsSyncScheduler) syncAll(ctx context.Context) {
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(googleSheetsDateLayout)
+	cursor, err := s.db.Collection("clients").Find(ctx, bson.M{
+		"google_sheets.refresh_token":    bson.M{"$nin": bson.A{nil, ""}},
+		"google_sheets.last_synced_date": bson.M{"$not": bson.M{"$gte": yesterday}},
+	}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		logger.Error("Failed to load clients for Google Sheets sync", "error", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var client struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&client); err != nil {
+			continue
+		}
+		if err := syncGoogleSheet(ctx, s.cfg, s.db, client.ID); err != nil {
+			logger.Warn("Google Sheets sync failed", "error", err, "client_id", client.ID.Hex())
+		}
+	}
+}
+
+// handleGetGoogleSheets returns the client's Google Sheets export connection
+func handleGetGoogleSheets(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		response := gin.H{
+			"available": googleSheetsOAuthConfig(cfg) != nil,
+			"connected": false,
+		}
+		if settings := clientDoc.GoogleSheets; settings != nil && settings.RefreshToken != "" {
+			response["connected"] = true
+			response["google_sheets"] = settings
+			response["spreadsheet_url"] = "https://docs.google.com/spreadsheets/d/" + settings.SpreadsheetID
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// handleConnectGoogleSheets starts the OAuth consent flow for exporting analytics to a spreadsheet.
+// The dashboard sends the user to the returned auth_url; Google redirects back to the public callback.
+func handleConnectGoogleSheets(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		oauthCfg := googleSheetsOAuthConfig(cfg)
+		if oauthCfg == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error_code": "google_sheets_not_configured",
+				"message":    "Google Sheets export is not available on this platform",
+			})
+			return
+		}
+
+		var request struct {
+			Spreadsheet string `json:"spreadsheet" binding:"required"` // spreadsheet ID or URL
+			SheetName   string `json:"sheet_name"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		spreadsheetID, ok := parseSpreadsheetID(request.Spreadsheet)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_spreadsheet",
+				"message":    "Provide a Google Sheets URL or spreadsheet ID",
+			})
+			return
+		}
+		sheetName := strings.TrimSpace(request.SheetName)
+		if sheetName == "" {
+			sheetName = googleSheetsDefaultSheet
+		}
+		if len([]rune(sheetName)) > maxGoogleSheetNameLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_sheet_name",
+				"message":    fmt.Sprintf("Sheet name must be at most %d characters", maxGoogleSheetNameLength),
+			})
+			return
+		}
+
+		state, err := signGoogleSheetsState(cfg.JWTSecret, googleSheetsState{
+			ClientID:      userClientID,
+			SpreadsheetID: spreadsheetID,
+			SheetName:     sheetName,
+			ExpiresAt:     time.Now().Add(googleSheetsStateTTL).Unix(),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "state_generation_failed",
+				"message":    "Failed to start Google authorization",
+			})
+			return
+		}
+
+		// Offline access with forced consent so Google always returns a refresh token
+		authURL := oauthCfg.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
+		c.JSON(http.StatusOK, gin.H{
+			"auth_url": authURL,
+		})
+	}
+}
+
+// handleGoogleSheetsCallback completes the consent flow: it stores the client's tokens, makes sure the
+// export tab exists and starts the backfill. Google sends the user's browser here, so it answers with HTML.
+func handleGoogleSheetsCallback(cfg *config.Config, db *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		oauthCfg := googleSheetsOAuthConfig(cfg)
+		if oauthCfg == nil {
+			respondGoogleSheetsCallback(c, http.StatusServiceUnavailable, "Google Sheets export is not available on this platform.")
+			return
+		}
+		if reason := c.Query("error"); reason != "" {
+			respondGoogleSheetsCallback(c, http.StatusBadRequest, "Google authorization was not granted ("+reason+").")
+			return
+		}
+
+		state, err := verifyGoogleSheetsState(cfg.JWTSecret, c.Query("state"), time.Now())
+		if err != nil {
+			respondGoogleSheetsCallback(c, http.StatusBadRequest, "This authorization link is invalid or has expired. Start again from the dashboard.")
+			return
+		}
+		clientObjID, err := primitive.ObjectIDFromHex(state.ClientID)
+		if err != nil {
+			respondGoogleSheetsCallback(c, http.StatusBadRequest, "This authorization link is invalid.")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+		defer cancel()
+
+		token, err := oauthCfg.Exchange(ctx, c.Query("code"))
+		if err != nil {
+			respondGoogleSheetsCallback(c, http.StatusBadGateway, "Google rejected the authorization. Please try again.")
+			return
+		}
+		if token.RefreshToken == "" {
+			respondGoogleSheetsCallback(c, http.StatusBadGateway, "Google did not grant offline access. Please try again.")
+			return
+		}
+
+		settings := &models.GoogleSheetsSettings{
+			SpreadsheetID: state.SpreadsheetID,
+			SheetName:     state.SheetName,
+			RefreshToken:  token.RefreshToken,
+			AccessToken:   token.AccessToken,
+			TokenExpiry:   token.Expiry,
+			ConnectedAt:   time.Now(),
+		}
+		service, _, err := newSheetsService(ctx, oauthCfg, settings)
+		if err == nil {
+			err = ensureSheetTab(ctx, service, settings.SpreadsheetID, settings.SheetName)
+		}
+		if err != nil {
+			revokeGoogleToken(ctx, token.RefreshToken)
+			respondGoogleSheetsCallback(c, http.StatusBadRequest, "The spreadsheet could not be opened with this Google account. Check that you can edit it and try again.")
+			return
+		}
+
+		// ✅ NEW: Store the tokens encrypted
+		stored := *settings
+		if stored.RefreshToken, err = sealGoogleSheetsToken(cfg, settings.RefreshToken); err == nil {
+			stored.AccessToken, err = sealGoogleSheetsToken(cfg, settings.AccessToken)
+		}
+		if err != nil {
+			logger.Error("Failed to encrypt Google Sheets tokens", "error", err, "client_id", clientObjID.Hex())
+			revokeGoogleToken(ctx, token.RefreshToken)
+			respondGoogleSheetsCallback(c, http.StatusInternalServerError, "Failed to save the connection. Please try again.")
+			return
+		}
+
+		result, err := db.Collection("clients").UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$set": bson.M{
+				"google_sheets": &stored,
+				"updated_at":    time.Now(),
+			},
+		})
+		invalidateClientConfig(clientObjID)
+		if err != nil || result.MatchedCount == 0 {
+			revokeGoogleToken(ctx, token.RefreshToken)
+			respondGoogleSheetsCallback(c, http.StatusInternalServerError, "Failed to save the connection. Please try again.")
+			return
+		}
+
+		go func() {
+			backfillCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			if err := syncGoogleSheet(backfillCtx, cfg, db, clientObjID); err != nil {
+				logger.Warn("Google Sheets backfill failed", "error", err, "client_id", clientObjID.Hex())
+			}
+		}()
+
+		respondGoogleSheetsCallback(c, http.StatusOK, "Google Sheet connected. Past analytics are being added now; you can close this window.")
+	}
+}
+
+// respondGoogleSheetsCallback renders the small page shown at the end of the consent flow
+func respondGoogleSheetsCallback(c *gin.Context, status int, message string) {
+	page := "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>Google Sheets</title></head><body><p>" +
+		html.EscapeString(message) + "</p></body></html>"
+	c.Data(status, "text/html; charset=utf-8", []byte(page))
+}
+
+// revokeGoogleToken asks Google to invalidate a token; failures are only logged since the token is discarded anyway
+func revokeGoogleToken(ctx context.Context, token string) {
+	if token == "" {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleSheetsRevokeEndpoint, strings.NewReader(url.Values{"token": {token}}.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger.Warn("Failed to revoke Google token", "error", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// handleDisconnectGoogleSheets stops the export and revokes the client's Google token
+func handleDisconnectGoogleSheets(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		var previous models.Client
+		err = clientsCollection.FindOneAndUpdate(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$unset": bson.M{"google_sheets": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		}).Decode(&previous)
+		invalidateClientConfig(clientObjID)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to disconnect Google Sheets",
+			})
+			return
+		}
+
+		if previous.GoogleSheets != nil {
+			if refreshToken, err := openGoogleSheetsToken(cfg, previous.GoogleSheets.RefreshToken); err == nil {
+				revokeGoogleToken(ctx, refreshToken)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Google Sheets disconnected",
+		})
+	}
+}
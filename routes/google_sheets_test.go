@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+func TestGoogleSheetsStateRoundTrip(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	state := googleSheetsState{ClientID: "abc", SpreadsheetID: "sheet", SheetName: "Daily", ExpiresAt: now.Add(time.Minute).Unix()}
+	signed, err := signGoogleSheetsState("secret", state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := verifyGoogleSheetsState("secret", signed, now)
+	if err != nil || *got != state {
+		t.Fatalf("verify = %+v, %v; want %+v", got, err, state)
+	}
+	if _, err := verifyGoogleSheetsState("other", signed, now); err == nil {
+		t.Error("state signed with another secret was accepted")
+	}
+	if _, err := verifyGoogleSheetsState("secret", signed, now.Add(2*time.Minute)); err == nil {
+		t.Error("expired state was accepted")
+	}
+	if _, err := verifyGoogleSheetsState("secret", "x"+signed, now); err == nil {
+		t.Error("tampered state was accepted")
+	}
+}
+
+func TestParseSpreadsheetID(t *testing.T) {
+	const id = "1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE2upms"
+	for _, input := range []string{id, " https://docs.google.com/spreadsheets/d/" + id + "/edit#gid=0 "} {
+		if got, ok := parseSpreadsheetID(input); !ok || got != id {
+			t.Errorf("parseSpreadsheetID(%q) = %q, %v", input, got, ok)
+		}
+	}
+	if _, ok := parseSpreadsheetID("https://example.com/not-a-sheet"); ok {
+		t.Error("accepted a non-spreadsheet URL")
+	}
+}
+
+func TestGoogleSheetsDaysToSync(t *testing.T) {
+	now := time.Date(2026, 3, 10, 8, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name      string
+		lastDate  string
+		createdAt time.Time
+		want      []time.Time
+	}{
+		{"backfill window", "", time.Time{}, []time.Time{day(7), day(8), day(9)}},
+		{"backfill not before client existed", "", day(8).Add(15 * time.Hour), []time.Time{day(8), day(9)}},
+		{"missed days", "2026-03-07", time.Time{}, []time.Time{day(8), day(9)}},
+		{"up to date", "2026-03-09", time.Time{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := googleSheetsDaysToSync(&models.GoogleSheetsSettings{LastSyncedDate: tt.lastDate}, tt.createdAt, now, 3)
+			if len(got) != len(tt.want) {
+				t.Fatalf("days = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("days = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGoogleSheetsTokenEncryption(t *testing.T) {
+	cfg := &config.Config{WebhookHeadersKey: "test-key"}
+	sealed, err := sealGoogleSheetsToken(cfg, "1//refresh-token")
+	if err != nil {
+		t.Fatalf("sealGoogleSheetsToken: %v", err)
+	}
+	if !isSealedGoogleSheetsToken(sealed) || strings.Contains(sealed, "refresh-token") {
+		t.Fatalf("sealed token = %q, want it encrypted", sealed)
+	}
+	if plain, err := openGoogleSheetsToken(cfg, sealed); err != nil || plain != "1//refresh-token" {
+		t.Errorf("openGoogleSheetsToken = %q, %v", plain, err)
+	}
+
+	// Tokens stored before encryption still work until the next sync re-stores them
+	if plain, err := openGoogleSheetsToken(cfg, "1//legacy"); err != nil || plain != "1//legacy" {
+		t.Errorf("legacy token = %q, %v", plain, err)
+	}
+	if _, err := openGoogleSheetsToken(&config.Config{WebhookHeadersKey: "other-key"}, sealed); err == nil {
+		t.Error("decrypting with a different key succeeded")
+	}
+}
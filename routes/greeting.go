@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// INSTANT GREETING REPLY
+// ===================
+
+// defaultGreetingPhrases are greetings recognised for every client, in English, Hindi/Hinglish and
+// a few other common widget languages. GREETING_PHRASES and Branding.GreetingPhrases add to these.
+var defaultGreetingPhrases = []string{
+	"hi", "hii", "hiii", "hello", "helo", "hey", "heya", "hiya", "yo", "howdy", "greetings",
+	"good morning", "good afternoon", "good evening", "good day", "morning", "evening",
+	"namaste", "namaskar", "pranam", "ram ram", "jai shri krishna", "sat sri akal", "salaam", "assalamualaikum",
+	"नमस्ते", "नमस्कार", "प्रणाम",
+	"hola", "buenos dias", "buenas tardes", "buenas noches", "bonjour", "salut", "hallo", "guten tag",
+	"ciao", "olá", "ola", "marhaba", "مرحبا", "السلام عليكم",
+}
+
+// greetingFillers may accompany a greeting without turning it into a question ("hi there", "hello team")
+var greetingFillers = map[string]bool{
+	"there": true, "team": true, "all": true, "everyone": true, "guys": true, "bot": true, "ji": true,
+	"sir": true, "madam": true, "maam": true, "friend": true, "again": true, "and": true,
+}
+
+const (
+	defaultGreetingReply = "Hi! How can I help you today?"
+	maxGreetingPhrases   = 50
+)
+
+// greetingPhrasesFor returns the greetings recognised for a client, longest first
+func greetingPhrasesFor(cfg *config.Config, branding models.Branding) [][]string {
+	var phrases [][]string
+	seen := make(map[string]bool)
+	for _, list := range [][]string{defaultGreetingPhrases, cfg.GreetingPhrases, branding.GreetingPhrases} {
+		for _, phrase := range list {
+			words := greetingWords(phrase)
+			key := strings.Join(words, " ")
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			phrases = append(phrases, words)
+		}
+	}
+	sort.SliceStable(phrases, func(i, j int) bool { return len(phrases[i]) > len(phrases[j]) })
+	return phrases
+}
+
+// greetingWords lowercases text and splits it into words, dropping punctuation and emoji
+func greetingWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r)
+	})
+}
+
+// isPureGreeting reports whether message consists only of greetings and filler words, e.g.
+// "Hi!", "hello there 👋" or "namaste ji". Anything else ("hi, what are your prices?") or a
+// question mark means the visitor wants an answer, so it is left to the AI.
+func isPureGreeting(message string, phrases [][]string) bool {
+	if strings.Contains(message, "?") {
+		return false
+	}
+
+	words := greetingWords(message)
+	if len(words) == 0 {
+		return false
+	}
+
+	greeted := false
+	for i := 0; i < len(words); {
+		matched := 0
+		for _, phrase := range phrases {
+			if len(phrase) <= len(words)-i && equalWords(words[i:i+len(phrase)], phrase) {
+				matched = len(phrase)
+				break
+			}
+		}
+		switch {
+		case matched > 0:
+			greeted = true
+			i += matched
+		case greetingFillers[words[i]]:
+			i++
+		default:
+			return false
+		}
+	}
+	return greeted
+}
+
+func equalWords(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// greetingReplyFor returns the client's welcome message (honouring the session's A/B variant)
+func greetingReplyFor(branding models.Branding, welcomeVariant string) string {
+	if variant := findWelcomeVariant(branding, welcomeVariant); variant != nil && variant.Text != "" {
+		return variant.Text
+	}
+	if branding.WelcomeMessage != "" {
+		return branding.WelcomeMessage
+	}
+	return defaultGreetingReply
+}
+
+// respondToGreeting answers a pure greeting with the canned welcome reply, without an AI call or token charge
+func respondToGreeting(ctx context.Context, c *gin.Context, messagesCollection *mongo.Collection, clientDoc *models.Client, req ChatRequest, welcomeVariant string) {
+	logger.Info("Public chat greeting answered without AI",
+		"client_id", clientDoc.ID.Hex(), "session_id", req.SessionID)
+
+	reply := greetingReplyFor(clientDoc.Branding, welcomeVariant)
+	messageID, err := persistMessage(ctx, messagesCollection, clientDoc.ID, req, reply, 0, nil, welcomeVariant, visitorTrackingFor(clientDoc, req), c.Request)
+	if err != nil {
+		logger.Warn("Failed to persist greeting message", "error", err, "client_id", clientDoc.ID.Hex(), "session_id", req.SessionID)
+	}
+
+	remainingTokens := clientDoc.TokenLimit - clientDoc.TokenUsed
+	if remainingTokens < 0 {
+		remainingTokens = 0
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reply":            reply,
+		"token_cost":       0,
+		"remaining_tokens": remainingTokens,
+		"conversation_id":  req.SessionID,
+		"message_id":       messageID.Hex(),
+		"latency_ms":       0,
+		"timestamp":        time.Now().Unix(),
+		"instant_greeting": true,
+	})
+}
@@ -0,0 +1,293 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// IMAGE-AWARE RESPONSES
+// ===================
+
+const (
+	maxImageTags         = 20
+	maxImageTagLength    = 50
+	maxImageCaptionLen   = 500
+	maxChatImages        = 3   // images returned with one chat reply
+	maxImagesScanned     = 500 // images considered per client when matching
+	minImageMatchScore   = 2
+	imageTagMatchScore   = 3
+	imageTokenMatchScore = 1
+)
+
+// imageMatchStopWords are ignored when matching titles and captions
+var imageMatchStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "you": true, "your": true, "are": true, "with": true,
+	"have": true, "has": true, "what": true, "about": true, "this": true, "that": true, "can": true,
+	"show": true, "see": true, "any": true, "our": true, "image": true, "photo": true, "picture": true,
+}
+
+// chatImage is the image payload returned with a chat reply
+type chatImage struct {
+	ID      string `json:"id"`
+	URL     string `json:"url"`
+	Title   string `json:"title"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// imageMatchTokens splits text into lowercase words of 3+ characters, dropping stop words
+// and a trailing plural "s" so that "sofas" matches "sofa"
+func imageMatchTokens(text string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(word)) < 3 || imageMatchStopWords[word] {
+			continue
+		}
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = strings.TrimSuffix(word, "s")
+		}
+		tokens[word] = true
+	}
+	return tokens
+}
+
+// normalizeImageTags lowercases, trims and de-duplicates tags, rejecting oversized input
+func normalizeImageTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxImageTagLength {
+			return nil, fmt.Errorf("tag %q exceeds %d characters", tag, maxImageTagLength)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxImageTags {
+		return nil, fmt.Errorf("at most %d tags are allowed per image", maxImageTags)
+	}
+	return normalized, nil
+}
+
+// scoreImageMatch rates how relevant an image is to a message. Tags are matched as whole
+// words or phrases; title and caption words add a smaller score each.
+func scoreImageMatch(image *models.Image, message string, messageTokens map[string]bool) int {
+	score := 0
+	padded := " " + strings.Join(strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ") + " "
+
+	for _, tag := range image.Tags {
+		tagTokens := imageMatchTokens(tag)
+		if strings.Contains(padded, " "+tag+" ") {
+			score += imageTagMatchScore
+			continue
+		}
+		// Allow single-word tags to match their plural/singular form
+		if len(tagTokens) == 1 {
+			for token := range tagTokens {
+				if messageTokens[token] {
+					score += imageTagMatchScore
+				}
+			}
+		}
+	}
+
+	titleTokens := imageMatchTokens(image.Title)
+	titleMatches := 0
+	for token := range titleTokens {
+		if messageTokens[token] {
+			titleMatches++
+		}
+	}
+	score += titleMatches * imageTokenMatchScore
+	// A fully matched title is a strong signal even when it is a single word
+	if len(titleTokens) > 0 && titleMatches == len(titleTokens) && score < minImageMatchScore {
+		score = minImageMatchScore
+	}
+
+	for token := range imageMatchTokens(image.Caption) {
+		if messageTokens[token] && !titleTokens[token] {
+			score += imageTokenMatchScore
+		}
+	}
+	return score
+}
+
+// matchRelevantImages returns up to maxChatImages of the client's images that match the message
+func matchRelevantImages(ctx context.Context, imagesCollection *mongo.Collection, clientID primitive.ObjectID, message string) []chatImage {
+	messageTokens := imageMatchTokens(message)
+	if len(messageTokens) == 0 {
+		return nil
+	}
+
+	cursor, err := imagesCollection.Find(ctx, bson.M{"client_id": clientID},
+		options.Find().SetLimit(maxImagesScanned).SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		logger.Warn("Failed to load images for matching", "client_id", clientID.Hex(), "error", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var images []models.Image
+	if err := cursor.All(ctx, &images); err != nil {
+		logger.Warn("Failed to decode images for matching", "client_id", clientID.Hex(), "error", err)
+		return nil
+	}
+
+	type scoredImage struct {
+		image *models.Image
+		score int
+	}
+	var matches []scoredImage
+	for i := range images {
+		if score := scoreImageMatch(&images[i], message, messageTokens); score >= minImageMatchScore {
+			matches = append(matches, scoredImage{image: &images[i], score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	if len(matches) > maxChatImages {
+		matches = matches[:maxChatImages]
+	}
+
+	result := make([]chatImage, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, chatImage{
+			ID:      m.image.ID.Hex(),
+			URL:     m.image.URL,
+			Title:   m.image.Title,
+			Caption: m.image.Caption,
+		})
+	}
+	return result
+}
+
+// handleUpdateImage updates an image's title, caption and tags for the authenticated client
+func handleUpdateImage(imagesCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		imageObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_image_id",
+				"message":    "Invalid image ID format",
+			})
+			return
+		}
+
+		var req struct {
+			Title   *string  `json:"title"`
+			Caption *string  `json:"caption"`
+			Tags    []string `json:"tags"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		set := bson.M{"updated_at": time.Now()}
+		if req.Title != nil {
+			title := strings.TrimSpace(*req.Title)
+			if title == "" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_title",
+					"message":    "Title cannot be empty",
+				})
+				return
+			}
+			set["title"] = title
+		}
+		if req.Caption != nil {
+			caption := strings.TrimSpace(*req.Caption)
+			if len(caption) > maxImageCaptionLen {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_caption",
+					"message":    fmt.Sprintf("Caption must be at most %d characters", maxImageCaptionLen),
+				})
+				return
+			}
+			set["caption"] = caption
+		}
+		if req.Tags != nil {
+			tags, err := normalizeImageTags(req.Tags)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_tags",
+					"message":    err.Error(),
+				})
+				return
+			}
+			set["tags"] = tags
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		var image models.Image
+		err = imagesCollection.FindOneAndUpdate(ctx,
+			bson.M{"_id": imageObjID, "client_id": clientObjID},
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&image)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "image_not_found",
+					"message":    "Image not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to update image",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Image updated successfully",
+			"image":   image,
+		})
+	}
+}
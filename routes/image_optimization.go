@@ -0,0 +1,354 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder for DecodeConfig
+	"image/jpeg"
+	"image/png"
+
+	"saas-chatbot-platform/internal/config"
+)
+
+// ===================
+// UPLOADED IMAGE OPTIMIZATION
+// ===================
+//
+// Uploaded JPEG and PNG images are decoded, turned upright according to their EXIF orientation,
+// scaled down to fit IMAGE_MAX_WIDTH x IMAGE_MAX_HEIGHT and re-encoded, which also drops all metadata
+// (EXIF, GPS, camera details, text chunks). A smaller thumbnail is stored alongside when configured.
+// With IMAGE_OPTIMIZATION_ENABLED=false images are still re-encoded to drop metadata, but not resized.
+// GIFs are stored unchanged so animations keep working; WebP can't be decoded with the standard
+// library, so only its EXIF and XMP chunks are removed.
+
+// maxImagePixels rejects decompression bombs before the full image is decoded
+const maxImagePixels = 50_000_000
+
+var errImageTooManyPixels = errors.New("image dimensions are too large")
+
+// optimizedImage is the stored form of an upload
+type optimizedImage struct {
+	Data      []byte
+	Ext       string
+	Width     int
+	Height    int
+	Thumbnail []byte // nil when no thumbnail is stored
+}
+
+// optimizeUploadedImage prepares an uploaded image of the given sniffed content type for storage
+func optimizeUploadedImage(cfg *config.Config, data []byte, contentType string) (*optimizedImage, error) {
+	ext := allowedImageTypes[contentType]
+	if contentType == "image/webp" {
+		stripped, err := stripWebPMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+		return &optimizedImage{Data: stripped, Ext: ext}, nil
+	}
+
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if imgConfig.Width*imgConfig.Height > maxImagePixels {
+		return nil, errImageTooManyPixels
+	}
+	if contentType == "image/gif" {
+		return &optimizedImage{Data: data, Ext: ext, Width: imgConfig.Width, Height: imgConfig.Height}, nil
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	img := toRGBA(decoded)
+	if contentType == "image/jpeg" {
+		img = orientImage(img, jpegOrientation(data))
+	}
+
+	if cfg.ImageOptimizationEnabled {
+		if w, h := fitWithin(img.Bounds().Dx(), img.Bounds().Dy(), cfg.ImageMaxWidth, cfg.ImageMaxHeight); w != img.Bounds().Dx() || h != img.Bounds().Dy() {
+			img = resizeImage(img, w, h)
+		}
+	}
+
+	out := &optimizedImage{Ext: ext, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if out.Data, err = encodeImage(img, contentType, cfg.ImageJPEGQuality); err != nil {
+		return nil, err
+	}
+
+	if cfg.ImageOptimizationEnabled && cfg.ImageThumbnailSize > 0 {
+		thumb := img
+		if w, h := fitWithin(img.Bounds().Dx(), img.Bounds().Dy(), cfg.ImageThumbnailSize, cfg.ImageThumbnailSize); w != img.Bounds().Dx() || h != img.Bounds().Dy() {
+			thumb = resizeImage(img, w, h)
+		}
+		if out.Thumbnail, err = encodeImage(thumb, contentType, cfg.ImageJPEGQuality); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func encodeImage(img *image.RGBA, contentType string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	} else {
+		if quality < 1 || quality > 100 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fitWithin scales width x height down, keeping the aspect ratio, to fit maxWidth x maxHeight.
+// A non-positive limit leaves that side unconstrained; images are never scaled up.
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight && float64(maxHeight)/float64(height) < scale {
+		scale = float64(maxHeight) / float64(height)
+	}
+	if scale == 1.0 {
+		return width, height
+	}
+	w, h := int(float64(width)*scale+0.5), int(float64(height)*scale+0.5)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// resampleWeight is one source pixel's share of a destination pixel
+type resampleWeight struct {
+	index  int
+	weight float32
+}
+
+// areaWeights returns, for each of dstLen output pixels, the source pixels it covers and how much
+func areaWeights(srcLen, dstLen int) [][]resampleWeight {
+	scale := float64(srcLen) / float64(dstLen)
+	weights := make([][]resampleWeight, dstLen)
+	for d := 0; d < dstLen; d++ {
+		start, end := float64(d)*scale, float64(d+1)*scale
+		for s := int(start); s < srcLen && float64(s) < end; s++ {
+			lo, hi := float64(s), float64(s+1)
+			if lo < start {
+				lo = start
+			}
+			if hi > end {
+				hi = end
+			}
+			weights[d] = append(weights[d], resampleWeight{index: s, weight: float32((hi - lo) / scale)})
+		}
+	}
+	return weights
+}
+
+// resizeImage downscales src to width x height by area averaging (premultiplied alpha, so
+// transparent pixels don't darken edges)
+func resizeImage(src *image.RGBA, width, height int) *image.RGBA {
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	xWeights, yWeights := areaWeights(srcW, width), areaWeights(srcH, height)
+
+	// Horizontal pass into a float buffer of width x srcH
+	tmp := make([]float32, width*srcH*4)
+	for y := 0; y < srcH; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x, ws := range xWeights {
+			var r, g, b, a float32
+			for _, w := range ws {
+				p := row[w.index*4:]
+				r += float32(p[0]) * w.weight
+				g += float32(p[1]) * w.weight
+				b += float32(p[2]) * w.weight
+				a += float32(p[3]) * w.weight
+			}
+			t := tmp[(y*width+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, b, a
+		}
+	}
+
+	// Vertical pass into the destination
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, ws := range yWeights {
+		for x := 0; x < width; x++ {
+			var r, g, b, a float32
+			for _, w := range ws {
+				t := tmp[(w.index*width+x)*4:]
+				r += t[0] * w.weight
+				g += t[1] * w.weight
+				b += t[2] * w.weight
+				a += t[3] * w.weight
+			}
+			p := dst.Pix[y*dst.Stride+x*4:]
+			p[0], p[1], p[2], p[3] = clampUint8(r), clampUint8(g), clampUint8(b), clampUint8(a)
+		}
+	}
+	return dst
+}
+
+func clampUint8(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 when it has none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image: no more metadata
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the Orientation tag from IFD0 of a TIFF-structured EXIF block
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 { // Orientation, SHORT
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orientImage applies an EXIF orientation so the image is stored upright
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // needs 90 degrees clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs 90 degrees counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+	return dst
+}
+
+// stripWebPMetadata removes the EXIF and XMP chunks from a WebP file and clears their VP8X flags
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("invalid WebP file")
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errors.New("invalid WebP file")
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2 // chunks are padded to an even length
+		if end > len(data) {
+			if i+8+size == len(data) { // tolerate a missing final pad byte
+				end = len(data)
+			} else {
+				return nil, errors.New("invalid WebP file")
+			}
+		}
+		if fourCC != "EXIF" && fourCC != "XMP " {
+			chunk := append([]byte(nil), data[i:end]...)
+			if fourCC == "VP8X" && size >= 1 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+			out = append(out, chunk...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"saas-chatbot-platform/internal/config"
+)
+
+// jpegWithOrientation encodes a width x height JPEG carrying an EXIF Orientation tag
+func jpegWithOrientation(t *testing.T, width, height, orientation int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Big-endian TIFF with one IFD0 entry: Orientation (0x0112), SHORT, count 1
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	binary.BigEndian.PutUint16(tiff[18:], uint16(orientation))
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestOptimizeUploadedImage_OrientsResizesAndStripsEXIF(t *testing.T) {
+	cfg := &config.Config{ImageOptimizationEnabled: true, ImageMaxWidth: 100, ImageMaxHeight: 100, ImageJPEGQuality: 80, ImageThumbnailSize: 20}
+	data := jpegWithOrientation(t, 200, 100, 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation = %d, want 6", got)
+	}
+
+	optimized, err := optimizeUploadedImage(cfg, data, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rotated upright to 100x200, then fitted within 100x100
+	if optimized.Width != 50 || optimized.Height != 100 {
+		t.Errorf("size = %dx%d, want 50x100", optimized.Width, optimized.Height)
+	}
+	if bytes.Contains(optimized.Data, []byte("Exif\x00\x00")) {
+		t.Error("optimized image still contains EXIF")
+	}
+	thumb, _, err := image.DecodeConfig(bytes.NewReader(optimized.Thumbnail))
+	if err != nil || thumb.Width != 10 || thumb.Height != 20 {
+		t.Errorf("thumbnail = %dx%d (err %v), want 10x20", thumb.Width, thumb.Height, err)
+	}
+}
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct{ w, h, maxW, maxH, wantW, wantH int }{
+		{800, 600, 1600, 1600, 800, 600},
+		{3200, 1600, 1600, 1600, 1600, 800},
+		{1000, 4000, 1600, 1600, 400, 1600},
+		{5000, 10, 100, 100, 100, 1},
+		{3000, 2000, 0, 1000, 1500, 1000},
+	}
+	for _, tt := range tests {
+		if w, h := fitWithin(tt.w, tt.h, tt.maxW, tt.maxH); w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitWithin(%d, %d, %d, %d) = %d, %d, want %d, %d", tt.w, tt.h, tt.maxW, tt.maxH, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestStripWebPMetadata(t *testing.T) {
+	chunk := func(fourCC string, payload []byte) []byte {
+		c := append([]byte(fourCC), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(c[4:], uint32(len(payload)))
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x08 | 0x04 | 0x10 // EXIF, XMP and alpha flags
+	body := append([]byte("WEBP"), chunk("VP8X", vp8x)...)
+	body = append(body, chunk("VP8L", []byte{1, 2, 3})...)
+	body = append(body, chunk("EXIF", []byte("gps data"))...)
+	body = append(body, chunk("XMP ", []byte("<x:xmpmeta/>"))...)
+	data := append([]byte("RIFF\x00\x00\x00\x00"), body...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(body)))
+
+	stripped, err := stripWebPMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte("EXIF")) || bytes.Contains(stripped, []byte("XMP ")) {
+		t.Error("metadata chunks were not removed")
+	}
+	if flags := stripped[20]; flags != 0x10 {
+		t.Errorf("VP8X flags = %#x, want 0x10", flags)
+	}
+	if size := binary.LittleEndian.Uint32(stripped[4:]); int(size) != len(stripped)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(stripped)-8)
+	}
+}
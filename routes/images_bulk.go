@@ -0,0 +1,354 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// BULK IMAGE UPLOAD
+// ===================
+
+// maxBulkImageUploads bounds the number of files accepted in one request
+const maxBulkImageUploads = 50
+
+// imageUploadDir is served by the router's /uploads static route
+const imageUploadDir = "uploads/images"
+
+// allowedImageTypes maps accepted content types (sniffed from the file) to the stored extension
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// deleteImage removes a client's image row and, for uploaded images, its stored file and thumbnail.
+// It reports whether an image was found.
+func deleteImage(ctx context.Context, imagesCollection *mongo.Collection, imageID, clientID primitive.ObjectID) (bool, error) {
+	var image models.Image
+	err := imagesCollection.FindOneAndDelete(ctx, bson.M{
+		"_id":       imageID,
+		"client_id": clientID,
+	}).Decode(&image)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, url := range []string{image.URL, image.ThumbnailURL} {
+		if path, ok := storedImagePath(url, clientID); ok {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				logger.Warn("Failed to remove stored image file", "client_id", clientID.Hex(), "path", path, "error", err)
+			}
+		}
+	}
+	return true, nil
+}
+
+// storedImagePath maps an uploaded image URL to its file in the client's upload directory.
+// External URLs and anything outside that directory are not touched.
+func storedImagePath(url string, clientID primitive.ObjectID) (string, bool) {
+	prefix := fmt.Sprintf("/%s/%s/", imageUploadDir, clientID.Hex())
+	name := strings.TrimPrefix(url, prefix)
+	if url == "" || name == url || name == "" || strings.ContainsAny(name, `/\`) || name == ".." {
+		return "", false
+	}
+	return filepath.Join(imageUploadDir, clientID.Hex(), name), true
+}
+
+// bulkImageResult reports the outcome for one uploaded file
+type bulkImageResult struct {
+	Filename  string        `json:"filename"`
+	Success   bool          `json:"success"`
+	Image     *models.Image `json:"image,omitempty"`
+	ErrorCode string        `json:"error_code,omitempty"`
+	Message   string        `json:"message,omitempty"`
+}
+
+// remainingImageSlots returns how many more images the client may add and its image limit
+func remainingImageSlots(ctx context.Context, cfg *config.Config, clientsCollection, imagesCollection *mongo.Collection, clientID primitive.ObjectID) (int, int, error) {
+	maxImages := cfg.DefaultMaxImages
+	var client models.Client
+	if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientID}).Decode(&client); err == nil && client.MaxImages > 0 {
+		maxImages = client.MaxImages
+	}
+	if maxImages <= 0 {
+		return maxBulkImageUploads, 0, nil // no limit configured
+	}
+
+	count, err := imagesCollection.CountDocuments(ctx, bson.M{"client_id": clientID})
+	if err != nil {
+		return 0, maxImages, err
+	}
+	return maxImages - int(count), maxImages, nil
+}
+
+// detectImageType sniffs the file's content type and returns the stored extension if it is allowed
+func detectImageType(file *multipart.FileHeader) (string, string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	contentType := http.DetectContentType(head[:n])
+	return contentType, allowedImageTypes[contentType], nil
+}
+
+// readAndOptimizeImage reads an uploaded file and prepares it for storage
+func readAndOptimizeImage(cfg *config.Config, file *multipart.FileHeader, contentType string) (*optimizedImage, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, cfg.MaxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	return optimizeUploadedImage(cfg, data, contentType)
+}
+
+// imageTitleFromFilename derives a default title from the uploaded file name
+func imageTitleFromFilename(filename string) string {
+	title := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	title = strings.TrimSpace(strings.NewReplacer("_", " ", "-", " ").Replace(title))
+	if title == "" {
+		return "Image"
+	}
+	return title
+}
+
+// handleBulkUploadImages uploads several images in one multipart request.
+// Files are sent as repeated "images" fields; optional "titles", "captions" and "tags"
+// (comma-separated) fields apply to the files in the same order.
+// Each file is validated and stored independently so that a partial batch still succeeds.
+func handleBulkUploadImages(cfg *config.Config, clientsCollection, imagesCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_form",
+				"message":    "Failed to parse multipart form",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		files := form.File["images"]
+		if len(files) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "no_files",
+				"message":    "At least one image is required in the \"images\" field",
+			})
+			return
+		}
+		if len(files) > maxBulkImageUploads {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "too_many_files",
+				"message":    fmt.Sprintf("Maximum %d images allowed per bulk upload", maxBulkImageUploads),
+			})
+			return
+		}
+		titles := form.Value["titles"]
+		captions := form.Value["captions"]
+		tagLists := form.Value["tags"]
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+		defer cancel()
+
+		remaining, maxImages, err := remainingImageSlots(ctx, cfg, clientsCollection, imagesCollection, clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to check image limit",
+			})
+			return
+		}
+
+		clientDir := filepath.Join(imageUploadDir, clientObjID.Hex())
+		if err := os.MkdirAll(clientDir, 0755); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to create upload directory",
+			})
+			return
+		}
+
+		results := make([]bulkImageResult, 0, len(files))
+		uploaded := 0
+		for i, file := range files {
+			result := bulkImageResult{Filename: file.Filename}
+
+			if uploaded >= remaining {
+				result.ErrorCode = "image_limit_exceeded"
+				result.Message = fmt.Sprintf("Image limit reached (%d images)", maxImages)
+				results = append(results, result)
+				continue
+			}
+
+			if file.Size > cfg.MaxImageSize {
+				result.ErrorCode = "file_too_large"
+				result.Message = fmt.Sprintf("File size must be at most %d bytes", cfg.MaxImageSize)
+				results = append(results, result)
+				continue
+			}
+
+			contentType, ext, err := detectImageType(file)
+			if err != nil {
+				result.ErrorCode = "invalid_file"
+				result.Message = "Failed to read file"
+				results = append(results, result)
+				continue
+			}
+			if ext == "" {
+				result.ErrorCode = "invalid_file_type"
+				result.Message = fmt.Sprintf("Only JPEG, PNG, GIF and WebP images are allowed (got %s)", contentType)
+				results = append(results, result)
+				continue
+			}
+
+			var tags []string
+			if i < len(tagLists) {
+				if tags, err = normalizeImageTags(strings.Split(tagLists[i], ",")); err != nil {
+					result.ErrorCode = "invalid_tags"
+					result.Message = err.Error()
+					results = append(results, result)
+					continue
+				}
+			}
+			caption := ""
+			if i < len(captions) {
+				caption = strings.TrimSpace(captions[i])
+				if len(caption) > maxImageCaptionLen {
+					result.ErrorCode = "invalid_caption"
+					result.Message = fmt.Sprintf("Caption must be at most %d characters", maxImageCaptionLen)
+					results = append(results, result)
+					continue
+				}
+			}
+
+			// ✅ NEW: Resize, strip metadata and build a thumbnail before storing
+			optimized, err := readAndOptimizeImage(cfg, file, contentType)
+			if err != nil {
+				result.ErrorCode = "invalid_image"
+				result.Message = "Image could not be processed"
+				if errors.Is(err, errImageTooManyPixels) {
+					result.ErrorCode = "image_dimensions_too_large"
+					result.Message = fmt.Sprintf("Image must be at most %d megapixels", maxImagePixels/1_000_000)
+				}
+				results = append(results, result)
+				continue
+			}
+
+			imageID := primitive.NewObjectID()
+			filename := imageID.Hex() + optimized.Ext
+			if err := os.WriteFile(filepath.Join(clientDir, filename), optimized.Data, 0644); err != nil {
+				result.ErrorCode = "file_save_error"
+				result.Message = "Failed to save image file"
+				results = append(results, result)
+				continue
+			}
+			thumbnailURL := ""
+			thumbnailName := imageID.Hex() + "_thumb" + optimized.Ext
+			if optimized.Thumbnail != nil {
+				if err := os.WriteFile(filepath.Join(clientDir, thumbnailName), optimized.Thumbnail, 0644); err == nil {
+					thumbnailURL = fmt.Sprintf("/%s/%s/%s", imageUploadDir, clientObjID.Hex(), thumbnailName)
+				}
+			}
+
+			title := imageTitleFromFilename(file.Filename)
+			if i < len(titles) && strings.TrimSpace(titles[i]) != "" {
+				title = strings.TrimSpace(titles[i])
+			}
+
+			image := models.Image{
+				ID:        imageID,
+				ClientID:  clientObjID,
+				URL:       fmt.Sprintf("/%s/%s/%s", imageUploadDir, clientObjID.Hex(), filename),
+				Title:     title,
+				Caption:   caption,
+				Tags:      tags,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+
+				ThumbnailURL: thumbnailURL,
+				Width:        optimized.Width,
+				Height:       optimized.Height,
+			}
+			if _, err := imagesCollection.InsertOne(ctx, image); err != nil {
+				os.Remove(filepath.Join(clientDir, filename))
+				if thumbnailURL != "" {
+					os.Remove(filepath.Join(clientDir, thumbnailName))
+				}
+				result.ErrorCode = "database_error"
+				result.Message = "Failed to add image"
+				results = append(results, result)
+				continue
+			}
+
+			result.Success = true
+			result.Image = &image
+			results = append(results, result)
+			uploaded++
+		}
+
+		status := http.StatusOK
+		message := "Images uploaded successfully"
+		if uploaded == 0 {
+			status = http.StatusBadRequest
+			message = "No images were uploaded"
+		} else if uploaded < len(files) {
+			message = "Some images failed to upload"
+		}
+
+		c.JSON(status, gin.H{
+			"message":        message,
+			"results":        results,
+			"uploaded_count": uploaded,
+			"failed_count":   len(files) - uploaded,
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestStoredImagePath(t *testing.T) {
+	clientID := primitive.NewObjectID()
+	other := primitive.NewObjectID()
+	base := "/" + imageUploadDir + "/" + clientID.Hex() + "/"
+
+	if got, ok := storedImagePath(base+"abc.jpg", clientID); !ok || got != filepath.Join(imageUploadDir, clientID.Hex(), "abc.jpg") {
+		t.Errorf("uploaded image = %q, %v", got, ok)
+	}
+	for _, url := range []string{
+		"",
+		"https://cdn.example.com/abc.jpg",
+		"/" + imageUploadDir + "/" + other.Hex() + "/abc.jpg",
+		base + "../" + other.Hex() + "/abc.jpg",
+		base + "..",
+		base,
+	} {
+		if got, ok := storedImagePath(url, clientID); ok {
+			t.Errorf("storedImagePath(%q) = %q, want no local file", url, got)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package routes
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/ai"
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+	"saas-chatbot-platform/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// TELEGRAM BOT INTEGRATION
+// ===================
+
+// telegramSessionID maps a Telegram chat to a stable conversation/session ID
+func telegramSessionID(chatID int64) string {
+	return fmt.Sprintf("telegram_%d", chatID)
+}
+
+// handleTelegramWebhook receives bot updates for a client, answers them with the
+// client's knowledge base and replies through the Bot API.
+// Telegram retries non-2xx responses, so once the update is authenticated we always
+// acknowledge it and process the message in the background.
+func handleTelegramWebhook(cfg *config.Config, aiPool *ai.ClientPool, db *mongo.Database, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientOID, err := primitive.ObjectIDFromHex(c.Param("client_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		var client models.Client
+		err = clientsCollection.FindOne(ctx, bson.M{"_id": clientOID}).Decode(&client)
+		if err != nil || !client.TelegramBotEnabled || client.TelegramBotToken == "" || client.TelegramWebhookSecret == "" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "integration_not_found",
+				"message":    "Telegram integration not configured",
+			})
+			return
+		}
+
+		// Validate the per-client webhook secret
+		secret := c.GetHeader("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(client.TelegramWebhookSecret)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error_code": "invalid_webhook_secret",
+				"message":    "Invalid webhook secret",
+			})
+			return
+		}
+
+		var update services.TelegramUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid update payload",
+			})
+			return
+		}
+
+		// Only plain text messages from users are answered
+		if update.Message == nil || strings.TrimSpace(update.Message.Text) == "" ||
+			(update.Message.From != nil && update.Message.From.IsBot) {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+			return
+		}
+
+		go processTelegramMessage(cfg, aiPool, db, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection, &client, update.Message)
+
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
+
+// processTelegramMessage generates and sends the reply for one inbound Telegram message
+func processTelegramMessage(cfg *config.Config, aiPool *ai.ClientPool, db *mongo.Database, clientsCollection, pdfsCollection, messagesCollection, crawlsCollection *mongo.Collection, client *models.Client, msg *services.TelegramMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	token, err := openIntegrationSecret(cfg, client.TelegramBotToken)
+	if err != nil {
+		logger.Error("Failed to decrypt Telegram bot token", "error", err, "client_id", client.ID.Hex())
+		return
+	}
+	bot := services.NewTelegramClient(token)
+	sessionID := telegramSessionID(msg.Chat.ID)
+	text := strings.TrimSpace(msg.Text)
+
+	// Telegram's /start command opens the chat; greet instead of sending it to the model
+	if text == "/start" {
+		greeting := "Hi! How can I help you today?"
+		if client.Branding.WelcomeMessage != "" {
+			greeting = client.Branding.WelcomeMessage
+		}
+		if err := bot.SendMessage(ctx, msg.Chat.ID, greeting); err != nil {
+			logger.Warn("Failed to send Telegram greeting", "error", err, "client_id", client.ID.Hex())
+		}
+		return
+	}
+	if len([]rune(text)) > 2000 {
+		text = string([]rune(text)[:2000])
+	}
+
+	if clientChatDisabled(client) || client.TokenUsed >= client.TokenLimit {
+		logger.Warn("Telegram message not answered: client unavailable or out of tokens",
+			"client_id", client.ID.Hex(), "status", client.Status)
+		if client.TokenUsed >= client.TokenLimit {
+			recordTokenLimitRejection(cfg, clientsCollection, client.ID, "telegram")
+		}
+		return
+	}
+
+	reply, tokenCost, _, meta, err := generateAIResponseWithMemory(ctx, cfg, aiPool, db, pdfsCollection, messagesCollection, crawlsCollection, client, text, sessionID, nil, false, personaHint{})
+	if err != nil {
+		logger.Error("Telegram AI response failed", "error", err, "client_id", client.ID.Hex(), "session_id", sessionID)
+		reply = mapToUserFriendlyError(err, "Failed to generate AI response").UserMessage
+		tokenCost = 0
+	}
+
+	// ✅ NEW: Mask profanity in the sent reply; the stored reply stays unfiltered
+	sent, _ := profanityFilterFor(cfg, client).mask(reply)
+	if err := bot.SendMessage(ctx, msg.Chat.ID, sent); err != nil {
+		logger.Warn("Failed to send Telegram reply", "error", err, "client_id", client.ID.Hex(), "session_id", sessionID)
+	}
+
+	if tokenCost == 0 {
+		return
+	}
+
+	fromName := "Telegram User"
+	if msg.From != nil {
+		fromName = strings.TrimSpace(msg.From.FirstName + " " + msg.From.LastName)
+		if fromName == "" && msg.From.Username != "" {
+			fromName = "@" + msg.From.Username
+		}
+	}
+
+	message := models.Message{
+		FromUserID:     primitive.NilObjectID,
+		FromName:       fromName,
+		Message:        text,
+		Reply:          reply,
+		Timestamp:      time.Now(),
+		ClientID:       client.ID,
+		ConversationID: sessionID,
+		SessionID:      sessionID,
+		TokenCost:      tokenCost,
+		Channel:        "telegram",
+	}
+	if meta != nil {
+		message.SourceAttribution = meta.SourceAttribution
+		message.Confidence = meta.Confidence
+		message.DemoBooking = meta.DemoBooking
+	}
+	if _, err := messagesCollection.InsertOne(ctx, message); err != nil {
+		logger.Warn("Failed to persist Telegram message", "error", err, "session_id", sessionID)
+	}
+
+	if err := updateTokenUsage(ctx, clientsCollection, client.ID, client.TokenLimit, tokenCost); err != nil {
+		logger.Warn("Failed to update token usage for Telegram message", "error", err, "client_id", client.ID.Hex())
+		return
+	}
+	evaluateTokenAlerts(cfg, clientsCollection, client.ID, client.TokenUsed+tokenCost, client.TokenLimit)
+}
+
+// handleGetTelegramBot returns the Telegram bot integration status for the authenticated client
+func handleGetTelegramBot(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		var client models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "client_not_found",
+					"message":    "Client not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch Telegram bot configuration",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"telegram_bot_enabled":    client.TelegramBotEnabled,
+			"telegram_bot_username":   client.TelegramBotUsername,
+			"telegram_bot_configured": client.TelegramBotToken != "",
+		})
+	}
+}
+
+// handleUpdateTelegramBot stores the bot token, generates a webhook secret and
+// registers the webhook with Telegram when PUBLIC_API_URL is configured
+func handleUpdateTelegramBot(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var request struct {
+			BotToken string `json:"bot_token"`
+			Enabled  *bool  `json:"enabled,omitempty"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+		defer cancel()
+
+		update := bson.M{
+			"$set": bson.M{
+				"updated_at": time.Now(),
+			},
+		}
+		response := gin.H{
+			"message": "Telegram bot configuration updated successfully",
+		}
+
+		if token := strings.TrimSpace(request.BotToken); token != "" {
+			bot := services.NewTelegramClient(token)
+			info, err := bot.GetMe(ctx)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_bot_token",
+					"message":    "Telegram rejected the bot token",
+				})
+				return
+			}
+
+			// ✅ NEW: The token is stored encrypted, like webhook header values
+			sealedToken, err := sealIntegrationSecret(cfg, token)
+			if err != nil {
+				logger.Error("Failed to encrypt Telegram bot token", "error", err, "client_id", clientObjID.Hex())
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "encryption_failed",
+					"message":    "Failed to store the bot token",
+				})
+				return
+			}
+
+			secret, err := utils.GenerateSecureRandomString(32)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error_code": "secret_generation_failed",
+					"message":    "Failed to generate webhook secret",
+				})
+				return
+			}
+
+			webhookPath := "/integrations/telegram/" + clientObjID.Hex()
+			if cfg.PublicAPIURL != "" {
+				if err := bot.SetWebhook(ctx, cfg.PublicAPIURL+webhookPath, secret); err != nil {
+					c.JSON(http.StatusBadGateway, gin.H{
+						"error_code": "webhook_registration_failed",
+						"message":    "Failed to register Telegram webhook",
+						"details":    err.Error(),
+					})
+					return
+				}
+				response["webhook_url"] = cfg.PublicAPIURL + webhookPath
+			} else {
+				// No public URL known: the webhook must be registered manually with this secret
+				response["webhook_path"] = webhookPath
+				response["webhook_secret"] = secret
+			}
+
+			update["$set"].(bson.M)["telegram_bot_token"] = sealedToken
+			update["$set"].(bson.M)["telegram_bot_username"] = info.Username
+			update["$set"].(bson.M)["telegram_webhook_secret"] = secret
+			update["$set"].(bson.M)["telegram_bot_enabled"] = true
+			response["telegram_bot_username"] = info.Username
+		}
+
+		if request.Enabled != nil {
+			update["$set"].(bson.M)["telegram_bot_enabled"] = *request.Enabled
+		}
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, update)
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update Telegram bot configuration",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
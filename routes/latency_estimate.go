@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// RESPONSE LATENCY ESTIMATE
+// ===================
+
+const (
+	latencySampleSize      = 100              // most recent successful responses considered
+	latencySampleWindow    = 24 * time.Hour   // ignore metrics older than this
+	latencyEstimateTTL     = 60 * time.Second // how long an estimate is served from memory
+	defaultLatencyEstimate = 3000             // ms, used when the client has no recent metrics
+
+	// maxLatencyEstimateCacheEntries bounds memory, since any client id can be requested publicly;
+	// expired entries are dropped first when it is reached
+	maxLatencyEstimateCacheEntries = 10000
+)
+
+// latencyEstimate summarises a client's recent response times for the widget's typing indicator
+type latencyEstimate struct {
+	EstimatedMs int  `json:"estimated_ms"` // median response time
+	AverageMs   int  `json:"average_ms"`
+	P90Ms       int  `json:"p90_ms"`
+	SampleSize  int  `json:"sample_size"`
+	IsDefault   bool `json:"is_default"` // true when there were no recent metrics
+	computedAt  time.Time
+}
+
+var (
+	latencyEstimateCache   = make(map[primitive.ObjectID]*latencyEstimate)
+	latencyEstimateCacheMu sync.Mutex
+)
+
+// cacheLatencyEstimate stores an estimate, sweeping expired entries once the cache is full
+func cacheLatencyEstimate(clientID primitive.ObjectID, estimate *latencyEstimate) {
+	latencyEstimateCacheMu.Lock()
+	defer latencyEstimateCacheMu.Unlock()
+	if len(latencyEstimateCache) >= maxLatencyEstimateCacheEntries {
+		for id, cached := range latencyEstimateCache {
+			if time.Since(cached.computedAt) >= latencyEstimateTTL {
+				delete(latencyEstimateCache, id)
+			}
+		}
+		if len(latencyEstimateCache) >= maxLatencyEstimateCacheEntries {
+			latencyEstimateCache = make(map[primitive.ObjectID]*latencyEstimate)
+		}
+	}
+	latencyEstimateCache[clientID] = estimate
+}
+
+// computeLatencyEstimate reads the client's recent successful performance metrics
+func computeLatencyEstimate(ctx context.Context, metricsCollection *mongo.Collection, clientID primitive.ObjectID) (*latencyEstimate, error) {
+	cursor, err := metricsCollection.Find(ctx, bson.M{
+		"client_id": clientID,
+		"status":    "success",
+		"timestamp": bson.M{"$gte": time.Now().Add(-latencySampleWindow)},
+	}, options.Find().
+		SetSort(bson.M{"timestamp": -1}).
+		SetLimit(latencySampleSize).
+		SetProjection(bson.M{"total_time_ms": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var metrics []models.PerformanceMetrics
+	if err := cursor.All(ctx, &metrics); err != nil {
+		return nil, err
+	}
+
+	estimate := &latencyEstimate{computedAt: time.Now()}
+	if len(metrics) == 0 {
+		estimate.EstimatedMs = defaultLatencyEstimate
+		estimate.AverageMs = defaultLatencyEstimate
+		estimate.P90Ms = defaultLatencyEstimate
+		estimate.IsDefault = true
+		return estimate, nil
+	}
+
+	times := make([]int, 0, len(metrics))
+	total := 0
+	for _, m := range metrics {
+		times = append(times, m.TotalTimeMs)
+		total += m.TotalTimeMs
+	}
+	sort.Ints(times)
+
+	estimate.SampleSize = len(times)
+	estimate.AverageMs = total / len(times)
+	estimate.EstimatedMs = times[len(times)/2]
+	estimate.P90Ms = times[(len(times)*9)/10]
+	return estimate, nil
+}
+
+// handlePublicLatencyEstimate returns the client's recent response latency so the widget can
+// set expectations while showing its typing indicator. Estimates are cached briefly in memory.
+func handlePublicLatencyEstimate(db *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientOID, err := primitive.ObjectIDFromHex(c.Param("client_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		latencyEstimateCacheMu.Lock()
+		cached, ok := latencyEstimateCache[clientOID]
+		latencyEstimateCacheMu.Unlock()
+		if ok && time.Since(cached.computedAt) < latencyEstimateTTL {
+			c.JSON(http.StatusOK, cached)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		estimate, err := computeLatencyEstimate(ctx, db.Collection("performance_metrics"), clientOID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to estimate response latency",
+			})
+			return
+		}
+
+		cacheLatencyEstimate(clientOID, estimate)
+
+		c.JSON(http.StatusOK, estimate)
+	}
+}
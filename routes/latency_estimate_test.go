@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCacheLatencyEstimateIsBounded(t *testing.T) {
+	latencyEstimateCacheMu.Lock()
+	latencyEstimateCache = make(map[primitive.ObjectID]*latencyEstimate)
+	latencyEstimateCacheMu.Unlock()
+
+	stale := time.Now().Add(-2 * latencyEstimateTTL)
+	for i := 0; i < maxLatencyEstimateCacheEntries; i++ {
+		cacheLatencyEstimate(primitive.NewObjectID(), &latencyEstimate{computedAt: stale})
+	}
+	fresh := primitive.NewObjectID()
+	cacheLatencyEstimate(fresh, &latencyEstimate{computedAt: time.Now()})
+
+	latencyEstimateCacheMu.Lock()
+	defer latencyEstimateCacheMu.Unlock()
+	if len(latencyEstimateCache) != 1 {
+		t.Errorf("cache size = %d after sweeping expired entries, want 1", len(latencyEstimateCache))
+	}
+	if _, ok := latencyEstimateCache[fresh]; !ok {
+		t.Error("newest estimate was not cached")
+	}
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ===================
+// DAILY MESSAGE QUOTA
+// ===================
+
+// dailyMessageKeyTTL keeps a day's counter a little past midnight UTC so late requests still find it
+const dailyMessageKeyTTL = 48 * time.Hour
+
+// dailyMessageKey is the Redis counter of a client's public chat messages for one UTC day
+func dailyMessageKey(clientID string, day time.Time) string {
+	return fmt.Sprintf("chat:daily_messages:%s:%s", clientID, day.UTC().Format("2006-01-02"))
+}
+
+// nextDailyReset returns the next UTC midnight, when daily message counts start over
+func nextDailyReset(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// reserveDailyMessage counts one message against the client's daily limit and reports whether it is within
+// the limit, along with today's count. Redis errors fail open so an outage doesn't take chat down.
+func reserveDailyMessage(ctx context.Context, rdb *redis.Client, clientID string, limit int) (int64, bool) {
+	key := dailyMessageKey(clientID, time.Now())
+	count, err := rdb.Incr(ctx, key).Result()
+	if err != nil {
+		logger.Warn("Failed to count daily messages", "error", err, "client_id", clientID)
+		return 0, true
+	}
+	if count == 1 {
+		rdb.Expire(ctx, key, dailyMessageKeyTTL)
+	}
+	if count > int64(limit) {
+		// Rejected messages don't count toward the day
+		rdb.Decr(ctx, key)
+		return count - 1, false
+	}
+	return count, true
+}
+
+// releaseDailyMessage returns a reserved message when no reply was produced
+func releaseDailyMessage(rdb *redis.Client, clientID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	rdb.Decr(ctx, dailyMessageKey(clientID, time.Now()))
+}
@@ -0,0 +1,297 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// FACETED MESSAGE SEARCH
+// ===================
+//
+// GET /client/messages/search?q=refund returns the matching chat messages (visitor message or bot reply,
+// newest first) with facet counts over all matches:
+//
+//	by_month     in the client's analytics timezone (YYYY-MM)
+//	by_feedback  latest feedback on the reply: positive, negative or none
+//	by_topic     topics found by extractTopics in the visitor messages; counted over the newest
+//	             maxMessageSearchTopicSample matches, since topics are not stored on messages
+//
+// Optional: from / to (YYYY-MM-DD or RFC3339), page, limit. Spam is left out.
+
+const (
+	minMessageSearchChars       = 2
+	maxMessageSearchChars       = 200
+	maxMessageSearchTopicSample = 5000
+)
+
+// messageSearchFacets is the $facet output of the search pipeline
+type messageSearchFacets struct {
+	Results []bson.M `bson:"results"`
+	Total   []struct {
+		Count int64 `bson:"count"`
+	} `bson:"total"`
+	ByMonth    []messageSearchBucket `bson:"by_month"`
+	ByFeedback []messageSearchBucket `bson:"by_feedback"`
+}
+
+// messageSearchBucket is one facet value with its message count
+type messageSearchBucket struct {
+	Value string `bson:"_id" json:"value"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// latestFeedbackLookup adds "feedback": [{feedback_type}] with the latest feedback on each message
+func latestFeedbackLookup() bson.D {
+	return bson.D{{Key: "$lookup", Value: bson.M{
+		"from":         "message_feedback",
+		"localField":   "_id",
+		"foreignField": "message_id",
+		"pipeline": bson.A{
+			bson.M{"$sort": bson.M{"timestamp": -1}},
+			bson.M{"$limit": 1},
+			bson.M{"$project": bson.M{"_id": 0, "feedback_type": 1}},
+		},
+		"as": "feedback",
+	}}}
+}
+
+// messageSearchPipeline builds the $facet pipeline for one page of results and the month/feedback counts
+func messageSearchPipeline(match bson.M, loc *time.Location, page, limit int) mongo.Pipeline {
+	feedbackType := bson.M{"$ifNull": bson.A{bson.M{"$first": "$feedback.feedback_type"}, "none"}}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$facet", Value: bson.M{
+			"results": bson.A{
+				bson.M{"$sort": bson.M{"timestamp": -1}},
+				bson.M{"$skip": (page - 1) * limit},
+				bson.M{"$limit": limit},
+				latestFeedbackLookup(),
+				bson.M{"$project": bson.M{
+					"_id":             1,
+					"conversation_id": 1,
+					"message":         1,
+					"reply":           1,
+					"timestamp":       1,
+					"channel":         1,
+					"feedback":        feedbackType,
+				}},
+			},
+			"total": bson.A{bson.M{"$count": "count"}},
+			"by_month": bson.A{
+				bson.M{"$group": bson.M{
+					"_id": bson.M{"$dateToString": bson.M{
+						"format":   "%Y-%m",
+						"date":     "$timestamp",
+						"timezone": loc.String(),
+					}},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+			"by_feedback": bson.A{
+				bson.M{"$project": bson.M{"_id": 1}},
+				latestFeedbackLookup(),
+				bson.M{"$group": bson.M{"_id": feedbackType, "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.M{"count": -1}},
+			},
+		}}},
+	}
+}
+
+// countMessageTopics tallies extractTopics over visitor messages ("general" when no topic is recognised)
+func countMessageTopics(messages []string) []messageSearchBucket {
+	counts := make(map[string]int64)
+	for _, message := range messages {
+		for _, topic := range extractTopics(message) {
+			counts[topic]++
+		}
+	}
+
+	buckets := make([]messageSearchBucket, 0, len(counts))
+	for topic, count := range counts {
+		buckets = append(buckets, messageSearchBucket{Value: topic, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Value < buckets[j].Value
+	})
+	return buckets
+}
+
+// handleMessageSearch searches the authenticated client's chat messages and returns facet counts
+func handleMessageSearch(clientsCollection, messagesCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		query := strings.TrimSpace(c.Query("q"))
+		if len([]rune(query)) < minMessageSearchChars || len([]rune(query)) > maxMessageSearchChars {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_query",
+				"message":    "q must be between 2 and 200 characters",
+			})
+			return
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		match := bson.M{
+			"client_id": clientObjID,
+			"spam":      bson.M{"$exists": false},
+			"$or":       bson.A{bson.M{"message": pattern}, bson.M{"reply": pattern}},
+		}
+
+		timeFilter := bson.M{}
+		if from := c.Query("from"); from != "" {
+			t, err := parseExportDate(from, false)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_date",
+					"message":    "Invalid from date. Use YYYY-MM-DD or RFC3339",
+				})
+				return
+			}
+			timeFilter["$gte"] = t
+		}
+		if to := c.Query("to"); to != "" {
+			t, err := parseExportDate(to, true)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_date",
+					"message":    "Invalid to date. Use YYYY-MM-DD or RFC3339",
+				})
+				return
+			}
+			timeFilter["$lte"] = t
+		}
+		if len(timeFilter) > 0 {
+			match["timestamp"] = timeFilter
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+		defer cancel()
+
+		loc := loadAnalyticsLocation(ctx, clientsCollection, clientObjID)
+
+		cursor, err := messagesCollection.Aggregate(ctx, messageSearchPipeline(match, loc, page, limit), options.Aggregate().SetAllowDiskUse(true))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to search messages",
+			})
+			return
+		}
+		var facets []messageSearchFacets
+		if err := cursor.All(ctx, &facets); err != nil || len(facets) == 0 {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to decode search results",
+			})
+			return
+		}
+		result := facets[0]
+
+		var total int64
+		if len(result.Total) > 0 {
+			total = result.Total[0].Count
+		}
+
+		// Topics are derived in Go, so they are counted over the newest matches only
+		topicCursor, err := messagesCollection.Find(ctx, match, options.Find().
+			SetSort(bson.M{"timestamp": -1}).
+			SetLimit(maxMessageSearchTopicSample).
+			SetProjection(bson.M{"message": 1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to count topics",
+			})
+			return
+		}
+		var topicMessages []struct {
+			Message string `bson:"message"`
+		}
+		if err := topicCursor.All(ctx, &topicMessages); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to count topics",
+			})
+			return
+		}
+		texts := make([]string, 0, len(topicMessages))
+		for _, m := range topicMessages {
+			texts = append(texts, m.Message)
+		}
+
+		results := make([]gin.H, 0, len(result.Results))
+		for _, doc := range result.Results {
+			entry := gin.H{}
+			for key, value := range doc {
+				if key == "_id" {
+					key = "id"
+				}
+				entry[key] = value
+			}
+			results = append(results, entry)
+		}
+
+		totalPages := (total + int64(limit) - 1) / int64(limit)
+
+		c.JSON(http.StatusOK, gin.H{
+			"query":   query,
+			"results": results,
+			"facets": gin.H{
+				"by_month":          result.ByMonth,
+				"by_feedback":       result.ByFeedback,
+				"by_topic":          countMessageTopics(texts),
+				"topic_sample_size": len(texts),
+			},
+			"timezone": loc.String(),
+			"pagination": gin.H{
+				"page":        page,
+				"limit":       limit,
+				"total":       total,
+				"total_pages": totalPages,
+			},
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountMessageTopics(t *testing.T) {
+	got := countMessageTopics([]string{
+		"What is the price?",
+		"Can I get a demo and a quote?",
+		"hello there",
+	})
+	want := []messageSearchBucket{
+		{Value: "pricing", Count: 2},
+		{Value: "demo", Count: 1},
+		{Value: "general", Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
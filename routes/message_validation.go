@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================
+// Chat message validation
+// ===================
+
+var (
+	errEmptyChatMessage   = errors.New("message is empty")
+	errChatMessageTooLong = errors.New("message is too long")
+)
+
+// isBlankRune reports whether r renders as nothing: unicode whitespace (incl. NBSP and the
+// ideographic space) plus zero-width characters that unicode.IsSpace doesn't cover
+func isBlankRune(r rune) bool {
+	if unicode.IsSpace(r) {
+		return true
+	}
+	switch r {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return true
+	}
+	return false
+}
+
+// validateChatMessage trims a visitor message and rejects it when nothing visible is left or it
+// exceeds maxLength characters (0 = unlimited). Emoji-only messages are valid.
+func validateChatMessage(message string, maxLength int) (string, error) {
+	trimmed := strings.TrimFunc(message, isBlankRune)
+	if trimmed == "" {
+		return "", errEmptyChatMessage
+	}
+	if maxLength > 0 && utf8.RuneCountInString(trimmed) > maxLength {
+		return "", errChatMessageTooLong
+	}
+	return trimmed, nil
+}
+
+// respondToInvalidChatMessage writes the 400 for a message rejected by validateChatMessage
+func respondToInvalidChatMessage(c *gin.Context, err error, maxLength int) {
+	if errors.Is(err, errChatMessageTooLong) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error_code": "message_too_long",
+			"message":    fmt.Sprintf("Message must be at most %d characters", maxLength),
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error_code": "empty_message",
+		"message":    "Message cannot be empty",
+	})
+}
@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateChatMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		max     int
+		want    string
+		wantErr error
+	}{
+		{"plain", "  hello  ", 100, "hello", nil},
+		{"empty", "", 100, "", errEmptyChatMessage},
+		{"ascii whitespace", " \t\r\n ", 100, "", errEmptyChatMessage},
+		{"no-break space", "\u00a0\u00a0", 100, "", errEmptyChatMessage},
+		{"ideographic space", "\u3000", 100, "", errEmptyChatMessage},
+		{"em and thin spaces", "\u2003\u2009 ", 100, "", errEmptyChatMessage},
+		{"line and paragraph separators", "\u2028\u2029", 100, "", errEmptyChatMessage},
+		{"zero-width characters", "\u200b\u200d\ufeff\u2060", 100, "", errEmptyChatMessage},
+		{"trims unicode whitespace", "\u3000hi there\u00a0", 100, "hi there", nil},
+		{"single emoji", "👍", 100, "👍", nil},
+		{"emoji with skin tone", " 👋\U0001f3fd ", 100, "👋\U0001f3fd", nil},
+		{"zwj emoji sequence", "👨\u200d👩\u200d👧", 100, "👨\u200d👩\u200d👧", nil},
+		{"flag emoji", "🇮🇳", 100, "🇮🇳", nil},
+		{"length counts characters not bytes", strings.Repeat("😀", 5), 5, strings.Repeat("😀", 5), nil},
+		{"too long", strings.Repeat("a", 6), 5, "", errChatMessageTooLong},
+		{"length ignores trimmed whitespace", "  abcde  ", 5, "abcde", nil},
+		{"unlimited", strings.Repeat("a", 10000), 0, strings.Repeat("a", 10000), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateChatMessage(tt.message, tt.max)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("validateChatMessage(%q) error = %v, want %v", tt.message, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("validateChatMessage(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
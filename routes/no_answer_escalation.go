@@ -0,0 +1,234 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// NO-ANSWER ESCALATION
+// ===================
+
+// maxNoAnswerEscalationThreshold caps how many unanswered turns a client can tolerate before the hand-off
+const maxNoAnswerEscalationThreshold = 20
+
+// noAnswerEscalationReply is appended to the reply when the hand-off is offered; the next message is
+// handled by the contact collection flow
+const noAnswerEscalationReply = "It looks like I haven't been able to answer your questions so far. I'd be happy to connect you with our team - may I have your name, please?"
+
+// isNoAnswerTurn reports whether a reply found nothing relevant in the client's knowledge
+func isNoAnswerTurn(confidence *models.ResponseConfidence) bool {
+	return confidence != nil && (confidence.NoInformation || !confidence.HasGoodMatches)
+}
+
+// noAnswerEscalationThreshold returns after how many consecutive unanswered turns the hand-off is offered
+// (0 = never). A negative client value disables it, 0 uses the platform default.
+func noAnswerEscalationThreshold(cfg *config.Config, client *models.Client) int {
+	switch {
+	case client.NoAnswerEscalationThreshold < 0:
+		return 0
+	case client.NoAnswerEscalationThreshold > 0:
+		return client.NoAnswerEscalationThreshold
+	}
+	return cfg.NoAnswerEscalationThreshold
+}
+
+// consecutiveNoAnswerTurns counts the unanswered turns that directly precede the current one, up to limit
+func consecutiveNoAnswerTurns(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, sessionID string, limit int) (int, error) {
+	cursor, err := messagesCollection.Find(ctx, bson.M{
+		"client_id":       clientID,
+		"conversation_id": sessionID,
+	}, options.Find().
+		SetSort(bson.M{"timestamp": -1}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"confidence": 1, "no_answer_escalation": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var recent []models.Message
+	if err := cursor.All(ctx, &recent); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, msg := range recent {
+		// An earlier hand-off restarts the count
+		if msg.NoAnswerEscalation || !isNoAnswerTurn(msg.Confidence) {
+			break
+		}
+		count++
+	}
+	return count, nil
+}
+
+// shouldOfferNoAnswerEscalation reports whether this reply completes a run of unanswered turns long enough
+// to offer the client's team, and contact details aren't already being collected
+func shouldOfferNoAnswerEscalation(ctx context.Context, cfg *config.Config, messagesCollection *mongo.Collection, client *models.Client, sessionID string, meta *aiResponseMeta) bool {
+	if meta == nil || !isNoAnswerTurn(meta.Confidence) {
+		return false
+	}
+	threshold := noAnswerEscalationThreshold(cfg, client)
+	if threshold <= 0 {
+		return false
+	}
+
+	if threshold > 1 {
+		previous, err := consecutiveNoAnswerTurns(ctx, messagesCollection, client.ID, sessionID, threshold-1)
+		if err != nil {
+			logger.Warn("Failed to count unanswered turns", "error", err, "client_id", client.ID.Hex(), "session_id", sessionID)
+			return false
+		}
+		if previous+1 < threshold {
+			return false
+		}
+	}
+
+	phase, chatDisabled, err := getContactCollectionState(ctx, messagesCollection, client.ID, sessionID)
+	if err != nil || chatDisabled || phase != "none" {
+		return false
+	}
+	return true
+}
+
+// handleGetNoAnswerEscalation returns the authenticated client's no-answer escalation threshold and how often it fired
+func handleGetNoAnswerEscalation(cfg *config.Config, clientsCollection, messagesCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		since := time.Now().AddDate(0, 0, -30)
+		offered, err := messagesCollection.CountDocuments(ctx, bson.M{
+			"client_id":            clientObjID,
+			"no_answer_escalation": true,
+			"timestamp":            bson.M{"$gte": since},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to count escalations",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"threshold":                clientDoc.NoAnswerEscalationThreshold,
+			"effective_threshold":      noAnswerEscalationThreshold(cfg, clientDoc),
+			"default_threshold":        cfg.NoAnswerEscalationThreshold,
+			"escalations_last_30_days": offered,
+		})
+	}
+}
+
+// handleUpdateNoAnswerEscalation sets after how many consecutive unanswered turns the hand-off is offered
+// (-1 = never, 0 = platform default)
+func handleUpdateNoAnswerEscalation(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var request struct {
+			Threshold *int `json:"threshold" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+		if *request.Threshold < -1 || *request.Threshold > maxNoAnswerEscalationThreshold {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_threshold",
+				"message":    fmt.Sprintf("threshold must be between -1 (never) and %d; 0 uses the platform default", maxNoAnswerEscalationThreshold),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$set": bson.M{
+				"no_answer_escalation_threshold": *request.Threshold,
+				"updated_at":                     time.Now(),
+			},
+		})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update no-answer escalation",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		updated := &models.Client{NoAnswerEscalationThreshold: *request.Threshold}
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "No-answer escalation updated",
+			"threshold":           *request.Threshold,
+			"effective_threshold": noAnswerEscalationThreshold(cfg, updated),
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// ACTIVE DOCUMENTS
+// ===================
+//
+// Only active, processed documents are used to answer visitors. PATCH /client/pdfs/:id/active
+// {"active": false} takes a document out of retrieval without deleting it; documents without the flag
+// (uploaded before it existed) count as active. PATCH /client/pdfs/status/bulk sets the same flag for many
+// documents. Documents that are still processing or failed are excluded as well.
+
+// nonRetrievablePDFStatuses are the document statuses left out of retrieval. "inactive" was written by
+// early versions of the bulk status update and is still honored for those documents.
+var nonRetrievablePDFStatuses = bson.A{
+	"inactive",
+	models.StatusPending,
+	models.StatusProcessing,
+	models.StatusFailed,
+	models.StatusCancelled,
+}
+
+// pdfIsActive reports whether the document's active toggle is on
+func pdfIsActive(pdf *models.PDF) bool {
+	return pdf.Active == nil || *pdf.Active
+}
+
+// retrievablePDFFilter matches the client's documents that may be used for answers
+func retrievablePDFFilter(clientID primitive.ObjectID) bson.M {
+	return bson.M{
+		"client_id": clientID,
+		"active":    bson.M{"$ne": false},
+		"status":    bson.M{"$nin": nonRetrievablePDFStatuses},
+	}
+}
+
+// excludedPDFIDs lists the client's documents that must not be used for answers, for filtering pdf_chunks
+func excludedPDFIDs(ctx context.Context, pdfsCollection *mongo.Collection, clientID primitive.ObjectID) ([]interface{}, error) {
+	return pdfsCollection.Distinct(ctx, "_id", bson.M{
+		"client_id": clientID,
+		"$or": bson.A{
+			bson.M{"active": false},
+			bson.M{"status": bson.M{"$in": nonRetrievablePDFStatuses}},
+		},
+	})
+}
+
+// handleSetPDFActive turns a document's use in answers on or off
+func handleSetPDFActive(pdfsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		pdfObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_pdf_id",
+				"message":    "Invalid PDF ID format",
+			})
+			return
+		}
+
+		var request struct {
+			Active *bool `json:"active" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_input",
+				"message":    "active (true or false) is required",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		result, err := pdfsCollection.UpdateOne(ctx,
+			bson.M{"_id": pdfObjID, "client_id": clientObjID},
+			bson.M{"$set": bson.M{"active": *request.Active, "updated_at": now}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update PDF",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "pdf_not_found",
+				"message":    "PDF not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "PDF updated successfully",
+			"pdf_id":     pdfObjID.Hex(),
+			"active":     *request.Active,
+			"updated_at": now.UTC(),
+		})
+	}
+}
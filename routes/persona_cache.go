@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// DEFAULT PERSONA CACHE
+// ===================
+
+// defaultPersonaCache keeps the default persona in memory so clients without their own persona don't cost
+// a system_settings read per chat message. Each instance holds its own copy: an admin change is visible
+// immediately on the instance that handled it and on the others once their TTL has run out.
+type defaultPersonaCache struct {
+	mu        sync.RWMutex
+	persona   *models.AIPersonaData // nil when no default persona is set
+	expiresAt time.Time
+}
+
+var sharedDefaultPersonaCache = &defaultPersonaCache{}
+
+// get returns the cached persona and whether the cache is still fresh
+func (pc *defaultPersonaCache) get() (*models.AIPersonaData, bool) {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.persona, time.Now().Before(pc.expiresAt)
+}
+
+func (pc *defaultPersonaCache) set(persona *models.AIPersonaData, ttl time.Duration) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.persona = persona
+	pc.expiresAt = time.Now().Add(ttl)
+}
+
+func (pc *defaultPersonaCache) invalidate() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.persona = nil
+	pc.expiresAt = time.Time{}
+}
+
+// invalidateDefaultPersonaCache drops the cached default persona so the next request reloads it
+func invalidateDefaultPersonaCache() {
+	sharedDefaultPersonaCache.invalidate()
+}
+
+// getDefaultPersona returns the default persona, from memory while the cache is fresh. The result is
+// shared between requests and must not be modified. Lookup errors are not cached.
+func getDefaultPersona(ctx context.Context, cfg *config.Config, db *mongo.Database) (*models.AIPersonaData, error) {
+	ttl := time.Duration(cfg.DefaultPersonaCacheTTL) * time.Second
+	if ttl <= 0 {
+		return loadDefaultPersona(ctx, db)
+	}
+	if persona, fresh := sharedDefaultPersonaCache.get(); fresh {
+		return persona, nil
+	}
+	persona, err := loadDefaultPersona(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	sharedDefaultPersonaCache.set(persona, ttl)
+	return persona, nil
+}
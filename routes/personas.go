@@ -0,0 +1,513 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// CLIENT PERSONAS
+// ===================
+//
+// Besides its AI persona, a client can define personas for different jobs (sales, support, ...). Each reply
+// is routed to one: the persona the visitor picked in the widget, then the one tied to the pre-question they
+// clicked, then the highest-priority persona whose topics match the message. Without a match the session
+// stays with the persona that answered its previous message, and otherwise the client's AI persona is used.
+
+const (
+	maxClientPersonas         = 20
+	maxPersonaNameLength      = 100
+	maxPersonaContentChars    = 50000
+	maxPersonaTopics          = 30
+	maxPersonaTopicLength     = 50
+	defaultPersonaSelectorKey = "default" // widget selector value that clears the session's persona
+)
+
+// personaHint carries what the visitor did that can route the message to a persona
+type personaHint struct {
+	Selector      string // persona ID picked in the widget, or "default"
+	PreQuestionID string // pre-question the message came from
+}
+
+// personasCollection returns the collection holding clients' personas
+func personasCollection(db *mongo.Database) *mongo.Collection {
+	return db.Collection("client_personas")
+}
+
+// loadClientPersonas returns the client's enabled personas, highest priority first
+func loadClientPersonas(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID) ([]models.ClientPersona, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "created_at", Value: 1}}).SetLimit(maxClientPersonas)
+	cursor, err := personasCollection(db).Find(ctx, bson.M{"client_id": clientID, "enabled": true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var personas []models.ClientPersona
+	if err := cursor.All(ctx, &personas); err != nil {
+		return nil, err
+	}
+	return personas, nil
+}
+
+// personaMatchesTopic reports whether message is about one of the persona's topics, either a topic
+// detected by extractTopics or a keyword/phrase found as whole words in the message
+func personaMatchesTopic(persona *models.ClientPersona, detected map[string]bool, messageWords string) bool {
+	for _, topic := range persona.Triggers.Topics {
+		topic = strings.ToLower(strings.TrimSpace(topic))
+		if detected[topic] {
+			return true
+		}
+		if phrase := strings.Join(greetingWords(topic), " "); phrase != "" && strings.Contains(messageWords, " "+phrase+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// selectPersona picks the persona for a message from personas (highest priority first), or nil for the
+// client's default persona. previousID is the persona that answered the session's last message, if any.
+func selectPersona(personas []models.ClientPersona, hint personaHint, message string, previousID *primitive.ObjectID) *models.ClientPersona {
+	if len(personas) == 0 || hint.Selector == defaultPersonaSelectorKey {
+		return nil
+	}
+
+	if hint.Selector != "" {
+		for i := range personas {
+			if personas[i].Triggers.Selectable && personas[i].ID.Hex() == hint.Selector {
+				return &personas[i]
+			}
+		}
+	}
+
+	if hint.PreQuestionID != "" {
+		for i := range personas {
+			for _, id := range personas[i].Triggers.PreQuestionIDs {
+				if id == hint.PreQuestionID {
+					return &personas[i]
+				}
+			}
+		}
+	}
+
+	detected := make(map[string]bool)
+	for _, topic := range extractTopics(message) {
+		detected[topic] = true
+	}
+	messageWords := " " + strings.Join(greetingWords(message), " ") + " "
+	for i := range personas {
+		if personaMatchesTopic(&personas[i], detected, messageWords) {
+			return &personas[i]
+		}
+	}
+
+	if previousID != nil {
+		for i := range personas {
+			if personas[i].ID == *previousID {
+				return &personas[i]
+			}
+		}
+	}
+	return nil
+}
+
+// routePersona returns the persona that should answer message in sessionID, or nil to use the default.
+// Lookup failures are logged and fall back to the default persona.
+func routePersona(ctx context.Context, db *mongo.Database, messagesCollection *mongo.Collection, clientID primitive.ObjectID, sessionID, message string, hint personaHint) *models.ClientPersona {
+	personas, err := loadClientPersonas(ctx, db, clientID)
+	if err != nil {
+		logger.Warn("Failed to load client personas", "error", err, "client_id", clientID.Hex())
+		return nil
+	}
+	if len(personas) == 0 {
+		return nil
+	}
+
+	var previous struct {
+		PersonaID *primitive.ObjectID `bson:"persona_id"`
+	}
+	err = messagesCollection.FindOne(ctx,
+		bson.M{"client_id": clientID, "conversation_id": sessionID},
+		options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetProjection(bson.M{"persona_id": 1}),
+	).Decode(&previous)
+	if err != nil && err != mongo.ErrNoDocuments {
+		logger.Warn("Failed to look up session persona", "error", err, "client_id", clientID.Hex(), "session_id", sessionID)
+	}
+
+	return selectPersona(personas, hint, message, previous.PersonaID)
+}
+
+// publicPersonas lists the personas a visitor can pick in the widget
+func publicPersonas(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID) []gin.H {
+	list := []gin.H{}
+	personas, err := loadClientPersonas(ctx, db, clientID)
+	if err != nil {
+		logger.Warn("Failed to load client personas", "error", err, "client_id", clientID.Hex())
+		return list
+	}
+	for _, persona := range personas {
+		if persona.Triggers.Selectable {
+			list = append(list, gin.H{"id": persona.ID.Hex(), "name": persona.Name})
+		}
+	}
+	return list
+}
+
+// personaRequest is the body for creating or replacing a persona
+type personaRequest struct {
+	Name     string                 `json:"name" binding:"required"`
+	Content  string                 `json:"content" binding:"required"`
+	Enabled  *bool                  `json:"enabled"`
+	Priority int                    `json:"priority"`
+	Triggers models.PersonaTriggers `json:"triggers"`
+}
+
+// validatePersonaRequest trims and checks a persona request against the client's pre-questions,
+// returning a message describing the first problem
+func validatePersonaRequest(req *personaRequest, branding models.Branding) string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > maxPersonaNameLength {
+		return fmt.Sprintf("Name is required and must be at most %d characters", maxPersonaNameLength)
+	}
+	if req.Content == "" || utf8.RuneCountInString(req.Content) > maxPersonaContentChars {
+		return fmt.Sprintf("Content is required and must be at most %d characters", maxPersonaContentChars)
+	}
+
+	topics := make([]string, 0, len(req.Triggers.Topics))
+	for _, topic := range req.Triggers.Topics {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		if utf8.RuneCountInString(topic) > maxPersonaTopicLength || len(greetingWords(topic)) == 0 {
+			return fmt.Sprintf("Each topic must contain letters and be at most %d characters: %q", maxPersonaTopicLength, topic)
+		}
+		topics = append(topics, topic)
+	}
+	if len(topics) > maxPersonaTopics {
+		return fmt.Sprintf("Maximum %d topics allowed", maxPersonaTopics)
+	}
+	req.Triggers.Topics = topics
+
+	for _, id := range req.Triggers.PreQuestionIDs {
+		if findPreQuestionConfig(branding, id) == nil {
+			return fmt.Sprintf("Unknown pre-question: %q", id)
+		}
+	}
+	return ""
+}
+
+// personaIDParam parses the :id path parameter, writing the 400 response when it is invalid
+func personaIDParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error_code": "invalid_persona_id",
+			"message":    "Invalid persona ID format",
+		})
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// handleListPersonas returns all of the client's personas, including disabled ones
+func handleListPersonas(db *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		cursor, err := personasCollection(db).Find(ctx, bson.M{"client_id": clientObjID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "fetch_failed",
+				"message":    "Failed to load personas",
+			})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		personas := []models.ClientPersona{}
+		if err := cursor.All(ctx, &personas); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "fetch_failed",
+				"message":    "Failed to load personas",
+			})
+			return
+		}
+		sort.SliceStable(personas, func(i, j int) bool {
+			if personas[i].Priority != personas[j].Priority {
+				return personas[i].Priority > personas[j].Priority
+			}
+			return personas[i].CreatedAt.Before(personas[j].CreatedAt)
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"personas": personas,
+			"total":    len(personas),
+		})
+	}
+}
+
+// handleCreatePersona adds a persona to the client
+func handleCreatePersona(db *mongo.Database, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var req personaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+		if problem := validatePersonaRequest(&req, clientDoc.Branding); problem != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_persona",
+				"message":    problem,
+			})
+			return
+		}
+
+		count, err := personasCollection(db).CountDocuments(ctx, bson.M{"client_id": clientObjID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "create_failed",
+				"message":    "Failed to create persona",
+			})
+			return
+		}
+		if count >= maxClientPersonas {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "too_many_personas",
+				"message":    fmt.Sprintf("Maximum %d personas allowed", maxClientPersonas),
+			})
+			return
+		}
+
+		now := time.Now()
+		persona := models.ClientPersona{
+			ID:        primitive.NewObjectID(),
+			ClientID:  clientObjID,
+			Name:      req.Name,
+			Content:   req.Content,
+			Enabled:   req.Enabled == nil || *req.Enabled,
+			Priority:  req.Priority,
+			Triggers:  req.Triggers,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if _, err := personasCollection(db).InsertOne(ctx, persona); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "create_failed",
+				"message":    "Failed to create persona",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Persona created",
+			"persona": persona,
+		})
+	}
+}
+
+// handleUpdatePersona replaces a persona's settings
+func handleUpdatePersona(db *mongo.Database, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+		personaID, ok := personaIDParam(c)
+		if !ok {
+			return
+		}
+
+		var req personaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+		if problem := validatePersonaRequest(&req, clientDoc.Branding); problem != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_persona",
+				"message":    problem,
+			})
+			return
+		}
+
+		set := bson.M{
+			"name":       req.Name,
+			"content":    req.Content,
+			"priority":   req.Priority,
+			"triggers":   req.Triggers,
+			"updated_at": time.Now(),
+		}
+		if req.Enabled != nil {
+			set["enabled"] = *req.Enabled
+		}
+
+		var persona models.ClientPersona
+		err = personasCollection(db).FindOneAndUpdate(ctx,
+			bson.M{"_id": personaID, "client_id": clientObjID},
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&persona)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "persona_not_found",
+				"message":    "Persona not found",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update persona",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Persona updated",
+			"persona": persona,
+		})
+	}
+}
+
+// handleDeletePersona removes a persona; sessions it was answering fall back to the default persona
+func handleDeletePersona(db *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+		personaID, ok := personaIDParam(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		result, err := personasCollection(db).DeleteOne(ctx, bson.M{"_id": personaID, "client_id": clientObjID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "delete_failed",
+				"message":    "Failed to delete persona",
+			})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "persona_not_found",
+				"message":    "Persona not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Persona deleted",
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"testing"
+
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSelectPersona(t *testing.T) {
+	sales := models.ClientPersona{ID: primitive.NewObjectID(), Name: "Sales", Priority: 10,
+		Triggers: models.PersonaTriggers{Topics: []string{"pricing", "demo"}, Selectable: true}}
+	support := models.ClientPersona{ID: primitive.NewObjectID(), Name: "Support", Priority: 5,
+		Triggers: models.PersonaTriggers{Topics: []string{"refund", "not working"}, PreQuestionIDs: []string{"pq_help"}}}
+	personas := []models.ClientPersona{sales, support}
+
+	tests := []struct {
+		name     string
+		hint     personaHint
+		message  string
+		previous *primitive.ObjectID
+		want     string
+	}{
+		{"no match", personaHint{}, "Hello there", nil, ""},
+		{"detected topic", personaHint{}, "What is the price of the basic plan?", nil, "Sales"},
+		{"keyword phrase", personaHint{}, "The export is not working", nil, "Support"},
+		{"keyword needs whole words", personaHint{}, "Tell me about refunds", nil, ""},
+		{"pre-question", personaHint{PreQuestionID: "pq_help"}, "I need help", nil, "Support"},
+		{"selector wins", personaHint{Selector: sales.ID.Hex()}, "I want a refund", nil, "Sales"},
+		{"only selectable personas can be picked", personaHint{Selector: support.ID.Hex()}, "Hi", nil, ""},
+		{"default selector clears", personaHint{Selector: defaultPersonaSelectorKey}, "What is the price?", &sales.ID, ""},
+		{"session stays with previous persona", personaHint{}, "And after that?", &support.ID, "Support"},
+		{"new topic switches persona", personaHint{}, "Can I book a demo?", &support.ID, "Sales"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if persona := selectPersona(personas, tt.hint, tt.message, tt.previous); persona != nil {
+				got = persona.Name
+			}
+			if got != tt.want {
+				t.Errorf("selectPersona = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePersonaRequest(t *testing.T) {
+	branding := models.Branding{PreQuestionConfigs: []models.PreQuestionConfig{{ID: "pq_1", Text: "Pricing?"}}}
+
+	req := personaRequest{Name: " Sales ", Content: "You are the sales assistant.",
+		Triggers: models.PersonaTriggers{Topics: []string{" pricing ", ""}, PreQuestionIDs: []string{"pq_1"}}}
+	if problem := validatePersonaRequest(&req, branding); problem != "" {
+		t.Fatalf("valid request rejected: %s", problem)
+	}
+	if req.Name != "Sales" || len(req.Triggers.Topics) != 1 || req.Triggers.Topics[0] != "pricing" {
+		t.Errorf("request not normalized: %+v", req)
+	}
+
+	req = personaRequest{Name: "Support", Content: "Help", Triggers: models.PersonaTriggers{PreQuestionIDs: []string{"missing"}}}
+	if problem := validatePersonaRequest(&req, branding); problem == "" {
+		t.Error("unknown pre-question accepted")
+	}
+}
@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// PRE-QUESTION ROUTING
+// ===================
+
+// Pre-question actions
+const (
+	preQuestionActionAI      = "ai"      // send the question text to the AI as a normal message
+	preQuestionActionAnswer  = "answer"  // reply with the configured canned answer, no AI call
+	preQuestionActionContact = "contact" // start contact collection straight away, no AI call
+)
+
+const (
+	maxPreQuestionConfigs     = 5
+	maxPreQuestionAnswerChars = 2000
+)
+
+// defaultContactPreQuestionReply opens contact collection when no answer is configured
+const defaultContactPreQuestionReply = "I'd be happy to connect you with our team. May I have your name, please?"
+
+// validatePreQuestionConfigs checks ids are unique and every entry has a known action with the fields it needs
+func validatePreQuestionConfigs(configs []models.PreQuestionConfig) error {
+	if len(configs) > maxPreQuestionConfigs {
+		return fmt.Errorf("maximum %d pre-questions allowed", maxPreQuestionConfigs)
+	}
+	seen := make(map[string]bool)
+	for i, pq := range configs {
+		id := strings.TrimSpace(pq.ID)
+		if id == "" {
+			return fmt.Errorf("pre-question %d: id is required", i+1)
+		}
+		if seen[id] {
+			return fmt.Errorf("duplicate pre-question id %q", id)
+		}
+		seen[id] = true
+		if strings.TrimSpace(pq.Text) == "" {
+			return fmt.Errorf("pre-question %q: text is required", id)
+		}
+		switch pq.Action {
+		case preQuestionActionAI, preQuestionActionContact:
+		case preQuestionActionAnswer:
+			if strings.TrimSpace(pq.Answer) == "" {
+				return fmt.Errorf("pre-question %q: answer is required for action %q", id, preQuestionActionAnswer)
+			}
+		default:
+			return fmt.Errorf("pre-question %q: action must be one of ai, answer or contact", id)
+		}
+		if len(pq.Answer) > maxPreQuestionAnswerChars {
+			return fmt.Errorf("pre-question %q: answer must be at most %d characters", id, maxPreQuestionAnswerChars)
+		}
+	}
+	return nil
+}
+
+// findPreQuestionConfig returns the configured pre-question with the given id
+func findPreQuestionConfig(branding models.Branding, id string) *models.PreQuestionConfig {
+	for i := range branding.PreQuestionConfigs {
+		if branding.PreQuestionConfigs[i].ID == id {
+			return &branding.PreQuestionConfigs[i]
+		}
+	}
+	return nil
+}
+
+// publicPreQuestions lists pre-questions for the widget without their scripted answers
+func publicPreQuestions(branding models.Branding) []gin.H {
+	list := make([]gin.H, 0, len(branding.PreQuestionConfigs))
+	for _, pq := range branding.PreQuestionConfigs {
+		list = append(list, gin.H{
+			"id":     pq.ID,
+			"text":   pq.Text,
+			"action": pq.Action,
+		})
+	}
+	return list
+}
+
+// respondToScriptedPreQuestion persists and returns the scripted reply for an "answer" or "contact"
+// pre-question. No tokens are charged.
+func respondToScriptedPreQuestion(ctx context.Context, c *gin.Context, messagesCollection *mongo.Collection, clientDoc *models.Client, req ChatRequest, pq *models.PreQuestionConfig, welcomeVariant string) {
+	reply := strings.TrimSpace(pq.Answer)
+	if pq.Action == preQuestionActionContact && reply == "" {
+		reply = defaultContactPreQuestionReply
+	}
+
+	messageID, err := persistMessage(ctx, messagesCollection, clientDoc.ID, req, reply, 0, nil, welcomeVariant, visitorTrackingFor(clientDoc, req), c.Request)
+	if err != nil {
+		logger.Warn("Failed to persist scripted pre-question reply", "error", err, "client_id", clientDoc.ID.Hex(), "session_id", req.SessionID)
+	} else if pq.Action == preQuestionActionContact {
+		if err := updateContactCollectionState(ctx, messagesCollection, clientDoc.ID, req.SessionID, "awaiting_name", "", "", false); err != nil {
+			logger.Warn("Failed to start contact collection", "error", err, "client_id", clientDoc.ID.Hex(), "session_id", req.SessionID)
+		}
+	}
+
+	remainingTokens := clientDoc.TokenLimit - clientDoc.TokenUsed
+	if remainingTokens < 0 {
+		remainingTokens = 0
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reply":              reply,
+		"token_cost":         0,
+		"remaining_tokens":   remainingTokens,
+		"conversation_id":    req.SessionID,
+		"message_id":         messageID.Hex(),
+		"latency_ms":         0,
+		"timestamp":          time.Now().Unix(),
+		"pre_question_id":    pq.ID,
+		"scripted":           true,
+		"contact_collection": pq.Action == preQuestionActionContact,
+	})
+}
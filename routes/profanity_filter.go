@@ -0,0 +1,317 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// REPLY PROFANITY FILTER
+// ===================
+//
+// Clients that enable the filter get profane words in the bot's replies masked with asterisks before the
+// reply is returned. The stored reply keeps the original text so analytics and quality review see what
+// the model actually produced. Words match whole words, case-insensitively, in any script; entries with
+// several words match as a phrase.
+
+// defaultProfanityWords are masked for every client with the filter enabled, in English, Hindi/Hinglish
+// and a few other common widget languages. PROFANITY_WORDS and the client's own list add to these.
+var defaultProfanityWords = []string{
+	"fuck", "fucking", "fucked", "fucker", "motherfucker", "shit", "shitty", "bullshit", "bitch", "bastard",
+	"asshole", "dick", "dickhead", "cunt", "prick", "wanker", "twat", "slut", "whore", "piss off", "son of a bitch",
+	"chutiya", "chutiye", "madarchod", "behenchod", "bhenchod", "bhosdike", "bhosdi", "gandu", "harami", "kutta",
+	"kamina", "saala", "randi", "lavda", "lauda",
+	"चूतिया", "मादरचोद", "बहनचोद", "भोसडीके", "गांडू", "हरामी", "कमीना", "रंडी",
+	"mierda", "puta", "pendejo", "cabrón", "gilipollas", "joder",
+	"merde", "putain", "connard", "salope",
+	"scheiße", "scheisse", "arschloch", "fotze",
+}
+
+const (
+	maxProfanityWords      = 200
+	maxProfanityWordLength = 50
+)
+
+// profanityFilter masks a fixed set of words and phrases
+type profanityFilter struct {
+	phrases [][]string // longest first
+}
+
+// profanityFilterFor returns the reply filter for a client, or nil when the client hasn't enabled it
+func profanityFilterFor(cfg *config.Config, client *models.Client) *profanityFilter {
+	if client == nil || client.ProfanityFilter == nil || !client.ProfanityFilter.Enabled {
+		return nil
+	}
+
+	filter := &profanityFilter{}
+	seen := make(map[string]bool)
+	for _, list := range [][]string{defaultProfanityWords, cfg.ProfanityWords, client.ProfanityFilter.Words} {
+		for _, entry := range list {
+			words := greetingWords(entry)
+			key := strings.Join(words, " ")
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			filter.phrases = append(filter.phrases, words)
+		}
+	}
+	sort.SliceStable(filter.phrases, func(i, j int) bool { return len(filter.phrases[i]) > len(filter.phrases[j]) })
+	return filter
+}
+
+// wordSpan is a word's byte range in the original text and its lowercased form
+type wordSpan struct {
+	start, end int
+	word       string
+}
+
+// wordSpans splits text into words the same way greetingWords does, keeping their positions
+func wordSpans(text string) []wordSpan {
+	var spans []wordSpan
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+		if isWordRune && start < 0 {
+			start = i
+		} else if !isWordRune && start >= 0 {
+			spans = append(spans, wordSpan{start: start, end: i, word: strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{start: start, end: len(text), word: strings.ToLower(text[start:])})
+	}
+	return spans
+}
+
+// mask replaces every listed word or phrase in text with asterisks and reports how many were masked.
+// A nil filter returns text unchanged.
+func (f *profanityFilter) mask(text string) (string, int) {
+	if f == nil || text == "" {
+		return text, 0
+	}
+
+	spans := wordSpans(text)
+	var masked []wordSpan
+	for i := 0; i < len(spans); {
+		matched := 0
+		for _, phrase := range f.phrases {
+			if len(phrase) > len(spans)-i {
+				continue
+			}
+			ok := true
+			for j, word := range phrase {
+				if spans[i+j].word != word {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				matched = len(phrase)
+				break
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+		masked = append(masked, spans[i:i+matched]...)
+		i += matched
+	}
+	if len(masked) == 0 {
+		return text, 0
+	}
+
+	var b strings.Builder
+	b.Grow(len(text))
+	last := 0
+	for _, span := range masked {
+		b.WriteString(text[last:span.start])
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(text[span.start:span.end])))
+		last = span.end
+	}
+	b.WriteString(text[last:])
+	return b.String(), len(masked)
+}
+
+// maskAll masks each text and reports how many words were masked in total
+func (f *profanityFilter) maskAll(texts []string) ([]string, int) {
+	if f == nil || len(texts) == 0 {
+		return texts, 0
+	}
+	masked := make([]string, len(texts))
+	total := 0
+	for i, text := range texts {
+		var count int
+		masked[i], count = f.mask(text)
+		total += count
+	}
+	return masked, total
+}
+
+// maskReplyExtras masks the structured reply and quick replies sent alongside a reply. It returns
+// masked copies, leaving meta as stored, and how many words were masked.
+func (f *profanityFilter) maskReplyExtras(meta *aiResponseMeta) (*models.StructuredReply, []string, int) {
+	if meta == nil {
+		return nil, nil, 0
+	}
+
+	total := 0
+	var structured *models.StructuredReply
+	if meta.Structured != nil {
+		copied := *meta.Structured
+		var count int
+		copied.Reply, count = f.mask(copied.Reply)
+		total += count
+		copied.SuggestedActions, count = f.maskAll(copied.SuggestedActions)
+		total += count
+		structured = &copied
+	}
+
+	quickReplies, count := f.maskAll(meta.QuickReplies)
+	return structured, quickReplies, total + count
+}
+
+// handleGetProfanityFilter returns the client's reply profanity filter settings
+func handleGetProfanityFilter(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		settings := models.ProfanityFilterSettings{Words: []string{}}
+		if clientDoc.ProfanityFilter != nil {
+			settings = *clientDoc.ProfanityFilter
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"profanity_filter":    settings,
+			"default_words_count": len(defaultProfanityWords),
+		})
+	}
+}
+
+// handleUpdateProfanityFilter turns the reply profanity filter on or off and sets the client's extra words
+func handleUpdateProfanityFilter(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var settings models.ProfanityFilterSettings
+		if err := c.ShouldBindJSON(&settings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		words := make([]string, 0, len(settings.Words))
+		for _, word := range settings.Words {
+			word = strings.TrimSpace(word)
+			if word == "" {
+				continue
+			}
+			if utf8.RuneCountInString(word) > maxProfanityWordLength || len(greetingWords(word)) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_words",
+					"message":    fmt.Sprintf("Each word must contain letters and be at most %d characters: %q", maxProfanityWordLength, word),
+				})
+				return
+			}
+			words = append(words, word)
+		}
+		if len(words) > maxProfanityWords {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "too_many_words",
+				"message":    fmt.Sprintf("Maximum %d words allowed", maxProfanityWords),
+			})
+			return
+		}
+		settings.Words = words
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$set": bson.M{
+				"profanity_filter": settings,
+				"updated_at":       time.Now(),
+			},
+		})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update profanity filter",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Profanity filter updated",
+			"profanity_filter": settings,
+		})
+	}
+}
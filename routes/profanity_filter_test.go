@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"testing"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+func TestProfanityFilterMask(t *testing.T) {
+	client := &models.Client{ProfanityFilter: &models.ProfanityFilterSettings{Enabled: true, Words: []string{"Darn it", "zut"}}}
+	filter := profanityFilterFor(&config.Config{ProfanityWords: []string{""}}, client)
+
+	tests := []struct {
+		name  string
+		text  string
+		want  string
+		count int
+	}{
+		{"clean", "Our plans start at $10.", "Our plans start at $10.", 0},
+		{"case insensitive", "That is SHIT, sorry.", "That is ****, sorry.", 1},
+		{"whole words only", "Visit Scunthorpe or Dickens Street.", "Visit Scunthorpe or Dickens Street.", 0},
+		{"phrase", "Well, son of a bitch!", "Well, *** ** * *****!", 4},
+		{"client phrase", "darn   it, that failed", "****   **, that failed", 2},
+		{"client word", "Zut!", "***!", 1},
+		{"devanagari", "वह हरामी है", "वह ***** है", 1},
+		{"accented", "¡Qué cabrón!", "¡Qué ******!", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := filter.mask(tt.text)
+			if got != tt.want || count != tt.count {
+				t.Errorf("mask(%q) = %q, %d; want %q, %d", tt.text, got, count, tt.want, tt.count)
+			}
+		})
+	}
+}
+
+func TestProfanityFilterFor_Disabled(t *testing.T) {
+	cfg := &config.Config{}
+	for _, client := range []*models.Client{{}, {ProfanityFilter: &models.ProfanityFilterSettings{Enabled: false}}} {
+		if filter := profanityFilterFor(cfg, client); filter != nil {
+			t.Fatalf("profanityFilterFor = %v, want nil", filter)
+		}
+	}
+
+	var filter *profanityFilter
+	if got, count := filter.mask("shit"); got != "shit" || count != 0 {
+		t.Errorf("nil filter mask = %q, %d", got, count)
+	}
+}
+
+func TestProfanityFilterMaskReplyExtras(t *testing.T) {
+	client := &models.Client{ProfanityFilter: &models.ProfanityFilterSettings{Enabled: true}}
+	filter := profanityFilterFor(&config.Config{}, client)
+
+	meta := &aiResponseMeta{
+		Structured: &models.StructuredReply{
+			Reply:            "That is shit, sorry.",
+			Intent:           "complaint",
+			SuggestedActions: []string{"Why is it shit?", "Talk to sales"},
+		},
+		QuickReplies: []string{"Is the shit plan cheaper?", "Pricing"},
+	}
+
+	structured, quickReplies, count := filter.maskReplyExtras(meta)
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+	if structured.Reply != "That is ****, sorry." || structured.SuggestedActions[0] != "Why is it ****?" || structured.Intent != "complaint" {
+		t.Errorf("structured = %+v, want the reply and suggested actions masked", structured)
+	}
+	if quickReplies[0] != "Is the **** plan cheaper?" || quickReplies[1] != "Pricing" {
+		t.Errorf("quick replies = %v", quickReplies)
+	}
+	if meta.Structured.Reply != "That is shit, sorry." || meta.QuickReplies[0] != "Is the shit plan cheaper?" {
+		t.Error("maskReplyExtras changed the stored meta")
+	}
+
+	var disabled *profanityFilter
+	if s, q, n := disabled.maskReplyExtras(meta); s.Reply != meta.Structured.Reply || q[0] != meta.QuickReplies[0] || n != 0 {
+		t.Errorf("nil filter changed extras: %+v %v %d", s, q, n)
+	}
+}
@@ -0,0 +1,202 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// PROMPT INSPECTION
+// ===================
+//
+// Clients tuning their persona can see what the model was given for a reply. When prompt inspection is
+// enabled for a client, each generated reply keeps a snapshot of its prompt rendered from the client's
+// template, with the client's persona, retrieved knowledge, conversation and message in place but the
+// platform's built-in instructions (and the platform default persona) replaced by [redacted: ...] markers.
+
+const (
+	maxPromptSnapshotChars  = 200000
+	maxPromptSourceExcerpt  = 300
+	defaultPersonaRedaction = "[redacted: platform default persona]"
+)
+
+// redactedPromptSection marks a built-in instruction block in an inspected prompt
+func redactedPromptSection(name string) string {
+	return fmt.Sprintf("[redacted: %s]\n\n", strings.ToLower(promptTemplatePlaceholders[name]))
+}
+
+// buildInspectablePrompt renders the client's template like buildPromptWithHistory, keeping the client's data
+// and replacing the built-in instructions with redaction markers
+func buildInspectablePrompt(template, clientName, contextStr string, history []models.Message, currentMessage string, knownFacts map[string]string) string {
+	knowledge := "[redacted: answer mode instructions]\n\n"
+	if contextStr != "" {
+		knowledge = "=== YOUR COMPLETE KNOWLEDGE BASE ===\n" + contextStr + "\n=== END OF KNOWLEDGE BASE ===\n\n" + knowledge
+	}
+
+	historySection := "[redacted: first-message rules]\n\n"
+	if len(history) > 0 {
+		historySection = "PREVIOUS CONVERSATION:\n" + formatPromptConversation(history) + "[redacted: repetition and demo state guidance]\n\n"
+	}
+
+	sections := map[string]string{
+		"client_name":     clientName,
+		"context":         contextStr,
+		"conversation":    formatPromptConversation(history),
+		"message":         currentMessage,
+		"known_facts":     formatKnownFacts(knownFacts),
+		"isolation":       redactedPromptSection("isolation"),
+		"knowledge":       knowledge,
+		"guidelines":      redactedPromptSection("guidelines"),
+		"sales_playbook":  redactedPromptSection("sales_playbook"),
+		"history":         historySection,
+		"current_message": fmt.Sprintf("USER'S CURRENT MESSAGE: \"%s\"\n\n", currentMessage),
+		"response_rules":  redactedPromptSection("response_rules"),
+	}
+	return services.RenderPlaceholders(template, sections)
+}
+
+// promptSources lists the retrieved chunks placed in the prompt, with a short excerpt of each
+func promptSources(pdfChunks, crawledChunks []models.ContentChunk) []models.PromptSource {
+	sources := make([]models.PromptSource, 0, len(pdfChunks)+len(crawledChunks))
+	excerpt := func(text string) string {
+		text = strings.TrimSpace(text)
+		if runes := []rune(text); len(runes) > maxPromptSourceExcerpt {
+			return string(runes[:maxPromptSourceExcerpt]) + "…"
+		}
+		return text
+	}
+	for _, chunk := range pdfChunks {
+		sources = append(sources, models.PromptSource{SourceType: "pdf", Reference: chunk.ChunkID, Excerpt: excerpt(chunk.Text)})
+	}
+	for _, chunk := range crawledChunks {
+		text, reference := splitCrawlChunkSource(chunk)
+		sources = append(sources, models.PromptSource{SourceType: "crawl", Reference: reference, Excerpt: excerpt(text)})
+	}
+	return sources
+}
+
+// newPromptSnapshot builds the inspectable snapshot of a reply's prompt. contextStr is the context as sent,
+// including the persona; personaSource says whose persona it is ("client", "persona", "default" or "none").
+func newPromptSnapshot(cfg *config.Config, client *models.Client, sessionID, contextStr, personaSource, personaContent string, history []models.Message, message string, knownFacts map[string]string, pdfChunks, crawledChunks []models.ContentChunk) *models.PromptSnapshot {
+	if personaSource == "default" {
+		contextStr = strings.Replace(contextStr, personaContent, defaultPersonaRedaction, 1)
+	}
+
+	prompt := buildInspectablePrompt(promptTemplateForClient(cfg, client), companyNameFor(client), contextStr, history, message, knownFacts)
+	if len(prompt) > maxPromptSnapshotChars {
+		prompt = prompt[:maxPromptSnapshotChars] + "\n[truncated]"
+	}
+
+	now := time.Now()
+	return &models.PromptSnapshot{
+		ClientID:       client.ID,
+		ConversationID: sessionID,
+		Prompt:         prompt,
+		CustomTemplate: strings.TrimSpace(client.PromptTemplate) != "",
+		PersonaSource:  personaSource,
+		Sources:        promptSources(pdfChunks, crawledChunks),
+		CreatedAt:      now,
+		ExpiresAt:      now.AddDate(0, 0, promptSnapshotRetentionDays(cfg)),
+	}
+}
+
+// promptSnapshotRetentionDays returns how long prompt snapshots are kept
+func promptSnapshotRetentionDays(cfg *config.Config) int {
+	if cfg.PromptSnapshotRetentionDays > 0 {
+		return cfg.PromptSnapshotRetentionDays
+	}
+	return 30
+}
+
+// storePromptSnapshot saves a reply's prompt snapshot under its message id
+func storePromptSnapshot(ctx context.Context, db *mongo.Database, snapshot *models.PromptSnapshot, messageID primitive.ObjectID) {
+	snapshot.MessageID = messageID
+	if _, err := db.Collection("prompt_snapshots").InsertOne(ctx, snapshot); err != nil {
+		logger.Warn("Failed to store prompt snapshot", "error", err, "message_id", messageID.Hex())
+	}
+}
+
+// handleGetMessagePrompt returns the redacted prompt and retrieved sources behind one of the client's replies.
+// ?format=text downloads the prompt as a text file.
+func handleGetMessagePrompt(db *mongo.Database, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientObjID, err := primitive.ObjectIDFromHex(middleware.GetClientID(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		messageID, err := primitive.ObjectIDFromHex(c.Param("message_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_message_id",
+				"message":    "Invalid message ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		var client models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+		if !client.PromptInspectionEnabled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "feature_not_enabled",
+				"message":    "Prompt inspection is not enabled for your account. Please contact your administrator.",
+			})
+			return
+		}
+
+		var snapshot models.PromptSnapshot
+		err = db.Collection("prompt_snapshots").FindOne(ctx, bson.M{
+			"client_id":  clientObjID,
+			"message_id": messageID,
+		}).Decode(&snapshot)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "prompt_not_found",
+					"message":    "No prompt was recorded for this message. Prompts are kept only while inspection is enabled and for a limited time.",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch prompt",
+			})
+			return
+		}
+
+		if c.Query("format") == "text" {
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=prompt-%s.txt", messageID.Hex()))
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(snapshot.Prompt))
+			return
+		}
+
+		c.JSON(http.StatusOK, snapshot)
+	}
+}
@@ -0,0 +1,244 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// SYSTEM PROMPT TEMPLATES
+// ===================
+
+// defaultPromptTemplate reproduces the built-in sales assistant prompt section by section
+const defaultPromptTemplate = "{{isolation}}{{knowledge}}{{known_facts}}{{guidelines}}{{sales_playbook}}{{history}}{{current_message}}{{response_rules}}"
+
+// maxPromptTemplateLength bounds custom templates (the sections themselves are not counted)
+const maxPromptTemplateLength = 20000
+
+// promptTemplatePlaceholders lists the insertion points a prompt template can use
+var promptTemplatePlaceholders = map[string]string{
+	// Built-in prompt sections
+	"isolation":       "Client data isolation rules",
+	"knowledge":       "Persona and document knowledge base with the matching answer mode",
+	"known_facts":     "Verified customer facts passed by the host site as signed context (empty otherwise)",
+	"guidelines":      "Language detection, information sharing and communication style rules",
+	"sales_playbook":  "Sales follow-up questions, topic depth and contact collection flow",
+	"history":         "Previous conversation with repetition and demo state guidance, or first-message rules",
+	"current_message": "The user's current message with a heading",
+	"response_rules":  "Response structure and prohibited behaviours",
+	// Raw values
+	"client_name":  "The company name the bot represents (branding display_company_name, else the client's name)",
+	"context":      "Raw persona, document and website context",
+	"conversation": "Raw previous conversation as Customer/You lines",
+	"message":      "Raw text of the user's current message",
+}
+
+var (
+	deploymentPromptTemplate     string
+	deploymentPromptTemplateOnce sync.Once
+)
+
+// promptTemplateForClient returns the client's template, the deployment template from
+// PROMPT_TEMPLATE_PATH, or the built-in default, in that order
+func promptTemplateForClient(cfg *config.Config, client *models.Client) string {
+	if client != nil && strings.TrimSpace(client.PromptTemplate) != "" {
+		return client.PromptTemplate
+	}
+
+	deploymentPromptTemplateOnce.Do(func() {
+		deploymentPromptTemplate = defaultPromptTemplate
+		if cfg == nil || cfg.PromptTemplatePath == "" {
+			return
+		}
+		data, err := os.ReadFile(cfg.PromptTemplatePath)
+		if err != nil {
+			logger.Warn("Failed to read prompt template, using default", "error", err, "path", cfg.PromptTemplatePath)
+			return
+		}
+		if err := validatePromptTemplate(string(data)); err != nil {
+			logger.Warn("Invalid prompt template, using default", "error", err, "path", cfg.PromptTemplatePath)
+			return
+		}
+		deploymentPromptTemplate = string(data)
+	})
+	return deploymentPromptTemplate
+}
+
+// validatePromptTemplate checks that a template only uses known placeholders and includes the user's message
+func validatePromptTemplate(template string) error {
+	if len(template) > maxPromptTemplateLength {
+		return fmt.Errorf("template must be at most %d characters", maxPromptTemplateLength)
+	}
+
+	hasMessage := false
+	var unknown []string
+	for _, name := range services.PlaceholderNames(template) {
+		if _, ok := promptTemplatePlaceholders[name]; !ok {
+			unknown = append(unknown, name)
+		}
+		if name == "current_message" || name == "message" {
+			hasMessage = true
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown placeholders: %s", strings.Join(unknown, ", "))
+	}
+	if !hasMessage {
+		return fmt.Errorf("template must include {{current_message}} or {{message}}")
+	}
+	return nil
+}
+
+// formatPromptConversation renders history for the {{conversation}} placeholder
+func formatPromptConversation(history []models.Message) string {
+	var b strings.Builder
+	for _, msg := range history {
+		b.WriteString(fmt.Sprintf("Customer: %s\n", msg.Message))
+		b.WriteString(fmt.Sprintf("You: %s\n\n", msg.Reply))
+	}
+	return b.String()
+}
+
+// handleGetPromptTemplate returns the authenticated client's prompt template and the available placeholders
+func handleGetPromptTemplate(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		var client models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "client_not_found",
+					"message":    "Client not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch prompt template",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"prompt_template":  promptTemplateForClient(cfg, &client),
+			"is_custom":        strings.TrimSpace(client.PromptTemplate) != "",
+			"default_template": promptTemplateForClient(cfg, nil),
+			"placeholders":     promptTemplatePlaceholders,
+		})
+	}
+}
+
+// handleUpdatePromptTemplate sets the authenticated client's prompt template.
+// An empty template resets the client to the deployment default.
+func handleUpdatePromptTemplate(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var request struct {
+			PromptTemplate string `json:"prompt_template"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		template := request.PromptTemplate
+		if strings.TrimSpace(template) != "" {
+			if err := validatePromptTemplate(template); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_prompt_template",
+					"message":    err.Error(),
+				})
+				return
+			}
+		} else {
+			template = ""
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$set": bson.M{
+				"prompt_template": template,
+				"updated_at":      time.Now(),
+			},
+		})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update prompt template",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Prompt template updated successfully",
+			"is_custom": template != "",
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// QUALITY ALERT DEDUPLICATION
+// ===================
+//
+// Quality alerts are stored one document per condition. While an unacknowledged alert for a condition
+// was last seen within the cooldown (QUALITY_ALERT_COOLDOWN_HOURS), repeated checks only bump its
+// occurrence_count and refresh its message and metrics, so a client whose metrics stay bad for days gets
+// one alert instead of one per check. Acknowledging it, or the condition staying clear for a full
+// cooldown, lets the next occurrence raise a new alert.
+
+const defaultQualityAlertCooldown = 24 * time.Hour
+
+// qualityAlertCondition is one threshold a client's quality metrics currently breach
+type qualityAlertCondition struct {
+	Key     string // stable id used for deduplication, e.g. "low_satisfaction"
+	Message string
+}
+
+// qualityAlertConditions returns the alert thresholds the metrics breach
+func qualityAlertConditions(metrics *models.QualityMetrics) []qualityAlertCondition {
+	var conditions []qualityAlertCondition
+	enoughFeedback := metrics.TotalFeedback >= 10
+
+	// Low satisfaction rate alert
+	if enoughFeedback && metrics.SatisfactionRate < 0.7 {
+		conditions = append(conditions, qualityAlertCondition{"low_satisfaction",
+			fmt.Sprintf("Low satisfaction rate: %.1f%% (threshold: 70%%)", metrics.SatisfactionRate*100)})
+	}
+
+	// High negative feedback rate alert
+	if enoughFeedback {
+		if negativeRate := float64(metrics.NegativeFeedback) / float64(metrics.TotalFeedback); negativeRate > 0.3 {
+			conditions = append(conditions, qualityAlertCondition{"high_negative_feedback",
+				fmt.Sprintf("High negative feedback rate: %.1f%% (threshold: 30%%)", negativeRate*100)})
+		}
+	}
+
+	// Critical issue alert
+	if metrics.IssueDistribution["wrong_answer"] >= 5 {
+		conditions = append(conditions, qualityAlertCondition{"wrong_answers",
+			fmt.Sprintf("Multiple wrong answer issues: %d reports", metrics.IssueDistribution["wrong_answer"])})
+	}
+
+	// Low quality score alert
+	if enoughFeedback && metrics.AverageQualityScore < 0.5 {
+		conditions = append(conditions, qualityAlertCondition{"low_quality_score",
+			fmt.Sprintf("Low average quality score: %.2f (threshold: 0.5)", metrics.AverageQualityScore)})
+	}
+
+	return conditions
+}
+
+// qualityAlertCooldown is how long an unacknowledged alert absorbs repeats of its condition
+func qualityAlertCooldown(cfg *config.Config) time.Duration {
+	if cfg.QualityAlertCooldownHours > 0 {
+		return time.Duration(cfg.QualityAlertCooldownHours) * time.Hour
+	}
+	return defaultQualityAlertCooldown
+}
+
+// recordQualityAlert adds an occurrence to the open alert for the condition, or creates a new alert if
+// none was seen within the cooldown. It reports whether a new alert was created.
+func recordQualityAlert(ctx context.Context, alertsCollection *mongo.Collection, clientID primitive.ObjectID, condition qualityAlertCondition, metrics *models.QualityMetrics, cooldown time.Duration) (bool, error) {
+	now := time.Now()
+	result, err := alertsCollection.UpdateOne(ctx, bson.M{
+		"client_id":        clientID,
+		"condition":        condition.Key,
+		"acknowledged":     false,
+		"last_occurred_at": bson.M{"$gte": now.Add(-cooldown)},
+	}, bson.M{
+		"$set": bson.M{
+			"alerts":           []string{condition.Message},
+			"metrics":          metrics,
+			"last_occurred_at": now,
+		},
+		"$inc":         bson.M{"occurrence_count": 1},
+		"$setOnInsert": bson.M{"created_at": now},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+func TestQualityAlertConditions(t *testing.T) {
+	keys := func(metrics *models.QualityMetrics) []string {
+		var out []string
+		for _, condition := range qualityAlertConditions(metrics) {
+			out = append(out, condition.Key)
+		}
+		return out
+	}
+
+	bad := &models.QualityMetrics{
+		TotalFeedback:       20,
+		NegativeFeedback:    8,
+		SatisfactionRate:    0.6,
+		AverageQualityScore: 0.4,
+		IssueDistribution:   map[string]int{"wrong_answer": 5},
+	}
+	want := []string{"low_satisfaction", "high_negative_feedback", "wrong_answers", "low_quality_score"}
+	if got := keys(bad); !reflect.DeepEqual(got, want) {
+		t.Errorf("conditions = %v, want %v", got, want)
+	}
+
+	// Rates from too little feedback don't alert, and no feedback doesn't divide by zero
+	if got := keys(&models.QualityMetrics{TotalFeedback: 3, NegativeFeedback: 3}); len(got) != 0 {
+		t.Errorf("conditions with little feedback = %v, want none", got)
+	}
+	if got := keys(&models.QualityMetrics{}); len(got) != 0 {
+		t.Errorf("conditions without feedback = %v, want none", got)
+	}
+}
+
+func TestQualityAlertCooldown(t *testing.T) {
+	if got := qualityAlertCooldown(&config.Config{}); got != 24*time.Hour {
+		t.Errorf("default cooldown = %v, want 24h", got)
+	}
+	if got := qualityAlertCooldown(&config.Config{QualityAlertCooldownHours: 72}); got != 72*time.Hour {
+		t.Errorf("configured cooldown = %v, want 72h", got)
+	}
+}
@@ -0,0 +1,123 @@
+package routes
+
+import (
+	"strings"
+
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// QUICK-REPLY SUGGESTIONS
+// ===================
+
+// maxQuickReplies is how many quick replies are offered after a reply
+const maxQuickReplies = 3
+
+// quickReplyDuplicateOverlap is the word overlap at which a suggestion counts as already asked
+const quickReplyDuplicateOverlap = 0.6
+
+// quickReplyTemplates are next questions a visitor typically asks, keyed by the topics of detectLastTopic.
+// They are phrased as the visitor so a click can be sent as the next message.
+var quickReplyTemplates = map[string][]string{
+	"pricing": {
+		"What plans do you offer?",
+		"Is there a free trial?",
+		"Can I get a custom quote?",
+		"Are there any setup fees?",
+	},
+	"database": {
+		"Where does your data come from?",
+		"Can I filter contacts by location?",
+		"How often is the data updated?",
+	},
+	"delivery": {
+		"What delivery rate can I expect?",
+		"How long does delivery take?",
+		"Can I track delivery reports?",
+	},
+	"conversion": {
+		"What results have other customers seen?",
+		"How do you track leads?",
+		"How can I improve my ROI?",
+	},
+	"demo": {
+		"Can I book a demo?",
+		"How long does a demo take?",
+		"What will the demo cover?",
+	},
+	"general": {
+		"What services do you offer?",
+		"How much does it cost?",
+		"Can I book a demo?",
+		"How do I get started?",
+	},
+}
+
+// nextQuickReplyTopic is where a conversation usually goes after each topic, to round out the suggestions
+var nextQuickReplyTopic = map[string]string{
+	"pricing":    "demo",
+	"database":   "pricing",
+	"delivery":   "conversion",
+	"conversion": "demo",
+	"demo":       "pricing",
+	"general":    "pricing",
+}
+
+// quickReplyAlreadyAsked reports whether a suggestion repeats the current message or a recent question
+func quickReplyAlreadyAsked(suggestion string, asked []map[string]bool) bool {
+	tokens := imageMatchTokens(suggestion)
+	if len(tokens) == 0 {
+		return false
+	}
+	for _, askedTokens := range asked {
+		if len(askedTokens) == 0 {
+			continue
+		}
+		shared := 0
+		for token := range tokens {
+			if askedTokens[token] {
+				shared++
+			}
+		}
+		smaller := len(tokens)
+		if len(askedTokens) < smaller {
+			smaller = len(askedTokens)
+		}
+		if float64(shared)/float64(smaller) >= quickReplyDuplicateOverlap {
+			return true
+		}
+	}
+	return false
+}
+
+// quickReplySuggestions returns up to three next questions for the visitor. Suggested actions from a
+// structured reply come first; the rest are taken from the templates of the current topic and the topic
+// that usually follows it, so no extra tokens are spent. Anything the visitor just asked, or asked in the
+// last few turns, is left out.
+func quickReplySuggestions(history []models.Message, message string, suggestedActions []string) []string {
+	asked := []map[string]bool{imageMatchTokens(message)}
+	for i := len(history) - 1; i >= 0 && i >= len(history)-5; i-- {
+		asked = append(asked, imageMatchTokens(history[i].Message))
+	}
+
+	topic := detectLastTopic(history, message)
+	candidates := append([]string{}, suggestedActions...)
+	candidates = append(candidates, quickReplyTemplates[topic]...)
+	candidates = append(candidates, quickReplyTemplates[nextQuickReplyTopic[topic]]...)
+
+	suggestions := make([]string, 0, maxQuickReplies)
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		key := strings.ToLower(candidate)
+		if candidate == "" || seen[key] || quickReplyAlreadyAsked(candidate, asked) {
+			continue
+		}
+		seen[key] = true
+		suggestions = append(suggestions, candidate)
+		if len(suggestions) == maxQuickReplies {
+			break
+		}
+	}
+	return suggestions
+}
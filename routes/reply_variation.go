@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// REPEATED REPLY VARIATION
+// ===================
+//
+// detectRepeatedCTA and detectRepeatedPhrase steer the prompt, but the model can still answer with a
+// near-copy of one of its recent replies. After generation the reply is compared with the last few bot
+// replies in the session; when the word overlap reaches REPEATED_REPLY_SIMILARITY the reply is generated
+// once more with an instruction to say something different. The message is flagged so the quality
+// metrics can report how often this happens per client.
+
+// minRepeatedReplyTokens keeps short replies ("Sure, happy to help!") out of the check, where any overlap
+// looks like a repeat
+const minRepeatedReplyTokens = 5
+
+// replySimilarity is the Jaccard overlap of the significant words of two replies, from 0 to 1
+func replySimilarity(a, b string) float64 {
+	tokensA, tokensB := attributionTokens(a), attributionTokens(b)
+	if len(tokensA) < minRepeatedReplyTokens || len(tokensB) < minRepeatedReplyTokens {
+		return 0
+	}
+	shared := 0
+	for token := range tokensA {
+		if tokensB[token] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(tokensA)+len(tokensB)-shared)
+}
+
+// mostSimilarRecentReply returns the most similar of the last lookback bot replies in history
+// (oldest first) and its similarity to reply
+func mostSimilarRecentReply(reply string, history []models.Message, lookback int) (string, float64) {
+	var best string
+	bestScore := 0.0
+	checked := 0
+	for i := len(history) - 1; i >= 0 && checked < lookback; i-- {
+		if history[i].Reply == "" {
+			continue
+		}
+		checked++
+		if score := replySimilarity(reply, history[i].Reply); score > bestScore {
+			best, bestScore = history[i].Reply, score
+		}
+	}
+	return best, bestScore
+}
+
+// repeatedReplyThreshold is the similarity at which a reply counts as a repeat (0 disables the check)
+func repeatedReplyThreshold(cfg *config.Config) float64 {
+	if cfg.RepeatedReplySimilarity <= 0 || cfg.RepeatedReplySimilarity > 1 {
+		return 0
+	}
+	return cfg.RepeatedReplySimilarity
+}
+
+// isRepeatedReply reports whether reply is too close to one of the recent replies, returning that reply
+func isRepeatedReply(cfg *config.Config, reply string, history []models.Message) (string, bool) {
+	threshold := repeatedReplyThreshold(cfg)
+	if threshold == 0 {
+		return "", false
+	}
+	previous, score := mostSimilarRecentReply(reply, history, cfg.RepeatedReplyLookback)
+	return previous, score >= threshold
+}
+
+// replyVariationInstruction asks the model not to repeat its earlier reply
+func replyVariationInstruction(previous string) string {
+	return fmt.Sprintf("\n\nIMPORTANT: You already gave this answer earlier in the conversation:\n%q\n"+
+		"Say something different this time: do not repeat it. Address what the user just asked, add new "+
+		"information or a different angle, or ask a clarifying question instead.", previous)
+}
+
+// countRepeatedReplies returns how many bot replies in [start, end] were regenerated as repeats, and
+// their share of all bot replies
+func countRepeatedReplies(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time) (int, float64, error) {
+	filter := bson.M{
+		"client_id": clientID,
+		"timestamp": bson.M{"$gte": start, "$lte": end},
+		"reply":     bson.M{"$nin": bson.A{"", nil}},
+	}
+	total, err := messagesCollection.CountDocuments(ctx, filter)
+	if err != nil || total == 0 {
+		return 0, 0, err
+	}
+	filter["repeated_reply_regenerated"] = true
+	repeated, err := messagesCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(repeated), math.Round(float64(repeated)/float64(total)*1000) / 1000, nil
+}
@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"testing"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+func TestReplySimilarity(t *testing.T) {
+	reply := "Our premium plan includes unlimited chats, priority support and custom branding for your website."
+	tests := []struct {
+		name     string
+		other    string
+		wantLow  float64
+		wantHigh float64
+	}{
+		{"identical", reply, 1, 1},
+		{"reworded punctuation", "Our premium plan includes unlimited chats, priority support, and custom branding for your website!", 1, 1},
+		{"different answer", "You can reach the support team by email any weekday between nine and five.", 0, 0.2},
+		{"too short", "Sure, happy to help!", 0, 0},
+	}
+	for _, tt := range tests {
+		got := replySimilarity(reply, tt.other)
+		if got < tt.wantLow || got > tt.wantHigh {
+			t.Errorf("%s: similarity = %.2f, want between %.2f and %.2f", tt.name, got, tt.wantLow, tt.wantHigh)
+		}
+	}
+}
+
+func TestIsRepeatedReply(t *testing.T) {
+	cfg := &config.Config{RepeatedReplySimilarity: 0.85, RepeatedReplyLookback: 2}
+	repeat := "Our premium plan includes unlimited chats, priority support and custom branding for your website."
+	history := []models.Message{
+		{Reply: repeat},
+		{Reply: "You can reach the support team by email any weekday between nine and five."},
+		{Reply: "We integrate with WhatsApp, Telegram and your website widget out of the box."},
+	}
+
+	if _, repeated := isRepeatedReply(cfg, repeat, history); repeated {
+		t.Error("reply older than the lookback was treated as a repeat")
+	}
+
+	cfg.RepeatedReplyLookback = 3
+	previous, repeated := isRepeatedReply(cfg, repeat, history)
+	if !repeated || previous != repeat {
+		t.Errorf("isRepeatedReply = (%q, %v), want the first reply", previous, repeated)
+	}
+
+	cfg.RepeatedReplySimilarity = 0
+	if _, repeated := isRepeatedReply(cfg, repeat, history); repeated {
+		t.Error("check ran with the threshold disabled")
+	}
+}
@@ -0,0 +1,592 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// MESSAGE RETENTION
+// ===================
+
+// maxRetentionDays caps the configurable retention period (10 years)
+const maxRetentionDays = 3650
+
+// retentionCheckInterval is how often the scheduler looks for clients due a purge
+const retentionCheckInterval = time.Hour
+
+// retentionActions are the supported ways of disposing of expired messages
+var retentionActions = map[string]bool{
+	"delete":  true,
+	"archive": true, // moved to the messages_archive collection
+}
+
+// MessageRetentionScheduler periodically purges messages older than each client's retention policy.
+// Before messages are removed their daily totals are rolled up into message_daily_stats so
+// aggregated analytics survive the purge.
+type MessageRetentionScheduler struct {
+	cfg         *config.Config
+	db          *mongo.Database
+	auditLogger *models.AuditLogger
+	stopChan    chan struct{}
+}
+
+// NewMessageRetentionScheduler creates a retention scheduler
+func NewMessageRetentionScheduler(cfg *config.Config, db *mongo.Database, auditLogger *models.AuditLogger) *MessageRetentionScheduler {
+	return &MessageRetentionScheduler{
+		cfg:         cfg,
+		db:          db,
+		auditLogger: auditLogger,
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Start scans for clients due a purge every retentionCheckInterval until Stop is called
+func (s *MessageRetentionScheduler) Start() {
+	ticker := time.NewTicker(retentionCheckInterval)
+	defer ticker.Stop()
+
+	logger.Info("Starting message retention scheduler", "purge_interval", retentionPurgeInterval(s.cfg).String())
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			s.purgeDueClients(ctx)
+			cancel()
+
+		case <-s.stopChan:
+			logger.Info("Stopping message retention scheduler")
+			return
+		}
+	}
+}
+
+// Stop stops the scheduler
+func (s *MessageRetentionScheduler) Stop() {
+	close(s.stopChan)
+}
+
+// retentionPurgeInterval returns the configured time between purges of one client
+func retentionPurgeInterval(cfg *config.Config) time.Duration {
+	interval := time.Duration(cfg.MessageRetentionInterval) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	return interval
+}
+
+// purgeDueClients purges every client with a retention policy whose last purge is older than the purge interval
+func (s *MessageRetentionScheduler) purgeDueClients(ctx context.Context) {
+	clientsCollection := s.db.Collection("clients")
+	cursor, err := clientsCollection.Find(ctx, bson.M{
+		"message_retention.retention_days": bson.M{"$gt": 0},
+	})
+	if err != nil {
+		logger.Error("Failed to load clients for message retention", "error", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	interval := retentionPurgeInterval(s.cfg)
+	now := time.Now()
+	for cursor.Next(ctx) {
+		var client models.Client
+		if err := cursor.Decode(&client); err != nil || client.MessageRetention == nil {
+			continue
+		}
+
+		policy := client.MessageRetention
+		if !policy.LastPurgeAt.IsZero() && now.Sub(policy.LastPurgeAt) < interval {
+			continue
+		}
+
+		// Claim the purge so that only one instance processes this client
+		claimFilter := bson.M{"_id": client.ID}
+		if policy.LastPurgeAt.IsZero() {
+			claimFilter["$or"] = bson.A{
+				bson.M{"message_retention.last_purge_at": bson.M{"$exists": false}},
+				bson.M{"message_retention.last_purge_at": policy.LastPurgeAt},
+			}
+		} else {
+			claimFilter["message_retention.last_purge_at"] = policy.LastPurgeAt
+		}
+		claim, err := clientsCollection.UpdateOne(ctx, claimFilter, bson.M{"$set": bson.M{"message_retention.last_purge_at": now}})
+		if err != nil {
+			logger.Warn("Failed to claim message retention purge", "error", err, "client_id", client.ID.Hex())
+			continue
+		}
+		if claim.ModifiedCount == 0 {
+			continue
+		}
+
+		s.purgeClient(ctx, &client, now)
+	}
+}
+
+// purgeClient removes one client's expired messages and records the outcome in the audit log
+func (s *MessageRetentionScheduler) purgeClient(ctx context.Context, client *models.Client, now time.Time) {
+	policy := client.MessageRetention
+	action := policy.Action
+	if !retentionActions[action] {
+		action = "delete"
+	}
+	// Purge whole UTC days so each day is rolled up exactly once
+	cutoff := now.AddDate(0, 0, -policy.RetentionDays).UTC().Truncate(24 * time.Hour)
+
+	purged, exempted, err := purgeExpiredMessages(ctx, s.db, client.ID, cutoff, action, policy.ExemptLeads)
+
+	event := &models.AuditEvent{
+		ClientID:   client.ID.Hex(),
+		UserID:     "system",
+		Action:     "DELETE",
+		Resource:   "messages",
+		ResourceID: client.ID.Hex(),
+		Success:    err == nil,
+		Changes: map[string]interface{}{
+			"reason":                 "retention_policy",
+			"retention_days":         policy.RetentionDays,
+			"action":                 action,
+			"cutoff":                 cutoff,
+			"purged_count":           purged,
+			"exempt_leads":           policy.ExemptLeads,
+			"exempted_conversations": exempted,
+		},
+	}
+	if err != nil {
+		event.ErrorMessage = err.Error()
+		logger.Warn("Message retention purge failed", "error", err, "client_id", client.ID.Hex(), "purged", purged)
+	} else if purged > 0 {
+		logger.Info("Purged expired messages", "client_id", client.ID.Hex(), "count", purged, "action", action)
+	}
+	if s.auditLogger != nil {
+		s.auditLogger.Log(event)
+	}
+
+	s.db.Collection("clients").UpdateOne(ctx, bson.M{"_id": client.ID}, bson.M{
+		"$set": bson.M{"message_retention.last_purged_count": purged},
+	})
+}
+
+// purgeExpiredMessages rolls up, optionally archives and then deletes a client's messages older than cutoff.
+// It returns the number of deleted messages and the number of lead conversations that were kept.
+func purgeExpiredMessages(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID, cutoff time.Time, action string, exemptLeads bool) (int64, int, error) {
+	messagesCollection := db.Collection("messages")
+	filter := bson.M{
+		"client_id": clientID,
+		"timestamp": bson.M{"$lt": cutoff},
+	}
+
+	exempted := 0
+	if exemptLeads {
+		leadConversations, err := leadConversationIDs(ctx, db, clientID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to load lead conversations: %w", err)
+		}
+		if len(leadConversations) > 0 {
+			filter["conversation_id"] = bson.M{"$nin": leadConversations}
+			exempted = len(leadConversations)
+		}
+	}
+
+	// Preserve per-day analytics for the messages about to be removed
+	if err := rollupMessageStats(ctx, messagesCollection, filter); err != nil {
+		return 0, exempted, fmt.Errorf("failed to roll up message stats: %w", err)
+	}
+
+	if action == "archive" {
+		cursor, err := messagesCollection.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: filter}},
+			{{Key: "$merge", Value: bson.M{
+				"into":           "messages_archive",
+				"on":             "_id",
+				"whenMatched":    "keepExisting",
+				"whenNotMatched": "insert",
+			}}},
+		})
+		if err != nil {
+			return 0, exempted, fmt.Errorf("failed to archive messages: %w", err)
+		}
+		cursor.Close(ctx)
+	}
+
+	result, err := messagesCollection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, exempted, fmt.Errorf("failed to delete messages: %w", err)
+	}
+	return result.DeletedCount, exempted, nil
+}
+
+// leadConversationIDs returns conversations where the visitor left an email, booked a demo or was tagged as a lead
+func leadConversationIDs(ctx context.Context, db *mongo.Database, clientID primitive.ObjectID) (bson.A, error) {
+	fromMessages, err := db.Collection("messages").Distinct(ctx, "conversation_id", bson.M{
+		"client_id": clientID,
+		"$or": bson.A{
+			bson.M{"user_email": bson.M{"$nin": bson.A{nil, ""}}},
+			bson.M{"demo_booking": bson.M{"$exists": true}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tagged, err := db.Collection("conversation_tags").Distinct(ctx, "session_id", bson.M{
+		"client_id": clientID,
+		"$or": bson.A{
+			bson.M{"tags": "lead"},
+			bson.M{"auto_tags": "lead"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[interface{}]bool)
+	ids := bson.A{}
+	for _, id := range append(fromMessages, tagged...) {
+		if id == nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// messageDailyStatsCollection holds the per-day totals of purged messages
+const messageDailyStatsCollection = "message_daily_stats"
+
+// rollupMessageStats stores the daily message, token and conversation totals of the matched messages
+// in message_daily_stats, keyed by client, day and channel. Purges cover whole days, so a day's totals
+// are written once; a retry after a failed delete sees the same (or, after a partial delete, fewer)
+// messages, and keeping the larger value makes the merge idempotent.
+func rollupMessageStats(ctx context.Context, messagesCollection *mongo.Collection, filter bson.M) error {
+	cursor, err := messagesCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"client_id": "$client_id",
+				"day":       bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$timestamp"}},
+				"channel":   messageChannelExpr(),
+			},
+			"messages":      bson.M{"$sum": 1},
+			"tokens":        bson.M{"$sum": "$token_cost"},
+			"conversations": bson.M{"$addToSet": "$conversation_id"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"client_id":     "$_id.client_id",
+			"day":           "$_id.day",
+			"channel":       "$_id.channel",
+			"messages":      1,
+			"tokens":        1,
+			"conversations": bson.M{"$size": "$conversations"},
+			"updated_at":    "$$NOW",
+		}}},
+		{{Key: "$merge", Value: bson.M{
+			"into": messageDailyStatsCollection,
+			"on":   "_id",
+			"whenMatched": bson.A{
+				bson.M{"$set": bson.M{
+					"messages":      bson.M{"$max": bson.A{"$messages", "$$new.messages"}},
+					"tokens":        bson.M{"$max": bson.A{"$tokens", "$$new.tokens"}},
+					"conversations": bson.M{"$max": bson.A{"$conversations", "$$new.conversations"}},
+					"updated_at":    "$$new.updated_at",
+				}},
+			},
+			"whenNotMatched": "insert",
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// rolledUpDay is one day of purged-message totals from message_daily_stats
+type rolledUpDay struct {
+	Day           string `bson:"_id"`
+	Messages      int64  `bson:"messages"`
+	Tokens        int64  `bson:"tokens"`
+	Conversations int64  `bson:"conversations"`
+}
+
+// loadRolledUpStats returns the client's purged-message totals per UTC day within [start, end],
+// limited to channel when set, ordered by day
+func loadRolledUpStats(ctx context.Context, statsCollection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time, channel string) ([]rolledUpDay, error) {
+	match := bson.M{
+		"client_id": clientID,
+		"day": bson.M{
+			"$gte": start.UTC().Format("2006-01-02"),
+			"$lte": end.UTC().Format("2006-01-02"),
+		},
+	}
+	if channel != "" {
+		match["channel"] = channel
+	}
+
+	cursor, err := statsCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$day",
+			"messages":      bson.M{"$sum": "$messages"},
+			"tokens":        bson.M{"$sum": "$tokens"},
+			"conversations": bson.M{"$sum": "$conversations"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var days []rolledUpDay
+	if err := cursor.All(ctx, &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// mergeRolledUpSeries adds purged-message totals into a daily time series, appending days that have
+// no live messages left. Rolled-up days are UTC, so with another timezone they are approximate.
+func mergeRolledUpSeries(timeSeries []gin.H, rolledUp []rolledUpDay) []gin.H {
+	if len(rolledUp) == 0 {
+		return timeSeries
+	}
+	byDate := make(map[string]gin.H, len(timeSeries))
+	for _, point := range timeSeries {
+		if date, ok := point["date"].(string); ok {
+			byDate[date] = point
+		}
+	}
+	for _, day := range rolledUp {
+		if point, ok := byDate[day.Day]; ok {
+			point["total_messages"] = bsonInt64(point["total_messages"]) + day.Messages
+			point["total_tokens"] = bsonInt64(point["total_tokens"]) + day.Tokens
+			point["total_conversations"] = bsonInt64(point["total_conversations"]) + day.Conversations
+			continue
+		}
+		point := gin.H{
+			"period":              "day",
+			"date":                day.Day,
+			"total_messages":      day.Messages,
+			"total_tokens":        day.Tokens,
+			"active_users":        0, // not kept for purged messages
+			"total_conversations": day.Conversations,
+		}
+		byDate[day.Day] = point
+		timeSeries = append(timeSeries, point)
+	}
+	sort.SliceStable(timeSeries, func(i, j int) bool {
+		di, _ := timeSeries[i]["date"].(string)
+		dj, _ := timeSeries[j]["date"].(string)
+		return di < dj
+	})
+	return timeSeries
+}
+
+// bsonInt64 converts a numeric value decoded from an aggregation into int64
+func bsonInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// nextRetentionPurge estimates when the client's expired messages will next be purged
+func nextRetentionPurge(cfg *config.Config, policy *models.MessageRetentionPolicy) *time.Time {
+	if policy == nil || policy.RetentionDays <= 0 || !cfg.MessageRetentionEnabled {
+		return nil
+	}
+	next := policy.LastPurgeAt.Add(retentionPurgeInterval(cfg))
+	// Never purged or overdue: picked up by the next scheduler check
+	if policy.LastPurgeAt.IsZero() || next.Before(time.Now()) {
+		next = time.Now().Add(retentionCheckInterval)
+	}
+	next = next.Truncate(time.Minute)
+	return &next
+}
+
+// handleGetRetentionPolicy returns the message retention policy and next purge time for the authenticated client
+func handleGetRetentionPolicy(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		var client models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&client); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error_code": "client_not_found",
+					"message":    "Client not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to fetch retention policy",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, retentionPolicyResponse(cfg, client.MessageRetention))
+	}
+}
+
+// handleUpdateRetentionPolicy sets the authenticated client's message retention policy.
+// retention_days of 0 keeps messages forever.
+func handleUpdateRetentionPolicy(cfg *config.Config, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var request struct {
+			RetentionDays *int   `json:"retention_days"`
+			Action        string `json:"action"`
+			ExemptLeads   *bool  `json:"exempt_leads"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		set := bson.M{"updated_at": time.Now()}
+
+		if request.RetentionDays != nil {
+			if *request.RetentionDays < 0 || *request.RetentionDays > maxRetentionDays {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_retention_days",
+					"message":    fmt.Sprintf("retention_days must be between 0 (keep forever) and %d", maxRetentionDays),
+				})
+				return
+			}
+			set["message_retention.retention_days"] = *request.RetentionDays
+		}
+
+		if request.Action != "" {
+			action := strings.ToLower(strings.TrimSpace(request.Action))
+			if !retentionActions[action] {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_action",
+					"message":    "Action must be delete or archive",
+				})
+				return
+			}
+			set["message_retention.action"] = action
+		}
+
+		if request.ExemptLeads != nil {
+			set["message_retention.exempt_leads"] = *request.ExemptLeads
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		// Default the action for clients configuring retention for the first time
+		clientsCollection.UpdateOne(ctx, bson.M{
+			"_id":                      clientObjID,
+			"message_retention.action": bson.M{"$exists": false},
+		}, bson.M{"$set": bson.M{"message_retention.action": "delete"}})
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{"$set": set})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update retention policy",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		var updatedClient models.Client
+		if err := clientsCollection.FindOne(ctx, bson.M{"_id": clientObjID}).Decode(&updatedClient); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Retention policy updated successfully",
+			})
+			return
+		}
+
+		response := retentionPolicyResponse(cfg, updatedClient.MessageRetention)
+		response["message"] = "Retention policy updated successfully"
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// retentionPolicyResponse describes a policy, its current cutoff and the next purge time
+func retentionPolicyResponse(cfg *config.Config, policy *models.MessageRetentionPolicy) gin.H {
+	if policy == nil {
+		policy = &models.MessageRetentionPolicy{Action: "delete"}
+	}
+
+	response := gin.H{
+		"message_retention": policy,
+		"enabled":           policy.RetentionDays > 0,
+		"next_purge_at":     nextRetentionPurge(cfg, policy),
+	}
+	if policy.RetentionDays > 0 {
+		response["cutoff"] = time.Now().AddDate(0, 0, -policy.RetentionDays).Truncate(time.Minute)
+	}
+	return response
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMergeRolledUpSeries(t *testing.T) {
+	live := []gin.H{
+		{"period": "day", "date": "2026-03-02", "total_messages": int32(4), "total_tokens": int32(40), "active_users": int32(2), "total_conversations": int32(2)},
+	}
+	rolledUp := []rolledUpDay{
+		{Day: "2026-03-01", Messages: 10, Tokens: 100, Conversations: 3},
+		{Day: "2026-03-02", Messages: 6, Tokens: 60, Conversations: 1},
+	}
+
+	series := mergeRolledUpSeries(live, rolledUp)
+	if len(series) != 2 {
+		t.Fatalf("len(series) = %d, want 2", len(series))
+	}
+	if series[0]["date"] != "2026-03-01" || series[0]["total_messages"] != int64(10) {
+		t.Errorf("purged-only day = %v, want 2026-03-01 with 10 messages", series[0])
+	}
+	if series[1]["total_messages"] != int64(10) || series[1]["total_tokens"] != int64(100) || series[1]["total_conversations"] != int64(3) {
+		t.Errorf("mixed day = %v, want live and purged totals added", series[1])
+	}
+	if got := mergeRolledUpSeries(live, nil); len(got) != 1 {
+		t.Errorf("no rolled-up days changed the series: %v", got)
+	}
+}
@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"context"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// PER-SESSION TOKEN BUDGET
+// ===================
+
+// sessionLimitReply is returned instead of generating once a session has used its token cap
+const sessionLimitReply = "Thanks for chatting with us! This conversation has reached its limit. Please leave your contact details or start a new conversation later and our team will be happy to help."
+
+// sessionTokenCapForClient returns the per-session token cap (0 = unlimited)
+func sessionTokenCapForClient(cfg *config.Config, client *models.Client) int {
+	if client.SessionTokenCap > 0 {
+		return client.SessionTokenCap
+	}
+	return cfg.DefaultSessionTokenCap
+}
+
+// sessionTokensUsed sums token_cost over the messages of one conversation
+func sessionTokensUsed(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, sessionID string) (int, error) {
+	cursor, err := messagesCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"client_id": clientID, "conversation_id": sessionID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": nil,
+			"tokens": bson.M{"$sum": bson.M{
+				"$toInt": bson.M{"$ifNull": bson.A{"$token_cost", 0}},
+			}},
+		}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Tokens int `bson:"tokens"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Tokens, nil
+}
@@ -0,0 +1,276 @@
+package routes
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// SIGNED CONVERSATION CONTEXT
+// ===================
+//
+// Host sites that already know the user (e.g. a logged-in customer portal) can pass facts
+// to /public/chat. The payload is base64url-encoded JSON such as
+//
+//	{"facts": {"name": "Asha", "account_tier": "gold"}, "exp": 1735689600}
+//
+// sent as "context", with "context_signature" set to the hex HMAC-SHA256 of the encoded
+// payload using the client's context signing secret. Verified facts are injected into the
+// prompt as trusted known facts.
+
+const (
+	maxSignedContextBytes  = 4096 // encoded payload size
+	maxKnownFacts          = 20
+	maxKnownFactKeyChars   = 50
+	maxKnownFactValueChars = 500
+	maxSignedContextTTL    = 24 * time.Hour // exp may be at most this far in the future
+)
+
+var errInvalidSignedContext = errors.New("invalid signed context")
+
+// signedContextPayload is the decoded "context" payload
+type signedContextPayload struct {
+	Facts map[string]string `json:"facts"`
+	Exp   int64             `json:"exp"` // unix seconds
+}
+
+// verifySignedContext checks the signature and expiry of an encoded context payload and returns its facts
+func verifySignedContext(secret, encoded, signature string) (map[string]string, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("%w: signed context is not enabled for this client", errInvalidSignedContext)
+	}
+	if signature == "" {
+		return nil, fmt.Errorf("%w: context_signature is required", errInvalidSignedContext)
+	}
+	if len(encoded) > maxSignedContextBytes {
+		return nil, fmt.Errorf("%w: context exceeds %d bytes", errInvalidSignedContext, maxSignedContextBytes)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	expected := mac.Sum(nil)
+	provided, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil || !hmac.Equal(provided, expected) {
+		return nil, fmt.Errorf("%w: signature mismatch", errInvalidSignedContext)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: context must be base64url-encoded JSON", errInvalidSignedContext)
+	}
+	var payload signedContextPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("%w: context must be base64url-encoded JSON", errInvalidSignedContext)
+	}
+
+	now := time.Now()
+	if payload.Exp == 0 {
+		return nil, fmt.Errorf("%w: exp is required", errInvalidSignedContext)
+	}
+	exp := time.Unix(payload.Exp, 0)
+	if now.After(exp) {
+		return nil, fmt.Errorf("%w: context has expired", errInvalidSignedContext)
+	}
+	if exp.Sub(now) > maxSignedContextTTL {
+		return nil, fmt.Errorf("%w: exp must be within %s", errInvalidSignedContext, maxSignedContextTTL)
+	}
+
+	if len(payload.Facts) > maxKnownFacts {
+		return nil, fmt.Errorf("%w: at most %d facts are allowed", errInvalidSignedContext, maxKnownFacts)
+	}
+	for key, value := range payload.Facts {
+		if strings.TrimSpace(key) == "" || len(key) > maxKnownFactKeyChars || len(value) > maxKnownFactValueChars {
+			return nil, fmt.Errorf("%w: fact keys must be 1-%d characters and values at most %d", errInvalidSignedContext, maxKnownFactKeyChars, maxKnownFactValueChars)
+		}
+	}
+	return payload.Facts, nil
+}
+
+// formatKnownFacts renders verified facts for the {{known_facts}} prompt section
+func formatKnownFacts(facts map[string]string) string {
+	if len(facts) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(facts))
+	for key := range facts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Facts are single-line so they can't break out of their section
+	flatten := strings.NewReplacer("\r", " ", "\n", " ")
+	var b strings.Builder
+	b.WriteString("✅ VERIFIED CUSTOMER INFORMATION (provided by the client's own website):\n")
+	for _, key := range keys {
+		b.WriteString(fmt.Sprintf("• %s: %s\n", flatten.Replace(strings.TrimSpace(key)), flatten.Replace(strings.TrimSpace(facts[key]))))
+	}
+	b.WriteString("Treat these as known facts about the customer. Use them to personalise your answer and do NOT ask the customer for information listed here.\n\n")
+	return b.String()
+}
+
+// handleGetContextSigningSecret reports whether signed context is enabled for the authenticated client
+func handleGetContextSigningSecret(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"enabled": clientDoc.ContextSigningSecret != "",
+		})
+	}
+}
+
+// handleRotateContextSigningSecret generates a new context signing secret for the authenticated
+// client. The secret is only returned by this call; earlier signatures stop validating.
+func handleRotateContextSigningSecret(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		secret, err := utils.GenerateSecureRandomString(32)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to generate secret",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$set": bson.M{
+				"context_signing_secret": secret,
+				"updated_at":             time.Now(),
+			},
+		})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update context signing secret",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Context signing secret rotated. Store it securely; it will not be shown again.",
+			"secret":  secret,
+		})
+	}
+}
+
+// handleDisableContextSigning removes the secret so signed context is rejected
+func handleDisableContextSigning(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, bson.M{
+			"$unset": bson.M{"context_signing_secret": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		})
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to disable signed context",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Signed context disabled",
+		})
+	}
+}
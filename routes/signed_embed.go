@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// SIGNED EMBED SNIPPET
+// ===================
+//
+// POST /client/embed-snippet/signed signs the client's embed configuration (client ID, allowed domains,
+// expiry) with EMBED_SIGNING_SECRET and returns the token with ready-to-paste snippets. Domains default to
+// the client's domain whitelist. The token is verified by the domain middleware for clients with
+// require_signed_embed (see middleware/embed_token.go); issuing a new token does not revoke older ones
+// before they expire.
+
+const (
+	maxSignedEmbedDomains = 50
+	maxEmbedTokenTTLDays  = 365
+)
+
+// signedEmbedDomains validates and normalizes the domains for a token, falling back to the whitelist
+func signedEmbedDomains(requested, whitelist []string) ([]string, error) {
+	domains := requested
+	if len(domains) == 0 {
+		domains = whitelist
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("at least one domain is required (none given and the domain whitelist is empty)")
+	}
+	if len(domains) > maxSignedEmbedDomains {
+		return nil, fmt.Errorf("at most %d domains are allowed", maxSignedEmbedDomains)
+	}
+
+	seen := make(map[string]bool, len(domains))
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !middleware.ValidDomainPattern(domain) {
+			return nil, fmt.Errorf("invalid domain %q: use host names such as example.com or *.example.com", domain)
+		}
+		if !seen[domain] {
+			seen[domain] = true
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized, nil
+}
+
+// handleGenerateSignedEmbed issues a signed embed token for the authenticated client.
+// Optional body: {"domains": ["example.com", "*.example.com"], "expires_in_days": n} (1-365).
+func handleGenerateSignedEmbed(cfg *config.Config, clientsCollection *mongo.Collection, auditLogger *models.AuditLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		if cfg.EmbedSigningSecret == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error_code": "embed_signing_disabled",
+				"message":    "Signed embeds are not configured on this server",
+			})
+			return
+		}
+
+		var req struct {
+			Domains       []string `json:"domains"`
+			ExpiresInDays *int     `json:"expires_in_days"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_input",
+					"message":    "Invalid request body",
+				})
+				return
+			}
+		}
+		ttlDays := cfg.EmbedTokenTTLDays
+		if req.ExpiresInDays != nil {
+			ttlDays = *req.ExpiresInDays
+		}
+		if ttlDays < 1 || ttlDays > maxEmbedTokenTTLDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_input",
+				"message":    fmt.Sprintf("expires_in_days must be between 1 and %d", maxEmbedTokenTTLDays),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		domains, err := signedEmbedDomains(req.Domains, clientDoc.DomainWhitelist)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_domains",
+				"message":    err.Error(),
+			})
+			return
+		}
+
+		now := time.Now()
+		expiresAt := now.AddDate(0, 0, ttlDays)
+		token, err := middleware.SignEmbedToken(cfg.EmbedSigningSecret, middleware.EmbedTokenClaims{
+			ClientID: clientObjID.Hex(),
+			Domains:  domains,
+			IssuedAt: now.Unix(),
+			Expires:  expiresAt.Unix(),
+		})
+		if err != nil {
+			logger.Error("Failed to sign embed token", "error", err, "client_id", clientObjID.Hex())
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "internal_error",
+				"message":    "Failed to sign embed configuration",
+			})
+			return
+		}
+
+		if auditLogger != nil {
+			auditLogger.LogAsync(&models.AuditEvent{
+				ClientID:   clientObjID.Hex(),
+				UserID:     middleware.GetUserID(c),
+				Action:     "CREATE",
+				Resource:   "signed_embed",
+				ResourceID: clientObjID.Hex(),
+				IPAddress:  c.ClientIP(),
+				UserAgent:  c.Request.UserAgent(),
+				RequestID:  middleware.GetRequestID(c),
+				Success:    true,
+				Changes: map[string]interface{}{
+					"domains":    domains,
+					"expires_at": expiresAt,
+				},
+			})
+		}
+
+		baseURL := "http://" + c.Request.Host
+		if c.Request.TLS != nil {
+			baseURL = "https://" + c.Request.Host
+		}
+		frameURL := baseURL + "/embed/chatframe/" + clientObjID.Hex() + "?embed_token=" + token
+
+		c.JSON(http.StatusOK, gin.H{
+			"client_id":            clientObjID.Hex(),
+			"embed_token":          token,
+			"domains":              domains,
+			"expires_at":           expiresAt,
+			"require_signed_embed": clientDoc.RequireSignedEmbed,
+			"header":               middleware.EmbedTokenHeader,
+			"script_tag": `<script>
+	(function() {
+		var chatbot = document.createElement('iframe');
+		chatbot.src = '` + frameURL + `';
+		chatbot.style.cssText = 'position: fixed; bottom: 20px; right: 20px; width: 350px; height: 500px; border: none; border-radius: 10px; box-shadow: 0 4px 20px rgba(0,0,0,0.15); z-index: 9999;';
+		document.body.appendChild(chatbot);
+	})();
+</script>`,
+			"iframe_tag": `<iframe src="` + frameURL + `" width="350" height="500" style="border: none; border-radius: 10px; box-shadow: 0 4px 20px rgba(0,0,0,0.15);"></iframe>`,
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSignedEmbedDomains(t *testing.T) {
+	got, err := signedEmbedDomains([]string{" Example.com", "*.example.com", "example.com"}, []string{"ignored.com"})
+	if want := []string{"example.com", "*.example.com"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got (%v, %v), want %v", got, err, want)
+	}
+
+	got, err = signedEmbedDomains(nil, []string{"shop.io"})
+	if want := []string{"shop.io"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("whitelist fallback: got (%v, %v), want %v", got, err, want)
+	}
+
+	for _, domains := range [][]string{nil, {"bad domain.com"}, {"app.*.example.com"}} {
+		if _, err := signedEmbedDomains(domains, nil); err == nil {
+			t.Errorf("%v: expected an error", domains)
+		}
+	}
+}
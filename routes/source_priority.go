@@ -0,0 +1,262 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// SOURCE OF TRUTH PRIORITY
+// ===================
+//
+// The persona, uploaded PDFs and crawled pages can contradict each other (an old price on the website, a
+// new one in the PDF). Each client orders the three sources; the context lists them in that order, labels
+// every block with its authority and tells the model to trust the higher one on conflict. After the reply,
+// the highest-priority source it drew from is recorded as the winner on the message's source attribution.
+
+const (
+	sourcePersona = "persona"
+	sourcePDF     = "pdf"
+	sourceCrawl   = "crawl"
+
+	// personaContextLabel starts the persona block in the context; the prompt checks for it
+	personaContextLabel = "AI PERSONALITY & KNOWLEDGE"
+)
+
+// defaultSourcePriority is the order used before clients configured one: persona, then PDFs, then the website
+var defaultSourcePriority = []string{sourcePersona, sourcePDF, sourceCrawl}
+
+// sourcePriorityFor returns the client's source order, completed with any sources it leaves out in default order
+func sourcePriorityFor(client *models.Client) []string {
+	priority := make([]string, 0, len(defaultSourcePriority))
+	seen := make(map[string]bool)
+	for _, list := range [][]string{client.SourcePriority, defaultSourcePriority} {
+		for _, source := range list {
+			if isKnownSource(source) && !seen[source] {
+				seen[source] = true
+				priority = append(priority, source)
+			}
+		}
+	}
+	return priority
+}
+
+func isKnownSource(source string) bool {
+	return source == sourcePersona || source == sourcePDF || source == sourceCrawl
+}
+
+// buildSourceBlocks writes the persona, PDF and crawl blocks of the context in priority order. Only sources
+// with content are included and numbered, so "authority 1" is always the most trusted one present.
+func buildSourceBlocks(priority []string, personaContent string, pdfChunks, crawledChunks []models.ContentChunk) string {
+	var present []string
+	for _, source := range priority {
+		switch {
+		case source == sourcePersona && personaContent != "",
+			source == sourcePDF && len(pdfChunks) > 0,
+			source == sourceCrawl && len(crawledChunks) > 0:
+			present = append(present, source)
+		}
+	}
+	if len(present) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	if len(present) > 1 {
+		b.WriteString("SOURCE PRIORITY: The sources below are ranked by authority (1 is highest). ")
+		b.WriteString("If they disagree, answer with the information from the higher-authority source and do not mention the conflict.\n\n")
+	}
+
+	for i, source := range present {
+		authority := fmt.Sprintf("[authority %d of %d]", i+1, len(present))
+		switch source {
+		case sourcePersona:
+			fmt.Fprintf(&b, "%s %s:\n%s\n\n---\n\n", personaContextLabel, authority, personaContent)
+		case sourcePDF:
+			fmt.Fprintf(&b, "COMPANY INFORMATION - UPLOADED DOCUMENTS %s:\n\n", authority)
+			for _, chunk := range pdfChunks {
+				fmt.Fprintf(&b, "%s\n\n", chunk.Text)
+			}
+			b.WriteString("---\n\n")
+		case sourceCrawl:
+			fmt.Fprintf(&b, "COMPANY INFORMATION - WEBSITE PAGES %s:\n\n", authority)
+			for _, chunk := range crawledChunks {
+				fmt.Fprintf(&b, "%s\n\n", chunk.Text)
+			}
+			b.WriteString("---\n\n")
+		}
+	}
+	return b.String()
+}
+
+// personaAttributionScore is the best token overlap between reply and a paragraph of the persona,
+// scored like attributeReplyToSources scores chunks
+func personaAttributionScore(reply, personaContent string) float64 {
+	replyTokens := attributionTokens(reply)
+	if len(replyTokens) == 0 || personaContent == "" {
+		return 0
+	}
+
+	best := 0.0
+	for _, paragraph := range strings.Split(personaContent, "\n\n") {
+		paragraphTokens := attributionTokens(paragraph)
+		overlap := 0
+		for token := range replyTokens {
+			if paragraphTokens[token] {
+				overlap++
+			}
+		}
+		if score := float64(overlap) / float64(len(replyTokens)); score > best {
+			best = score
+		}
+	}
+	return math.Round(best*1000) / 1000
+}
+
+// resolveWinningSource records on attribution which source the reply is credited to: the highest-priority
+// source whose content it overlaps with, or "none"
+func resolveWinningSource(attribution *models.SourceAttribution, priority []string, reply, personaContent string) {
+	if attribution == nil {
+		return
+	}
+	attribution.PersonaScore = personaAttributionScore(reply, personaContent)
+	attribution.SourcePriority = priority
+	attribution.WinningSource = "none"
+
+	scores := map[string]float64{
+		sourcePersona: attribution.PersonaScore,
+		sourcePDF:     attribution.PDFScore,
+		sourceCrawl:   attribution.CrawlScore,
+	}
+	for _, source := range priority {
+		if scores[source] >= attributionMinScore {
+			attribution.WinningSource = source
+			return
+		}
+	}
+}
+
+// handleGetSourcePriority returns the client's source-of-truth order
+func handleGetSourcePriority(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"source_priority": sourcePriorityFor(clientDoc),
+			"is_default":      len(clientDoc.SourcePriority) == 0,
+		})
+	}
+}
+
+// handleUpdateSourcePriority sets the client's source-of-truth order; an empty list restores the default
+func handleUpdateSourcePriority(clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		var request struct {
+			SourcePriority []string `json:"source_priority"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_request",
+				"message":    "Invalid request body",
+				"details":    err.Error(),
+			})
+			return
+		}
+
+		seen := make(map[string]bool)
+		for _, source := range request.SourcePriority {
+			if !isKnownSource(source) || seen[source] {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_source_priority",
+					"message":    `source_priority must list "persona", "pdf" and "crawl", each at most once`,
+				})
+				return
+			}
+			seen[source] = true
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		update := bson.M{"$set": bson.M{"source_priority": request.SourcePriority, "updated_at": time.Now()}}
+		if len(request.SourcePriority) == 0 {
+			update = bson.M{"$unset": bson.M{"source_priority": ""}, "$set": bson.M{"updated_at": time.Now()}}
+		}
+		result, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientObjID}, update)
+		invalidateClientConfig(clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "update_failed",
+				"message":    "Failed to update source priority",
+			})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error_code": "client_not_found",
+				"message":    "Client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":         "Source priority updated",
+			"source_priority": sourcePriorityFor(&models.Client{SourcePriority: request.SourcePriority}),
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"saas-chatbot-platform/models"
+)
+
+func TestSourcePriorityFor(t *testing.T) {
+	tests := []struct {
+		configured []string
+		want       []string
+	}{
+		{nil, []string{"persona", "pdf", "crawl"}},
+		{[]string{"crawl", "pdf", "persona"}, []string{"crawl", "pdf", "persona"}},
+		{[]string{"pdf"}, []string{"pdf", "persona", "crawl"}},
+		{[]string{"pdf", "bogus", "pdf"}, []string{"pdf", "persona", "crawl"}},
+	}
+	for _, tt := range tests {
+		if got := sourcePriorityFor(&models.Client{SourcePriority: tt.configured}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sourcePriorityFor(%v) = %v, want %v", tt.configured, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSourceBlocks(t *testing.T) {
+	pdf := []models.ContentChunk{{Text: "Premium costs $20 per month."}}
+	crawl := []models.ContentChunk{{Text: "Premium costs $15 per month.\n\nSource: https://example.com/pricing"}}
+
+	blocks := buildSourceBlocks([]string{"pdf", "persona", "crawl"}, "", pdf, crawl)
+	pdfAt := strings.Index(blocks, "UPLOADED DOCUMENTS [authority 1 of 2]")
+	crawlAt := strings.Index(blocks, "WEBSITE PAGES [authority 2 of 2]")
+	if !strings.HasPrefix(blocks, "SOURCE PRIORITY:") || pdfAt < 0 || crawlAt < pdfAt {
+		t.Errorf("unexpected blocks:\n%s", blocks)
+	}
+	if strings.Contains(blocks, personaContextLabel) {
+		t.Error("empty persona was included")
+	}
+
+	single := buildSourceBlocks(defaultSourcePriority, "We are Acme.", nil, nil)
+	if strings.Contains(single, "SOURCE PRIORITY") || !strings.HasPrefix(single, personaContextLabel+" [authority 1 of 1]:") {
+		t.Errorf("unexpected single block:\n%s", single)
+	}
+}
+
+func TestResolveWinningSource(t *testing.T) {
+	reply := "Premium costs twenty dollars monthly"
+	persona := "Tone: friendly and brief.\n\nPremium costs twenty dollars monthly for everyone."
+
+	attribution := &models.SourceAttribution{PDFScore: 0.9, CrawlScore: 0.9}
+	resolveWinningSource(attribution, []string{"crawl", "pdf", "persona"}, reply, persona)
+	if attribution.WinningSource != "crawl" {
+		t.Errorf("winner = %q, want crawl", attribution.WinningSource)
+	}
+
+	attribution = &models.SourceAttribution{PDFScore: 0.9, CrawlScore: 0}
+	resolveWinningSource(attribution, defaultSourcePriority, reply, persona)
+	if attribution.WinningSource != "persona" || attribution.PersonaScore != 1 {
+		t.Errorf("winner = %q (persona score %v), want persona", attribution.WinningSource, attribution.PersonaScore)
+	}
+
+	attribution = &models.SourceAttribution{}
+	resolveWinningSource(attribution, defaultSourcePriority, reply, "")
+	if attribution.WinningSource != "none" {
+		t.Errorf("winner = %q, want none", attribution.WinningSource)
+	}
+}
+
+func TestAttributeReplyToSources(t *testing.T) {
+	reply := "Premium plan costs twenty dollars monthly"
+	matching := "Premium plan costs twenty dollars monthly."
+	unrelated := "Contact support anytime by email."
+
+	tests := []struct {
+		name         string
+		pdf, crawl   []models.ContentChunk
+		wantDominant string
+		wantWinner   string // with crawl ranked above pdf
+		wantTop      int
+	}{
+		{
+			name:         "pdf dominant",
+			pdf:          []models.ContentChunk{{ChunkID: "pdf-1", Text: matching}},
+			crawl:        []models.ContentChunk{{Text: unrelated + "\n\nSource: https://example.com/help"}},
+			wantDominant: "pdf",
+			wantWinner:   "pdf",
+			wantTop:      1,
+		},
+		{
+			name:         "crawl dominant",
+			pdf:          []models.ContentChunk{{ChunkID: "pdf-1", Text: unrelated}},
+			crawl:        []models.ContentChunk{{Text: matching + "\n\nSource: https://example.com/pricing"}},
+			wantDominant: "crawl",
+			wantWinner:   "crawl",
+			wantTop:      1,
+		},
+		{
+			name:         "tie",
+			pdf:          []models.ContentChunk{{ChunkID: "pdf-1", Text: matching}},
+			crawl:        []models.ContentChunk{{Text: matching + "\n\nSource: https://example.com/pricing"}},
+			wantDominant: "pdf",
+			wantWinner:   "crawl",
+			wantTop:      2,
+		},
+		{
+			name:         "empty chunks",
+			wantDominant: "none",
+			wantWinner:   "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attribution := attributeReplyToSources(reply, tt.pdf, tt.crawl)
+			if attribution.DominantSource != tt.wantDominant {
+				t.Errorf("dominant = %q (pdf %v, crawl %v), want %q",
+					attribution.DominantSource, attribution.PDFScore, attribution.CrawlScore, tt.wantDominant)
+			}
+			if len(attribution.TopSources) != tt.wantTop {
+				t.Errorf("top sources = %v, want %d", attribution.TopSources, tt.wantTop)
+			}
+
+			resolveWinningSource(attribution, []string{"crawl", "pdf", "persona"}, reply, "")
+			if attribution.WinningSource != tt.wantWinner {
+				t.Errorf("winner = %q, want %q", attribution.WinningSource, tt.wantWinner)
+			}
+		})
+	}
+
+	if attribution := attributeReplyToSources("", []models.ContentChunk{{Text: matching}}, nil); attribution.DominantSource != "none" {
+		t.Errorf("empty reply dominant = %q, want none", attribution.DominantSource)
+	}
+}
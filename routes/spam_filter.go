@@ -0,0 +1,245 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// SPAM / OFF-TOPIC FILTER
+// ===================
+
+// spamKeywords are phrases typical of crypto, gambling and marketing spam
+var spamKeywords = []string{
+	"bitcoin", "crypto", "btc", "ethereum", "usdt", "airdrop", "nft", "forex", "binary option",
+	"casino", "betting", "jackpot", "lottery", "viagra", "cialis", "loan approved", "work from home",
+	"earn money", "make money", "double your", "guaranteed profit", "investment opportunity",
+	"click here", "free money", "seo services", "backlinks", "buy followers", "dm me", "telegram me",
+}
+
+// abusiveWords are insults and profanity that never warrant an AI reply
+var abusiveWords = []string{
+	"fuck", "fucking", "shit", "bitch", "bastard", "asshole", "dickhead", "motherfucker",
+	"chutiya", "madarchod", "bhenchod", "behenchod", "randi", "gandu", "harami",
+}
+
+var (
+	spamURLPattern    = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+	keyboardRows      = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"}
+	spamNudgeTemplate = "I'm here to help with questions about %s. What would you like to know?"
+)
+
+// maxSignalScore caps what any one signal contributes, keeping it below the default
+// threshold so a message is only filtered when at least two signals agree
+const maxSignalScore = 0.6
+
+// minKeyboardRunLength is how many adjacent keys of one keyboard row make a mash; four
+// matches real words like "property" and "liberty"
+const minKeyboardRunLength = 5
+
+// classifySpam scores a message from 0 (clean) to 1 (certain spam) using keyboard-mash, repetition,
+// link, spam keyword and abuse signals, and returns the score with the strongest reason.
+// Each signal is capped at maxSignalScore.
+func classifySpam(message string) (float64, string) {
+	lower := strings.ToLower(strings.TrimSpace(message))
+	if lower == "" {
+		return 0, ""
+	}
+
+	score, reason, strongest := 0.0, "", 0.0
+	add := func(value float64, why string) {
+		value = minFloat(value, maxSignalScore)
+		if value > strongest {
+			strongest, reason = value, why
+		}
+		score += value
+	}
+
+	words := strings.FieldsFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) })
+
+	// Keyboard mashing: words without vowels, long consonant runs or keyboard-row sequences
+	longWords, gibberish := 0, 0
+	for _, w := range words {
+		if len(w) < 5 || !isASCIIWord(w) {
+			continue
+		}
+		longWords++
+		if isGibberishWord(w) {
+			gibberish++
+		}
+	}
+	if longWords > 0 && gibberish > 0 {
+		add(0.9*float64(gibberish)/float64(longWords), "gibberish")
+	}
+
+	// Long runs of the same character ("aaaaaaaa", "!!!!!!!!")
+	if run := longestRun(lower); run >= 6 && run*2 >= len([]rune(lower)) {
+		add(0.8, "repeated_characters") // the message is mostly one character
+	} else if run >= 6 {
+		add(0.5, "repeated_characters")
+	}
+
+	// Link floods
+	if links := len(spamURLPattern.FindAllString(lower, -1)); links >= 2 {
+		add(0.5, "links")
+	} else if links == 1 {
+		add(0.2, "links")
+	}
+
+	// Spam keywords
+	hits := 0
+	for _, kw := range spamKeywords {
+		if containsWord(lower, kw) {
+			hits++
+		}
+	}
+	if hits > 0 {
+		add(minFloat(0.35*float64(hits), 0.9), "spam_keywords")
+	}
+
+	// Abuse
+	for _, bad := range abusiveWords {
+		if containsWord(lower, bad) {
+			add(0.8, "abuse")
+			break
+		}
+	}
+
+	if score > 1 {
+		score = 1
+	}
+	return score, reason
+}
+
+// isGibberishWord reports whether a word looks like keyboard mashing
+func isGibberishWord(w string) bool {
+	vowels, run, maxRun := 0, 0, 0
+	for _, r := range w {
+		if strings.ContainsRune("aeiouy", r) {
+			vowels++
+			run = 0
+			continue
+		}
+		if unicode.IsLetter(r) {
+			run++
+			if run > maxRun {
+				maxRun = run
+			}
+		}
+	}
+	if vowels == 0 || maxRun >= 6 {
+		return true
+	}
+	for _, row := range keyboardRows {
+		for i := 0; i+minKeyboardRunLength <= len(row); i++ {
+			if strings.Contains(w, row[i:i+minKeyboardRunLength]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isASCIIWord(w string) bool {
+	for _, r := range w {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// longestRun returns the length of the longest run of one repeated non-space character
+func longestRun(s string) int {
+	longest, run := 0, 0
+	var prev rune
+	for _, r := range s {
+		if r == prev && !unicode.IsSpace(r) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		prev = r
+	}
+	return longest
+}
+
+// containsWord reports whether phrase occurs in text on word boundaries
+func containsWord(text, phrase string) bool {
+	for start := 0; start < len(text); {
+		i := strings.Index(text[start:], phrase)
+		if i < 0 {
+			return false
+		}
+		idx, end := start+i, start+i+len(phrase)
+		if (idx == 0 || !isWordByte(text[idx-1])) && (end == len(text) || !isWordByte(text[end])) {
+			return true
+		}
+		start = idx + 1
+	}
+	return false
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= '0' && b <= '9'
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// spamThresholdForClient returns the client's spam threshold, falling back to the platform default
+func spamThresholdForClient(cfg *config.Config, client *models.Client) float64 {
+	if client.SpamThreshold > 0 {
+		return client.SpamThreshold
+	}
+	return cfg.SpamThreshold
+}
+
+// respondToSpamMessage stores the message flagged as spam and replies with a neutral nudge.
+// Gemini is not called and no tokens are charged.
+func respondToSpamMessage(ctx context.Context, c *gin.Context, messagesCollection *mongo.Collection, clientDoc *models.Client, req ChatRequest, score float64, reason string, welcomeVariant string) {
+	logger.Info("Public chat message classified as spam",
+		"client_id", clientDoc.ID.Hex(), "session_id", req.SessionID, "score", score, "reason", reason)
+
+	reply := fmt.Sprintf(spamNudgeTemplate, companyNameFor(clientDoc))
+	meta := &aiResponseMeta{Spam: &models.SpamVerdict{Score: score, Reason: reason}}
+	messageID, err := persistMessage(ctx, messagesCollection, clientDoc.ID, req, reply, 0, meta, welcomeVariant, visitorTrackingFor(clientDoc, req), c.Request)
+	if err != nil {
+		logger.Warn("Failed to persist spam message", "error", err, "client_id", clientDoc.ID.Hex(), "session_id", req.SessionID)
+	}
+
+	remainingTokens := clientDoc.TokenLimit - clientDoc.TokenUsed
+	if remainingTokens < 0 {
+		remainingTokens = 0
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reply":            reply,
+		"token_cost":       0,
+		"remaining_tokens": remainingTokens,
+		"conversation_id":  req.SessionID,
+		"message_id":       messageID.Hex(),
+		"latency_ms":       0,
+		"timestamp":        time.Now().Unix(),
+		"filtered":         true,
+	})
+}
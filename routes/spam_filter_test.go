@@ -0,0 +1,44 @@
+package routes
+
+import "testing"
+
+func TestClassifySpamRealWords(t *testing.T) {
+	const threshold = 0.7
+
+	clean := []string{
+		"What is your return policy for property insurance?",
+		"Does the liberty plan cover my family?",
+		"I need help with my qwerty keyboard order",
+		"Rhythm classes on Saturdays?",
+		"You guys are shit at replying",
+		"Is bitcoin accepted?",
+	}
+	for _, msg := range clean {
+		if score, reason := classifySpam(msg); score >= threshold {
+			t.Errorf("classifySpam(%q) = %.2f (%s), want below %.2f", msg, score, reason, threshold)
+		}
+	}
+
+	spam := []string{
+		"asdfgh jklasd!!!!!!!!",
+		"earn money with bitcoin casino, click here https://a.example https://b.example",
+	}
+	for _, msg := range spam {
+		if score, _ := classifySpam(msg); score < threshold {
+			t.Errorf("classifySpam(%q) = %.2f, want at least %.2f", msg, score, threshold)
+		}
+	}
+}
+
+func TestIsGibberishWord(t *testing.T) {
+	for _, w := range []string{"property", "liberty", "typewriter", "asking", "rhythm"} {
+		if isGibberishWord(w) {
+			t.Errorf("isGibberishWord(%q) = true, want false", w)
+		}
+	}
+	for _, w := range []string{"asdfgh", "zxcvbn", "qwrtpsd"} {
+		if !isGibberishWord(w) {
+			t.Errorf("isGibberishWord(%q) = false, want true", w)
+		}
+	}
+}
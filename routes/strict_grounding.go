@@ -0,0 +1,166 @@
+package routes
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===================
+// STRICT GROUNDING
+// ===================
+//
+// The prompt forbids inventing facts, but clients in regulated fields (legal, medical) can opt in to a
+// hard check: after generation every sentence that states something is compared with the retrieved
+// context, the persona and the visitor's message. A sentence is kept when enough of its significant
+// words (STRICT_GROUNDING_MIN_OVERLAP) and every number in it appear there; otherwise it is replaced
+// with "I don't have that information." Questions and short conversational sentences are left alone.
+// The number of stripped claims is stored on the message and reported in the quality metrics.
+
+const (
+	strictGroundingFallback = "I don't have that information."
+	defaultStrictMinOverlap = 0.5
+	minStrictClaimTokens    = 3 // sentences with fewer significant words are conversational, not claims
+)
+
+// claimNumberPattern matches numbers, prices and dates in a sentence, e.g. "12", "4.5", "1,200"
+var claimNumberPattern = regexp.MustCompile(`\d+(?:[.,:/]\d+)*`)
+
+// listMarkerPattern matches the indentation and bullet or number starting a list item
+var listMarkerPattern = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s+`)
+
+// strictGroundingMinOverlap is the share of a sentence's significant words the context must contain
+func strictGroundingMinOverlap(cfg *config.Config) float64 {
+	if cfg.StrictGroundingMinOverlap > 0 && cfg.StrictGroundingMinOverlap <= 1 {
+		return cfg.StrictGroundingMinOverlap
+	}
+	return defaultStrictMinOverlap
+}
+
+// splitSentences splits a line after ".", "!" or "?" followed by a space
+func splitSentences(line string) []string {
+	var sentences []string
+	start := 0
+	for i := 0; i < len(line)-1; i++ {
+		if strings.ContainsRune(".!?", rune(line[i])) && line[i+1] == ' ' {
+			sentences = append(sentences, line[start:i+1])
+			start = i + 2
+		}
+	}
+	if start < len(line) {
+		sentences = append(sentences, line[start:])
+	}
+	return sentences
+}
+
+// groundingKnowledge indexes the words and numbers the reply may draw on
+type groundingKnowledge struct {
+	tokens  map[string]bool
+	numbers map[string]bool
+}
+
+func newGroundingKnowledge(texts ...string) *groundingKnowledge {
+	knowledge := &groundingKnowledge{tokens: make(map[string]bool), numbers: make(map[string]bool)}
+	for _, text := range texts {
+		for token := range attributionTokens(text) {
+			knowledge.tokens[token] = true
+		}
+		for _, number := range claimNumberPattern.FindAllString(text, -1) {
+			knowledge.numbers[number] = true
+		}
+	}
+	return knowledge
+}
+
+// supports reports whether a sentence is a question, conversational, or backed by the knowledge
+func (k *groundingKnowledge) supports(sentence string, minOverlap float64) bool {
+	trimmed := strings.TrimSpace(sentence)
+	if strings.HasSuffix(trimmed, "?") {
+		return true
+	}
+	lower := strings.ToLower(trimmed)
+	for _, phrase := range noInformationPhrases {
+		if strings.Contains(lower, phrase) {
+			return true
+		}
+	}
+
+	for _, number := range claimNumberPattern.FindAllString(trimmed, -1) {
+		if !k.numbers[number] {
+			return false
+		}
+	}
+
+	tokens := attributionTokens(trimmed)
+	if len(tokens) < minStrictClaimTokens {
+		return true
+	}
+	found := 0
+	for token := range tokens {
+		if k.tokens[token] {
+			found++
+		}
+	}
+	return float64(found)/float64(len(tokens)) >= minOverlap
+}
+
+// enforceGrounding replaces the reply's unsupported sentences with strictGroundingFallback, keeping the
+// line layout, and returns the new reply and how many sentences were replaced
+func enforceGrounding(reply string, knowledge *groundingKnowledge, minOverlap float64) (string, int) {
+	stripped := 0
+	lines := strings.Split(reply, "\n")
+	for i, line := range lines {
+		// List markers ("1. ", "- ") are layout, not part of the first sentence
+		marker := listMarkerPattern.FindString(line)
+		var kept []string
+		for _, sentence := range splitSentences(line[len(marker):]) {
+			if knowledge.supports(sentence, minOverlap) {
+				kept = append(kept, sentence)
+				continue
+			}
+			stripped++
+			// One fallback stands in for a run of unsupported sentences
+			if len(kept) == 0 || kept[len(kept)-1] != strictGroundingFallback {
+				kept = append(kept, strictGroundingFallback)
+			}
+		}
+		if len(kept) > 0 {
+			lines[i] = marker + strings.Join(kept, " ")
+		}
+	}
+	return strings.Join(lines, "\n"), stripped
+}
+
+// sumStrippedClaims totals the claims strict grounding removed from the client's replies in [start, end]
+func sumStrippedClaims(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, start, end time.Time) (int, error) {
+	cursor, err := messagesCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"client_id":       clientID,
+			"timestamp":       bson.M{"$gte": start, "$lte": end},
+			"stripped_claims": bson.M{"$gt": 0},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$stripped_claims"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Total int `bson:"total"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Total, nil
+}
@@ -0,0 +1,48 @@
+package routes
+
+import "testing"
+
+func TestEnforceGrounding(t *testing.T) {
+	knowledge := newGroundingKnowledge(
+		"The Business plan costs 49 per month and includes priority support and custom branding.",
+		"How much is the business plan?",
+	)
+
+	tests := []struct {
+		name         string
+		reply        string
+		want         string
+		wantStripped int
+	}{
+		{
+			"supported",
+			"The Business plan costs 49 per month. It includes priority support and custom branding.",
+			"The Business plan costs 49 per month. It includes priority support and custom branding.",
+			0,
+		},
+		{
+			"wrong number",
+			"The Business plan costs 59 per month. Would you like a demo?",
+			"I don't have that information. Would you like a demo?",
+			1,
+		},
+		{
+			"invented claims collapse into one fallback",
+			"Happy to help! Our offices operate across Germany, Brazil and Japan. Founded by former astronauts.",
+			"Happy to help! I don't have that information.",
+			2,
+		},
+		{
+			"list layout kept",
+			"Plans:\n1. Business plan costs 49 per month with priority support\n- Enterprise plan offers dedicated hosting clusters worldwide",
+			"Plans:\n1. Business plan costs 49 per month with priority support\n- I don't have that information.",
+			1,
+		},
+	}
+	for _, tt := range tests {
+		got, stripped := enforceGrounding(tt.reply, knowledge, 0.5)
+		if got != tt.want || stripped != tt.wantStripped {
+			t.Errorf("%s: got (%q, %d), want (%q, %d)", tt.name, got, stripped, tt.want, tt.wantStripped)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"saas-chatbot-platform/models"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ===================
+// STRUCTURED OUTPUT (JSON MODE)
+// ===================
+
+const (
+	maxStructuredEntities         = 10
+	maxStructuredSuggestedActions = 4
+	maxSuggestedActionChars       = 60
+)
+
+// structuredOutputInstruction is appended to the prompt when a structured reply is requested
+const structuredOutputInstruction = `
+
+OUTPUT FORMAT: Respond with a JSON object only.
+- "reply": your answer to the user, written exactly as you would otherwise answer
+- "intent": a short snake_case label for what the user wants (e.g. "pricing_inquiry", "support_request", "greeting")
+- "entities": facts from the user's message as {"type", "value"} pairs (e.g. product, date, location, quantity); empty if none
+- "suggested_actions": up to 4 short follow-up messages the user might send next, phrased as the user`
+
+// structuredReplySchema constrains Gemini's JSON mode to the StructuredReply shape
+var structuredReplySchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"reply":  {Type: genai.TypeString},
+		"intent": {Type: genai.TypeString},
+		"entities": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"type":  {Type: genai.TypeString},
+					"value": {Type: genai.TypeString},
+				},
+				Required: []string{"type", "value"},
+			},
+		},
+		"suggested_actions": {
+			Type:  genai.TypeArray,
+			Items: &genai.Schema{Type: genai.TypeString},
+		},
+	},
+	Required: []string{"reply", "intent", "entities", "suggested_actions"},
+}
+
+// setStructuredOutput switches the model between JSON mode and plain text
+func setStructuredOutput(model *genai.GenerativeModel, enabled bool) {
+	if enabled {
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = structuredReplySchema
+		return
+	}
+	model.ResponseMIMEType = ""
+	model.ResponseSchema = nil
+}
+
+// parseStructuredReply validates a JSON mode response. Entities and suggested actions are cleaned up and
+// capped; a response without a reply is rejected so the caller can fall back to plain text.
+func parseStructuredReply(text string) (*models.StructuredReply, error) {
+	text = strings.TrimSpace(text)
+	// Tolerate a fenced code block around the JSON
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	var parsed models.StructuredReply
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &parsed); err != nil {
+		return nil, err
+	}
+	parsed.Reply = strings.TrimSpace(parsed.Reply)
+	if parsed.Reply == "" {
+		return nil, errors.New("structured reply has no reply text")
+	}
+	parsed.Intent = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(parsed.Intent), " ", "_"))
+
+	entities := make([]models.StructuredEntity, 0, len(parsed.Entities))
+	for _, e := range parsed.Entities {
+		e.Type = strings.TrimSpace(e.Type)
+		e.Value = strings.TrimSpace(e.Value)
+		if e.Type == "" || e.Value == "" {
+			continue
+		}
+		entities = append(entities, e)
+		if len(entities) == maxStructuredEntities {
+			break
+		}
+	}
+	parsed.Entities = entities
+
+	actions := make([]string, 0, len(parsed.SuggestedActions))
+	for _, a := range parsed.SuggestedActions {
+		a = strings.TrimSpace(a)
+		if a == "" || len(a) > maxSuggestedActionChars {
+			continue
+		}
+		actions = append(actions, a)
+		if len(actions) == maxStructuredSuggestedActions {
+			break
+		}
+	}
+	parsed.SuggestedActions = actions
+
+	return &parsed, nil
+}
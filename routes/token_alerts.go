@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ===================
+// TOKEN LIMIT ALERTING
+// ===================
+//
+// Chats refused for lack of tokens are counted per client (OTel counter plus a counter on the client
+// document), and usage alerts are emailed as soon as a reply pushes a client past a threshold rather than
+// waiting for the periodic scan. handleGetTokens adds the alert level and a depletion forecast.
+
+// tokenForecastWindowDays is how far back the burn rate for the depletion forecast looks
+const tokenForecastWindowDays = 7
+
+var (
+	tokenLimitRejectionsOnce    sync.Once
+	tokenLimitRejectionsCounter metric.Int64Counter
+)
+
+func tokenLimitRejections() metric.Int64Counter {
+	tokenLimitRejectionsOnce.Do(func() {
+		counter, err := otel.Meter("saas-chatbot-platform").Int64Counter("tokens.limit_rejections",
+			metric.WithDescription("Chat requests rejected because the client's token limit was reached"),
+		)
+		if err != nil {
+			logger.Warn("Failed to register token limit rejection metric", "error", err)
+		}
+		tokenLimitRejectionsCounter = counter
+	})
+	return tokenLimitRejectionsCounter
+}
+
+// recordTokenLimitRejection counts a chat refused for lack of tokens and makes sure the client's
+// "exhausted" alert goes out. channel is "widget", "dashboard" or "telegram".
+func recordTokenLimitRejection(cfg *config.Config, clientsCollection *mongo.Collection, clientID primitive.ObjectID, channel string) {
+	if counter := tokenLimitRejections(); counter != nil {
+		counter.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("client_id", clientID.Hex()),
+			attribute.String("channel", channel),
+		))
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		_, err := clientsCollection.UpdateOne(ctx, bson.M{"_id": clientID}, bson.M{
+			"$inc": bson.M{"token_limit_rejections": 1},
+			"$set": bson.M{"last_token_limit_rejection_at": time.Now()},
+		})
+		if err != nil {
+			logger.Warn("Failed to record token limit rejection", "error", err, "client_id", clientID.Hex())
+		}
+		logger.Warn("Chat rejected: token limit reached", "client_id", clientID.Hex(), "channel", channel)
+
+		if err := services.NewAlertEvaluator(*cfg, services.NewSMTPEmailSender(*cfg), clientsCollection).EvaluateAndNotify(ctx, clientID); err != nil {
+			logger.Warn("Failed to evaluate token alerts", "error", err, "client_id", clientID.Hex())
+		}
+	}()
+}
+
+// evaluateTokenAlerts emails a usage alert in the background once a balance of used out of limit
+// tokens crosses TOKEN_WARN_PERCENT; below it nothing is looked up
+func evaluateTokenAlerts(cfg *config.Config, clientsCollection *mongo.Collection, clientID primitive.ObjectID, used, limit int) {
+	if services.TokenAlertLevel(*cfg, used, limit) == "none" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := services.NewAlertEvaluator(*cfg, services.NewSMTPEmailSender(*cfg), clientsCollection).EvaluateAndNotify(ctx, clientID); err != nil {
+			logger.Warn("Failed to evaluate token alerts", "error", err, "client_id", clientID.Hex())
+		}
+	}()
+}
+
+// tokensUsedSince sums the token cost of the client's messages since the given time
+func tokensUsedSince(ctx context.Context, messagesCollection *mongo.Collection, clientID primitive.ObjectID, since time.Time) (int, error) {
+	cursor, err := messagesCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"client_id": clientID, "timestamp": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "tokens": bson.M{"$sum": bson.M{"$ifNull": []interface{}{"$token_cost", 0}}}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Tokens int `bson:"tokens"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Tokens, nil
+}
+
+// forecastWindowStart is where the burn-rate window starts: tokenForecastWindowDays back, or the client's
+// creation if it is younger, but at least one day back so a new client's first chats aren't extrapolated
+func forecastWindowStart(createdAt, now time.Time) time.Time {
+	start := now.AddDate(0, 0, -tokenForecastWindowDays)
+	if createdAt.After(start) {
+		start = createdAt
+	}
+	if latest := now.Add(-24 * time.Hour); start.After(latest) {
+		start = latest
+	}
+	return start
+}
+
+// forecastTokenDepletion projects the remaining tokens forward at the average daily burn over [start, now)
+func forecastTokenDepletion(remaining, usedInWindow int, start, now time.Time) *models.TokenForecast {
+	days := now.Sub(start).Hours() / 24
+	forecast := &models.TokenForecast{
+		WindowDays:         int(math.Round(days)),
+		TokensUsedInWindow: usedInWindow,
+	}
+	if days <= 0 || usedInWindow <= 0 {
+		return forecast
+	}
+
+	forecast.AvgDailyTokens = math.Round(float64(usedInWindow)/days*10) / 10
+	daysLeft := 0.0
+	if remaining > 0 {
+		daysLeft = float64(remaining) / (float64(usedInWindow) / days)
+	}
+	rounded := math.Round(daysLeft*10) / 10
+	whole := math.Floor(daysLeft)
+	depletion := now.AddDate(0, 0, int(whole)).Add(time.Duration((daysLeft - whole) * float64(24*time.Hour)))
+	forecast.DaysToDepletion = &rounded
+	forecast.ProjectedDepletionDate = &depletion
+	return forecast
+}
@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForecastWindowStart(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		want      time.Time
+	}{
+		{"established client", now.AddDate(-1, 0, 0), now.AddDate(0, 0, -7)},
+		{"three-day-old client", now.AddDate(0, 0, -3), now.AddDate(0, 0, -3)},
+		{"created today", now.Add(-2 * time.Hour), now.Add(-24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		if got := forecastWindowStart(tt.createdAt, now); !got.Equal(tt.want) {
+			t.Errorf("%s: forecastWindowStart = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestForecastTokenDepletion(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	start := now.AddDate(0, 0, -7)
+
+	forecast := forecastTokenDepletion(3500, 7000, start, now)
+	if forecast.WindowDays != 7 || forecast.AvgDailyTokens != 1000 {
+		t.Errorf("window %d days, avg %v/day, want 7 days at 1000/day", forecast.WindowDays, forecast.AvgDailyTokens)
+	}
+	if forecast.DaysToDepletion == nil || *forecast.DaysToDepletion != 3.5 {
+		t.Fatalf("days to depletion = %v, want 3.5", forecast.DaysToDepletion)
+	}
+	if want := now.Add(84 * time.Hour); !forecast.ProjectedDepletionDate.Equal(want) {
+		t.Errorf("projected depletion = %v, want %v", forecast.ProjectedDepletionDate, want)
+	}
+
+	if exhausted := forecastTokenDepletion(0, 7000, start, now); exhausted.DaysToDepletion == nil || *exhausted.DaysToDepletion != 0 {
+		t.Errorf("exhausted balance: days to depletion = %v, want 0", exhausted.DaysToDepletion)
+	}
+
+	idle := forecastTokenDepletion(3500, 0, start, now)
+	if idle.DaysToDepletion != nil || idle.ProjectedDepletionDate != nil {
+		t.Errorf("idle client got a forecast: %+v", idle)
+	}
+}
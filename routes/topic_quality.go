@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"sort"
+	"time"
+
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// PER-TOPIC QUALITY
+// ===================
+
+// topicQualityTally accumulates feedback counts and quality scores for one topic
+type topicQualityTally struct {
+	positive, negative int
+	scoreSum           float64
+	scoreCount         int
+}
+
+func (t *topicQualityTally) add(feedback models.MessageFeedback) {
+	if feedback.FeedbackType == "positive" {
+		t.positive++
+	} else {
+		t.negative++
+	}
+	if feedback.QualityScore > 0 {
+		t.scoreSum += feedback.QualityScore
+		t.scoreCount++
+	}
+}
+
+func (t *topicQualityTally) result() models.TopicQuality {
+	quality := models.TopicQuality{
+		TotalFeedback:    t.positive + t.negative,
+		PositiveFeedback: t.positive,
+		NegativeFeedback: t.negative,
+	}
+	if quality.TotalFeedback > 0 {
+		quality.SatisfactionRate = float64(t.positive) / float64(quality.TotalFeedback)
+	}
+	if t.scoreCount > 0 {
+		quality.AverageQualityScore = t.scoreSum / float64(t.scoreCount)
+	}
+	return quality
+}
+
+// topicQualityTracker builds the per-topic breakdown and its daily trend over a period's feedback
+type topicQualityTracker struct {
+	overall map[string]*topicQualityTally
+	daily   map[string]map[string]*topicQualityTally // date -> topic -> tally
+	loc     *time.Location                           // timezone the days are taken in
+}
+
+func newTopicQualityTracker(loc *time.Location) *topicQualityTracker {
+	return &topicQualityTracker{
+		loc:     loc,
+		overall: make(map[string]*topicQualityTally),
+		daily:   make(map[string]map[string]*topicQualityTally),
+	}
+}
+
+// add records feedback on an answer about topic
+func (t *topicQualityTracker) add(topic string, feedback models.MessageFeedback) {
+	tallyFor(t.overall, topic).add(feedback)
+
+	date := feedback.Timestamp.In(t.loc).Format("2006-01-02")
+	day, ok := t.daily[date]
+	if !ok {
+		day = make(map[string]*topicQualityTally)
+		t.daily[date] = day
+	}
+	tallyFor(day, topic).add(feedback)
+}
+
+func tallyFor(tallies map[string]*topicQualityTally, topic string) *topicQualityTally {
+	tally, ok := tallies[topic]
+	if !ok {
+		tally = &topicQualityTally{}
+		tallies[topic] = tally
+	}
+	return tally
+}
+
+// breakdown returns satisfaction and average quality per topic over the whole period
+func (t *topicQualityTracker) breakdown() map[string]models.TopicQuality {
+	return topicQualities(t.overall)
+}
+
+// trend returns the per-topic quality for each day with feedback, oldest first
+func (t *topicQualityTracker) trend() []models.TopicQualityPoint {
+	dates := make([]string, 0, len(t.daily))
+	for date := range t.daily {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	points := make([]models.TopicQualityPoint, 0, len(dates))
+	for _, date := range dates {
+		points = append(points, models.TopicQualityPoint{Date: date, Topics: topicQualities(t.daily[date])})
+	}
+	return points
+}
+
+func topicQualities(tallies map[string]*topicQualityTally) map[string]models.TopicQuality {
+	out := make(map[string]models.TopicQuality, len(tallies))
+	for topic, tally := range tallies {
+		out[topic] = tally.result()
+	}
+	return out
+}
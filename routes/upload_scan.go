@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+	"saas-chatbot-platform/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================
+// UPLOAD VIRUS SCANNING
+// ===================
+
+// respondUploadScanError answers uploads rejected by the virus scan and records infected ones in the audit
+// log. It returns false when err isn't a scan failure so the caller's own error handling applies.
+func respondUploadScanError(c *gin.Context, auditLogger *models.AuditLogger, clientID, filename string, err error) bool {
+	var infected *services.InfectedFileError
+	if errors.As(err, &infected) {
+		if auditLogger != nil {
+			auditLogger.Log(&models.AuditEvent{
+				ClientID:     clientID,
+				UserID:       middleware.GetUserID(c),
+				Action:       "REJECT",
+				Resource:     "pdf",
+				IPAddress:    c.ClientIP(),
+				UserAgent:    c.Request.UserAgent(),
+				RequestID:    middleware.GetRequestID(c),
+				Success:      false,
+				ErrorMessage: infected.Error(),
+				Changes: map[string]interface{}{
+					"reason":          "malware_detected",
+					"filename":        filename,
+					"signature":       infected.Signature,
+					"scanner":         infected.Scanner,
+					"quarantine_path": infected.QuarantinePath,
+				},
+			})
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error_code": "infected_file",
+			"message":    "The file was rejected because it contains malware",
+			"signature":  infected.Signature,
+		})
+		return true
+	}
+
+	if errors.Is(err, services.ErrScanUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error_code": "virus_scan_unavailable",
+			"message":    "Uploads can't be scanned for viruses right now. Please try again in a few minutes.",
+		})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// VISITOR TRACKING CONSENT
+// ===================
+
+// visitorTracking is what may be recorded about a widget visitor alongside their messages
+type visitorTracking struct {
+	// Track records IP, geolocation, user agent and referrer, and remembers the visitor's name by IP
+	Track bool
+	// AnonymizeIP truncates the IP before it is stored or looked up (see Client.AnonymizeIPs)
+	AnonymizeIP bool
+}
+
+// visitorTrackingFor decides what to record for a chat message. When the client requires consent, visitors
+// are only tracked once the widget reports they accepted tracking; without consent only the conversation
+// itself is stored, which is all that's needed to answer and continue the chat.
+func visitorTrackingFor(clientDoc *models.Client, req ChatRequest) visitorTracking {
+	track := true
+	if clientDoc.Branding.RequireTrackingConsent {
+		track = req.TrackingConsent != nil && *req.TrackingConsent
+	}
+	return visitorTracking{
+		Track:       track,
+		AnonymizeIP: clientDoc.AnonymizeIPs,
+	}
+}
@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// WARM RECONNECT
+// ===================
+//
+// When a visitor comes back to a conversation after WARM_RECONNECT_GAP_MINUTES of silence, clients with
+// Branding.WarmReconnect get a short "welcome back" line in front of the first reply, recalling what was
+// discussed: the first sentence of the stored conversation summary, or else the topics of the last few
+// messages.
+
+const (
+	defaultWarmReconnectGap = time.Hour
+	warmReconnectMessages   = 5   // recent messages the topics are taken from when there is no summary
+	maxWarmReconnectChars   = 200 // longest recap taken from the summary
+)
+
+// warmReconnectGap is the silence after which the next message counts as a return visit
+func warmReconnectGap(cfg *config.Config) time.Duration {
+	if cfg.WarmReconnectGapMinutes > 0 {
+		return time.Duration(cfg.WarmReconnectGapMinutes) * time.Minute
+	}
+	return defaultWarmReconnectGap
+}
+
+// firstSentence returns the first sentence of text, cut at maxChars runes
+func firstSentence(text string, maxChars int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	for _, end := range []string{". ", "! ", "? "} {
+		if idx := strings.Index(text, end); idx != -1 {
+			text = text[:idx]
+		}
+	}
+	text = strings.TrimRight(text, ".!?")
+	if runes := []rune(text); len(runes) > maxChars {
+		text = strings.TrimSpace(string(runes[:maxChars])) + "..."
+	}
+	return strings.TrimSpace(text)
+}
+
+// warmReconnectLine builds the welcome-back line from the conversation summary, falling back to the
+// topics of the recent messages (oldest first). It returns "" when there is nothing to recall.
+func warmReconnectLine(summary string, recent []models.Message) string {
+	if recap := firstSentence(summary, maxWarmReconnectChars); recap != "" {
+		if !strings.HasSuffix(recap, "...") {
+			recap += "."
+		}
+		return "Welcome back! Last time we discussed: " + recap
+	}
+
+	var topics []string
+	seen := make(map[string]bool)
+	for i := len(recent) - 1; i >= 0; i-- {
+		for _, topic := range extractTopics(recent[i].Message) {
+			if !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+	switch len(topics) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("Welcome back! Last time we talked about %s.", topics[0])
+	default:
+		return fmt.Sprintf("Welcome back! Last time we talked about %s and %s.", strings.Join(topics[:len(topics)-1], ", "), topics[len(topics)-1])
+	}
+}
+
+// warmReconnectGreeting returns the welcome-back line for a visitor returning to the conversation after
+// the gap, or "" if the conversation is new, still active or has nothing to recall
+func warmReconnectGreeting(ctx context.Context, cfg *config.Config, messagesCollection *mongo.Collection, clientID primitive.ObjectID, sessionID string) string {
+	filter := bson.M{
+		"client_id":       clientID,
+		"conversation_id": sessionID,
+		"spam":            bson.M{"$exists": false},
+	}
+	cursor, err := messagesCollection.Find(ctx, filter, options.Find().
+		SetSort(bson.M{"timestamp": -1}).
+		SetLimit(warmReconnectMessages).
+		SetProjection(bson.M{"message": 1, "timestamp": 1}))
+	if err != nil {
+		return ""
+	}
+	defer cursor.Close(ctx)
+
+	var recent []models.Message
+	if err := cursor.All(ctx, &recent); err != nil || len(recent) == 0 {
+		return ""
+	}
+	if time.Since(recent[0].Timestamp) < warmReconnectGap(cfg) {
+		return ""
+	}
+	// Oldest first, as everywhere else history is handled
+	for i, j := 0, len(recent)-1; i < j; i, j = i+1, j-1 {
+		recent[i], recent[j] = recent[j], recent[i]
+	}
+
+	var summary ConversationSummary
+	_ = messagesCollection.Database().Collection("conversation_summaries").
+		FindOne(ctx, bson.M{"client_id": clientID, "conversation_id": sessionID}).Decode(&summary)
+	return warmReconnectLine(summary.Summary, recent)
+}
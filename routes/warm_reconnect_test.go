@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+
+	"saas-chatbot-platform/models"
+)
+
+func TestWarmReconnectLine(t *testing.T) {
+	summary := "The visitor compared the Pro and Business plans for a team of 12. They asked about annual billing!"
+	if got, want := warmReconnectLine(summary, nil), "Welcome back! Last time we discussed: The visitor compared the Pro and Business plans for a team of 12."; got != want {
+		t.Errorf("summary line = %q, want %q", got, want)
+	}
+
+	long := strings.Repeat("pricing ", 60)
+	if got := warmReconnectLine(long, nil); len([]rune(got)) > maxWarmReconnectChars+60 || !strings.HasSuffix(got, "pricing...") {
+		t.Errorf("long summary line = %q", got)
+	}
+
+	if got := warmReconnectLine("", nil); got != "" {
+		t.Errorf("empty line = %q, want none", got)
+	}
+
+	recent := []models.Message{{Message: "what does it cost?"}, {Message: "can i book a demo"}}
+	got := warmReconnectLine("", recent)
+	if !strings.HasPrefix(got, "Welcome back! Last time we talked about ") {
+		t.Errorf("topic line = %q", got)
+	}
+}
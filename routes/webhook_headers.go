@@ -0,0 +1,214 @@
+package routes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/models"
+)
+
+// ===================
+// CUSTOM WEBHOOK HEADERS
+// ===================
+//
+// Clients can attach static headers (typically Authorization or an API key) to outbound webhook requests
+// so their endpoints can authenticate us with their own scheme. Values are encrypted with AES-256-GCM
+// under WEBHOOK_HEADERS_KEY (or JWT_SECRET) before they are stored, and the API only ever returns the
+// header names. Headers we set ourselves - the X-Chatbot-* signature headers, Content-Type and transport
+// headers - cannot be overridden.
+
+const (
+	maxWebhookHeaders          = 10
+	maxWebhookHeaderValueBytes = 4096
+	webhookHeaderCipherPrefix  = "v1:"
+)
+
+// webhookHeaderNamePattern is an RFC 7230 token
+var webhookHeaderNamePattern = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// reservedWebhookHeaders are set by the platform or the HTTP transport; any X-Chatbot-* name is reserved too
+var reservedWebhookHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Type":      true,
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Upgrade":           true,
+	"Te":                true,
+	"Trailer":           true,
+	"User-Agent":        true,
+}
+
+// validateWebhookHeaders checks header names and values supplied by a client
+func validateWebhookHeaders(headers map[string]string) error {
+	if len(headers) > maxWebhookHeaders {
+		return fmt.Errorf("at most %d headers are allowed", maxWebhookHeaders)
+	}
+	seen := make(map[string]bool)
+	for name, value := range headers {
+		if !webhookHeaderNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		canonical := http.CanonicalHeaderKey(name)
+		if reservedWebhookHeaders[canonical] || strings.HasPrefix(canonical, "X-Chatbot-") {
+			return fmt.Errorf("header %q is set by the platform and cannot be overridden", canonical)
+		}
+		if seen[canonical] {
+			return fmt.Errorf("header %q is listed more than once", canonical)
+		}
+		seen[canonical] = true
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("header %q needs a value", canonical)
+		}
+		if len(value) > maxWebhookHeaderValueBytes {
+			return fmt.Errorf("header %q is longer than %d bytes", canonical, maxWebhookHeaderValueBytes)
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("header %q contains invalid characters", canonical)
+		}
+	}
+	return nil
+}
+
+// webhookHeadersCipher builds the AEAD for header values from WEBHOOK_HEADERS_KEY, or JWT_SECRET if unset
+func webhookHeadersCipher(cfg *config.Config) (cipher.AEAD, error) {
+	secret := cfg.WebhookHeadersKey
+	if secret == "" {
+		secret = cfg.JWTSecret
+	}
+	if secret == "" {
+		return nil, errors.New("no key configured for webhook header encryption")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealWebhookHeaders validates and encrypts headers for storage, sorted by name
+func sealWebhookHeaders(cfg *config.Config, headers map[string]string) ([]models.WebhookHeader, error) {
+	if err := validateWebhookHeaders(headers); err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	aead, err := webhookHeadersCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]models.WebhookHeader, 0, len(headers))
+	for name, value := range headers {
+		ciphertext, err := sealWebhookHeaderValue(aead, value)
+		if err != nil {
+			return nil, err
+		}
+		sealed = append(sealed, models.WebhookHeader{
+			Name:  http.CanonicalHeaderKey(name),
+			Value: ciphertext,
+		})
+	}
+	sort.Slice(sealed, func(i, j int) bool { return sealed[i].Name < sealed[j].Name })
+	return sealed, nil
+}
+
+// sealWebhookHeaderValue encrypts one value for storage with a random nonce
+func sealWebhookHeaderValue(aead cipher.AEAD, value string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ciphertext := aead.Seal(nonce, nonce, []byte(value), nil)
+	return webhookHeaderCipherPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// openWebhookHeaderValue decrypts a stored header value
+func openWebhookHeaderValue(aead cipher.AEAD, stored string) (string, error) {
+	if !strings.HasPrefix(stored, webhookHeaderCipherPrefix) {
+		return "", errors.New("unrecognized header encryption format")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, webhookHeaderCipherPrefix))
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted header value")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("header value could not be decrypted (was the key changed?)")
+	}
+	return string(plain), nil
+}
+
+// sealIntegrationSecret encrypts an integration credential (OAuth or API token) for storage with the
+// webhook header key; an empty value stays empty so it still clears the setting
+func sealIntegrationSecret(cfg *config.Config, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	aead, err := webhookHeadersCipher(cfg)
+	if err != nil {
+		return "", err
+	}
+	return sealWebhookHeaderValue(aead, value)
+}
+
+// isSealedIntegrationSecret reports whether a stored credential is encrypted; credentials saved
+// before encryption was added are plaintext
+func isSealedIntegrationSecret(stored string) bool {
+	return strings.HasPrefix(stored, webhookHeaderCipherPrefix)
+}
+
+// openIntegrationSecret decrypts a stored credential, passing legacy plaintext values through
+func openIntegrationSecret(cfg *config.Config, stored string) (string, error) {
+	if !isSealedIntegrationSecret(stored) {
+		return stored, nil
+	}
+	aead, err := webhookHeadersCipher(cfg)
+	if err != nil {
+		return "", err
+	}
+	return openWebhookHeaderValue(aead, stored)
+}
+
+// applyWebhookHeaders decrypts the stored headers onto an outbound request. Call it before setting the
+// platform's own headers so those always win.
+func applyWebhookHeaders(cfg *config.Config, req *http.Request, headers []models.WebhookHeader) error {
+	if len(headers) == 0 {
+		return nil
+	}
+	aead, err := webhookHeadersCipher(cfg)
+	if err != nil {
+		return err
+	}
+	for _, header := range headers {
+		value, err := openWebhookHeaderValue(aead, header.Value)
+		if err != nil {
+			return fmt.Errorf("custom header %s: %w", header.Name, err)
+		}
+		req.Header.Set(header.Name, value)
+	}
+	return nil
+}
+
+// webhookHeaderNames lists the configured header names for API responses; values are never returned
+func webhookHeaderNames(headers []models.WebhookHeader) []string {
+	names := make([]string, 0, len(headers))
+	for _, header := range headers {
+		names = append(names, header.Name)
+	}
+	return names
+}
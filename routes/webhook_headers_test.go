@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"saas-chatbot-platform/internal/config"
+)
+
+func TestValidateWebhookHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr bool
+	}{
+		{"authorization", map[string]string{"Authorization": "Bearer abc"}, false},
+		{"api key", map[string]string{"x-api-key": "k1", "X-Tenant": "acme"}, false},
+		{"signature override", map[string]string{"X-Chatbot-Signature": "sha256=00"}, true},
+		{"signature override lowercase", map[string]string{"x-chatbot-signature": "sha256=00"}, true},
+		{"content type", map[string]string{"Content-Type": "text/plain"}, true},
+		{"invalid name", map[string]string{"Bad Header": "x"}, true},
+		{"header injection", map[string]string{"X-Api-Key": "k\r\nX-Chatbot-Signature: forged"}, true},
+		{"empty value", map[string]string{"X-Api-Key": " "}, true},
+		{"duplicate", map[string]string{"x-api-key": "a", "X-Api-Key": "b"}, true},
+	}
+	for _, tt := range tests {
+		if err := validateWebhookHeaders(tt.headers); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateWebhookHeaders error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWebhookHeadersRoundTrip(t *testing.T) {
+	cfg := &config.Config{WebhookHeadersKey: "test-key"}
+	sealed, err := sealWebhookHeaders(cfg, map[string]string{"authorization": "Bearer secret-token", "X-Api-Key": "k1"})
+	if err != nil {
+		t.Fatalf("sealWebhookHeaders: %v", err)
+	}
+	if len(sealed) != 2 || sealed[0].Name != "Authorization" || strings.Contains(sealed[0].Value, "secret-token") {
+		t.Fatalf("unexpected sealed headers: %+v", sealed)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://crm.example.com/hook", nil)
+	if err := applyWebhookHeaders(cfg, req, sealed); err != nil {
+		t.Fatalf("applyWebhookHeaders: %v", err)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret-token" || req.Header.Get("X-Api-Key") != "k1" {
+		t.Errorf("headers not applied: %v", req.Header)
+	}
+
+	if err := applyWebhookHeaders(&config.Config{WebhookHeadersKey: "other-key"}, req, sealed); err == nil {
+		t.Error("decrypting with a different key succeeded")
+	}
+}
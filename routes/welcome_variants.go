@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/middleware"
+	"saas-chatbot-platform/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===================
+// WELCOME MESSAGE A/B TESTING
+// ===================
+
+const (
+	maxWelcomeVariants      = 5
+	maxWelcomeVariantWeight = 100
+)
+
+// validateWelcomeVariants checks variant ids are unique and every variant has text and a positive weight
+func validateWelcomeVariants(variants []models.WelcomeMessageVariant) error {
+	if len(variants) > maxWelcomeVariants {
+		return fmt.Errorf("maximum %d welcome message variants allowed", maxWelcomeVariants)
+	}
+	seen := make(map[string]bool)
+	for i, v := range variants {
+		id := strings.TrimSpace(v.ID)
+		if id == "" {
+			return fmt.Errorf("variant %d: id is required", i+1)
+		}
+		if seen[id] {
+			return fmt.Errorf("duplicate variant id %q", id)
+		}
+		seen[id] = true
+		if strings.TrimSpace(v.Text) == "" {
+			return fmt.Errorf("variant %q: text is required", id)
+		}
+		if v.Weight <= 0 || v.Weight > maxWelcomeVariantWeight {
+			return fmt.Errorf("variant %q: weight must be between 1 and %d", id, maxWelcomeVariantWeight)
+		}
+	}
+	return nil
+}
+
+// selectWelcomeVariant picks a weighted variant. The choice is derived from the session id so that a
+// session always sees the same variant without storing assignments; without a session id it is random.
+func selectWelcomeVariant(branding models.Branding, clientID, sessionID string) *models.WelcomeMessageVariant {
+	totalWeight := 0
+	for _, v := range branding.WelcomeVariants {
+		if v.Weight > 0 {
+			totalWeight += v.Weight
+		}
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+
+	var bucket int
+	if sessionID == "" {
+		bucket = rand.Intn(totalWeight)
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(clientID + "|" + sessionID))
+		bucket = int(h.Sum32() % uint32(totalWeight))
+	}
+
+	for i := range branding.WelcomeVariants {
+		v := &branding.WelcomeVariants[i]
+		if v.Weight <= 0 {
+			continue
+		}
+		if bucket < v.Weight {
+			return v
+		}
+		bucket -= v.Weight
+	}
+	return nil
+}
+
+// sessionWelcomeVariant returns the variant a session started with. The first message of a session
+// stores its variant, so later messages reuse it even if the client edits or reweights the variants
+// mid-session; a new session gets a fresh selectWelcomeVariant pick.
+func sessionWelcomeVariant(ctx context.Context, messagesCollection *mongo.Collection, branding models.Branding, clientID primitive.ObjectID, sessionID string) *models.WelcomeMessageVariant {
+	if sessionID != "" {
+		var first struct {
+			WelcomeVariant string `bson:"welcome_variant"`
+		}
+		err := messagesCollection.FindOne(ctx, bson.M{
+			"client_id":       clientID,
+			"session_id":      sessionID,
+			"welcome_variant": bson.M{"$nin": bson.A{nil, ""}},
+		}, options.FindOne().SetSort(bson.M{"timestamp": 1}).SetProjection(bson.M{"welcome_variant": 1})).Decode(&first)
+		if err == nil {
+			if variant := findWelcomeVariant(branding, first.WelcomeVariant); variant != nil {
+				return variant
+			}
+			// The variant was removed since; keep attributing the session to it
+			return &models.WelcomeMessageVariant{ID: first.WelcomeVariant}
+		}
+	}
+	return selectWelcomeVariant(branding, clientID.Hex(), sessionID)
+}
+
+// findWelcomeVariant returns the configured variant with id, or nil
+func findWelcomeVariant(branding models.Branding, id string) *models.WelcomeMessageVariant {
+	for i := range branding.WelcomeVariants {
+		if branding.WelcomeVariants[i].ID == id {
+			return &branding.WelcomeVariants[i]
+		}
+	}
+	return nil
+}
+
+// welcomeVariantStats compares engagement and conversion for one variant
+type welcomeVariantStats struct {
+	VariantID                  string  `json:"variant_id" bson:"_id"`
+	Text                       string  `json:"text,omitempty" bson:"-"`
+	Weight                     int     `json:"weight,omitempty" bson:"-"`
+	Conversations              int     `json:"conversations" bson:"conversations"`
+	Messages                   int     `json:"messages" bson:"messages"`
+	AvgMessagesPerConversation float64 `json:"avg_messages_per_conversation" bson:"-"`
+	Conversions                int     `json:"conversions" bson:"conversions"`
+	ConversionRate             float64 `json:"conversion_rate" bson:"-"`
+}
+
+// handleWelcomeVariantAnalytics compares conversations started with each welcome message variant.
+// A conversation converts when it captured a lead or booked a demo.
+func handleWelcomeVariantAnalytics(db *mongo.Database, clientsCollection *mongo.Collection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClientID := middleware.GetClientID(c)
+		if userClientID == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error_code": "forbidden",
+				"message":    "Client ID required",
+			})
+			return
+		}
+
+		clientObjID, err := primitive.ObjectIDFromHex(userClientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error_code": "invalid_client_id",
+				"message":    "Invalid client ID format",
+			})
+			return
+		}
+
+		days := 30
+		if v := c.Query("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 365 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error_code": "invalid_days",
+					"message":    "days must be between 1 and 365",
+				})
+				return
+			}
+			days = n
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+		defer cancel()
+
+		clientDoc, err := getClientConfig(ctx, clientsCollection, clientObjID)
+		if err != nil {
+			handleClientError(c, err)
+			return
+		}
+
+		leadIDs, err := leadConversationIDs(ctx, db, clientObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to load conversions",
+			})
+			return
+		}
+
+		cursor, err := db.Collection("messages").Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{
+				"client_id":       clientObjID,
+				"welcome_variant": bson.M{"$nin": bson.A{nil, ""}},
+				"timestamp":       bson.M{"$gte": time.Now().AddDate(0, 0, -days)},
+			}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":      bson.M{"variant": "$welcome_variant", "conversation": "$conversation_id"},
+				"messages": bson.M{"$sum": 1},
+			}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":           "$_id.variant",
+				"conversations": bson.M{"$sum": 1},
+				"messages":      bson.M{"$sum": "$messages"},
+				"conversions": bson.M{"$sum": bson.M{
+					"$cond": bson.A{bson.M{"$in": bson.A{"$_id.conversation", leadIDs}}, 1, 0},
+				}},
+			}}},
+			{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to aggregate welcome variant analytics",
+			})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var stats []welcomeVariantStats
+		if err := cursor.All(ctx, &stats); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error_code": "database_error",
+				"message":    "Failed to decode welcome variant analytics",
+			})
+			return
+		}
+
+		variants := make(map[string]models.WelcomeMessageVariant)
+		for _, v := range clientDoc.Branding.WelcomeVariants {
+			variants[v.ID] = v
+		}
+		for i := range stats {
+			s := &stats[i]
+			if v, ok := variants[s.VariantID]; ok {
+				s.Text = v.Text
+				s.Weight = v.Weight
+				delete(variants, s.VariantID)
+			}
+			if s.Conversations > 0 {
+				s.AvgMessagesPerConversation = float64(s.Messages) / float64(s.Conversations)
+				s.ConversionRate = float64(s.Conversions) / float64(s.Conversations)
+			}
+		}
+		// Configured variants without conversations yet
+		for _, v := range clientDoc.Branding.WelcomeVariants {
+			if _, ok := variants[v.ID]; ok {
+				stats = append(stats, welcomeVariantStats{VariantID: v.ID, Text: v.Text, Weight: v.Weight})
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"days":     days,
+			"variants": stats,
+		})
+	}
+}
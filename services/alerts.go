@@ -44,14 +44,8 @@ func (a *AlertEvaluator) EvaluateAndNotify(ctx context.Context, clientID primiti
     percentUsed := float64(client.TokenUsed) / float64(client.TokenLimit) * 100
     
     // Determine alert level needed
-    var alertLevel string
-    if percentUsed >= float64(a.config.TokenExhaustedPercent) {
-        alertLevel = "exhausted"
-    } else if percentUsed >= float64(a.config.TokenCriticalPercent) {
-        alertLevel = "critical"
-    } else if percentUsed >= float64(a.config.TokenWarnPercent) {
-        alertLevel = "warn"
-    } else {
+    alertLevel := TokenAlertLevel(a.config, client.TokenUsed, client.TokenLimit)
+    if alertLevel == "none" {
         return nil // No alert needed
     }
     
@@ -81,6 +75,23 @@ func (a *AlertEvaluator) EvaluateAndNotify(ctx context.Context, clientID primiti
     return a.updateAlertStatus(ctx, clientID, alertLevel)
 }
 
+// TokenAlertLevel returns the alert level ("none", "warn", "critical" or "exhausted") for a token balance
+func TokenAlertLevel(cfg config.Config, used, limit int) string {
+    if limit <= 0 {
+        return "none"
+    }
+    percentUsed := float64(used) / float64(limit) * 100
+    switch {
+    case percentUsed >= float64(cfg.TokenExhaustedPercent):
+        return "exhausted"
+    case percentUsed >= float64(cfg.TokenCriticalPercent):
+        return "critical"
+    case percentUsed >= float64(cfg.TokenWarnPercent):
+        return "warn"
+    }
+    return "none"
+}
+
 func (a *AlertEvaluator) shouldSkipAlert(client models.Client, alertLevel string) bool {
     // If no alert has been sent yet, don't skip
     if client.AlertLevelSent == "" || client.AlertLevelSent == "none" {
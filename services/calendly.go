@@ -0,0 +1,119 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const calendlyAPIBaseURL = "https://api.calendly.com"
+
+// CalendlyClient talks to the Calendly v2 API using a client's personal access token
+type CalendlyClient struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// CalendlySchedulingLink is a single-use booking link created through the API
+type CalendlySchedulingLink struct {
+	BookingURL string `json:"booking_url"`
+	Owner      string `json:"owner"`
+	OwnerType  string `json:"owner_type"`
+}
+
+// NewCalendlyClient creates a new Calendly API client
+func NewCalendlyClient(token string) *CalendlyClient {
+	return &CalendlyClient{
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		baseURL: calendlyAPIBaseURL,
+		token:   token,
+	}
+}
+
+// CreateSchedulingLink creates a single-use scheduling link for the given event type URI
+func (c *CalendlyClient) CreateSchedulingLink(ctx context.Context, eventTypeURI string) (*CalendlySchedulingLink, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"max_event_count": 1,
+		"owner":           eventTypeURI,
+		"owner_type":      "EventType",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal scheduling link request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/scheduling_links", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheduling link request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calendly request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calendly response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendly returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Resource CalendlySchedulingLink `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode calendly response: %w", err)
+	}
+	if result.Resource.BookingURL == "" {
+		return nil, fmt.Errorf("calendly response missing booking_url")
+	}
+
+	return &result.Resource, nil
+}
+
+// PrefillCalendlyURL adds Calendly's name/email prefill parameters to a booking URL.
+// The original URL is returned unchanged if it cannot be parsed.
+func PrefillCalendlyURL(bookingURL, name, email string) string {
+	parsed, err := url.Parse(bookingURL)
+	if err != nil {
+		return bookingURL
+	}
+
+	query := parsed.Query()
+	if name != "" {
+		query.Set("name", name)
+	}
+	if email != "" {
+		query.Set("email", email)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// PrefillCalendlyDate opens a booking URL on the given day using Calendly's month/date parameters.
+// The original URL is returned unchanged if it cannot be parsed.
+func PrefillCalendlyDate(bookingURL string, day time.Time) string {
+	parsed, err := url.Parse(bookingURL)
+	if err != nil {
+		return bookingURL
+	}
+
+	query := parsed.Query()
+	query.Set("month", day.Format("2006-01"))
+	query.Set("date", day.Format("2006-01-02"))
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
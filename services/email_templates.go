@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// placeholderPattern matches {{name}} placeholders, allowing surrounding spaces
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z][a-zA-Z0-9_]*)\s*\}\}`)
+
+// RenderedEmail is an email template with all placeholders substituted
+type RenderedEmail struct {
+	Subject  string `json:"subject"`
+	HTMLBody string `json:"html_body"`
+	TextBody string `json:"text_body"`
+}
+
+// MissingVariablesError is returned when a template uses placeholders that were not supplied
+type MissingVariablesError struct {
+	TemplateType string
+	Missing      []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return fmt.Sprintf("email template %q is missing variables: %s", e.TemplateType, strings.Join(e.Missing, ", "))
+}
+
+// ErrEmailTemplateNotFound is returned when the client has no active template of the requested type
+var ErrEmailTemplateNotFound = fmt.Errorf("email template not found")
+
+// EmailTemplateRenderer loads a client's templates from the email_templates collection and renders them
+type EmailTemplateRenderer struct {
+	collection *mongo.Collection
+	clientID   primitive.ObjectID
+}
+
+// NewEmailTemplateRenderer creates a renderer for one client's templates
+func NewEmailTemplateRenderer(collection *mongo.Collection, clientID primitive.ObjectID) *EmailTemplateRenderer {
+	return &EmailTemplateRenderer{
+		collection: collection,
+		clientID:   clientID,
+	}
+}
+
+// RenderEmailTemplate loads the client's active template of templateType and substitutes vars.
+// It returns ErrEmailTemplateNotFound if no active template exists and a *MissingVariablesError
+// listing every placeholder without a value.
+func (r *EmailTemplateRenderer) RenderEmailTemplate(ctx context.Context, templateType string, vars map[string]string) (*RenderedEmail, error) {
+	var tmpl models.EmailTemplate
+	err := r.collection.FindOne(ctx, bson.M{
+		"client_id": r.clientID,
+		"type":      templateType,
+		"is_active": true,
+	}).Decode(&tmpl)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrEmailTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load email template: %w", err)
+	}
+
+	return RenderEmailTemplateContent(tmpl, vars)
+}
+
+// RenderEmailTemplateContent substitutes vars into an already loaded template.
+// Values are HTML-escaped in the HTML body only.
+func RenderEmailTemplateContent(tmpl models.EmailTemplate, vars map[string]string) (*RenderedEmail, error) {
+	if missing := MissingTemplateVariables(tmpl, vars); len(missing) > 0 {
+		return nil, &MissingVariablesError{TemplateType: tmpl.Type, Missing: missing}
+	}
+
+	return &RenderedEmail{
+		Subject:  substitutePlaceholders(tmpl.Subject, vars, false),
+		HTMLBody: substitutePlaceholders(tmpl.HTMLBody, vars, true),
+		TextBody: substitutePlaceholders(tmpl.TextBody, vars, false),
+	}, nil
+}
+
+// TemplateVariables returns the sorted, de-duplicated placeholder names used by a template
+func TemplateVariables(tmpl models.EmailTemplate) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, text := range []string{tmpl.Subject, tmpl.HTMLBody, tmpl.TextBody} {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				names = append(names, match[1])
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MissingTemplateVariables returns the placeholders used by a template that have no value in vars
+func MissingTemplateVariables(tmpl models.EmailTemplate, vars map[string]string) []string {
+	var missing []string
+	for _, name := range TemplateVariables(tmpl) {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// PlaceholderNames returns the sorted, de-duplicated placeholder names used in text
+func PlaceholderNames(text string) []string {
+	return TemplateVariables(models.EmailTemplate{Subject: text})
+}
+
+// RenderPlaceholders replaces every {{name}} in text with its value, leaving unknown placeholders
+// untouched. Values are inserted verbatim and never re-scanned for placeholders.
+func RenderPlaceholders(text string, vars map[string]string) string {
+	return substitutePlaceholders(text, vars, false)
+}
+
+// substitutePlaceholders replaces every {{name}} with its value
+func substitutePlaceholders(text string, vars map[string]string, escapeHTML bool) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		value, ok := vars[name]
+		if !ok {
+			return placeholder
+		}
+		if escapeHTML {
+			return html.EscapeString(value)
+		}
+		return value
+	})
+}
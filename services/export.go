@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"saas-chatbot-platform/internal/auth"
@@ -23,7 +24,7 @@ import (
 
 // ExportRequest represents the request parameters for chat export
 type ExportRequest struct {
-	Format         string    `json:"format" binding:"required,oneof=json excel both"` // json, excel, both
+	Format         string    `json:"format" binding:"omitempty,oneof=json excel both csv txt"` // json, excel, both, csv, txt
 	DateFrom       time.Time `json:"date_from,omitempty"`
 	DateTo         time.Time `json:"date_to,omitempty"`
 	ClientID       string    `json:"client_id,omitempty"`
@@ -54,6 +55,7 @@ type ExportInfo struct {
 	TotalRecords   int       `json:"total_records"`
 	DateRange      string    `json:"date_range,omitempty"`
 	ClientID       string    `json:"client_id,omitempty"`
+	ClientName     string    `json:"client_name,omitempty"`
 	ConversationID string    `json:"conversation_id,omitempty"`
 	Format         string    `json:"format"`
 	IncludeGeo     bool      `json:"include_geo"`
@@ -150,6 +152,30 @@ func (es *ExportService) ExportChats(ctx context.Context, req *ExportRequest, us
 	// Build query filter
 	filter := es.BuildQueryFilter(req, userClaims)
 
+	// csv and txt are only produced by StreamMessages; report how many records the download will contain
+	if req.Format == "csv" || req.Format == "txt" {
+		countOpts := options.Count()
+		if req.Limit > 0 {
+			countOpts.SetLimit(int64(req.Limit))
+		}
+		total, err := es.messagesCollection.CountDocuments(ctx, filter, countOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count messages: %w", err)
+		}
+		if total == 0 {
+			return &ExportResponse{
+				Success:     true,
+				Message:     "No messages found for the specified criteria",
+				RecordCount: 0,
+			}, nil
+		}
+		return &ExportResponse{
+			Success:     true,
+			Message:     fmt.Sprintf("%s export ready for download", strings.ToUpper(req.Format)),
+			RecordCount: int(total),
+		}, nil
+	}
+
 	// Set up pagination
 	opts := options.Find()
 	if req.Limit > 0 {
@@ -216,6 +242,9 @@ func (es *ExportService) BuildQueryFilter(req *ExportRequest, userClaims *auth.C
 		}
 	}
 
+	// ✅ NEW: Keep messages filtered as spam out of exports
+	filter["spam"] = bson.M{"$exists": false}
+
 	// Add conversation ID filter
 	if req.ConversationID != "" {
 		filter["conversation_id"] = req.ConversationID
@@ -239,73 +268,15 @@ func (es *ExportService) BuildQueryFilter(req *ExportRequest, userClaims *auth.C
 // ConvertToExportFormat converts MongoDB messages to export format
 func (es *ExportService) ConvertToExportFormat(messages []models.Message, req *ExportRequest, summary *ExportSummary) *ChatExportData {
 	exportMessages := make([]MessageExport, len(messages))
-
 	for i, msg := range messages {
-		exportMsg := MessageExport{
-			ID:             msg.ID.Hex(),
-			FromName:       msg.FromName,
-			Message:        msg.Message,
-			Reply:          msg.Reply,
-			Timestamp:      msg.Timestamp,
-			ConversationID: msg.ConversationID,
-			TokenCost:      msg.TokenCost,
-			UserIP:         msg.UserIP,
-			UserAgent:      msg.UserAgent,
-			Referrer:       msg.Referrer,
-			SessionID:      msg.SessionID,
-			IsEmbedUser:    msg.IsEmbedUser,
-		}
-
-		// Add geolocation data if requested
-		if req.IncludeGeo {
-			exportMsg.GeoData = &GeoDataExport{
-				Country:      msg.Country,
-				CountryCode:  msg.CountryCode,
-				Region:       msg.Region,
-				RegionName:   msg.RegionName,
-				City:         msg.City,
-				Latitude:     msg.Latitude,
-				Longitude:    msg.Longitude,
-				Timezone:     msg.Timezone,
-				ISP:          msg.ISP,
-				Organization: msg.Organization,
-				IPType:       msg.IPType,
-			}
-		}
-
-		// Add metadata if requested
-		if req.IncludeMeta {
-			exportMsg.MetaData = &MetaDataExport{
-				CreatedAt:  msg.Timestamp, // Using timestamp as created_at
-				ClientID:   msg.ClientID.Hex(),
-				FromUserID: msg.FromUserID.Hex(),
-				UserName:   msg.UserName,
-				UserEmail:  msg.UserEmail,
-			}
-		}
-
-		exportMessages[i] = exportMsg
-	}
-
-	// Build date range string
-	var dateRange string
-	if !req.DateFrom.IsZero() || !req.DateTo.IsZero() {
-		if !req.DateFrom.IsZero() && !req.DateTo.IsZero() {
-			dateRange = fmt.Sprintf("%s to %s",
-				req.DateFrom.Format("2006-01-02"),
-				req.DateTo.Format("2006-01-02"))
-		} else if !req.DateFrom.IsZero() {
-			dateRange = fmt.Sprintf("From %s", req.DateFrom.Format("2006-01-02"))
-		} else {
-			dateRange = fmt.Sprintf("Until %s", req.DateTo.Format("2006-01-02"))
-		}
+		exportMessages[i] = es.toMessageExport(msg, req)
 	}
 
 	return &ChatExportData{
 		ExportInfo: ExportInfo{
 			ExportDate:     time.Now(),
 			TotalRecords:   len(messages),
-			DateRange:      dateRange,
+			DateRange:      exportDateRange(req),
 			ClientID:       req.ClientID,
 			ConversationID: req.ConversationID,
 			Format:         req.Format,
@@ -317,80 +288,139 @@ func (es *ExportService) ConvertToExportFormat(messages []models.Message, req *E
 	}
 }
 
+// toMessageExport converts a single message, adding geo and metadata when requested
+func (es *ExportService) toMessageExport(msg models.Message, req *ExportRequest) MessageExport {
+	exportMsg := MessageExport{
+		ID:             msg.ID.Hex(),
+		FromName:       msg.FromName,
+		Message:        msg.Message,
+		Reply:          msg.Reply,
+		Timestamp:      msg.Timestamp,
+		ConversationID: msg.ConversationID,
+		TokenCost:      msg.TokenCost,
+		UserIP:         msg.UserIP,
+		UserAgent:      msg.UserAgent,
+		Referrer:       msg.Referrer,
+		SessionID:      msg.SessionID,
+		IsEmbedUser:    msg.IsEmbedUser,
+	}
+
+	// Add geolocation data if requested
+	if req.IncludeGeo {
+		exportMsg.GeoData = &GeoDataExport{
+			Country:      msg.Country,
+			CountryCode:  msg.CountryCode,
+			Region:       msg.Region,
+			RegionName:   msg.RegionName,
+			City:         msg.City,
+			Latitude:     msg.Latitude,
+			Longitude:    msg.Longitude,
+			Timezone:     msg.Timezone,
+			ISP:          msg.ISP,
+			Organization: msg.Organization,
+			IPType:       msg.IPType,
+		}
+	}
+
+	// Add metadata if requested
+	if req.IncludeMeta {
+		exportMsg.MetaData = &MetaDataExport{
+			CreatedAt:  msg.Timestamp, // Using timestamp as created_at
+			ClientID:   msg.ClientID.Hex(),
+			FromUserID: msg.FromUserID.Hex(),
+			UserName:   msg.UserName,
+			UserEmail:  msg.UserEmail,
+		}
+	}
+
+	return exportMsg
+}
+
 // GenerateSummary generates summary statistics for the export
 func (es *ExportService) GenerateSummary(ctx context.Context, messages []models.Message, req *ExportRequest) (*ExportSummary, error) {
-	summary := &ExportSummary{
-		TotalMessages:   len(messages),
-		IPTypeBreakdown: make(map[string]int),
+	builder := newSummaryBuilder()
+	for i := range messages {
+		builder.add(&messages[i])
 	}
+	return es.finishSummary(builder, req), nil
+}
 
-	// Calculate total tokens
-	totalTokens := 0
-	uniqueUsers := make(map[string]bool)
-	conversationCounts := make(map[string]int)
-	countryCounts := make(map[string]int)
-	ispCounts := make(map[string]int)
+// summaryBuilder accumulates export summary counts one message at a time,
+// so streamed exports can report the same summary as in-memory ones
+type summaryBuilder struct {
+	totalMessages      int
+	totalTokens        int
+	uniqueUsers        map[string]bool
+	conversationCounts map[string]int
+	countryCounts      map[string]int
+	ispCounts          map[string]int
+	ipTypeBreakdown    map[string]int
+}
 
-	for _, msg := range messages {
-		totalTokens += msg.TokenCost
-		uniqueUsers[msg.SessionID] = true
-		conversationCounts[msg.ConversationID]++
+func newSummaryBuilder() *summaryBuilder {
+	return &summaryBuilder{
+		uniqueUsers:        make(map[string]bool),
+		conversationCounts: make(map[string]int),
+		countryCounts:      make(map[string]int),
+		ispCounts:          make(map[string]int),
+		ipTypeBreakdown:    make(map[string]int),
+	}
+}
 
-		// Count countries
-		if msg.Country != "" {
-			countryCounts[msg.Country]++
-		}
+// add counts a single message
+func (b *summaryBuilder) add(msg *models.Message) {
+	b.totalMessages++
+	b.totalTokens += msg.TokenCost
+	b.uniqueUsers[msg.SessionID] = true
+	b.conversationCounts[msg.ConversationID]++
 
-		// Count ISPs
-		if msg.ISP != "" {
-			ispCounts[msg.ISP]++
-		}
+	// Count countries
+	if msg.Country != "" {
+		b.countryCounts[msg.Country]++
+	}
 
-		// Count IP types
-		if msg.IPType != "" {
-			summary.IPTypeBreakdown[msg.IPType]++
-		}
+	// Count ISPs
+	if msg.ISP != "" {
+		b.ispCounts[msg.ISP]++
 	}
 
-	summary.TotalTokens = totalTokens
-	summary.UniqueUsers = len(uniqueUsers)
+	// Count IP types
+	if msg.IPType != "" {
+		b.ipTypeBreakdown[msg.IPType]++
+	}
+}
+
+// finishSummary turns the accumulated counts into an ExportSummary
+func (es *ExportService) finishSummary(b *summaryBuilder, req *ExportRequest) *ExportSummary {
+	summary := &ExportSummary{
+		TotalMessages:   b.totalMessages,
+		TotalTokens:     b.totalTokens,
+		UniqueUsers:     len(b.uniqueUsers),
+		DateRange:       exportDateRange(req),
+		IPTypeBreakdown: b.ipTypeBreakdown,
+	}
 
 	// Calculate conversation stats
-	conversationCount := len(conversationCounts)
+	conversationCount := len(b.conversationCounts)
 	summary.ConversationStats.TotalConversations = conversationCount
 	if conversationCount > 0 {
-		summary.ConversationStats.AvgMessagesPerConv = float64(len(messages)) / float64(conversationCount)
+		summary.ConversationStats.AvgMessagesPerConv = float64(b.totalMessages) / float64(conversationCount)
 	}
 
 	// Find longest conversation
-	longestConv := 0
-	for _, count := range conversationCounts {
-		if count > longestConv {
-			longestConv = count
-		}
-	}
-	summary.ConversationStats.LongestConversation = longestConv
-
-	// Build date range string
-	if !req.DateFrom.IsZero() || !req.DateTo.IsZero() {
-		if !req.DateFrom.IsZero() && !req.DateTo.IsZero() {
-			summary.DateRange = fmt.Sprintf("%s to %s",
-				req.DateFrom.Format("2006-01-02"),
-				req.DateTo.Format("2006-01-02"))
-		} else if !req.DateFrom.IsZero() {
-			summary.DateRange = fmt.Sprintf("From %s", req.DateFrom.Format("2006-01-02"))
-		} else {
-			summary.DateRange = fmt.Sprintf("Until %s", req.DateTo.Format("2006-01-02"))
+	for _, count := range b.conversationCounts {
+		if count > summary.ConversationStats.LongestConversation {
+			summary.ConversationStats.LongestConversation = count
 		}
 	}
 
 	// Get top countries (limit to 10)
-	summary.TopCountries = es.getTopItems(countryCounts, 10)
+	summary.TopCountries = es.getTopItems(b.countryCounts, 10)
 
 	// Get top ISPs (limit to 10)
-	summary.TopISPs = es.getTopISPs(ispCounts, 10)
+	summary.TopISPs = es.getTopISPs(b.ispCounts, 10)
 
-	return summary, nil
+	return summary
 }
 
 // getTopItems returns top items by count
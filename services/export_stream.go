@@ -0,0 +1,333 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/auth"
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// streamFlushEvery controls how often streamed exports are flushed to the client
+const streamFlushEvery = 500
+
+// IsStreamingFormat reports whether format is written row by row from the database cursor
+func IsStreamingFormat(format string) bool {
+	switch format {
+	case "json", "csv", "txt":
+		return true
+	}
+	return false
+}
+
+// StreamContentType returns the Content-Type and file extension for a streaming format
+func StreamContentType(format string) (contentType, extension string) {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8", "csv"
+	case "txt":
+		return "text/plain; charset=utf-8", "txt"
+	default:
+		return "application/json", "json"
+	}
+}
+
+// StreamMessages writes the export for req to w without loading all messages into memory.
+// json and csv are ordered newest first; txt is grouped into one transcript per conversation.
+// It returns the number of messages written.
+func (es *ExportService) StreamMessages(ctx context.Context, w io.Writer, req *ExportRequest, userClaims *auth.Claims) (int, error) {
+	filter := es.BuildQueryFilter(req, userClaims)
+
+	info, err := es.buildExportInfo(ctx, filter, req)
+	if err != nil {
+		return 0, err
+	}
+
+	opts := options.Find().SetAllowDiskUse(true)
+	if req.Limit > 0 {
+		opts.SetLimit(int64(req.Limit))
+	}
+	if req.Format == "txt" {
+		opts.SetSort(bson.D{{Key: "conversation_id", Value: 1}, {Key: "timestamp", Value: 1}})
+	} else {
+		opts.SetSort(bson.D{{Key: "timestamp", Value: -1}})
+	}
+
+	cursor, err := es.messagesCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	bw := bufio.NewWriter(w)
+	flush := func() {
+		bw.Flush()
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	var count int
+	switch req.Format {
+	case "json":
+		count, err = es.streamJSON(ctx, bw, cursor, info, req, flush)
+	case "csv":
+		count, err = es.streamCSV(ctx, bw, cursor, req, flush)
+	case "txt":
+		count, err = es.streamTXT(ctx, bw, cursor, info, flush)
+	default:
+		return 0, fmt.Errorf("unsupported streaming format: %s", req.Format)
+	}
+	if err != nil {
+		return count, err
+	}
+
+	if err := cursor.Err(); err != nil {
+		return count, fmt.Errorf("cursor error: %w", err)
+	}
+	flush()
+	return count, nil
+}
+
+// CountMessages returns how many messages an export for req will contain
+func (es *ExportService) CountMessages(ctx context.Context, req *ExportRequest, userClaims *auth.Claims) (int64, error) {
+	countOpts := options.Count()
+	if req.Limit > 0 {
+		countOpts.SetLimit(int64(req.Limit))
+	}
+	total, err := es.messagesCollection.CountDocuments(ctx, es.BuildQueryFilter(req, userClaims), countOpts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+	return total, nil
+}
+
+// buildExportInfo collects the header metadata (client name, period, record count)
+func (es *ExportService) buildExportInfo(ctx context.Context, filter bson.M, req *ExportRequest) (*ExportInfo, error) {
+	countOpts := options.Count()
+	if req.Limit > 0 {
+		countOpts.SetLimit(int64(req.Limit))
+	}
+	total, err := es.messagesCollection.CountDocuments(ctx, filter, countOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	info := &ExportInfo{
+		ExportDate:     time.Now(),
+		TotalRecords:   int(total),
+		DateRange:      exportDateRange(req),
+		ClientID:       req.ClientID,
+		ConversationID: req.ConversationID,
+		Format:         req.Format,
+		IncludeGeo:     req.IncludeGeo,
+		IncludeMeta:    req.IncludeMeta,
+	}
+
+	if clientID, ok := filter["client_id"].(primitive.ObjectID); ok {
+		info.ClientID = clientID.Hex()
+		var client models.Client
+		if err := es.clientsCollection.FindOne(ctx, bson.M{"_id": clientID}).Decode(&client); err == nil {
+			info.ClientName = client.Name
+		}
+	}
+	if info.DateRange == "" {
+		info.DateRange = "All time"
+	}
+
+	return info, nil
+}
+
+// streamJSON writes the ChatExportData schema ({"export_info", "messages", "summary"}) one message
+// at a time; the summary is accumulated while streaming and written after the messages
+func (es *ExportService) streamJSON(ctx context.Context, w *bufio.Writer, cursor cursorIterator, info *ExportInfo, req *ExportRequest, flush func()) (int, error) {
+	header, err := json.Marshal(info)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal export info: %w", err)
+	}
+	fmt.Fprintf(w, "{\"export_info\":%s,\"messages\":[", header)
+
+	summary := newSummaryBuilder()
+	count := 0
+	for cursor.Next(ctx) {
+		var msg models.Message
+		if err := cursor.Decode(&msg); err != nil {
+			continue
+		}
+		summary.add(&msg)
+		row, err := json.Marshal(es.toMessageExport(msg, req))
+		if err != nil {
+			continue
+		}
+		if count > 0 {
+			w.WriteString(",")
+		}
+		w.Write(row)
+		count++
+		if count%streamFlushEvery == 0 {
+			flush()
+		}
+	}
+
+	footer, err := json.Marshal(es.finishSummary(summary, req))
+	if err != nil {
+		return count, fmt.Errorf("failed to marshal export summary: %w", err)
+	}
+	fmt.Fprintf(w, "],\"summary\":%s}", footer)
+	return count, nil
+}
+
+// streamCSV writes a header row and one row per message. Metadata stays in the JSON
+// and txt formats so spreadsheet importers see a plain table.
+func (es *ExportService) streamCSV(ctx context.Context, w *bufio.Writer, cursor cursorIterator, req *ExportRequest, flush func()) (int, error) {
+	cw := csv.NewWriter(w)
+	headers := []string{
+		"id", "timestamp", "conversation_id", "from_name", "message", "reply", "token_cost", "channel",
+	}
+	if req.IncludeGeo {
+		headers = append(headers, "user_ip", "country", "region_name", "city", "isp", "ip_type")
+	}
+	if req.IncludeMeta {
+		headers = append(headers, "client_id", "user_name", "user_email", "user_agent", "referrer")
+	}
+	if err := cw.Write(headers); err != nil {
+		return 0, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	count := 0
+	for cursor.Next(ctx) {
+		var msg models.Message
+		if err := cursor.Decode(&msg); err != nil {
+			continue
+		}
+		channel := msg.Channel
+		if channel == "" {
+			channel = "embed"
+		}
+		row := []string{
+			msg.ID.Hex(),
+			msg.Timestamp.Format(time.RFC3339),
+			msg.ConversationID,
+			msg.FromName,
+			msg.Message,
+			msg.Reply,
+			strconv.Itoa(msg.TokenCost),
+			channel,
+		}
+		if req.IncludeGeo {
+			row = append(row, msg.UserIP, msg.Country, msg.RegionName, msg.City, msg.ISP, msg.IPType)
+		}
+		if req.IncludeMeta {
+			row = append(row, msg.ClientID.Hex(), msg.UserName, msg.UserEmail, msg.UserAgent, msg.Referrer)
+		}
+		for i := range row {
+			row[i] = csvSafeCell(row[i])
+		}
+		if err := cw.Write(row); err != nil {
+			return count, fmt.Errorf("failed to write CSV row: %w", err)
+		}
+		count++
+		if count%streamFlushEvery == 0 {
+			cw.Flush()
+			flush()
+		}
+	}
+
+	cw.Flush()
+	return count, cw.Error()
+}
+
+// streamTXT writes a readable "User: ... / Bot: ..." transcript per conversation.
+// The cursor must be sorted by conversation_id, then timestamp.
+func (es *ExportService) streamTXT(ctx context.Context, w *bufio.Writer, cursor cursorIterator, info *ExportInfo, flush func()) (int, error) {
+	clientName := info.ClientName
+	if clientName == "" {
+		clientName = "All clients"
+	}
+	fmt.Fprintf(w, "Chat transcript export - %s\n", clientName)
+	fmt.Fprintf(w, "Period: %s\n", info.DateRange)
+	fmt.Fprintf(w, "Exported: %s\n", info.ExportDate.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(w, "Messages: %d\n", info.TotalRecords)
+
+	count := 0
+	currentConversation := ""
+	for cursor.Next(ctx) {
+		var msg models.Message
+		if err := cursor.Decode(&msg); err != nil {
+			continue
+		}
+
+		if count == 0 || msg.ConversationID != currentConversation {
+			currentConversation = msg.ConversationID
+			w.WriteString("\n" + strings.Repeat("=", 60) + "\n")
+			fmt.Fprintf(w, "Conversation: %s\n", msg.ConversationID)
+			if msg.UserName != "" {
+				fmt.Fprintf(w, "Visitor: %s\n", msg.UserName)
+			}
+			fmt.Fprintf(w, "Started: %s\n", msg.Timestamp.Format("2006-01-02 15:04:05"))
+			w.WriteString(strings.Repeat("=", 60) + "\n")
+		}
+
+		userLabel := "User"
+		if msg.UserName != "" {
+			userLabel = "User (" + msg.UserName + ")"
+		}
+		timestamp := msg.Timestamp.Format("15:04")
+		fmt.Fprintf(w, "\n[%s] %s: %s\n", timestamp, userLabel, msg.Message)
+		fmt.Fprintf(w, "[%s] Bot: %s\n", timestamp, msg.Reply)
+
+		count++
+		if count%streamFlushEvery == 0 {
+			flush()
+		}
+	}
+
+	if count == 0 {
+		w.WriteString("\nNo messages found for the specified criteria.\n")
+	}
+	return count, nil
+}
+
+// cursorIterator is the subset of *mongo.Cursor used by the stream writers
+type cursorIterator interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+}
+
+// csvSafeCell prefixes cells that spreadsheets would evaluate as formulas with a quote,
+// so visitor-controlled text like "=HYPERLINK(...)" stays literal
+func csvSafeCell(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
+
+// exportDateRange formats the requested period for export headers
+func exportDateRange(req *ExportRequest) string {
+	switch {
+	case !req.DateFrom.IsZero() && !req.DateTo.IsZero():
+		return fmt.Sprintf("%s to %s", req.DateFrom.Format("2006-01-02"), req.DateTo.Format("2006-01-02"))
+	case !req.DateFrom.IsZero():
+		return fmt.Sprintf("From %s", req.DateFrom.Format("2006-01-02"))
+	case !req.DateTo.IsZero():
+		return fmt.Sprintf("Until %s", req.DateTo.Format("2006-01-02"))
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sliceCursor replays messages through the cursorIterator interface
+type sliceCursor struct {
+	messages []models.Message
+	pos      int
+}
+
+func (c *sliceCursor) Next(ctx context.Context) bool {
+	c.pos++
+	return c.pos <= len(c.messages)
+}
+
+func (c *sliceCursor) Decode(val interface{}) error {
+	*val.(*models.Message) = c.messages[c.pos-1]
+	return nil
+}
+
+func exportTestMessages() []models.Message {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	return []models.Message{
+		{ID: primitive.NewObjectID(), Message: "=HYPERLINK(\"http://evil\")", Reply: "hello", ConversationID: "c1", SessionID: "s1", TokenCost: 5, Timestamp: now},
+		{ID: primitive.NewObjectID(), Message: "-2+3", Reply: "@mention", ConversationID: "c1", SessionID: "s1", TokenCost: 7, Timestamp: now},
+		{ID: primitive.NewObjectID(), Message: "pricing?", Reply: "see plans", ConversationID: "c2", SessionID: "s2", TokenCost: 3, Timestamp: now},
+	}
+}
+
+func TestStreamJSONKeepsExportSchema(t *testing.T) {
+	es := &ExportService{}
+	req := &ExportRequest{Format: "json"}
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	count, err := es.streamJSON(context.Background(), w, &sliceCursor{messages: exportTestMessages()}, &ExportInfo{Format: "json"}, req, func() {})
+	if err != nil {
+		t.Fatalf("streamJSON: %v", err)
+	}
+	w.Flush()
+	if count != 3 {
+		t.Fatalf("count = %d, want 3", count)
+	}
+
+	var data ChatExportData
+	if err := json.Unmarshal(buf.Bytes(), &data); err != nil {
+		t.Fatalf("output is not a ChatExportData document: %v", err)
+	}
+	if len(data.Messages) != 3 {
+		t.Errorf("messages = %d, want 3", len(data.Messages))
+	}
+	if data.Summary.TotalMessages != 3 || data.Summary.TotalTokens != 15 {
+		t.Errorf("summary totals = %d messages, %d tokens; want 3, 15", data.Summary.TotalMessages, data.Summary.TotalTokens)
+	}
+	if data.Summary.ConversationStats.TotalConversations != 2 || data.Summary.ConversationStats.LongestConversation != 2 {
+		t.Errorf("conversation stats = %+v", data.Summary.ConversationStats)
+	}
+}
+
+func TestStreamCSVHasNoCommentsAndEscapesFormulas(t *testing.T) {
+	es := &ExportService{}
+	req := &ExportRequest{Format: "csv"}
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	if _, err := es.streamCSV(context.Background(), w, &sliceCursor{messages: exportTestMessages()}, req, func() {}); err != nil {
+		t.Fatalf("streamCSV: %v", err)
+	}
+	w.Flush()
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if records[0][0] != "id" {
+		t.Fatalf("first row = %v, want the header row", records[0])
+	}
+	if got := records[1][4]; got != "'=HYPERLINK(\"http://evil\")" {
+		t.Errorf("formula message = %q, want it prefixed with a quote", got)
+	}
+	if got := records[2][4]; got != "'-2+3" {
+		t.Errorf("minus-leading message = %q", got)
+	}
+	if got := records[2][5]; got != "'@mention" {
+		t.Errorf("at-leading reply = %q", got)
+	}
+	if got := records[3][4]; got != "pricing?" {
+		t.Errorf("plain message = %q, want it unchanged", got)
+	}
+}
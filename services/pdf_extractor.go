@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"saas-chatbot-platform/internal/ai"
 	"saas-chatbot-platform/internal/config"
 	"saas-chatbot-platform/models"
 
@@ -127,15 +128,38 @@ func (e *PDFExtractor) ExtractText(ctx context.Context, filePath string) (*Extra
 
 // extractWithDeepSeekOCR removed - DeepSeek-OCR dependency eliminated
 
+// GeminiExtractionKey returns the API key for document extraction, so extraction can bill a different
+// quota than chat (GEMINI_EXTRACTION_API_KEY, falling back to GEMINI_API_KEY)
+func GeminiExtractionKey(cfg *config.Config) string {
+	if cfg.GeminiExtractionAPIKey != "" {
+		return cfg.GeminiExtractionAPIKey
+	}
+	return cfg.GeminiAPIKey
+}
+
+// AcquireGeminiExtraction waits for the process-wide extraction throttle (GEMINI_EXTRACTION_CONCURRENCY,
+// GEMINI_EXTRACTION_PER_MINUTE); release must be called once the extraction's Gemini calls are done
+func AcquireGeminiExtraction(ctx context.Context, cfg *config.Config) (func(), error) {
+	return ai.SharedExtractionThrottle(cfg.GeminiExtractionConcurrency, cfg.GeminiExtractionPerMinute).Acquire(ctx)
+}
+
 // extractWithGemini uses Google Gemini API for text extraction
 func (e *PDFExtractor) extractWithGemini(ctx context.Context, content []byte) (*ExtractionResult, error) {
-	if e.config.GeminiAPIKey == "" {
+	apiKey := GeminiExtractionKey(e.config)
+	if apiKey == "" {
 		return nil, fmt.Errorf("gemini API key not configured")
 	}
 
+	// ✅ NEW: Share the extraction throttle so uploads can't starve chat of Gemini quota
+	release, err := AcquireGeminiExtraction(ctx, e.config)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Initialize Gemini client if not already done
 	if e.geminiClient == nil {
-		client, err := genai.NewClient(ctx, option.WithAPIKey(e.config.GeminiAPIKey))
+		client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create gemini client: %w", err)
 		}
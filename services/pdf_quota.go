@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+	"saas-chatbot-platform/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Document quota error codes
+const (
+	QuotaDocumentLimitExceeded = "document_limit_exceeded"
+	QuotaStorageExceeded       = "storage_quota_exceeded"
+)
+
+// QuotaError is returned when accepting an upload would exceed the client's
+// document count or total storage limit
+type QuotaError struct {
+	Code             string
+	MaxDocuments     int
+	DocumentCount    int64
+	MaxTotalBytes    int64
+	StorageUsedBytes int64
+	FileSize         int64
+}
+
+func (e *QuotaError) Error() string {
+	if e.Code == QuotaDocumentLimitExceeded {
+		return fmt.Sprintf("document limit reached (%d of %d)", e.DocumentCount, e.MaxDocuments)
+	}
+	return fmt.Sprintf("storage quota exceeded (%d + %d of %d bytes)", e.StorageUsedBytes, e.FileSize, e.MaxTotalBytes)
+}
+
+// DocumentLimits returns the client's document limits, falling back to platform defaults
+func DocumentLimits(cfg *config.Config, client *models.Client) (int, int64) {
+	maxDocuments := cfg.DefaultMaxDocuments
+	if client.MaxDocuments > 0 {
+		maxDocuments = client.MaxDocuments
+	}
+	maxTotalBytes := cfg.DefaultMaxTotalBytes
+	if client.MaxTotalBytes > 0 {
+		maxTotalBytes = client.MaxTotalBytes
+	}
+	return maxDocuments, maxTotalBytes
+}
+
+// clientIDForms matches documents stored with either an ObjectID client_id
+// (/client/upload) or its hex string (/api/async/upload)
+func clientIDForms(clientID primitive.ObjectID) bson.M {
+	return bson.M{"$in": bson.A{clientID, clientID.Hex()}}
+}
+
+// CheckQuota returns a *QuotaError if storing fileSize more bytes would exceed
+// the client's document count or total storage limit, nil otherwise.
+// Lookup failures never block an upload.
+func (s *PDFService) CheckQuota(ctx context.Context, clientID primitive.ObjectID, fileSize int64) error {
+	if s.clientsCollection == nil {
+		return nil
+	}
+
+	var client models.Client
+	if err := s.clientsCollection.FindOne(ctx, bson.M{"_id": clientID}).Decode(&client); err != nil {
+		logger.Warn("Failed to load client for quota check", "client_id", clientID.Hex(), "error", err)
+		return nil
+	}
+
+	maxDocuments, maxTotalBytes := DocumentLimits(s.config, &client)
+
+	if maxDocuments > 0 {
+		documentCount, err := s.pdfsCollection.CountDocuments(ctx, bson.M{"client_id": clientIDForms(clientID)})
+		if err != nil {
+			logger.Warn("Failed to count client documents", "client_id", clientID.Hex(), "error", err)
+		} else if documentCount >= int64(maxDocuments) {
+			return &QuotaError{
+				Code:          QuotaDocumentLimitExceeded,
+				MaxDocuments:  maxDocuments,
+				DocumentCount: documentCount,
+			}
+		}
+	}
+
+	if maxTotalBytes > 0 && client.StorageUsedBytes+fileSize > maxTotalBytes {
+		return &QuotaError{
+			Code:             QuotaStorageExceeded,
+			MaxTotalBytes:    maxTotalBytes,
+			StorageUsedBytes: client.StorageUsedBytes,
+			FileSize:         fileSize,
+		}
+	}
+
+	return nil
+}
+
+// ChargeStorage adds delta bytes (negative to release) to the client's tracked storage usage
+func (s *PDFService) ChargeStorage(ctx context.Context, clientID primitive.ObjectID, delta int64) {
+	if s.clientsCollection == nil || delta == 0 {
+		return
+	}
+	_, err := s.clientsCollection.UpdateOne(ctx, bson.M{"_id": clientID}, bson.M{
+		"$inc": bson.M{"storage_used_bytes": delta},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		logger.Warn("Failed to update storage usage", "client_id", clientID.Hex(), "delta", delta, "error", err)
+		return
+	}
+	// Never let the counter drift below zero (e.g. documents uploaded before tracking existed)
+	if delta < 0 {
+		s.clientsCollection.UpdateOne(ctx,
+			bson.M{"_id": clientID, "storage_used_bytes": bson.M{"$lt": 0}},
+			bson.M{"$set": bson.M{"storage_used_bytes": 0}})
+	}
+}
+
+// DeletePDFs deletes the client's PDFs matching filter and releases their bytes
+// from the client's storage usage. client_id is added to filter.
+func (s *PDFService) DeletePDFs(ctx context.Context, clientID primitive.ObjectID, filter bson.M) (int64, error) {
+	scoped := bson.M{"client_id": clientIDForms(clientID)}
+	for k, v := range filter {
+		scoped[k] = v
+	}
+
+	freedBytes, err := s.sumSizes(ctx, scoped)
+	if err != nil {
+		logger.Warn("Failed to sum PDF sizes before delete", "client_id", clientID.Hex(), "error", err)
+	}
+
+	result, err := s.pdfsCollection.DeleteMany(ctx, scoped)
+	if err != nil {
+		return 0, err
+	}
+	if result.DeletedCount > 0 {
+		s.ChargeStorage(ctx, clientID, -freedBytes)
+	}
+	return result.DeletedCount, nil
+}
+
+// sumSizes returns the combined size of PDFs matching filter; documents from
+// /client/upload keep it in metadata.size, async uploads in size
+func (s *PDFService) sumSizes(ctx context.Context, filter bson.M) (int64, error) {
+	cursor, err := s.pdfsCollection.Aggregate(ctx, []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": bson.M{
+			"$ifNull": bson.A{"$metadata.size", bson.M{"$ifNull": bson.A{"$size", 0}}},
+		}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Total int64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Total, nil
+}
@@ -26,22 +26,24 @@ import (
 
 // PDFService provides secure, production-ready PDF processing
 type PDFService struct {
-	config         *config.Config
-	pdfsCollection *mongo.Collection
-	extractor      *PDFExtractor
-	storage        *FileStorageManager
+	config            *config.Config
+	clientsCollection *mongo.Collection // storage quota is tracked on the client document
+	pdfsCollection    *mongo.Collection
+	extractor         *PDFExtractor
+	storage           *FileStorageManager
 }
 
 // NewPDFService creates a new PDF service instance
-func NewPDFService(cfg *config.Config, pdfsCollection *mongo.Collection) *PDFService {
+func NewPDFService(cfg *config.Config, clientsCollection, pdfsCollection *mongo.Collection) *PDFService {
 	storage := NewFileStorageManager(cfg)
 	extractor := NewPDFExtractor(cfg)
 
 	return &PDFService{
-		config:         cfg,
-		pdfsCollection: pdfsCollection,
-		extractor:      extractor,
-		storage:        storage,
+		config:            cfg,
+		clientsCollection: clientsCollection,
+		pdfsCollection:    pdfsCollection,
+		extractor:         extractor,
+		storage:           storage,
 	}
 }
 
@@ -50,6 +52,7 @@ type FileStorageManager struct {
 	config    *config.Config
 	uploadDir string
 	tempDir   string
+	scanner   VirusScanner // nil when virus scanning is disabled
 }
 
 // NewFileStorageManager creates a new file storage manager
@@ -70,6 +73,7 @@ func NewFileStorageManager(cfg *config.Config) *FileStorageManager {
 		config:    cfg,
 		uploadDir: uploadDir,
 		tempDir:   tempDir,
+		scanner:   NewVirusScanner(cfg),
 	}
 }
 
@@ -80,12 +84,14 @@ type SecureUploadRequest struct {
 	ClientID primitive.ObjectID
 	UserID   primitive.ObjectID
 	IsAsync  bool
+	Force    bool // Store and process the file even if an identical one was already uploaded
 }
 
 // UploadResult represents the result of an upload operation
 type UploadResult struct {
-	PDF    *models.PDF
-	TaskID string // For async processing
+	PDF       *models.PDF
+	TaskID    string // For async processing
+	Duplicate bool   // True when an identical file already existed for the client
 }
 
 // ValidateAndProcessUpload validates and processes a PDF upload
@@ -101,18 +107,26 @@ func (s *PDFService) ValidateAndProcessUpload(ctx context.Context, req *SecureUp
 		return nil, fmt.Errorf("file storage failed: %w", err)
 	}
 
-	// Step 3: Check for duplicates
-	existingPDF, err := s.checkDuplicate(ctx, req.ClientID, fileInfo.Hash)
-	if err != nil {
-		s.storage.Cleanup(fileInfo.Path) // Clean up on error
-		return nil, fmt.Errorf("duplicate check failed: %w", err)
+	// Step 3: Check for duplicates (unless the caller forces a re-upload)
+	if !req.Force {
+		existingPDF, err := s.checkDuplicate(ctx, req.ClientID, fileInfo.Hash)
+		if err != nil {
+			s.storage.Cleanup(fileInfo.Path) // Clean up on error
+			return nil, fmt.Errorf("duplicate check failed: %w", err)
+		}
+		if existingPDF != nil {
+			s.storage.Cleanup(fileInfo.Path) // Clean up duplicate
+			return &UploadResult{PDF: existingPDF, Duplicate: true}, nil
+		}
 	}
-	if existingPDF != nil {
-		s.storage.Cleanup(fileInfo.Path) // Clean up duplicate
-		return &UploadResult{PDF: existingPDF}, nil
+
+	// Step 4: Enforce the client's document count and storage limits (duplicates above cost nothing)
+	if err := s.CheckQuota(ctx, req.ClientID, fileInfo.Size); err != nil {
+		s.storage.Cleanup(fileInfo.Path)
+		return nil, err
 	}
 
-	// Step 4: Create PDF document record
+	// Step 5: Create PDF document record
 	pdfDoc := &models.PDF{
 		ID:           primitive.NewObjectID(),
 		ClientID:     req.ClientID,
@@ -128,13 +142,14 @@ func (s *PDFService) ValidateAndProcessUpload(ctx context.Context, req *SecureUp
 		},
 	}
 
-	// Step 5: Save to database
+	// Step 6: Save to database and charge the stored bytes to the client
 	if _, err := s.pdfsCollection.InsertOne(ctx, pdfDoc); err != nil {
 		s.storage.Cleanup(fileInfo.Path) // Clean up on error
 		return nil, fmt.Errorf("database save failed: %w", err)
 	}
+	s.ChargeStorage(ctx, req.ClientID, fileInfo.Size)
 
-	// Step 6: Process based on size and async flag
+	// Step 7: Process based on size and async flag
 	result := &UploadResult{PDF: pdfDoc}
 
 	if req.IsAsync || fileInfo.Size > s.config.SyncProcessingLimit {
@@ -266,6 +281,13 @@ func (sm *FileStorageManager) SecureStore(file multipart.File, header *multipart
 		}
 	}
 
+	// Virus scan before the file is moved anywhere it could be processed or served
+	// (infected files are quarantined by the scan)
+	if err := ScanUploadedFile(sm.config, sm.scanner, tempPath, clientID); err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+
 	// Move to final location
 	if err := os.Rename(tempPath, filePath); err != nil {
 		os.Remove(tempPath) // Clean up temp file
@@ -443,14 +465,14 @@ func (sm *FileStorageManager) generateSecureFilename(originalName string) string
 	return fmt.Sprintf("%s_%s_%s%s", timestamp, randomPrefix, safeName, ext)
 }
 
-// checkDuplicate checks if a file with the same hash already exists
+// checkDuplicate checks if a file with the same hash already exists (processed or still being processed)
 func (s *PDFService) checkDuplicate(ctx context.Context, clientID primitive.ObjectID, fileHash string) (*models.PDF, error) {
 	var existingPDF models.PDF
 	err := s.pdfsCollection.FindOne(ctx, bson.M{
 		"client_id": clientID,
 		"file_hash": fileHash,
-		"status":    bson.M{"$in": []string{models.StatusCompleted, models.StatusProcessing}},
-	}).Decode(&existingPDF)
+		"status":    bson.M{"$in": []string{models.StatusCompleted, models.StatusProcessing, models.StatusPending}},
+	}, options.FindOne().SetSort(bson.M{"uploaded_at": 1})).Decode(&existingPDF)
 
 	if err == mongo.ErrNoDocuments {
 		return nil, nil // No duplicate found
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const telegramAPIBaseURL = "https://api.telegram.org"
+
+// telegramMaxMessageLength is the Bot API limit for a single text message
+const telegramMaxMessageLength = 4096
+
+// TelegramClient calls the Telegram Bot API for a single bot
+type TelegramClient struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// TelegramUpdate is the subset of a Bot API update the webhook handles
+type TelegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *TelegramMessage `json:"message,omitempty"`
+}
+
+// TelegramMessage is an incoming Telegram message
+type TelegramMessage struct {
+	MessageID int64         `json:"message_id"`
+	From      *TelegramUser `json:"from,omitempty"`
+	Chat      TelegramChat  `json:"chat"`
+	Date      int64         `json:"date"`
+	Text      string        `json:"text"`
+}
+
+// TelegramUser is the sender of a Telegram message
+type TelegramUser struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// TelegramChat identifies the chat a message belongs to
+type TelegramChat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+// TelegramBotInfo is the result of getMe
+type TelegramBotInfo struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// NewTelegramClient creates a new Telegram Bot API client
+func NewTelegramClient(token string) *TelegramClient {
+	return &TelegramClient{
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		baseURL: telegramAPIBaseURL,
+		token:   token,
+	}
+}
+
+// GetMe validates the bot token and returns the bot identity
+func (c *TelegramClient) GetMe(ctx context.Context) (*TelegramBotInfo, error) {
+	var info TelegramBotInfo
+	if err := c.call(ctx, "getMe", map[string]interface{}{}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// SetWebhook registers the webhook URL with a secret token Telegram echoes back on every update
+func (c *TelegramClient) SetWebhook(ctx context.Context, webhookURL, secretToken string) error {
+	return c.call(ctx, "setWebhook", map[string]interface{}{
+		"url":             webhookURL,
+		"secret_token":    secretToken,
+		"allowed_updates": []string{"message"},
+	}, nil)
+}
+
+// SendMessage sends a plain text message, truncated to the Bot API limit
+func (c *TelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	if runes := []rune(text); len(runes) > telegramMaxMessageLength {
+		text = string(runes[:telegramMaxMessageLength])
+	}
+	return c.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}, nil)
+}
+
+// call performs a Bot API method and decodes its result into out (if non-nil)
+func (c *TelegramClient) call(ctx context.Context, method string, params map[string]interface{}, out interface{}) error {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telegram %s request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/bot%s/%s", c.baseURL, c.token, method), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create telegram %s request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Don't wrap the error: it contains the URL and therefore the bot token
+		return fmt.Errorf("telegram %s request failed", method)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read telegram %s response: %w", method, err)
+	}
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to decode telegram %s response: %w", method, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram %s failed (status %d): %s", method, resp.StatusCode, result.Description)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(result.Result, out); err != nil {
+			return fmt.Errorf("failed to decode telegram %s result: %w", method, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"saas-chatbot-platform/internal/config"
+	"saas-chatbot-platform/internal/logger"
+)
+
+// ErrScanUnavailable is returned when a configured virus scanner can't be reached and VIRUS_SCAN_FAIL_OPEN is off
+var ErrScanUnavailable = errors.New("virus scanner unavailable")
+
+// InfectedFileError is returned for uploads the virus scanner flagged; the file has been quarantined
+type InfectedFileError struct {
+	Signature      string
+	Scanner        string
+	QuarantinePath string
+}
+
+func (e *InfectedFileError) Error() string {
+	return fmt.Sprintf("file rejected: malware detected (%s)", e.Signature)
+}
+
+// ScanResult is a scanner's verdict on one file
+type ScanResult struct {
+	Infected  bool
+	Signature string
+}
+
+// VirusScanner scans uploaded content before it is processed
+type VirusScanner interface {
+	Scan(ctx context.Context, r io.Reader) (*ScanResult, error)
+	Name() string
+}
+
+// NewVirusScanner returns the scanner configured for this deployment, or nil when scanning is disabled.
+// A clamd address takes precedence over a scanning API.
+func NewVirusScanner(cfg *config.Config) VirusScanner {
+	timeout := virusScanTimeout(cfg)
+	switch {
+	case cfg.VirusScanClamdAddress != "":
+		network, address := parseClamdAddress(cfg.VirusScanClamdAddress)
+		return &clamdScanner{network: network, address: address, timeout: timeout}
+	case cfg.VirusScanAPIURL != "":
+		return &httpScanner{
+			url:    cfg.VirusScanAPIURL,
+			apiKey: cfg.VirusScanAPIKey,
+			client: &http.Client{Timeout: timeout},
+		}
+	}
+	return nil
+}
+
+// virusScanTimeout bounds one scan, including connecting to the scanner
+func virusScanTimeout(cfg *config.Config) time.Duration {
+	if cfg.VirusScanTimeout > 0 {
+		return time.Duration(cfg.VirusScanTimeout) * time.Second
+	}
+	return 60 * time.Second
+}
+
+// parseClamdAddress accepts unix:///path/clamd.sock, tcp://host:3310 or a bare host:port
+func parseClamdAddress(raw string) (string, string) {
+	if u, err := url.Parse(raw); err == nil {
+		switch u.Scheme {
+		case "unix":
+			return "unix", u.Path
+		case "tcp":
+			return "tcp", u.Host
+		}
+	}
+	if strings.HasPrefix(raw, "/") {
+		return "unix", raw
+	}
+	return "tcp", raw
+}
+
+// clamdScanner streams files to a ClamAV daemon with the INSTREAM command
+type clamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func (s *clamdScanner) Name() string { return "clamd" }
+
+func (s *clamdScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to start clamd scan: %w", err)
+	}
+
+	// Each chunk is prefixed with its length as a 4-byte big-endian integer; a zero length ends the stream
+	buf := make([]byte, 32*1024)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read upload for scanning: %w", readErr)
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseClamdReply reads "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+func parseClamdReply(reply string) (*ScanResult, error) {
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case reply == "OK":
+		return &ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+	return nil, fmt.Errorf("clamd error: %s", reply)
+}
+
+// httpScanner posts the file body to an external scanning API, which must answer
+// {"infected": bool, "signature": "..."}
+type httpScanner struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func (s *httpScanner) Name() string { return "api" }
+
+func (s *httpScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("scanning API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scanning API returned HTTP %d", resp.StatusCode)
+	}
+
+	var verdict struct {
+		Infected  bool   `json:"infected"`
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&verdict); err != nil {
+		return nil, fmt.Errorf("invalid scanning API response: %w", err)
+	}
+	if verdict.Infected && verdict.Signature == "" {
+		verdict.Signature = "unknown"
+	}
+	return &ScanResult{Infected: verdict.Infected, Signature: verdict.Signature}, nil
+}
+
+// ScanUploadedFile scans a stored upload. Infected files are moved to the quarantine directory and an
+// *InfectedFileError is returned. A nil scanner makes this a no-op.
+func ScanUploadedFile(cfg *config.Config, scanner VirusScanner, path, clientID string) error {
+	if scanner == nil {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open upload for scanning: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), virusScanTimeout(cfg))
+	defer cancel()
+	result, err := scanner.Scan(ctx, file)
+	file.Close()
+
+	if err != nil {
+		if cfg.VirusScanFailOpen {
+			logger.Warn("Virus scan skipped (fail-open)", "file", filepath.Base(path), "error", err)
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrScanUnavailable, err)
+	}
+	if !result.Infected {
+		return nil
+	}
+
+	infected := &InfectedFileError{Signature: result.Signature, Scanner: scanner.Name()}
+	quarantinePath, qErr := quarantineFile(cfg, path, clientID)
+	if qErr != nil {
+		logger.Error("Failed to quarantine infected upload", "file", path, "error", qErr)
+		os.Remove(path)
+	} else {
+		infected.QuarantinePath = quarantinePath
+	}
+	return infected
+}
+
+// quarantineFile moves a file into <storage>/quarantine/<client> where nothing processes or serves it
+func quarantineFile(cfg *config.Config, path, clientID string) (string, error) {
+	baseDir := cfg.FileStorageDir
+	if baseDir == "" {
+		baseDir = "./storage"
+	}
+	dir := filepath.Join(baseDir, "quarantine", clientID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	dest := filepath.Join(dir, fmt.Sprintf("%d_%s.quarantined", time.Now().Unix(), filepath.Base(path)))
+	if err := os.Rename(path, dest); err != nil {
+		return "", err
+	}
+	os.Chmod(dest, 0400)
+	return dest, nil
+}
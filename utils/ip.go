@@ -235,6 +235,39 @@ func isPrivateIP(ip string) bool {
 	return parsedIP.IsPrivate() || parsedIP.IsLoopback() || parsedIP.IsUnspecified()
 }
 
+// AnonymizeIP truncates an IP address so it no longer identifies a single device: the last octet of an
+// IPv4 address and the last 80 bits of an IPv6 address are zeroed (keeping the /24 or /48 network).
+// Country and region lookups still work on the result. Invalid input is returned unchanged.
+func AnonymizeIP(ip string) string {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return ip
+	}
+	if v4 := parsedIP.To4(); v4 != nil {
+		return net.IP{v4[0], v4[1], v4[2], 0}.String()
+	}
+	return parsedIP.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// CoarseGeolocation keeps only country, region and timezone of geolocation data, dropping the city,
+// coordinates and network operator that can narrow a visitor down
+func CoarseGeolocation(geoData *GeolocationData) *GeolocationData {
+	return &GeolocationData{
+		IP:          geoData.IP,
+		Status:      geoData.Status,
+		Message:     geoData.Message,
+		Continent:   geoData.Continent,
+		Country:     geoData.Country,
+		CountryCode: geoData.CountryCode,
+		Region:      geoData.Region,
+		RegionName:  geoData.RegionName,
+		Timezone:    geoData.Timezone,
+		Mobile:      geoData.Mobile,
+		Proxy:       geoData.Proxy,
+		Hosting:     geoData.Hosting,
+	}
+}
+
 // GetCountryFromIP performs basic IP-based country detection
 // This is a simple implementation - in production, you might want to use a service like MaxMind
 func GetCountryFromIP(ip string) string {
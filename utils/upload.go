@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+)
+
+// PDFSignature is the magic number every PDF file starts with
+var PDFSignature = []byte("%PDF-")
+
+// UploadSniffLength is how many leading bytes ValidatePDFUpload inspects
+const UploadSniffLength = 512
+
+// allowedPDFContentTypes are the declared MIME types accepted for PDF uploads; browsers and some HTTP
+// clients send octet-stream or nothing for files they don't recognise
+var allowedPDFContentTypes = map[string]bool{
+	"":                         true,
+	"application/pdf":          true,
+	"application/x-pdf":        true,
+	"application/acrobat":      true,
+	"application/octet-stream": true,
+}
+
+// knownFileSignatures name common non-PDF formats so a rejected upload can say what it actually is
+// (signatures are lower case and matched against the ASCII-lowered file start)
+var knownFileSignatures = []struct {
+	magic []byte
+	kind  string
+}{
+	{[]byte("mz"), "Windows executable"},
+	{[]byte("\x7felf"), "ELF executable"},
+	{[]byte("pk\x03\x04"), "ZIP archive or Office document"},
+	{[]byte("\x89png"), "PNG image"},
+	{[]byte("\xff\xd8\xff"), "JPEG image"},
+	{[]byte("gif8"), "GIF image"},
+	{[]byte("<!doctype html"), "HTML document"},
+	{[]byte("<html"), "HTML document"},
+	{[]byte("#!"), "script"},
+}
+
+// ValidatePDFUpload checks a PDF upload's extension, declared Content-Type and leading bytes, and returns
+// the error to send back when any of them doesn't fit a PDF (nil when the upload looks valid)
+func ValidatePDFUpload(filename, contentType string, head []byte) *ErrorResponse {
+	if !strings.EqualFold(filepath.Ext(filename), ".pdf") {
+		return &ErrorResponse{
+			ErrorCode: "invalid_file_extension",
+			Message:   "Only files with a .pdf extension are allowed",
+		}
+	}
+
+	mediaType := ""
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return &ErrorResponse{
+				ErrorCode: "invalid_mime_type",
+				Message:   "The file's Content-Type could not be parsed",
+			}
+		}
+		mediaType = strings.ToLower(parsed)
+	}
+	if !allowedPDFContentTypes[mediaType] {
+		return &ErrorResponse{
+			ErrorCode: "invalid_mime_type",
+			Message:   "Only PDF files are allowed (received " + mediaType + ")",
+		}
+	}
+
+	if !bytes.HasPrefix(head, PDFSignature) {
+		message := "File content does not match the PDF signature"
+		lowered := asciiLower(bytes.TrimLeft(head, " \t\r\n"))
+		for _, sig := range knownFileSignatures {
+			if bytes.HasPrefix(lowered, sig.magic) {
+				message += " (detected: " + sig.kind + ")"
+				break
+			}
+		}
+		return &ErrorResponse{
+			ErrorCode: "invalid_file_signature",
+			Message:   message,
+		}
+	}
+	return nil
+}
+
+// asciiLower lower-cases ASCII letters and leaves every other byte untouched
+func asciiLower(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// ValidatePDFFileHeader reads the start of an uploaded file and validates it with ValidatePDFUpload. It
+// uses ReadAt so the file can still be processed from the beginning.
+func ValidatePDFFileHeader(file multipart.File, header *multipart.FileHeader) *ErrorResponse {
+	head := make([]byte, UploadSniffLength)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return &ErrorResponse{
+			ErrorCode: "invalid_file",
+			Message:   "Cannot read file header",
+		}
+	}
+	return ValidatePDFUpload(header.Filename, header.Header.Get("Content-Type"), head[:n])
+}
@@ -0,0 +1,62 @@
+package utils
+
+import "testing"
+
+func TestValidatePDFUpload(t *testing.T) {
+	pdf := []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n")
+
+	tests := []struct {
+		name        string
+		filename    string
+		contentType string
+		head        []byte
+		wantCode    string
+	}{
+		{"valid pdf", "brochure.pdf", "application/pdf", pdf, ""},
+		{"upper case extension", "BROCHURE.PDF", "application/pdf", pdf, ""},
+		{"octet-stream content type", "brochure.pdf", "application/octet-stream", pdf, ""},
+		{"no content type", "brochure.pdf", "", pdf, ""},
+		{"content type with parameters", "brochure.pdf", "application/pdf; name=brochure.pdf", pdf, ""},
+		{"executable renamed to pdf", "invoice.pdf", "application/pdf", []byte("MZ\x90\x00\x03\x00\x00\x00"), "invalid_file_signature"},
+		{"elf renamed to pdf", "invoice.pdf", "application/pdf", []byte("\x7fELF\x02\x01\x01"), "invalid_file_signature"},
+		{"zip renamed to pdf", "report.pdf", "application/pdf", []byte("PK\x03\x04\x14\x00"), "invalid_file_signature"},
+		{"html renamed to pdf", "page.pdf", "application/pdf", []byte("<!DOCTYPE html><html>"), "invalid_file_signature"},
+		{"signature not at start", "late.pdf", "application/pdf", append([]byte("junk"), pdf...), "invalid_file_signature"},
+		{"empty file", "empty.pdf", "application/pdf", nil, "invalid_file_signature"},
+		{"double extension", "invoice.pdf.exe", "application/pdf", pdf, "invalid_file_extension"},
+		{"pdf with exe extension", "invoice.exe", "application/pdf", pdf, "invalid_file_extension"},
+		{"no extension", "invoice", "application/pdf", pdf, "invalid_file_extension"},
+		{"executable mime type", "invoice.pdf", "application/x-msdownload", pdf, "invalid_mime_type"},
+		{"html mime type", "invoice.pdf", "text/html", pdf, "invalid_mime_type"},
+		{"malformed mime type", "invoice.pdf", "application/pdf; =", pdf, "invalid_mime_type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidatePDFUpload(tt.filename, tt.contentType, tt.head)
+			if tt.wantCode == "" {
+				if got != nil {
+					t.Fatalf("expected upload to be accepted, got %s: %s", got.ErrorCode, got.Message)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("expected %s, upload was accepted", tt.wantCode)
+			}
+			if got.ErrorCode != tt.wantCode {
+				t.Fatalf("expected %s, got %s: %s", tt.wantCode, got.ErrorCode, got.Message)
+			}
+		})
+	}
+}
+
+func TestValidatePDFUploadNamesDetectedType(t *testing.T) {
+	got := ValidatePDFUpload("setup.pdf", "application/pdf", []byte("MZ\x90\x00"))
+	if got == nil {
+		t.Fatal("expected executable to be rejected")
+	}
+	want := "File content does not match the PDF signature (detected: Windows executable)"
+	if got.Message != want {
+		t.Fatalf("message = %q, want %q", got.Message, want)
+	}
+}